@@ -18,18 +18,32 @@ type JwtUserClaim struct {
 	ExternalProfile string `json:"externaltype"`
 	DisplayName     string `json:"displayname"`
 	Avatar          string `json:"avatar"`
+	/* nil in the tokens made before the roles */
+	Permissions []string `json:"permissions"`
+	/* the guid of the tenant, empty for all data */
+	Tenant string `json:"tenant"`
+	/* 0 in the tokens made before the partitions */
+	PartID     int  `json:"partid"`
+	SuperAdmin bool `json:"superadmin"`
 	jwt.StandardClaims
 }
 
-func Token(user model.TableUser) (string, error) {
-
-	tNow := time.Now()
-	tUTC := tNow
+// GrantedPermissions are the permissions of the token, all for admins and the default
+// ones for a token made before the roles
+func (claims *JwtUserClaim) GrantedPermissions() []string {
+	switch {
+	case claims.UserAdmin:
+		return model.Permissions
+	case claims.Permissions == nil:
+		return model.DefaultPermissions
+	}
+	return claims.Permissions
+}
 
-	newTUTC := tUTC.Add(time.Duration(config.Setting.AUTH_SETTINGS.AuthTokenExpire) * time.Minute)
+// Claims returns the claims of the user in the JWTs of the session
+func Claims(user model.TableUser, session string) *JwtUserClaim {
 
-	// Set custom claims
-	claims := &JwtUserClaim{
+	return &JwtUserClaim{
 		user.UserName,
 		user.IsAdmin,
 		user.UserGroup,
@@ -37,10 +51,26 @@ func Token(user model.TableUser) (string, error) {
 		user.ExternalProfile,
 		user.FirstName + " " + user.LastName,
 		user.Avatar,
+		user.Permissions,
+		user.TenantGUID,
+		model.Partition(user.PartId),
+		user.IsAdmin && user.SuperAdmin,
 		jwt.StandardClaims{
-			ExpiresAt: newTUTC.Unix(),
+			Id: session,
 		},
 	}
+}
+
+// Token signs the claims, the JWT is valid for AccessTokenExpire minutes
+func Token(claims *JwtUserClaim) (string, error) {
+
+	tNow := time.Now()
+	tUTC := tNow
+
+	newTUTC := tUTC.Add(time.Duration(config.Setting.AUTH_SETTINGS.AccessTokenExpire) * time.Minute)
+
+	claims.IssuedAt = tUTC.Unix()
+	claims.ExpiresAt = newTUTC.Unix()
 
 	logger.Debug("Current time : ", tNow)
 	logger.Debug("Local time : ", tUTC)
@@ -2,6 +2,9 @@ package auth
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
@@ -9,6 +12,85 @@ import (
 	"github.com/sipcapture/homer-app/utils/logger"
 )
 
+/* routes which need a token scope other than api */
+var scopedRoutes = struct {
+	sync.RWMutex
+	routes map[string]string
+}{routes: map[string]string{}}
+
+// ScopeRoute restricts the route to auth tokens with the scope and to admin users
+func ScopeRoute(route *echo.Route, scope string) {
+	scopedRoutes.Lock()
+	defer scopedRoutes.Unlock()
+	scopedRoutes.routes[route.Method+" "+route.Path] = scope
+}
+
+// RouteScope returns the scope a token needs for the route of the request
+func RouteScope(c echo.Context) string {
+	scopedRoutes.RLock()
+	defer scopedRoutes.RUnlock()
+	if scope, ok := scopedRoutes.routes[c.Request().Method+" "+c.Path()]; ok {
+		return scope
+	}
+	return model.TokenScopeAPI
+}
+
+/* routes which take the token from the query, browsers can't set headers on WebSockets */
+var queryTokenRoutes = struct {
+	sync.RWMutex
+	routes map[string]bool
+}{routes: map[string]bool{}}
+
+// QueryTokenRoute lets the route take the JWT from ?token= and the auth token from ?auth_token=
+func QueryTokenRoute(route *echo.Route) {
+	queryTokenRoutes.Lock()
+	defer queryTokenRoutes.Unlock()
+	queryTokenRoutes.routes[route.Method+" "+route.Path] = true
+}
+
+// QueryToken returns the query parameter if the route of the request accepts tokens there
+func QueryToken(c echo.Context, name string) string {
+	queryTokenRoutes.RLock()
+	defer queryTokenRoutes.RUnlock()
+	if queryTokenRoutes.routes[c.Request().Method+" "+c.Path()] {
+		return c.QueryParam(name)
+	}
+	return ""
+}
+
+// QueryTokenExtractor hands ?token= to the JWT middleware on routes registered with QueryTokenRoute
+func QueryTokenExtractor(c echo.Context) ([]string, error) {
+	if token := QueryToken(c, "token"); token != "" {
+		return []string{token}, nil
+	}
+	return nil, fmt.Errorf("no token in the query")
+}
+
+/* tells if a session has been revoked, set by the session service */
+var revocation = struct {
+	sync.RWMutex
+	revoked func(session string) bool
+}{}
+
+// RevocationCheck sets how the JWT middleware finds out that the session of a token has
+// been revoked
+func RevocationCheck(revoked func(session string) bool) {
+	revocation.Lock()
+	defer revocation.Unlock()
+	revocation.revoked = revoked
+}
+
+// SessionRevoked checks the session of a JWT, the tokens made before the sessions
+// have none and can't be revoked, they are refused
+func SessionRevoked(session string) bool {
+	if session == "" {
+		return true
+	}
+	revocation.RLock()
+	defer revocation.RUnlock()
+	return revocation.revoked != nil && revocation.revoked(session)
+}
+
 func MiddlewareRes(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 
@@ -18,12 +100,23 @@ func MiddlewareRes(next echo.HandlerFunc) echo.HandlerFunc {
 			logger.Debug("Claims")
 			logger.Debug(claims)
 
+			if SessionRevoked(claims.Id) {
+				return echo.NewHTTPError(401, "The session has ended, please log in again")
+			}
+
+			if scope := RouteScope(c); scope != model.TokenScopeAPI && !claims.UserAdmin {
+				return echo.NewHTTPError(403, fmt.Sprintf("This API requires admin access or a token with the scope [%s]", scope))
+			}
+
 			appContext := model.AppContext{
 				Context:      c,
 				UserName:     claims.UserName,
 				Admin:        claims.UserAdmin,
 				UserGroup:    claims.UserGroup,
 				ExternalAuth: claims.ExternalAuth,
+				Permissions:  GetPermissions(c),
+				Tenant:       claims.Tenant,
+				PartID:       model.Partition(claims.PartID),
 			}
 			if err := next(appContext); err != nil {
 				c.Error(err)
@@ -39,6 +132,10 @@ func MiddlewareRes(next echo.HandlerFunc) echo.HandlerFunc {
 			logger.Debug("Authkey: ", tokenKey.AuthKey)
 			logger.Debug(tokenKey)
 
+			if scope := RouteScope(c); !tokenKey.HasScope(scope) {
+				return echo.NewHTTPError(403, fmt.Sprintf("The AuthToken in use doesn't have the scope [%s]", scope))
+			}
+
 			if err := next(tokenKey); err != nil {
 				c.Error(err)
 			}
@@ -49,30 +146,41 @@ func MiddlewareRes(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
-func IsAdmin(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		if c.Get("user") != nil {
-			user := c.Get("user").(*jwt.Token)
-			claims := user.Claims.(*JwtUserClaim)
-			isAdmin := claims.UserAdmin
-			if !isAdmin {
-				return echo.NewHTTPError(403, "This API requires admin access.")
+// RequirePermission lets only users and auth tokens with the permission use the route
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !HasPermission(c, permission) {
+				return echo.NewHTTPError(403, fmt.Sprintf("This API requires the permission [%s]", permission))
 			}
 			return next(c)
-		} else if c.Get("authtoken") != nil {
+		}
+	}
+}
 
-			tokenKey := c.Get("authtoken").(model.KeyContext)
-			isAdmin := tokenKey.UserAdmin
+// GetPermissions returns the permissions of the user or auth token of the request
+func GetPermissions(c echo.Context) []string {
 
-			if !isAdmin {
-				return echo.NewHTTPError(403, "This API requires admin access. The AuthToken in use!")
-			}
-			return next(c)
-		} else {
-			//return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusOK, []byte(reply))
-			return echo.NewHTTPError(403, "This API requires admin access!")
+	if c.Get("user") != nil {
+		return c.Get("user").(*jwt.Token).Claims.(*JwtUserClaim).GrantedPermissions()
+	} else if c.Get("authtoken") != nil {
+		tokenKey := c.Get("authtoken").(model.KeyContext)
+		if tokenKey.UserAdmin {
+			return model.Permissions
+		}
+		return tokenKey.Permissions
+	}
+	return nil
+}
+
+// HasPermission checks the permission of the user or auth token of the request
+func HasPermission(c echo.Context, permission string) bool {
+	for _, val := range GetPermissions(c) {
+		if val == permission {
+			return true
 		}
 	}
+	return false
 }
 
 /* check if it's admin */
@@ -106,6 +214,111 @@ func GetUserGroup(c echo.Context) string {
 	}
 }
 
+/* get the login session of the JWT, auth tokens have none */
+func GetSession(c echo.Context) string {
+
+	if c.Get("user") != nil {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(*JwtUserClaim)
+		return claims.Id
+	}
+	return ""
+}
+
+// SessionAlive returns a check of the JWT or auth token of the request for connections
+// which outlive it: it fails once the token has expired or its session has been revoked
+func SessionAlive(c echo.Context) func() bool {
+
+	if c.Get("user") != nil {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(*JwtUserClaim)
+		session, expiresAt := claims.Id, claims.ExpiresAt
+		return func() bool {
+			return (expiresAt == 0 || time.Now().Unix() < expiresAt) && !SessionRevoked(session)
+		}
+	} else if c.Get("authtoken") != nil {
+		tokenKey := c.Get("authtoken").(model.KeyContext)
+		expireDate := tokenKey.TokenObject.ExpireDate
+		return func() bool {
+			return expireDate.IsZero() || time.Now().Before(expireDate)
+		}
+	}
+	return func() bool { return false }
+}
+
+/* get the tenant, empty if the data of all tenants can be seen */
+func GetTenant(c echo.Context) string {
+
+	if c.Get("user") != nil {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(*JwtUserClaim)
+		return claims.Tenant
+	} else if c.Get("authtoken") != nil {
+		tokenKey := c.Get("authtoken").(model.KeyContext)
+		return tokenKey.Tenant
+	}
+	return ""
+}
+
+/* get the partition of the config of the user */
+func GetPartition(c echo.Context) int {
+
+	if c.Get("user") != nil {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(*JwtUserClaim)
+		return model.Partition(claims.PartID)
+	} else if c.Get("authtoken") != nil {
+		tokenKey := c.Get("authtoken").(model.KeyContext)
+		return model.Partition(tokenKey.PartID)
+	}
+	return model.DefaultPartition
+}
+
+/* check if the user manages all partitions, auth tokens never do */
+func IsSuperAdmin(c echo.Context) bool {
+
+	if c.Get("user") != nil {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(*JwtUserClaim)
+		return claims.UserAdmin && claims.SuperAdmin
+	}
+	return false
+}
+
+// RequestPartition returns the partition a request works on: the one of the user or,
+// for a super admin, the one of the partid query parameter
+func RequestPartition(c echo.Context) int {
+
+	if IsSuperAdmin(c) {
+		if partid, err := strconv.Atoi(c.QueryParam("partid")); err == nil && partid > 0 {
+			return partid
+		}
+	}
+	return GetPartition(c)
+}
+
+// AdminPartition returns the partition an admin API lists, 0 for all of them when a
+// super admin doesn't ask for one
+func AdminPartition(c echo.Context) int {
+
+	if IsSuperAdmin(c) && c.QueryParam("partid") == "" {
+		return 0
+	}
+	return RequestPartition(c)
+}
+
+// RequireSuperAdmin lets only the admins of all partitions use the route
+func RequireSuperAdmin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !IsSuperAdmin(c) {
+				return echo.NewHTTPError(403, "This API requires super admin access")
+			}
+			return next(c)
+		}
+	}
+}
+
 /* get user group */
 func GetUserProfile(c echo.Context) (*JwtUserClaim, error) {
 
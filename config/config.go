@@ -2,14 +2,51 @@ package config
 
 import (
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/oidc"
 	"golang.org/x/oauth2"
 )
 
 var Setting HomerSettingServer
 
-var OAuth2TokenMap map[string]model.OAuth2MapToken
+// OAuth2TokenMap keeps the one time tokens of the OAuth2 and OpenID Connect logins until
+// they are swapped at /oauth2/token, the callbacks and the swaps run at once
+var OAuth2TokenMap = &OAuth2TokenStore{tokens: map[string]model.OAuth2MapToken{}}
+
+type OAuth2TokenStore struct {
+	sync.Mutex
+	tokens map[string]model.OAuth2MapToken
+}
+
+// Add keeps the token, the ones which have expired unused are dropped
+func (ts *OAuth2TokenStore) Add(token string, val model.OAuth2MapToken) {
+
+	ts.Lock()
+	defer ts.Unlock()
+
+	now := time.Now()
+	for key, old := range ts.tokens {
+		if old.ExpireDate.Before(now) {
+			delete(ts.tokens, key)
+		}
+	}
+	ts.tokens[token] = val
+}
+
+// Take returns the token and forgets it, a token can be swapped once. False if it is
+// unknown, true with an expired token which the caller refuses.
+func (ts *OAuth2TokenStore) Take(token string) (model.OAuth2MapToken, bool) {
+
+	ts.Lock()
+	defer ts.Unlock()
+
+	val, ok := ts.tokens[token]
+	delete(ts.tokens, token)
+	return val, ok
+}
 
 type HomerSettingServer struct {
 	MAIN_SETTINGS struct {
@@ -40,6 +77,7 @@ type HomerSettingServer struct {
 	TRANSACTION_SETTINGS struct {
 		DedupModel        string `default:"message-ip-pair"`
 		GlobalDeduplicate bool   `default:"false"`
+		Lint              bool   `default:"false"`
 	}
 
 	DASHBOARD_SETTINGS struct {
@@ -49,7 +87,12 @@ type HomerSettingServer struct {
 	AUTH_SETTINGS struct {
 		JwtSecret       string `default:""`
 		AuthTokenHeader string `default:"Auth-Token"`
+		/* how long a login lasts without being refreshed, in minutes */
 		AuthTokenExpire uint32 `default:"1200"`
+		/* how long a JWT lasts, in minutes */
+		AccessTokenExpire uint32 `default:"15"`
+		/* the issuer in the authenticator apps */
+		MFAIssuer string `default:"Homer"`
 	}
 
 	API_SETTINGS struct {
@@ -83,6 +126,18 @@ type HomerSettingServer struct {
 		ExpireSSOToken       uint32   `default:"5"`
 	}
 
+	OIDC_SETTINGS struct {
+		Enable bool `default:"false"`
+		/* where the providers are listed as login links, /<name> is added */
+		UrlToServiceRedirect string `default:"/api/v3/oidc/login"`
+		/* where the browser goes back with the one time token */
+		UrlToService string `default:"/"`
+		/* the minutes to log in at the provider and to swap the one time token */
+		ExpireLogin    uint32 `default:"10"`
+		ExpireSSOToken uint32 `default:"5"`
+		Providers      []oidc.Config
+	}
+
 	LOG_SETTINGS struct {
 		Enable        bool   `default:"true"`
 		MaxAgeDays    uint32 `default:"7"`
@@ -111,6 +166,86 @@ type HomerSettingServer struct {
 		GID        uint32   `default:"0"`
 		ImportNode string   `default:""`
 		Enable     bool     `default:"false"`
+		Workers    int      `default:"4"`
+		Timeout    int      `default:"10"`
+		CacheSize  int      `default:"1000"`
+	}
+
+	IMPORT_SETTINGS struct {
+		Node            string `default:""`
+		CaptureID       string `default:"0"`
+		FallbackProfile string `default:"200_default"`
+		BatchSize       int    `default:"500"`
+		KeepJobs        int    `default:"100"`
+		TEXT_LOG        struct {
+			Format          string `default:"auto"`
+			TimestampRegex  string `default:""`
+			TimestampLayout string `default:""`
+			AddressRegex    string `default:""`
+			LocalIP         string `default:"127.0.0.1"`
+			LocalPort       int    `default:"5060"`
+		}
+	}
+	HEP_RELAY_SETTINGS struct {
+		Enable            bool     `default:"true"`
+		Host              string   `default:"127.0.0.1"`
+		Port              int      `default:"9060"`
+		Upstreams         []string `default:""`
+		TLSCA             string   `default:""`
+		TLSSkipVerify     bool     `default:"false"`
+		MaxRate           int      `default:"1000"`
+		Burst             int      `default:"0"`
+		ReconnectInterval int      `default:"1000"`
+		QueueSize         int      `default:"1000"`
+	}
+	HEP_COLLECTOR_SETTINGS struct {
+		Enable        bool   `default:"false"`
+		UDPAddress    string `default:"0.0.0.0:9060"`
+		TCPAddress    string `default:""`
+		TLSAddress    string `default:""`
+		TLSCert       string `default:""`
+		TLSKey        string `default:""`
+		AuthKey       string `default:""`
+		Node          string `default:""`
+		QueueSize     int    `default:"20000"`
+		BatchSize     int    `default:"500"`
+		FlushInterval int    `default:"1000"`
+		BlockTimeout  int    `default:"1000"`
+		IdleTimeout   int    `default:"0"`
+	}
+	LIVE_SETTINGS struct {
+		Enable           bool `default:"true"`
+		PollInterval     int  `default:"1000"`
+		SettleDelay      int  `default:"2000"`
+		MaxRate          int  `default:"100"`
+		MaxLag           int  `default:"30"`
+		IdleTimeout      int  `default:"300"`
+		MaxSubscriptions int  `default:"5"`
+	}
+	INGEST_SETTINGS struct {
+		Enable     bool `default:"true"`
+		MaxRecords int  `default:"50000"`
+	}
+	STIR_SHAKEN_SETTINGS struct {
+		CertDir string `default:""`
+		MaxAge  int    `default:"60"`
+	}
+
+	ALIAS_SYNC_SETTINGS struct {
+		Enable   bool   `default:"false"`
+		Source   string `default:"inventory"`
+		Path     string `default:""`
+		URL      string `default:""`
+		Format   string `default:""`
+		Interval int    `default:"3600"`
+		Timeout  int    `default:"30"`
+	}
+
+	GEOIP_SETTINGS struct {
+		Enable      bool   `default:"false"`
+		LocationDB  string `default:""`
+		ASNDB       string `default:""`
+		ReloadCheck int    `default:"10"`
 	}
 	//Loki
 	LOKI_CONFIG struct {
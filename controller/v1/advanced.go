@@ -6,6 +6,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/auth"
 	"github.com/sipcapture/homer-app/data/service"
 	"github.com/sipcapture/homer-app/model"
 	httpresponse "github.com/sipcapture/homer-app/network/response"
@@ -37,7 +38,7 @@ type AdvancedController struct {
 //   201: body:GlobalSettingsStructList
 //   400: body:FailureResponse
 func (ac *AdvancedController) GetAll(c echo.Context) error {
-	alias, _ := ac.AdvancedService.GetAll()
+	alias, _ := ac.AdvancedService.GetAll(auth.RequestPartition(c))
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, string(alias))
 }
 
@@ -67,6 +68,7 @@ func (ac *AdvancedController) AddAdvanced(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
+	u.PartId = auth.RequestPartition(c)
 	// validate input request body
 	if err := c.Validate(u); err != nil {
 		logger.Error(err.Error())
@@ -112,7 +114,7 @@ func (ac *AdvancedController) UpdateAdvancedAgainstGUID(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := ac.AdvancedService.GetAdvancedAgainstGUID(guid)
+	reply, err := ac.AdvancedService.GetAdvancedAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -128,7 +130,7 @@ func (ac *AdvancedController) UpdateAdvancedAgainstGUID(c echo.Context) error {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 	u.GUID = guid
-	reply, err = ac.AdvancedService.UpdateAdvancedAgainstGUID(guid, u)
+	reply, err = ac.AdvancedService.UpdateAdvancedAgainstGUID(guid, auth.RequestPartition(c), u)
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -166,11 +168,11 @@ func (ac *AdvancedController) DeleteAdvancedAgainstGUID(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := ac.AdvancedService.GetAdvancedAgainstGUID(guid)
+	reply, err := ac.AdvancedService.GetAdvancedAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err = ac.AdvancedService.DeleteAdvancedAgainstGUID(guid)
+	reply, err = ac.AdvancedService.DeleteAdvancedAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.DeleteAdvancedAgainstFailed)
 	}
@@ -209,7 +211,7 @@ func (ac *AdvancedController) GetAdvancedAgainstGUID(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := ac.AdvancedService.GetAdvancedAgainstGUID(guid)
+	reply, err := ac.AdvancedService.GetAdvancedAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.GetAdvancedAgainstFailed)
 	}
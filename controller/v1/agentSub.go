@@ -18,6 +18,7 @@ import (
 type AgentsubController struct {
 	Controller
 	AgentsubService *service.AgentsubService
+	TenantService   *service.TenantService
 }
 
 // swagger:route GET /agent/subscribe agent agentsSubGetAgentsub
@@ -356,6 +357,7 @@ func (ass *AgentsubController) DeleteAgentsubAgainstGUID(c echo.Context) error {
 //
 //	201: body:AgentsLocationList
 //	400: body:FailureResponse
+//	403: body:FailureResponse
 func (ass *AgentsubController) GetAgentSearchByTypeAndGUID(c echo.Context) error {
 	guid, err := url.QueryUnescape(c.Param("guid"))
 	if err != nil {
@@ -378,8 +380,11 @@ func (ass *AgentsubController) GetAgentSearchByTypeAndGUID(c echo.Context) error
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 
-	reply, err := ass.AgentsubService.DoSearchByPost(agentObject, transactionObject, typeRequest)
-	if err != nil {
+	reply, err := ass.AgentsubService.DoSearchByPost(agentObject, transactionObject, typeRequest,
+		tenantFilter(c, ass.TenantService))
+	if err == service.ErrTenantHidden {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDataHidden)
+	} else if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 
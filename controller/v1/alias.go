@@ -2,22 +2,27 @@ package controllerv1
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/Jeffail/gabs/v2"
 	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
 	"github.com/sipcapture/homer-app/data/service"
 	"github.com/sipcapture/homer-app/model"
 	httpresponse "github.com/sipcapture/homer-app/network/response"
 	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/aliasfile"
 	"github.com/sipcapture/homer-app/utils/logger"
 )
 
 type AliasController struct {
 	Controller
-	AliasService *service.AliasService
+	AliasService     *service.AliasService
+	AliasSyncService *service.AliasSyncService
 }
 
 // swagger:route POST /alias alias aliasAddAlias
@@ -59,6 +64,12 @@ func (alc *AliasController) AddAlias(c echo.Context) error {
 		logger.Error(err.Error())
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
+	aliasObject.PartId = auth.RequestPartition(c)
+	if aliasObject.GroupGUID != "" {
+		if _, err := alc.AliasService.GetGroup(aliasObject.GroupGUID, aliasObject.PartId); err != nil {
+			return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasGroupNotFound)
+		}
+	}
 
 	row, _ := alc.AliasService.Add(&aliasObject)
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, row)
@@ -95,6 +106,7 @@ func (als *AliasController) DeleteAlias(c echo.Context) error {
 
 	aliasObject := model.TableAlias{}
 	aliasObject.GUID = c.Param("guid")
+	aliasObject.PartId = auth.RequestPartition(c)
 	data, err := als.AliasService.Get(&aliasObject)
 	if err != nil {
 		reply := gabs.New()
@@ -159,6 +171,12 @@ func (als *AliasController) UpdateAlias(c echo.Context) error {
 		logger.Error(err.Error())
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
+	aliasObject.PartId = auth.RequestPartition(c)
+	if aliasObject.GroupGUID != "" {
+		if _, err := als.AliasService.GetGroup(aliasObject.GroupGUID, aliasObject.PartId); err != nil {
+			return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasGroupNotFound)
+		}
+	}
 	aliasObject.GUID = c.Param("guid")
 	data, err := als.AliasService.Get(&aliasObject)
 	if err != nil {
@@ -200,7 +218,7 @@ func (als *AliasController) UpdateAlias(c echo.Context) error {
 //   200: body:AliasStructList
 func (alc *AliasController) GetAllAlias(c echo.Context) error {
 
-	alias, _ := alc.AliasService.GetAll()
+	alias, _ := alc.AliasService.GetAll(auth.RequestPartition(c))
 
 	sort.Slice(alias[:], func(i, j int) bool {
 		return alias[i].GUID < alias[j].GUID
@@ -213,3 +231,184 @@ func (alc *AliasController) GetAllAlias(c echo.Context) error {
 
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
 }
+
+// swagger:route GET /alias/export alias aliasExportAlias
+//
+// Export the aliases as csv, json or yaml
+// ---
+// produces:
+// - application/octet-stream
+// parameters:
+// + name: format
+//   in: query
+//   description: csv, json or yaml
+//   type: string
+// + name: source
+//   in: query
+//   description: only the aliases of this source
+//   type: string
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   200: body:TextResponse
+//   400: body:FailureResponse
+func (alc *AliasController) ExportAlias(c echo.Context) error {
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = aliasfile.FormatCSV
+	}
+
+	data, err := alc.AliasService.Export(format, c.QueryParam("source"), auth.RequestPartition(c))
+	if err != nil {
+		logger.Error("alias export: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasExportFailed)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=aliases.%s", format))
+	return c.Blob(http.StatusOK, "application/octet-stream", data)
+}
+
+// swagger:route POST /alias/import alias aliasImportAlias
+//
+// Import aliases of a csv, json, yaml, Kamailio dispatcher.list or address dump file.
+// The aliases of the source are replaced by the file, manual ones are never changed.
+// ---
+// consumes:
+// - multipart/form-data
+// produces:
+// - application/json
+// parameters:
+// + name: fileKey
+//   in: formData
+//   description: the file, or the request body without a form
+//   type: file
+// + name: format
+//   in: query
+//   description: csv, json, yaml, dispatcher or address, guessed from the file name if empty
+//   type: string
+// + name: source
+//   in: query
+//   description: the source of the aliases, import by default
+//   type: string
+// + name: dry_run
+//   in: query
+//   description: only return what would change
+//   type: boolean
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   201: body:SuccessResponse
+//   400: body:FailureResponse
+func (alc *AliasController) ImportAlias(c echo.Context) error {
+
+	format := c.QueryParam("format")
+	source := c.QueryParam("source")
+	if source == "" {
+		source = "import"
+	}
+	dryRun := false
+	if val := c.QueryParam("dry_run"); val != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(val); err != nil {
+			return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+		}
+	}
+
+	var data []byte
+	var err error
+	if file, fileErr := c.FormFile("fileKey"); fileErr == nil {
+		if format == "" {
+			format = aliasfile.DetectFormat(file.Filename)
+		}
+		src, openErr := file.Open()
+		if openErr != nil {
+			logger.Error("alias import: ", openErr)
+			return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+		}
+		defer src.Close()
+		data, err = ioutil.ReadAll(src)
+	} else {
+		data, err = ioutil.ReadAll(c.Request().Body)
+	}
+	if err != nil {
+		logger.Error("alias import: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if format == "" {
+		format = aliasfile.FormatJSON
+	}
+
+	diff, err := alc.AliasService.Import(format, data, source, auth.RequestPartition(c), dryRun)
+	if err != nil {
+		logger.Error("alias import: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasImportFailed+": "+err.Error())
+	}
+	return aliasDiffResponse(c, diff, dryRun)
+}
+
+// swagger:route POST /alias/sync alias aliasSyncAlias
+//
+// Sync the aliases of the configured inventory file or URL now
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: dry_run
+//   in: query
+//   description: only return what would change
+//   type: boolean
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   201: body:SuccessResponse
+//   400: body:FailureResponse
+func (alc *AliasController) SyncAlias(c echo.Context) error {
+
+	/* the inventory is synced into the default partition */
+	if auth.RequestPartition(c) != model.DefaultPartition {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.PartitionDenied)
+	}
+	if !alc.AliasSyncService.Enabled() {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasSyncNotConfigured)
+	}
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+
+	diff, err := alc.AliasSyncService.Sync(dryRun)
+	if err != nil {
+		logger.Error("alias sync: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasImportFailed+": "+err.Error())
+	}
+	return aliasDiffResponse(c, diff, dryRun)
+}
+
+func aliasDiffResponse(c echo.Context, diff *aliasfile.Diff, dryRun bool) error {
+
+	reply := gabs.New()
+	reply.Set(diff, "data")
+	reply.Set(dryRun, "dry_run")
+	if dryRun {
+		reply.Set("aliases compared", "message")
+	} else {
+		reply.Set("aliases imported", "message")
+	}
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
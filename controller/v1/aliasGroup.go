@@ -0,0 +1,224 @@
+package controllerv1
+
+import (
+	"net/http"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// swagger:route GET /alias/group alias aliasGetAllAliasGroup
+//
+// Get all alias groups
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   200: body:AliasGroupStructList
+func (alc *AliasController) GetAllAliasGroup(c echo.Context) error {
+
+	groups, err := alc.AliasService.GetAllGroups(auth.RequestPartition(c))
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(groups, "data")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route GET /alias/group/tree alias aliasGetAliasGroupTree
+//
+// Get the alias groups as a tree with their aliases
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   200: body:AliasGroupTree
+func (alc *AliasController) GetAliasGroupTree(c echo.Context) error {
+
+	groups, ungrouped, err := alc.AliasService.GroupTree(auth.RequestPartition(c))
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(groups, "data", "groups")
+	reply.Set(ungrouped, "data", "aliases")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route POST /alias/group alias aliasAddAliasGroup
+//
+// Adds an alias group
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: AliasGroupStruct
+//   in: body
+//   description: AliasGroupStruct parameters
+//   schema:
+//      type: AliasGroupStruct
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:AliasGroupSuccessResponse
+//   400: body:FailureResponse
+func (alc *AliasController) AddAliasGroup(c echo.Context) error {
+
+	group := model.TableAliasGroup{}
+	if err := c.Bind(&group); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if err := c.Validate(group); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+	group.PartId = auth.RequestPartition(c)
+
+	reply, err := alc.AliasService.AddGroup(&group)
+	if err != nil {
+		logger.Error("alias group: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasGroupFailed+": "+err.Error())
+	}
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply)
+}
+
+// swagger:route PUT /alias/group/{guid} alias aliasUpdateAliasGroup
+//
+// Update the name, parent and tags of an alias group
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: guid
+//   in: path
+//   example: 11111111-1111-1111-1111-111111111111
+//   description: guid of the alias group
+//   required: true
+//   type: string
+// + name: AliasGroupStruct
+//   in: body
+//   description: AliasGroupStruct parameters
+//   schema:
+//      type: AliasGroupStruct
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:AliasGroupSuccessResponse
+//   400: body:FailureResponse
+func (alc *AliasController) UpdateAliasGroup(c echo.Context) error {
+
+	group := model.TableAliasGroup{}
+	if err := c.Bind(&group); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if err := c.Validate(group); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+	group.GUID = c.Param("guid")
+	group.PartId = auth.RequestPartition(c)
+	if _, err := alc.AliasService.GetGroup(group.GUID, group.PartId); err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasGroupNotFound)
+	}
+
+	if err := alc.AliasService.UpdateGroup(&group); err != nil {
+		logger.Error("alias group: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasGroupFailed+": "+err.Error())
+	}
+
+	reply := gabs.New()
+	reply.Set(group.GUID, "data")
+	reply.Set("successfully updated alias group", "message")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route DELETE /alias/group/{guid} alias aliasDeleteAliasGroup
+//
+// Delete an alias group, its groups and aliases move to its parent
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: guid
+//   in: path
+//   example: 11111111-1111-1111-1111-111111111111
+//   description: guid of the alias group
+//   required: true
+//   type: string
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:AliasGroupSuccessResponse
+//   400: body:FailureResponse
+func (alc *AliasController) DeleteAliasGroup(c echo.Context) error {
+
+	group, err := alc.AliasService.GetGroup(c.Param("guid"), auth.RequestPartition(c))
+	if err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasGroupNotFound)
+	}
+
+	if err := alc.AliasService.DeleteGroup(&group); err != nil {
+		logger.Error("alias group: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.AliasGroupFailed+": "+err.Error())
+	}
+
+	reply := gabs.New()
+	reply.Set(group.GUID, "data")
+	reply.Set("successfully deleted alias group", "message")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
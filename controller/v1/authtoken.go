@@ -1,12 +1,15 @@
 package controllerv1
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"time"
 
+	"github.com/Jeffail/gabs/v2"
 	"github.com/labstack/echo/v4"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/auth"
 	"github.com/sipcapture/homer-app/data/service"
 	"github.com/sipcapture/homer-app/migration/jsonschema"
 	"github.com/sipcapture/homer-app/model"
@@ -21,6 +24,17 @@ type AuthtokenController struct {
 	AuthtokenService *service.AuthtokenService
 }
 
+/* the user object of a token puts it in the partition of the request */
+func tokenUserObject(c echo.Context, object json.RawMessage) json.RawMessage {
+
+	userObject, err := gabs.ParseJSON(object)
+	if err != nil {
+		userObject = gabs.New()
+	}
+	userObject.Set(auth.RequestPartition(c), "partid")
+	return userObject.Bytes()
+}
+
 // swagger:route GET /token/auth token authTokenGetAuthtoken
 //
 // Get all authentication token data
@@ -42,7 +56,7 @@ type AuthtokenController struct {
 //   400: body:FailureResponse
 func (ass *AuthtokenController) GetAuthtoken(c echo.Context) error {
 
-	reply, err := ass.AuthtokenService.GetAuthtoken()
+	reply, err := ass.AuthtokenService.GetAuthtoken(auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.GetAuthTokenFailed)
 	}
@@ -81,7 +95,7 @@ func (ass *AuthtokenController) GetAuthtokenAgainstGUID(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := ass.AuthtokenService.GetAuthtokenAgainstGUID(guid)
+	reply, err := ass.AuthtokenService.GetAuthtokenAgainstGUID(guid, auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -137,9 +151,13 @@ func (ass *AuthtokenController) AddAuthtoken(c echo.Context) error {
 	u.GUID = uid.String()
 	u.UserGUID = uid.String()
 	u.Token = heputils.GenerateToken()
-	u.UserObject = jsonschema.AgentObjectforAuthToken
+	u.UserObject = tokenUserObject(c, jsonschema.AgentObjectforAuthToken)
 	u.IPAddress = "0.0.0.0/0"
 	u.CreateDate = time.Now()
+	/* the tokens of the users of a tenant belong to it too */
+	if tenant := auth.GetTenant(c); tenant != "" {
+		u.TenantGUID = tenant
+	}
 	u.LastUsageDate = time.Now()
 	reply, err := ass.AuthtokenService.AddAuthtoken(u)
 	if err != nil {
@@ -185,7 +203,7 @@ func (ass *AuthtokenController) UpdateAuthtokenAgainstGUID(c echo.Context) error
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := ass.AuthtokenService.GetAuthtokenAgainstGUID(guid)
+	reply, err := ass.AuthtokenService.GetAuthtokenAgainstGUID(guid, auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -202,7 +220,13 @@ func (ass *AuthtokenController) UpdateAuthtokenAgainstGUID(c echo.Context) error
 	}
 	u.GUID = guid
 	u.LastUsageDate = time.Now()
-	reply, err = ass.AuthtokenService.UpdateAuthtokenAgainstGUID(guid, u)
+	if len(u.UserObject) > 0 && !auth.IsSuperAdmin(c) {
+		u.UserObject = tokenUserObject(c, u.UserObject)
+	}
+	if tenant := auth.GetTenant(c); tenant != "" {
+		u.TenantGUID = tenant
+	}
+	reply, err = ass.AuthtokenService.UpdateAuthtokenAgainstGUID(guid, auth.AdminPartition(c), u)
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -240,15 +264,15 @@ func (ass *AuthtokenController) DeleteAuthtokenAgainstGUID(c echo.Context) error
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := ass.AuthtokenService.GetAuthtokenAgainstGUID(guid)
+	reply, err := ass.AuthtokenService.GetAuthtokenAgainstGUID(guid, auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err = ass.AuthtokenService.DeleteAuthtokenAgainstGUID(guid)
+	reply, err = ass.AuthtokenService.DeleteAuthtokenAgainstGUID(guid, auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err = ass.AuthtokenService.DeleteAuthtokenAgainstGUID(guid)
+	reply, err = ass.AuthtokenService.DeleteAuthtokenAgainstGUID(guid, auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
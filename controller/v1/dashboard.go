@@ -7,12 +7,14 @@ import (
 	"net/url"
 
 	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
 	"github.com/sipcapture/homer-app/config"
 	"github.com/sipcapture/homer-app/data/service"
 	"github.com/sipcapture/homer-app/migration/jsonschema"
 	"github.com/sipcapture/homer-app/model"
 	httpresponse "github.com/sipcapture/homer-app/network/response"
 	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/heputils"
 	"github.com/sipcapture/homer-app/utils/logger"
 )
 
@@ -44,7 +46,7 @@ func (dbc *DashBoardController) GetDashBoardLists(c echo.Context) error {
 
 	cc := c.(model.AppContext)
 	username := cc.UserName
-	reply, err := dbc.DashBoardService.GetDashBoardsLists(username)
+	reply, err := dbc.DashBoardService.GetDashBoardsLists(username, auth.GetPartition(c))
 	if err != nil {
 
 		var dashboardHome []byte
@@ -58,10 +60,10 @@ func (dbc *DashBoardController) GetDashBoardLists(c echo.Context) error {
 			dashboardHome = jsonschema.DashboardHome
 		}
 
-		dbc.DashBoardService.InsertDashboardByName(username, "home", dashboardHome)
-		dbc.DashBoardService.InsertDashboardByName(username, "smartsearch", jsonschema.DashboardSmartSearch)
+		dbc.DashBoardService.InsertDashboardByName(username, "home", auth.GetPartition(c), dashboardHome)
+		dbc.DashBoardService.InsertDashboardByName(username, "smartsearch", auth.GetPartition(c), jsonschema.DashboardSmartSearch)
 
-		reply, err = dbc.DashBoardService.GetDashBoardsLists(username)
+		reply, err = dbc.DashBoardService.GetDashBoardsLists(username, auth.GetPartition(c))
 		if err != nil {
 			return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.GetDashboardListFailed)
 		}
@@ -106,7 +108,7 @@ func (dbc *DashBoardController) GetDashBoard(c echo.Context) error {
 
 	logger.Debug("*** Database Session created *** ")
 
-	reply, err := dbc.DashBoardService.GetDashBoard(username, dashboardID)
+	reply, err := dbc.DashBoardService.GetDashBoard(username, dashboardID, auth.GetPartition(c))
 	if err != nil {
 		if dashboardID == "home" {
 
@@ -121,19 +123,19 @@ func (dbc *DashBoardController) GetDashBoard(c echo.Context) error {
 				dashboardHome = jsonschema.DashboardHome
 			}
 
-			_, err := dbc.DashBoardService.InsertDashboardByName(username, "home", dashboardHome)
+			_, err := dbc.DashBoardService.InsertDashboardByName(username, "home", auth.GetPartition(c), dashboardHome)
 			if err != nil {
 				logger.Error("Couldn't create dashboard home: ", err.Error())
 				return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.HomeDashboardNotExists)
 			}
 
-			dbc.DashBoardService.InsertDashboardByName(username, "smartsearch", jsonschema.DashboardSmartSearch)
+			dbc.DashBoardService.InsertDashboardByName(username, "smartsearch", auth.GetPartition(c), jsonschema.DashboardSmartSearch)
 			if err != nil {
 				logger.Error("Couldn't create dashboard smartsearch: ", err.Error())
 				return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.HomeDashboardNotExists)
 			}
 
-			reply, err = dbc.DashBoardService.GetDashBoard(username, dashboardID)
+			reply, err = dbc.DashBoardService.GetDashBoard(username, dashboardID, auth.GetPartition(c))
 			if err != nil {
 				logger.Error("Couldn't get dashboards : ", err.Error())
 				return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.DashboardNotExists)
@@ -196,6 +198,9 @@ func (dbc *DashBoardController) InsertDashboard(c echo.Context) error {
 		logger.Debug(err)
 		return err
 	}
+	if sharedDashboard(jsonData) && !auth.HasPermission(c, model.PermissionDashboardsShare) {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.DashboardShareDenied)
+	}
 
 	data, err := json.Marshal(jsonData)
 	if err != nil {
@@ -203,7 +208,7 @@ func (dbc *DashBoardController) InsertDashboard(c echo.Context) error {
 		return err
 	}
 
-	reply, err := dbc.DashBoardService.InsertDashboard(username, dashboardId, data)
+	reply, err := dbc.DashBoardService.InsertDashboard(username, dashboardId, auth.GetPartition(c), data)
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.InsertDashboardFailed)
 	}
@@ -257,6 +262,9 @@ func (dbc *DashBoardController) UpdateDashboard(c echo.Context) error {
 		logger.Debug(err)
 		return err
 	}
+	if sharedDashboard(jsonData) && !auth.HasPermission(c, model.PermissionDashboardsShare) {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.DashboardShareDenied)
+	}
 
 	data, err := json.Marshal(jsonData)
 	if err != nil {
@@ -264,7 +272,7 @@ func (dbc *DashBoardController) UpdateDashboard(c echo.Context) error {
 		return err
 	}
 
-	reply, err := dbc.DashBoardService.UpdateDashboard(username, dashboardId, data)
+	reply, err := dbc.DashBoardService.UpdateDashboard(username, dashboardId, auth.GetPartition(c), data)
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.InsertDashboardFailed)
 	}
@@ -306,7 +314,7 @@ func (dbc *DashBoardController) DeleteDashboard(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := dbc.DashBoardService.DeleteDashboard(username, dashboardId)
+	reply, err := dbc.DashBoardService.DeleteDashboard(username, dashboardId, auth.GetPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.DeleteDashboardFailed)
 	}
@@ -343,7 +351,7 @@ func (dbc *DashBoardController) ResetUserDashboard(c echo.Context) error {
 
 	cc := c.(model.AppContext)
 	username := cc.UserName
-	_, err := dbc.DashBoardService.DeleteAllDashboards(username)
+	_, err := dbc.DashBoardService.DeleteAllDashboards(username, auth.GetPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.DeleteDashboardFailed)
 	}
@@ -359,10 +367,10 @@ func (dbc *DashBoardController) ResetUserDashboard(c echo.Context) error {
 		dashboardHome = jsonschema.DashboardHome
 	}
 
-	dbc.DashBoardService.InsertDashboardByName(username, "home", dashboardHome)
-	dbc.DashBoardService.InsertDashboardByName(username, "smartsearch", jsonschema.DashboardSmartSearch)
+	dbc.DashBoardService.InsertDashboardByName(username, "home", auth.GetPartition(c), dashboardHome)
+	dbc.DashBoardService.InsertDashboardByName(username, "smartsearch", auth.GetPartition(c), jsonschema.DashboardSmartSearch)
 
-	reply, err := dbc.DashBoardService.GetDashBoardsLists(username)
+	reply, err := dbc.DashBoardService.GetDashBoardsLists(username, auth.GetPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.GetDashboardListFailed)
 	}
@@ -370,3 +378,13 @@ func (dbc *DashBoardController) ResetUserDashboard(c echo.Context) error {
 	return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusOK, []byte(reply))
 
 }
+
+/* the dashboards are shared with "shared": true, "true" or 1 */
+func sharedDashboard(data map[string]interface{}) bool {
+	switch shared := data["shared"].(type) {
+	case string:
+		return shared == "true" || shared == "1"
+	default:
+		return heputils.CheckBoolValue(shared)
+	}
+}
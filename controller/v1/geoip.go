@@ -0,0 +1,84 @@
+package controllerv1
+
+import (
+	"net/http"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// swagger:route POST /search/geo/aggregate search searchGeoAggregate
+//
+// Returns the messages of a search per country or ASN of the addresses
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// parameters:
+// + name: by
+//   in: query
+//   description: country (default) or asn
+//   type: string
+// + name: direction
+//   in: query
+//   description: src (default), dst or both
+//   type: string
+// + name: SearchTransactionRequest
+//   in: body
+//   type: object
+//   description: SearchTransactionRequest parameters
+//   schema:
+//     type: SearchTransactionRequest
+//   required: true
+//
+// responses:
+//   200: body:GeoAggregateResponse
+//   400: body:FailureResponse
+func (sc *SearchController) GeoAggregate(c echo.Context) error {
+
+	searchObject := model.SearchObject{}
+	if err := c.Bind(&searchObject); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+
+	by, direction := c.QueryParam("by"), c.QueryParam("direction")
+	if (by != "" && by != "country" && by != "asn") ||
+		(direction != "" && direction != "src" && direction != "dst" && direction != "both") {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+
+	partid := auth.RequestPartition(c)
+	mapsFieldsData, err := sc.SettingService.GetAllMapping(partid)
+	if err != nil {
+		logger.Error("mapping error select: ", mapsFieldsData)
+	}
+	counts, err := sc.SearchService.GeoAggregate(&searchObject, sc.AliasService.Resolver(partid), tenantFilter(c, sc.TenantService), mapsFieldsData, by, direction)
+	if err == service.ErrGeoIPDisabled {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.GeoIPNotConfigured)
+	} else if err != nil {
+		logger.Error("geo aggregate: ", err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusServiceUnavailable, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(counts, "data")
+	reply.Set(len(counts), "total")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
@@ -0,0 +1,35 @@
+package controllerv1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/data/service"
+)
+
+type HepCollectorController struct {
+	Controller
+	HepCollectorService *service.HepCollectorService
+}
+
+// swagger:route GET /hep/collector/stats Collector hepCollectorStats
+//
+// Returns the counters of the built-in HEP collector: received, dropped and inserted frames
+// and the length of the queue
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:HepCollectorStats
+func (hc *HepCollectorController) GetStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, hc.HepCollectorService.Stats())
+}
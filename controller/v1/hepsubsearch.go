@@ -7,12 +7,14 @@ import (
 	"github.com/sipcapture/homer-app/data/service"
 	"github.com/sipcapture/homer-app/model"
 	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
 	"github.com/sipcapture/homer-app/utils/logger"
 )
 
 type HepsubsearchController struct {
 	Controller
 	HepsubsearchService *service.HepsubsearchService
+	TenantService       *service.TenantService
 }
 
 // swagger:route POST /hepsub/search hep hepSubSearchDoHepsubsearch
@@ -42,6 +44,7 @@ type HepsubsearchController struct {
 // responses:
 //   201: body:HepsubCreateSuccessResponse
 //   400: body:FailureResponse
+//   403: body:FailureResponse
 func (hss *HepsubsearchController) DoHepsubsearch(c echo.Context) error {
 	// Stub an user to be populated from the body
 	searchObject := model.SearchObject{}
@@ -55,8 +58,10 @@ func (hss *HepsubsearchController) DoHepsubsearch(c echo.Context) error {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 
-	reply, err := hss.HepsubsearchService.DoHepSubSearch(searchObject)
-	if err != nil {
+	reply, err := hss.HepsubsearchService.DoHepSubSearch(searchObject, tenantFilter(c, hss.TenantService))
+	if err == service.ErrTenantHidden {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDataHidden)
+	} else if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 	return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, []byte(reply))
@@ -0,0 +1,433 @@
+package controllerv1
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+type ImportController struct {
+	Controller
+	ImportService *service.ImportService
+	TenantService *service.TenantService
+}
+
+/* the jobs are seen by their owner, the admins of its partition and tenant see them all */
+func importOwner(c echo.Context) model.ImportOwner {
+	username, _ := auth.IsRequestAdmin(c)
+	return model.ImportOwner{
+		UserName:   username,
+		TenantGUID: auth.GetTenant(c),
+		PartID:     auth.GetPartition(c),
+		Admin:      auth.HasPermission(c, model.PermissionUsersAdmin),
+		SuperAdmin: auth.IsSuperAdmin(c),
+	}
+}
+
+// saveUpload stores the uploaded capture in a temporary file, the import job removes it
+func saveUpload(c echo.Context) (string, string, error) {
+
+	file, err := c.FormFile("fileKey")
+	if err != nil {
+		logger.Error("ImportPcap fileKey was not found: ", err.Error())
+		return "", "", err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		logger.Error("ImportPcap couldn't open it: ", err.Error())
+		return "", "", err
+	}
+	defer src.Close()
+
+	dst, err := ioutil.TempFile("", "homer-import-*")
+	if err != nil {
+		logger.Error("ImportPcap couldn't create temporary file: ", err.Error())
+		return "", "", err
+	}
+	defer dst.Close()
+
+	if _, err = io.Copy(dst, src); err != nil {
+		logger.Error("ImportPcap couldn't copy it: ", err.Error())
+		return "", "", err
+	}
+
+	return file.Filename, dst.Name(), nil
+}
+
+// importOptions reads the job options from the form fields
+func importOptions(c echo.Context) (model.ImportOptions, error) {
+
+	options := model.ImportOptions{
+		Node:            c.FormValue("node"),
+		CaptureID:       c.FormValue("capture_id"),
+		Profile:         c.FormValue("profile"),
+		Format:          c.FormValue("format"),
+		TimestampRegex:  c.FormValue("timestamp_regex"),
+		TimestampLayout: c.FormValue("timestamp_layout"),
+		AddressRegex:    c.FormValue("address_regex"),
+		LogDate:         c.FormValue("log_date"),
+	}
+
+	if val := c.FormValue("now"); val != "" {
+		now, err := strconv.ParseBool(val)
+		if err != nil {
+			return options, fmt.Errorf("bad now value: %s", val)
+		}
+		options.Now = now
+	}
+
+	/* seconds or a duration like -2h30m */
+	if val := c.FormValue("time_shift"); val != "" {
+		if seconds, err := strconv.ParseInt(val, 10, 64); err == nil {
+			options.TimeShift = seconds
+		} else if duration, err := time.ParseDuration(val); err == nil {
+			options.TimeShift = int64(duration / time.Second)
+		} else {
+			return options, fmt.Errorf("bad time_shift value: %s", val)
+		}
+	}
+
+	return options, nil
+}
+
+// runImportAndWait keeps the old synchronous behaviour of /import/data/pcap
+func (ic *ImportController) runImportAndWait(c echo.Context, now bool) error {
+
+	options, err := importOptions(c)
+	if err != nil {
+		logger.Error("ImportPcap bad options: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	options.Now = options.Now || now
+
+	fileName, filePath, err := saveUpload(c)
+	if err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadPCAPData)
+	}
+
+	owner := importOwner(c)
+	job, err := ic.ImportService.StartImport(fileName, filePath, options, owner, tenantFilter(c, ic.TenantService))
+	if err == service.ErrTenantHidden {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDataHidden)
+	} else if err == nil {
+		job, err = ic.ImportService.WaitImport(job.ID, owner)
+	}
+
+	if err != nil || job.Status == model.ImportJobFailed {
+		logger.Error("Bad decoding: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadPCAPData)
+	}
+
+	reply := gabs.New()
+	report := gabs.New()
+	report.Set(job.Inserted, "inserted")
+	report.Set(job.Rejected, "rejected")
+	report.Set(job.ID, "importId")
+	reply.Set(report.Data(), "data")
+	reply.Set("All good", "message")
+
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route POST /import/data/pcap Import GetMessagesAsPCap
+//
+// Imports a pcap or pcapng file and waits for the result
+// ---
+// consumes:
+// - multipart/form-data
+// produces:
+// - application/json
+// parameters:
+// + name: fileKey
+//   in: formData
+//   type: file
+//   description: pcap or pcapng file
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:ListUsers
+//   400: body:FailureResponse
+//   403: body:FailureResponse
+func (ic *ImportController) GetDataAsPCap(c echo.Context) error {
+	return ic.runImportAndWait(c, false)
+}
+
+// swagger:route POST /import/data/pcap/now Import GetMessagesAsPCapNow
+//
+// Imports a pcap or pcapng file moved to the current time and waits for the result
+// ---
+// consumes:
+// - multipart/form-data
+// produces:
+// - application/json
+// parameters:
+// + name: fileKey
+//   in: formData
+//   type: file
+//   description: pcap or pcapng file
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:ListUsers
+//   400: body:FailureResponse
+//   403: body:FailureResponse
+func (ic *ImportController) GetDataAsPCapNow(c echo.Context) error {
+	return ic.runImportAndWait(c, true)
+}
+
+// swagger:route POST /import/job Import importCreateJob
+//
+// Starts a background import of a pcap/pcapng file, HEP captures included, or a SIP text log
+// ---
+// consumes:
+// - multipart/form-data
+// produces:
+// - application/json
+// parameters:
+// + name: fileKey
+//   in: formData
+//   type: file
+//   description: pcap, pcapng or text log file
+//   required: true
+// + name: node
+//   in: formData
+//   type: string
+//   description: data node, the configured import node if empty
+// + name: capture_id
+//   in: formData
+//   type: string
+//   description: captureId written to the protocol_header
+// + name: time_shift
+//   in: formData
+//   type: string
+//   description: seconds or duration (-2h30m) added to every timestamp
+// + name: now
+//   in: formData
+//   type: boolean
+//   description: move the first packet to the current time
+// + name: profile
+//   in: formData
+//   type: string
+//   description: write all rows into this profile
+// + name: format
+//   in: formData
+//   type: string
+//   description: auto, pcap, asterisk, freeswitch, kamailio or custom
+// + name: timestamp_regex
+//   in: formData
+//   type: string
+//   description: text logs, regex with the named group ts
+// + name: timestamp_layout
+//   in: formData
+//   type: string
+//   description: text logs, go time layout of ts or unix
+// + name: address_regex
+//   in: formData
+//   type: string
+//   description: text logs, regex with the named groups src_ip, src_port, dst_ip, dst_port or peer_ip, peer_port, dir
+// + name: log_date
+//   in: formData
+//   type: string
+//   description: text logs, date (2006-01-02) of timestamps without one
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   202: body:ImportJob
+//   400: body:FailureResponse
+//   403: body:FailureResponse
+func (ic *ImportController) CreateImportJob(c echo.Context) error {
+
+	options, err := importOptions(c)
+	if err != nil {
+		logger.Error("CreateImportJob bad options: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+
+	fileName, filePath, err := saveUpload(c)
+	if err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadPCAPData)
+	}
+
+	job, err := ic.ImportService.StartImport(fileName, filePath, options, importOwner(c), tenantFilter(c, ic.TenantService))
+	if err == service.ErrTenantHidden {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDataHidden)
+	} else if err != nil {
+		logger.Error("CreateImportJob: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.ImportJobFailed)
+	}
+
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// swagger:route GET /import/job Import importGetJobs
+//
+// Returns the import jobs of the user, an admin gets the ones of its partition and tenant
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:ImportJobList
+func (ic *ImportController) GetImportJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, ic.ImportService.GetImportJobs(importOwner(c)))
+}
+
+// swagger:route GET /import/job/{id} Import importGetJob
+//
+// Returns the progress of an import job
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: id
+//   in: path
+//   description: id of the import
+//   required: true
+//   type: string
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:ImportJob
+//   404: body:FailureResponse
+func (ic *ImportController) GetImportJob(c echo.Context) error {
+
+	id, _ := url.QueryUnescape(c.Param("id"))
+
+	job, err := ic.ImportService.GetImportJob(id, importOwner(c))
+	if err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusNotFound, webmessages.ImportJobNotFound)
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// swagger:route POST /import/job/{id}/cancel Import importCancelJob
+//
+// Cancels a running import job
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: id
+//   in: path
+//   description: id of the import
+//   required: true
+//   type: string
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:ImportJob
+//   404: body:FailureResponse
+func (ic *ImportController) CancelImportJob(c echo.Context) error {
+
+	id, _ := url.QueryUnescape(c.Param("id"))
+
+	job, err := ic.ImportService.CancelImportJob(id, importOwner(c))
+	if err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusNotFound, webmessages.ImportJobNotFound)
+	}
+
+	return c.JSON(http.StatusOK, job)
+}
+
+// swagger:route DELETE /import/job/{id} Import importDeleteJob
+//
+// Cancels the import and deletes all rows tagged with its id. The imports which aren't
+// known anymore are only deleted by a super admin.
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: id
+//   in: path
+//   description: id of the import
+//   required: true
+//   type: string
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:SuccessResponse
+//   400: body:FailureResponse
+func (ic *ImportController) DeleteImportJob(c echo.Context) error {
+
+	id, _ := url.QueryUnescape(c.Param("id"))
+
+	deleted, err := ic.ImportService.DeleteImportData(id, importOwner(c))
+	if err != nil {
+		logger.Error("DeleteImportJob: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.ImportDeleteFailed)
+	}
+
+	reply := gabs.New()
+	reply.Set(id, "data", "importId")
+	reply.Set(deleted, "data", "deleted")
+	reply.Set("successfully deleted", "message")
+
+	return httpresponse.CreateSuccessResponse(&c, http.StatusOK, reply.String())
+}
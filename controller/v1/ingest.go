@@ -0,0 +1,74 @@
+package controllerv1
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/data/service"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+type IngestController struct {
+	Controller
+	IngestService *service.IngestService
+	TenantService *service.TenantService
+}
+
+// swagger:route POST /ingest/hep Ingest ingestHep
+//
+// Writes records into the data tables. The body is NDJSON, every line is either a JSON object
+// shaped like a search result row (sid, create_date, protocol_header, data_header, raw, profile)
+// or a base64 encoded HEPv3 frame. Needs an admin user or an auth token with the scope ingest.
+// The records are checked with the mappings of the partition of the user or token, the node
+// and the captureIds with its tenant.
+// ---
+// consumes:
+// - application/x-ndjson
+// produces:
+// - application/json
+// parameters:
+// + name: node
+//   in: query
+//   type: string
+//   description: data node, the configured import node if empty
+// + name: profile
+//   in: query
+//   type: string
+//   description: profile of records which don't have one, i.e. 1_call
+//
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:IngestResult
+//   400: body:IngestResult
+//   403: body:FailureResponse
+func (ic *IngestController) IngestHep(c echo.Context) error {
+
+	body := c.Request().Body
+	defer body.Close()
+
+	result, err := ic.IngestService.IngestHep(body, c.QueryParam("node"), c.QueryParam("profile"), auth.GetPartition(c),
+		tenantFilter(c, ic.TenantService))
+	if err == service.ErrTenantHidden {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDataHidden)
+	} else if err != nil {
+		logger.Error("IngestHep: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.IngestFailed)
+	}
+
+	if result.Inserted == 0 && len(result.Errors) > 0 {
+		return c.JSON(http.StatusBadRequest, result)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
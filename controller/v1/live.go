@@ -0,0 +1,255 @@
+package controllerv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/tenant"
+	"golang.org/x/net/websocket"
+)
+
+type LiveController struct {
+	Controller
+	SearchService  *service.SearchService
+	SettingService *service.UserSettingsService
+	AliasService   *service.AliasService
+	TenantService  *service.TenantService
+}
+
+/* open live subscriptions per user */
+var liveSubscriptions = struct {
+	sync.Mutex
+	users map[string]int
+}{users: map[string]int{}}
+
+func acquireLiveSubscription(userName string) bool {
+	liveSubscriptions.Lock()
+	defer liveSubscriptions.Unlock()
+	if max := config.Setting.LIVE_SETTINGS.MaxSubscriptions; max > 0 && liveSubscriptions.users[userName] >= max {
+		return false
+	}
+	liveSubscriptions.users[userName]++
+	return true
+}
+
+func releaseLiveSubscription(userName string) {
+	liveSubscriptions.Lock()
+	defer liveSubscriptions.Unlock()
+	if liveSubscriptions.users[userName]--; liveSubscriptions.users[userName] <= 0 {
+		delete(liveSubscriptions.users, userName)
+	}
+}
+
+// swagger:route GET /live/ws Live liveTail
+//
+// WebSocket pushing new messages matching a search. The client sends
+// {"type":"subscribe","search":<SearchObject>}, {"type":"unsubscribe"} and {"type":"ping"},
+// the server answers with subscribed, unsubscribed, pong, data, overflow, error, idle and expired messages.
+// Browsers pass the JWT as ?token= or the auth token as ?auth_token=. The socket is closed
+// with an expired message once the token expires or its session is revoked.
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: token
+//   in: query
+//   type: string
+//   description: JWT of the user
+// + name: auth_token
+//   in: query
+//   type: string
+//   description: auth token
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   101: body:LiveMessage
+//   429: body:FailureResponse
+func (lc *LiveController) LiveTail(c echo.Context) error {
+
+	userName, _ := auth.IsRequestAdmin(c)
+	scope := tenantFilter(c, lc.TenantService)
+	partid := auth.RequestPartition(c)
+	alive := auth.SessionAlive(c)
+
+	if !acquireLiveSubscription(userName) {
+		return httpresponse.CreateBadResponse(&c, http.StatusTooManyRequests, webmessages.LiveTooManySubscriptions)
+	}
+	defer releaseLiveSubscription(userName)
+
+	/* the token has been checked already, no origin check needed */
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		lc.serveLive(ws, scope, partid, alive)
+	}}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+func sendLive(ws *websocket.Conn, message model.LiveMessage) error {
+	ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return websocket.JSON.Send(ws, message)
+}
+
+// serveLive runs one connection: requests are read in the background, the subscription
+// is polled every poll interval. The idle timer is only reset by the client, so the token
+// is checked on every poll too.
+func (lc *LiveController) serveLive(ws *websocket.Conn, scope *tenant.Filter, partid int, alive func() bool) {
+
+	defer ws.Close()
+
+	settings := config.Setting.LIVE_SETTINGS
+	pollInterval := time.Duration(settings.PollInterval) * time.Millisecond
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	settleDelay := time.Duration(settings.SettleDelay) * time.Millisecond
+	maxLag := time.Duration(settings.MaxLag) * time.Second
+	idleTimeout := time.Duration(settings.IdleTimeout) * time.Second
+	if idleTimeout <= 0 {
+		idleTimeout = 5 * time.Minute
+	}
+
+	/* rows per poll */
+	limit := int(int64(settings.MaxRate) * int64(pollInterval) / int64(time.Second))
+	if limit < 1 {
+		limit = 1
+	}
+
+	requests := make(chan model.LiveRequest)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(requests)
+		for {
+			var msg []byte
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+			request := model.LiveRequest{}
+			if err := json.Unmarshal(msg, &request); err != nil {
+				request.Type = ""
+			}
+			select {
+			case requests <- request:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var search *model.SearchObject
+	var cursor *service.LiveCursor
+	var mapsFieldsData map[string]json.RawMessage
+
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for {
+		var err error
+
+		select {
+		case request, ok := <-requests:
+			if !ok {
+				return
+			}
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(idleTimeout)
+
+			switch request.Type {
+			case model.LiveSubscribe:
+				if request.Search == nil {
+					err = sendLive(ws, model.LiveMessage{Type: model.LiveError, Message: webmessages.UserRequestFormatIncorrect})
+					break
+				}
+
+				mapsFieldsData, err = lc.SettingService.GetAllMapping(partid)
+				if err != nil {
+					logger.Error("mapping error select: ", err)
+				}
+
+				/* start now or a bit earlier, never further back than max_lag */
+				start := time.Now().Add(-settleDelay)
+				if from := request.Search.Timestamp.From; from > 0 {
+					if since := time.Unix(0, from*int64(time.Millisecond)); since.Before(start) && time.Since(since) < maxLag {
+						start = since
+					}
+				}
+
+				search = request.Search
+				cursor = service.NewLiveCursor(start)
+				err = sendLive(ws, model.LiveMessage{Type: model.LiveSubscribed})
+
+			case model.LiveUnsubscribe:
+				search, cursor = nil, nil
+				err = sendLive(ws, model.LiveMessage{Type: model.LiveUnsubscribed})
+
+			case model.LivePing:
+				err = sendLive(ws, model.LiveMessage{Type: model.LivePong})
+
+			default:
+				err = sendLive(ws, model.LiveMessage{Type: model.LiveError, Message: webmessages.UserRequestFormatIncorrect})
+			}
+
+		case <-poll.C:
+			if !alive() {
+				sendLive(ws, model.LiveMessage{Type: model.LiveExpired, Message: webmessages.LiveSessionEnded})
+				return
+			}
+			if search == nil {
+				continue
+			}
+
+			/* rows are written with a delay, only the settled ones are sent */
+			until := time.Now().Add(-settleDelay)
+
+			if maxLag > 0 && cursor.Lag(until) > maxLag {
+				cursor.SkipTo(until)
+				if err = sendLive(ws, model.LiveMessage{Type: model.LiveOverflow, SkippedTo: until.UnixNano() / int64(time.Millisecond)}); err != nil {
+					break
+				}
+			}
+
+			rows, dataErr := lc.SearchService.LiveData(search, cursor, until, limit, lc.AliasService.Resolver(partid), scope, mapsFieldsData)
+			if dataErr != nil {
+				logger.Error("LiveTail data select: ", dataErr.Error())
+				err = sendLive(ws, model.LiveMessage{Type: model.LiveError, Message: webmessages.BadDatabaseRetrieve})
+				break
+			}
+
+			if total, _ := rows.ArrayCount(); total > 0 {
+				err = sendLive(ws, model.LiveMessage{Type: model.LiveData, Total: total, Data: rows.Data()})
+			}
+
+		case <-idle.C:
+			sendLive(ws, model.LiveMessage{Type: model.LiveIdle})
+			return
+		}
+
+		if err != nil {
+			logger.Debug("LiveTail connection closed: ", err.Error())
+			return
+		}
+	}
+}
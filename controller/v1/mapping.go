@@ -42,7 +42,7 @@ type MappingController struct {
 //	201: body:MappingSchemaList
 //	400: body:FailureResponse
 func (mpc *MappingController) GetMapping(c echo.Context) error {
-	reply, err := mpc.MappingService.GetMapping()
+	reply, err := mpc.MappingService.GetMapping(auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -97,7 +97,7 @@ func (mpc *MappingController) GetMappingFields(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := mpc.MappingService.GetMappingFields(id, transaction)
+	reply, err := mpc.MappingService.GetMappingFields(id, transaction, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MappingSchemaFailed)
 	}
@@ -141,7 +141,7 @@ func (mpc *MappingController) GetMappingAgainstGUID(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := mpc.MappingService.GetMappingAgainstGUID(guid)
+	reply, err := mpc.MappingService.GetMappingAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MappingSchemaByUUIDFailed)
 	}
@@ -184,6 +184,7 @@ func (mpc *MappingController) AddMapping(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
+	u.PartID = auth.RequestPartition(c)
 	// validate input request body
 	if err := c.Validate(u); err != nil {
 		logger.Error(err.Error())
@@ -239,7 +240,7 @@ func (mpc *MappingController) UpdateMappingAgainstGUID(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := mpc.MappingService.GetMappingAgainstGUID(guid)
+	reply, err := mpc.MappingService.GetMappingAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -255,7 +256,7 @@ func (mpc *MappingController) UpdateMappingAgainstGUID(c echo.Context) error {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 	u.GUID = guid
-	reply, err = mpc.MappingService.UpdateMappingAgainstGUID(guid, u)
+	reply, err = mpc.MappingService.UpdateMappingAgainstGUID(guid, auth.RequestPartition(c), u)
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
@@ -297,11 +298,11 @@ func (mpc *MappingController) DeleteMappingAgainstGUID(c echo.Context) error {
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err := mpc.MappingService.GetMappingAgainstGUID(guid)
+	reply, err := mpc.MappingService.GetMappingAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	reply, err = mpc.MappingService.DeleteMappingAgainstGUID(guid)
+	reply, err = mpc.MappingService.DeleteMappingAgainstGUID(guid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.DeleteMappingSchemaFailed)
 	}
@@ -357,7 +358,7 @@ func (mpc *MappingController) GetSmartHepProfile(c echo.Context) error {
 
 	queryString := c.QueryString()
 
-	reply, err := mpc.MappingService.GetSmartSuggestionAginstProfile(hepid, profile, queryString)
+	reply, err := mpc.MappingService.GetSmartSuggestionAginstProfile(hepid, profile, auth.RequestPartition(c), queryString)
 	if err != nil {
 		logger.Error("Error during map generator: ", err.Error())
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.SmartHepProfileFailed)
@@ -397,13 +398,7 @@ func (mpc *MappingController) GetSmartHepProfile(c echo.Context) error {
 //	400: body:FailureResponse
 func (mpc *MappingController) ResetMapping(c echo.Context) error {
 
-	_, isAdmin := auth.IsRequestAdmin(c)
-
-	if !isAdmin {
-		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MappingRecreateFailed)
-	}
-
-	err := mpc.MappingService.RecreateMapping()
+	err := mpc.MappingService.RecreateMapping(auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MappingSchemaByUUIDFailed)
 	}
@@ -449,17 +444,11 @@ func (mpc *MappingController) ResetMapping(c echo.Context) error {
 //	400: body:FailureResponse
 func (mpc *MappingController) ResetMappingAgainstUUID(c echo.Context) error {
 
-	_, isAdmin := auth.IsRequestAdmin(c)
-
-	if !isAdmin {
-		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MappingRecreateFailed)
-	}
-
 	uuid, err := url.QueryUnescape(c.Param("uuid"))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	err = mpc.MappingService.RecreateMappingByUUID(uuid)
+	err = mpc.MappingService.RecreateMappingByUUID(uuid, auth.RequestPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MappingSchemaByUUIDFailed)
 	}
@@ -0,0 +1,407 @@
+package controllerv1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+type MFAController struct {
+	Controller
+	MFAService     *service.MFAService
+	SessionService *service.SessionService
+}
+
+/* the internal user of the token */
+func (mc *MFAController) user(c echo.Context) (model.TableUser, error) {
+	username, _ := auth.IsRequestAdmin(c)
+	if c.Get("user") == nil {
+		return model.TableUser{}, service.ErrMFAInternal
+	}
+	return mc.MFAService.User(username)
+}
+
+func (mc *MFAController) badResponse(c echo.Context, err error) error {
+	logger.Error("2fa: ", err)
+	switch err {
+	case service.ErrMFACode:
+		return httpresponse.CreateBadResponse(&c, http.StatusUnauthorized, webmessages.MFACodeIncorrect)
+	case service.ErrMFAChallenge:
+		return httpresponse.CreateBadResponse(&c, http.StatusUnauthorized, webmessages.MFAChallengeInvalid)
+	case service.ErrMFAInternal:
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MFAInternalOnly)
+	case service.ErrMFANotEnrolled, service.ErrMFAEnrolled, service.ErrMFAPolicy:
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+	return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MFAFailed)
+}
+
+// swagger:route POST /auth/mfa user userLoginMFA
+//
+// Returns a JWT Token and UUID attached to user for the challenge of the login and a code
+// of the app or a recovery code
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: MFARequest
+//   in: body
+//   description: the challenge and the code
+//   schema:
+//      type: MFARequest
+//   required: true
+//
+// responses:
+//   201: body:UserLoginSuccessResponse
+//   401: body:FailureResponse
+func (mc *MFAController) CompleteLogin(c echo.Context) error {
+
+	u := model.MFARequest{}
+	if err := c.Bind(&u); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+
+	user, err := mc.MFAService.Complete(u.Challenge, u.Code)
+	if err != nil {
+		return mc.badResponse(c, err)
+	}
+
+	loginObject, err := mc.SessionService.Start(user, true, c.RealIP(), c.Request().UserAgent())
+	if err != nil {
+		logger.Error("session: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusInternalServerError, webmessages.SessionFailed)
+	}
+	response, _ := json.Marshal(loginObject)
+	return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, response)
+}
+
+// swagger:route POST /auth/mfa/enrol user userLoginMFAEnrol
+//
+// Sets up the TOTP of a user the policy wants a code from at login, the login is
+// completed at /auth/mfa with a code of the app
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: MFARequest
+//   in: body
+//   description: the challenge of the login
+//   schema:
+//      type: MFARequest
+//   required: true
+//
+// responses:
+//   201: body:MFAEnrolment
+//   401: body:FailureResponse
+func (mc *MFAController) EnrolLogin(c echo.Context) error {
+
+	u := model.MFARequest{}
+	if err := c.Bind(&u); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+
+	enrolment, err := mc.MFAService.EnrolChallenge(u.Challenge)
+	if err != nil {
+		return mc.badResponse(c, err)
+	}
+	response, _ := json.Marshal(enrolment)
+	return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, response)
+}
+
+// swagger:route GET /users/mfa user userGetMFA
+//
+// Returns the 2FA of the current user
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:MFAStatus
+//   400: body:FailureResponse
+func (mc *MFAController) GetStatus(c echo.Context) error {
+
+	user, err := mc.user(c)
+	if err != nil {
+		return mc.badResponse(c, err)
+	}
+	status, err := mc.MFAService.Status(user)
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(status, "data")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusOK, reply.String())
+}
+
+// swagger:route POST /users/mfa/enrol user userEnrolMFA
+//
+// Makes the TOTP secret, the URI of the QR code and the recovery codes of the current
+// user, 2FA is on once a code is confirmed at /users/mfa/confirm
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:MFAEnrolment
+//   400: body:FailureResponse
+func (mc *MFAController) Enrol(c echo.Context) error {
+
+	user, err := mc.user(c)
+	if err != nil {
+		return mc.badResponse(c, err)
+	}
+	enrolment, err := mc.MFAService.Enrol(user)
+	if err != nil {
+		return mc.badResponse(c, err)
+	}
+	response, _ := json.Marshal(enrolment)
+	return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, response)
+}
+
+// swagger:route POST /users/mfa/confirm user userConfirmMFA
+//
+// Turns the 2FA of the current user on with a code of the app
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: MFARequest
+//   in: body
+//   description: the code of the app
+//   schema:
+//      type: MFARequest
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:MFAStatus
+//   400: body:FailureResponse
+func (mc *MFAController) Confirm(c echo.Context) error {
+
+	u := model.MFARequest{}
+	if err := c.Bind(&u); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	user, err := mc.user(c)
+	if err != nil {
+		return mc.badResponse(c, err)
+	}
+	if err := mc.MFAService.Confirm(user, u.Code); err != nil {
+		return mc.badResponse(c, err)
+	}
+
+	response := fmt.Sprintf("{\"data\":\"%s\",\"message\":\"%s\"}", user.GUID, "successfully enabled 2FA")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, response)
+}
+
+// swagger:route POST /users/mfa/disable user userDisableMFA
+//
+// Turns the 2FA of the current user off with a code of the app or a recovery code,
+// not for the users the policy wants a code from
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: MFARequest
+//   in: body
+//   description: the code of the app or a recovery code
+//   schema:
+//      type: MFARequest
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:UserDeleteSuccessResponse
+//   400: body:FailureResponse
+func (mc *MFAController) Disable(c echo.Context) error {
+
+	u := model.MFARequest{}
+	if err := c.Bind(&u); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	user, err := mc.user(c)
+	if err != nil {
+		return mc.badResponse(c, err)
+	}
+	if err := mc.MFAService.Disable(user, u.Code); err != nil {
+		return mc.badResponse(c, err)
+	}
+
+	response := fmt.Sprintf("{\"data\":\"%s\",\"message\":\"%s\"}", user.GUID, "successfully disabled 2FA")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, response)
+}
+
+// swagger:route DELETE /users/{userGuid}/mfa user userResetMFA
+//
+// Removes the 2FA of a user who lost the app, the user enrols again
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: userGuid
+//   in: path
+//   type: string
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:UserDeleteSuccessResponse
+//   400: body:FailureResponse
+func (mc *MFAController) ResetUser(c echo.Context) error {
+
+	guid := c.Param("userGuid")
+	user, err := mc.MFAService.Reset(guid, auth.GetPartition(c), auth.IsSuperAdmin(c))
+	if err != nil {
+		logger.Error("2fa: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusNotFound, webmessages.MFAUserNotFound)
+	}
+
+	/* the sessions made with the lost TOTP end too */
+	if _, err := mc.SessionService.RevokeUser(user.UserName, user.PartId, true); err != nil {
+		logger.Error("session: ", err)
+	}
+
+	response := fmt.Sprintf("{\"data\":\"%s\",\"message\":\"%s\"}", guid, "successfully reset 2FA")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, response)
+}
+
+// swagger:route GET /mfa/policy user userGetMFAPolicy
+//
+// Returns the user groups which must log in with 2FA
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:MFAPolicy
+//   400: body:FailureResponse
+func (mc *MFAController) GetPolicy(c echo.Context) error {
+
+	policy, err := mc.MFAService.Policy()
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(policy, "data")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusOK, reply.String())
+}
+
+// swagger:route PUT /mfa/policy user userSetMFAPolicy
+//
+// Sets the user groups which must log in with 2FA, their users without TOTP enrol at
+// their next login
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: MFAPolicy
+//   in: body
+//   description: the user groups
+//   schema:
+//      type: MFAPolicy
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:MFAPolicy
+//   400: body:FailureResponse
+func (mc *MFAController) SetPolicy(c echo.Context) error {
+
+	policy := model.MFAPolicy{}
+	if err := c.Bind(&policy); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	err := mc.MFAService.SetPolicy(policy)
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.MFAFailed)
+	}
+	if policy, err = mc.MFAService.Policy(); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(policy, "data")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
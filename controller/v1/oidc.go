@@ -0,0 +1,118 @@
+package controllerv1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/oidc"
+)
+
+type OIDCController struct {
+	Controller
+	OIDCService *service.OIDCService
+	Providers   map[string]*oidc.Provider
+	Logins      *oidc.Logins
+}
+
+// swagger:route GET /oidc/login/{provider} user userOIDCLogin
+//
+// Sends the browser to the OpenID Connect provider to log in
+// ---
+// parameters:
+// + name: provider
+//   in: path
+//   type: string
+//   required: true
+//
+// responses:
+//   302:
+//   404: body:FailureResponse
+func (oc *OIDCController) LoginRedirect(c echo.Context) error {
+
+	provider, ok := oc.Providers[c.Param("provider")]
+	if !ok {
+		return httpresponse.CreateBadResponse(&c, http.StatusNotFound, webmessages.OIDCProviderNotFound)
+	}
+
+	login, err := oc.Logins.Start(provider.Name)
+	if err != nil {
+		logger.Error("oidc: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusInternalServerError, webmessages.OIDCLoginFailed)
+	}
+	u, err := provider.AuthCodeURL(c.Request().Context(), login)
+	if err != nil {
+		logger.Error("oidc: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadGateway, webmessages.OIDCLoginFailed)
+	}
+
+	logger.Debug("OIDC redirecting to: ", u)
+	return c.Redirect(http.StatusFound, u)
+}
+
+// swagger:route GET /oidc/callback/{provider} user userOIDCCallback
+//
+// The OpenID Connect provider sends the browser back here, the browser goes on to the UI
+// with a one time token to swap at /oauth2/token
+// ---
+// parameters:
+// + name: provider
+//   in: path
+//   type: string
+//   required: true
+//
+// responses:
+//   302:
+//   400: body:FailureResponse
+//   401: body:FailureResponse
+func (oc *OIDCController) Callback(c echo.Context) error {
+
+	provider, ok := oc.Providers[c.Param("provider")]
+	if !ok {
+		return httpresponse.CreateBadResponse(&c, http.StatusNotFound, webmessages.OIDCProviderNotFound)
+	}
+
+	if val := c.QueryParam("error"); val != "" {
+		logger.Error("oidc: ", provider.Name, " returned: ", val, " ", c.QueryParam("error_description"))
+		return httpresponse.CreateBadResponse(&c, http.StatusUnauthorized, webmessages.OIDCLoginFailed)
+	}
+
+	/* the state is used once and only for the provider it has been made for */
+	login, ok := oc.Logins.Finish(c.QueryParam("state"))
+	if !ok || login.Provider != provider.Name {
+		logger.Error("oidc: invalid state for ", provider.Name)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, "State invalid")
+	}
+	code := c.QueryParam("code")
+	if code == "" {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, "Code not found")
+	}
+
+	claims, err := provider.Exchange(c.Request().Context(), code, login)
+	if err != nil {
+		logger.Error("oidc: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusUnauthorized, webmessages.OIDCLoginFailed)
+	}
+	user, err := oc.OIDCService.User(provider, claims)
+	if err != nil {
+		logger.Error("oidc: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusUnauthorized, webmessages.OIDCLoginFailed)
+	}
+
+	ssoToken := uuid.NewV4().String()
+	config.OAuth2TokenMap.Add(ssoToken, model.OAuth2MapToken{
+		CreateDate: time.Now(),
+		ExpireDate: time.Now().Add(time.Duration(config.Setting.OIDC_SETTINGS.ExpireSSOToken) * time.Minute),
+		User:       &user,
+		Internal:   provider.Provision,
+	})
+
+	return c.Redirect(http.StatusFound, config.Setting.OIDC_SETTINGS.UrlToService+"?token="+ssoToken)
+}
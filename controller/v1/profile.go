@@ -9,11 +9,13 @@ import (
 	"github.com/sipcapture/homer-app/data/service"
 	httpresponse "github.com/sipcapture/homer-app/network/response"
 	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/decoder"
 )
 
 type ProfileController struct {
 	Controller
 	ProfileService *service.ProfileService
+	Decoders       *decoder.Registry
 }
 
 func (pc *ProfileController) GetHepsub(c echo.Context) error {
@@ -130,6 +132,21 @@ func (pc *ProfileController) GetModulesStatus(c echo.Context) error {
 	modulesResponse := gabs.New()
 	modulesResponse.Set(moduleLoki.Data(), "loki")
 
+	/* decoders and their health */
+	moduleDecoders := []decoder.Status{}
+	if pc.Decoders != nil {
+		moduleDecoders = pc.Decoders.Status()
+	}
+	modulesResponse.Set(moduleDecoders, "decoders")
+
+	/* the GeoIP databases and when they were loaded */
+	moduleGeoIP := gabs.New()
+	moduleGeoIP.Set(config.Setting.GEOIP_SETTINGS.Enable, "enable")
+	if db := service.GeoIP(); db != nil {
+		moduleGeoIP.Set(db.Status(), "databases")
+	}
+	modulesResponse.Set(moduleGeoIP.Data(), "geoip")
+
 	reply := gabs.New()
 	reply.Set("Modules status", "message")
 	reply.Set(modulesResponse.Data(), "data")
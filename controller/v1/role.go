@@ -0,0 +1,224 @@
+package controllerv1
+
+import (
+	"net/http"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+type RoleController struct {
+	Controller
+	RoleService *service.RoleService
+}
+
+// swagger:route GET /roles role roleGetAllRoles
+//
+// Get all roles with their permissions and groups
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   200: body:RoleStructList
+//   400: body:FailureResponse
+func (rc *RoleController) GetAllRoles(c echo.Context) error {
+
+	roles, err := rc.RoleService.GetAll()
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(roles, "data")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route GET /roles/permissions role roleGetPermissions
+//
+// Get the permissions of the roles and the ones of the current user
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   200: body:SuccessResponse
+func (rc *RoleController) GetPermissions(c echo.Context) error {
+
+	reply := gabs.New()
+	reply.Set(model.Permissions, "data", "all")
+	reply.Set(model.DefaultPermissions, "data", "default")
+	reply.Set(auth.GetPermissions(c), "data", "user")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route POST /roles role roleAddRole
+//
+// Adds a role
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: RoleStruct
+//   in: body
+//   description: RoleStruct parameters
+//   schema:
+//      type: RoleStruct
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:RoleSuccessResponse
+//   400: body:FailureResponse
+func (rc *RoleController) AddRole(c echo.Context) error {
+
+	role := model.TableRole{}
+	if err := c.Bind(&role); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if err := c.Validate(role); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+
+	reply, err := rc.RoleService.Add(&role)
+	if err != nil {
+		logger.Error("role: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.RoleFailed+": "+err.Error())
+	}
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply)
+}
+
+// swagger:route PUT /roles/{guid} role roleUpdateRole
+//
+// Update the name, permissions and groups of a role
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: guid
+//   in: path
+//   example: 11111111-1111-1111-1111-111111111111
+//   description: guid of the role
+//   required: true
+//   type: string
+// + name: RoleStruct
+//   in: body
+//   description: RoleStruct parameters
+//   schema:
+//      type: RoleStruct
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:RoleSuccessResponse
+//   400: body:FailureResponse
+func (rc *RoleController) UpdateRole(c echo.Context) error {
+
+	role := model.TableRole{}
+	if err := c.Bind(&role); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if err := c.Validate(role); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+	role.GUID = c.Param("guid")
+	if _, err := rc.RoleService.Get(role.GUID); err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.RoleNotFound)
+	}
+
+	if err := rc.RoleService.Update(&role); err != nil {
+		logger.Error("role: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.RoleFailed+": "+err.Error())
+	}
+
+	reply := gabs.New()
+	reply.Set(role.GUID, "data")
+	reply.Set("successfully updated role", "message")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route DELETE /roles/{guid} role roleDeleteRole
+//
+// Delete a role, the default role can't be deleted
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: guid
+//   in: path
+//   example: 11111111-1111-1111-1111-111111111111
+//   description: guid of the role
+//   required: true
+//   type: string
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:RoleSuccessResponse
+//   400: body:FailureResponse
+func (rc *RoleController) DeleteRole(c echo.Context) error {
+
+	role, err := rc.RoleService.Get(c.Param("guid"))
+	if err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.RoleNotFound)
+	}
+
+	if err := rc.RoleService.Delete(&role); err != nil {
+		logger.Error("role: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.RoleFailed+": "+err.Error())
+	}
+
+	reply := gabs.New()
+	reply.Set(role.GUID, "data")
+	reply.Set("successfully deleted role", "message")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
@@ -1,19 +1,13 @@
 package controllerv1
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/Jeffail/gabs/v2"
 	"github.com/labstack/echo/v4"
 	"github.com/sipcapture/homer-app/auth"
-	"github.com/sipcapture/homer-app/config"
 	"github.com/sipcapture/homer-app/data/service"
 	"github.com/sipcapture/homer-app/model"
 	httpresponse "github.com/sipcapture/homer-app/network/response"
@@ -26,11 +20,14 @@ type SearchController struct {
 	SearchService  *service.SearchService
 	SettingService *service.UserSettingsService
 	AliasService   *service.AliasService
+	TenantService  *service.TenantService
 }
 
 // swagger:route POST /search/call/data search searchSearchData
 //
-// Returns data based upon filtered json
+// Returns data based upon filtered json. The stir.* and lint.* fields are matched on the
+// decoded messages: the rows are read by date, ten times the limit at most, so a search
+// with them can return fewer rows than the limit.
 // ---
 // consumes:
 // - application/json
@@ -66,34 +63,15 @@ func (sc *SearchController) SearchData(c echo.Context) error {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
 	}
 
-	aliasRowData, _ := sc.AliasService.GetAllActive()
-	aliasData := make(map[string]string)
-	for _, row := range aliasRowData {
-		cidr := row.IP + "/" + strconv.Itoa(*row.Mask)
-		Port := strconv.Itoa(*row.Port)
-		CaptureID := row.CaptureID
-		ip, ipnet, err := net.ParseCIDR(cidr)
-		if err != nil {
-			logger.Debug("ParseCIDR alias CIDR: ["+cidr+"] error: ", err.Error())
-			logger.Error("ParseCIDR alias CIDR: ["+cidr+"] error: ", err.Error())
-		} else {
-			for ip := ip.Mask(ipnet.Mask); ipnet.Contains(ip); inc(ip) {
-				aliasData[ip.String()+":"+Port] = row.Alias
-				if config.Setting.MAIN_SETTINGS.UseCaptureIDInAlias {
-					aliasData[ip.String()+":"+Port+":"+CaptureID] = row.Alias
-				}
-			}
-		}
-	}
-
-	mapsFieldsData, err := sc.SettingService.GetAllMapping()
+	partid := auth.RequestPartition(c)
+	mapsFieldsData, err := sc.SettingService.GetAllMapping(partid)
 	if err != nil {
 		logger.Error("mapping error select: ", mapsFieldsData)
 	}
 
-	userGroup := auth.GetUserGroup(c)
+	scope := tenantFilter(c, sc.TenantService)
 
-	responseData, err := sc.SearchService.SearchData(&searchObject, aliasData, userGroup, mapsFieldsData)
+	responseData, err := sc.SearchService.SearchData(&searchObject, sc.AliasService.Resolver(partid), scope, mapsFieldsData)
 	if err != nil {
 		logger.Error("Error during data select: ", err.Error())
 		logger.Error("Error data select: ", responseData)
@@ -102,15 +80,6 @@ func (sc *SearchController) SearchData(c echo.Context) error {
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, responseData)
 }
 
-func inc(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
-
 // swagger:route POST /search/call/message search searchGetMessageById
 //
 // Returns message data based upon filtered json
@@ -148,7 +117,7 @@ func (sc *SearchController) GetMessageById(c echo.Context) error {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
 	}
 
-	responseData, err := sc.SearchService.GetMessageByID(&searchObject)
+	responseData, err := sc.SearchService.GetMessageByID(&searchObject, tenantFilter(c, sc.TenantService))
 	if err != nil {
 		logger.Debug("error during get message by id: ", err.Error())
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
@@ -193,7 +162,7 @@ func (sc *SearchController) GetDecodeMessageById(c echo.Context) error {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
 	}
 
-	responseData, err := sc.SearchService.GetDecodedMessageByID(&searchObject)
+	responseData, err := sc.SearchService.GetDecodedMessageByID(c.Request().Context(), &searchObject, tenantFilter(c, sc.TenantService))
 	if err != nil {
 		logger.Debug(responseData)
 	}
@@ -237,36 +206,15 @@ func (sc *SearchController) GetTransaction(c echo.Context) error {
 	}
 
 	transactionData, _ := json.Marshal(transactionObject)
-	correlation, _ := sc.SettingService.GetCorrelationMap(&transactionObject)
-	aliasRowData, _ := sc.AliasService.GetAllActive()
-
-	aliasData := make(map[string]string)
-	for _, row := range aliasRowData {
-		cidr := row.IP + "/" + strconv.Itoa(*row.Mask)
-		Port := strconv.Itoa(*row.Port)
-		CaptureID := row.CaptureID
-		ipAddr, ipNet, err := net.ParseCIDR(cidr)
-
-		if err != nil {
-			logger.Debug("ParseCIDR alias CIDR: ["+cidr+"] error: ", err.Error())
-			logger.Error("ParseCIDR alias CIDR: ["+cidr+"] error: ", err.Error())
-		} else {
-			for ip := ipAddr.Mask(ipNet.Mask); ipNet.Contains(ip); inc(ip) {
-				aliasData[ip.String()+":"+Port] = row.Alias
-				if config.Setting.MAIN_SETTINGS.UseCaptureIDInAlias {
-					aliasData[ip.String()+":"+Port+":"+CaptureID] = row.Alias
-				}
-			}
-		}
-	}
-
+	partid := auth.RequestPartition(c)
+	correlation, _ := sc.SettingService.GetCorrelationMap(&transactionObject, partid)
 	searchTable := "hep_proto_1_default'"
 
-	userGroup := auth.GetUserGroup(c)
+	scope := tenantFilter(c, sc.TenantService)
 
 	reply, _ := sc.SearchService.GetTransaction(searchTable, transactionData,
-		correlation, false, aliasData, 0, transactionObject.Param.Location.Node,
-		sc.SettingService, userGroup, transactionObject.Param.WhiteList)
+		correlation, false, sc.AliasService.Resolver(partid), 0, transactionObject.Param.Location.Node,
+		sc.SettingService, partid, scope, transactionObject.Param.WhiteList)
 
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply)
 
@@ -313,7 +261,7 @@ func (sc *SearchController) GetTransactionQos(c echo.Context) error {
 
 	searchTable := [...]string{"hep_proto_5_default", "hep_proto_35_default"}
 
-	row, _ := sc.SearchService.GetTransactionQos(searchTable, transactionData, searchObject.Param.Location.Node)
+	row, _ := sc.SearchService.GetTransactionQos(searchTable, transactionData, searchObject.Param.Location.Node, tenantFilter(c, sc.TenantService))
 
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, row)
 
@@ -357,7 +305,7 @@ func (sc *SearchController) GetTransactionLog(c echo.Context) error {
 	}
 	transactionData, _ := json.Marshal(searchObject)
 	searchTable := "hep_proto_100_default"
-	row, _ := sc.SearchService.GetTransactionLog(searchTable, transactionData, searchObject.Param.Location.Node)
+	row, _ := sc.SearchService.GetTransactionLog(searchTable, transactionData, searchObject.Param.Location.Node, tenantFilter(c, sc.TenantService))
 
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, row)
 }
@@ -372,7 +320,7 @@ func (sc *SearchController) GetTransactionHepSub(c echo.Context) error {
 	transactionData, _ := json.Marshal(searchObject)
 
 	searchTable := "hep_proto_100_default"
-	row, _ := sc.SearchService.GetTransactionLog(searchTable, transactionData, searchObject.Param.Location.Node)
+	row, _ := sc.SearchService.GetTransactionLog(searchTable, transactionData, searchObject.Param.Location.Node, tenantFilter(c, sc.TenantService))
 
 	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, row)
 }
@@ -415,14 +363,14 @@ func (sc *SearchController) GetMessagesAsPCap(c echo.Context) error {
 	}
 
 	transactionData, _ := json.Marshal(searchObject)
-	correlation, _ := sc.SettingService.GetCorrelationMap(&searchObject)
-	aliasData := make(map[string]string)
+	partid := auth.RequestPartition(c)
+	correlation, _ := sc.SettingService.GetCorrelationMap(&searchObject, partid)
 
 	searchTable := "hep_proto_1_default'"
-	userGroup := auth.GetUserGroup(c)
+	scope := tenantFilter(c, sc.TenantService)
 
-	reply, _ := sc.SearchService.GetTransaction(searchTable, transactionData, correlation, false, aliasData, 1,
-		searchObject.Param.Location.Node, sc.SettingService, userGroup, searchObject.Param.WhiteList)
+	reply, _ := sc.SearchService.GetTransaction(searchTable, transactionData, correlation, false, sc.AliasService.Resolver(partid), 1,
+		searchObject.Param.Location.Node, sc.SettingService, partid, scope, searchObject.Param.WhiteList)
 
 	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=export-%s.pcap", time.Now().Format(time.RFC3339)))
 	if err := c.Blob(http.StatusOK, "application/octet-stream", []byte(reply)); err != nil {
@@ -472,16 +420,16 @@ func (sc *SearchController) GetMessagesAsText(c echo.Context) error {
 	}
 
 	transactionData, _ := json.Marshal(searchObject)
-	correlation, _ := sc.SettingService.GetCorrelationMap(&searchObject)
-	aliasData := make(map[string]string)
+	partid := auth.RequestPartition(c)
+	correlation, _ := sc.SettingService.GetCorrelationMap(&searchObject, partid)
 
 	searchTable := "hep_proto_1_default'"
 
-	userGroup := auth.GetUserGroup(c)
+	scope := tenantFilter(c, sc.TenantService)
 
 	reply, _ := sc.SearchService.GetTransaction(searchTable, transactionData,
-		correlation, false, aliasData, 2, searchObject.Param.Location.Node,
-		sc.SettingService, userGroup, searchObject.Param.WhiteList)
+		correlation, false, sc.AliasService.Resolver(partid), 2, searchObject.Param.Location.Node,
+		sc.SettingService, partid, scope, searchObject.Param.WhiteList)
 
 	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=export-%s.txt", time.Now().Format(time.RFC3339)))
 	if err := c.String(http.StatusOK, reply); err != nil {
@@ -495,137 +443,3 @@ func (sc *SearchController) GetMessagesAsText(c echo.Context) error {
 	//return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply)
 
 }
-
-// swagger:route POST /import/data/pcap Import GetMessagesAsPCap
-//
-// Returns pcap data based upon filtered json
-// ---
-// consumes:
-// - application/json
-// produces:
-// - application/json
-// parameters:
-// + name: SearchObject
-//   in: body
-//   type: object
-//   description: SearchObject parameters
-//   schema:
-//     type: SearchObject
-//   required: true
-// Security:
-// - bearer: []
-//
-// SecurityDefinitions:
-// bearer:
-//      type: apiKey
-//      name: Authorization
-//      in: header
-//
-// responses:
-//   201: body:ListUsers
-//   400: body:FailureResponse
-func (sc *SearchController) GetDataAsPCap(c echo.Context) error {
-
-	file, err := c.FormFile("fileKey")
-	if err != nil {
-		logger.Error("GetDataAsPCap fileKey was not found: ", err.Error())
-		return err
-	}
-
-	src, err := file.Open()
-	if err != nil {
-		logger.Error("GetDataAsPCap couldn't open it: ", err.Error())
-		return err
-	}
-	defer src.Close()
-
-	buf := bytes.NewBuffer(nil)
-
-	// Copy
-	if _, err = io.Copy(buf, src); err != nil {
-		logger.Error("GetDataAsPCap couldn't copy it: ", err.Error())
-		return err
-	}
-
-	goodCounter, badCounter, err := sc.SearchService.ImportPcapData(buf, false)
-	if err != nil {
-		logger.Error("Bad decoding: ", err)
-		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadPCAPData)
-	} else {
-		reply := gabs.New()
-		report := gabs.New()
-		report.Set(goodCounter, "inserted")
-		report.Set(badCounter, "rejected")
-		reply.Set(report.Data(), "data")
-		reply.Set("All good", "message")
-
-		return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
-	}
-}
-
-// swagger:route POST /import/data/pcap/now Import GetMessagesAsPCapNow
-//
-// Returns pcap data based upon filtered json
-// ---
-// consumes:
-// - application/json
-// produces:
-// - application/json
-// parameters:
-// + name: SearchObject
-//   in: body
-//   type: object
-//   description: SearchObject parameters
-//   schema:
-//     type: SearchObject
-//   required: true
-// Security:
-// - bearer: []
-//
-// SecurityDefinitions:
-// bearer:
-//      type: apiKey
-//      name: Authorization
-//      in: header
-//
-// responses:
-//   201: body:ListUsers
-//   400: body:FailureResponse
-func (sc *SearchController) GetDataAsPCapNow(c echo.Context) error {
-
-	file, err := c.FormFile("fileKey")
-	if err != nil {
-		logger.Error("GetDataAsPCap fileKey was not found: ", err.Error())
-		return err
-	}
-
-	src, err := file.Open()
-	if err != nil {
-		logger.Error("GetDataAsPCap couldn't open it: ", err.Error())
-		return err
-	}
-	defer src.Close()
-
-	buf := bytes.NewBuffer(nil)
-
-	// Copy
-	if _, err = io.Copy(buf, src); err != nil {
-		logger.Error("GetDataAsPCap couldn't copy it: ", err.Error())
-		return err
-	}
-
-	goodCounter, badCounter, err := sc.SearchService.ImportPcapData(buf, true)
-	if err != nil {
-		logger.Error("Bad decoding: ", err)
-		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadPCAPData)
-	} else {
-		reply := gabs.New()
-		report := gabs.New()
-		report.Set(goodCounter, "inserted")
-		report.Set(badCounter, "rejected")
-		reply.Set(report.Data(), "data")
-		reply.Set("All good", "message")
-
-		return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
-	}
-}
@@ -0,0 +1,203 @@
+package controllerv1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+type SessionController struct {
+	Controller
+	SessionService *service.SessionService
+}
+
+// swagger:route POST /auth/refresh user userRefreshSession
+//
+// Returns a new JWT Token and a new refresh token, the refresh token can be used once
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: RefreshTokenRequest
+//   in: body
+//   description: the refresh token of the last login or refresh
+//   schema:
+//      type: RefreshTokenRequest
+//   required: true
+//
+// responses:
+//   201: body:UserLoginSuccessResponse
+//   401: body:FailureResponse
+func (sc *SessionController) RefreshSession(c echo.Context) error {
+
+	u := model.RefreshTokenRequest{}
+	if err := c.Bind(&u); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if err := c.Validate(u); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+
+	loginObject, err := sc.SessionService.Refresh(u.RefreshToken, c.RealIP(), c.Request().UserAgent())
+	if err != nil {
+		logger.Error("refresh: ", err)
+		badObject := model.UserTokenBadResponse{}
+		badObject.StatusCode = http.StatusUnauthorized
+		badObject.Message = webmessages.RefreshTokenInvalid
+		badObject.Error = webmessages.Unauthorized
+		response, _ := json.Marshal(badObject)
+		return httpresponse.CreateBadResponseWithJson(&c, http.StatusUnauthorized, response)
+	}
+
+	response, _ := json.Marshal(loginObject)
+	return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, response)
+}
+
+// swagger:route POST /auth/logout user userLogout
+//
+// Ends the session of the JWT Token, its refresh token and JWT Tokens don't work anymore
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   201: body:SessionSuccessResponse
+//   400: body:FailureResponse
+func (sc *SessionController) Logout(c echo.Context) error {
+
+	guid := auth.GetSession(c)
+	if guid == "" {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.SessionNotFound)
+	}
+	if err := sc.SessionService.Logout(guid); err != nil {
+		logger.Error("logout: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	response := fmt.Sprintf("{\"data\":\"%s\",\"message\":\"%s\"}", guid, "successfully logged out")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, response)
+}
+
+// swagger:route GET /sessions session sessionGetSessions
+//
+// Get the active sessions, of a user with ?username=
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   200: body:SessionStructList
+//   400: body:FailureResponse
+func (sc *SessionController) GetSessions(c echo.Context) error {
+
+	sessions, err := sc.SessionService.GetAll(c.QueryParam("username"), auth.AdminPartition(c), auth.IsSuperAdmin(c))
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(sessions, "data")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusOK, reply.String())
+}
+
+// swagger:route DELETE /sessions/{guid} session sessionDeleteSession
+//
+// Ends a session
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: guid
+//   in: path
+//   type: string
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:SessionSuccessResponse
+//   400: body:FailureResponse
+func (sc *SessionController) DeleteSession(c echo.Context) error {
+
+	guid := c.Param("guid")
+	if err := sc.SessionService.Revoke(guid, auth.AdminPartition(c), auth.IsSuperAdmin(c)); err != nil {
+		logger.Error("session: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusNotFound, webmessages.SessionNotFound)
+	}
+
+	response := fmt.Sprintf("{\"data\":\"%s\",\"message\":\"%s\"}", guid, "successfully ended session")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, response)
+}
+
+// swagger:route DELETE /sessions/user/{username} session sessionDeleteUserSessions
+//
+// Ends all the sessions of a user
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: username
+//   in: path
+//   type: string
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:SessionSuccessResponse
+//   400: body:FailureResponse
+func (sc *SessionController) DeleteUserSessions(c echo.Context) error {
+
+	username := c.Param("username")
+	count, err := sc.SessionService.RevokeUser(username, auth.AdminPartition(c), auth.IsSuperAdmin(c))
+	if err != nil {
+		logger.Error("session: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(count, "data")
+	reply.Set(fmt.Sprintf("successfully ended %d sessions of %s", count, username), "message")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
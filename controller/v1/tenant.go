@@ -0,0 +1,226 @@
+package controllerv1
+
+import (
+	"net/http"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	httpresponse "github.com/sipcapture/homer-app/network/response"
+	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+type TenantController struct {
+	Controller
+	TenantService *service.TenantService
+}
+
+// tenantFilter returns the filter of the data the user or auth token of the request can see
+func tenantFilter(c echo.Context, ts *service.TenantService) *tenant.Filter {
+	return ts.Filter(auth.GetTenant(c), auth.GetUserGroup(c))
+}
+
+/* only the users of no tenant can change the tenants */
+func (tc *TenantController) denied(c echo.Context) bool {
+	return auth.GetTenant(c) != ""
+}
+
+// swagger:route GET /tenants tenant tenantGetAllTenants
+//
+// Get all tenants
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+// responses:
+//   200: body:TenantStructList
+//   400: body:FailureResponse
+func (tc *TenantController) GetAllTenants(c echo.Context) error {
+
+	if tc.denied(c) {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDenied)
+	}
+
+	tenants, err := tc.TenantService.GetAll()
+	if err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.BadDatabaseRetrieve)
+	}
+
+	reply := gabs.New()
+	reply.Set(tenants, "data")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route POST /tenants tenant tenantAddTenant
+//
+// Adds a tenant
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: TenantStruct
+//   in: body
+//   description: TenantStruct parameters
+//   schema:
+//      type: TenantStruct
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:TenantSuccessResponse
+//   400: body:FailureResponse
+func (tc *TenantController) AddTenant(c echo.Context) error {
+
+	if tc.denied(c) {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDenied)
+	}
+
+	val := model.TableTenant{}
+	if err := c.Bind(&val); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if err := c.Validate(val); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+
+	reply, err := tc.TenantService.Add(&val)
+	if err != nil {
+		logger.Error("tenant: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.TenantFailed+": "+err.Error())
+	}
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply)
+}
+
+// swagger:route PUT /tenants/{guid} tenant tenantUpdateTenant
+//
+// Update what the data of a tenant is
+// ---
+// consumes:
+// - application/json
+// produces:
+// - application/json
+// parameters:
+// + name: guid
+//   in: path
+//   example: 11111111-1111-1111-1111-111111111111
+//   description: guid of the tenant
+//   required: true
+//   type: string
+// + name: TenantStruct
+//   in: body
+//   description: TenantStruct parameters
+//   schema:
+//      type: TenantStruct
+//   required: true
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:TenantSuccessResponse
+//   400: body:FailureResponse
+func (tc *TenantController) UpdateTenant(c echo.Context) error {
+
+	if tc.denied(c) {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDenied)
+	}
+
+	val := model.TableTenant{}
+	if err := c.Bind(&val); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFormatIncorrect)
+	}
+	if err := c.Validate(val); err != nil {
+		logger.Error(err.Error())
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
+	}
+	val.GUID = c.Param("guid")
+	if _, err := tc.TenantService.Get(val.GUID); err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.TenantNotFound)
+	}
+
+	if err := tc.TenantService.Update(&val); err != nil {
+		logger.Error("tenant: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.TenantFailed+": "+err.Error())
+	}
+
+	reply := gabs.New()
+	reply.Set(val.GUID, "data")
+	reply.Set("successfully updated tenant", "message")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
+
+// swagger:route DELETE /tenants/{guid} tenant tenantDeleteTenant
+//
+// Delete a tenant without users and auth tokens
+// ---
+// produces:
+// - application/json
+// parameters:
+// + name: guid
+//   in: path
+//   example: 11111111-1111-1111-1111-111111111111
+//   description: guid of the tenant
+//   required: true
+//   type: string
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// Responses:
+//   201: body:TenantSuccessResponse
+//   400: body:FailureResponse
+func (tc *TenantController) DeleteTenant(c echo.Context) error {
+
+	if tc.denied(c) {
+		return httpresponse.CreateBadResponse(&c, http.StatusForbidden, webmessages.TenantDenied)
+	}
+
+	val, err := tc.TenantService.Get(c.Param("guid"))
+	if err != nil {
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.TenantNotFound)
+	}
+
+	if err := tc.TenantService.Delete(&val); err != nil {
+		logger.Error("tenant: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.TenantFailed+": "+err.Error())
+	}
+
+	reply := gabs.New()
+	reply.Set(val.GUID, "data")
+	reply.Set("successfully deleted tenant", "message")
+	return httpresponse.CreateSuccessResponse(&c, http.StatusCreated, reply.String())
+}
@@ -23,7 +23,8 @@ import (
 
 type UserController struct {
 	Controller
-	UserService *service.UserService
+	UserService    *service.UserService
+	SessionService *service.SessionService
 }
 
 // swagger:route GET /users user userGetUser
@@ -48,9 +49,10 @@ type UserController struct {
 //	400: body:FailureResponse
 func (uc *UserController) GetUser(c echo.Context) error {
 
-	userName, isAdmin := auth.IsRequestAdmin(c)
+	userName, _ := auth.IsRequestAdmin(c)
+	isAdmin := auth.HasPermission(c, model.PermissionUsersAdmin)
 
-	user, count, err := uc.UserService.GetUser(userName, isAdmin)
+	user, count, err := uc.UserService.GetUser(userName, isAdmin, auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserRequestFailed)
 	}
@@ -187,6 +189,17 @@ func (uc *UserController) CreateUser(c echo.Context) error {
 		logger.Error(err.Error())
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
+	/* the users of a tenant can only add users to it */
+	if tenant := auth.GetTenant(c); tenant != "" {
+		u.TenantGUID = tenant
+	}
+	/* an admin adds users to its partition, a super admin to any */
+	if !auth.IsSuperAdmin(c) {
+		u.PartId = auth.GetPartition(c)
+		u.SuperAdmin = false
+	} else if u.PartId == 0 {
+		u.PartId = auth.RequestPartition(c)
+	}
 	// create a new user in database
 	if err := uc.UserService.CreateNewUser(&u); err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserCreationFailed)
@@ -239,7 +252,8 @@ func (uc *UserController) UpdateUser(c echo.Context) error {
 	// Stub an user to be populated from the body
 	u := model.TableUser{}
 	u.GUID = c.Param("userGuid")
-	userName, isAdmin := auth.IsRequestAdmin(c)
+	userName, _ := auth.IsRequestAdmin(c)
+	isAdmin := auth.HasPermission(c, model.PermissionUsersAdmin)
 
 	if err := c.Bind(&u); err != nil {
 		logger.Error(err.Error())
@@ -250,8 +264,11 @@ func (uc *UserController) UpdateUser(c echo.Context) error {
 		logger.Error(err.Error())
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
+	if tenant := auth.GetTenant(c); tenant != "" {
+		u.TenantGUID = tenant
+	}
 	// update user info in database
-	if err := uc.UserService.UpdateUser(&u, userName, isAdmin); err != nil {
+	if err := uc.UserService.UpdateUser(&u, userName, isAdmin, auth.GetPartition(c), auth.IsSuperAdmin(c)); err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 	response := fmt.Sprintf("{\"data\":\"%s\",\"message\":\"%s\"}", u.GUID, "successfully updated user")
@@ -292,7 +309,7 @@ func (uc *UserController) DeleteUser(c echo.Context) error {
 	u := model.TableUser{}
 
 	u.GUID = c.Param("userGuid")
-	if err := uc.UserService.DeleteUser(&u); err != nil {
+	if err := uc.UserService.DeleteUser(&u, auth.GetPartition(c), auth.IsSuperAdmin(c)); err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserCreationFailed)
 	}
 	response := fmt.Sprintf("{\"data\":\"%s\",\"message\":\"%s\"}", u.GUID, "successfully deleted user")
@@ -318,6 +335,7 @@ func (uc *UserController) DeleteUser(c echo.Context) error {
 // responses:
 //
 //	201: body:UserLoginSuccessResponse
+//	202: body:MFAChallenge
 //	400: body:FailureResponse
 func (uc *UserController) LoginUser(c echo.Context) error {
 	u := model.UserloginDetails{}
@@ -330,7 +348,7 @@ func (uc *UserController) LoginUser(c echo.Context) error {
 		logger.Error(err.Error())
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
-	token, userData, err := uc.UserService.LoginUser(u.Username, u.Password)
+	userData, challenge, err := uc.UserService.LoginUser(u.Username, u.Password)
 	if err != nil {
 		loginObject := model.UserTokenBadResponse{}
 		loginObject.StatusCode = http.StatusUnauthorized
@@ -340,10 +358,17 @@ func (uc *UserController) LoginUser(c echo.Context) error {
 		return httpresponse.CreateBadResponseWithJson(&c, http.StatusUnauthorized, response)
 	}
 
-	loginObject := model.UserTokenSuccessfulResponse{}
-	loginObject.Token = token
-	loginObject.Scope = userData.GUID
-	loginObject.User.Admin = userData.IsAdmin
+	/* the token comes with the code at /auth/mfa */
+	if challenge != nil {
+		response, _ := json.Marshal(challenge)
+		return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusAccepted, response)
+	}
+
+	loginObject, err := uc.SessionService.Start(userData, uc.UserService.InternalAuth(), c.RealIP(), c.Request().UserAgent())
+	if err != nil {
+		logger.Error("session: ", err)
+		return httpresponse.CreateBadResponse(&c, http.StatusInternalServerError, webmessages.SessionFailed)
+	}
 	response, _ := json.Marshal(loginObject)
 	return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, response)
 }
@@ -558,7 +583,7 @@ func (uc *UserController) AuthSericeRequest(c echo.Context) error {
 	oAuth2Object.ExpireDate = time.Now().Add(time.Duration(config.Setting.OAUTH2_SETTINGS.ExpireSSOToken) * time.Minute)
 	logger.Debug("AuthSericeRequest GenerateToken: ", ssoToken)
 
-	config.OAuth2TokenMap[ssoToken] = oAuth2Object
+	config.OAuth2TokenMap.Add(ssoToken, oAuth2Object)
 
 	//c.Response().Header().Add("Authorization", "Bearer "+token.AccessToken)
 	return c.Redirect(http.StatusFound, config.Setting.OAUTH2_SETTINGS.UrlToService+"?token="+ssoToken)
@@ -589,7 +614,7 @@ func (uc *UserController) Oauth2TokenExchange(c echo.Context) error {
 
 	logger.Debug("Doing Oauth2TokenExchange....")
 
-	if !config.Setting.OAUTH2_SETTINGS.Enable {
+	if !config.Setting.OAUTH2_SETTINGS.Enable && !config.Setting.OIDC_SETTINGS.Enable {
 		return httpresponse.CreateBadResponse(&c, http.StatusNotImplemented, "oauth2 is not enabled [3]")
 	}
 
@@ -605,14 +630,26 @@ func (uc *UserController) Oauth2TokenExchange(c echo.Context) error {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, err.Error())
 	}
 
-	if oAuth2Object, ok := config.OAuth2TokenMap[u.OneTimeToken]; ok {
+	/* the token can be swapped once */
+	if oAuth2Object, ok := config.OAuth2TokenMap.Take(u.OneTimeToken); ok {
 
 		if oAuth2Object.ExpireDate.Before(time.Now()) {
 			logger.Error("key has been expired: ", u.OneTimeToken)
 			return httpresponse.CreateBadResponse(&c, http.StatusNotFound, "key has been expired")
 		}
 
-		token, userData, err := uc.UserService.LoginUserUsingOauthToken(oAuth2Object)
+		/* OpenID Connect has already found the user */
+		if oAuth2Object.User != nil {
+			loginObject, err := uc.SessionService.Start(*oAuth2Object.User, oAuth2Object.Internal, c.RealIP(), c.Request().UserAgent())
+			if err != nil {
+				logger.Error("session: ", err)
+				return httpresponse.CreateBadResponse(&c, http.StatusInternalServerError, webmessages.SessionFailed)
+			}
+			response, _ := json.Marshal(loginObject)
+			return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, response)
+		}
+
+		userData, err := uc.UserService.LoginUserUsingOauthToken(oAuth2Object)
 		if err != nil {
 			loginObject := model.UserTokenBadResponse{}
 			loginObject.StatusCode = http.StatusUnauthorized
@@ -623,10 +660,11 @@ func (uc *UserController) Oauth2TokenExchange(c echo.Context) error {
 			return httpresponse.CreateBadResponseWithJson(&c, http.StatusUnauthorized, response)
 		}
 
-		loginObject := model.UserTokenSuccessfulResponse{}
-		loginObject.Token = token
-		loginObject.Scope = userData.GUID
-		loginObject.User.Admin = userData.IsAdmin
+		loginObject, err := uc.SessionService.Start(userData, false, c.RealIP(), c.Request().UserAgent())
+		if err != nil {
+			logger.Error("session: ", err)
+			return httpresponse.CreateBadResponse(&c, http.StatusInternalServerError, webmessages.SessionFailed)
+		}
 		response, _ := json.Marshal(loginObject)
 		return httpresponse.CreateSuccessResponseWithJson(&c, http.StatusCreated, response)
 	} else {
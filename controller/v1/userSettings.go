@@ -40,7 +40,7 @@ type UserSettingsController struct {
 func (usc *UserSettingsController) GetAll(c echo.Context) error {
 
 	userName, isAdmin := auth.IsRequestAdmin(c)
-	reply, err := usc.UserSettingsService.GetAll(userName, isAdmin)
+	reply, err := usc.UserSettingsService.GetAll(userName, isAdmin, auth.AdminPartition(c))
 	if err != nil {
 		return httpresponse.CreateBadResponse(&c, http.StatusBadRequest, webmessages.UserSettingsFailed)
 	}
@@ -117,6 +117,9 @@ func (usc *UserSettingsController) AddUserSettings(c echo.Context) error {
 
 	if userName, isAdmin := auth.IsRequestAdmin(c); !isAdmin {
 		userObject.UserName = userName
+		userObject.PartId = auth.GetPartition(c)
+	} else if !auth.IsSuperAdmin(c) || userObject.PartId == 0 {
+		userObject.PartId = auth.RequestPartition(c)
 	}
 
 	row, _ := usc.UserSettingsService.Add(&userObject)
@@ -156,14 +159,14 @@ func (usc *UserSettingsController) DeleteUserSettings(c echo.Context) error {
 	userObject.GUID = c.Param("category")
 	userName, isAdmin := auth.IsRequestAdmin(c)
 
-	data, err := usc.UserSettingsService.Get(&userObject, userName, isAdmin)
+	data, err := usc.UserSettingsService.Get(&userObject, userName, isAdmin, auth.AdminPartition(c))
 	if err != nil {
 		reply := gabs.New()
 		reply.Set(userObject.GUID, "data")
 		reply.Set(fmt.Sprintf("the userobject with id %s were not found", userObject.GUID), "message")
 	}
 
-	if err := usc.UserSettingsService.Delete(&userObject, userName, isAdmin); err != nil {
+	if err := usc.UserSettingsService.Delete(&userObject, userName, isAdmin, auth.AdminPartition(c)); err != nil {
 		reply := gabs.New()
 		reply.Set(userObject.GUID, "data")
 		reply.Set(fmt.Sprintf("the userobject with id %s were not found", userObject.GUID), "message")
@@ -224,7 +227,7 @@ func (usc *UserSettingsController) UpdateUserSettings(c echo.Context) error {
 	userObject.GUID = c.Param("category")
 	userName, isAdmin := auth.IsRequestAdmin(c)
 
-	data, err := usc.UserSettingsService.Get(&userObject, userName, isAdmin)
+	data, err := usc.UserSettingsService.Get(&userObject, userName, isAdmin, auth.AdminPartition(c))
 	if err != nil {
 		reply := gabs.New()
 		reply.Set(userObject.GUID, "data")
@@ -233,7 +236,11 @@ func (usc *UserSettingsController) UpdateUserSettings(c echo.Context) error {
 
 	userObject.CreateDate = time.Now()
 	userObject.Id = data.Id
-	if err := usc.UserSettingsService.Update(&userObject, userName, isAdmin); err != nil {
+	/* only a super admin moves a setting to another partition */
+	if !auth.IsSuperAdmin(c) {
+		userObject.PartId = auth.GetPartition(c)
+	}
+	if err := usc.UserSettingsService.Update(&userObject, userName, isAdmin, auth.AdminPartition(c)); err != nil {
 		reply := gabs.New()
 		reply.Set(userObject.GUID, "data")
 		reply.Set(fmt.Sprintf("the userobject with id %s were not found", userObject.GUID), "message")
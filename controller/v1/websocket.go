@@ -2,68 +2,96 @@ package controllerv1
 
 import (
 	"fmt"
-	"net"
+	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/utils/heprelay"
 	"github.com/sipcapture/homer-app/utils/logger"
 	"golang.org/x/net/websocket"
 )
 
 type WebSocketController struct {
 	Controller
-	Addr *string
+	Relay *heprelay.Relay
 }
 
-//  GET /ws WebSocket
+// swagger:route GET /ws WebSocket webSocketRelayHepData
 //
-// Returns data based upon filtered json
+// WebSocket relaying binary HEPv3 frames to the configured collectors. Every message has to
+// be exactly one well-formed HEPv3 frame, others are dropped and counted. It needs the
+// permission hep:relay. Browsers pass the JWT as ?token= or the auth token as ?auth_token=.
 // ---
-// consumes:
-// - application/json
-// produces:
-// - application/json
 // parameters:
-// - name: SearchObject
-//   in: body
-//   type: object
-//   description: SearchObject parameters
-//   schema:
-//     "$ref": "#/definitions/SearchCallData"
-//   required: true
+// + name: token
+//   in: query
+//   type: string
+//   description: JWT of the user
+// + name: auth_token
+//   in: query
+//   type: string
+//   description: auth token
+// Security:
+// - bearer: []
+//
 // SecurityDefinitions:
 // bearer:
 //      type: apiKey
 //      name: Authorization
 //      in: header
+//
 // responses:
-//   '200': body:ListUsers
-//   '400': body:UserLoginFailureResponse
+//   101: description: switching protocols
+//   401: body:FailureResponse
+//   403: body:FailureResponse
 func (wb *WebSocketController) RelayHepData(c echo.Context) error {
-	websocket.Handler(func(ws *websocket.Conn) {
-		conn, err := net.Dial("udp", *wb.Addr)
-		if err != nil {
-			fmt.Printf("Some error %v", err)
-			return
-		}
 
+	userName, _ := auth.IsRequestAdmin(c)
+
+	/* the token has been checked already, no origin check needed */
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+
+		wb.Relay.Connected()
 		defer func() {
+			wb.Relay.Disconnected()
 			ws.Close()
-			conn.Close()
 		}()
+
+		limiter := wb.Relay.NewLimiter()
 		for {
 			// Read
 			var msg []byte
-			err = websocket.Message.Receive(ws, &msg)
-			if err != nil {
-				logger.Error(fmt.Sprintf("got error while reading data on websocket", err))
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				logger.Debug(fmt.Sprintf("websocket of [%s] closed: %v", userName, err))
 				break
 			}
-			_, err = conn.Write(msg)
-			if err != nil {
-				logger.Error(fmt.Sprintf("got error while writing data on hep socket", err))
-				break
+
+			if err := wb.Relay.Relay(msg, limiter); err != nil {
+				logger.Debug(fmt.Sprintf("frame of [%s] not relayed: %v", userName, err))
 			}
 		}
-	}).ServeHTTP(c.Response(), c.Request())
+	}}
+	server.ServeHTTP(c.Response(), c.Request())
 	return nil
 }
+
+// swagger:route GET /hep/relay/stats WebSocket webSocketRelayStats
+//
+// Returns the counters of the HEP relay: frames relayed, rejected and rate limited per upstream
+// ---
+// produces:
+// - application/json
+// Security:
+// - bearer: []
+//
+// SecurityDefinitions:
+// bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+// responses:
+//   200: body:HepRelayStats
+func (wb *WebSocketController) GetRelayStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, wb.Relay.Stats())
+}
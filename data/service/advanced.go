@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/Jeffail/gabs/v2"
+	uuid "github.com/satori/go.uuid"
 	"github.com/sipcapture/homer-app/model"
 )
 
@@ -13,16 +14,18 @@ type AdvancedService struct {
 	ServiceConfig
 }
 
-// this method create new user in the database
-// it doesn't check internally whether all the validation are applied or not
-func (as *AdvancedService) GetAll() (string, error) {
+// GetAll returns the global settings of a partition, the ones it doesn't have itself
+// are the ones of the default partition
+func (as *AdvancedService) GetAll(partid int) (string, error) {
 
 	var userGlobalSettings = []model.TableGlobalSettings{}
 	if err := as.Session.Debug().
 		Table("global_settings").
+		Scopes(partitionScope(partid)).
 		Find(&userGlobalSettings).Error; err != nil {
 		return "", errors.New("no users settings found")
 	}
+	userGlobalSettings = ownSettings(userGlobalSettings, partid)
 
 	data, _ := json.Marshal(userGlobalSettings)
 	rows, _ := gabs.ParseJSON(data)
@@ -34,11 +37,12 @@ func (as *AdvancedService) GetAll() (string, error) {
 }
 
 // this method gets all the mapping from database
-func (as *AdvancedService) GetAdvancedAgainstGUID(guid string) (string, error) {
+func (as *AdvancedService) GetAdvancedAgainstGUID(guid string, partid int) (string, error) {
 	var userGlobalSettings = []model.TableGlobalSettings{}
 	var count int
 	if err := as.Session.Debug().Table("global_settings").
 		Where("guid = ?", guid).
+		Scopes(partitionScope(partid)).
 		Find(&userGlobalSettings).Count(&count).Error; err != nil {
 		return "", err
 	}
@@ -60,10 +64,24 @@ func (as *AdvancedService) AddAdvanced(data model.TableGlobalSettings) (string,
 	return response, nil
 }
 
-// this method gets all users from database
-func (as *AdvancedService) UpdateAdvancedAgainstGUID(guid string, data model.TableGlobalSettings) (string, error) {
+// UpdateAdvancedAgainstGUID changes a global setting of the partition. A setting of the
+// default partition is copied into the partition, the other partitions keep using it.
+func (as *AdvancedService) UpdateAdvancedAgainstGUID(guid string, partid int, data model.TableGlobalSettings) (string, error) {
+	partid = model.Partition(partid)
+	stored := model.TableGlobalSettings{}
 	if err := as.Session.Debug().Table("global_settings").
 		Where("guid = ?", guid).
+		Scopes(partitionScope(partid)).
+		First(&stored).Error; err != nil {
+		return "", err
+	}
+	data.PartId = partid
+	if stored.PartId != partid {
+		data.GUID = uuid.NewV4().String()
+		return as.AddAdvanced(data)
+	}
+	if err := as.Session.Debug().Table("global_settings").
+		Where("guid = ? AND partid = ?", guid, partid).
 		Update(&data).Error; err != nil {
 		return "", err
 	}
@@ -71,11 +89,11 @@ func (as *AdvancedService) UpdateAdvancedAgainstGUID(guid string, data model.Tab
 	return response, nil
 }
 
-// this method delete the mapping from database
-func (as *AdvancedService) DeleteAdvancedAgainstGUID(guid string) (string, error) {
+// this method delete a global setting of the partition from database
+func (as *AdvancedService) DeleteAdvancedAgainstGUID(guid string, partid int) (string, error) {
 	var advancedObject []*model.TableGlobalSettings
 	if err := as.Session.Debug().Table("global_settings").
-		Where("guid = ?", guid).
+		Where("guid = ? AND partid = ?", guid, model.Partition(partid)).
 		Delete(&advancedObject).Error; err != nil {
 		return "", err
 	}
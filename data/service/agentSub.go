@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -13,16 +14,22 @@ import (
 	"sort"
 
 	"github.com/Jeffail/gabs/v2"
+	"github.com/jinzhu/gorm"
 	"github.com/sipcapture/homer-app/config"
 	"github.com/sipcapture/homer-app/model"
 	"github.com/sipcapture/homer-app/utils/exportwriter"
 	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/tenant"
 )
 
 type AgentsubService struct {
 	ServiceConfig
+	/* the data DB, the values a tenant looks up are checked against its rows */
+	DataSession map[string]*gorm.DB
 }
 
+var sourceHeaderField = regexp.MustCompile(`^(data_header|protocol_header)\.(\w+)$`)
+
 // GetAgentsub gets all active HEPSUB agent sessions from database
 func (hs *AgentsubService) GetAgentsub() (string, error) {
 	var AgentsubObject []model.TableAgentLocationSession
@@ -189,9 +196,10 @@ func (hs *AgentsubService) DeleteAgentsubAgainstGUID(guid string) (string, error
 }
 
 // DoSearchByPost loads the subscriber mappings from the database and builds a HEPSUB search query using request data
-func (hs *AgentsubService) DoSearchByPost(agentObject model.TableAgentLocationSession, searchObject model.SearchObject, typeRequest string) ([]byte, error) {
+func (hs *AgentsubService) DoSearchByPost(agentObject model.TableAgentLocationSession, searchObject model.SearchObject,
+	typeRequest string, scope *tenant.Filter) ([]byte, error) {
 
-	var lookupField string
+	var lookupField, table string
 	var hepsubObject []model.TableHepsubSchema
 	Data, _ := json.Marshal(searchObject.Param.Search)
 	sData, _ := gabs.ParseJSON(Data)
@@ -204,6 +212,7 @@ func (hs *AgentsubService) DoSearchByPost(agentObject model.TableAgentLocationSe
 			return nil, fmt.Errorf("Agent HEPSUB: key is wrong: %d", len(elemArray))
 		}
 
+		table = "hep_proto_" + key
 		hepID, _ := strconv.Atoi(elemArray[0])
 		if err := hs.Session.Debug().Table("hepsub_mapping_schema").
 			Where("profile = ? AND hepid = ?", elemArray[1], hepID).
@@ -244,6 +253,12 @@ func (hs *AgentsubService) DoSearchByPost(agentObject model.TableAgentLocationSe
 
 	timeFrom := time.Unix(searchObject.Timestamp.From/int64(time.Microsecond), 0)
 	timeTo := time.Unix(searchObject.Timestamp.To/int64(time.Microsecond), 0)
+
+	/* the agent knows nothing of tenants */
+	if err := hs.tenantValues(scope, table, lookupFields, dataPost, timeFrom, timeTo); err != nil {
+		return nil, err
+	}
+
 	if len(lookupRange) > 0 {
 		timeFrom = timeFrom.Add(time.Duration(lookupRange[0].(float64)) * time.Second).UTC()
 		timeTo = timeTo.Add(time.Duration(lookupRange[1].(float64)) * time.Second).UTC()
@@ -301,3 +316,126 @@ func (hs *AgentsubService) DoSearchByPost(agentObject model.TableAgentLocationSe
 	reply.Set(responseData.Data(), "data")
 	return reply.Bytes(), nil
 }
+
+// tenantValues checks that the tenant has rows with the values of the source fields in
+// the time range, a tenant of some captureIds or networks can't look up the others
+func (hs *AgentsubService) tenantValues(scope *tenant.Filter, table string, sourceFields *gabs.Container,
+	values *gabs.Container, from, to time.Time) error {
+
+	if scope == nil {
+		return nil
+	}
+	if !scope.AllowsTable(table) {
+		return ErrTenantHidden
+	}
+	if len(scope.CaptureIDs) == 0 && len(scope.Networks) == 0 {
+		return nil
+	}
+
+	/* without source fields the callid is sent */
+	paths := map[string]string{"callid": "sid"}
+	if sourceFields != nil {
+		paths = map[string]string{}
+		for field, path := range sourceFields.ChildrenMap() {
+			paths[field], _ = path.Data().(string)
+		}
+	}
+
+	for field, path := range paths {
+		column, ok := sourceColumn(path)
+		if !ok {
+			return fmt.Errorf("agent HEPSUB: the source field %q can't be checked for the tenant", path)
+		}
+		vals := sourceValues(values.Search(field))
+		if len(vals) == 0 {
+			continue
+		}
+
+		rows := []model.HepTable{}
+		for node, session := range hs.DataSession {
+			if !scope.AllowsNode(node) {
+				continue
+			}
+			searchTmp := []model.HepTable{}
+			if err := session.Debug().
+				Table(table).
+				Select("sid, protocol_header, data_header").
+				Where("create_date BETWEEN ? AND ? AND "+column+" IN (?)", from, to, vals).
+				Scopes(tenantScope(scope)).
+				Find(&searchTmp).Error; err != nil {
+				return err
+			}
+			for i := range searchTmp {
+				searchTmp[i].DBNode = node
+			}
+			rows = append(rows, searchTmp...)
+		}
+
+		if hidden := hiddenValues(tenantRows(scope, table, rows), path, vals); len(hidden) > 0 {
+			logger.Error("agent HEPSUB: the tenant ", scope.Name, " has no rows with ", field, " ", hidden)
+			return ErrTenantHidden
+		}
+	}
+	return nil
+}
+
+// sourceColumn returns the column of a source field of a hepsub mapping, the sid or
+// a field of the data_header or protocol_header
+func sourceColumn(path string) (string, bool) {
+
+	if path == "sid" {
+		return path, true
+	}
+	if m := sourceHeaderField.FindStringSubmatch(path); m != nil {
+		return fmt.Sprintf("%s->>'%s'", m[1], m[2]), true
+	}
+	return "", false
+}
+
+// sourceValues returns the values of a source field of the request, one or a list
+func sourceValues(values *gabs.Container) []string {
+
+	if values == nil || values.Data() == nil {
+		return nil
+	}
+	list, ok := values.Data().([]interface{})
+	if !ok {
+		list = []interface{}{values.Data()}
+	}
+	vals := make([]string, 0, len(list))
+	for _, val := range list {
+		vals = append(vals, fmt.Sprint(val))
+	}
+	return vals
+}
+
+// hiddenValues returns the values no row has in the source field
+func hiddenValues(rows []model.HepTable, path string, vals []string) []string {
+
+	seen := map[string]bool{}
+	for _, row := range rows {
+		if path == "sid" {
+			seen[row.Sid] = true
+			continue
+		}
+		m := sourceHeaderField.FindStringSubmatch(path)
+		header := row.DataHeader
+		if m[1] == "protocol_header" {
+			header = row.ProtocolHeader
+		}
+		fields := map[string]interface{}{}
+		decoder := json.NewDecoder(bytes.NewReader(header))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err == nil && fields[m[2]] != nil {
+			seen[fmt.Sprint(fields[m[2]])] = true
+		}
+	}
+
+	hidden := []string{}
+	for _, val := range vals {
+		if !seen[val] {
+			hidden = append(hidden, val)
+		}
+	}
+	return hidden
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+func TestAgentSearchTenant(t *testing.T) {
+
+	filter, err := tenant.New("a", []string{"2001"}, []string{"localnode"}, []string{"1_call"}, []string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("[TestAgentSearchTenant] unexpected error: %v", err)
+	}
+
+	/* the rows the query found, before they are checked again */
+	rows := []model.HepTable{
+		{Sid: "call-a", DBNode: "localnode", ProtocolHeader: json.RawMessage(`{"captureId":2001,"srcIp":"10.1.0.5","dstIp":"192.0.2.1"}`),
+			DataHeader: json.RawMessage(`{"callid":"call-a","method":"INVITE"}`)},
+		{Sid: "call-b", DBNode: "localnode", ProtocolHeader: json.RawMessage(`{"captureId":2002,"srcIp":"10.1.0.5","dstIp":"192.0.2.1"}`),
+			DataHeader: json.RawMessage(`{"callid":"call-b","method":"INVITE"}`)},
+		{Sid: "call-c", DBNode: "othernode", ProtocolHeader: json.RawMessage(`{"captureId":2001,"srcIp":"10.1.0.6","dstIp":"192.0.2.1"}`),
+			DataHeader: json.RawMessage(`{"callid":"call-c","method":"INVITE"}`)},
+	}
+	visible := tenantRows(filter, "hep_proto_1_call", rows)
+
+	if hidden := hiddenValues(visible, "sid", []string{"call-a", "call-b", "call-c"}); !reflect.DeepEqual(hidden, []string{"call-b", "call-c"}) {
+		t.Errorf("[TestAgentSearchTenant] sid got hidden %v", hidden)
+	}
+	if hidden := hiddenValues(visible, "data_header.callid", []string{"call-a"}); len(hidden) != 0 {
+		t.Errorf("[TestAgentSearchTenant] data_header.callid got hidden %v", hidden)
+	}
+	if hidden := hiddenValues(visible, "protocol_header.srcIp", []string{"10.1.0.5", "10.1.0.6"}); !reflect.DeepEqual(hidden, []string{"10.1.0.6"}) {
+		t.Errorf("[TestAgentSearchTenant] protocol_header.srcIp got hidden %v", hidden)
+	}
+	if hidden := hiddenValues(visible, "protocol_header.captureId", []string{"2001", "2002"}); !reflect.DeepEqual(hidden, []string{"2002"}) {
+		t.Errorf("[TestAgentSearchTenant] protocol_header.captureId got hidden %v", hidden)
+	}
+
+	for path, column := range map[string]string{"sid": "sid", "data_header.callid": "data_header->>'callid'",
+		"protocol_header.srcIp": "protocol_header->>'srcIp'", "raw": "", "data_header.x' OR 'a": ""} {
+		if got, ok := sourceColumn(path); got != column || ok != (column != "") {
+			t.Errorf("[TestAgentSearchTenant] column of %q got %q", path, got)
+		}
+	}
+}
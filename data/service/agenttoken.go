@@ -7,6 +7,7 @@ import (
 	"sort"
 
 	"github.com/Jeffail/gabs/v2"
+	"github.com/jinzhu/gorm"
 	"github.com/sipcapture/homer-app/model"
 	"github.com/sipcapture/homer-app/utils/logger"
 )
@@ -15,12 +16,26 @@ type AuthtokenService struct {
 	ServiceConfig
 }
 
+/* the partition of a token is the one of its user object */
+const tokenPartition = "COALESCE((user_object->>'partid')::int, 10)"
+
+// tokenScope selects the tokens of a partition, of all partitions for 0
+func tokenScope(partid int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if partid == 0 {
+			return db
+		}
+		return db.Where(tokenPartition+" = ?", partid)
+	}
+}
+
 // this method gets all users from database
-func (hs *AuthtokenService) GetAuthtokenAgainstGUID(guid string) (string, error) {
+func (hs *AuthtokenService) GetAuthtokenAgainstGUID(guid string, partid int) (string, error) {
 	var AuthtokenObject []model.TableAuthToken
 	var count int
 	if err := hs.Session.Debug().Table("auth_token").
 		Where("guid = ?", guid).
+		Scopes(tokenScope(partid)).
 		Find(&AuthtokenObject).Count(&count).Error; err != nil {
 		return "", err
 	}
@@ -37,10 +52,11 @@ func (hs *AuthtokenService) GetAuthtokenAgainstGUID(guid string) (string, error)
 }
 
 // this method gets all users from database
-func (hs *AuthtokenService) GetAuthtoken() (string, error) {
+func (hs *AuthtokenService) GetAuthtoken(partid int) (string, error) {
 	var AuthtokenObject []model.TableAuthToken
 	var count int
 	if err := hs.Session.Debug().Table("auth_token").
+		Scopes(tokenScope(partid)).
 		Find(&AuthtokenObject).Count(&count).Error; err != nil {
 		return "", err
 	}
@@ -55,6 +71,9 @@ func (hs *AuthtokenService) GetAuthtoken() (string, error) {
 
 // this method gets all users from database
 func (hs *AuthtokenService) AddAuthtoken(data model.TableAuthToken) (string, error) {
+	if err := hs.checkTenant(data.TenantGUID); err != nil {
+		return "", err
+	}
 	if err := hs.Session.Debug().Table("auth_token").
 		Create(&data).Error; err != nil {
 		return "", err
@@ -70,21 +89,33 @@ func (hs *AuthtokenService) AddAuthtoken(data model.TableAuthToken) (string, err
 }
 
 // this method gets all users from database
-func (hs *AuthtokenService) UpdateAuthtokenAgainstGUID(guid string, data model.TableAuthToken) (string, error) {
+func (hs *AuthtokenService) UpdateAuthtokenAgainstGUID(guid string, partid int, data model.TableAuthToken) (string, error) {
+	if err := hs.checkTenant(data.TenantGUID); err != nil {
+		return "", err
+	}
 	if err := hs.Session.Debug().Table("auth_token").
 		Where("guid = ?", guid).
+		Scopes(tokenScope(partid)).
 		Update(&data).Error; err != nil {
 		return "", err
 	}
+	/* Update skips an empty tenant */
+	if err := hs.Session.Debug().Table("auth_token").
+		Where("guid = ?", guid).
+		Scopes(tokenScope(partid)).
+		UpdateColumn("tenant_guid", data.TenantGUID).Error; err != nil {
+		return "", err
+	}
 	response := fmt.Sprintf("{\"message\":\"successfully updated auth token settings\",\"data\":\"%s\"}", guid)
 	return response, nil
 }
 
 // this method gets all users from database
-func (hs *AuthtokenService) DeleteAuthtokenAgainstGUID(guid string) (string, error) {
+func (hs *AuthtokenService) DeleteAuthtokenAgainstGUID(guid string, partid int) (string, error) {
 	var AuthtokenObject []model.TableAuthToken
 	if err := hs.Session.Debug().Table("auth_token").
 		Where("guid = ?", guid).
+		Scopes(tokenScope(partid)).
 		Delete(&AuthtokenObject).Error; err != nil {
 		logger.Debug(err.Error())
 		return "", err
@@ -92,3 +123,14 @@ func (hs *AuthtokenService) DeleteAuthtokenAgainstGUID(guid string) (string, err
 	response := fmt.Sprintf("{\"message\":\"successfully deleted authtoken\",\"data\":\"%s\"}", guid)
 	return response, nil
 }
+
+func (hs *AuthtokenService) checkTenant(guid string) error {
+	if guid == "" {
+		return nil
+	}
+	tenantService := TenantService{ServiceConfig: hs.ServiceConfig}
+	if _, err := tenantService.Get(guid); err != nil {
+		return fmt.Errorf("the tenant '%s' doesn't exist", guid)
+	}
+	return nil
+}
@@ -1,24 +1,117 @@
 package service
 
 import (
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/gabs/v2"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/config"
 	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/alias"
+	"github.com/sipcapture/homer-app/utils/logger"
 )
 
 type AliasService struct {
 	ServiceConfig
 }
 
+// the resolvers of the partitions shared by search, transaction and export, loaded
+// once and reloaded when the aliases of the partition change
+var aliases struct {
+	sync.Mutex
+	resolvers map[int]*alias.Resolver
+}
+
+// Resolver returns the shared alias resolver of a partition
+func (as *AliasService) Resolver(partid int) *alias.Resolver {
+
+	partid = model.Partition(partid)
+
+	aliases.Lock()
+	defer aliases.Unlock()
+
+	if aliases.resolvers == nil {
+		aliases.resolvers = map[int]*alias.Resolver{}
+	}
+	resolver, ok := aliases.resolvers[partid]
+	if !ok {
+		resolver = alias.NewResolver(config.Setting.MAIN_SETTINGS.UseCaptureIDInAlias)
+		if err := as.load(resolver, partid); err != nil {
+			logger.Error("aliases can't be loaded: ", err)
+		}
+		aliases.resolvers[partid] = resolver
+	}
+	return resolver
+}
+
+// Refresh reloads the active aliases of a partition into its shared resolver
+func (as *AliasService) Refresh(partid int) error {
+
+	resolver := as.Resolver(partid)
+
+	aliases.Lock()
+	defer aliases.Unlock()
+	return as.load(resolver, model.Partition(partid))
+}
+
+func (as *AliasService) load(resolver *alias.Resolver, partid int) error {
+
+	rows, err := as.GetAllActive(partid)
+	if err != nil {
+		return err
+	}
+	groups, err := as.GetAllGroups(partid)
+	if err != nil {
+		return err
+	}
+	paths := groupPaths(groups)
+
+	entries := make([]*alias.Entry, 0, len(rows))
+	for _, row := range rows {
+		mask := 32
+		if strings.Contains(row.IP, ":") {
+			mask = 128
+		}
+		if row.Mask != nil {
+			mask = *row.Mask
+		}
+		port, portEnd := 0, 0
+		if row.Port != nil {
+			port = *row.Port
+		}
+		if row.PortEnd != nil {
+			portEnd = *row.PortEnd
+		}
+		entry, err := alias.NewEntry(row.Alias, row.IP, mask, port, portEnd, row.CaptureID)
+		if err != nil {
+			logger.Error("skipping alias: ", err)
+			continue
+		}
+		path := paths[row.GroupGUID]
+		entry.Groups = path.names
+		entry.Tags = append(append([]string{}, row.Tags...), path.tags...)
+		entries = append(entries, entry)
+	}
+	resolver.Load(entries)
+	return nil
+}
+
+func (as *AliasService) refreshLogged(partid int) {
+	if err := as.Refresh(partid); err != nil {
+		logger.Error("aliases can't be reloaded: ", err)
+	}
+}
+
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (as *AliasService) GetAll() ([]model.TableAlias, error) {
+func (as *AliasService) GetAll(partid int) ([]model.TableAlias, error) {
 
 	alias := []model.TableAlias{}
 	if err := as.Session.Debug().
 		Table("alias").
+		Where("partid = ?", model.Partition(partid)).
 		Find(&alias).Error; err != nil {
 		return alias, err
 	}
@@ -27,12 +120,12 @@ func (as *AliasService) GetAll() ([]model.TableAlias, error) {
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (as *AliasService) GetAllActive() ([]model.TableAlias, error) {
+func (as *AliasService) GetAllActive(partid int) ([]model.TableAlias, error) {
 
 	alias := []model.TableAlias{}
 	if err := as.Session.Debug().
 		Table("alias").
-		Where("status = true").
+		Where("status = true AND partid = ?", model.Partition(partid)).
 		Find(&alias).Error; err != nil {
 		return alias, err
 	}
@@ -45,11 +138,13 @@ func (as *AliasService) Add(alias *model.TableAlias) (string, error) {
 	u1 := uuid.NewV4()
 	alias.GUID = u1.String()
 	alias.CreateDate = time.Now()
+	alias.PartId = model.Partition(alias.PartId)
 	if err := as.Session.Debug().
 		Table("alias").
 		Create(&alias).Error; err != nil {
 		return "", err
 	}
+	as.refreshLogged(alias.PartId)
 	reply := gabs.New()
 	reply.Set(u1.String(), "data")
 	reply.Set("successfully created alias", "message")
@@ -62,7 +157,7 @@ func (as *AliasService) Get(alias *model.TableAlias) (model.TableAlias, error) {
 	data := model.TableAlias{}
 	if err := as.Session.Debug().
 		Table("alias").
-		Where("guid = ?", alias.GUID).Find(&data).Error; err != nil {
+		Where("guid = ? AND partid = ?", alias.GUID, model.Partition(alias.PartId)).Find(&data).Error; err != nil {
 		return data, err
 	}
 	return data, nil
@@ -73,9 +168,10 @@ func (as *AliasService) Get(alias *model.TableAlias) (model.TableAlias, error) {
 func (as *AliasService) Delete(alias *model.TableAlias) error {
 	if err := as.Session.Debug().
 		Table("alias").
-		Where("guid = ?", alias.GUID).Delete(model.TableAlias{}).Error; err != nil {
+		Where("guid = ? AND partid = ?", alias.GUID, model.Partition(alias.PartId)).Delete(model.TableAlias{}).Error; err != nil {
 		return err
 	}
+	as.refreshLogged(alias.PartId)
 	return nil
 }
 
@@ -86,8 +182,16 @@ func (as *AliasService) Update(alias *model.TableAlias) error {
 		Table("alias").
 		Debug().
 		Model(&model.TableAlias{}).
-		Where("guid = ?", alias.GUID).Update(alias).Error; err != nil {
+		Where("guid = ? AND partid = ?", alias.GUID, model.Partition(alias.PartId)).Update(alias).Error; err != nil {
+		return err
+	}
+	/* an update of the struct skips empty values, the tags and group can be cleared */
+	if err := as.Session.Debug().
+		Table("alias").
+		Where("guid = ? AND partid = ?", alias.GUID, model.Partition(alias.PartId)).
+		UpdateColumns(map[string]interface{}{"tags": alias.Tags, "group_guid": alias.GroupGUID}).Error; err != nil {
 		return err
 	}
+	as.refreshLogged(alias.PartId)
 	return nil
 }
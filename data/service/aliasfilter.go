@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sipcapture/homer-app/utils/alias"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// aliasMatcher returns the match of the virtual alias.* fields, values are
+// separated by ';' and the case is ignored
+func aliasMatcher(field, value string) (func(e *alias.Entry) bool, bool) {
+
+	values := strings.Split(value, ";")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	anyOf := func(match func(e *alias.Entry, val string) bool) func(e *alias.Entry) bool {
+		return func(e *alias.Entry) bool {
+			for _, val := range values {
+				if match(e, val) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	switch field {
+	case "alias.tag":
+		return anyOf(func(e *alias.Entry, val string) bool { return e.HasTag(val) }), true
+	case "alias.group":
+		return anyOf(func(e *alias.Entry, val string) bool { return e.InGroup(val) }), true
+	case "alias.name":
+		return anyOf(func(e *alias.Entry, val string) bool { return strings.EqualFold(e.Alias, val) }), true
+	}
+	return nil, false
+}
+
+// aliasSide returns the condition of the network, ports and captureId of the alias
+// on one side of the message
+func aliasSide(e *alias.Entry, side string) (string, []interface{}) {
+
+	cond := fmt.Sprintf("NULLIF(protocol_header->>'%sIp', '')::inet <<= ?::inet", side)
+	values := []interface{}{e.Network.String()}
+	if e.PortFrom != 0 {
+		cond += fmt.Sprintf(" AND (protocol_header->>'%sPort')::int BETWEEN ? AND ?", side)
+		values = append(values, e.PortFrom, e.PortTo)
+	}
+	if e.CaptureID != "" {
+		cond += " AND protocol_header->>'captureId' = ?"
+		values = append(values, e.CaptureID)
+	}
+	return "(" + cond + ")", values
+}
+
+// aliasCondition expands the virtual alias.tag, alias.group and alias.name fields
+// into the networks and ports of the matching aliases of the resolver, either as
+// source or as destination of the message. As in the lookup of the names the most
+// specific alias wins, so the addresses of a more specific alias which does not
+// match are left out
+func aliasCondition(resolver *alias.Resolver, field, value string, negate bool) (string, []interface{}) {
+
+	noMatch := "FALSE"
+	if negate {
+		noMatch = "TRUE"
+	}
+
+	match, ok := aliasMatcher(field, value)
+	if !ok {
+		logger.Error("bad alias search field: ", field, ", value: ", value)
+		return noMatch, nil
+	}
+	if resolver == nil {
+		logger.Error("aliases are not loaded, no match for: ", field)
+		return noMatch, nil
+	}
+	entries := resolver.Select(match)
+	if len(entries) == 0 {
+		return noMatch, nil
+	}
+
+	conditions := make([]string, 0, len(entries)*2)
+	values := []interface{}{}
+	for _, e := range entries {
+		shadowing := []*alias.Entry{}
+		for _, o := range resolver.Shadowing(e) {
+			if !match(o) {
+				shadowing = append(shadowing, o)
+			}
+		}
+		for _, side := range []string{"src", "dst"} {
+			cond, condValues := aliasSide(e, side)
+			values = append(values, condValues...)
+			if len(shadowing) != 0 {
+				excluded := make([]string, 0, len(shadowing))
+				for _, o := range shadowing {
+					oCond, oValues := aliasSide(o, side)
+					excluded = append(excluded, oCond)
+					values = append(values, oValues...)
+				}
+				cond += " AND NOT COALESCE(" + strings.Join(excluded, " OR ") + ", FALSE)"
+			}
+			conditions = append(conditions, "("+cond+")")
+		}
+	}
+
+	sql := "(" + strings.Join(conditions, " OR ") + ")"
+	if negate {
+		sql = "NOT COALESCE(" + sql + ", FALSE)"
+	}
+	return sql, values
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// ErrAliasGroupLoop is returned when a group would become its own parent
+var ErrAliasGroupLoop = errors.New("the alias group would be below itself")
+
+/* the names and tags of a group and of the groups above it */
+type groupPath struct {
+	names []string
+	tags  []string
+}
+
+// groupPaths resolves the hierarchy, a group of a broken loop is cut at the loop
+func groupPaths(groups []model.TableAliasGroup) map[string]groupPath {
+
+	byGUID := make(map[string]model.TableAliasGroup, len(groups))
+	for _, g := range groups {
+		byGUID[g.GUID] = g
+	}
+
+	paths := make(map[string]groupPath, len(groups))
+	for _, g := range groups {
+		var chain []model.TableAliasGroup
+		seen := map[string]bool{}
+		for cur, ok := g, true; ok && !seen[cur.GUID]; cur, ok = byGUID[cur.ParentGUID] {
+			seen[cur.GUID] = true
+			chain = append(chain, cur)
+		}
+		path := groupPath{}
+		for i := len(chain) - 1; i >= 0; i-- {
+			path.names = append(path.names, chain[i].Name)
+			path.tags = append(path.tags, chain[i].Tags...)
+		}
+		paths[g.GUID] = path
+	}
+	return paths
+}
+
+// GetAllGroups returns the alias groups of a partition
+func (as *AliasService) GetAllGroups(partid int) ([]model.TableAliasGroup, error) {
+
+	groups := []model.TableAliasGroup{}
+	if err := as.Session.Debug().
+		Table("alias_group").
+		Where("partid = ?", model.Partition(partid)).
+		Order("name").
+		Find(&groups).Error; err != nil {
+		return groups, err
+	}
+	return groups, nil
+}
+
+// GetGroup returns the alias group of the guid in a partition
+func (as *AliasService) GetGroup(guid string, partid int) (model.TableAliasGroup, error) {
+
+	group := model.TableAliasGroup{}
+	err := as.Session.Debug().
+		Table("alias_group").
+		Where("guid = ? AND partid = ?", guid, model.Partition(partid)).
+		First(&group).Error
+	return group, err
+}
+
+// checkParent fails if the parent is missing or below the group
+func (as *AliasService) checkParent(guid, parentGUID string, partid int) error {
+
+	if parentGUID == "" {
+		return nil
+	}
+	groups, err := as.GetAllGroups(partid)
+	if err != nil {
+		return err
+	}
+	byGUID := make(map[string]model.TableAliasGroup, len(groups))
+	for _, g := range groups {
+		byGUID[g.GUID] = g
+	}
+	if _, ok := byGUID[parentGUID]; !ok {
+		return errors.New("the parent alias group doesn't exist")
+	}
+	seen := map[string]bool{}
+	for cur := parentGUID; cur != "" && !seen[cur]; cur = byGUID[cur].ParentGUID {
+		if cur == guid {
+			return ErrAliasGroupLoop
+		}
+		seen[cur] = true
+	}
+	return nil
+}
+
+// AddGroup creates an alias group
+func (as *AliasService) AddGroup(group *model.TableAliasGroup) (string, error) {
+
+	group.PartId = model.Partition(group.PartId)
+	if err := as.checkParent("", group.ParentGUID, group.PartId); err != nil {
+		return "", err
+	}
+	group.GUID = uuid.NewV4().String()
+	group.CreateDate = time.Now()
+	if err := as.Session.Debug().
+		Table("alias_group").
+		Create(group).Error; err != nil {
+		return "", err
+	}
+	as.refreshLogged(group.PartId)
+	reply := gabs.New()
+	reply.Set(group.GUID, "data")
+	reply.Set("successfully created alias group", "message")
+	return reply.String(), nil
+}
+
+// UpdateGroup changes the name, parent and tags of an alias group
+func (as *AliasService) UpdateGroup(group *model.TableAliasGroup) error {
+
+	if err := as.checkParent(group.GUID, group.ParentGUID, group.PartId); err != nil {
+		return err
+	}
+	if err := as.Session.Debug().
+		Table("alias_group").
+		Where("guid = ? AND partid = ?", group.GUID, model.Partition(group.PartId)).
+		Updates(map[string]interface{}{"name": group.Name, "parent_guid": group.ParentGUID, "tags": group.Tags}).Error; err != nil {
+		return err
+	}
+	as.refreshLogged(group.PartId)
+	return nil
+}
+
+// DeleteGroup removes an alias group, its groups and aliases move to its parent
+func (as *AliasService) DeleteGroup(group *model.TableAliasGroup) error {
+
+	tx := as.Session.Begin()
+	for _, table := range []struct{ name, column string }{{"alias_group", "parent_guid"}, {"alias", "group_guid"}} {
+		if err := tx.Table(table.name).
+			Where(table.column+" = ? AND partid = ?", group.GUID, model.Partition(group.PartId)).
+			Updates(map[string]interface{}{table.column: group.ParentGUID}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Table("alias_group").
+		Where("guid = ? AND partid = ?", group.GUID, model.Partition(group.PartId)).
+		Delete(model.TableAliasGroup{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	as.refreshLogged(group.PartId)
+	return nil
+}
+
+// GroupTree returns the top groups of a partition with their groups and aliases, and
+// the aliases without group
+func (as *AliasService) GroupTree(partid int) ([]*model.AliasGroupTree, []model.TableAlias, error) {
+
+	groups, err := as.GetAllGroups(partid)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := as.GetAll(partid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes := make(map[string]*model.AliasGroupTree, len(groups))
+	for _, g := range groups {
+		nodes[g.GUID] = &model.AliasGroupTree{TableAliasGroup: g, Groups: []*model.AliasGroupTree{}, Aliases: []model.TableAlias{}}
+	}
+	top := []*model.AliasGroupTree{}
+	for _, g := range groups {
+		if parent, ok := nodes[g.ParentGUID]; ok {
+			parent.Groups = append(parent.Groups, nodes[g.GUID])
+		} else {
+			top = append(top, nodes[g.GUID])
+		}
+	}
+
+	ungrouped := []model.TableAlias{}
+	for _, row := range rows {
+		if n, ok := nodes[row.GroupGUID]; ok {
+			n.Aliases = append(n.Aliases, row)
+		} else {
+			ungrouped = append(ungrouped, row)
+		}
+	}
+	return top, ungrouped, nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/aliasfile"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+func aliasToFile(row model.TableAlias) aliasfile.Alias {
+
+	a := aliasfile.Alias{Alias: row.Alias, IP: row.IP, CaptureID: row.CaptureID, Tags: row.Tags, Source: row.Source, GUID: row.GUID}
+	if row.Mask != nil {
+		a.Mask = *row.Mask
+	}
+	if row.Port != nil {
+		a.Port = *row.Port
+	}
+	if row.PortEnd != nil {
+		a.PortEnd = *row.PortEnd
+	}
+	if row.Status != nil {
+		a.Status = *row.Status
+	}
+	return a
+}
+
+func aliasFromFile(a aliasfile.Alias, partid int) model.TableAlias {
+
+	mask, port, portEnd, status := a.Mask, a.Port, a.PortEnd, a.Status
+	return model.TableAlias{
+		GUID:       uuid.NewV4().String(),
+		Alias:      a.Alias,
+		IP:         a.IP,
+		Mask:       &mask,
+		Port:       &port,
+		PortEnd:    &portEnd,
+		CaptureID:  a.CaptureID,
+		Status:     &status,
+		Tags:       a.Tags,
+		Source:     a.Source,
+		PartId:     partid,
+		CreateDate: time.Now(),
+	}
+}
+
+// Export returns the aliases of a partition as csv, json or yaml, all or the ones
+// of a source
+func (as *AliasService) Export(format string, source string, partid int) ([]byte, error) {
+
+	rows, err := as.GetAll(partid)
+	if err != nil {
+		return nil, err
+	}
+	aliases := []aliasfile.Alias{}
+	for _, row := range rows {
+		if source == "" || row.Source == source {
+			aliases = append(aliases, aliasToFile(row))
+		}
+	}
+	return aliasfile.Write(format, aliases)
+}
+
+// Import reads a list and applies it to the aliases of its source in a partition
+func (as *AliasService) Import(format string, data []byte, source string, partid int, dryRun bool) (*aliasfile.Diff, error) {
+
+	aliases, err := aliasfile.Parse(format, data)
+	if err != nil {
+		return nil, err
+	}
+	return as.ImportAliases(aliases, source, partid, dryRun)
+}
+
+// ImportAliases compares the aliases with the stored ones of the source in the
+// partition and, unless it is a dry run, applies the difference in one transaction.
+// Manual aliases and the ones of other sources or partitions are never changed.
+func (as *AliasService) ImportAliases(aliases []aliasfile.Alias, source string, partid int, dryRun bool) (*aliasfile.Diff, error) {
+
+	partid = model.Partition(partid)
+	rows, err := as.GetAll(partid)
+	if err != nil {
+		return nil, err
+	}
+	stored := make([]aliasfile.Alias, len(rows))
+	for i, row := range rows {
+		stored[i] = aliasToFile(row)
+	}
+
+	diff, err := aliasfile.Compare(stored, aliases, source)
+	if err != nil || dryRun || diff.Empty() {
+		return diff, err
+	}
+
+	tx := as.Session.Begin()
+	for _, a := range diff.Added {
+		row := aliasFromFile(a, partid)
+		if err := tx.Table("alias").Create(&row).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	for _, c := range diff.Changed {
+		if err := tx.Table("alias").
+			Where("guid = ? AND source = ? AND partid = ?", c.To.GUID, source, partid).
+			Updates(map[string]interface{}{"alias": c.To.Alias, "status": c.To.Status, "tags": pq.StringArray(c.To.Tags)}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	for _, a := range diff.Removed {
+		if err := tx.Table("alias").
+			Where("guid = ? AND source = ? AND partid = ?", a.GUID, source, partid).
+			Delete(model.TableAlias{}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	as.refreshLogged(partid)
+	return diff, nil
+}
+
+// AliasSyncService loads the aliases of an inventory file or URL on a schedule into
+// the default partition
+type AliasSyncService struct {
+	AliasService
+	HttpClient *http.Client
+}
+
+// NewAliasSyncService returns the sync of the configured file or URL
+func NewAliasSyncService(session *gorm.DB) *AliasSyncService {
+	return &AliasSyncService{
+		AliasService: AliasService{ServiceConfig: ServiceConfig{Session: session}},
+		HttpClient:   &http.Client{Timeout: time.Duration(config.Setting.ALIAS_SYNC_SETTINGS.Timeout) * time.Second},
+	}
+}
+
+// Enabled is true if the sync is on and a file or URL is configured
+func (ss *AliasSyncService) Enabled() bool {
+	settings := config.Setting.ALIAS_SYNC_SETTINGS
+	return settings.Enable && (settings.Path != "" || settings.URL != "")
+}
+
+// Sync loads the configured file or URL and applies it
+func (ss *AliasSyncService) Sync(dryRun bool) (*aliasfile.Diff, error) {
+
+	settings := config.Setting.ALIAS_SYNC_SETTINGS
+
+	var data []byte
+	var err error
+	name := settings.Path
+	switch {
+	case settings.Path != "":
+		data, err = ioutil.ReadFile(settings.Path)
+	case settings.URL != "":
+		data, err = ss.download(settings.URL)
+		if u, parseErr := url.Parse(settings.URL); parseErr == nil {
+			name = path.Base(u.Path)
+		}
+	default:
+		return nil, errors.New("no alias sync file or url")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	format := settings.Format
+	if format == "" {
+		format = aliasfile.DetectFormat(name)
+	}
+	aliases, err := aliasfile.Parse(format, data)
+	if err != nil {
+		return nil, err
+	}
+	/* a broken inventory must not remove every alias */
+	if len(aliases) == 0 {
+		return nil, fmt.Errorf("no aliases in %s", name)
+	}
+	return ss.ImportAliases(aliases, settings.Source, model.DefaultPartition, dryRun)
+}
+
+func (ss *AliasSyncService) download(link string) ([]byte, error) {
+
+	resp, err := ss.HttpClient.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", link, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Run syncs now and then every interval until stop is closed
+func (ss *AliasSyncService) Run(interval time.Duration, stop <-chan struct{}) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		diff, err := ss.Sync(false)
+		if err != nil {
+			logger.Error("alias sync failed: ", err)
+		} else {
+			logger.Info(fmt.Sprintf("alias sync: %d added, %d changed, %d removed, %d conflicts",
+				len(diff.Added), len(diff.Changed), len(diff.Removed), len(diff.Conflicts)))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -18,11 +18,11 @@ type DashBoardService struct {
 }
 
 // this method gets all users from database
-func (us *DashBoardService) GetDashBoardsLists(username string) (string, error) {
+func (us *DashBoardService) GetDashBoardsLists(username string, partid int) (string, error) {
 	var userSettings []*model.TableUserSettings
 
 	var count int
-	if err := us.Session.Debug().Table("user_settings").Where("category = 'dashboard' AND (username = ? and param = ?)", username, "home").
+	if err := us.Session.Debug().Table("user_settings").Where("category = 'dashboard' AND (username = ? and param = ?) AND partid = ?", username, "home", model.Partition(partid)).
 		Find(&userSettings).Count(&count).Error; err != nil {
 		logger.Error("bad selection for  dashboard for user: ", username)
 		return "", err
@@ -33,7 +33,7 @@ func (us *DashBoardService) GetDashBoardsLists(username string) (string, error)
 		return "", fmt.Errorf("no home dashboard here")
 	}
 
-	if err := us.Session.Debug().Table("user_settings").Where("category = 'dashboard' AND (username = ? OR (data ->> 'shared' = 'true' OR data ->> 'shared' = '1'))", username).
+	if err := us.Session.Debug().Table("user_settings").Where("category = 'dashboard' AND (username = ? OR (data ->> 'shared' = 'true' OR data ->> 'shared' = '1')) AND partid = ?", username, model.Partition(partid)).
 		Find(&userSettings).Error; err != nil {
 		return "", err
 	}
@@ -105,9 +105,9 @@ func (us *DashBoardService) GetDashBoardsLists(username string) (string, error)
 }
 
 // this method gets all users from database
-func (us *DashBoardService) GetDashBoard(username, param string) (string, error) {
+func (us *DashBoardService) GetDashBoard(username, param string, partid int) (string, error) {
 	var userSettings model.TableUserSettings
-	if err := us.Session.Table("user_settings").Where("(username = ? OR (data ->> 'shared' = 'true' OR data ->> 'shared' = '1')) AND category = 'dashboard' and param = ? ", username, param).
+	if err := us.Session.Table("user_settings").Where("(username = ? OR (data ->> 'shared' = 'true' OR data ->> 'shared' = '1')) AND category = 'dashboard' and param = ? and partid = ?", username, param, model.Partition(partid)).
 		Find(&userSettings).Error; err != nil {
 		return "", err
 	}
@@ -125,13 +125,13 @@ func (us *DashBoardService) GetDashBoard(username, param string) (string, error)
 }
 
 // this method gets all users from database
-func (us *DashBoardService) InsertDashboard(username, dashboardId string, data json.RawMessage) (string, error) {
+func (us *DashBoardService) InsertDashboard(username, dashboardId string, partid int, data json.RawMessage) (string, error) {
 
 	newDashboard := model.TableUserSettings{}
 	u2 := uuid.NewV4()
 	newDashboard.GUID = u2.String()
 	newDashboard.Param = dashboardId
-	newDashboard.PartId = 10
+	newDashboard.PartId = model.Partition(partid)
 	newDashboard.UserName = username
 	newDashboard.Category = "dashboard"
 	newDashboard.CreateDate = time.Now()
@@ -167,13 +167,13 @@ func (us *DashBoardService) InsertDashboard(username, dashboardId string, data j
 }
 
 // this method gets all users from database
-func (us *DashBoardService) InsertDashboardByName(username string, dashboardName string, data json.RawMessage) (string, error) {
+func (us *DashBoardService) InsertDashboardByName(username string, dashboardName string, partid int, data json.RawMessage) (string, error) {
 
 	newDashboard := model.TableUserSettings{}
 	u2 := uuid.NewV4()
 	newDashboard.GUID = u2.String()
 	newDashboard.Param = dashboardName
-	newDashboard.PartId = 10
+	newDashboard.PartId = model.Partition(partid)
 	newDashboard.UserName = username
 	newDashboard.Category = "dashboard"
 	newDashboard.CreateDate = time.Now()
@@ -191,13 +191,13 @@ func (us *DashBoardService) InsertDashboardByName(username string, dashboardName
 }
 
 // this method gets all users from database
-func (us *DashBoardService) UpdateDashboard(username, dashboardId string, data json.RawMessage) (string, error) {
+func (us *DashBoardService) UpdateDashboard(username, dashboardId string, partid int, data json.RawMessage) (string, error) {
 
 	newDashboard := model.TableUserSettings{}
 	u2 := uuid.NewV4()
 	newDashboard.GUID = u2.String()
 	newDashboard.Param = dashboardId
-	newDashboard.PartId = 10
+	newDashboard.PartId = model.Partition(partid)
 	newDashboard.UserName = username
 	newDashboard.Category = "dashboard"
 	newDashboard.CreateDate = time.Now()
@@ -226,8 +226,8 @@ func (us *DashBoardService) UpdateDashboard(username, dashboardId string, data j
 }
 
 // this method gets all users from database
-func (us *DashBoardService) DeleteDashboard(username, dashboardId string) (string, error) {
-	if err := us.Session.Debug().Table("user_settings").Where("username = ? AND category = 'dashboard' and param = ? and partid = ? ", username, dashboardId, 10).
+func (us *DashBoardService) DeleteDashboard(username, dashboardId string, partid int) (string, error) {
+	if err := us.Session.Debug().Table("user_settings").Where("username = ? AND category = 'dashboard' and param = ? and partid = ? ", username, dashboardId, model.Partition(partid)).
 		Delete(&model.TableUserSettings{}).Error; err != nil {
 		return "", err
 	}
@@ -239,9 +239,9 @@ func (us *DashBoardService) DeleteDashboard(username, dashboardId string) (strin
 }
 
 // this method gets all users from database
-func (us *DashBoardService) DeleteAllDashboards(username string) (string, error) {
+func (us *DashBoardService) DeleteAllDashboards(username string, partid int) (string, error) {
 
-	if err := us.Session.Debug().Table("user_settings").Where("username = ? AND category = 'dashboard' and partid = ? ", username, 10).Delete(&model.TableUserSettings{}).Error; err != nil {
+	if err := us.Session.Debug().Table("user_settings").Where("username = ? AND category = 'dashboard' and partid = ? ", username, model.Partition(partid)).Delete(&model.TableUserSettings{}).Error; err != nil {
 		return "", err
 	}
 
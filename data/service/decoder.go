@@ -0,0 +1,43 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/decoder"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// external decoder
+type ExternalDecoder struct {
+	Binary    string   `json:"binary"`
+	Param     string   `json:"param"`
+	Protocols []string `json:"protocols"`
+	UID       uint32   `json:"uid"`
+	GID       uint32   `json:"gid"`
+	Active    bool     `json:"active"`
+	Workers   int      `json:"workers"`
+	Timeout   int      `json:"timeout"`
+	CacheSize int      `json:"cache_size"`
+}
+
+// NewDecoders returns the registry with the native decoders and the external
+// decoder as fallback for its protocols
+func NewDecoders(ext ExternalDecoder) *decoder.Registry {
+
+	registry := decoder.NewRegistry()
+	decoder.RegisterNative(registry)
+
+	if ext.Active && ext.Binary != "" {
+		args := append([]string{"-Q", "-T", "json", "-l", "-i", "-"}, strings.Fields(ext.Param)...)
+		shark := decoder.NewExternal("tshark", ext.Binary, args, ext.Workers, time.Duration(ext.Timeout)*time.Second, ext.CacheSize)
+		shark.UID = ext.UID
+		shark.GID = ext.GID
+		registry.Register(shark, ext.Protocols...)
+		if err := shark.Check(); err != nil {
+			logger.Error("external decoder can't be used: ", err)
+		}
+	}
+
+	return registry
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/alias"
+	"github.com/sipcapture/homer-app/utils/geoip"
+	"github.com/sipcapture/homer-app/utils/heputils"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+// ErrGeoIPDisabled is returned by the aggregate when GeoIP is not configured
+var ErrGeoIPDisabled = errors.New("geoip is not enabled")
+
+var geoDB struct {
+	once sync.Once
+	db   *geoip.DB
+}
+
+// GeoIP returns the configured databases, nil if GeoIP is disabled
+func GeoIP() *geoip.DB {
+
+	settings := config.Setting.GEOIP_SETTINGS
+	if !settings.Enable || (settings.LocationDB == "" && settings.ASNDB == "") {
+		return nil
+	}
+	geoDB.once.Do(func() {
+		geoip.CheckInterval = time.Duration(settings.ReloadCheck) * time.Second
+		geoDB.db = geoip.NewDB(settings.LocationDB, settings.ASNDB)
+	})
+	return geoDB.db
+}
+
+// geoLookup returns the location and ASN of an address, nil if unknown or disabled
+func geoLookup(ip string) *model.GeoInfo {
+
+	geo := GeoIP().Lookup(ip)
+	if geo == nil {
+		return nil
+	}
+	info := model.GeoInfo(*geo)
+	return &info
+}
+
+/* the messages of a pair of addresses of a captureId */
+type geoAddress struct {
+	CaptureID string
+	SrcIP     string
+	DstIP     string
+	Count     int64
+}
+
+// GeoAggregate counts the messages of a search per country or per ASN ("asn") of
+// the source, destination or both ("both") addresses. The virtual stir.* and lint.*
+// fields are not applied, they need the decoded messages. The rows are counted by the
+// database, the filter of the tenant is checked again on the counted addresses.
+func (ss *SearchService) GeoAggregate(searchObject *model.SearchObject, aliases *alias.Resolver, scope *tenant.Filter,
+	mapsFieldsData map[string]json.RawMessage, by string, direction string) ([]model.GeoCount, error) {
+
+	db := GeoIP()
+	if db == nil {
+		return nil, ErrGeoIPDisabled
+	}
+
+	searchFromTime := time.Unix(searchObject.Timestamp.From/int64(time.Microsecond), 0)
+	searchToTime := time.Unix(searchObject.Timestamp.To/int64(time.Microsecond), 0)
+	table, sqlWhere, dataArrayExtraValues, _ := searchQuery(searchObject, aliases, mapsFieldsData)
+	sql := "create_date between ? AND ?" + sqlWhere
+	dataArrayValues := append([]interface{}{searchFromTime, searchToTime}, dataArrayExtraValues...)
+
+	sides := []string{"srcIp"}
+	switch direction {
+	case "dst":
+		sides = []string{"dstIp"}
+	case "both":
+		sides = []string{"srcIp", "dstIp"}
+	}
+
+	/* the addresses are counted by the database, only distinct ones are looked up */
+	counts := map[string]int64{}
+	for session := range ss.Session {
+		if !heputils.ElementExists(searchObject.Param.Location.Node, session) ||
+			!scope.AllowsNode(session) || !scope.AllowsTable(table) {
+			continue
+		}
+		rows := []geoAddress{}
+		if err := ss.Session[session].Debug().
+			Table(table).
+			Select("protocol_header->>'captureId' AS capture_id, protocol_header->>'srcIp' AS src_ip, "+
+				"protocol_header->>'dstIp' AS dst_ip, count(*) AS count").
+			Where(sql, dataArrayValues...).
+			Scopes(tenantScope(scope)).
+			Group("capture_id, src_ip, dst_ip").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		geoCounts(counts, rows, sides, scope, session, table)
+	}
+
+	groups := map[model.GeoCount]*model.GeoCount{}
+	for ip, count := range counts {
+		key := model.GeoCount{}
+		if geo := db.Lookup(ip); geo != nil {
+			if by == "asn" {
+				key.ASN, key.Org = geo.ASN, geo.Org
+			} else {
+				key.Country = geo.Country
+			}
+		}
+		if groups[key] == nil {
+			group := key
+			groups[key] = &group
+		}
+		groups[key].Count += count
+		groups[key].Addresses++
+	}
+
+	reply := make([]model.GeoCount, 0, len(groups))
+	for _, group := range groups {
+		reply = append(reply, *group)
+	}
+	sort.Slice(reply, func(i, j int) bool {
+		if reply[i].Count != reply[j].Count {
+			return reply[i].Count > reply[j].Count
+		}
+		return reply[i].Country+reply[i].Org < reply[j].Country+reply[j].Org
+	})
+	return reply, nil
+}
+
+// geoCounts adds the messages of the rows to the addresses of the sides, the rows the
+// tenant can't see are dropped like the ones of the searches
+func geoCounts(counts map[string]int64, rows []geoAddress, sides []string, scope *tenant.Filter, node, table string) {
+
+	for _, row := range rows {
+		/* the protocol_header the filter checks, a captureId is a number */
+		captureID := "null"
+		if row.CaptureID != "" {
+			captureID = strconv.Quote(row.CaptureID)
+			if _, err := strconv.ParseFloat(row.CaptureID, 64); err == nil {
+				captureID = row.CaptureID
+			}
+		}
+		header := fmt.Sprintf(`{"captureId":%s,"srcIp":%s,"dstIp":%s}`,
+			captureID, strconv.Quote(row.SrcIP), strconv.Quote(row.DstIP))
+		if !scope.Allows(node, table, json.RawMessage(header)) {
+			continue
+		}
+		for _, side := range sides {
+			if side == "dstIp" {
+				counts[row.DstIP] += row.Count
+			} else {
+				counts[row.SrcIP] += row.Count
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+func TestGeoCountsTenant(t *testing.T) {
+
+	filter, err := tenant.New("a", []string{"2001"}, []string{"localnode"}, nil, []string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatalf("[TestGeoCountsTenant] unexpected error: %v", err)
+	}
+
+	rows := []geoAddress{
+		{CaptureID: "2001", SrcIP: "10.1.0.5", DstIP: "192.0.2.1", Count: 3},
+		/* another captureId */
+		{CaptureID: "2002", SrcIP: "10.1.0.5", DstIP: "192.0.2.1", Count: 5},
+		/* another network */
+		{CaptureID: "2001", SrcIP: "192.0.2.9", DstIP: "198.51.100.1", Count: 7},
+		/* no captureId */
+		{CaptureID: "", SrcIP: "10.1.0.6", DstIP: "192.0.2.1", Count: 2},
+		{CaptureID: "hep", SrcIP: "10.1.0.6", DstIP: "192.0.2.1", Count: 4},
+	}
+
+	counts := map[string]int64{}
+	geoCounts(counts, rows, []string{"srcIp", "dstIp"}, filter, "localnode", "hep_proto_1_call")
+	expected := map[string]int64{"10.1.0.5": 3, "192.0.2.1": 3}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("[TestGeoCountsTenant] got %v, expected %v", counts, expected)
+	}
+
+	/* the rows of another node are never counted */
+	counts = map[string]int64{}
+	geoCounts(counts, rows, []string{"srcIp"}, filter, "othernode", "hep_proto_1_call")
+	if len(counts) != 0 {
+		t.Errorf("[TestGeoCountsTenant] another node got %v", counts)
+	}
+
+	/* without a tenant everything is counted */
+	counts = map[string]int64{}
+	geoCounts(counts, rows, []string{"dstIp"}, nil, "othernode", "hep_proto_1_call")
+	expected = map[string]int64{"192.0.2.1": 14, "198.51.100.1": 7}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("[TestGeoCountsTenant] no tenant got %v, expected %v", counts, expected)
+	}
+
+	/* a tenant which can't be found sees nothing */
+	counts = map[string]int64{}
+	geoCounts(counts, rows, []string{"srcIp"}, tenant.Deny("b"), "localnode", "hep_proto_1_call")
+	if len(counts) != 0 {
+		t.Errorf("[TestGeoCountsTenant] denied got %v", counts)
+	}
+}
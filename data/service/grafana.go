@@ -45,7 +45,7 @@ func (ps *GrafanaService) GrafanaURL() (string, error) {
 		var userGlobalSettings = model.TableGlobalSettings{}
 
 		if err := ps.Session.Debug().Table("global_settings").
-			Where("param = ?", "grafana").
+			Where("param = ? AND partid = ?", "grafana", model.DefaultPartition).
 			Find(&userGlobalSettings).Error; err != nil {
 			return "", err
 		}
@@ -84,7 +84,7 @@ func (ps *GrafanaService) SetGrafanaObject() error {
 		var userGlobalSettings = model.TableGlobalSettings{}
 
 		if err := ps.Session.Debug().Table("global_settings").
-			Where("param = ?", "grafana").
+			Where("param = ? AND partid = ?", "grafana", model.DefaultPartition).
 			Find(&userGlobalSettings).Error; err != nil {
 			return err
 		}
@@ -218,7 +218,7 @@ func (ps *GrafanaService) GrafanaFolders() (string, error) {
 		reply.Set("1048", "errorcode")
 		reply.Set(webmessages.GrafanaProcessingError+fmt.Sprintf(" httpcode: %d", data.StatusCode), "message")
 		reply.Set(sData.Data(), "data")
-		err = fmt.Errorf("receive bad response from grafana: ", data.StatusCode)
+		err = fmt.Errorf("receive bad response from grafana: %d", data.StatusCode)
 		logger.Error("error: ", err.Error())
 		return reply.String(), err
 	}
@@ -273,7 +273,7 @@ func (ps *GrafanaService) GrafanaGetDashboardByUUUID(uuid string) (string, error
 		reply.Set("1048", "errorcode")
 		reply.Set(webmessages.GrafanaProcessingError+fmt.Sprintf(" httpcode: %d", data.StatusCode), "message")
 		reply.Set(sData.Data(), "data")
-		err = fmt.Errorf("receive bad response from grafana: ", data.StatusCode)
+		err = fmt.Errorf("receive bad response from grafana: %d", data.StatusCode)
 		logger.Error("error: ", err.Error())
 		return reply.String(), err
 	}
@@ -328,7 +328,7 @@ func (ps *GrafanaService) GrafanaGetFoldersdByUUUID(uuid string) (string, error)
 		reply.Set("1048", "errorcode")
 		reply.Set(webmessages.GrafanaProcessingError+fmt.Sprintf(" httpcode: %d", data.StatusCode), "message")
 		reply.Set(sData.Data(), "data")
-		err = fmt.Errorf("receive bad response from grafana: ", data.StatusCode)
+		err = fmt.Errorf("receive bad response from grafana: %d", data.StatusCode)
 		logger.Error("error: ", err.Error())
 		return reply.String(), err
 	}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/hepcollector"
+	"github.com/sipcapture/homer-app/utils/importreader"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// the collector reloads the mappings it checks the records with
+const collectorMappingsAge = time.Minute
+
+// HepCollectorService writes the packets of the HEP collector into the data tables
+type HepCollectorService struct {
+	ServiceData
+	ConfigSession *gorm.DB
+	Collector     *hepcollector.Collector
+	Node          string
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// Run reads the queue of the collector until it stops. A batch is written when it is full
+// or when the flush interval has passed.
+func (hs *HepCollectorService) Run() error {
+
+	session, node, err := selectDataSession(hs.Session, hs.Node)
+	if err != nil {
+		return err
+	}
+
+	batchSize := hs.BatchSize
+	if batchSize <= 0 || batchSize > maxImportBatchSize {
+		batchSize = maxImportBatchSize
+	}
+
+	flushInterval := hs.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	options := importreader.Options{
+		CaptureID:       config.Setting.IMPORT_SETTINGS.CaptureID,
+		FallbackProfile: config.Setting.IMPORT_SETTINGS.FallbackProfile,
+	}
+
+	logger.Info(fmt.Sprintf("HEP collector writes into node [%s]", node))
+
+	/* the collector has no user, its records are checked with the default partition */
+	mappings := newRecordMappings(hs.ConfigSession, model.DefaultPartition)
+	mappingsDate := time.Now()
+
+	/* the collector maps any payload type to <type>_default, it needs a mapping for its table */
+	build := func(packet *importreader.Packet) (*importreader.Record, error) {
+		record, err := importreader.BuildRecord(packet, options)
+		if err == nil {
+			err = mappings.validate(record)
+		}
+		return record, err
+	}
+
+	batch := make([]*importreader.Record, 0, batchSize)
+	failed := 0
+
+	flush := func() {
+		if len(batch) == 0 && failed == 0 {
+			return
+		}
+		inserted := len(batch)
+		if len(batch) > 0 {
+			_, rejected, err := writeRecords(session, batch)
+			if err != nil {
+				logger.Error(fmt.Sprintf("HEP collector couldn't write [%d] rows: %s", len(rejected), err.Error()))
+			}
+			failed += len(rejected)
+			inserted -= len(rejected)
+		}
+		hs.Collector.Inserted(inserted, failed)
+		batch = batch[:0]
+		failed = 0
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case packet := <-hs.Collector.Packets():
+			record, err := build(packet)
+			if err != nil {
+				logger.Debug("HEP collector couldn't build the row: ", err.Error())
+				failed++
+				continue
+			}
+			batch = append(batch, record)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			if time.Since(mappingsDate) > collectorMappingsAge {
+				mappings = newRecordMappings(hs.ConfigSession, model.DefaultPartition)
+				mappingsDate = time.Now()
+			}
+		case <-hs.Collector.Done():
+			/* write what is still queued */
+			for {
+				select {
+				case packet := <-hs.Collector.Packets():
+					if record, err := build(packet); err == nil {
+						batch = append(batch, record)
+					} else {
+						failed++
+					}
+					if len(batch) >= batchSize {
+						flush()
+					}
+					continue
+				default:
+				}
+				break
+			}
+			flush()
+			return nil
+		}
+	}
+}
+
+// Stats returns the counters of the collector
+func (hs *HepCollectorService) Stats() hepcollector.Stats {
+	return hs.Collector.Stats()
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 
 	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/tenant"
 )
 
 type HepsubsearchService struct {
@@ -29,8 +30,12 @@ func (hss *HepsubsearchService) GetAgentsub() (string, error) {
 	return response, nil
 }
 
-// this method gets all users from database
-func (hss *HepsubsearchService) DoHepSubSearch(data model.SearchObject) (string, error) {
+// DoHepSubSearch answers a HEPSUB search, the profiles of the search must be the
+// ones of the tenant
+func (hss *HepsubsearchService) DoHepSubSearch(data model.SearchObject, scope *tenant.Filter) (string, error) {
+	if err := tenantTables(scope, data.Param.Search); err != nil {
+		return "", err
+	}
 	response := fmt.Sprintf("{\"message\":\"successfully created DoHepSubSearch\",\"data\":\"empty\"}")
 	return response, nil
 }
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+func TestHepsubSearchTenant(t *testing.T) {
+
+	filter, err := tenant.New("a", []string{"2001"}, nil, []string{"1_call"}, nil)
+	if err != nil {
+		t.Fatalf("[TestHepsubSearchTenant] unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		scope  *tenant.Filter
+		search string
+		err    error
+	}{
+		{filter, `{"1_call":[]}`, nil},
+		{filter, `{"1_registration":[]}`, ErrTenantHidden},
+		{nil, `{"1_registration":[]}`, nil},
+		{tenant.Deny("b"), `{"1_call":[]}`, ErrTenantHidden},
+	}
+	hss := HepsubsearchService{}
+	for _, test := range tests {
+		searchObject := model.SearchObject{}
+		searchObject.Param.Search = json.RawMessage(test.search)
+		if _, err := hss.DoHepSubSearch(searchObject, test.scope); err != test.err {
+			t.Errorf("[TestHepsubSearchTenant] %s: got %v, expected %v", test.search, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/importreader"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// selectDataSession returns the data node records are written to: the requested one,
+// the configured import node or the first one
+func selectDataSession(sessions map[string]*gorm.DB, node string) (*gorm.DB, string, error) {
+
+	if len(sessions) == 0 {
+		return nil, "", fmt.Errorf("no data node has been configured")
+	}
+
+	if node != "" {
+		if val, ok := sessions[node]; ok {
+			return val, node, nil
+		}
+		return nil, "", fmt.Errorf("data node doesn't exist: %s", node)
+	}
+
+	if node = config.Setting.IMPORT_SETTINGS.Node; node != "" {
+		if val, ok := sessions[node]; ok {
+			return val, node, nil
+		}
+		logger.Error(fmt.Sprintf("import node [%s] doesn't exist, using the first one", node))
+	}
+
+	keys := reflect.ValueOf(sessions).MapKeys()
+	node = keys[0].String()
+	return sessions[node], node, nil
+}
+
+// writeRecords inserts the records in one transaction, one statement per table. A table
+// whose statement fails is rolled back to its savepoint, the rows of the other tables are
+// kept. It returns the tables which have received rows and the records which haven't
+// been written with the error of their tables.
+func writeRecords(session *gorm.DB, records []*importreader.Record) ([]string, []*importreader.Record, error) {
+
+	tables := map[string][]*importreader.Record{}
+	for _, record := range records {
+		tables[record.TableName()] = append(tables[record.TableName()], record)
+	}
+
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+
+	tx := session.Begin()
+	if tx.Error != nil {
+		return nil, records, tx.Error
+	}
+
+	written := make([]string, 0, len(names))
+	failed := []*importreader.Record{}
+	errs := []string{}
+
+	for _, table := range names {
+
+		values := make([]string, 0, len(tables[table]))
+		args := make([]interface{}, 0, len(tables[table])*5)
+
+		for _, record := range tables[table] {
+			values = append(values, "(?, ?, ?, ?, ?)")
+			args = append(args, record.Sid, record.CreateDate, string(record.ProtocolHeader), string(record.DataHeader), record.Raw)
+		}
+
+		if err := tx.Exec("SAVEPOINT hep_table").Error; err != nil {
+			tx.Rollback()
+			return nil, records, err
+		}
+
+		sql := "INSERT INTO " + table + " (sid, create_date, protocol_header, data_header, raw) VALUES " + strings.Join(values, ", ")
+		if err := tx.Exec(sql, args...).Error; err != nil {
+			logger.Error(fmt.Sprintf("Save failed for table [%s]: with error %s.", table, err.Error()))
+			if err := tx.Exec("ROLLBACK TO SAVEPOINT hep_table").Error; err != nil {
+				tx.Rollback()
+				return nil, records, err
+			}
+			failed = append(failed, tables[table]...)
+			errs = append(errs, fmt.Sprintf("table %s: %s", table, err.Error()))
+			continue
+		}
+		written = append(written, table)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, records, err
+	}
+
+	if len(errs) > 0 {
+		return written, failed, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return written, failed, nil
+}
+
+type mappingField struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// recordMappings checks records against the mappings of their profile in a partition, the
+// mapping of each profile is loaded once
+type recordMappings struct {
+	session  *gorm.DB
+	partid   int
+	mappings map[string][]mappingField
+}
+
+func newRecordMappings(session *gorm.DB, partid int) *recordMappings {
+	return &recordMappings{session: session, partid: partid, mappings: map[string][]mappingField{}}
+}
+
+// validate checks the types of the fields the mapping of the profile knows. A profile
+// without mapping, like an unknown HEP payload type, has no table.
+func (rm *recordMappings) validate(record *importreader.Record) error {
+
+	fields, ok := rm.mappings[record.Profile]
+	if !ok {
+		var err error
+		if fields, err = rm.load(record.Profile); err != nil {
+			return err
+		}
+		rm.mappings[record.Profile] = fields
+	}
+
+	if fields == nil {
+		return fmt.Errorf("no mapping for profile %s", record.Profile)
+	}
+
+	protocolHeader := map[string]interface{}{}
+	dataHeader := map[string]interface{}{}
+	json.Unmarshal(record.ProtocolHeader, &protocolHeader)
+	json.Unmarshal(record.DataHeader, &dataHeader)
+
+	headers := map[string]map[string]interface{}{
+		"protocol_header": protocolHeader,
+		"data_header":     dataHeader,
+	}
+
+	for _, field := range fields {
+		parts := strings.SplitN(field.ID, ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		header, ok := headers[parts[0]]
+		if !ok {
+			continue
+		}
+
+		val, ok := header[parts[1]]
+		if !ok || val == nil {
+			continue
+		}
+
+		switch field.Type {
+		case "string":
+			if _, ok := val.(string); !ok {
+				return fmt.Errorf("%s has to be a string", field.ID)
+			}
+		case "integer", "int", "number":
+			if _, ok := val.(float64); !ok {
+				return fmt.Errorf("%s has to be a number", field.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// load returns the fields of the profile, of the partition or else of the default
+// partition, nil if there is no mapping
+func (rm *recordMappings) load(profile string) ([]mappingField, error) {
+
+	parts := strings.SplitN(profile, "_", 2)
+	if len(parts) != 2 {
+		return nil, nil
+	}
+
+	hepid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, nil
+	}
+
+	var mapping []model.TableMappingSchema
+	if err := rm.session.Table("mapping_schema").Scopes(partitionScope(rm.partid)).
+		Where("hepid = ? AND profile = ?", hepid, parts[1]).
+		Find(&mapping).Error; err != nil {
+		return nil, err
+	}
+
+	if len(mapping) == 0 {
+		return nil, nil
+	}
+
+	fields := []mappingField{}
+	if err := json.Unmarshal(mapping[0].FieldsMapping, &fields); err != nil {
+		logger.Error(fmt.Sprintf("bad fields_mapping of profile [%s]: %s", profile, err.Error()))
+	}
+
+	return fields, nil
+}
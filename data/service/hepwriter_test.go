@@ -0,0 +1,34 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sipcapture/homer-app/utils/importreader"
+)
+
+func TestRecordMappingsValidate(t *testing.T) {
+
+	/* the mappings are preloaded, no config DB is needed */
+	mappings := newRecordMappings(nil, 10)
+	mappings.mappings["1_call"] = []mappingField{{ID: "protocol_header.captureId", Type: "integer"},
+		{ID: "data_header.callid", Type: "string"}}
+	mappings.mappings["99_default"] = nil
+
+	tests := []struct {
+		profile string
+		header  string
+		valid   bool
+	}{
+		{"1_call", `{"captureId":2001}`, true},
+		{"1_call", `{"captureId":"2001"}`, false},
+		{"99_default", `{"captureId":2001}`, false},
+	}
+	for _, test := range tests {
+		record := &importreader.Record{Profile: test.profile, ProtocolHeader: json.RawMessage(test.header),
+			DataHeader: json.RawMessage(`{"callid":"a84b4c76e66710"}`)}
+		if err := mappings.validate(record); (err == nil) != test.valid {
+			t.Errorf("[TestRecordMappingsValidate] %s %s: got %v, expected valid %v", test.profile, test.header, err, test.valid)
+		}
+	}
+}
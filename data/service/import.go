@@ -0,0 +1,491 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/importreader"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+const (
+	// errors kept per job, the rest is only counted
+	maxImportJobErrors = 100
+	// postgres accepts at most 65535 bind parameters per statement, we use 5 per row
+	maxImportBatchSize = 10000
+)
+
+// ImportService runs pcap and text log imports in the background
+type ImportService struct {
+	ServiceData
+}
+
+type importJob struct {
+	sync.Mutex
+	job    model.ImportJob
+	cancel context.CancelFunc
+	done   chan struct{}
+	/* the filter of the tenant of the owner, its records are checked */
+	scope *tenant.Filter
+}
+
+// jobs are shared by all controllers and survive until they are pruned
+var importJobs = struct {
+	sync.RWMutex
+	jobs map[string]*importJob
+}{jobs: make(map[string]*importJob)}
+
+func (j *importJob) snapshot() model.ImportJob {
+	j.Lock()
+	defer j.Unlock()
+
+	job := j.job
+	job.Errors = append([]string{}, j.job.Errors...)
+	job.Tables = append([]string{}, j.job.Tables...)
+	return job
+}
+
+func (j *importJob) addError(err error) {
+	j.Lock()
+	defer j.Unlock()
+
+	if len(j.job.Errors) < maxImportJobErrors {
+		j.job.Errors = append(j.job.Errors, err.Error())
+	}
+}
+
+func (j *importJob) finish(status string) {
+	j.Lock()
+	defer j.Unlock()
+
+	now := time.Now()
+	j.job.Status = status
+	j.job.FinishDate = &now
+}
+
+// StartImport registers a new job of the owner for the capture file and runs it in the
+// background. The file is removed once the job has finished.
+func (is *ImportService) StartImport(fileName, filePath string, options model.ImportOptions, owner model.ImportOwner,
+	scope *tenant.Filter) (model.ImportJob, error) {
+
+	session, node, err := is.importSession(options.Node)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+	options.Node = node
+
+	if options.CaptureID == "" {
+		options.CaptureID = config.Setting.IMPORT_SETTINGS.CaptureID
+	}
+
+	if !scope.AllowsNode(options.Node) || !scope.AllowsCaptureID(options.CaptureID) {
+		return model.ImportJob{}, ErrTenantHidden
+	}
+
+	if options.Profile != "" && !isValidProfile(options.Profile) {
+		return model.ImportJob{}, fmt.Errorf("bad profile name: %s", options.Profile)
+	}
+
+	if _, err := textLogOptions(options); err != nil {
+		return model.ImportJob{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &importJob{
+		job: model.ImportJob{
+			ID:         uuid.NewV4().String(),
+			Status:     model.ImportJobQueued,
+			FileName:   fileName,
+			Options:    options,
+			Errors:     []string{},
+			Tables:     []string{},
+			CreateDate: time.Now(),
+			Owner:      owner.UserName,
+			TenantGUID: owner.TenantGUID,
+			PartID:     owner.PartID,
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+		scope:  scope,
+	}
+
+	importJobs.Lock()
+	pruneImportJobs()
+	importJobs.jobs[job.job.ID] = job
+	importJobs.Unlock()
+
+	go func() {
+		defer close(job.done)
+		defer os.Remove(filePath)
+		defer cancel()
+		is.runImport(ctx, session, job, filePath)
+	}()
+
+	return job.snapshot(), nil
+}
+
+// WaitImport blocks until the job has finished
+func (is *ImportService) WaitImport(id string, owner model.ImportOwner) (model.ImportJob, error) {
+
+	job, err := getImportJob(id, owner)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	<-job.done
+	return job.snapshot(), nil
+}
+
+// GetImportJobs returns the known jobs the user sees, the newest first
+func (is *ImportService) GetImportJobs(owner model.ImportOwner) model.ImportJobList {
+
+	importJobs.RLock()
+	defer importJobs.RUnlock()
+
+	list := model.ImportJobList{Data: []model.ImportJob{}}
+	for _, job := range importJobs.jobs {
+		if snapshot := job.snapshot(); owner.Sees(snapshot) {
+			list.Data = append(list.Data, snapshot)
+		}
+	}
+
+	sort.Slice(list.Data, func(i, j int) bool {
+		return list.Data[i].CreateDate.After(list.Data[j].CreateDate)
+	})
+	list.Count = len(list.Data)
+
+	return list
+}
+
+// GetImportJob returns the progress of one job
+func (is *ImportService) GetImportJob(id string, owner model.ImportOwner) (model.ImportJob, error) {
+
+	job, err := getImportJob(id, owner)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+	return job.snapshot(), nil
+}
+
+// CancelImportJob stops a running job. Batches which have been committed already stay in
+// the database and can be removed with DeleteImportData.
+func (is *ImportService) CancelImportJob(id string, owner model.ImportOwner) (model.ImportJob, error) {
+
+	job, err := getImportJob(id, owner)
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+
+	job.cancel()
+	<-job.done
+
+	return job.snapshot(), nil
+}
+
+// DeleteImportData cancels the job if needed and removes all rows tagged with its import id.
+// Jobs which are no longer known are looked up in the default import tables on every node,
+// their owner is unknown and only a super admin deletes them.
+func (is *ImportService) DeleteImportData(id string, owner model.ImportOwner) (int64, error) {
+
+	if _, err := uuid.FromString(id); err != nil {
+		return 0, fmt.Errorf("bad import id: %s", id)
+	}
+
+	sessions := map[string]*gorm.DB{}
+	var tables []string
+	known := false
+
+	if job, err := getImportJob(id, owner); err == nil {
+		job.cancel()
+		<-job.done
+
+		snapshot := job.snapshot()
+		session, _, err := is.importSession(snapshot.Options.Node)
+		if err != nil {
+			return 0, err
+		}
+		sessions[snapshot.Options.Node] = session
+		tables = snapshot.Tables
+		known = true
+	} else if !owner.SuperAdmin {
+		return 0, err
+	} else {
+		sessions = is.Session
+		tables = []string{"hep_proto_1_call", "hep_proto_1_registration", "hep_proto_1_default",
+			"hep_proto_5_default", "hep_proto_100_default", "hep_proto_" + config.Setting.IMPORT_SETTINGS.FallbackProfile}
+	}
+
+	var deleted int64
+	for node, session := range sessions {
+		for _, table := range tables {
+			result := session.Exec("DELETE FROM "+table+" WHERE protocol_header->>'importId' = ?", id)
+			if result.Error != nil {
+				logger.Error(fmt.Sprintf("DeleteImportData: couldn't delete from [%s] on [%s]: %s", table, node, result.Error.Error()))
+				if known {
+					return deleted, result.Error
+				}
+				continue
+			}
+			deleted += result.RowsAffected
+		}
+	}
+
+	importJobs.Lock()
+	delete(importJobs.jobs, id)
+	importJobs.Unlock()
+
+	logger.Info(fmt.Sprintf("DeleteImportData: removed [%d] rows of import [%s]", deleted, id))
+
+	return deleted, nil
+}
+
+/* the jobs of other users are not found, like unknown ones */
+func getImportJob(id string, owner model.ImportOwner) (*importJob, error) {
+
+	importJobs.RLock()
+	defer importJobs.RUnlock()
+
+	if job, ok := importJobs.jobs[id]; ok && owner.Sees(job.snapshot()) {
+		return job, nil
+	}
+	return nil, fmt.Errorf("import job not found: %s", id)
+}
+
+// pruneImportJobs drops the oldest finished jobs, importJobs has to be locked
+func pruneImportJobs() {
+
+	/* the snapshots are taken under the lock of each job */
+	var finished []model.ImportJob
+	for _, job := range importJobs.jobs {
+		if snapshot := job.snapshot(); snapshot.Finished() {
+			finished = append(finished, snapshot)
+		}
+	}
+
+	keep := config.Setting.IMPORT_SETTINGS.KeepJobs
+	if keep < 1 {
+		keep = 1
+	}
+
+	if len(finished) < keep {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreateDate.Before(finished[j].CreateDate)
+	})
+
+	for _, job := range finished[:len(finished)-keep+1] {
+		delete(importJobs.jobs, job.ID)
+	}
+}
+
+func isValidProfile(profile string) bool {
+
+	for _, c := range profile {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '_' {
+			return false
+		}
+	}
+	return profile != ""
+}
+
+// importSession returns the data node the imported records have to be written to
+func (is *ImportService) importSession(node string) (*gorm.DB, string, error) {
+	return selectDataSession(is.Session, node)
+}
+
+// textLogOptions combines the text log settings of the job with the configured ones
+func textLogOptions(options model.ImportOptions) (importreader.TextLogOptions, error) {
+
+	settings := config.Setting.IMPORT_SETTINGS.TEXT_LOG
+	textOptions := importreader.TextLogOptions{
+		TimestampRegex:  settings.TimestampRegex,
+		TimestampLayout: settings.TimestampLayout,
+		AddressRegex:    settings.AddressRegex,
+		LocalIP:         settings.LocalIP,
+		LocalPort:       uint16(settings.LocalPort),
+	}
+
+	if options.AddressRegex != "" {
+		textOptions.AddressRegex = options.AddressRegex
+		textOptions.TimestampRegex = options.TimestampRegex
+	}
+	if options.TimestampLayout != "" {
+		textOptions.TimestampLayout = options.TimestampLayout
+	}
+
+	if options.LogDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", options.LogDate, time.Local)
+		if err != nil {
+			return textOptions, fmt.Errorf("bad log date: %s", options.LogDate)
+		}
+		textOptions.Date = date
+	}
+
+	for _, expr := range []string{textOptions.TimestampRegex, textOptions.AddressRegex} {
+		if _, err := regexp.Compile(expr); err != nil {
+			return textOptions, fmt.Errorf("bad regex: %s", err.Error())
+		}
+	}
+
+	return textOptions, nil
+}
+
+func (is *ImportService) runImport(ctx context.Context, session *gorm.DB, job *importJob, filePath string) {
+
+	job.Lock()
+	job.job.Status = model.ImportJobRunning
+	options := job.job.Options
+	job.Unlock()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		logger.Error("ImportPcapData: couldn't open the capture: ", err)
+		job.addError(err)
+		job.finish(model.ImportJobFailed)
+		return
+	}
+	defer file.Close()
+
+	textOptions, err := textLogOptions(options)
+	if err != nil {
+		job.addError(err)
+		job.finish(model.ImportJobFailed)
+		return
+	}
+
+	format := options.Format
+	if format == "" {
+		format = config.Setting.IMPORT_SETTINGS.TEXT_LOG.Format
+	}
+
+	reader, err := importreader.Open(file, format, textOptions)
+	if err != nil {
+		logger.Error("ImportPcapData: couldn't read the capture: ", err)
+		job.addError(err)
+		job.finish(model.ImportJobFailed)
+		return
+	}
+
+	readerOptions := importreader.Options{
+		CaptureID:       options.CaptureID,
+		FallbackProfile: config.Setting.IMPORT_SETTINGS.FallbackProfile,
+		Profile:         options.Profile,
+		ImportID:        job.job.ID,
+	}
+
+	batchSize := config.Setting.IMPORT_SETTINGS.BatchSize
+	if batchSize <= 0 || batchSize > maxImportBatchSize {
+		batchSize = maxImportBatchSize
+	}
+
+	timeShift := time.Duration(options.TimeShift) * time.Second
+	firstPacket := true
+	batch := make([]*importreader.Record, 0, batchSize)
+
+	for {
+		if ctx.Err() != nil {
+			logger.Info(fmt.Sprintf("ImportPcapData: job [%s] has been cancelled", job.job.ID))
+			job.finish(model.ImportJobCancelled)
+			return
+		}
+
+		packet, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			logger.Error("ImportPcapData: couldn't read packet: ", err)
+			job.addError(err)
+			break
+		}
+
+		/* shift the whole capture so it starts now */
+		if options.Now && firstPacket {
+			timeShift += time.Now().Sub(packet.Timestamp)
+		}
+		firstPacket = false
+		packet.Timestamp = packet.Timestamp.Add(timeShift)
+
+		job.Lock()
+		job.job.Frames, _ = reader.Counters()
+		job.job.PacketsRead++
+		job.Unlock()
+
+		record, err := importreader.BuildRecord(packet, readerOptions)
+		if err == nil {
+			err = tenantRecord(job.scope, options.Node, record)
+		}
+		if err != nil {
+			job.addError(err)
+			job.Lock()
+			job.job.Rejected++
+			job.Unlock()
+			continue
+		}
+
+		batch = append(batch, record)
+		if len(batch) >= batchSize {
+			insertImportBatch(session, job, batch)
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		insertImportBatch(session, job, batch)
+	}
+
+	frames, skipped := reader.Counters()
+	job.Lock()
+	job.job.Frames = frames
+	job.Unlock()
+
+	snapshot := job.snapshot()
+	logger.Info(fmt.Sprintf("ImportPcapData: job [%s] frames: [%d], skipped: [%d], inserted: [%d], rejected: [%d]",
+		snapshot.ID, frames, skipped, snapshot.Inserted, snapshot.Rejected))
+
+	if snapshot.Inserted == 0 && len(snapshot.Errors) > 0 {
+		job.finish(model.ImportJobFailed)
+		return
+	}
+
+	job.finish(model.ImportJobDone)
+}
+
+// insertImportBatch writes the batch and updates the counters of the job
+func insertImportBatch(session *gorm.DB, job *importJob, batch []*importreader.Record) {
+
+	tables, failed, err := writeRecords(session, batch)
+	if err != nil {
+		logger.Error("ImportPcapData: couldn't write batch: ", err)
+		job.addError(err)
+	}
+
+	job.Lock()
+	job.job.Inserted += len(batch) - len(failed)
+	job.job.Rejected += len(failed)
+	for _, table := range tables {
+		known := false
+		for _, name := range job.job.Tables {
+			if name == table {
+				known = true
+				break
+			}
+		}
+		if !known {
+			job.job.Tables = append(job.job.Tables, table)
+		}
+	}
+	job.Unlock()
+}
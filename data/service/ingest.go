@@ -0,0 +1,250 @@
+package service
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/importreader"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+const (
+	// the biggest NDJSON line we accept
+	maxIngestLine = 1024 * 1024
+)
+
+// IngestService writes records posted by external tools into the data tables
+type IngestService struct {
+	ServiceData
+	ConfigSession *gorm.DB
+}
+
+type ingestLine struct {
+	line   int
+	record *importreader.Record
+}
+
+// IngestHep reads NDJSON from the body. Every line is a HepTable shaped object or a base64
+// encoded HEPv3 frame. Records are validated against the mapping of their profile in the
+// partition and inserted in batches, errors are reported per line.
+func (is *IngestService) IngestHep(body io.Reader, node, profile string, partid int, scope *tenant.Filter) (model.IngestResult, error) {
+
+	result := model.IngestResult{Errors: []model.IngestError{}}
+
+	session, node, err := selectDataSession(is.Session, node)
+	if err != nil {
+		return result, err
+	}
+	if !scope.AllowsNode(node) {
+		return result, ErrTenantHidden
+	}
+	result.Node = node
+
+	if profile != "" && !isValidProfile(profile) {
+		return result, fmt.Errorf("bad profile name: %s", profile)
+	}
+
+	batchSize := config.Setting.IMPORT_SETTINGS.BatchSize
+	if batchSize <= 0 || batchSize > maxImportBatchSize {
+		batchSize = maxImportBatchSize
+	}
+
+	mappings := newRecordMappings(is.ConfigSession, partid)
+	batch := make([]ingestLine, 0, batchSize)
+
+	reject := func(line int, err error) {
+		result.Rejected++
+		result.Errors = append(result.Errors, model.IngestError{Line: line, Message: err.Error()})
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxIngestLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		result.Received++
+		if config.Setting.INGEST_SETTINGS.MaxRecords > 0 && result.Received > config.Setting.INGEST_SETTINGS.MaxRecords {
+			result.Received--
+			result.Errors = append(result.Errors, model.IngestError{Line: line,
+				Message: fmt.Sprintf("too many records, the limit is %d", config.Setting.INGEST_SETTINGS.MaxRecords)})
+			break
+		}
+
+		record, err := parseIngestLine(text, profile)
+		if err == nil {
+			err = mappings.validate(record)
+		}
+		if err == nil {
+			err = tenantRecord(scope, node, record)
+		}
+		if err != nil {
+			reject(line, err)
+			continue
+		}
+
+		batch = append(batch, ingestLine{line: line, record: record})
+		if len(batch) >= batchSize {
+			is.insertIngestBatch(session, batch, &result, reject)
+			batch = batch[:0]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		result.Errors = append(result.Errors, model.IngestError{Line: line + 1, Message: err.Error()})
+	}
+
+	if len(batch) > 0 {
+		is.insertIngestBatch(session, batch, &result, reject)
+	}
+
+	logger.Info(fmt.Sprintf("IngestHep: node [%s], received: [%d], inserted: [%d], rejected: [%d]",
+		node, result.Received, result.Inserted, result.Rejected))
+
+	return result, nil
+}
+
+// insertIngestBatch writes the batch in one transaction. The records of the tables which
+// have failed are written one by one to find the broken ones.
+func (is *IngestService) insertIngestBatch(session *gorm.DB, batch []ingestLine, result *model.IngestResult, reject func(int, error)) {
+
+	records := make([]*importreader.Record, 0, len(batch))
+	lines := make(map[*importreader.Record]int, len(batch))
+	for _, val := range batch {
+		records = append(records, val.record)
+		lines[val.record] = val.line
+	}
+
+	_, failed, err := writeRecords(session, records)
+	result.Inserted += len(records) - len(failed)
+	if err == nil {
+		return
+	}
+
+	/* only the tables which have failed are retried */
+	for _, record := range failed {
+		if _, _, err := writeRecords(session, []*importreader.Record{record}); err != nil {
+			reject(lines[record], err)
+			continue
+		}
+		result.Inserted++
+	}
+}
+
+// parseIngestLine turns a JSON object or a base64 HEPv3 frame into a record
+func parseIngestLine(text, profile string) (*importreader.Record, error) {
+
+	if strings.HasPrefix(text, "{") {
+		return parseIngestJSON(text, profile)
+	}
+
+	/* a quoted base64 string is fine too */
+	if strings.HasPrefix(text, "\"") {
+		if err := json.Unmarshal([]byte(text), &text); err != nil {
+			return nil, fmt.Errorf("bad JSON string: %s", err.Error())
+		}
+	}
+
+	frame, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		if frame, err = base64.RawStdEncoding.DecodeString(text); err != nil {
+			return nil, fmt.Errorf("line is neither a JSON object nor base64: %s", err.Error())
+		}
+	}
+
+	packet, err := importreader.UnwrapHEP(frame)
+	if err != nil {
+		return nil, fmt.Errorf("bad HEP frame: %s", err.Error())
+	}
+
+	return importreader.BuildRecord(packet, importreader.Options{
+		CaptureID:       config.Setting.IMPORT_SETTINGS.CaptureID,
+		FallbackProfile: config.Setting.IMPORT_SETTINGS.FallbackProfile,
+		Profile:         profile,
+	})
+}
+
+func parseIngestJSON(text, profile string) (*importreader.Record, error) {
+
+	var row model.HepTable
+	if err := json.Unmarshal([]byte(text), &row); err != nil {
+		return nil, fmt.Errorf("bad JSON record: %s", err.Error())
+	}
+
+	if row.Sid == "" {
+		return nil, fmt.Errorf("sid is missing")
+	}
+
+	protocolHeader := map[string]interface{}{}
+	if err := json.Unmarshal(row.ProtocolHeader, &protocolHeader); err != nil || len(protocolHeader) == 0 {
+		return nil, fmt.Errorf("protocol_header has to be a JSON object")
+	}
+
+	dataHeader := map[string]interface{}{}
+	if len(row.DataHeader) == 0 {
+		row.DataHeader = json.RawMessage("{}")
+	} else if err := json.Unmarshal(row.DataHeader, &dataHeader); err != nil {
+		return nil, fmt.Errorf("data_header has to be a JSON object")
+	}
+
+	if row.CreatedDate.IsZero() {
+		row.CreatedDate = time.Now()
+	}
+
+	if row.Profile == "" {
+		row.Profile = profile
+	}
+	if row.Profile == "" {
+		row.Profile = recordProfile(protocolHeader, dataHeader)
+	}
+	if !isValidProfile(row.Profile) {
+		return nil, fmt.Errorf("bad profile name: %s", row.Profile)
+	}
+
+	return &importreader.Record{
+		Profile:        row.Profile,
+		Sid:            row.Sid,
+		CreateDate:     row.CreatedDate,
+		ProtocolHeader: row.ProtocolHeader,
+		DataHeader:     row.DataHeader,
+		Raw:            row.Raw,
+	}, nil
+}
+
+// recordProfile picks the profile the way heplify-server does: SIP by the CSeq method,
+// everything else <payloadType>_default
+func recordProfile(protocolHeader, dataHeader map[string]interface{}) string {
+
+	payloadType := importreader.PayloadTypeSIP
+	if val, ok := protocolHeader["payloadType"].(float64); ok {
+		payloadType = int(val)
+	}
+
+	if payloadType != importreader.PayloadTypeSIP {
+		return fmt.Sprintf("%d_default", payloadType)
+	}
+
+	method, _ := dataHeader["method"].(string)
+	if cseq, ok := dataHeader["cseq"].(string); ok {
+		if fields := strings.Fields(cseq); len(fields) == 2 {
+			method = fields[1]
+		}
+	}
+
+	return importreader.SIPProfile(strings.ToUpper(method))
+}
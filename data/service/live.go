@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/alias"
+	"github.com/sipcapture/homer-app/utils/heputils"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+// LiveCursor remembers per node the last row a live subscription has sent
+type LiveCursor struct {
+	start time.Time
+	nodes map[string]liveCursorNode
+}
+
+type liveCursorNode struct {
+	date time.Time
+	id   int
+}
+
+// NewLiveCursor returns a cursor which starts with the rows written after since
+func NewLiveCursor(since time.Time) *LiveCursor {
+	return &LiveCursor{start: since, nodes: map[string]liveCursorNode{}}
+}
+
+// SkipTo forgets everything before the time, used when a client can't keep up
+func (lc *LiveCursor) SkipTo(since time.Time) {
+	lc.start = since
+	lc.nodes = map[string]liveCursorNode{}
+}
+
+// Lag returns how far the oldest node of the cursor is behind the time
+func (lc *LiveCursor) Lag(now time.Time) time.Duration {
+
+	oldest := now
+	for _, val := range lc.nodes {
+		if val.date.Before(oldest) {
+			oldest = val.date
+		}
+	}
+	if len(lc.nodes) == 0 {
+		oldest = lc.start
+	}
+	return now.Sub(oldest)
+}
+
+func (lc *LiveCursor) node(name string) liveCursorNode {
+	if val, ok := lc.nodes[name]; ok {
+		return val
+	}
+	return liveCursorNode{date: lc.start}
+}
+
+// LiveData returns the rows matching the search which were written after the cursor and
+// before until, at most limit of them. The cursor moves past the returned rows.
+func (ss *SearchService) LiveData(searchObject *model.SearchObject, cursor *LiveCursor, until time.Time, limit int,
+	aliases *alias.Resolver, scope *tenant.Filter, mapsFieldsData map[string]json.RawMessage) (*gabs.Container, error) {
+
+	table, sqlWhere, dataArrayExtraValues, _ := searchQuery(searchObject, aliases, mapsFieldsData)
+	sql := "(create_date, id) > (?, ?) AND create_date <= ?" + sqlWhere
+
+	nodes := []string{}
+	for session := range ss.Session {
+		/* no node means all of them */
+		if !scope.AllowsNode(session) || !scope.AllowsTable(table) {
+			continue
+		}
+		if len(searchObject.Param.Location.Node) == 0 || heputils.ElementExists(searchObject.Param.Location.Node, session) {
+			nodes = append(nodes, session)
+		}
+	}
+
+	/* the limit is shared by the nodes */
+	if len(nodes) > 1 {
+		limit = limit / len(nodes)
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	searchData := []model.HepTable{}
+	for _, session := range nodes {
+
+		position := cursor.node(session)
+		dataArrayValues := []interface{}{position.date, position.id, until}
+		dataArrayValues = append(dataArrayValues, dataArrayExtraValues...)
+
+		searchTmp := []model.HepTable{}
+		if err := ss.Session[session].
+			Table(table).
+			Where(sql, dataArrayValues...).
+			Scopes(tenantScope(scope)).
+			Order("create_date, id").
+			Limit(limit).
+			Find(&searchTmp).Error; err != nil {
+			return nil, err
+		}
+
+		if len(searchTmp) == 0 {
+			/* nothing new, the node has been read up to until */
+			if position.date.Before(until) {
+				cursor.nodes[session] = liveCursorNode{date: until, id: 0}
+			}
+			continue
+		}
+
+		last := searchTmp[len(searchTmp)-1]
+		cursor.nodes[session] = liveCursorNode{date: last.CreatedDate, id: last.Id}
+
+		for val := range searchTmp {
+			searchTmp[val].Node = session
+			searchTmp[val].DBNode = session
+		}
+		searchData = append(searchData, searchTmp...)
+	}
+
+	searchData = tenantRows(scope, table, searchData)
+	searchData = filterRows(rowFilters(searchObject), searchData)
+
+	sort.Slice(searchData, func(i, j int) bool {
+		return searchData[i].CreatedDate.Before(searchData[j].CreatedDate)
+	})
+
+	return formatSearchRows(table, searchData, aliases), nil
+}
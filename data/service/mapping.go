@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/Jeffail/gabs/v2"
+	uuid "github.com/satori/go.uuid"
 	"github.com/sipcapture/homer-app/migration"
 	"github.com/sipcapture/homer-app/model"
 	"github.com/sipcapture/homer-app/utils/logger"
@@ -16,14 +17,15 @@ type MappingService struct {
 	ServiceConfig
 }
 
-// this method gets all users from database
-func (mps *MappingService) GetMapping() (string, error) {
+// this method gets all mappings of a partition from database
+func (mps *MappingService) GetMapping(partid int) (string, error) {
 	var mappingObject []*model.TableMappingSchema
-	var count int
-	if err := mps.Session.Debug().Table("mapping_schema").Where("partid = ?", 10).
-		Find(&mappingObject).Count(&count).Error; err != nil {
+	if err := mps.Session.Debug().Table("mapping_schema").Scopes(partitionScope(partid)).
+		Find(&mappingObject).Error; err != nil {
 		return "", err
 	}
+	mappingObject = ownMappings(mappingObject, partid)
+	count := len(mappingObject)
 	if len(mappingObject) == 0 {
 		return "", fmt.Errorf("data was not found")
 	}
@@ -43,14 +45,16 @@ func (hs *MappingService) AddMapping(data model.TableMappingSchema) (string, err
 }
 
 // this method gets all the mapping from database
-func (mps *MappingService) GetMappingFields(id, transaction string) (string, error) {
+func (mps *MappingService) GetMappingFields(id, transaction string, partid int) (string, error) {
 	var mappingObject []*model.TableMappingSchema
-	var count int
 	if err := mps.Session.Debug().Table("mapping_schema").
 		Where("hepid = ? and profile = ?", id, transaction).
-		Find(&mappingObject).Count(&count).Error; err != nil {
+		Scopes(partitionScope(partid)).
+		Find(&mappingObject).Error; err != nil {
 		return "", err
 	}
+	mappingObject = ownMappings(mappingObject, partid)
+	count := len(mappingObject)
 	if len(mappingObject) == 0 {
 		return "", fmt.Errorf("data was not found")
 	}
@@ -60,11 +64,12 @@ func (mps *MappingService) GetMappingFields(id, transaction string) (string, err
 }
 
 // this method gets all the mapping from database
-func (mps *MappingService) GetMappingAgainstGUID(guid string) (string, error) {
+func (mps *MappingService) GetMappingAgainstGUID(guid string, partid int) (string, error) {
 	var mappingObject []*model.TableMappingSchema
 	var count int
 	if err := mps.Session.Debug().Table("mapping_schema").
 		Where("guid = ?", guid).
+		Scopes(partitionScope(partid)).
 		Find(&mappingObject).Count(&count).Error; err != nil {
 		return "", err
 	}
@@ -76,9 +81,24 @@ func (mps *MappingService) GetMappingAgainstGUID(guid string) (string, error) {
 	return response, nil
 }
 
-func (mps *MappingService) UpdateMappingAgainstGUID(guid string, data model.TableMappingSchema) (string, error) {
+// UpdateMappingAgainstGUID changes a mapping of the partition. A mapping of the default
+// partition is copied into the partition, the other partitions keep using it.
+func (mps *MappingService) UpdateMappingAgainstGUID(guid string, partid int, data model.TableMappingSchema) (string, error) {
+	partid = model.Partition(partid)
+	stored := model.TableMappingSchema{}
 	if err := mps.Session.Debug().Table("mapping_schema").
 		Where("guid = ?", guid).
+		Scopes(partitionScope(partid)).
+		First(&stored).Error; err != nil {
+		return "", err
+	}
+	data.PartID = partid
+	if stored.PartID != partid {
+		data.GUID = uuid.NewV4().String()
+		return mps.AddMapping(data)
+	}
+	if err := mps.Session.Debug().Table("mapping_schema").
+		Where("guid = ? AND partid = ?", guid, partid).
 		Update(&data).Error; err != nil {
 		return "", err
 	}
@@ -86,11 +106,11 @@ func (mps *MappingService) UpdateMappingAgainstGUID(guid string, data model.Tabl
 	return response, nil
 }
 
-// this method gets all the mapping from database
-func (mps *MappingService) DeleteMappingAgainstGUID(guid string) (string, error) {
+// this method deletes a mapping of the partition from database
+func (mps *MappingService) DeleteMappingAgainstGUID(guid string, partid int) (string, error) {
 	var mappingObject []*model.TableMappingSchema
 	if err := mps.Session.Debug().Table("mapping_schema").
-		Where("guid = ?", guid).
+		Where("guid = ? AND partid = ?", guid, model.Partition(partid)).
 		Delete(&mappingObject).Error; err != nil {
 		return "", err
 	}
@@ -99,12 +119,13 @@ func (mps *MappingService) DeleteMappingAgainstGUID(guid string) (string, error)
 }
 
 // this method gets all the mapping from database
-func (mps *MappingService) GetSmartSuggestionAginstProfile(hepid string, profile string, queryString string) (string, error) {
+func (mps *MappingService) GetSmartSuggestionAginstProfile(hepid string, profile string, partid int, queryString string) (string, error) {
 
 	var mappingObject model.TableMappingSchema
 	if err := mps.Session.Debug().Table("mapping_schema").
 		Where("hepid = ? and profile = ?", hepid, profile).
-		Take(&mappingObject).Error; err != nil {
+		Scopes(partitionScope(partid)).
+		First(&mappingObject).Error; err != nil {
 		return "", err
 	}
 	if mappingObject.MappingSettings == nil {
@@ -185,8 +206,9 @@ func (mps *MappingService) GetSmartSuggestionAginstProfile(hepid string, profile
 	return dataReply.String(), nil
 }
 
-// this method gets all the mapping from database
-func (mps *MappingService) RecreateMapping() error {
+// RecreateMapping resets the mappings of a partition, the default partition gets the
+// default mappings again and any other partition goes back to using them
+func (mps *MappingService) RecreateMapping(partid int) error {
 
 	mappingSchema := migration.GetMappingSchemas()
 
@@ -195,7 +217,15 @@ func (mps *MappingService) RecreateMapping() error {
 	/* globalSettingData data */
 	logger.Debug("reinstalling " + tableName)
 
-	mps.Session.Exec("TRUNCATE TABLE " + tableName)
+	partid = model.Partition(partid)
+	if err := mps.Session.Debug().Table(tableName).
+		Where("partid = ?", partid).
+		Delete(&model.TableMappingSchema{}).Error; err != nil {
+		return err
+	}
+	if partid != model.DefaultPartition {
+		return nil
+	}
 	for _, el := range mappingSchema {
 		db := mps.Session.Save(&el)
 		if db != nil && db.Error != nil {
@@ -208,19 +238,26 @@ func (mps *MappingService) RecreateMapping() error {
 	return nil
 }
 
-// this method gets all the mapping from database
-func (mps *MappingService) RecreateMappingByUUID(guid string) error {
+// RecreateMappingByUUID resets a mapping of a partition, the one of the default
+// partition to the default mapping and any other to the one of the default partition
+func (mps *MappingService) RecreateMappingByUUID(guid string, partid int) error {
 
+	partid = model.Partition(partid)
 	var mappingObject []*model.TableMappingSchema
 	var count int
 	if err := mps.Session.Debug().Table("mapping_schema").
-		Where("guid = ?", guid).
+		Where("guid = ? AND partid = ?", guid, partid).
 		Find(&mappingObject).Count(&count).Error; err != nil {
 		return err
 	}
 	if len(mappingObject) == 0 {
 		return fmt.Errorf("data was not found")
 	}
+	if partid != model.DefaultPartition {
+		return mps.Session.Debug().Table("mapping_schema").
+			Where("guid = ? AND partid = ?", guid, partid).
+			Delete(&model.TableMappingSchema{}).Error
+	}
 
 	mappingSchema := migration.GetMappingSchemas()
 
@@ -229,7 +266,7 @@ func (mps *MappingService) RecreateMappingByUUID(guid string) error {
 		if el.Hepid == mappingObject[0].Hepid && el.HepAlias == mappingObject[0].HepAlias && el.Profile == mappingObject[0].Profile {
 
 			if err := mps.Session.Debug().Table("mapping_schema").
-				Where("guid = ?", guid).
+				Where("guid = ? AND partid = ?", guid, partid).
 				Delete(&mappingObject).Error; err != nil {
 				return err
 			}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrMFAChallenge is returned for an unknown or expired login challenge
+	ErrMFAChallenge = errors.New("the challenge is unknown or has expired")
+	// ErrMFACode is returned for a wrong code
+	ErrMFACode = errors.New("the code is not correct")
+	// ErrMFANotEnrolled is returned when the user has no TOTP yet
+	ErrMFANotEnrolled = errors.New("2FA hasn't been set up for the user")
+	// ErrMFAEnrolled is returned when the user enrols again, it has to disable 2FA first
+	ErrMFAEnrolled = errors.New("2FA has already been set up for the user")
+	// ErrMFAPolicy is returned when a user the policy wants 2FA from disables it
+	ErrMFAPolicy = errors.New("2FA is required for the group of the user")
+	// ErrMFAInternal is returned for the users who don't log in with a password of the users table
+	ErrMFAInternal = errors.New("2FA is only for the internal users")
+)
+
+const (
+	mfaChallengeTime     = 5 * time.Minute
+	mfaChallengeAttempts = 5
+	mfaRecoveryCodes     = 10
+	/* the policy is a global setting of the default partition */
+	mfaPolicyCategory = "security"
+	mfaPolicyParam    = "mfa"
+)
+
+type MFAService struct {
+	ServiceConfig
+}
+
+// the logins waiting for a code, the password has been checked
+var mfaChallenges = struct {
+	sync.Mutex
+	pending map[string]*mfaChallenge
+}{pending: map[string]*mfaChallenge{}}
+
+type mfaChallenge struct {
+	user     model.TableUser
+	expire   time.Time
+	attempts int
+}
+
+// Challenge returns the challenge of a login the 2FA of the user or the policy wants a
+// code for, nil if none is needed
+func (ms *MFAService) Challenge(user model.TableUser) (*model.MFAChallenge, error) {
+
+	_, enrolled, err := ms.enrolled(user.GUID)
+	if err != nil {
+		return nil, err
+	}
+	if !enrolled {
+		required, err := ms.required(user)
+		if err != nil || !required {
+			return nil, err
+		}
+	}
+
+	guid := uuid.NewV4().String()
+	now := time.Now()
+
+	mfaChallenges.Lock()
+	defer mfaChallenges.Unlock()
+	for key, val := range mfaChallenges.pending {
+		if now.After(val.expire) {
+			delete(mfaChallenges.pending, key)
+		}
+	}
+	mfaChallenges.pending[guid] = &mfaChallenge{user: user, expire: now.Add(mfaChallengeTime)}
+
+	return &model.MFAChallenge{
+		MFARequired: true,
+		Challenge:   guid,
+		Enrolled:    enrolled,
+		ExpiresIn:   int(mfaChallengeTime / time.Second),
+	}, nil
+}
+
+func (ms *MFAService) pending(challenge string) (model.TableUser, error) {
+
+	mfaChallenges.Lock()
+	defer mfaChallenges.Unlock()
+
+	val, ok := mfaChallenges.pending[challenge]
+	if !ok || time.Now().After(val.expire) {
+		delete(mfaChallenges.pending, challenge)
+		return model.TableUser{}, ErrMFAChallenge
+	}
+	return val.user, nil
+}
+
+/* a wrong code counts, the challenge ends after a few and the password is needed again */
+func (ms *MFAService) failed(challenge string) {
+
+	mfaChallenges.Lock()
+	defer mfaChallenges.Unlock()
+
+	if val, ok := mfaChallenges.pending[challenge]; ok {
+		if val.attempts++; val.attempts >= mfaChallengeAttempts {
+			delete(mfaChallenges.pending, challenge)
+		}
+	}
+}
+
+// Complete checks the code of the login challenge and returns its user, the challenge
+// is used once. A user who enrols at login confirms the TOTP with the code.
+func (ms *MFAService) Complete(challenge, code string) (model.TableUser, error) {
+
+	user, err := ms.pending(challenge)
+	if err != nil {
+		return user, err
+	}
+
+	row := model.TableUserMFA{}
+	if ms.Session.Debug().Where("user_guid = ?", user.GUID).Find(&row).RecordNotFound() {
+		return user, ErrMFANotEnrolled
+	}
+
+	if row.Enabled {
+		err = ms.check(&row, code, true)
+	} else {
+		err = ms.confirm(&row, code)
+	}
+	if err != nil {
+		ms.failed(challenge)
+		return user, err
+	}
+
+	mfaChallenges.Lock()
+	delete(mfaChallenges.pending, challenge)
+	mfaChallenges.Unlock()
+	return user, nil
+}
+
+// EnrolChallenge sets up the TOTP of a user the policy wants a code from, at login
+func (ms *MFAService) EnrolChallenge(challenge string) (model.MFAEnrolment, error) {
+
+	user, err := ms.pending(challenge)
+	if err != nil {
+		return model.MFAEnrolment{}, err
+	}
+	return ms.Enrol(user)
+}
+
+// Enrol makes a new secret and recovery codes for the user, they work once a code has
+// been confirmed
+func (ms *MFAService) Enrol(user model.TableUser) (model.MFAEnrolment, error) {
+
+	enrolment := model.MFAEnrolment{}
+
+	row, enrolled, err := ms.enrolled(user.GUID)
+	if err != nil {
+		return enrolment, err
+	}
+	if enrolled {
+		return enrolment, ErrMFAEnrolled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return enrolment, err
+	}
+	codes, err := totp.NewRecoveryCodes(mfaRecoveryCodes)
+	if err != nil {
+		return enrolment, err
+	}
+	hashes := []string{}
+	for _, code := range codes {
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return enrolment, err
+		}
+		hashes = append(hashes, string(hash))
+	}
+
+	if row == nil {
+		row = &model.TableUserMFA{UserGUID: user.GUID}
+	}
+	row.Secret = secret
+	row.Enabled = false
+	row.LastCounter = 0
+	row.RecoveryCodes = hashes
+	row.CreateDate = time.Now()
+	row.EnableDate = nil
+	if err := ms.Session.Debug().Save(row).Error; err != nil {
+		return enrolment, err
+	}
+
+	enrolment.Secret = secret
+	enrolment.URI = totp.URI(config.Setting.AUTH_SETTINGS.MFAIssuer, user.UserName, secret)
+	enrolment.RecoveryCodes = codes
+	return enrolment, nil
+}
+
+// Confirm turns the TOTP of the user on with a code of the app
+func (ms *MFAService) Confirm(user model.TableUser, code string) error {
+
+	row := model.TableUserMFA{}
+	if ms.Session.Debug().Where("user_guid = ?", user.GUID).Find(&row).RecordNotFound() {
+		return ErrMFANotEnrolled
+	}
+	if row.Enabled {
+		return ErrMFAEnrolled
+	}
+	return ms.confirm(&row, code)
+}
+
+func (ms *MFAService) confirm(row *model.TableUserMFA, code string) error {
+
+	/* the recovery codes aren't proof that the app has the secret */
+	if err := ms.check(row, code, false); err != nil {
+		return err
+	}
+	return ms.Session.Debug().Model(&model.TableUserMFA{}).Where("id = ?", row.Id).
+		Updates(map[string]interface{}{"enabled": true, "enable_date": time.Now()}).Error
+}
+
+// Disable turns the TOTP of the user off, with a code or a recovery code
+func (ms *MFAService) Disable(user model.TableUser, code string) error {
+
+	row, enrolled, err := ms.enrolled(user.GUID)
+	if err != nil {
+		return err
+	}
+	if !enrolled {
+		return ErrMFANotEnrolled
+	}
+	required, err := ms.required(user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFAPolicy
+	}
+	if err := ms.check(row, code, true); err != nil {
+		return err
+	}
+	return ms.Session.Debug().Where("id = ?", row.Id).Delete(&model.TableUserMFA{}).Error
+}
+
+// Reset removes the TOTP of a user who lost the app, an admin resets the users of its
+// partition, a super admin the ones of all
+func (ms *MFAService) Reset(userGUID string, partid int, superAdmin bool) (model.TableUser, error) {
+
+	user := model.TableUser{}
+	sqlWhere := map[string]interface{}{"guid": userGUID}
+	if !superAdmin {
+		sqlWhere = map[string]interface{}{"guid": userGUID, "partid": partid, "superadmin": false}
+	}
+	if ms.Session.Debug().Where(sqlWhere).Find(&user).RecordNotFound() {
+		return user, errors.New("the user was not found")
+	}
+	return user, ms.Session.Debug().Where("user_guid = ?", userGUID).Delete(&model.TableUserMFA{}).Error
+}
+
+// Delete removes the TOTP of a deleted user
+func (ms *MFAService) Delete(userGUID string) error {
+	return ms.Session.Debug().Where("user_guid = ?", userGUID).Delete(&model.TableUserMFA{}).Error
+}
+
+// User returns the internal user of the name, the users of LDAP, HTTP auth and the
+// identity providers have their 2FA there
+func (ms *MFAService) User(username string) (model.TableUser, error) {
+
+	if config.Setting.MAIN_SETTINGS.DefaultAuth != "internal" {
+		return model.TableUser{}, ErrMFAInternal
+	}
+	userService := UserService{ServiceConfig: ms.ServiceConfig}
+	user, err := userService.InternalUser(username)
+	if err != nil {
+		return user, err
+	}
+	if user.Source != "" {
+		return user, ErrMFAInternal
+	}
+	return user, nil
+}
+
+// Status returns the 2FA of the user
+func (ms *MFAService) Status(user model.TableUser) (model.MFAStatus, error) {
+
+	status := model.MFAStatus{}
+	row, enrolled, err := ms.enrolled(user.GUID)
+	if err != nil {
+		return status, err
+	}
+	if enrolled {
+		status.Enabled = true
+		status.RecoveryCodes = len(row.RecoveryCodes)
+	}
+	status.Required, err = ms.required(user)
+	return status, err
+}
+
+// check takes a code of the app, newer than the last one, or a recovery code which is
+// used up
+func (ms *MFAService) check(row *model.TableUserMFA, code string, recovery bool) error {
+
+	if counter, ok := totp.Validate(row.Secret, code, time.Now()); ok {
+		/* two logins with the same code at once, only one wins */
+		db := ms.Session.Debug().Model(&model.TableUserMFA{}).
+			Where("id = ? AND last_counter < ?", row.Id, counter).
+			UpdateColumn("last_counter", counter)
+		if db.Error != nil {
+			return db.Error
+		}
+		if db.RowsAffected == 0 {
+			return ErrMFACode
+		}
+		return nil
+	}
+
+	if recovery {
+		code = totp.NormalizeRecoveryCode(code)
+		for i, hash := range row.RecoveryCodes {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) != nil {
+				continue
+			}
+			left := append(append([]string{}, row.RecoveryCodes[:i]...), row.RecoveryCodes[i+1:]...)
+			logger.Info("recovery code used by the user ", row.UserGUID, ", ", len(left), " left")
+			db := ms.Session.Debug().Model(&model.TableUserMFA{}).
+				Where("id = ? AND ? = ANY(recovery_codes)", row.Id, hash).
+				UpdateColumn("recovery_codes", pq.StringArray(left))
+			if db.Error != nil {
+				return db.Error
+			}
+			if db.RowsAffected == 0 {
+				return ErrMFACode
+			}
+			return nil
+		}
+	}
+	return ErrMFACode
+}
+
+/* the TOTP of the user, enrolled once it has been confirmed */
+func (ms *MFAService) enrolled(userGUID string) (*model.TableUserMFA, bool, error) {
+
+	row := model.TableUserMFA{}
+	db := ms.Session.Debug().Where("user_guid = ?", userGUID).Find(&row)
+	if db.RecordNotFound() {
+		return nil, false, nil
+	}
+	if db.Error != nil {
+		return nil, false, db.Error
+	}
+	return &row, row.Enabled, nil
+}
+
+func (ms *MFAService) required(user model.TableUser) (bool, error) {
+
+	policy, err := ms.Policy()
+	if err != nil {
+		return false, err
+	}
+	for _, group := range UserGroups(user.UserGroup) {
+		for _, val := range policy.Groups {
+			if strings.EqualFold(group, val) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Policy returns the user groups which must use 2FA
+func (ms *MFAService) Policy() (model.MFAPolicy, error) {
+
+	policy := model.MFAPolicy{Groups: []string{}}
+	row := model.TableGlobalSettings{}
+	db := ms.Session.Debug().Where("partid = ? AND category = ? AND param = ?",
+		model.DefaultPartition, mfaPolicyCategory, mfaPolicyParam).Find(&row)
+	if db.RecordNotFound() {
+		return policy, nil
+	}
+	if db.Error != nil {
+		return policy, db.Error
+	}
+	if err := json.Unmarshal(row.Data, &policy); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// SetPolicy sets the user groups which must use 2FA
+func (ms *MFAService) SetPolicy(policy model.MFAPolicy) error {
+
+	groups := []string{}
+	for _, group := range policy.Groups {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	data, err := json.Marshal(model.MFAPolicy{Groups: groups})
+	if err != nil {
+		return err
+	}
+
+	row := model.TableGlobalSettings{}
+	if ms.Session.Debug().Where("partid = ? AND category = ? AND param = ?",
+		model.DefaultPartition, mfaPolicyCategory, mfaPolicyParam).Find(&row).RecordNotFound() {
+		row = model.TableGlobalSettings{
+			GUID:     uuid.NewV4().String(),
+			PartId:   model.DefaultPartition,
+			Category: mfaPolicyCategory,
+			Param:    mfaPolicyParam,
+		}
+	}
+	row.Data = data
+	row.CreateDate = time.Now()
+	return ms.Session.Debug().Save(&row).Error
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/oidc"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type OIDCService struct {
+	ServiceConfig
+}
+
+// User returns the user of the claims of a provider. With provisioning the user is made
+// in the users table at its first login, its groups and names are updated at the next.
+func (oc *OIDCService) User(provider *oidc.Provider, claims oidc.Claims) (model.TableUser, error) {
+
+	userData := model.TableUser{}
+	userService := UserService{ServiceConfig: oc.ServiceConfig}
+
+	username := provider.Username(claims)
+	if username == "" {
+		return userData, fmt.Errorf("the claims of %s have no user name", provider.Name)
+	}
+	groups := oc.knownGroups(provider.Groups(claims))
+	if len(groups) == 0 {
+		return userData, fmt.Errorf("the user %s has no known group at %s", username, provider.Name)
+	}
+
+	userData.UserName = username
+	userData.PartId = model.Partition(provider.PartID)
+	userData.Email = claims.String("email")
+	userData.FirstName = claims.String("given_name")
+	userData.LastName = claims.String("family_name")
+	userData.UserGroup = strings.Join(groups, ",")
+	userData.Source = "oidc:" + provider.Name
+
+	/* the provider knows the user by the subject, the name may be given to another one */
+	if claims.String("sub") == "" {
+		return userData, fmt.Errorf("the claims of %s have no subject", provider.Name)
+	}
+	subject := claims.String("iss") + "|" + claims.String("sub")
+	userData.Subject = subject
+
+	if provider.Provision {
+		name, err := oc.provision(userData)
+		if err != nil {
+			return userData, err
+		}
+		return userService.InternalUser(name)
+	}
+
+	/* the settings and dashboards go by the user name, a local user keeps its own */
+	if !oc.Session.Debug().Where("username = ?", username).Find(&model.TableUser{}).RecordNotFound() {
+		return userData, fmt.Errorf("the user %s of %s exists in the users table", username, provider.Name)
+	}
+
+	userData.Id = int(hashString(subject))
+	hash := md5.Sum([]byte(subject))
+	userData.GUID = hex.EncodeToString(hash[:])
+	userData.Avatar = claims.String("picture")
+	userData.ExternalAuth = true
+	userData.ExternalProfile = userData.Source
+	/* the roles of the groups make an admin, not their names */
+	userData.Permissions = userService.permissions(userData)
+	userData.IsAdmin = AdminPermissions(userData.Permissions)
+	userData.TenantGUID = userService.tenant(userData)
+	return userData, nil
+}
+
+// knownGroups keeps the user groups of the config and the groups roles are given to, a
+// group of the provider can't make an admin by chance
+func (oc *OIDCService) knownGroups(groups []string) []string {
+
+	roleService := RoleService{ServiceConfig: oc.ServiceConfig}
+	roles, err := roleService.cachedRoles()
+	if err != nil {
+		logger.Error("roles can't be loaded: ", err)
+	}
+
+	known := []string{}
+	for _, group := range groups {
+		found := false
+		for _, val := range config.Setting.MAIN_SETTINGS.UserGroups {
+			found = found || strings.EqualFold(val, group)
+		}
+		for _, role := range roles {
+			found = found || roleOfGroups(role, []string{group})
+		}
+		if found {
+			known = append(known, group)
+		} else {
+			logger.Debug("unknown group of an OpenID Connect user: ", group)
+		}
+	}
+	return known
+}
+
+// provision makes or updates the user of the subject and returns its name. The user keeps
+// the name it has been made with, the provider may give that name to another subject later.
+func (oc *OIDCService) provision(userData model.TableUser) (string, error) {
+
+	user := model.TableUser{}
+	if !oc.Session.Debug().Where("source = ? AND subject = ?", userData.Source, userData.Subject).
+		Find(&user).RecordNotFound() {
+		return user.UserName, oc.updateUser(user, userData, nil)
+	}
+
+	if oc.Session.Debug().Where("username = ?", userData.UserName).Find(&user).RecordNotFound() {
+
+		/* a password nobody knows, the user logs in at the provider */
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(uuid.NewV4().String()), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+		userData.Hash = string(hashedPassword)
+		userData.GUID = uuid.NewV4().String()
+		userData.Department = "OIDC"
+		userData.CreatedAt = time.Now()
+		if userData.FirstName == "" {
+			userData.FirstName = userData.UserName
+		}
+		logger.Info("new user ", userData.UserName, " from ", userData.Source)
+		return userData.UserName, oc.Session.Debug().Create(&userData).Error
+	}
+
+	if err := ownUser(user, userData); err != nil {
+		return "", err
+	}
+	/* the users made before the subject was kept get it at their next login */
+	return user.UserName, oc.updateUser(user, userData, map[string]interface{}{"subject": userData.Subject})
+}
+
+// ownUser checks that the user of the name has been made for the subject, only the users
+// the provider has made are its own, not the local ones of the same name
+func ownUser(user, userData model.TableUser) error {
+
+	if user.Source != userData.Source {
+		return fmt.Errorf("the user %s exists and doesn't come from %s", userData.UserName, userData.Source)
+	}
+	if user.Subject != "" && user.Subject != userData.Subject {
+		return fmt.Errorf("the user %s of %s belongs to another subject", userData.UserName, userData.Source)
+	}
+	return nil
+}
+
+// updateUser takes the groups and names of the provider
+func (oc *OIDCService) updateUser(user, userData model.TableUser, updates map[string]interface{}) error {
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["usergroup"], updates["email"] = userData.UserGroup, userData.Email
+	if userData.FirstName != "" {
+		updates["firstname"] = userData.FirstName
+	}
+	if userData.LastName != "" {
+		updates["lastname"] = userData.LastName
+	}
+	return oc.Session.Debug().Model(&model.TableUser{}).Where("guid = ?", user.GUID).Updates(updates).Error
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+)
+
+/* the roles of the config DB, kept in the cache so no DB is needed */
+func loadTestRoles(roles []model.TableRole) {
+	roleCache.Lock()
+	roleCache.roles, roleCache.loaded = roles, time.Now()
+	roleCache.Unlock()
+}
+
+func TestOIDCAdminOfRoles(t *testing.T) {
+
+	loadTestRoles([]model.TableRole{
+		{Name: model.RoleDefault, Permissions: model.DefaultPermissions},
+		{Name: "admin", Permissions: model.Permissions, Groups: []string{"admin"}},
+		{Name: "support", Permissions: []string{model.PermissionAliasWrite}, Groups: []string{"sysadmins-ro"}},
+	})
+	defer loadTestRoles(nil)
+
+	savedGroups := config.Setting.MAIN_SETTINGS.UserGroups
+	config.Setting.MAIN_SETTINGS.UserGroups = []string{"admin", "user", "noadmin"}
+	defer func() { config.Setting.MAIN_SETTINGS.UserGroups = savedGroups }()
+
+	oc := OIDCService{}
+	roleService := RoleService{}
+
+	tests := []struct {
+		groups []string
+		admin  bool
+	}{
+		{[]string{"admin"}, true},
+		{[]string{"Admin"}, true},
+		{[]string{"user", "admin"}, true},
+		{[]string{"noadmin"}, false},
+		{[]string{"sysadmins-ro"}, false},
+		{[]string{"user"}, false},
+		{[]string{"administrators"}, false},
+	}
+	for _, test := range tests {
+		permissions := roleService.Permissions(oc.knownGroups(test.groups), false)
+		if admin := AdminPermissions(permissions); admin != test.admin {
+			t.Errorf("[TestOIDCAdminOfRoles] groups %v: got admin %v, expected %v (permissions %v)",
+				test.groups, admin, test.admin, permissions)
+		}
+	}
+}
+
+func TestOIDCKnownGroups(t *testing.T) {
+
+	loadTestRoles([]model.TableRole{
+		{Name: "support", Permissions: []string{model.PermissionAliasWrite}, Groups: []string{"noc"}},
+	})
+	defer loadTestRoles(nil)
+
+	savedGroups := config.Setting.MAIN_SETTINGS.UserGroups
+	config.Setting.MAIN_SETTINGS.UserGroups = []string{"admin", "user"}
+	defer func() { config.Setting.MAIN_SETTINGS.UserGroups = savedGroups }()
+
+	oc := OIDCService{}
+	known := oc.knownGroups([]string{"user", "NOC", "everyone", "admins"})
+	if len(known) != 2 || known[0] != "user" || known[1] != "NOC" {
+		t.Errorf("[TestOIDCKnownGroups] got %v, expected [user NOC]", known)
+	}
+}
+
+func TestOIDCOwnUser(t *testing.T) {
+
+	userData := model.TableUser{UserName: "alice", Source: "oidc:keycloak", Subject: "https://idp.example.com|f3a1"}
+
+	tests := []struct {
+		user  model.TableUser
+		owned bool
+	}{
+		{model.TableUser{UserName: "alice", Source: "oidc:keycloak", Subject: "https://idp.example.com|f3a1"}, true},
+		/* made before the subject was kept */
+		{model.TableUser{UserName: "alice", Source: "oidc:keycloak"}, true},
+		/* the name has been given to another subject */
+		{model.TableUser{UserName: "alice", Source: "oidc:keycloak", Subject: "https://idp.example.com|9b2c"}, false},
+		{model.TableUser{UserName: "alice", Source: "oidc:google", Subject: "https://idp.example.com|f3a1"}, false},
+		{model.TableUser{UserName: "alice"}, false},
+	}
+	for _, test := range tests {
+		if err := ownUser(test.user, userData); (err == nil) != test.owned {
+			t.Errorf("[TestOIDCOwnUser] %s %q: got %v, expected owned %v", test.user.Source, test.user.Subject, err, test.owned)
+		}
+	}
+}
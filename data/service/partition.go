@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// partitionScope selects the config rows of a partition and the ones of the default
+// partition, which the partition uses as long as it doesn't have its own. The rows of
+// the partition come first.
+func partitionScope(partid int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("partid IN (?)", []int{model.Partition(partid), model.DefaultPartition}).
+			Order(fmt.Sprintf("partid = %d", model.DefaultPartition))
+	}
+}
+
+// ownMappings keeps, for every hepid and profile, the mapping of the partition over
+// the one of the default partition
+func ownMappings(rows []*model.TableMappingSchema, partid int) []*model.TableMappingSchema {
+
+	own := map[string]bool{}
+	for _, row := range rows {
+		if row.PartID == model.Partition(partid) {
+			own[fmt.Sprintf("%d_%s", row.Hepid, row.Profile)] = true
+		}
+	}
+	mappings := []*model.TableMappingSchema{}
+	for _, row := range rows {
+		if row.PartID != model.Partition(partid) && own[fmt.Sprintf("%d_%s", row.Hepid, row.Profile)] {
+			continue
+		}
+		mappings = append(mappings, row)
+	}
+	return mappings
+}
+
+// ownSettings keeps, for every category and param, the global setting of the
+// partition over the one of the default partition
+func ownSettings(rows []model.TableGlobalSettings, partid int) []model.TableGlobalSettings {
+
+	own := map[string]bool{}
+	for _, row := range rows {
+		if row.PartId == model.Partition(partid) {
+			own[row.Category+"/"+row.Param] = true
+		}
+	}
+	settings := []model.TableGlobalSettings{}
+	for _, row := range rows {
+		if row.PartId != model.Partition(partid) && own[row.Category+"/"+row.Param] {
+			continue
+		}
+		settings = append(settings, row)
+	}
+	return settings
+}
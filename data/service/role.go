@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// ErrRoleDefault is returned when the default role is deleted or renamed
+var ErrRoleDefault = errors.New("the default role can't be deleted or renamed")
+
+type RoleService struct {
+	ServiceConfig
+}
+
+// the roles are needed at every login and auth token request, they are kept until
+// they change here or for a minute, another instance may change them
+var roleCache struct {
+	sync.Mutex
+	roles  []model.TableRole
+	loaded time.Time
+}
+
+const roleCacheTime = time.Minute
+
+// UserGroups splits the group of a user, several groups are separated by commas
+func UserGroups(userGroup string) []string {
+
+	groups := []string{}
+	for _, group := range strings.Split(userGroup, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Permissions returns the permissions of the default role and of the roles of the
+// groups. Admin users have all.
+func (rs *RoleService) Permissions(groups []string, admin bool) []string {
+
+	if admin {
+		return append([]string{}, model.Permissions...)
+	}
+
+	roles, err := rs.cachedRoles()
+	if err != nil {
+		logger.Error("roles can't be loaded: ", err)
+		return []string{}
+	}
+
+	/* without a default role, like before the roles were populated, users keep
+	   what they could do before */
+	found := map[string]bool{}
+	for _, permission := range model.DefaultPermissions {
+		found[permission] = true
+	}
+	for _, role := range roles {
+		if role.Name == model.RoleDefault {
+			found = map[string]bool{}
+			break
+		}
+	}
+	for _, role := range roles {
+		if role.Name != model.RoleDefault && !roleOfGroups(role, groups) {
+			continue
+		}
+		for _, permission := range role.Permissions {
+			found[permission] = true
+		}
+	}
+
+	permissions := []string{}
+	for permission := range found {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// AdminPermissions tells if the permissions make an admin, the users who can manage the
+// users can do everything else through them
+func AdminPermissions(permissions []string) bool {
+	for _, permission := range permissions {
+		if permission == model.PermissionUsersAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func roleOfGroups(role model.TableRole, groups []string) bool {
+	for _, roleGroup := range role.Groups {
+		for _, group := range groups {
+			if strings.EqualFold(roleGroup, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (rs *RoleService) cachedRoles() ([]model.TableRole, error) {
+
+	roleCache.Lock()
+	defer roleCache.Unlock()
+
+	if roleCache.roles == nil || time.Since(roleCache.loaded) > roleCacheTime {
+		roles, err := rs.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		roleCache.roles, roleCache.loaded = roles, time.Now()
+	}
+	return roleCache.roles, nil
+}
+
+func (rs *RoleService) invalidate() {
+	roleCache.Lock()
+	roleCache.roles = nil
+	roleCache.Unlock()
+}
+
+func checkRole(role *model.TableRole) error {
+	for _, permission := range role.Permissions {
+		if !model.IsPermission(permission) {
+			return fmt.Errorf("unknown permission %q", permission)
+		}
+	}
+	return nil
+}
+
+// GetAll returns the roles
+func (rs *RoleService) GetAll() ([]model.TableRole, error) {
+
+	roles := []model.TableRole{}
+	if err := rs.Session.Debug().
+		Table("roles").
+		Order("name").
+		Find(&roles).Error; err != nil {
+		return roles, err
+	}
+	return roles, nil
+}
+
+// Get returns the role of the guid
+func (rs *RoleService) Get(guid string) (model.TableRole, error) {
+
+	role := model.TableRole{}
+	err := rs.Session.Debug().
+		Table("roles").
+		Where("guid = ?", guid).
+		First(&role).Error
+	return role, err
+}
+
+// Add creates a role
+func (rs *RoleService) Add(role *model.TableRole) (string, error) {
+
+	if err := checkRole(role); err != nil {
+		return "", err
+	}
+	role.GUID = uuid.NewV4().String()
+	role.CreateDate = time.Now()
+	if err := rs.Session.Debug().
+		Table("roles").
+		Create(role).Error; err != nil {
+		return "", err
+	}
+	rs.invalidate()
+
+	reply := gabs.New()
+	reply.Set(role.GUID, "data")
+	reply.Set("successfully created role", "message")
+	return reply.String(), nil
+}
+
+// Update changes a role, the default role keeps its name
+func (rs *RoleService) Update(role *model.TableRole) error {
+
+	if err := checkRole(role); err != nil {
+		return err
+	}
+	old, err := rs.Get(role.GUID)
+	if err != nil {
+		return err
+	}
+	if old.Name == model.RoleDefault && role.Name != model.RoleDefault {
+		return ErrRoleDefault
+	}
+	if err := rs.Session.Debug().
+		Table("roles").
+		Where("guid = ?", role.GUID).
+		Updates(map[string]interface{}{"name": role.Name, "description": role.Description,
+			"permissions": role.Permissions, "groups": role.Groups}).Error; err != nil {
+		return err
+	}
+	rs.invalidate()
+	return nil
+}
+
+// Delete removes a role, except the default one
+func (rs *RoleService) Delete(role *model.TableRole) error {
+
+	if role.Name == model.RoleDefault {
+		return ErrRoleDefault
+	}
+	if err := rs.Session.Debug().
+		Table("roles").
+		Where("guid = ?", role.GUID).
+		Delete(model.TableRole{}).Error; err != nil {
+		return err
+	}
+	rs.invalidate()
+	return nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/jinzhu/gorm"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/sqlparser"
+	"github.com/sipcapture/homer-app/sqlparser/query"
+	"github.com/sipcapture/homer-app/utils/siplint"
+	"github.com/sipcapture/homer-app/utils/sipparser"
+	"github.com/sipcapture/homer-app/utils/stirshaken"
+)
+
+// rowFilter is a virtual search field which can't be matched in SQL, like stir.* and
+// lint.*. The query only preselects the rows, filterRows decodes and matches them.
+// findRows reads more pages of the query until the limit is filled, up to rowFilterPages.
+type rowFilter struct {
+	field  string
+	values []string
+	negate bool
+}
+
+var rowFilterPrefixes = []string{"stir.", "lint."}
+
+func isRowFilter(field string) bool {
+	for _, prefix := range rowFilterPrefixes {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// rowFilters returns the virtual fields of the search of a SearchObject
+func rowFilters(searchObject *model.SearchObject) []rowFilter {
+
+	filters := []rowFilter{}
+	data, _ := json.Marshal(searchObject.Param.Search)
+	sData, _ := gabs.ParseJSON(data)
+
+	for _, elems := range sData.ChildrenMap() {
+		for _, elem := range elems.Children() {
+			name, _ := elem.S("name").Data().(string)
+			value, _ := elem.S("value").Data().(string)
+
+			if name == "smartinput" {
+				queryA, err := sqlparser.Parse(value)
+				if err != nil {
+					continue
+				}
+				for _, vCond := range queryA.Conditions {
+					operator := query.OperatorString[vCond.Operator]
+					if isRowFilter(vCond.Operand1) {
+						filters = append(filters, rowFilter{field: vCond.Operand1, values: strings.Split(vCond.Operand2, ";"),
+							negate: operator == "!=" || operator == "<>"})
+					}
+				}
+				continue
+			}
+
+			if isRowFilter(name) {
+				value = strings.TrimPrefix(value, "||")
+				filters = append(filters, rowFilter{field: name, values: strings.Split(strings.TrimPrefix(value, "!="), ";"),
+					negate: strings.HasPrefix(value, "!=")})
+			}
+		}
+	}
+
+	return filters
+}
+
+// rowFilterPages is how many pages of the limit a search with virtual fields reads at
+// most, every row of them is decoded
+const rowFilterPages = 10
+
+// findRows returns up to limit rows of the query which keep leaves and which match the
+// virtual fields. With virtual fields, the rows are read page by page by date until the
+// limit is filled, the rows run out or rowFilterPages pages have been read.
+func findRows(db *gorm.DB, filters []rowFilter, limit int, keep func([]model.HepTable) []model.HepTable) []model.HepTable {
+
+	rows := []model.HepTable{}
+	if len(filters) == 0 || limit <= 0 {
+		db.Limit(limit).Find(&rows)
+		return filterRows(filters, keep(rows))
+	}
+
+	db = db.Order("create_date, id")
+	for page := 0; page < rowFilterPages && len(rows) < limit; page++ {
+		pageRows := []model.HepTable{}
+		db.Offset(page * limit).Limit(limit).Find(&pageRows)
+		found := len(pageRows)
+		rows = append(rows, filterRows(filters, keep(pageRows))...)
+		if found < limit {
+			break
+		}
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// filterRows keeps the rows matching all the virtual fields
+func filterRows(filters []rowFilter, rows []model.HepTable) []model.HepTable {
+
+	if len(filters) == 0 {
+		return rows
+	}
+
+	filtered := rows[:0]
+	for _, row := range rows {
+		var stir *stirshaken.Result
+		var findings []siplint.Finding
+
+		match := true
+		for _, filter := range filters {
+			matched := false
+			switch {
+			case strings.HasPrefix(filter.field, "stir."):
+				if stir == nil {
+					if stir = stirCheck(row.Raw, row.CreatedDate); stir == nil {
+						stir = &stirshaken.Result{Status: stirshaken.StatusNone}
+					}
+				}
+				matched = stir.Match(filter.field, filter.values)
+			case strings.HasPrefix(filter.field, "lint."):
+				if findings == nil {
+					findings = siplint.LintMessage(siplint.Message{ID: float64(row.Id), Sip: sipparser.ParseMsg(row.Raw, nil, nil)})
+				}
+				matched = lintMatch(findings, filter.field, filter.values)
+			}
+			if matched == filter.negate {
+				match = false
+				break
+			}
+		}
+		if match {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered
+}
+
+// lintMatch tells whether one of the findings has one of the severities or rules
+func lintMatch(findings []siplint.Finding, field string, values []string) bool {
+
+	for _, f := range findings {
+		for _, val := range values {
+			val = strings.TrimSpace(val)
+			if (field == "lint.severity" && strings.EqualFold(f.Severity, val)) || (field == "lint.rule" && f.Rule == val) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -2,33 +2,32 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net"
-	"os"
-	"os/exec"
-	"reflect"
 	"sort"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/Jeffail/gabs/v2"
 	"github.com/dop251/goja"
-	"github.com/jinzhu/gorm"
 	"github.com/shomali11/util/xconditions"
 	"github.com/sipcapture/homer-app/config"
 	"github.com/sipcapture/homer-app/model"
 	"github.com/sipcapture/homer-app/sqlparser"
 	"github.com/sipcapture/homer-app/sqlparser/query"
+	"github.com/sipcapture/homer-app/utils/alias"
+	"github.com/sipcapture/homer-app/utils/decoder"
 	"github.com/sipcapture/homer-app/utils/exportwriter"
 	"github.com/sipcapture/homer-app/utils/heputils"
 	"github.com/sipcapture/homer-app/utils/logger"
 	"github.com/sipcapture/homer-app/utils/logger/function"
+	"github.com/sipcapture/homer-app/utils/siplint"
 	"github.com/sipcapture/homer-app/utils/sipparser"
+	"github.com/sipcapture/homer-app/utils/stirshaken"
+	"github.com/sipcapture/homer-app/utils/tenant"
 )
 
 //search Service
@@ -36,16 +35,6 @@ type SearchService struct {
 	ServiceData
 }
 
-//external decoder
-type ExternalDecoder struct {
-	Binary    string   `json:"binary"`
-	Param     string   `json:"param"`
-	Protocols []string `json:"protocols"`
-	UID       uint32   `json:"uid"`
-	GID       uint32   `json:"gid"`
-	Active    bool     `json:"active"`
-}
-
 func executeJSInputFunction(jsString string, callIds []interface{}) []interface{} {
 
 	vm := goja.New()
@@ -118,7 +107,26 @@ const (
 	RESET  = 10
 )
 
-func buildQuery(elems []interface{}, orLogic bool, mappingJSON json.RawMessage, element int) (sql string, sLimit int, dataValueArray []interface{}) {
+// sdpCondition matches the virtual sdp.* fields against the SDP in the raw message,
+// values are separated by ';'
+func sdpCondition(field, value string, negate bool) (string, []interface{}) {
+
+	pattern, ok := sipparser.SdpFieldPattern(field, strings.Split(value, ";"))
+	if !ok {
+		logger.Error("bad sdp search field: ", field, ", value: ", value)
+		if negate {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+
+	if negate {
+		return "raw !~* ?", []interface{}{pattern}
+	}
+	return "raw ~* ?", []interface{}{pattern}
+}
+
+func buildQuery(elems []interface{}, orLogic bool, mappingJSON json.RawMessage, element int, aliases *alias.Resolver) (sql string, sLimit int, dataValueArray []interface{}) {
 	sLimit = 200
 
 	smartMap := make(map[string]model.MappingSmart)
@@ -185,7 +193,22 @@ func buildQuery(elems []interface{}, orLogic bool, mappingJSON json.RawMessage,
 						typeValue = modSmart.Type
 					}
 
-					if strings.Contains(operandField, ".") {
+					if strings.HasPrefix(operandField, "sdp.") {
+						sdpSQL, sdpValues := sdpCondition(operandField, operandValue, operator == "!=" || operator == "<>")
+						sql += sdpSQL
+						dataValueArray = append(dataValueArray, sdpValues...)
+					} else if strings.HasPrefix(operandField, "stir.") {
+						stirSQL, stirValues := stirCondition(operandField, operandValue, operator == "!=" || operator == "<>")
+						sql += stirSQL
+						dataValueArray = append(dataValueArray, stirValues...)
+					} else if strings.HasPrefix(operandField, "alias.") {
+						aliasSQL, aliasValues := aliasCondition(aliases, operandField, operandValue, operator == "!=" || operator == "<>")
+						sql += aliasSQL
+						dataValueArray = append(dataValueArray, aliasValues...)
+					} else if strings.HasPrefix(operandField, "lint.") {
+						/* matched by filterRows */
+						sql += "TRUE"
+					} else if strings.Contains(operandField, ".") {
 						elemArray := strings.Split(operandField, ".")
 						if typeValue == "integer" {
 							sql += fmt.Sprintf("(%s->>'%s')::int %s ? ", elemArray[0], elemArray[1], operator)
@@ -270,6 +293,24 @@ func buildQuery(elems []interface{}, orLogic bool, mappingJSON json.RawMessage,
 			} else if formName == "raw" {
 				sql = sql + operator + formName + notStr + " ILIKE '" + heputils.Sanitize(formValue) + "'"
 				continue
+			} else if strings.HasPrefix(formName, "sdp.") {
+				sdpSQL, sdpValues := sdpCondition(formName, strings.TrimPrefix(formValue, "!="), notStr != "")
+				sql = sql + operator + sdpSQL
+				dataValueArray = append(dataValueArray, sdpValues...)
+				continue
+			} else if strings.HasPrefix(formName, "stir.") {
+				stirSQL, stirValues := stirCondition(formName, strings.TrimPrefix(formValue, "!="), notStr != "")
+				sql = sql + operator + stirSQL
+				dataValueArray = append(dataValueArray, stirValues...)
+				continue
+			} else if strings.HasPrefix(formName, "alias.") {
+				aliasSQL, aliasValues := aliasCondition(aliases, formName, strings.TrimPrefix(formValue, "!="), notStr != "")
+				sql = sql + operator + aliasSQL
+				dataValueArray = append(dataValueArray, aliasValues...)
+				continue
+			} else if strings.HasPrefix(formName, "lint.") {
+				sql = sql + operator + "TRUE"
+				continue
 			}
 
 			var valueArray []string
@@ -354,66 +395,70 @@ func buildQuery(elems []interface{}, orLogic bool, mappingJSON json.RawMessage,
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *SearchService) SearchData(searchObject *model.SearchObject, aliasData map[string]string,
-	userGroup string, mapsFieldsData map[string]json.RawMessage) (string, error) {
-	table := "hep_proto_1_default"
-	searchData := []model.HepTable{}
-	searchFromTime := time.Unix(searchObject.Timestamp.From/int64(time.Microsecond), 0)
-	searchToTime := time.Unix(searchObject.Timestamp.To/int64(time.Microsecond), 0)
+// searchQuery turns the filter of a SearchObject into a where clause. The clause starts with
+// " AND" and is meant to follow the time range.
+// The filter of the tenant is not part of it, see tenantScope.
+func searchQuery(searchObject *model.SearchObject, aliases *alias.Resolver,
+	mapsFieldsData map[string]json.RawMessage) (table string, sql string, dataArrayValues []interface{}, sLimit int) {
+
+	table = "hep_proto_1_default"
 	Data, _ := json.Marshal(searchObject.Param.Search)
 	sData, _ := gabs.ParseJSON(Data)
-	sql := "create_date between ? AND ?"
-	dataArrayExtraValues := []interface{}{}
-	dataArrayValues := []interface{}{}
-
-	logger.Debug("ISOLATEGROUP ", config.Setting.MAIN_SETTINGS.IsolateGroup)
-	logger.Debug("USERGROUP ", userGroup)
-
-	if config.Setting.MAIN_SETTINGS.IsolateGroup != "" && config.Setting.MAIN_SETTINGS.IsolateGroup == userGroup {
-		sql = sql + " AND " + config.Setting.MAIN_SETTINGS.IsolateQuery
-	}
-
-	var sLimit int
-	dataArrayValues = append(dataArrayValues, searchFromTime)
-	dataArrayValues = append(dataArrayValues, searchToTime)
+	dataArrayValues = []interface{}{}
 
 	for key, _ := range sData.ChildrenMap() {
 		table = "hep_proto_" + key
 		if sData.Exists(key) {
 			elems := sData.Search(key).Data().([]interface{})
 			mappingJSON := mapsFieldsData[key]
-			s, l, dArray := buildQuery(elems, searchObject.Param.OrLogic, mappingJSON, len(dataArrayExtraValues))
-			dataArrayExtraValues = append(dataArrayExtraValues, dArray...)
+			s, l, dArray := buildQuery(elems, searchObject.Param.OrLogic, mappingJSON, len(dataArrayValues), aliases)
+			dataArrayValues = append(dataArrayValues, dArray...)
 			sql += s
 			sLimit = l
 		}
 	}
 
+	return table, sql, dataArrayValues, sLimit
+}
+
+func (ss *SearchService) SearchData(searchObject *model.SearchObject, aliases *alias.Resolver,
+	scope *tenant.Filter, mapsFieldsData map[string]json.RawMessage) (string, error) {
+	searchData := []model.HepTable{}
+	searchFromTime := time.Unix(searchObject.Timestamp.From/int64(time.Microsecond), 0)
+	searchToTime := time.Unix(searchObject.Timestamp.To/int64(time.Microsecond), 0)
+
+	table, sqlWhere, dataArrayExtraValues, sLimit := searchQuery(searchObject, aliases, mapsFieldsData)
+	sql := "create_date between ? AND ?" + sqlWhere
+	dataArrayValues := []interface{}{searchFromTime, searchToTime}
 	dataArrayValues = append(dataArrayValues, dataArrayExtraValues...)
 
+	/* the stir.* and lint.* fields need the decoded messages */
+	filters := rowFilters(searchObject)
+
 	//var searchData
 	for session := range ss.Session {
-		searchTmp := []model.HepTable{}
 
 		/* if node doesnt exists - continue */
-		if !heputils.ElementExists(searchObject.Param.Location.Node, session) {
+		if !heputils.ElementExists(searchObject.Param.Location.Node, session) ||
+			!scope.AllowsNode(session) || !scope.AllowsTable(table) {
 			continue
 		}
 
-		ss.Session[session].Debug().
+		db := ss.Session[session].Debug().
 			Table(table).
 			Where(sql, dataArrayValues...).
-			Limit(sLimit).
-			Find(&searchTmp)
+			Scopes(tenantScope(scope))
 
-		if len(searchTmp) > 0 {
-			for val := range searchTmp {
-				searchTmp[val].Node = session
-				searchTmp[val].DBNode = session
+		node := session
+		searchTmp := findRows(db, filters, sLimit, func(rows []model.HepTable) []model.HepTable {
+			for val := range rows {
+				rows[val].Node = node
+				rows[val].DBNode = node
 			}
+			return tenantRows(scope, table, rows)
+		})
 
-			searchData = append(searchData, searchTmp...)
-		}
+		searchData = append(searchData, searchTmp...)
 	}
 
 	/* lets sort it */
@@ -421,11 +466,34 @@ func (ss *SearchService) SearchData(searchObject *model.SearchObject, aliasData
 		return searchData[i].CreatedDate.Before(searchData[j].CreatedDate)
 	})
 
+	dataReply := formatSearchRows(table, searchData, aliases)
+
+	dataKeys := gabs.Wrap([]interface{}{})
+	for _, v := range dataReply.Children() {
+		for key := range v.ChildrenMap() {
+			if !function.ArrayKeyExits(key, dataKeys) {
+				dataKeys.ArrayAppend(key)
+			}
+		}
+	}
+
+	total, _ := dataReply.ArrayCount()
+
+	reply := gabs.New()
+	reply.Set(total, "total")
+	reply.Set(dataReply.Data(), "data")
+	reply.Set(dataKeys.Data(), "keys")
+
+	return reply.String(), nil
+}
+
+// formatSearchRows flattens the rows the way the search reply has them and adds the aliases
+func formatSearchRows(table string, searchData []model.HepTable, aliases *alias.Resolver) *gabs.Container {
+
 	rows, _ := json.Marshal(searchData)
 	data, _ := gabs.ParseJSON(rows)
 	dataReply := gabs.Wrap([]interface{}{})
 	for _, value := range data.Children() {
-		alias := gabs.New()
 		dataElement := gabs.New()
 		for k, v := range value.ChildrenMap() {
 			switch k {
@@ -461,83 +529,28 @@ func (ss *SearchService) SearchData(searchObject *model.SearchObject, aliasData
 			}
 		}
 
-		srcPort, dstPort := "0", "0"
+		srcPort, dstPort := 0, 0
 
 		if dataElement.Exists("srcPort") {
-			srcPort = strconv.FormatFloat(dataElement.S("srcPort").Data().(float64), 'f', 0, 64)
+			srcPort = int(dataElement.S("srcPort").Data().(float64))
 		}
 
 		if dataElement.Exists("dstPort") {
-			dstPort = strconv.FormatFloat(dataElement.S("dstPort").Data().(float64), 'f', 0, 64)
+			dstPort = int(dataElement.S("dstPort").Data().(float64))
 		}
 
 		srcIP := dataElement.S("srcIp").Data().(string)
 		dstIP := dataElement.S("dstIp").Data().(string)
+		captureID, _ := dataElement.S("captureId").Data().(string)
 
-		srcIPPort := srcIP + ":" + srcPort
-		dstIPPort := dstIP + ":" + dstPort
-		srcIPPortZero := srcIP + ":" + "0"
-		dstIPPortZero := dstIP + ":" + "0"
-
-		testInput := net.ParseIP(srcIP)
-		if testInput.To4() == nil && testInput.To16() != nil {
-			srcIPPort = "[" + srcIP + "]:" + srcPort
-			srcIPPortZero = "[" + srcIP + "]:" + "0"
-		}
-
-		testInput = net.ParseIP(dstIP)
-		if testInput.To4() == nil && testInput.To16() != nil {
-			dstIPPort = "[" + dstIP + "]:" + dstPort
-			dstIPPortZero = "[" + dstIP + "]:" + "0"
-
-		}
-
-		//add capture ID
-		if config.Setting.MAIN_SETTINGS.UseCaptureIDInAlias && dataElement.Exists("captureId") {
-
-			captureID := dataElement.S("captureId").Data().(string)
-
-			if value, ok := aliasData[srcIPPort+":"+captureID]; ok {
-				alias.Set(value, srcIPPort)
-			} else if value, ok := aliasData[srcIPPortZero+":"+captureID]; ok {
-				alias.Set(value, srcIPPort)
-			}
-
-			if value, ok := aliasData[dstIPPort+":"+captureID]; ok {
-				alias.Set(value, dstIPPort)
-			} else if value, ok := aliasData[dstIPPortZero+":"+captureID]; ok {
-				alias.Set(value, dstIPPort)
-			}
+		dataElement.Set(aliases.Name(srcIP, srcPort, captureID), "aliasSrc")
+		dataElement.Set(aliases.Name(dstIP, dstPort, captureID), "aliasDst")
+		if geo := geoLookup(srcIP); geo != nil {
+			dataElement.Set(geo, "srcGeo")
 		}
-
-		if !alias.Exists(srcIPPort) {
-
-			if value, ok := aliasData[srcIPPort]; ok {
-				alias.Set(value, srcIPPort)
-			} else if value, ok := aliasData[srcIPPortZero]; ok {
-				alias.Set(value, srcIPPort)
-			}
+		if geo := geoLookup(dstIP); geo != nil {
+			dataElement.Set(geo, "dstGeo")
 		}
-
-		if !alias.Exists(dstIPPort) {
-
-			if value, ok := aliasData[dstIPPort]; ok {
-				alias.Set(value, dstIPPort)
-			} else if value, ok := aliasData[dstIPPortZero]; ok {
-				alias.Set(value, dstIPPort)
-			}
-		}
-
-		if !alias.Exists(srcIPPort) {
-			alias.Set(srcIPPort, srcIPPort)
-		}
-
-		if !alias.Exists(dstIPPort) {
-			alias.Set(dstIPPort, dstIPPort)
-		}
-
-		dataElement.Set(alias.Search(srcIPPort).Data(), "aliasSrc")
-		dataElement.Set(alias.Search(dstIPPort).Data(), "aliasDst")
 		dataElement.Set(table, "table")
 
 		createDate := int64(dataElement.S("timeSeconds").Data().(float64)*1000000 + dataElement.S("timeUseconds").Data().(float64))
@@ -554,23 +567,7 @@ func (ss *SearchService) SearchData(searchObject *model.SearchObject, aliasData
 		}
 	}
 
-	dataKeys := gabs.Wrap([]interface{}{})
-	for _, v := range dataReply.Children() {
-		for key := range v.ChildrenMap() {
-			if !function.ArrayKeyExits(key, dataKeys) {
-				dataKeys.ArrayAppend(key)
-			}
-		}
-	}
-
-	total, _ := dataReply.ArrayCount()
-
-	reply := gabs.New()
-	reply.Set(total, "total")
-	reply.Set(dataReply.Data(), "data")
-	reply.Set(dataKeys.Data(), "keys")
-
-	return reply.String(), nil
+	return dataReply
 }
 
 // this method create new user in the database
@@ -586,7 +583,7 @@ func (ss *SearchService) GetDBNodeList(searchObject *model.SearchObject) (string
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *SearchService) GetDecodedMessageByID(searchObject *model.SearchObject) (string, error) {
+func (ss *SearchService) GetDecodedMessageByID(ctx context.Context, searchObject *model.SearchObject, scope *tenant.Filter) (string, error) {
 	table := "hep_proto_1_default"
 	sLimit := searchObject.Param.Limit
 	searchData := []model.HepTable{}
@@ -595,7 +592,6 @@ func (ss *SearchService) GetDecodedMessageByID(searchObject *model.SearchObject)
 	Data, _ := json.Marshal(searchObject.Param.Search)
 	sData, _ := gabs.ParseJSON(Data)
 	sql := "create_date between ? and ?"
-	var doDecode = false
 
 	for key := range sData.ChildrenMap() {
 		table = "hep_proto_" + key
@@ -620,18 +616,14 @@ func (ss *SearchService) GetDecodedMessageByID(searchObject *model.SearchObject)
 				}
 				sql = sql + " AND " + fmt.Sprintf("%s IN (%s)", "id", strings.Join(keyData[:], ","))
 			}
-
-			/* check if we have to decode */
-			if ss.Decoder.Active && heputils.ItemExists(ss.Decoder.Protocols, key) {
-				doDecode = true
-			}
 		}
 	}
 
 	for session := range ss.Session {
 
 		/* if node doesnt exists - continue */
-		if !heputils.ElementExists(searchObject.Param.Location.Node, session) {
+		if !heputils.ElementExists(searchObject.Param.Location.Node, session) ||
+			!scope.AllowsNode(session) || !scope.AllowsTable(table) {
 			continue
 		}
 
@@ -639,6 +631,7 @@ func (ss *SearchService) GetDecodedMessageByID(searchObject *model.SearchObject)
 		ss.Session[session].Debug().
 			Table(table).
 			Where(sql, searchFromTime, searchToTime).
+			Scopes(tenantScope(scope)).
 			Limit(sLimit).
 			Find(&searchTmp)
 
@@ -651,6 +644,7 @@ func (ss *SearchService) GetDecodedMessageByID(searchObject *model.SearchObject)
 			searchData = append(searchData, searchTmp...)
 		}
 	}
+	searchData = tenantRows(scope, table, searchData)
 
 	rows, _ := json.Marshal(searchData)
 	data, _ := gabs.ParseJSON(rows)
@@ -661,11 +655,26 @@ func (ss *SearchService) GetDecodedMessageByID(searchObject *model.SearchObject)
 		for k := range value.ChildrenMap() {
 			switch k {
 			case "raw":
-				/* doing sipIsup extraction */
-				if doDecode {
-					if decodedData, err := ss.excuteExternalDecoder(value); err == nil {
-						newData.Set(decodedData, "decoded")
+				/* native decoders first, the external one is the fallback */
+				node, _ := value.S("dbnode").Data().(string)
+				rec := decoder.NewRecord(node, table, value)
+				result, err := ss.Decoders.Decode(ctx, rec)
+				if ctx.Err() != nil {
+					/* the client has gone, the other messages aren't decoded */
+					return "", ctx.Err()
+				}
+				if err != nil {
+					if err != decoder.ErrNoDecoder {
+						logger.Error("decoding failed: ", err)
 					}
+					continue
+				}
+				for name, section := range result.Sections {
+					newData.Set(section, name)
+				}
+				newData.Set(result.Decoder, "decoder")
+				if sipMsg, ok := result.Sections["sip"].(*sipparser.SipMsg); ok && len(sipMsg.Identity) > 0 {
+					newData.Set(stirCheck(rec.Raw, rec.Date), "stir")
 				}
 			}
 		}
@@ -692,7 +701,7 @@ func (ss *SearchService) GetDecodedMessageByID(searchObject *model.SearchObject)
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *SearchService) GetMessageByID(searchObject *model.SearchObject) (string, error) {
+func (ss *SearchService) GetMessageByID(searchObject *model.SearchObject, scope *tenant.Filter) (string, error) {
 	table := "hep_proto_1_default"
 	sLimit := searchObject.Param.Limit
 	searchData := []model.HepTable{}
@@ -733,7 +742,8 @@ func (ss *SearchService) GetMessageByID(searchObject *model.SearchObject) (strin
 
 	for session := range ss.Session {
 		/* if node doesnt exists - continue */
-		if !heputils.ElementExists(searchObject.Param.Location.Node, session) {
+		if !heputils.ElementExists(searchObject.Param.Location.Node, session) ||
+			!scope.AllowsNode(session) || !scope.AllowsTable(table) {
 			continue
 		}
 
@@ -741,6 +751,7 @@ func (ss *SearchService) GetMessageByID(searchObject *model.SearchObject) (strin
 		ss.Session[session].Debug().
 			Table(table).
 			Where(sql, searchFromTime, searchToTime).
+			Scopes(tenantScope(scope)).
 			Limit(sLimit).
 			Find(&searchTmp)
 
@@ -753,6 +764,7 @@ func (ss *SearchService) GetMessageByID(searchObject *model.SearchObject) (strin
 			searchData = append(searchData, searchTmp...)
 		}
 	}
+	searchData = tenantRows(scope, table, searchData)
 
 	rows, _ := json.Marshal(searchData)
 	data, _ := gabs.ParseJSON(rows)
@@ -770,10 +782,10 @@ func (ss *SearchService) GetMessageByID(searchObject *model.SearchObject) (strin
 
 			case "raw":
 				newData := gabs.New()
-				/* doing sipIsup extraction */
+				/* binary bodies like SIP-I ISUP are shown as hex */
 				if sipExist {
 					rawElement := fmt.Sprintf("%v", v.Data().(interface{}))
-					newData.Set(heputils.IsupToHex(rawElement), k)
+					newData.Set(sipparser.ParseMsgLazy(rawElement, "Content-Type").PrintableMsg(), k)
 				} else {
 					newData.Set(v.Data().(interface{}), k)
 				}
@@ -816,101 +828,11 @@ func (ss *SearchService) GetMessageByID(searchObject *model.SearchObject) (strin
 	return reply.String(), nil
 }
 
-//this method create new user in the database
-//it doesn't check internally whether all the validation are applied or not
-func (ss *SearchService) excuteExternalDecoder(dataRecord *gabs.Container) (interface{}, error) {
-
-	if ss.Decoder.Active {
-		logger.Debug("Trying to debug using external decoder")
-		logger.Debug(fmt.Sprintf("Decoder to [%s, %s, %v]\n", ss.Decoder.Binary, ss.Decoder.Param, ss.Decoder.Protocols))
-		//cmd := exec.Command(ss.Decoder.Binary, ss.Decoder.Param)
-		var buffer bytes.Buffer
-		export := exportwriter.NewWriter(buffer)
-		var rootExecute = false
-
-		// pcap export
-		if err := export.WritePcapHeader(65536, 1); err != nil {
-			logger.Error("write error to the pcap header", err)
-			return nil, err
-		}
-
-		if err := export.WriteDataPcapBuffer(dataRecord); err != nil {
-			logger.Error("write error to the pcap buffer", err)
-			return nil, err
-		}
-
-		cmd := exec.Command(ss.Decoder.Binary, "-Q", "-T", "json", "-l", "-i", "-", ss.Decoder.Param)
-
-		/*check if we root under root - changing to an user */
-		uid, gid := os.Getuid(), os.Getgid()
-
-		if uid == 0 || gid == 0 {
-			logger.Info("running under root/wheel: UID: [%d], GID: [%d] - [%d] - [%d]. Changing to user...", uid, gid, ss.Decoder.UID, ss.Decoder.GID)
-			if ss.Decoder.UID != 0 && ss.Decoder.GID != 0 {
-				logger.Info("Changing to: UID: [%d], GID: [%d]", uid, gid)
-				cmd.SysProcAttr = &syscall.SysProcAttr{
-					Credential: &syscall.Credential{
-						Uid: ss.Decoder.UID, Gid: ss.Decoder.GID,
-						NoSetGroups: true,
-					},
-				}
-			} else {
-				logger.Error("You run external decoder under root! Please set UID/GID in the config")
-				rootExecute = true
-			}
-		}
-
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			logger.Error("Bad cmd stdin", err)
-			return nil, err
-		}
-		go func() {
-			defer stdin.Close()
-			io.WriteString(stdin, export.Buffer.String())
-			return
-		}()
-
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			logger.Error("Bad combined output: ", err)
-			return nil, err
-		}
-
-		var skipElement = 0
-
-		/* this is fix if you run the webapp under root */
-		if rootExecute {
-			/* limit search String */
-			maxEl := len(out)
-			if maxEl > 100 {
-				maxEl = 100
-			}
-			for i := 0; i < maxEl; i++ {
-				if string(out[i]) == "[" || string(out[i]) == "{" {
-					skipElement = i
-					break
-				}
-			}
-		}
-
-		sData, err := gabs.ParseJSON(out[skipElement:])
-		if err != nil {
-			logger.Error("bad json", err)
-			return nil, err
-		}
-
-		return sData.Data(), nil
-	}
-
-	return nil, errors.New("decoder not active. You should not be here")
-}
-
 //this method create new user in the database
 //it doesn't check internally whether all the validation are applied or not
 func (ss *SearchService) GetTransaction(table string, data []byte, correlationJSON []byte, doexp bool,
-	aliasData map[string]string, typeReport int, nodes []string, settingService *UserSettingsService,
-	userGroup string, whitelist []string) (string, error) {
+	aliases *alias.Resolver, typeReport int, nodes []string, settingService *UserSettingsService, partid int,
+	scope *tenant.Filter, whitelist []string) (string, error) {
 	var dataWhere []interface{}
 	requestData, _ := gabs.ParseJSON(data)
 	for key, value := range requestData.Search("param", "search").ChildrenMap() {
@@ -923,7 +845,7 @@ func (ss *SearchService) GetTransaction(table string, data []byte, correlationJS
 	timeFrom := time.Unix(int64(timeWhereFrom/float64(time.Microsecond)), 0).UTC()
 	timeTo := time.Unix(int64(timeWhereTo/float64(time.Microsecond)), 0).UTC()
 
-	dataRow, _ := ss.GetTransactionData(table, "sid", dataWhere, timeFrom, timeTo, nodes, userGroup, false, whitelist)
+	dataRow, _ := ss.GetTransactionData(table, "sid", dataWhere, timeFrom, timeTo, nodes, scope, false, whitelist)
 	marshalData, _ := json.Marshal(dataRow)
 
 	jsonParsed, _ := gabs.ParseJSON(marshalData)
@@ -1005,7 +927,7 @@ func (ss *SearchService) GetTransaction(table string, data []byte, correlationJS
 			if corrs.Exists("input_script") {
 				inputScript := corrs.Search("input_script").Data().(string)
 				logger.Debug("Input function: ", inputScript)
-				dataScript, err := settingService.GetScriptByParam("scripts", inputScript)
+				dataScript, err := settingService.GetScriptByParam("scripts", inputScript, partid)
 				if err == nil {
 					scriptNew, _ := strconv.Unquote(dataScript)
 					logger.Debug("OUR script:", scriptNew)
@@ -1027,7 +949,7 @@ func (ss *SearchService) GetTransaction(table string, data []byte, correlationJS
 				likeSearch = true
 			}
 
-			newDataRow, _ := ss.GetTransactionData(table, lookupField, newWhereData, from, to, nodes, userGroup, likeSearch, whitelist)
+			newDataRow, _ := ss.GetTransactionData(table, lookupField, newWhereData, from, to, nodes, scope, likeSearch, whitelist)
 			if corrs.Exists("append_sid") && corrs.Search("append_sid").Data().(bool) {
 				marshalData, _ = json.Marshal(newDataRow)
 				jsonParsed, _ = gabs.ParseJSON(marshalData)
@@ -1063,7 +985,7 @@ func (ss *SearchService) GetTransaction(table string, data []byte, correlationJS
 			if corrs.Exists("output_script") {
 				outputScript := corrs.Search("output_script").Data().(string)
 				logger.Debug("Output function: ", outputScript)
-				dataScript, err := settingService.GetScriptByParam("scripts", outputScript)
+				dataScript, err := settingService.GetScriptByParam("scripts", outputScript, partid)
 				if err == nil {
 					scriptNew, _ := strconv.Unquote(dataScript)
 					logger.Debug("OUR script:", scriptNew)
@@ -1090,12 +1012,13 @@ func (ss *SearchService) GetTransaction(table string, data []byte, correlationJS
 	jsonParsed, _ = gabs.ParseJSON(marshalData)
 
 	if typeReport == 0 {
-		reply := ss.getTransactionSummary(jsonParsed, aliasData)
+		reply := ss.getTransactionSummary(jsonParsed, aliases)
 		return reply, nil
 	} else {
 
 		var buffer bytes.Buffer
 		export := exportwriter.NewWriter(buffer)
+		export.Aliases = aliases
 
 		// pcap export
 		if typeReport == 1 {
@@ -1161,7 +1084,7 @@ func uniqueHepTable(hepSlice []model.HepTable) []model.HepTable {
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
 func (ss *SearchService) GetTransactionData(table string, fieldKey string, dataWhere []interface{}, timeFrom,
-	timeTo time.Time, nodes []string, userGroup string, likeSearch bool, whitelist []string) ([]model.HepTable, error) {
+	timeTo time.Time, nodes []string, scope *tenant.Filter, likeSearch bool, whitelist []string) ([]model.HepTable, error) {
 
 	searchData := []model.HepTable{}
 	query := "create_date between ? AND ? "
@@ -1172,20 +1095,14 @@ func (ss *SearchService) GetTransactionData(table string, fieldKey string, dataW
 		query += "AND " + fieldKey + " in (?)"
 	}
 
-	logger.Debug("ISOLATEGROUP ", config.Setting.MAIN_SETTINGS.IsolateGroup)
-	logger.Debug("USERGROUP ", userGroup)
-
-	if config.Setting.MAIN_SETTINGS.IsolateGroup != "" && config.Setting.MAIN_SETTINGS.IsolateGroup == userGroup {
-		query = query + " AND " + config.Setting.MAIN_SETTINGS.IsolateQuery
-	}
-
 	for _, ip := range whitelist {
 		query = query + fmt.Sprintf(" AND (protocol_header->>'srcIp' != '%s' AND protocol_header->>'dstIp' != '%s' ) ", ip, ip)
 	}
 
 	for session := range ss.Session {
 		/* if node doesnt exists - continue */
-		if !heputils.ElementExists(nodes, session) {
+		if !heputils.ElementExists(nodes, session) ||
+			!scope.AllowsNode(session) || !scope.AllowsTable(table) {
 			continue
 		}
 
@@ -1193,6 +1110,7 @@ func (ss *SearchService) GetTransactionData(table string, fieldKey string, dataW
 		if err := ss.Session[session].Debug().
 			Table(table).
 			Where(query, timeFrom.Format(time.RFC3339), timeTo.Format(time.RFC3339), dataWhere).
+			Scopes(tenantScope(scope)).
 			Find(&searchTmp).Error; err != nil {
 			logger.Error("GetTransactionData: We have got error: ", err)
 		}
@@ -1211,12 +1129,13 @@ func (ss *SearchService) GetTransactionData(table string, fieldKey string, dataW
 			searchData = append(searchData, searchTmp...)
 		}
 	}
+	searchData = tenantRows(scope, table, searchData)
 
 	//response, _ := json.Marshal(searchData)
 	return searchData, nil
 }
 
-func (ss *SearchService) getTransactionSummary(data *gabs.Container, aliasData map[string]string) string {
+func (ss *SearchService) getTransactionSummary(data *gabs.Container, aliases *alias.Resolver) string {
 
 	var position = 0
 	sid := gabs.New()
@@ -1226,6 +1145,7 @@ func (ss *SearchService) getTransactionSummary(data *gabs.Container, aliasData m
 
 	callData := []model.CallElement{}
 	dataReply := gabs.Wrap([]interface{}{})
+	lintMessages := []siplint.Message{}
 
 	for _, value := range data.Children() {
 		dataElement := gabs.New()
@@ -1347,9 +1267,15 @@ func (ss *SearchService) getTransactionSummary(data *gabs.Container, aliasData m
 
 				str := dataElement.S("raw").Data().(string)
 
-				x := []string{"From", "To"}
-
-				sip := sipparser.ParseMsg(str, x, nil)
+				/* only what the summary shows, transactions can have thousands of messages.
+				   The lint needs all the headers, the message is parsed once in full then. */
+				var sip *sipparser.SipMsg
+				if config.Setting.TRANSACTION_SETTINGS.Lint {
+					sip = sipparser.ParseMsg(str, nil, nil)
+					lintMessages = append(lintMessages, siplint.Message{ID: callElement.ID, Sip: sip})
+				} else {
+					sip = sipparser.ParseMsgLazy(str, "From", "To", "Identity")
+				}
 
 				if !dataElement.Exists("from_domain") && sip.FromHost != "" {
 					dataElement.Set(sip.FromHost, "from_domain")
@@ -1357,6 +1283,25 @@ func (ss *SearchService) getTransactionSummary(data *gabs.Container, aliasData m
 				if !dataElement.Exists("to_domain") && sip.ToHost != "" {
 					dataElement.Set(sip.FromHost, "to_domain")
 				}
+
+				/* STIR/SHAKEN, failures are shown in red */
+				if len(sip.Identity) > 0 {
+					at := time.Time{}
+					if dataElement.Exists("timeSeconds") {
+						at = time.Unix(int64(heputils.CheckFloatValue(dataElement.S("timeSeconds").Data())), 0)
+					}
+					stir := stirCheck(str, at)
+					dataElement.Set(stir.Status, "stir_status")
+					dataElement.Set(stir.Attest, "stir_attest")
+					if stir.Reason != "" {
+						dataElement.Set(stir.Reason, "stir_reason")
+					}
+					callElement.StirStatus = stir.Status
+					callElement.StirAttest = stir.Attest
+					if stir.Status == stirshaken.StatusInvalid || stir.Status == stirshaken.StatusMalformed {
+						callElement.MsgColor = "red"
+					}
+				}
 			}
 		}
 
@@ -1379,56 +1324,20 @@ func (ss *SearchService) getTransactionSummary(data *gabs.Container, aliasData m
 			callElement.DstID = "[" + callElement.DstHost + "]:" + strconv.FormatFloat(callElement.DstPort, 'f', 0, 64)
 		}
 
-		srcIPPortZero := callElement.SrcIP + ":" + strconv.Itoa(0)
-		dstIPPortZero := callElement.DstIP + ":" + strconv.Itoa(0)
-
-		//add capture ID
-		if config.Setting.MAIN_SETTINGS.UseCaptureIDInAlias && dataElement.Exists("captureId") {
-
-			captureID := dataElement.S("captureId").Data().(string)
-
-			if value, ok := aliasData[srcIPPort+":"+captureID]; ok {
-				alias.Set(value, srcIPPort)
-			} else if value, ok := aliasData[srcIPPortZero+":"+captureID]; ok {
-				alias.Set(value, srcIPPort)
-			}
-
-			if value, ok := aliasData[dstIPPort+":"+captureID]; ok {
-				alias.Set(value, dstIPPort)
-			} else if value, ok := aliasData[dstIPPortZero+":"+captureID]; ok {
-				alias.Set(value, dstIPPort)
-			}
-		}
-
-		if !alias.Exists(srcIPPort) {
-
-			if value, ok := aliasData[srcIPPort]; ok {
-				alias.Set(value, srcIPPort)
-			} else if value, ok := aliasData[srcIPPortZero]; ok {
-				alias.Set(value, srcIPPort)
-			}
-		}
-
-		if !alias.Exists(dstIPPort) {
-
-			if value, ok := aliasData[dstIPPort]; ok {
-				alias.Set(value, dstIPPort)
-			} else if value, ok := aliasData[dstIPPortZero]; ok {
-				alias.Set(value, dstIPPort)
-			}
-		}
+		captureID, _ := dataElement.S("captureId").Data().(string)
+		callElement.AliasSrc = aliases.Name(callElement.SrcIP, int(callElement.SrcPort), captureID)
+		callElement.AliasDst = aliases.Name(callElement.DstIP, int(callElement.DstPort), captureID)
+		callElement.SrcGeo = geoLookup(callElement.SrcIP)
+		callElement.DstGeo = geoLookup(callElement.DstIP)
 
 		if !alias.Exists(srcIPPort) {
-			alias.Set(srcIPPort, srcIPPort)
+			alias.Set(callElement.AliasSrc, srcIPPort)
 		}
 
 		if !alias.Exists(dstIPPort) {
-			alias.Set(dstIPPort, dstIPPort)
+			alias.Set(callElement.AliasDst, dstIPPort)
 		}
 
-		callElement.AliasSrc = alias.Search(srcIPPort).Data().(string)
-		callElement.AliasDst = alias.Search(dstIPPort).Data().(string)
-
 		if !host.Exists(callElement.SrcID) {
 			jsonObj := gabs.New()
 			jsonObj.Array(callElement.SrcID, "host")
@@ -1465,13 +1374,16 @@ func (ss *SearchService) getTransactionSummary(data *gabs.Container, aliasData m
 	reply.Set(host.Data(), "data", "hosts")
 	reply.Set(callData, "data", "calldata")
 	reply.Set(alias.Data(), "data", "alias")
+	if config.Setting.TRANSACTION_SETTINGS.Lint {
+		reply.Set(siplint.LintDialog(lintMessages), "data", "lint")
+	}
 	reply.Set(dataKeys.Data(), "keys")
 	return reply.String()
 }
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *SearchService) GetTransactionQos(tables [2]string, data []byte, nodes []string) (string, error) {
+func (ss *SearchService) GetTransactionQos(tables [2]string, data []byte, nodes []string, scope *tenant.Filter) (string, error) {
 
 	var dataWhere []interface{}
 	sid := gabs.New()
@@ -1495,7 +1407,8 @@ func (ss *SearchService) GetTransactionQos(tables [2]string, data []byte, nodes
 
 		for session := range ss.Session {
 			/* if node doesnt exists - continue */
-			if !heputils.ElementExists(nodes, session) {
+			if !heputils.ElementExists(nodes, session) ||
+				!scope.AllowsNode(session) || !scope.AllowsTable(table) {
 				continue
 			}
 
@@ -1503,6 +1416,7 @@ func (ss *SearchService) GetTransactionQos(tables [2]string, data []byte, nodes
 			if err := ss.Session[session].Debug().
 				Table(table).
 				Where(query, dataWhere, timeFrom.Format(time.RFC3339), timeTo.Format(time.RFC3339)).
+				Scopes(tenantScope(scope)).
 				Find(&searchTmp).Error; err != nil {
 				logger.Error("GetTransactionQos: We have got error: ", err)
 				return "", err
@@ -1517,6 +1431,7 @@ func (ss *SearchService) GetTransactionQos(tables [2]string, data []byte, nodes
 				searchData = append(searchData, searchTmp...)
 			}
 		}
+		searchData = tenantRows(scope, table, searchData)
 
 		/* lets sort it */
 		sort.Slice(searchData, func(i, j int) bool {
@@ -1557,7 +1472,7 @@ func (ss *SearchService) GetTransactionQos(tables [2]string, data []byte, nodes
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *SearchService) GetTransactionLog(table string, data []byte, nodes []string) (string, error) {
+func (ss *SearchService) GetTransactionLog(table string, data []byte, nodes []string, scope *tenant.Filter) (string, error) {
 
 	var dataWhere []interface{}
 	sid := gabs.New()
@@ -1577,13 +1492,15 @@ func (ss *SearchService) GetTransactionLog(table string, data []byte, nodes []st
 	query := "sid in (?) and create_date between ? and ?"
 	for session := range ss.Session {
 		/* if node doesnt exists - continue */
-		if !heputils.ElementExists(nodes, session) {
+		if !heputils.ElementExists(nodes, session) ||
+			!scope.AllowsNode(session) || !scope.AllowsTable(table) {
 			continue
 		}
 		searchTmp := []model.HepTable{}
 		if err := ss.Session[session].Debug().
 			Table(table).
 			Where(query, dataWhere, timeFrom.Format(time.RFC3339), timeTo.Format(time.RFC3339)).
+			Scopes(tenantScope(scope)).
 			Find(&searchTmp).Error; err != nil {
 			logger.Error("GetTransactionLog: We have got error: ", err)
 			return "", err
@@ -1598,6 +1515,7 @@ func (ss *SearchService) GetTransactionLog(table string, data []byte, nodes []st
 			searchData = append(searchData, searchTmp...)
 		}
 	}
+	searchData = tenantRows(scope, table, searchData)
 
 	response, _ := json.Marshal(searchData)
 	row, _ := gabs.ParseJSON(response)
@@ -1627,215 +1545,3 @@ func (ss *SearchService) GetTransactionLog(table string, data []byte, nodes []st
 	reply.Set(dataReply.Data(), "data")
 	return reply.String(), nil
 }
-
-// this method create new user in the database
-// it doesn't check internally whether all the validation are applied or not
-func (ss *SearchService) ImportPcapData(buf *bytes.Buffer, now bool) (int, int, error) {
-
-	if config.Setting.DECODER_SHARK.Enable {
-		logger.Debug("Decoded KEY")
-		logger.Debug("Trying to debug using external decoder")
-		logger.Debug(fmt.Sprintf("Decoder to [%s, %s, %v]\n", config.Setting.DECODER_SHARK.Bin, config.Setting.DECODER_SHARK.Param, config.Setting.DECODER_SHARK.Protocols))
-		rootExecute := false
-		cmd := exec.Command(config.Setting.DECODER_SHARK.Bin, "-Q", "-T", "json", "-o", "rtp.heuristic_rtp:TRUE", "-l", "-i", "-", config.Setting.DECODER_SHARK.Param)
-		/*check if we root under root - changing to an user */
-		uid, gid := os.Getuid(), os.Getgid()
-
-		if uid == 0 || gid == 0 {
-			logger.Info(fmt.Sprintf("running under root/wheel: UID: [%d], GID: [%d] - [%d] - [%d]. Changing to user...", uid, gid, config.Setting.DECODER_SHARK.UID, config.Setting.DECODER_SHARK.GID))
-			if config.Setting.DECODER_SHARK.UID != 0 && config.Setting.DECODER_SHARK.GID != 0 {
-				logger.Info(fmt.Sprintf("Changing to: UID: [%d], GID: [%d]", uid, gid))
-				cmd.SysProcAttr = &syscall.SysProcAttr{
-					Credential: &syscall.Credential{
-						Uid: config.Setting.DECODER_SHARK.UID, Gid: config.Setting.DECODER_SHARK.GID,
-						NoSetGroups: true,
-					},
-				}
-			} else {
-				logger.Error("You run external decoder under root! Please set UID/GID in the config")
-				rootExecute = true
-			}
-		}
-
-		stdin, err := cmd.StdinPipe()
-		if err != nil {
-			logger.Error("Bad cmd stdin", err)
-			return 0, 0, err
-		}
-		go func() {
-			defer stdin.Close()
-			io.WriteString(stdin, buf.String())
-		}()
-
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			logger.Error("Bad combined output: ", err)
-			return 0, 0, err
-		}
-
-		var skipElement = 0
-
-		/* this is fix if you run the webapp under root */
-		if rootExecute {
-			/* limit search String */
-			maxEl := len(out)
-			if maxEl > 100 {
-				maxEl = 100
-			}
-			for i := 0; i < maxEl; i++ {
-				if string(out[i]) == "[" || string(out[i]) == "{" {
-					skipElement = i
-					break
-				}
-			}
-		}
-
-		//tmpRawData := model.TableRawData{}
-		//for session := range ss.Session {
-		var session *gorm.DB
-
-		if config.Setting.DECODER_SHARK.ImportNode != "" {
-			if val, ok := ss.Session[config.Setting.DECODER_SHARK.ImportNode]; ok {
-				session = val
-			} else {
-				keys := reflect.ValueOf(ss.Session).MapKeys()
-				session = ss.Session[keys[0].String()]
-			}
-		} else {
-			keys := reflect.ValueOf(ss.Session).MapKeys()
-			session = ss.Session[keys[0].String()]
-		}
-
-		badCounter := 0
-		goodCounter := 0
-		var addTimeDifferent time.Duration
-		addTimeDifferent = 0
-
-		sData, err := gabs.ParseJSON(out[skipElement:])
-		for _, dataElement := range sData.Children() {
-
-			protocolHeader := gabs.New()
-			dataHeader := gabs.New()
-			var ipProto, srcPort, dstPort uint16
-			var sourceIp, destinationIp string
-
-			if dataElement.Exists("_source", "layers") {
-
-				tmpData := model.TableRawData{}
-
-				layerData := dataElement.S("_source", "layers")
-
-				if layerData.Exists("frame", "frame.time_epoch") {
-					timeArray := strings.Split(layerData.S("frame", "frame.time_epoch").Data().(string), ".")
-					timeSec, _ := strconv.ParseInt(timeArray[0], 10, 64)
-					timeUSec, _ := strconv.ParseInt(timeArray[1], 10, 64)
-					unixTimeUTC := time.Unix(timeSec, timeUSec) //gives unix time stamp in utc
-
-					if now {
-						if addTimeDifferent == 0 {
-							tNow := time.Now()
-							addTimeDifferent = tNow.Sub(unixTimeUTC)
-						}
-						unixTimeUTC = unixTimeUTC.Add(addTimeDifferent)
-					}
-
-					tmpData.CreateDate = unixTimeUTC
-				}
-
-				if layerData.Exists("ip", "ip.proto") {
-					val, _ := strconv.ParseUint(layerData.S("ip", "ip.proto").Data().(string), 10, 16)
-					ipProto = uint16(val)
-					protocolHeader.Set(ipProto, "proto")
-				}
-
-				if layerData.Exists("ip", "ip.version") {
-					val := layerData.S("ip", "ip.version").Data().(string)
-					protocolHeader.Set(val, "ip_version")
-				}
-
-				/* Check IP */
-				if layerData.Exists("ip", "ip.src") {
-					val := layerData.S("ip", "ip.src").Data().(string)
-					protocolHeader.Set(val, "srcIp")
-				}
-				if layerData.Exists("ip", "ip.dst") {
-					val := layerData.S("ip", "ip.dst").Data().(string)
-					protocolHeader.Set(val, "dstIp")
-				}
-
-				/* udp */
-				if ipProto == 17 {
-					if layerData.Exists("udp", "udp.srcport") {
-						val, _ := strconv.ParseUint(layerData.S("udp", "udp.srcport").Data().(string), 10, 16)
-						srcPort = uint16(val)
-						protocolHeader.Set(srcPort, "srcPort")
-					}
-					if layerData.Exists("udp", "udp.dstport") {
-						val, _ := strconv.ParseUint(layerData.S("udp", "udp.dstport").Data().(string), 10, 16)
-						dstPort = uint16(val)
-						protocolHeader.Set(dstPort, "dstPort")
-					}
-					/* tcp */
-				} else if ipProto == 5 {
-					if layerData.Exists("tcp", "tcp.srcport") {
-						val, _ := strconv.ParseUint(layerData.S("tcp", "tcp.srcport").Data().(string), 10, 16)
-						srcPort = uint16(val)
-						protocolHeader.Set(srcPort, "srcPort")
-					}
-					if layerData.Exists("tcp", "tcp.dstport") {
-						val, _ := strconv.ParseUint(layerData.S("tcp", "tcp.dstport").Data().(string), 10, 16)
-						dstPort = uint16(val)
-						protocolHeader.Set(dstPort, "dstPort")
-					}
-					/* sctp */
-				} else if ipProto == 132 {
-					if layerData.Exists("sctp", "sctp.srcport") {
-						val, _ := strconv.ParseUint(layerData.S("sctp", "sctp.srcport").Data().(string), 10, 16)
-						srcPort = uint16(val)
-						protocolHeader.Set(srcPort, "srcPort")
-					}
-					if layerData.Exists("sctp", "sctp.dstport") {
-						val, _ := strconv.ParseUint(layerData.S("sctp", "sctp.dstport").Data().(string), 10, 16)
-						dstPort = uint16(val)
-						protocolHeader.Set(dstPort, "dstPort")
-					}
-				}
-
-				hashIPPort := fmt.Sprintf("%s:%d->%s:%d", sourceIp, srcPort, destinationIp, dstPort)
-				tmpData.SID = strconv.FormatUint(uint64(heputils.Hash32(hashIPPort)), 10)
-
-				if layerData.Exists("frame") {
-					frame := layerData.S("frame").Data()
-					data := gabs.New()
-					data.Set(frame, "frame")
-					tmpData.Raw = data.Bytes()
-				}
-
-				dataHeader.Set(tmpData.SID, "callid")
-				dataHeader.Set("event", "method")
-
-				tmpData.ProtocolHeader = protocolHeader.Data().([]byte)
-				tmpData.DataHeader = dataHeader.Data().([]byte)
-
-				db := session.Save(&tmpData)
-				if db != nil && db.Error != nil {
-					logger.Error(fmt.Sprintf("Save failed for table [%s]: with error %s.", tmpData.TableName(), db.Error.Error()))
-				} else {
-					logger.Debug(fmt.Sprintf("Save for table [%s] was success.", tmpData.TableName()))
-				}
-
-				goodCounter++
-			}
-		}
-
-		if err != nil {
-			logger.Error(fmt.Sprintf("Error commmit transaction Error: %s", err.Error()))
-			return goodCounter, badCounter, err
-		}
-
-		//logger.Debug("DDD:", sData)
-		return goodCounter, badCounter, err
-	}
-
-	return 0, 0, fmt.Errorf("tshark has been not enabled")
-}
@@ -5,12 +5,13 @@ import (
 
 	client "github.com/influxdata/influxdb1-client/v2"
 	"github.com/jinzhu/gorm"
+	"github.com/sipcapture/homer-app/utils/decoder"
 )
 
 // Service : here you tell us what Salutation is
 type ServiceData struct {
-	Session map[string]*gorm.DB
-	Decoder ExternalDecoder
+	Session  map[string]*gorm.DB
+	Decoders *decoder.Registry
 }
 
 //ServiceConfig
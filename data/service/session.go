@@ -0,0 +1,291 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/session"
+)
+
+var (
+	// ErrSessionNotFound is returned for a refresh token which is unknown, revoked or expired
+	ErrSessionNotFound = errors.New("the refresh token is unknown or has expired")
+	// ErrSessionReused is returned when a refresh token is used again, the session is revoked
+	ErrSessionReused = errors.New("the refresh token has already been used, the session has been revoked")
+)
+
+type SessionService struct {
+	ServiceConfig
+}
+
+// the revoked sessions whose JWTs may not have expired yet, the JWT middleware checks
+// them at every request. They are loaded again every minute, another instance may
+// revoke sessions.
+var revokedSessions struct {
+	sync.Mutex
+	sessions map[string]bool
+	loaded   time.Time
+}
+
+func accessTokenLifetime() time.Duration {
+	return time.Duration(config.Setting.AUTH_SETTINGS.AccessTokenExpire) * time.Minute
+}
+
+func sessionLifetime() time.Duration {
+	return time.Duration(config.Setting.AUTH_SETTINGS.AuthTokenExpire) * time.Minute
+}
+
+// sessionScope selects the active sessions an admin can see: the ones of its partition,
+// of all partitions for partid 0, and the ones of super admins only for a super admin
+func sessionScope(partid int, superAdmin bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("revoke_date IS NULL AND expire_date > ?", time.Now())
+		if partid > 0 {
+			db = db.Where("partid = ?", partid)
+		}
+		if !superAdmin {
+			db = db.Where("superadmin = false")
+		}
+		return db
+	}
+}
+
+// Start opens a session for a user who has logged in. Internal users are loaded again
+// at every refresh, the claims of the others are kept with the session.
+func (ss *SessionService) Start(user model.TableUser, internal bool, ipAddress, userAgent string) (model.UserTokenSuccessfulResponse, error) {
+
+	reply := model.UserTokenSuccessfulResponse{}
+
+	refreshToken, err := session.NewRefreshToken()
+	if err != nil {
+		return reply, err
+	}
+
+	claims := auth.Claims(user, uuid.NewV4().String())
+	userObject, err := json.Marshal(claims)
+	if err != nil {
+		return reply, err
+	}
+
+	now := time.Now()
+	row := model.TableSession{
+		GUID:         claims.Id,
+		UserName:     user.UserName,
+		UserGUID:     user.GUID,
+		PartId:       claims.PartID,
+		SuperAdmin:   claims.SuperAdmin,
+		Internal:     internal,
+		RefreshHash:  session.Hash(refreshToken),
+		UserObject:   userObject,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		CreateDate:   now,
+		LastUsedDate: now,
+		ExpireDate:   now.Add(sessionLifetime()),
+	}
+
+	ss.cleanup()
+	if err := ss.Session.Debug().Create(&row).Error; err != nil {
+		return reply, err
+	}
+
+	return ss.tokens(claims, user.GUID, refreshToken)
+}
+
+// Refresh swaps a refresh token for a new JWT and a new refresh token. A refresh token
+// used a second time has been stolen, the session is revoked for the thief and the user.
+func (ss *SessionService) Refresh(refreshToken, ipAddress, userAgent string) (model.UserTokenSuccessfulResponse, error) {
+
+	reply := model.UserTokenSuccessfulResponse{}
+	hash := session.Hash(refreshToken)
+
+	row := model.TableSession{}
+	if ss.Session.Debug().Where("refresh_hash = ?", hash).Find(&row).RecordNotFound() {
+		if !ss.Session.Debug().Where("previous_hash = ? AND revoke_date IS NULL", hash).Find(&row).RecordNotFound() {
+			logger.Error("reuse of a refresh token of the session ", row.GUID, " of the user ", row.UserName)
+			if _, err := ss.revoke(ss.Session.Where("guid = ?", row.GUID)); err != nil {
+				return reply, err
+			}
+			return reply, ErrSessionReused
+		}
+		return reply, ErrSessionNotFound
+	}
+
+	if row.RevokeDate != nil || row.ExpireDate.Before(time.Now()) {
+		return reply, ErrSessionNotFound
+	}
+
+	claims := &auth.JwtUserClaim{}
+	if row.Internal {
+		userService := UserService{ServiceConfig: ss.ServiceConfig}
+		user, err := userService.InternalUser(row.UserName)
+		if err != nil {
+			/* the user has been deleted */
+			if _, err := ss.revoke(ss.Session.Where("guid = ?", row.GUID)); err != nil {
+				logger.Error("the session can't be revoked: ", err)
+			}
+			return reply, ErrSessionNotFound
+		}
+		claims = auth.Claims(user, row.GUID)
+	} else if err := json.Unmarshal(row.UserObject, claims); err != nil {
+		return reply, err
+	}
+	claims.Id = row.GUID
+
+	newToken, err := session.NewRefreshToken()
+	if err != nil {
+		return reply, err
+	}
+	userObject, err := json.Marshal(claims)
+	if err != nil {
+		return reply, err
+	}
+
+	/* only one refresh wins when the token is sent twice at the same time */
+	now := time.Now()
+	db := ss.Session.Debug().Model(&model.TableSession{}).
+		Where("guid = ? AND refresh_hash = ? AND revoke_date IS NULL", row.GUID, hash).
+		Updates(map[string]interface{}{
+			"refresh_hash":  session.Hash(newToken),
+			"previous_hash": hash,
+			"user_object":   string(userObject),
+			"partid":        claims.PartID,
+			"superadmin":    claims.SuperAdmin,
+			"ip_address":    ipAddress,
+			"user_agent":    userAgent,
+			"lastused_date": now,
+			"expire_date":   now.Add(sessionLifetime()),
+		})
+	if db.Error != nil {
+		return reply, db.Error
+	}
+	if db.RowsAffected == 0 {
+		return reply, ErrSessionNotFound
+	}
+
+	return ss.tokens(claims, row.UserGUID, newToken)
+}
+
+func (ss *SessionService) tokens(claims *auth.JwtUserClaim, userGUID, refreshToken string) (model.UserTokenSuccessfulResponse, error) {
+
+	reply := model.UserTokenSuccessfulResponse{}
+	token, err := auth.Token(claims)
+	if err != nil {
+		return reply, err
+	}
+
+	reply.Token = token
+	reply.Scope = userGUID
+	reply.User.Admin = claims.UserAdmin
+	reply.RefreshToken = refreshToken
+	reply.ExpiresIn = int(accessTokenLifetime() / time.Second)
+	return reply, nil
+}
+
+// Logout revokes the session of the user
+func (ss *SessionService) Logout(guid string) error {
+	_, err := ss.revoke(ss.Session.Where("guid = ?", guid))
+	return err
+}
+
+// GetAll returns the active sessions, of the user if one is given. partid 0 means all
+// partitions.
+func (ss *SessionService) GetAll(username string, partid int, superAdmin bool) ([]model.TableSession, error) {
+
+	sessions := []model.TableSession{}
+	db := ss.Session.Debug().Scopes(sessionScope(partid, superAdmin))
+	if username != "" {
+		db = db.Where("username = ?", username)
+	}
+	if err := db.Order("lastused_date DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Revoke ends an active session
+func (ss *SessionService) Revoke(guid string, partid int, superAdmin bool) error {
+
+	count, err := ss.revoke(ss.Session.Scopes(sessionScope(partid, superAdmin)).Where("guid = ?", guid))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUser ends all the active sessions of the user and returns how many they were
+func (ss *SessionService) RevokeUser(username string, partid int, superAdmin bool) (int, error) {
+	return ss.revoke(ss.Session.Scopes(sessionScope(partid, superAdmin)).Where("username = ?", username))
+}
+
+// revoke ends the sessions of the query, their JWTs are refused from now on
+func (ss *SessionService) revoke(db *gorm.DB) (int, error) {
+
+	guids := []string{}
+	if err := db.Debug().Model(&model.TableSession{}).Where("revoke_date IS NULL").Pluck("guid", &guids).Error; err != nil {
+		return 0, err
+	}
+	if len(guids) == 0 {
+		return 0, nil
+	}
+
+	if err := ss.Session.Debug().Model(&model.TableSession{}).Where("guid IN (?)", guids).
+		UpdateColumn("revoke_date", time.Now()).Error; err != nil {
+		return 0, err
+	}
+
+	revokedSessions.Lock()
+	defer revokedSessions.Unlock()
+	if revokedSessions.sessions == nil {
+		revokedSessions.sessions = map[string]bool{}
+	}
+	for _, guid := range guids {
+		revokedSessions.sessions[guid] = true
+	}
+	return len(guids), nil
+}
+
+// Revoked tells the JWT middleware if the session has been revoked
+func (ss *SessionService) Revoked(guid string) bool {
+
+	revokedSessions.Lock()
+	defer revokedSessions.Unlock()
+
+	if time.Since(revokedSessions.loaded) > roleCacheTime {
+		/* the JWTs of the sessions revoked before have all expired */
+		guids := []string{}
+		if err := ss.Session.Model(&model.TableSession{}).
+			Where("revoke_date > ?", time.Now().Add(-accessTokenLifetime())).
+			Pluck("guid", &guids).Error; err != nil {
+			logger.Error("revoked sessions can't be loaded: ", err)
+		} else {
+			revokedSessions.sessions = map[string]bool{}
+			for _, val := range guids {
+				revokedSessions.sessions[val] = true
+			}
+			revokedSessions.loaded = time.Now()
+		}
+	}
+	return revokedSessions.sessions[guid]
+}
+
+/* the sessions which ended before the last JWTs they made expired aren't needed anymore */
+func (ss *SessionService) cleanup() {
+
+	before := time.Now().Add(-accessTokenLifetime())
+	if err := ss.Session.Debug().Where("expire_date < ? OR revoke_date < ?", before, before).
+		Delete(&model.TableSession{}).Error; err != nil {
+		logger.Error("old sessions can't be deleted: ", err)
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/utils/sipparser"
+	"github.com/sipcapture/homer-app/utils/stirshaken"
+)
+
+// identityPattern finds an Identity header in the raw message
+const identityPattern = `(^|\n)(identity|y)[ \t]*:`
+
+var stirVerifier struct {
+	once     sync.Once
+	verifier *stirshaken.Verifier
+}
+
+// stirCheck verifies the Identity of a raw SIP message captured at the given time,
+// nil if it has none
+func stirCheck(raw string, at time.Time) *stirshaken.Result {
+
+	stirVerifier.once.Do(func() {
+		stirshaken.MaxAge = time.Duration(config.Setting.STIR_SHAKEN_SETTINGS.MaxAge) * time.Second
+		stirVerifier.verifier = stirshaken.NewVerifier(config.Setting.STIR_SHAKEN_SETTINGS.CertDir)
+	})
+
+	sipMsg := sipparser.ParseMsgLazy(raw, "Identity")
+	if len(sipMsg.Identity) == 0 {
+		return nil
+	}
+	return stirVerifier.verifier.Check(sipMsg, at)
+}
+
+// stirCondition preselects the rows of the virtual stir.* fields, they are matched
+// after decoding by filterRows. Only stir.status = none wants rows without Identity.
+func stirCondition(field, value string, negate bool) (string, []interface{}) {
+
+	none := field == "stir.status" && strings.EqualFold(strings.TrimSpace(value), stirshaken.StatusNone)
+	switch {
+	case none && !negate:
+		return "raw !~* ?", []interface{}{identityPattern}
+	case !none && negate:
+		return "TRUE", nil
+	}
+	return "raw ~* ?", []interface{}{identityPattern}
+}
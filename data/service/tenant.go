@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+// ErrTenantInUse is returned when a tenant with users or auth tokens is deleted
+var ErrTenantInUse = errors.New("the tenant still has users or auth tokens")
+
+type TenantService struct {
+	ServiceConfig
+}
+
+// the filter of the tenant is needed by every data request, the tenants are kept
+// until they change here or for a minute like the roles
+var tenantCache struct {
+	sync.Mutex
+	tenants []model.TableTenant
+	loaded  time.Time
+}
+
+// Filter returns the filter of the data of a tenant. Without a tenant the user sees
+// everything, except the isolate_group of the group_settings: it gets the tenant of
+// the group, see MigrateIsolateGroup, or nothing. An unknown tenant sees nothing.
+func (ts *TenantService) Filter(guid string, userGroup string) *tenant.Filter {
+
+	if guid == "" {
+		isolateGroup := config.Setting.MAIN_SETTINGS.IsolateGroup
+		if isolateGroup == "" || isolateGroup != userGroup {
+			return nil
+		}
+		if guid = ts.OfGroups([]string{isolateGroup}); guid == "" {
+			logger.Error("the isolate_group ", isolateGroup, " has no tenant, it sees no data until it has one")
+			return tenant.Deny(userGroup)
+		}
+	}
+
+	tenants, err := ts.cachedTenants()
+	if err != nil {
+		logger.Error("tenants can't be loaded: ", err)
+		return tenant.Deny(guid)
+	}
+	for _, val := range tenants {
+		if val.GUID != guid {
+			continue
+		}
+		filter, err := tenant.New(val.Name, val.CaptureIDs, val.Nodes, val.Profiles, val.Networks)
+		if err != nil {
+			logger.Error("tenant ", val.Name, ": ", err)
+			return tenant.Deny(guid)
+		}
+		return filter
+	}
+
+	logger.Error("tenant not found: ", guid)
+	return tenant.Deny(guid)
+}
+
+// OfGroups returns the guid of the first tenant, by name, of one of the groups
+func (ts *TenantService) OfGroups(groups []string) string {
+
+	tenants, err := ts.cachedTenants()
+	if err != nil {
+		logger.Error("tenants can't be loaded: ", err)
+		return ""
+	}
+	for _, val := range tenants {
+		for _, tenantGroup := range val.Groups {
+			for _, group := range groups {
+				if strings.EqualFold(tenantGroup, group) {
+					return val.GUID
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// MigrateIsolateGroup turns the isolate_query of the group_settings into a tenant of
+// the isolate_group, unless the group has one already. The query isn't applied as
+// SQL anymore, the ones a tenant can't express are refused.
+func (ts *TenantService) MigrateIsolateGroup(group, query string) error {
+
+	tenants, err := ts.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, val := range tenants {
+		for _, tenantGroup := range val.Groups {
+			if strings.EqualFold(tenantGroup, group) {
+				return nil
+			}
+		}
+	}
+
+	captureIDs, networks, err := tenant.ParseIsolateQuery(query)
+	if err != nil {
+		return err
+	}
+	val := &model.TableTenant{Name: group, Description: "migrated from group_settings.isolate_query",
+		CaptureIDs: captureIDs, Networks: networks, Groups: []string{group}}
+	if _, err := ts.Add(val); err != nil {
+		return err
+	}
+	logger.Info("the isolate_group ", group, " has the new tenant ", val.Name)
+	return nil
+}
+
+func (ts *TenantService) cachedTenants() ([]model.TableTenant, error) {
+
+	tenantCache.Lock()
+	defer tenantCache.Unlock()
+
+	if tenantCache.tenants == nil || time.Since(tenantCache.loaded) > roleCacheTime {
+		tenants, err := ts.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		tenantCache.tenants, tenantCache.loaded = tenants, time.Now()
+	}
+	return tenantCache.tenants, nil
+}
+
+func (ts *TenantService) invalidate() {
+	tenantCache.Lock()
+	tenantCache.tenants = nil
+	tenantCache.Unlock()
+}
+
+func checkTenant(val *model.TableTenant) error {
+	_, err := tenant.New(val.Name, val.CaptureIDs, val.Nodes, val.Profiles, val.Networks)
+	return err
+}
+
+// GetAll returns the tenants
+func (ts *TenantService) GetAll() ([]model.TableTenant, error) {
+
+	tenants := []model.TableTenant{}
+	if err := ts.Session.Debug().
+		Table("tenants").
+		Order("name").
+		Find(&tenants).Error; err != nil {
+		return tenants, err
+	}
+	return tenants, nil
+}
+
+// Get returns the tenant of the guid
+func (ts *TenantService) Get(guid string) (model.TableTenant, error) {
+
+	val := model.TableTenant{}
+	err := ts.Session.Debug().
+		Table("tenants").
+		Where("guid = ?", guid).
+		First(&val).Error
+	return val, err
+}
+
+// Add creates a tenant
+func (ts *TenantService) Add(val *model.TableTenant) (string, error) {
+
+	if err := checkTenant(val); err != nil {
+		return "", err
+	}
+	val.GUID = uuid.NewV4().String()
+	val.CreateDate = time.Now()
+	if err := ts.Session.Debug().
+		Table("tenants").
+		Create(val).Error; err != nil {
+		return "", err
+	}
+	ts.invalidate()
+
+	reply := gabs.New()
+	reply.Set(val.GUID, "data")
+	reply.Set("successfully created tenant", "message")
+	return reply.String(), nil
+}
+
+// Update changes a tenant
+func (ts *TenantService) Update(val *model.TableTenant) error {
+
+	if err := checkTenant(val); err != nil {
+		return err
+	}
+	if err := ts.Session.Debug().
+		Table("tenants").
+		Where("guid = ?", val.GUID).
+		Updates(map[string]interface{}{"name": val.Name, "description": val.Description,
+			"capture_ids": val.CaptureIDs, "nodes": val.Nodes, "networks": val.Networks,
+			"profiles": val.Profiles, "groups": val.Groups}).Error; err != nil {
+		return err
+	}
+	ts.invalidate()
+	return nil
+}
+
+// Delete removes a tenant which has no users and auth tokens anymore
+func (ts *TenantService) Delete(val *model.TableTenant) error {
+
+	var users, tokens int
+	if err := ts.Session.Table("users").Where("tenant_guid = ?", val.GUID).Count(&users).Error; err != nil {
+		return err
+	}
+	if err := ts.Session.Table("auth_token").Where("tenant_guid = ?", val.GUID).Count(&tokens).Error; err != nil {
+		return err
+	}
+	if users > 0 || tokens > 0 {
+		return ErrTenantInUse
+	}
+
+	if err := ts.Session.Debug().
+		Table("tenants").
+		Where("guid = ?", val.GUID).
+		Delete(model.TableTenant{}).Error; err != nil {
+		return err
+	}
+	ts.invalidate()
+	return nil
+}
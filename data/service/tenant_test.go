@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sipcapture/homer-app/config"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/importreader"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+/* the tenants of the config DB, kept in the cache so no DB is needed */
+func loadTestTenants(tenants []model.TableTenant) {
+	tenantCache.Lock()
+	tenantCache.tenants, tenantCache.loaded = tenants, time.Now()
+	tenantCache.Unlock()
+}
+
+func TestTenantFilterIsolateGroup(t *testing.T) {
+
+	savedGroup := config.Setting.MAIN_SETTINGS.IsolateGroup
+	config.Setting.MAIN_SETTINGS.IsolateGroup = "acme"
+	defer func() { config.Setting.MAIN_SETTINGS.IsolateGroup = savedGroup }()
+	defer loadTestTenants(nil)
+
+	ts := TenantService{}
+	header := []byte(`{"captureId":2001,"srcIp":"10.1.0.5","dstIp":"192.0.2.1"}`)
+
+	loadTestTenants([]model.TableTenant{})
+	if filter := ts.Filter("", "acme"); filter.Allows("node1", "hep_proto_1_call", header) {
+		t.Errorf("[TestTenantFilterIsolateGroup] the isolate group without a tenant sees data")
+	}
+	if filter := ts.Filter("", "users"); filter != nil {
+		t.Errorf("[TestTenantFilterIsolateGroup] another group got %v", filter)
+	}
+
+	loadTestTenants([]model.TableTenant{{GUID: "3a8f1b3c", Name: "acme", CaptureIDs: []string{"2002"},
+		Groups: []string{"acme"}}})
+	filter := ts.Filter("", "acme")
+	if filter == nil || filter.Name != "acme" || filter.Allows("node1", "hep_proto_1_call", header) {
+		t.Errorf("[TestTenantFilterIsolateGroup] the isolate group got %v", filter)
+	}
+}
+
+func TestTenantRecord(t *testing.T) {
+
+	filter, err := tenant.New("a", []string{"2001"}, []string{"node1"}, nil, nil)
+	if err != nil {
+		t.Fatalf("[TestTenantRecord] unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		scope  *tenant.Filter
+		node   string
+		header string
+		allow  bool
+	}{
+		{filter, "node1", `{"captureId":2001}`, true},
+		{filter, "node1", `{"captureId":"2001"}`, true},
+		{filter, "node1", `{"captureId":2002}`, false},
+		{filter, "node1", `{}`, false},
+		{filter, "node2", `{"captureId":2001}`, false},
+		{nil, "node2", `{"captureId":2002}`, true},
+		{tenant.Deny("b"), "node1", `{"captureId":2001}`, false},
+	}
+	for _, test := range tests {
+		record := &importreader.Record{Profile: "1_call", ProtocolHeader: json.RawMessage(test.header)}
+		err := tenantRecord(test.scope, test.node, record)
+		if (err == nil) != test.allow || (err != nil && !errors.Is(err, ErrTenantHidden)) {
+			t.Errorf("[TestTenantRecord] %s %s: got %v, expected allowed %v", test.node, test.header, err, test.allow)
+		}
+	}
+}
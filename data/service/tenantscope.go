@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/importreader"
+	"github.com/sipcapture/homer-app/utils/tenant"
+)
+
+// ErrTenantHidden is returned when a request names data the tenant can't see
+var ErrTenantHidden = errors.New("the data is not visible to the tenant")
+
+// tenantScope adds the condition of the filter of a tenant to a query of a
+// hep_proto table, nothing for a nil filter
+func tenantScope(scope *tenant.Filter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if sql, values := scope.Condition(); sql != "" {
+			return db.Where(sql, values...)
+		}
+		return db
+	}
+}
+
+// tenantRows drops the rows the tenant can't see. The query has the condition of the
+// tenant already, the rows are checked again to not depend on the SQL only.
+func tenantRows(scope *tenant.Filter, table string, rows []model.HepTable) []model.HepTable {
+
+	if scope == nil {
+		return rows
+	}
+	allowed := rows[:0]
+	for _, row := range rows {
+		if scope.Allows(row.DBNode, table, row.ProtocolHeader) {
+			allowed = append(allowed, row)
+		}
+	}
+	return allowed
+}
+
+// tenantTables checks the hep_proto tables of the profiles of a search, the keys of
+// its param.search
+func tenantTables(scope *tenant.Filter, search json.RawMessage) error {
+
+	if scope == nil || len(search) == 0 {
+		return nil
+	}
+	profiles := map[string]json.RawMessage{}
+	if err := json.Unmarshal(search, &profiles); err != nil {
+		return err
+	}
+	for profile := range profiles {
+		if !scope.AllowsTable("hep_proto_" + profile) {
+			return ErrTenantHidden
+		}
+	}
+	return nil
+}
+
+// tenantRecord checks a record written by a tenant, the node and the captureId must be
+// the ones of the tenant. The captureId can come from the capture itself.
+func tenantRecord(scope *tenant.Filter, node string, record *importreader.Record) error {
+
+	if scope == nil {
+		return nil
+	}
+	header := struct {
+		CaptureID json.Number `json:"captureId"`
+	}{}
+	decoder := json.NewDecoder(bytes.NewReader(record.ProtocolHeader))
+	decoder.UseNumber()
+	if err := decoder.Decode(&header); err != nil {
+		return err
+	}
+	if !scope.AllowsNode(node) || !scope.AllowsCaptureID(header.CaptureID.String()) {
+		return fmt.Errorf("%w: node %s, captureId %s", ErrTenantHidden, node, header.CaptureID)
+	}
+	return nil
+}
@@ -30,14 +30,16 @@ type UserService struct {
 	HttpAuth   *httpauth.Client
 }
 
-// this method gets all users from database
-func (us *UserService) GetUser(UserName string, isAdmin bool) ([]*model.TableUser, int, error) {
+// this method gets all users of a partition from database, of all partitions for 0
+func (us *UserService) GetUser(UserName string, isAdmin bool, partid int) ([]*model.TableUser, int, error) {
 
 	var user []*model.TableUser
 	var sqlWhere = make(map[string]interface{})
 
 	if !isAdmin {
 		sqlWhere = map[string]interface{}{"username": UserName}
+	} else if partid != 0 {
+		sqlWhere = map[string]interface{}{"partid": partid}
 	}
 
 	if err := us.Session.Debug().Table("users").Where(sqlWhere).Find(&user).Error; err != nil {
@@ -96,6 +98,7 @@ func (us *UserService) GetGroups() (string, error) {
 func (us *UserService) CreateNewUser(user *model.TableUser) error {
 
 	user.CreatedAt = time.Now()
+	user.PartId = model.Partition(user.PartId)
 
 	if user.Password == "" {
 		return errors.New("empty password")
@@ -105,6 +108,9 @@ func (us *UserService) CreateNewUser(user *model.TableUser) error {
 		logger.Error("create user with group that doesn't exist: ", user.UserGroup)
 		return fmt.Errorf("the user group '%s' doesn't exist", user.UserGroup)
 	}
+	if err := us.checkTenant(user.TenantGUID); err != nil {
+		return err
+	}
 
 	// lets generate hash from password
 	password := []byte(user.Password)
@@ -123,9 +129,10 @@ func (us *UserService) CreateNewUser(user *model.TableUser) error {
 	return nil
 }
 
-// this method update user info in the database
+// this method update user info in the database, an admin changes the users of the
+// partition, a super admin the ones of all partitions
 // it doesn't check internally whether all the validation are applied or not
-func (us *UserService) UpdateUser(user *model.TableUser, UserName string, isAdmin bool) error {
+func (us *UserService) UpdateUser(user *model.TableUser, UserName string, isAdmin bool, partid int, superAdmin bool) error {
 
 	// get new instance of user data source
 	user.CreatedAt = time.Now()
@@ -135,8 +142,12 @@ func (us *UserService) UpdateUser(user *model.TableUser, UserName string, isAdmi
 
 	if !isAdmin {
 		sqlWhere = map[string]interface{}{"guid": user.GUID, "username": UserName}
-	} else {
+	} else if superAdmin {
 		sqlWhere = map[string]interface{}{"guid": user.GUID}
+	} else {
+		/* the super admins are managed by super admins only */
+		sqlWhere = map[string]interface{}{"guid": user.GUID, "partid": partid, "superadmin": false}
+		user.PartId = partid
 	}
 
 	if us.Session.Where(sqlWhere).Find(&oldRecord).RecordNotFound() {
@@ -147,6 +158,9 @@ func (us *UserService) UpdateUser(user *model.TableUser, UserName string, isAdmi
 		logger.Error("create user with group that doesn't exist: ", user.UserGroup)
 		return fmt.Errorf("the user group '%s' doesn't exist", user.UserGroup)
 	}
+	if err := us.checkTenant(user.TenantGUID); err != nil {
+		return err
+	}
 
 	if user.Password != "" {
 		password := []byte(user.Password)
@@ -172,6 +186,17 @@ func (us *UserService) UpdateUser(user *model.TableUser, UserName string, isAdmi
 		if err != nil {
 			return err
 		}
+		/* Update skips the empty tenant of a user who can see everything again */
+		err = us.Session.Debug().Table("users").Model(&model.TableUser{}).Where(sqlWhere).UpdateColumn("tenant_guid", user.TenantGUID).Error
+		if err != nil {
+			return err
+		}
+		if superAdmin {
+			err = us.Session.Debug().Table("users").Model(&model.TableUser{}).Where(sqlWhere).UpdateColumn("superadmin", user.SuperAdmin).Error
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -180,25 +205,49 @@ func (us *UserService) UpdateUser(user *model.TableUser, UserName string, isAdmi
 
 // this method deletes user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (us *UserService) DeleteUser(user *model.TableUser) error {
+func (us *UserService) DeleteUser(user *model.TableUser, partid int, superAdmin bool) error {
 
 	// get new instance of user data source
 	newUser := model.TableUser{}
 
-	if us.Session.Where("guid =?", user.GUID).Find(&newUser).RecordNotFound() {
+	sqlWhere := map[string]interface{}{"guid": user.GUID}
+	if !superAdmin {
+		sqlWhere = map[string]interface{}{"guid": user.GUID, "partid": partid, "superadmin": false}
+	}
+
+	if us.Session.Where(sqlWhere).Find(&newUser).RecordNotFound() {
 		return fmt.Errorf("the user with id '%s' was not found", user.GUID)
 	}
-	err := us.Session.Debug().Where("guid =?", user.GUID).Delete(&model.TableUser{}).Error
+	err := us.Session.Debug().Where(sqlWhere).Delete(&model.TableUser{}).Error
 	if err != nil {
 		return err
 	}
+
+	mfaService := MFAService{ServiceConfig: us.ServiceConfig}
+	if err := mfaService.Delete(newUser.GUID); err != nil {
+		logger.Error("the 2FA of the user can't be deleted: ", err)
+	}
+
+	/* its JWTs stop working now, not when they expire */
+	sessionService := SessionService{ServiceConfig: us.ServiceConfig}
+	if _, err := sessionService.RevokeUser(newUser.UserName, 0, true); err != nil {
+		logger.Error("the sessions of the user can't be revoked: ", err)
+	}
 	return nil
 }
 
-// this method is used to login the user
+// InternalAuth tells if the users log in with the users table
+func (us *UserService) InternalAuth() bool {
+	return us.LdapClient == nil && us.HttpAuth == nil
+}
+
+// this method is used to login the user, internal users with 2FA get a challenge
+// to complete with a code instead
 // it doesn't check internally whether all the validation are applied or not
-func (us *UserService) LoginUser(username, password string) (string, model.TableUser, error) {
+func (us *UserService) LoginUser(username, password string) (model.TableUser, *model.MFAChallenge, error) {
 	userData := model.TableUser{}
+	/* the LDAP groups get the roles too */
+	var ldapGroups []string
 
 	switch {
 	case us.LdapClient != nil:
@@ -209,12 +258,12 @@ func (us *UserService) LoginUser(username, password string) (string, model.Table
 			ok, isAdmin, user, err = us.LdapClient.Authenticate(username, password)
 			if err != nil {
 				errorString := fmt.Sprintf("Error authenticating user %s: %+v", username, err)
-				return "", userData, errors.New(errorString)
+				return userData, nil, errors.New(errorString)
 			}
 		}
 
 		if !ok {
-			return "", userData, errors.New("authenticating failed for user")
+			return userData, nil, errors.New("authenticating failed for user")
 		}
 
 		userData.UserName = username
@@ -244,10 +293,11 @@ func (us *UserService) LoginUser(username, password string) (string, model.Table
 		if err != nil {
 			logger.Error("Couldn't get any group for user ", username, ": ", err)
 			if !us.LdapClient.UserMode && !us.LdapClient.AdminMode {
-				return "", userData, errors.New("couldn't fetch any LDAP group and membership is required for login")
+				return userData, nil, errors.New("couldn't fetch any LDAP group and membership is required for login")
 			}
 		} else {
 			logger.Debug("Found groups for user ", username, ": ", groups)
+			ldapGroups = groups
 			// ElementExists returns true if the given slice is empty, so we explicitly check that here
 			// to prevent users with no groups from becoming admins
 			if len(groups) > 0 && heputils.ElementExists(groups, us.LdapClient.AdminGroup) {
@@ -266,17 +316,17 @@ func (us *UserService) LoginUser(username, password string) (string, model.Table
 					userData.UserGroup = "admin"
 				}
 				if !userData.IsAdmin && !us.LdapClient.UserMode {
-					return "", userData, errors.New("failed group match. Group membership is required for login because AdminMode and UserMode are false")
+					return userData, nil, errors.New("failed group match. Group membership is required for login because AdminMode and UserMode are false")
 				}
 			}
 		}
 	case us.HttpAuth != nil:
 		response, err := us.HttpAuth.Authenticate(username, password)
 		if err != nil {
-			return "", userData, errors.New("password is not correct")
+			return userData, nil, errors.New("password is not correct")
 		}
 		if !response.Auth {
-			return "", userData, errors.New("password is not correct")
+			return userData, nil, errors.New("password is not correct")
 		}
 		userData = response.Data
 		userData.IsAdmin = false
@@ -285,19 +335,18 @@ func (us *UserService) LoginUser(username, password string) (string, model.Table
 			userData.IsAdmin = true
 		}
 	default:
-		if err := us.Session.Debug().Table("users").Where("username =?", username).Find(&userData).Error; err != nil {
-			return "", userData, errors.New("user is not found")
+		user, err := us.InternalUser(username)
+		if err != nil {
+			return userData, nil, err
 		}
-		if err := bcrypt.CompareHashAndPassword([]byte(userData.Hash), []byte(password)); err != nil {
-			return "", userData, errors.New("password is not correct")
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Hash), []byte(password)); err != nil {
+			return userData, nil, errors.New("password is not correct")
 		}
 
-		/* check admin or not */
-		userData.IsAdmin = false
-		userData.ExternalAuth = false
-		if userData.UserGroup != "" && strings.Contains(strings.ToLower(userData.UserGroup), "admin") {
-			userData.IsAdmin = true
-		}
+		/* the users with 2FA send a code before they get a token */
+		mfaService := MFAService{ServiceConfig: us.ServiceConfig}
+		challenge, err := mfaService.Challenge(user)
+		return user, challenge, err
 	}
 
 	if config.Setting.MAIN_SETTINGS.EnableGravatar && userData.Email != "" {
@@ -305,8 +354,61 @@ func (us *UserService) LoginUser(username, password string) (string, model.Table
 		userData.Avatar = fmt.Sprintf(config.Setting.MAIN_SETTINGS.GravatarUrl, hex.EncodeToString(hash[:]))
 	}
 
-	token, err := auth.Token(userData)
-	return token, userData, err
+	userData.Permissions = us.permissions(userData, ldapGroups...)
+	userData.TenantGUID = us.tenant(userData, ldapGroups...)
+	return userData, nil, nil
+}
+
+// InternalUser loads a user of the users table with what its tokens need, at login
+// and at every refresh of its session
+func (us *UserService) InternalUser(username string) (model.TableUser, error) {
+
+	userData := model.TableUser{}
+	if err := us.Session.Debug().Table("users").Where("username =?", username).Find(&userData).Error; err != nil {
+		return userData, errors.New("user is not found")
+	}
+
+	/* check admin or not */
+	userData.IsAdmin = false
+	userData.ExternalAuth = false
+	if userData.UserGroup != "" && strings.Contains(strings.ToLower(userData.UserGroup), "admin") {
+		userData.IsAdmin = true
+	}
+
+	if config.Setting.MAIN_SETTINGS.EnableGravatar && userData.Email != "" {
+		hash := md5.Sum([]byte(userData.Email))
+		userData.Avatar = fmt.Sprintf(config.Setting.MAIN_SETTINGS.GravatarUrl, hex.EncodeToString(hash[:]))
+	}
+
+	userData.Permissions = us.permissions(userData)
+	userData.TenantGUID = us.tenant(userData)
+	return userData, nil
+}
+
+// permissions resolves the roles of the group of the user and of other groups
+func (us *UserService) permissions(user model.TableUser, groups ...string) []string {
+	roleService := RoleService{ServiceConfig: us.ServiceConfig}
+	return roleService.Permissions(append(UserGroups(user.UserGroup), groups...), user.IsAdmin)
+}
+
+// tenant returns the tenant of the user or, if none has been given, the one of its groups
+func (us *UserService) tenant(user model.TableUser, groups ...string) string {
+	if user.TenantGUID != "" {
+		return user.TenantGUID
+	}
+	tenantService := TenantService{ServiceConfig: us.ServiceConfig}
+	return tenantService.OfGroups(append(UserGroups(user.UserGroup), groups...))
+}
+
+func (us *UserService) checkTenant(guid string) error {
+	if guid == "" {
+		return nil
+	}
+	tenantService := TenantService{ServiceConfig: us.ServiceConfig}
+	if _, err := tenantService.Get(guid); err != nil {
+		return fmt.Errorf("the tenant '%s' doesn't exist", guid)
+	}
+	return nil
 }
 
 func hashString(s string) uint32 {
@@ -357,6 +459,27 @@ func (us *UserService) GetAuthTypeList() ([]byte, error) {
 		replyFinal.ArrayAppend(replyOauth.Data(), "oauth2")
 	}
 
+	/* the OpenID Connect providers are links like the OAuth2 one */
+	if config.Setting.OIDC_SETTINGS.Enable {
+		for i, provider := range config.Setting.OIDC_SETTINGS.Providers {
+			name := provider.DisplayName
+			if name == "" {
+				name = provider.Name
+			}
+			replyOIDC := gabs.New()
+			replyOIDC.Set(name, "name")
+			replyOIDC.Set(provider.Name, "provider_name")
+			replyOIDC.Set(config.Setting.OIDC_SETTINGS.UrlToServiceRedirect+"/"+provider.Name, "url")
+			replyOIDC.Set(provider.Image, "provider_image")
+			replyOIDC.Set(provider.AutoRedirect, "auto_redirect")
+			replyOIDC.Set("oauth2", "type")
+			replyOIDC.Set("oidc", "protocol")
+			replyOIDC.Set(4+i, "position")
+			replyOIDC.Set(true, "enable")
+			replyFinal.ArrayAppend(replyOIDC.Data(), "oauth2")
+		}
+	}
+
 	replyFinal.Set(replyInternal.Data(), "internal")
 	replyFinal.Set(replyLdap.Data(), "ldap")
 
@@ -380,7 +503,7 @@ func (us *UserService) GetAuthTypeList() ([]byte, error) {
 
 // this method is used to login the user
 // it doesn't check internally whether all the validation are applied or not
-func (us *UserService) LoginUserUsingOauthToken(oAuth2Object model.OAuth2MapToken) (string, model.TableUser, error) {
+func (us *UserService) LoginUserUsingOauthToken(oAuth2Object model.OAuth2MapToken) (model.TableUser, error) {
 
 	userJsonData, _ := gabs.ParseJSON(oAuth2Object.ProfileJson)
 
@@ -433,9 +556,10 @@ func (us *UserService) LoginUserUsingOauthToken(oAuth2Object model.OAuth2MapToke
 	hash := md5.Sum([]byte(userData.UserName))
 	userData.GUID = hex.EncodeToString(hash[:])
 	userData.ExternalAuth = true
+	userData.Permissions = us.permissions(userData)
+	userData.TenantGUID = us.tenant(userData)
 
-	token, err := auth.Token(userData)
-	return token, userData, err
+	return userData, nil
 }
 
 // this method gets all users from database
@@ -467,11 +591,14 @@ func (us *UserService) GetUserProfileFromToken(userTokenProfile *auth.JwtUserCla
 	userProfile.DisplayName = userTokenProfile.DisplayName
 	userProfile.Avatar = userTokenProfile.Avatar
 	userProfile.UserAdmin = userTokenProfile.UserAdmin
+	userProfile.Permissions = userTokenProfile.GrantedPermissions()
+	userProfile.PartID = model.Partition(userTokenProfile.PartID)
+	userProfile.SuperAdmin = userTokenProfile.UserAdmin && userTokenProfile.SuperAdmin
 
 	userProfile.ExternalProfile = userTokenProfile.ExternalProfile
 
 	if !userTokenProfile.ExternalAuth {
-		user, count, err := us.GetUser(userTokenProfile.UserName, false)
+		user, count, err := us.GetUser(userTokenProfile.UserName, false, 0)
 		if err == nil && count > 0 {
 			userProfile.GUID = user[0].GUID
 		}
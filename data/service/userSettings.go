@@ -19,7 +19,7 @@ type UserSettingsService struct {
 	ServiceConfig
 }
 
-func (ss *UserSettingsService) GetCorrelationMap(data *model.SearchObject) ([]byte, error) {
+func (ss *UserSettingsService) GetCorrelationMap(data *model.SearchObject, partid int) ([]byte, error) {
 
 	var mappingSchema = model.TableMappingSchema{}
 	Data, _ := json.Marshal(data.Param.Search)
@@ -36,23 +36,25 @@ func (ss *UserSettingsService) GetCorrelationMap(data *model.SearchObject) ([]by
 	ss.Session.Debug().
 		Table("mapping_schema").
 		Where("hepid = ? and profile = ?", hepid, profile).
-		Find(&mappingSchema)
+		Scopes(partitionScope(partid)).
+		First(&mappingSchema)
 
 	return mappingSchema.CorrelationMapping, nil
 }
 
-// get all mapping from database
-func (ss *UserSettingsService) GetAllMapping() (map[string]json.RawMessage, error) {
+// get all mapping of a partition from database
+func (ss *UserSettingsService) GetAllMapping(partid int) (map[string]json.RawMessage, error) {
 
 	var mappingObject []*model.TableMappingSchema
 	mapsFieldsData := make(map[string]json.RawMessage)
 
-	if err := ss.Session.Debug().Table("mapping_schema").Where("partid = ?", 10).
+	if err := ss.Session.Debug().Table("mapping_schema").Scopes(partitionScope(partid)).
 		Find(&mappingObject).Error; err != nil {
 
 		logger.Error("Error during mapping retrieve ", err.Error())
 		return mapsFieldsData, err
 	}
+	mappingObject = ownMappings(mappingObject, partid)
 
 	if len(mappingObject) == 0 {
 		logger.Error("Error:  mapping is null")
@@ -73,13 +75,14 @@ func (ss *UserSettingsService) GetAllMapping() (map[string]json.RawMessage, erro
 	return mapsFieldsData, nil
 }
 
-// get Category by param
-func (as *UserSettingsService) GetScriptByParam(category string, scriptName string) (string, error) {
+// get Category by param of a partition
+func (as *UserSettingsService) GetScriptByParam(category string, scriptName string, partid int) (string, error) {
 
 	var userGlobalSettings = model.TableGlobalSettings{}
 	if err := as.Session.Debug().
 		Table("global_settings").
-		Where("category = ? AND param = ? AND partid = 10", category, scriptName).
+		Where("category = ? AND param = ?", category, scriptName).
+		Scopes(partitionScope(partid)).
 		First(&userGlobalSettings).Error; err != nil {
 		return "", errors.New("no users settings found")
 	}
@@ -87,14 +90,16 @@ func (as *UserSettingsService) GetScriptByParam(category string, scriptName stri
 	return string(userGlobalSettings.Data), nil
 }
 
-/* get all */
-func (ss *UserSettingsService) GetAll(UserName string, isAdmin bool) (string, error) {
+/* get all, an admin gets the ones of the partition or of all partitions for 0 */
+func (ss *UserSettingsService) GetAll(UserName string, isAdmin bool, partid int) (string, error) {
 	var userSettings = []model.TableUserSettings{}
 
 	var sqlWhere = make(map[string]interface{})
 
 	if !isAdmin {
 		sqlWhere = map[string]interface{}{"username": UserName}
+	} else if partid != 0 {
+		sqlWhere = map[string]interface{}{"partid": partid}
 	}
 
 	if err := ss.Session.Debug().
@@ -165,13 +170,15 @@ func (ss *UserSettingsService) Add(userObject *model.TableUserSettings) (string,
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *UserSettingsService) Get(userObject *model.TableUserSettings, UserName string, isAdmin bool) (model.TableUserSettings, error) {
+func (ss *UserSettingsService) Get(userObject *model.TableUserSettings, UserName string, isAdmin bool, partid int) (model.TableUserSettings, error) {
 	data := model.TableUserSettings{}
 
 	var sqlWhere = make(map[string]interface{})
 
 	if !isAdmin {
 		sqlWhere = map[string]interface{}{"guid": userObject.GUID, "username": UserName}
+	} else if partid != 0 {
+		sqlWhere = map[string]interface{}{"guid": userObject.GUID, "partid": partid}
 	} else {
 		sqlWhere = map[string]interface{}{"guid": userObject.GUID}
 	}
@@ -186,12 +193,14 @@ func (ss *UserSettingsService) Get(userObject *model.TableUserSettings, UserName
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *UserSettingsService) Delete(userObject *model.TableUserSettings, UserName string, isAdmin bool) error {
+func (ss *UserSettingsService) Delete(userObject *model.TableUserSettings, UserName string, isAdmin bool, partid int) error {
 
 	var sqlWhere = make(map[string]interface{})
 
 	if !isAdmin {
 		sqlWhere = map[string]interface{}{"guid": userObject.GUID, "username": UserName}
+	} else if partid != 0 {
+		sqlWhere = map[string]interface{}{"guid": userObject.GUID, "partid": partid}
 	} else {
 		sqlWhere = map[string]interface{}{"guid": userObject.GUID}
 	}
@@ -207,12 +216,14 @@ func (ss *UserSettingsService) Delete(userObject *model.TableUserSettings, UserN
 
 // this method create new user in the database
 // it doesn't check internally whether all the validation are applied or not
-func (ss *UserSettingsService) Update(userObject *model.TableUserSettings, UserName string, isAdmin bool) error {
+func (ss *UserSettingsService) Update(userObject *model.TableUserSettings, UserName string, isAdmin bool, partid int) error {
 
 	var sqlWhere = make(map[string]interface{})
 
 	if !isAdmin {
 		sqlWhere = map[string]interface{}{"guid": userObject.GUID, "username": UserName}
+	} else if partid != 0 {
+		sqlWhere = map[string]interface{}{"guid": userObject.GUID, "partid": partid}
 	} else {
 		sqlWhere = map[string]interface{}{"guid": userObject.GUID}
 	}
@@ -28,13 +28,16 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -60,10 +63,14 @@ import (
 	httpresponse "github.com/sipcapture/homer-app/network/response"
 	apirouterv1 "github.com/sipcapture/homer-app/router/v1"
 	"github.com/sipcapture/homer-app/system/webmessages"
+	"github.com/sipcapture/homer-app/utils/decoder"
+	"github.com/sipcapture/homer-app/utils/hepcollector"
+	"github.com/sipcapture/homer-app/utils/heprelay"
 	"github.com/sipcapture/homer-app/utils/heputils"
 	"github.com/sipcapture/homer-app/utils/httpauth"
 	"github.com/sipcapture/homer-app/utils/ldap"
 	"github.com/sipcapture/homer-app/utils/logger"
+	"github.com/sipcapture/homer-app/utils/oidc"
 	"github.com/spf13/viper"
 	"gopkg.in/go-playground/validator.v9"
 )
@@ -145,6 +152,9 @@ type ServicesObject struct {
 	serviceLoki       service.ServiceLoki
 	serviceGrafana    service.ServiceGrafana
 	externalDecoder   service.ExternalDecoder
+	decoders          *decoder.Registry
+	hepCollector      *service.HepCollectorService
+	oidcProviders     map[string]*oidc.Provider
 }
 
 var servicesObject ServicesObject
@@ -280,6 +290,15 @@ func main() {
 	// update version
 	updateVersionApplication(servicesObject.configDBSession)
 
+	// the isolate_query of the group_settings becomes a tenant
+	if group, query := config.Setting.MAIN_SETTINGS.IsolateGroup, config.Setting.MAIN_SETTINGS.IsolateQuery; group != "" && query != "" {
+		tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: servicesObject.configDBSession}}
+		if err := tenantService.MigrateIsolateGroup(group, query); err != nil {
+			logger.Error("group_settings.isolate_query can't become a tenant: ", err.Error(),
+				", give the group ", group, " a tenant at /tenants, it sees no data until then")
+		}
+	}
+
 	if *appFlags.ShowCurrentConfig {
 		ShowCurrentConfigToConsole()
 		os.Exit(0)
@@ -290,6 +309,100 @@ func main() {
 
 }
 
+// newHepRelay returns the relay of /ws, nil if it is disabled or misconfigured
+func newHepRelay() *heprelay.Relay {
+
+	settings := config.Setting.HEP_RELAY_SETTINGS
+	if !settings.Enable {
+		return nil
+	}
+
+	/* host and port are the old single UDP collector */
+	upstreams := settings.Upstreams
+	if len(upstreams) == 0 {
+		upstreams = []string{fmt.Sprintf("udp://%s", net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port)))}
+	}
+
+	options := heprelay.Options{
+		Upstreams:         upstreams,
+		MaxRate:           settings.MaxRate,
+		Burst:             settings.Burst,
+		ReconnectInterval: time.Duration(settings.ReconnectInterval) * time.Millisecond,
+		QueueSize:         settings.QueueSize,
+		TLSConfig:         &tls.Config{InsecureSkipVerify: settings.TLSSkipVerify, MinVersion: tls.VersionTLS12},
+	}
+
+	if settings.TLSCA != "" {
+		ca, err := ioutil.ReadFile(settings.TLSCA)
+		if err != nil {
+			logger.Error("HEP relay couldn't read the CA: ", err.Error())
+			return nil
+		}
+		options.TLSConfig.RootCAs = x509.NewCertPool()
+		if !options.TLSConfig.RootCAs.AppendCertsFromPEM(ca) {
+			logger.Error("HEP relay: no certificate in ", settings.TLSCA)
+			return nil
+		}
+	}
+
+	relay, err := heprelay.New(options)
+	if err != nil {
+		logger.Error("HEP relay couldn't start: ", err.Error())
+		return nil
+	}
+
+	return relay
+}
+
+// startHepCollector opens the HEP listeners and the writer, nil if it couldn't start
+func startHepCollector() *service.HepCollectorService {
+
+	settings := config.Setting.HEP_COLLECTOR_SETTINGS
+
+	options := hepcollector.Options{
+		UDPAddress:   settings.UDPAddress,
+		TCPAddress:   settings.TCPAddress,
+		TLSAddress:   settings.TLSAddress,
+		QueueSize:    settings.QueueSize,
+		BlockTimeout: time.Duration(settings.BlockTimeout) * time.Millisecond,
+		IdleTimeout:  time.Duration(settings.IdleTimeout) * time.Second,
+		AuthKey:      settings.AuthKey,
+	}
+
+	if settings.TLSAddress != "" {
+		cert, err := tls.LoadX509KeyPair(settings.TLSCert, settings.TLSKey)
+		if err != nil {
+			logger.Error("HEP collector couldn't load the TLS certificate: ", err.Error())
+			return nil
+		}
+		options.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	collector := hepcollector.New(options)
+	if err := collector.Start(); err != nil {
+		logger.Error("HEP collector couldn't start: ", err.Error())
+		return nil
+	}
+
+	hepCollectorService := &service.HepCollectorService{
+		ServiceData:   service.ServiceData{Session: servicesObject.dataDBSession},
+		ConfigSession: servicesObject.configDBSession,
+		Collector:     collector,
+		Node:          settings.Node,
+		BatchSize:     settings.BatchSize,
+		FlushInterval: time.Duration(settings.FlushInterval) * time.Millisecond,
+	}
+
+	go func() {
+		if err := hepCollectorService.Run(); err != nil {
+			logger.Error("HEP collector stopped: ", err.Error())
+			collector.Stop()
+		}
+	}()
+
+	return hepCollectorService
+}
+
 func configureLogging() {
 
 	/* OLD LOG */
@@ -373,14 +486,15 @@ func configureServiceObjects() {
 		}
 	}
 
+	if viper.IsSet("transaction_settings.lint") {
+		config.Setting.TRANSACTION_SETTINGS.Lint = viper.GetBool("transaction_settings.lint")
+	}
+
 	/* CaptID alias */
 	if viper.IsSet("api_settings.add_captid_to_resolve") {
 		config.Setting.MAIN_SETTINGS.UseCaptureIDInAlias = viper.GetBool("api_settings.add_captid_to_resolve")
 	}
 
-	/* init map */
-	config.OAuth2TokenMap = make(map[string]model.OAuth2MapToken)
-
 	/* oauth2 */
 	if viper.IsSet("oauth2.enable") {
 		config.Setting.OAUTH2_SETTINGS.Enable = viper.GetBool("oauth2.enable")
@@ -513,6 +627,41 @@ func configureServiceObjects() {
 		}
 	}
 
+	/* OpenID Connect */
+	if viper.IsSet("oidc.enable") {
+		config.Setting.OIDC_SETTINGS.Enable = viper.GetBool("oidc.enable")
+	}
+	if viper.IsSet("oidc.service_redirect") {
+		config.Setting.OIDC_SETTINGS.UrlToServiceRedirect = viper.GetString("oidc.service_redirect")
+	}
+	if viper.IsSet("oidc.service_root") {
+		config.Setting.OIDC_SETTINGS.UrlToService = viper.GetString("oidc.service_root")
+	}
+	if viper.IsSet("oidc.expire_login") {
+		config.Setting.OIDC_SETTINGS.ExpireLogin = viper.GetUint32("oidc.expire_login")
+	}
+	if viper.IsSet("oidc.expire_sso") {
+		config.Setting.OIDC_SETTINGS.ExpireSSOToken = viper.GetUint32("oidc.expire_sso")
+	}
+	if viper.IsSet("oidc.providers") {
+		if err := viper.UnmarshalKey("oidc.providers", &config.Setting.OIDC_SETTINGS.Providers); err != nil {
+			logger.Error("bad oidc providers: ", err)
+		}
+	}
+
+	servicesObject.oidcProviders = map[string]*oidc.Provider{}
+	if config.Setting.OIDC_SETTINGS.Enable {
+		client := &http.Client{Timeout: time.Duration(config.Setting.MAIN_SETTINGS.TimeoutHttpClient) * time.Second}
+		for _, val := range config.Setting.OIDC_SETTINGS.Providers {
+			provider, err := oidc.NewProvider(val, client)
+			if err != nil {
+				logger.Error("oidc provider: ", err)
+				continue
+			}
+			servicesObject.oidcProviders[provider.Name] = provider
+		}
+	}
+
 	/* Check LDAP here */
 	switch config.Setting.MAIN_SETTINGS.DefaultAuth {
 	case "ldap":
@@ -842,6 +991,257 @@ func configureAsHTTPServer() {
 		config.Setting.DECODER_SHARK.Enable = viper.GetBool("decoder_shark.enable")
 	}
 
+	if viper.IsSet("decoder_shark.workers") {
+		config.Setting.DECODER_SHARK.Workers = viper.GetInt("decoder_shark.workers")
+	}
+
+	if viper.IsSet("decoder_shark.timeout") {
+		config.Setting.DECODER_SHARK.Timeout = viper.GetInt("decoder_shark.timeout")
+	}
+
+	if viper.IsSet("decoder_shark.cache_size") {
+		config.Setting.DECODER_SHARK.CacheSize = viper.GetInt("decoder_shark.cache_size")
+	}
+
+	servicesObject.externalDecoder.Workers = config.Setting.DECODER_SHARK.Workers
+	servicesObject.externalDecoder.Timeout = config.Setting.DECODER_SHARK.Timeout
+	servicesObject.externalDecoder.CacheSize = config.Setting.DECODER_SHARK.CacheSize
+	servicesObject.decoders = service.NewDecoders(servicesObject.externalDecoder)
+
+	// IMPORT
+	if viper.IsSet("import_settings.node") {
+		config.Setting.IMPORT_SETTINGS.Node = viper.GetString("import_settings.node")
+	} else {
+		config.Setting.IMPORT_SETTINGS.Node = config.Setting.DECODER_SHARK.ImportNode
+	}
+
+	if viper.IsSet("import_settings.capture_id") {
+		config.Setting.IMPORT_SETTINGS.CaptureID = viper.GetString("import_settings.capture_id")
+	}
+
+	if viper.IsSet("import_settings.fallback_profile") {
+		config.Setting.IMPORT_SETTINGS.FallbackProfile = viper.GetString("import_settings.fallback_profile")
+	}
+
+	if viper.IsSet("import_settings.batch_size") {
+		config.Setting.IMPORT_SETTINGS.BatchSize = viper.GetInt("import_settings.batch_size")
+	}
+
+	if viper.IsSet("import_settings.keep_jobs") {
+		config.Setting.IMPORT_SETTINGS.KeepJobs = viper.GetInt("import_settings.keep_jobs")
+	}
+
+	if viper.IsSet("import_settings.text_log.format") {
+		config.Setting.IMPORT_SETTINGS.TEXT_LOG.Format = viper.GetString("import_settings.text_log.format")
+	}
+
+	if viper.IsSet("import_settings.text_log.timestamp_regex") {
+		config.Setting.IMPORT_SETTINGS.TEXT_LOG.TimestampRegex = viper.GetString("import_settings.text_log.timestamp_regex")
+	}
+
+	if viper.IsSet("import_settings.text_log.timestamp_layout") {
+		config.Setting.IMPORT_SETTINGS.TEXT_LOG.TimestampLayout = viper.GetString("import_settings.text_log.timestamp_layout")
+	}
+
+	if viper.IsSet("import_settings.text_log.address_regex") {
+		config.Setting.IMPORT_SETTINGS.TEXT_LOG.AddressRegex = viper.GetString("import_settings.text_log.address_regex")
+	}
+
+	if viper.IsSet("import_settings.text_log.local_ip") {
+		config.Setting.IMPORT_SETTINGS.TEXT_LOG.LocalIP = viper.GetString("import_settings.text_log.local_ip")
+	}
+
+	if viper.IsSet("import_settings.text_log.local_port") {
+		config.Setting.IMPORT_SETTINGS.TEXT_LOG.LocalPort = viper.GetInt("import_settings.text_log.local_port")
+	}
+
+	// INGEST
+	if viper.IsSet("ingest_settings.enable") {
+		config.Setting.INGEST_SETTINGS.Enable = viper.GetBool("ingest_settings.enable")
+	}
+
+	if viper.IsSet("ingest_settings.max_records") {
+		config.Setting.INGEST_SETTINGS.MaxRecords = viper.GetInt("ingest_settings.max_records")
+	}
+
+	// HEP RELAY
+	if viper.IsSet("hep_relay.enable") {
+		config.Setting.HEP_RELAY_SETTINGS.Enable = viper.GetBool("hep_relay.enable")
+	}
+
+	if viper.IsSet("hep_relay.host") {
+		config.Setting.HEP_RELAY_SETTINGS.Host = viper.GetString("hep_relay.host")
+	}
+
+	if viper.IsSet("hep_relay.port") {
+		config.Setting.HEP_RELAY_SETTINGS.Port = viper.GetInt("hep_relay.port")
+	}
+
+	if viper.IsSet("hep_relay.upstreams") {
+		config.Setting.HEP_RELAY_SETTINGS.Upstreams = viper.GetStringSlice("hep_relay.upstreams")
+	}
+
+	if viper.IsSet("hep_relay.tls_ca") {
+		config.Setting.HEP_RELAY_SETTINGS.TLSCA = viper.GetString("hep_relay.tls_ca")
+	}
+
+	if viper.IsSet("hep_relay.tls_skip_verify") {
+		config.Setting.HEP_RELAY_SETTINGS.TLSSkipVerify = viper.GetBool("hep_relay.tls_skip_verify")
+	}
+
+	if viper.IsSet("hep_relay.max_rate") {
+		config.Setting.HEP_RELAY_SETTINGS.MaxRate = viper.GetInt("hep_relay.max_rate")
+	}
+
+	if viper.IsSet("hep_relay.burst") {
+		config.Setting.HEP_RELAY_SETTINGS.Burst = viper.GetInt("hep_relay.burst")
+	}
+
+	if viper.IsSet("hep_relay.reconnect_interval") {
+		config.Setting.HEP_RELAY_SETTINGS.ReconnectInterval = viper.GetInt("hep_relay.reconnect_interval")
+	}
+
+	if viper.IsSet("hep_relay.queue_size") {
+		config.Setting.HEP_RELAY_SETTINGS.QueueSize = viper.GetInt("hep_relay.queue_size")
+	}
+
+	// LIVE
+	if viper.IsSet("live_settings.enable") {
+		config.Setting.LIVE_SETTINGS.Enable = viper.GetBool("live_settings.enable")
+	}
+
+	if viper.IsSet("live_settings.poll_interval") {
+		config.Setting.LIVE_SETTINGS.PollInterval = viper.GetInt("live_settings.poll_interval")
+	}
+
+	if viper.IsSet("live_settings.settle_delay") {
+		config.Setting.LIVE_SETTINGS.SettleDelay = viper.GetInt("live_settings.settle_delay")
+	}
+
+	if viper.IsSet("live_settings.max_rate") {
+		config.Setting.LIVE_SETTINGS.MaxRate = viper.GetInt("live_settings.max_rate")
+	}
+
+	if viper.IsSet("live_settings.max_lag") {
+		config.Setting.LIVE_SETTINGS.MaxLag = viper.GetInt("live_settings.max_lag")
+	}
+
+	if viper.IsSet("live_settings.idle_timeout") {
+		config.Setting.LIVE_SETTINGS.IdleTimeout = viper.GetInt("live_settings.idle_timeout")
+	}
+
+	if viper.IsSet("live_settings.max_subscriptions") {
+		config.Setting.LIVE_SETTINGS.MaxSubscriptions = viper.GetInt("live_settings.max_subscriptions")
+	}
+
+	// HEP COLLECTOR
+	if viper.IsSet("hep_collector.enable") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.Enable = viper.GetBool("hep_collector.enable")
+	}
+
+	if viper.IsSet("hep_collector.udp") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.UDPAddress = viper.GetString("hep_collector.udp")
+	}
+
+	if viper.IsSet("hep_collector.tcp") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.TCPAddress = viper.GetString("hep_collector.tcp")
+	}
+
+	if viper.IsSet("hep_collector.tls") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.TLSAddress = viper.GetString("hep_collector.tls")
+	}
+
+	if viper.IsSet("hep_collector.tls_cert") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.TLSCert = viper.GetString("hep_collector.tls_cert")
+	}
+
+	if viper.IsSet("hep_collector.tls_key") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.TLSKey = viper.GetString("hep_collector.tls_key")
+	}
+
+	if viper.IsSet("hep_collector.auth_key") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.AuthKey = viper.GetString("hep_collector.auth_key")
+	}
+
+	if viper.IsSet("hep_collector.node") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.Node = viper.GetString("hep_collector.node")
+	}
+
+	if viper.IsSet("hep_collector.queue_size") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.QueueSize = viper.GetInt("hep_collector.queue_size")
+	}
+
+	if viper.IsSet("hep_collector.batch_size") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.BatchSize = viper.GetInt("hep_collector.batch_size")
+	}
+
+	if viper.IsSet("hep_collector.flush_interval") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.FlushInterval = viper.GetInt("hep_collector.flush_interval")
+	}
+
+	if viper.IsSet("hep_collector.block_timeout") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.BlockTimeout = viper.GetInt("hep_collector.block_timeout")
+	}
+
+	if viper.IsSet("hep_collector.idle_timeout") {
+		config.Setting.HEP_COLLECTOR_SETTINGS.IdleTimeout = viper.GetInt("hep_collector.idle_timeout")
+	}
+
+	// STIR/SHAKEN
+	if viper.IsSet("stir_shaken.cert_dir") {
+		config.Setting.STIR_SHAKEN_SETTINGS.CertDir = viper.GetString("stir_shaken.cert_dir")
+	}
+
+	if viper.IsSet("stir_shaken.max_age") {
+		config.Setting.STIR_SHAKEN_SETTINGS.MaxAge = viper.GetInt("stir_shaken.max_age")
+	}
+
+	// ALIAS SYNC
+	if viper.IsSet("alias_sync.enable") {
+		config.Setting.ALIAS_SYNC_SETTINGS.Enable = viper.GetBool("alias_sync.enable")
+	}
+
+	if viper.IsSet("alias_sync.source") {
+		config.Setting.ALIAS_SYNC_SETTINGS.Source = viper.GetString("alias_sync.source")
+	}
+
+	if viper.IsSet("alias_sync.path") {
+		config.Setting.ALIAS_SYNC_SETTINGS.Path = viper.GetString("alias_sync.path")
+	}
+
+	if viper.IsSet("alias_sync.url") {
+		config.Setting.ALIAS_SYNC_SETTINGS.URL = viper.GetString("alias_sync.url")
+	}
+
+	if viper.IsSet("alias_sync.format") {
+		config.Setting.ALIAS_SYNC_SETTINGS.Format = viper.GetString("alias_sync.format")
+	}
+
+	if viper.IsSet("alias_sync.interval") {
+		config.Setting.ALIAS_SYNC_SETTINGS.Interval = viper.GetInt("alias_sync.interval")
+	}
+
+	if viper.IsSet("alias_sync.timeout") {
+		config.Setting.ALIAS_SYNC_SETTINGS.Timeout = viper.GetInt("alias_sync.timeout")
+	}
+
+	// GEOIP
+	if viper.IsSet("geoip.enable") {
+		config.Setting.GEOIP_SETTINGS.Enable = viper.GetBool("geoip.enable")
+	}
+
+	if viper.IsSet("geoip.location_db") {
+		config.Setting.GEOIP_SETTINGS.LocationDB = viper.GetString("geoip.location_db")
+	}
+
+	if viper.IsSet("geoip.asn_db") {
+		config.Setting.GEOIP_SETTINGS.ASNDB = viper.GetString("geoip.asn_db")
+	}
+
+	if viper.IsSet("geoip.reload_check") {
+		config.Setting.GEOIP_SETTINGS.ReloadCheck = viper.GetInt("geoip.reload_check")
+	}
+
 	if viper.IsSet("swagger.enable") {
 		config.Setting.SWAGGER.Enable = viper.GetBool("swagger.enable")
 	}
@@ -854,6 +1254,20 @@ func configureAsHTTPServer() {
 		config.Setting.SWAGGER.ApiHost = viper.GetString("swagger.api_host")
 	}
 
+	// start the built-in HEP collector
+	if config.Setting.HEP_COLLECTOR_SETTINGS.Enable {
+		servicesObject.hepCollector = startHepCollector()
+	}
+
+	// sync the aliases of the inventory
+	if aliasSync := service.NewAliasSyncService(servicesObject.configDBSession); aliasSync.Enabled() {
+		interval := config.Setting.ALIAS_SYNC_SETTINGS.Interval
+		if interval < 60 {
+			interval = 60
+		}
+		go aliasSync.Run(time.Duration(interval)*time.Second, nil)
+	}
+
 	// perform routing for v1 version of web apis
 	performV1APIRouting(e)
 
@@ -1006,12 +1420,14 @@ func performV1APIRouting(e *echo.Echo) {
 		apirouterv1.RouteUserApis(acc, servicesObject.configDBSession, nil, nil)
 	}
 
+	// OpenID Connect login
+	apirouterv1.RouteOIDCApis(acc, servicesObject.configDBSession, servicesObject.oidcProviders)
+
 	//subscribe access with authKey
 	apirouterv1.RouteAgentsubAuthKeyApis(acc, servicesObject.configDBSession)
 
-	// route hep_reply apis
-	addr := fmt.Sprintf("%s:%d", viper.Get("hep_relay.host"), viper.GetInt("hep_relay.port"))
-	apirouterv1.RouteWebSocketApis(acc, addr)
+	// hep relay, routed with the restricted web services
+	hepRelay := newHepRelay()
 
 	// restricted web services will fall in this group
 	res := e.Group(prefix + "/api/v3")
@@ -1019,6 +1435,8 @@ func performV1APIRouting(e *echo.Echo) {
 	config := middleware.JWTConfig{
 		Claims:     &auth.JwtUserClaim{},
 		SigningKey: []byte(config.Setting.AUTH_SETTINGS.JwtSecret),
+		/* WebSockets can pass the JWT as ?token= */
+		TokenLookupFuncs: []middleware.ValuesExtractor{auth.QueryTokenExtractor},
 		Skipper: func(c echo.Context) bool {
 
 			if !config.Setting.API_SETTINGS.EnableTokenAccess {
@@ -1028,6 +1446,9 @@ func performV1APIRouting(e *echo.Echo) {
 
 			/* TOKEN */
 			tokenValue := c.Request().Header.Get(config.Setting.AUTH_SETTINGS.AuthTokenHeader)
+			if tokenValue == "" {
+				tokenValue = auth.QueryToken(c, "auth_token")
+			}
 			if tokenValue != "" {
 				var tokenObject model.TableAuthToken
 				var count int
@@ -1066,6 +1487,7 @@ func performV1APIRouting(e *echo.Echo) {
 					}
 				}
 
+				roleService := service.RoleService{ServiceConfig: service.ServiceConfig{Session: servicesObject.configDBSession}}
 				keyContext := model.KeyContext{
 					Context:     c,
 					AuthKey:     tokenValue,
@@ -1074,6 +1496,10 @@ func performV1APIRouting(e *echo.Echo) {
 					UserName:    userName,
 					UserGroup:   userGroup,
 					Auth:        false,
+					Scopes:      tokenObject.Scopes(),
+					Permissions: roleService.Permissions(service.UserGroups(userGroup), isAdmin),
+					Tenant:      tokenObject.TenantGUID,
+					PartID:      model.Partition(int(userObject.PartID)),
 				}
 
 				c.Set("authtoken", keyContext)
@@ -1084,6 +1510,9 @@ func performV1APIRouting(e *echo.Echo) {
 	}
 
 	res.Use(middleware.JWTWithConfig(config))
+	/* the JWTs of the sessions which have ended are refused */
+	sessionService := service.SessionService{ServiceConfig: service.ServiceConfig{Session: servicesObject.configDBSession}}
+	auth.RevocationCheck(sessionService.Revoked)
 	res.Use(auth.MiddlewareRes)
 
 	logger.Debug(auth.JwtUserClaim{})
@@ -1099,6 +1528,14 @@ func performV1APIRouting(e *echo.Echo) {
 	apirouterv1.RouteHepsubApis(res, servicesObject.configDBSession)
 	// route make auth token
 	apirouterv1.RouteAuthTokenApis(res, servicesObject.configDBSession)
+	// route roles and permissions
+	apirouterv1.RouteRoleApis(res, servicesObject.configDBSession)
+	// route tenant apis
+	apirouterv1.RouteTenantApis(res, servicesObject.configDBSession)
+	// route login sessions apis
+	apirouterv1.RouteSessionApis(res, servicesObject.configDBSession)
+	// route 2FA apis
+	apirouterv1.RouteMFAApis(res, servicesObject.configDBSession)
 
 	/*************** PARTLY admin access ONLY ***************/
 	// route user apis
@@ -1106,18 +1543,28 @@ func performV1APIRouting(e *echo.Echo) {
 	// route userSettings apis
 	apirouterv1.RouteUserSettingsApis(res, servicesObject.configDBSession)
 	// route agent sub apis
-	apirouterv1.RouteAgentsubApis(res, servicesObject.configDBSession)
+	apirouterv1.RouteAgentsubApis(res, servicesObject.configDBSession, servicesObject.dataDBSession)
 
 	// route hep sub search apis
 	apirouterv1.RouteHepSubSearch(res, servicesObject.configDBSession)
 
 	// route search apis
-	apirouterv1.RouteSearchApis(res, servicesObject.dataDBSession, servicesObject.configDBSession, servicesObject.externalDecoder)
+	apirouterv1.RouteSearchApis(res, servicesObject.dataDBSession, servicesObject.configDBSession, servicesObject.decoders)
+	// route import apis
+	apirouterv1.RouteImportApis(res, servicesObject.dataDBSession, servicesObject.configDBSession)
+	// route ingest apis
+	apirouterv1.RouteIngestApis(res, servicesObject.dataDBSession, servicesObject.configDBSession)
+	// route hep_relay apis
+	apirouterv1.RouteWebSocketApis(res, hepRelay)
+	// route live search apis
+	apirouterv1.RouteLiveApis(res, servicesObject.dataDBSession, servicesObject.configDBSession)
+	// route hep collector apis
+	apirouterv1.RouteHepCollectorApis(res, servicesObject.hepCollector)
 	// route dashboards apis
 	apirouterv1.RouteDashboardApis(res, servicesObject.configDBSession)
 
 	// route profile apis
-	apirouterv1.RouteProfileApis(res, servicesObject.configDBSession, servicesObject.databaseNodeMap)
+	apirouterv1.RouteProfileApis(res, servicesObject.configDBSession, servicesObject.databaseNodeMap, servicesObject.decoders)
 	// route RouteStatisticApis apis
 	apirouterv1.RouteStatisticApis(res, servicesObject.influxDBSession)
 	// route RouteStatisticApis apis
@@ -1458,6 +1905,14 @@ func updateVersionApplication(configDBSession *gorm.DB) bool {
 		config.Setting.AUTH_SETTINGS.AuthTokenExpire = viper.GetUint32("auth_settings.token_expire")
 	}
 
+	if viper.IsSet("auth_settings.access_token_expire") {
+		config.Setting.AUTH_SETTINGS.AccessTokenExpire = viper.GetUint32("auth_settings.access_token_expire")
+	}
+
+	if viper.IsSet("auth_settings.mfa_issuer") {
+		config.Setting.AUTH_SETTINGS.MFAIssuer = viper.GetString("auth_settings.mfa_issuer")
+	}
+
 	if viper.IsSet("auth_settings.auth_token_header") {
 		config.Setting.AUTH_SETTINGS.AuthTokenHeader = viper.GetString("auth_settings.auth_token_header")
 	}
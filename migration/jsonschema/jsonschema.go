@@ -14,8 +14,11 @@ var TableVersion = map[string]int{
 	"global_settings":        1,
 	"hepsub_mapping_schema":  1,
 	"mapping_schema":         1,
+	"roles":                  1,
+	"sessions":               1,
 	"users":                  1,
 	"user_settings":          1,
+	"user_mfa":               1,
 }
 
 var MinimumPgSQL = 10
@@ -577,13 +580,77 @@ var FieldsMapping1call = json.RawMessage(`[
 	  "skip": false,
 	  "hide": true
 	},
+	{
+	  "id": "data_header.isup_called",
+	  "name": "ISUP Called Number",
+	  "type": "string",
+	  "index": "none",
+	  "form_type": "input",
+	  "position": 22,
+	  "skip": false,
+	  "hide": true
+	},
+	{
+	  "id": "data_header.isup_calling",
+	  "name": "ISUP Calling Number",
+	  "type": "string",
+	  "index": "none",
+	  "form_type": "input",
+	  "position": 23,
+	  "skip": false,
+	  "hide": true
+	},
+	{
+	  "id": "data_header.isup_cause",
+	  "name": "ISUP Cause",
+	  "type": "integer",
+	  "index": "none",
+	  "form_type": "input",
+	  "position": 24,
+	  "skip": false,
+	  "hide": true
+	},
+	{
+	  "id": "lint.severity",
+	  "name": "Lint Findings",
+	  "type": "string",
+	  "index": "none",
+	  "form_type": "input",
+	  "form_default": [
+		"error",
+		"warning"
+	  ],
+	  "position": 25,
+	  "skip": false,
+	  "hide": true
+	},
+	{
+	  "id": "alias.tag",
+	  "name": "Alias Tag",
+	  "type": "string",
+	  "index": "none",
+	  "form_type": "input",
+	  "position": 26,
+	  "skip": false,
+	  "hide": true
+	},
+	{
+	  "id": "alias.group",
+	  "name": "Alias Group",
+	  "type": "string",
+	  "index": "none",
+	  "form_type": "input",
+	  "position": 27,
+	  "skip": false,
+	  "hide": true
+	},
 	{
 	  "id": "raw",
 	  "name": "SIP RAW",
 	  "type": "string",
 	  "index": "none",
 	  "form_type": "input",
-	  "position": 22,
+	  "position": 28,
 	  "skip": false,
 	  "hide": true
   },
@@ -598,7 +665,7 @@ var FieldsMapping1call = json.RawMessage(`[
         "registration",
         "default"
     ],
-    "position": 23,
+    "position": 29,
     "skip": false,
     "hide": true,
     "profile": true
@@ -615,7 +682,7 @@ var FieldsMapping1call = json.RawMessage(`[
     "_form_api": "/database/node/list",
     "system_param": true,
     "mapping": "param.location.node",
-    "position": 23,
+    "position": 29,
     "skip": true,
     "hide": true
   }
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/lib/pq"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 	uuid "github.com/satori/go.uuid"
 	"github.com/sipcapture/homer-app/migration/jsonschema"
 	"github.com/sipcapture/homer-app/model"
@@ -212,7 +212,13 @@ func CreateHomerConfigTables(configDBSession *gorm.DB, homerDBconfig string, typ
 		heputils.Colorize(heputils.ColorGreen, createString)
 	}
 
+	/* the first start with partitions: the admins so far keep managing everything */
+	upgradePartitions := configDBSession.HasTable("users") && !configDBSession.Dialect().HasColumn("users", "superadmin")
+
 	db := configDBSession.AutoMigrate(&model.TableAlias{},
+		&model.TableAliasGroup{},
+		&model.TableRole{},
+		&model.TableTenant{},
 		&model.TableGlobalSettings{},
 		&model.TableMappingSchema{},
 		&model.TableUserSettings{},
@@ -221,18 +227,38 @@ func CreateHomerConfigTables(configDBSession *gorm.DB, homerDBconfig string, typ
 		&model.TableAgentLocationSession{},
 		&model.TableVersions{},
 		&model.TableApplications{},
-		&model.TableAuthToken{})
+		&model.TableAuthToken{},
+		&model.TableSession{},
+		&model.TableUserMFA{})
 	if db != nil && db.Error != nil {
 		logger.Error(fmt.Sprintf("Automigrate failed: with error %s", db.Error))
 	} else {
 		logger.Debug("Automigrate was success")
 	}
 
+	if upgradePartitions {
+		upgradeHomerPartitions(configDBSession)
+	}
+
 	if showUpgrade {
 		heputils.Colorize(heputils.ColorYellow, "\r\nDONE")
 	}
 }
 
+// upgradeHomerPartitions makes the admins of the default partition super admins and
+// moves the global settings, stored with partid 1 so far, to the default partition
+func upgradeHomerPartitions(configDBSession *gorm.DB) {
+
+	if err := configDBSession.Exec("UPDATE users SET superadmin = true WHERE usergroup ILIKE ? AND partid = ?",
+		"%admin%", model.DefaultPartition).Error; err != nil {
+		logger.Error("upgrade of the admins failed: ", err)
+	}
+	if err := configDBSession.Exec("UPDATE global_settings SET partid = ? WHERE partid = 1",
+		model.DefaultPartition).Error; err != nil {
+		logger.Error("upgrade of the global settings failed: ", err)
+	}
+}
+
 func checkHomerConfigTables(configDBSession *gorm.DB) map[string]bool {
 
 	data := []model.TableVersions{}
@@ -274,6 +300,7 @@ func PopulateHomerConfigTables(configDBSession *gorm.DB, homerDBconfig string, f
 			LastName:   "Admin",
 			Department: "Develop",
 			UserGroup:  "admin",
+			SuperAdmin: true,
 			Hash:       string(hashedAdminPassword),
 			GUID:       uuid.NewV4().String(),
 		},
@@ -285,11 +312,29 @@ func PopulateHomerConfigTables(configDBSession *gorm.DB, homerDBconfig string, f
 			LastName:   "Support",
 			Department: "Develop",
 			UserGroup:  "admin",
+			SuperAdmin: true,
 			Hash:       string(hashedSupportPassword),
 			GUID:       uuid.NewV4().String(),
 		},
 	}
 
+	roles := []model.TableRole{
+		model.TableRole{
+			GUID:        uuid.NewV4().String(),
+			Name:        model.RoleDefault,
+			Description: "every user has it",
+			Permissions: model.DefaultPermissions,
+			Groups:      []string{},
+		},
+		model.TableRole{
+			GUID:        uuid.NewV4().String(),
+			Name:        "admin",
+			Description: "all permissions",
+			Permissions: model.Permissions,
+			Groups:      []string{"admin"},
+		},
+	}
+
 	authTokens := []model.TableAuthToken{
 		model.TableAuthToken{
 			ID:            1,
@@ -311,35 +356,35 @@ func PopulateHomerConfigTables(configDBSession *gorm.DB, homerDBconfig string, f
 	globalSettingData := []model.TableGlobalSettings{
 		model.TableGlobalSettings{
 			GUID:     uuid.NewV4().String(),
-			PartId:   1,
+			PartId:   10,
 			Category: "search",
 			Param:    "lokiserver",
 			Data:     jsonschema.LokiConfig,
 		},
 		model.TableGlobalSettings{
 			GUID:     uuid.NewV4().String(),
-			PartId:   1,
+			PartId:   10,
 			Category: "search",
 			Param:    "promserver",
 			Data:     jsonschema.PrometheusConfig,
 		},
 		model.TableGlobalSettings{
 			GUID:     uuid.NewV4().String(),
-			PartId:   1,
+			PartId:   10,
 			Category: "search",
 			Param:    "grafana",
 			Data:     jsonschema.GrafanaConfig,
 		},
 		model.TableGlobalSettings{
 			GUID:     uuid.NewV4().String(),
-			PartId:   1,
+			PartId:   10,
 			Category: "export",
 			Param:    "transaction",
 			Data:     jsonschema.ExportConfig,
 		},
 		model.TableGlobalSettings{
 			GUID:     uuid.NewV4().String(),
-			PartId:   1,
+			PartId:   10,
 			Category: "search",
 			Param:    "transaction",
 			Data:     jsonschema.TransactionConfig,
@@ -570,6 +615,34 @@ func PopulateHomerConfigTables(configDBSession *gorm.DB, homerDBconfig string, f
 		}
 	}
 
+	forceIt = force
+	tableName = "roles"
+
+	if !heputils.ElementExists(tablesPopulate, tableName) {
+		forceIt = false
+	}
+
+	if val, ok := createTables[tableName]; !ok || ok && val || forceIt {
+		/* roles data */
+		if lenTable == 0 || heputils.YesNo(tableName) {
+
+			heputils.Colorize(heputils.ColorRed, "reinstalling "+tableName)
+			configDBSession.Exec("TRUNCATE TABLE " + tableName)
+			for _, el := range roles {
+				db := configDBSession.Save(&el)
+				if db != nil && db.Error != nil {
+					logger.Error(fmt.Sprintf("Save failed for table [%s]: with error %s. Role: [%s]", tableName, db.Error, el.Name))
+				} else {
+					logger.Debug(fmt.Sprintf("Save for table [%s] was success. Role: [%s]", tableName, el.Name))
+				}
+			}
+			tableVersions = append(tableVersions, model.TableVersions{
+				NameTable:    tableName,
+				VersionTable: jsonschema.TableVersion[tableName],
+			})
+		}
+	}
+
 	forceIt = force
 	tableName = "global_settings"
 
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/lib/pq"
 )
 
 func (TableAlias) TableName() string {
@@ -23,6 +25,9 @@ type TableAlias struct {
 	// example: 5060
 	// required: true
 	Port *int `gorm:"column:port;type:int;default:0" json:"port" validate:"required,numeric"`
+	// last port of a range, 0 for a single port
+	// example: 5080
+	PortEnd *int `gorm:"column:port_end;type:int;default:0" json:"port_end" validate:"omitempty,numeric"`
 	// example: 32
 	// required: true
 	Mask *int `gorm:"column:mask;type:int" json:"mask" validate:"required,numeric"`
@@ -31,6 +36,18 @@ type TableAlias struct {
 	CaptureID  string    `gorm:"column:captureID;type:varchar(20)" json:"captureID" validate:"required"`
 	Status     *bool     `gorm:"column:status;type:bool" json:"status" validate:"required"`
 	CreateDate time.Time `gorm:"column:create_date;default:current_timestamp;not null" json:"-"`
+	// where an imported alias comes from, empty for manual aliases
+	// example: inventory
+	Source string `gorm:"column:source;type:varchar(100);default:''" json:"source"`
+	// tags like the site, carrier, customer or role of the address
+	// example: ["carrier-x","sbc"]
+	Tags pq.StringArray `gorm:"column:tags;type:text[]" json:"tags"`
+	// guid of the alias group, empty if the alias has no group
+	// example: 7d5b2c4e-4f6a-4c1e-9d0b-1b2a3c4d5e6f
+	GroupGUID string `gorm:"column:group_guid;type:varchar(36);default:''" json:"group_guid"`
+	// partition of the alias
+	// example: 10
+	PartId int `gorm:"column:partid;type:int;default:10;not null" json:"partid"`
 }
 
 // swagger:model AliasStructList
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func (TableAliasGroup) TableName() string {
+
+	return "alias_group"
+}
+
+// swagger:model AliasGroupStruct
+type TableAliasGroup struct {
+	Id   int    `gorm:"column:id;primary_key;AUTO_INCREMENT" json:"id"`
+	GUID string `gorm:"column:guid;type:uuid" json:"guid"`
+	// group name
+	// example: POP Frankfurt
+	// required: true
+	Name string `gorm:"column:name;type:varchar(250)" json:"name" validate:"required"`
+	// guid of the parent group, empty for a top group
+	// example: 7d5b2c4e-4f6a-4c1e-9d0b-1b2a3c4d5e6f
+	ParentGUID string `gorm:"column:parent_guid;type:varchar(36);default:''" json:"parent_guid"`
+	// tags of the group, every alias below the group has them
+	// example: ["carrier-x","site-fra"]
+	Tags pq.StringArray `gorm:"column:tags;type:text[]" json:"tags"`
+	// partition of the group
+	// example: 10
+	PartId     int       `gorm:"column:partid;type:int;default:10;not null" json:"partid"`
+	CreateDate time.Time `gorm:"column:create_date;default:current_timestamp;not null" json:"-"`
+}
+
+// swagger:model AliasGroupStructList
+type TableAliasGroupList struct {
+	Data []TableAliasGroup `json:"data"`
+}
+
+// swagger:model AliasGroupTree
+type AliasGroupTree struct {
+	TableAliasGroup
+	Groups  []*AliasGroupTree `json:"groups"`
+	Aliases []TableAlias      `json:"aliases"`
+}
+
+// swagger:model AliasGroupSuccessResponse
+type AliasGroupSuccessResponse struct {
+	// example: f2d0a540-bf21-4c0d-ac73-8696ea10855a
+	Data string `json:"data"`
+	// example: successfully created alias group
+	Message string `json:"message"`
+}
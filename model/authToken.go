@@ -2,9 +2,17 @@ package model
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
+const (
+	// TokenScopeAPI gives access to the normal API, tokens without a scope have it
+	TokenScopeAPI = "api"
+	// TokenScopeIngest gives access to /ingest/hep
+	TokenScopeIngest = "ingest"
+)
+
 func (TableAuthToken) TableName() string {
 	return "auth_token"
 }
@@ -24,6 +32,24 @@ type TableAuthToken struct {
 	UsageCalls    int             `gorm:"column:usage_calls;type:int;default:1" json:"usage_calls"`
 	LimitCalls    int             `gorm:"column:limit_calls;type:int;default:1000" json:"limit_calls"`
 	Active        *bool           `gorm:"column:active;type:bool" json:"active" validate:"required"`
+	Scope         string          `gorm:"column:scope;type:varchar(250);default:'api'" json:"scope"`
+	TenantGUID    string          `gorm:"column:tenant_guid;type:varchar(36)" json:"tenant_guid"`
+}
+
+// Scopes returns the scopes of the token, api if none has been set
+func (t *TableAuthToken) Scopes() []string {
+
+	var scopes []string
+	for _, scope := range strings.Split(t.Scope, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		return []string{TokenScopeAPI}
+	}
+	return scopes
 }
 
 // swagger:model AuthToken
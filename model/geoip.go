@@ -0,0 +1,37 @@
+package model
+
+// swagger:model GeoInfo
+type GeoInfo struct {
+	// example: DE
+	Country string `json:"country,omitempty"`
+	// example: Frankfurt am Main
+	City string `json:"city,omitempty"`
+	// example: 3320
+	ASN uint `json:"asn,omitempty"`
+	// example: Deutsche Telekom AG
+	Org string `json:"org,omitempty"`
+}
+
+// swagger:model GeoCount
+type GeoCount struct {
+	// the country, or the ASN and org, empty when the address is unknown
+	// example: DE
+	Country string `json:"country,omitempty"`
+	// example: 3320
+	ASN uint `json:"asn,omitempty"`
+	// example: Deutsche Telekom AG
+	Org string `json:"org,omitempty"`
+	// messages of the addresses
+	// example: 1520
+	Count int64 `json:"count"`
+	// distinct addresses
+	// example: 12
+	Addresses int `json:"addresses"`
+}
+
+// swagger:model GeoAggregateResponse
+type GeoAggregateResponse struct {
+	Data []GeoCount `json:"data"`
+	// example: 2
+	Total int `json:"total"`
+}
@@ -0,0 +1,106 @@
+package model
+
+import "time"
+
+const (
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobDone      = "done"
+	ImportJobFailed    = "failed"
+	ImportJobCancelled = "cancelled"
+)
+
+// swagger:model ImportOptions
+type ImportOptions struct {
+	// data node the rows are written to, the configured import node if empty
+	// example: localnode
+	Node string `json:"node"`
+	// captureId written to the protocol_header
+	// example: 2001
+	CaptureID string `json:"captureId"`
+	// shift all timestamps by this amount of seconds
+	// example: -3600
+	TimeShift int64 `json:"time_shift"`
+	// move the first packet to the current time, time_shift is applied on top
+	// example: false
+	Now bool `json:"now"`
+	// write all rows into this profile, i.e. 1_call
+	// example: 1_call
+	Profile string `json:"profile"`
+	// auto, pcap, asterisk, freeswitch, kamailio or custom
+	// example: auto
+	Format string `json:"format"`
+	// text logs: regex with the named group ts
+	TimestampRegex string `json:"timestamp_regex,omitempty"`
+	// text logs: go time layout of ts or unix
+	// example: 2006-01-02 15:04:05.000
+	TimestampLayout string `json:"timestamp_layout,omitempty"`
+	// text logs: regex with the named groups src_ip, src_port, dst_ip, dst_port or peer_ip, peer_port, dir
+	AddressRegex string `json:"address_regex,omitempty"`
+	// text logs: date of timestamps without one
+	// example: 2020-09-13
+	LogDate string `json:"log_date,omitempty"`
+}
+
+// swagger:model ImportJob
+type ImportJob struct {
+	// example: 6f7ab4b1-0d4f-4d25-9cc5-7a5c2b3a4f1e
+	ID string `json:"id"`
+	// queued, running, done, failed or cancelled
+	// example: running
+	Status string `json:"status"`
+	// example: trace.pcap
+	FileName string        `json:"filename"`
+	Options  ImportOptions `json:"options"`
+	// example: 1000
+	Frames int `json:"frames"`
+	// example: 950
+	PacketsRead int `json:"packets_read"`
+	// example: 940
+	Inserted int `json:"inserted"`
+	// example: 10
+	Rejected int      `json:"rejected"`
+	Errors   []string `json:"errors"`
+	// tables which have received rows
+	Tables     []string   `json:"tables"`
+	CreateDate time.Time  `json:"create_date"`
+	FinishDate *time.Time `json:"finish_date,omitempty"`
+	// the user who has started the job
+	// example: admin
+	Owner      string `json:"owner"`
+	TenantGUID string `json:"tenant,omitempty"`
+	// example: 10
+	PartID int `json:"partid"`
+}
+
+// ImportOwner is the user of an import request. A job is seen by its owner, by the
+// admins of its partition and tenant and by the super admins.
+type ImportOwner struct {
+	UserName   string
+	TenantGUID string
+	PartID     int
+	Admin      bool
+	SuperAdmin bool
+}
+
+// Sees tells whether the user may see, cancel and delete the job
+func (owner ImportOwner) Sees(job ImportJob) bool {
+	if owner.SuperAdmin {
+		return true
+	}
+	if job.PartID != owner.PartID || job.TenantGUID != owner.TenantGUID {
+		return false
+	}
+	return owner.Admin || job.Owner == owner.UserName
+}
+
+// Finished reports whether the job won't change anymore
+func (job *ImportJob) Finished() bool {
+	return job.Status == ImportJobDone || job.Status == ImportJobFailed || job.Status == ImportJobCancelled
+}
+
+// swagger:model ImportJobList
+type ImportJobList struct {
+	Count int         `json:"count"`
+	Data  []ImportJob `json:"data"`
+}
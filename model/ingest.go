@@ -0,0 +1,23 @@
+package model
+
+// swagger:model IngestError
+type IngestError struct {
+	// line of the request body, starting with 1
+	// example: 3
+	Line int `json:"line"`
+	// example: data_header.method has to be a string
+	Message string `json:"message"`
+}
+
+// swagger:model IngestResult
+type IngestResult struct {
+	// example: localnode
+	Node string `json:"node"`
+	// example: 100
+	Received int `json:"received"`
+	// example: 99
+	Inserted int `json:"inserted"`
+	// example: 1
+	Rejected int           `json:"rejected"`
+	Errors   []IngestError `json:"errors"`
+}
@@ -0,0 +1,44 @@
+package model
+
+const (
+	// sent by the client
+	LiveSubscribe   = "subscribe"
+	LiveUnsubscribe = "unsubscribe"
+	LivePing        = "ping"
+
+	// sent by the server
+	LiveSubscribed   = "subscribed"
+	LiveUnsubscribed = "unsubscribed"
+	LivePong         = "pong"
+	LiveData         = "data"
+	LiveOverflow     = "overflow"
+	LiveError        = "error"
+	LiveIdle         = "idle"
+	LiveExpired      = "expired"
+)
+
+// swagger:model LiveRequest
+type LiveRequest struct {
+	// subscribe, unsubscribe or ping
+	// example: subscribe
+	Type string `json:"type"`
+	// the filter of the subscription, timestamp.from can start it in the recent past
+	Search *SearchObject `json:"search,omitempty"`
+}
+
+// swagger:model LiveMessage
+type LiveMessage struct {
+	// subscribed, unsubscribed, pong, data, overflow, error, idle or expired
+	// example: data
+	Type string `json:"type"`
+	// number of rows in data
+	// example: 2
+	Total int `json:"total,omitempty"`
+	// rows formatted like the ones of /search/call/data
+	Data interface{} `json:"data,omitempty"`
+	// example: bad search object
+	Message string `json:"message,omitempty"`
+	// overflow: rows before this time (ms) have been skipped
+	// example: 1581793200000
+	SkippedTo int64 `json:"skipped_to,omitempty"`
+}
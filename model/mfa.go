@@ -0,0 +1,79 @@
+package model
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func (TableUserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// TableUserMFA is the TOTP of an internal user, it works once it has been confirmed
+// with a code
+type TableUserMFA struct {
+	Id       int    `gorm:"column:id;primary_key;AUTO_INCREMENT" json:"-"`
+	UserGUID string `gorm:"column:user_guid;type:varchar(50);unique_index;not null" json:"-"`
+	/* the base32 secret, the codes can't be checked without it */
+	Secret  string `gorm:"column:secret;type:varchar(64);not null" json:"-"`
+	Enabled bool   `gorm:"column:enabled;type:bool;default:false" json:"enabled"`
+	/* the time step of the last code, a code can't be used twice */
+	LastCounter int64 `gorm:"column:last_counter;type:bigint;default:0" json:"-"`
+	/* the bcrypt hashes of the recovery codes not used yet */
+	RecoveryCodes pq.StringArray `gorm:"column:recovery_codes;type:text[]" json:"-"`
+	CreateDate    time.Time      `gorm:"column:create_date;default:current_timestamp;not null" json:"create_date"`
+	EnableDate    *time.Time     `gorm:"column:enable_date" json:"enable_date,omitempty"`
+}
+
+// swagger:model MFAStatus
+type MFAStatus struct {
+	// the user logs in with a code
+	Enabled bool `json:"enabled"`
+	// the policy wants a code from the user
+	Required bool `json:"required"`
+	// the recovery codes left
+	// example: 10
+	RecoveryCodes int `json:"recovery_codes"`
+}
+
+// swagger:model MFAEnrolment
+type MFAEnrolment struct {
+	// example: JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP
+	Secret string `json:"secret"`
+	// the URI of the QR code
+	// example: otpauth://totp/Homer:admin?algorithm=SHA1&digits=6&issuer=Homer&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP
+	URI string `json:"uri"`
+	// they are shown once, each one logs in once without the app
+	// example: ["k3v9q-2mxa7","p8c4r-t6wd2"]
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// swagger:model MFAChallenge
+type MFAChallenge struct {
+	MFARequired bool `json:"mfa_required"`
+	// the challenge to send with the code to /auth/mfa
+	// example: 5d1e6a3b-0c8f-4b7e-9a2d-3f4e5a6b7c8d
+	Challenge string `json:"challenge"`
+	// false when the policy wants a code but the user has to enrol first at /auth/mfa/enrol
+	Enrolled bool `json:"enrolled"`
+	// the seconds to send the code
+	// example: 300
+	ExpiresIn int `json:"expires_in"`
+}
+
+// swagger:model MFARequest
+type MFARequest struct {
+	// the challenge of the login, none for the logged in user
+	Challenge string `json:"challenge"`
+	// a code of the app or a recovery code
+	// example: 287082
+	Code string `json:"code"`
+}
+
+// swagger:model MFAPolicy
+type MFAPolicy struct {
+	// the user groups which must log in with a code
+	// example: ["admin","support"]
+	Groups []string `json:"groups"`
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// permissions of the roles
+const (
+	PermissionSearchRead      = "search:read"
+	PermissionExportPcap      = "export:pcap"
+	PermissionImportPcap      = "import:pcap"
+	PermissionImportDelete    = "import:delete"
+	PermissionAliasWrite      = "alias:write"
+	PermissionMappingWrite    = "mapping:write"
+	PermissionSettingsWrite   = "settings:write"
+	PermissionStatsRead       = "stats:read"
+	PermissionUsersAdmin      = "users:admin"
+	PermissionTokensAdmin     = "tokens:admin"
+	PermissionDashboardsShare = "dashboards:share"
+	PermissionHepRelay        = "hep:relay"
+)
+
+// Permissions are all the permissions, admin users have them
+var Permissions = []string{
+	PermissionSearchRead,
+	PermissionExportPcap,
+	PermissionImportPcap,
+	PermissionImportDelete,
+	PermissionAliasWrite,
+	PermissionMappingWrite,
+	PermissionSettingsWrite,
+	PermissionStatsRead,
+	PermissionUsersAdmin,
+	PermissionTokensAdmin,
+	PermissionDashboardsShare,
+	PermissionHepRelay,
+}
+
+// RoleDefault is the role every user has, whatever the group
+const RoleDefault = "default"
+
+// DefaultPermissions are the permissions of the default role, what users without
+// admin could do before the roles
+var DefaultPermissions = []string{
+	PermissionSearchRead,
+	PermissionExportPcap,
+	PermissionImportPcap,
+	PermissionDashboardsShare,
+}
+
+// IsPermission checks the name of a permission
+func IsPermission(permission string) bool {
+	for _, val := range Permissions {
+		if val == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (TableRole) TableName() string {
+
+	return "roles"
+}
+
+// swagger:model RoleStruct
+type TableRole struct {
+	Id   int    `gorm:"column:id;primary_key;AUTO_INCREMENT" json:"id"`
+	GUID string `gorm:"column:guid;type:uuid" json:"guid"`
+	// example: support
+	// required: true
+	Name string `gorm:"column:name;type:varchar(100);unique_index" json:"name" validate:"required"`
+	// example: support engineers, they can edit aliases
+	Description string `gorm:"column:description;type:varchar(250)" json:"description"`
+	// example: ["search:read","export:pcap","alias:write"]
+	Permissions pq.StringArray `gorm:"column:permissions;type:text[]" json:"permissions"`
+	// the user groups which have the role
+	// example: ["support","noc"]
+	Groups     pq.StringArray `gorm:"column:groups;type:text[]" json:"groups"`
+	CreateDate time.Time      `gorm:"column:create_date;default:current_timestamp;not null" json:"-"`
+}
+
+// swagger:model RoleStructList
+type TableRoleList struct {
+	Data []TableRole `json:"data"`
+}
+
+// swagger:model RoleSuccessResponse
+type RoleSuccessResponse struct {
+	// example: f2d0a540-bf21-4c0d-ac73-8696ea10855a
+	Data string `json:"data"`
+	// example: successfully created role
+	Message string `json:"message"`
+}
@@ -189,6 +189,14 @@ type CallElement struct {
 	Destination int `json:"destination"`
 	// example: 1633374982350
 	MicroTs int64 `json:"micro_ts"`
+	// STIR/SHAKEN verification of the Identity header
+	// example: invalid
+	StirStatus string `json:"stir_status,omitempty"`
+	// example: A
+	StirAttest string `json:"stir_attest,omitempty"`
+	// country, city and ASN of the addresses when GeoIP is enabled
+	SrcGeo *GeoInfo `json:"srcGeo,omitempty"`
+	DstGeo *GeoInfo `json:"dstGeo,omitempty"`
 }
 
 // swagger:model SearchTransactionLog
@@ -359,12 +367,26 @@ type SearchTransaction struct {
 		// example: {127.0.0.1: localhost, 100.20.15.1: party1}
 		Alias    map[string]string `json:"alias"`
 		Calldata []CallElement     `json:"calldata"`
+		// RFC 3261/3262/3311 findings of the messages and dialogs
+		Lint []LintFinding `json:"lint"`
 	} `json:"data"`
 	// example: ["callid", "srcIp", "srcPort"]
 	Keys  []string `json:"keys"`
 	Total int      `json:"total"`
 }
 
+// LintFinding is a broken SIP rule of a message
+type LintFinding struct {
+	// example: via-branch-cookie
+	Rule string `json:"rule"`
+	// example: error
+	Severity string `json:"severity"`
+	// example: 5162
+	MessageID float64 `json:"message_id"`
+	// example: branch "74bf9"
+	Text string `json:"text"`
+}
+
 //swagger:model SearchTransactionRequest
 type SearchTransactionRequest struct {
 	Config struct {
@@ -673,5 +695,13 @@ type MessageDecoded struct {
 			// example: pcap_file
 			Type string `json:"_type"`
 		} `json:"decoded"`
+		// SIP message decoded natively: start line, headers in order, typed headers and body
+		Sip map[string]interface{} `json:"sip"`
+		// session description of the SIP body
+		Sdp map[string]interface{} `json:"sdp"`
+		// ISUP message of a SIP-I body or of HEP type 54
+		Isup map[string]interface{} `json:"isup"`
+		// STIR/SHAKEN verification of the Identity header
+		Stir map[string]interface{} `json:"stir"`
 	} `json:"data"`
 }
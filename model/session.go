@@ -0,0 +1,56 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+func (TableSession) TableName() string {
+	return "sessions"
+}
+
+// swagger:model SessionStruct
+type TableSession struct {
+	ID int `gorm:"column:id;primary_key;AUTO_INCREMENT" json:"-"`
+	// the id of the session in the JWTs
+	// example: 4c1b2d9e-58a3-4f0e-b7b6-2a1d5e3c9f10
+	GUID string `gorm:"column:guid;type:uuid;unique_index" json:"guid"`
+	// example: admin
+	UserName string `gorm:"column:username;type:varchar(100);index" json:"username"`
+	UserGUID string `gorm:"column:user_guid;type:varchar(50)" json:"user_guid"`
+	// example: 10
+	PartId     int  `gorm:"column:partid;type:int;default:10;not null" json:"partid"`
+	SuperAdmin bool `gorm:"column:superadmin;type:bool;default:false" json:"superadmin"`
+	/* the user is in the users table, it is loaded again at every refresh */
+	Internal bool `gorm:"column:internal;type:bool;default:false" json:"internal"`
+	/* the SHA-256 of the refresh token and of the one it replaced, to find out reuse */
+	RefreshHash  string `gorm:"column:refresh_hash;type:varchar(64);unique_index" json:"-"`
+	PreviousHash string `gorm:"column:previous_hash;type:varchar(64);index" json:"-"`
+	/* the claims of the JWTs, for the users who aren't internal */
+	UserObject   json.RawMessage `gorm:"column:user_object;type:json" json:"-"`
+	IPAddress    string          `gorm:"column:ip_address;type:varchar(100)" json:"ip_address"`
+	UserAgent    string          `gorm:"column:user_agent;type:varchar(250)" json:"user_agent"`
+	CreateDate   time.Time       `gorm:"column:create_date;default:current_timestamp;not null" json:"create_date"`
+	LastUsedDate time.Time       `gorm:"column:lastused_date;not null" json:"lastused_date"`
+	ExpireDate   time.Time       `gorm:"column:expire_date;not null" json:"expire_date"`
+	RevokeDate   *time.Time      `gorm:"column:revoke_date" json:"revoke_date,omitempty"`
+}
+
+// swagger:model SessionStructList
+type TableSessionList struct {
+	Data []TableSession `json:"data"`
+}
+
+// swagger:model RefreshTokenRequest
+type RefreshTokenRequest struct {
+	// required: true
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// swagger:model SessionSuccessResponse
+type SessionSuccessResponse struct {
+	// example: 4c1b2d9e-58a3-4f0e-b7b6-2a1d5e3c9f10
+	Data string `json:"data"`
+	// example: successfully ended session
+	Message string `json:"message"`
+}
@@ -12,6 +12,12 @@ type AppContext struct {
 	Admin        bool   `json:"admin"`
 	UserGroup    string `json:"usergroup"`
 	ExternalAuth bool   `json:"externalauth"`
+	/* the permissions of the roles of the user */
+	Permissions []string `json:"permissions"`
+	/* the guid of the tenant of the user, empty for all data */
+	Tenant string `json:"tenant"`
+	/* the partition of the config of the user */
+	PartID int `json:"partid"`
 }
 
 // swagger:model SuccessResponse
@@ -39,4 +45,31 @@ type KeyContext struct {
 	UserAdmin   bool           `json:"admin"`
 	AuthKey     string         `json:"auth-key"`
 	Auth        bool           `json:"auth"`
+	Scopes      []string       `json:"scopes"`
+	Permissions []string       `json:"permissions"`
+	Tenant      string         `json:"tenant"`
+	PartID      int            `json:"partid"`
+}
+
+// HasPermission checks if the roles of the token user give the permission
+func (k KeyContext) HasPermission(permission string) bool {
+	if k.UserAdmin {
+		return true
+	}
+	for _, val := range k.Permissions {
+		if val == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope checks if the token has been given the scope
+func (k KeyContext) HasScope(scope string) bool {
+	for _, val := range k.Scopes {
+		if val == scope {
+			return true
+		}
+	}
+	return false
 }
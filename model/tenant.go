@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func (TableTenant) TableName() string {
+
+	return "tenants"
+}
+
+// swagger:model TenantStruct
+type TableTenant struct {
+	Id   int    `gorm:"column:id;primary_key;AUTO_INCREMENT" json:"id"`
+	GUID string `gorm:"column:guid;type:uuid" json:"guid"`
+	// example: acme
+	// required: true
+	Name string `gorm:"column:name;type:varchar(100);unique_index" json:"name" validate:"required"`
+	// example: ACME Telecom
+	Description string `gorm:"column:description;type:varchar(250)" json:"description"`
+	// the captureIds of the tenant, all if empty
+	// example: ["2001","2002"]
+	CaptureIDs pq.StringArray `gorm:"column:capture_ids;type:text[]" json:"capture_ids"`
+	// the database nodes of the tenant, all if empty
+	// example: ["localnode"]
+	Nodes pq.StringArray `gorm:"column:nodes;type:text[]" json:"nodes"`
+	// the source or destination networks of the tenant, all if empty
+	// example: ["10.20.0.0/16","192.168.1.10"]
+	Networks pq.StringArray `gorm:"column:networks;type:text[]" json:"networks"`
+	// the profiles (hep_proto_<profile>) of the tenant, all if empty
+	// example: ["1_call","1_registration"]
+	Profiles pq.StringArray `gorm:"column:profiles;type:text[]" json:"profiles"`
+	// the users of the groups belong to the tenant when they haven't been given one,
+	// for LDAP and OAuth users
+	// example: ["acme"]
+	Groups     pq.StringArray `gorm:"column:groups;type:text[]" json:"groups"`
+	CreateDate time.Time      `gorm:"column:create_date;default:current_timestamp;not null" json:"-"`
+}
+
+// swagger:model TenantStructList
+type TableTenantList struct {
+	Data []TableTenant `json:"data"`
+}
+
+// swagger:model TenantSuccessResponse
+type TenantSuccessResponse struct {
+	// example: 3a8f1b3c-4d45-4a8e-9d0f-6b2c1e7d5a90
+	Data string `json:"data"`
+	// example: successfully created tenant
+	Message string `json:"message"`
+}
@@ -45,6 +45,32 @@ type TableUser struct {
 	CreatedAt       time.Time `gorm:"column:created_at;default:current_timestamp;not null" json:"-"`
 	ExternalProfile string    `gorm:"-" json:"-"`
 	Avatar          string    `gorm:"-" json:"-"`
+	// the permissions of the roles of the user, set at login
+	Permissions []string `gorm:"-" json:"-"`
+	// the tenant of the user, it sees only the data of the tenant. All data if empty.
+	// example: 3a8f1b3c-4d45-4a8e-9d0f-6b2c1e7d5a90
+	TenantGUID string `gorm:"column:tenant_guid;type:varchar(36)" json:"tenant_guid"`
+	// an admin who manages all partitions
+	SuperAdmin bool `gorm:"column:superadmin;type:bool;default:false" json:"superadmin"`
+	// where the user comes from, empty for the users made here and oidc:<provider>
+	// for the ones an OpenID Connect provider has created
+	// example: oidc:keycloak
+	Source string `gorm:"column:source;type:varchar(100)" json:"source"`
+	// the issuer and subject (iss|sub) of the provider of the user, it stays the same
+	// when the user name is given to someone else
+	Subject string `gorm:"column:subject;type:varchar(300);index:idx_subject" json:"-"`
+}
+
+// DefaultPartition is the partid of the config of users without one and the config
+// a partition uses as long as it doesn't have its own
+const DefaultPartition = 10
+
+// Partition returns the partid, the default one if none has been set
+func Partition(partid int) int {
+	if partid <= 0 {
+		return DefaultPartition
+	}
+	return partid
 }
 
 type HTTPAUTHResp struct {
@@ -60,6 +86,12 @@ type UserTokenSuccessfulResponse struct {
 	// the uuid
 	// example: b9f6q23a-0bde-41ce-cd36-da3dbc17ea12
 	Scope string `json:"scope"`
+	// gets a new token at /auth/refresh, it can be used once
+	// example: 3q2-7wXo0sTz4Uo8bHlVZf1oY9Yw5d8Kq0m9vQe2rJc
+	RefreshToken string `json:"refresh_token"`
+	// the seconds the token is valid
+	// example: 900
+	ExpiresIn int `json:"expires_in"`
 	// the uuid
 	User struct {
 		Admin bool `json:"admin"`
@@ -141,6 +173,9 @@ type OAuth2MapToken struct {
 	ExpireDate  time.Time       `json:"expire_date"`
 	Oauth2Token *oauth2.Token   `json:"-"`
 	ProfileJson json.RawMessage `json:"profile_json"`
+	/* the user of an OpenID Connect login, it has no profile to fetch */
+	User     *TableUser `json:"-"`
+	Internal bool       `json:"-"`
 }
 
 //swagger:model AuthTypeList
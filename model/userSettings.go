@@ -67,4 +67,9 @@ type UserProfile struct {
 	UserAdmin       bool   `json:"admin"`
 	ExternalAuth    bool   `json:"external_auth"`
 	ExternalProfile string `json:"external_profile"`
+	// example: ["search:read","export:pcap"]
+	Permissions []string `json:"permissions"`
+	// example: 10
+	PartID     int  `json:"partid"`
+	SuperAdmin bool `json:"superadmin"`
 }
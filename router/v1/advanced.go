@@ -6,6 +6,7 @@ import (
 	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 )
 
 func RouteAdvancedApis(acc *echo.Group, configSession *gorm.DB) {
@@ -17,7 +18,7 @@ func RouteAdvancedApis(acc *echo.Group, configSession *gorm.DB) {
 	}
 	acc.GET("/advanced", ac.GetAll)
 	acc.GET("/advanced/:guid", ac.GetAdvancedAgainstGUID)
-	acc.POST("/advanced", ac.AddAdvanced, auth.IsAdmin)
-	acc.PUT("/advanced/:guid", ac.UpdateAdvancedAgainstGUID, auth.IsAdmin)
-	acc.DELETE("/advanced/:guid", ac.DeleteAdvancedAgainstGUID, auth.IsAdmin)
+	acc.POST("/advanced", ac.AddAdvanced, auth.RequirePermission(model.PermissionSettingsWrite))
+	acc.PUT("/advanced/:guid", ac.UpdateAdvancedAgainstGUID, auth.RequirePermission(model.PermissionSettingsWrite))
+	acc.DELETE("/advanced/:guid", ac.DeleteAdvancedAgainstGUID, auth.RequirePermission(model.PermissionSettingsWrite))
 }
@@ -3,16 +3,20 @@ package apirouterv1
 import (
 	"github.com/jinzhu/gorm"
 	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 )
 
-func RouteAgentsubApis(acc *echo.Group, session *gorm.DB) {
+func RouteAgentsubApis(acc *echo.Group, session *gorm.DB, dataSession map[string]*gorm.DB) {
 	// initialize service of user
-	AgentsubService := service.AgentsubService{ServiceConfig: service.ServiceConfig{Session: session}}
+	AgentsubService := service.AgentsubService{ServiceConfig: service.ServiceConfig{Session: session}, DataSession: dataSession}
+	tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: session}}
 	// initialize user controller
 	ass := controllerv1.AgentsubController{
 		AgentsubService: &AgentsubService,
+		TenantService:   &tenantService,
 	}
 
 	// create agent subscribe
@@ -24,11 +28,11 @@ func RouteAgentsubApis(acc *echo.Group, session *gorm.DB) {
 	acc.GET("/agent/type/:type", ass.GetAgentsubByType)
 
 	acc.GET("/agent/subscribe/:guid", ass.GetAgentsubAgainstGUID)
-	acc.DELETE("/agent/subscribe/:guid", ass.DeleteAgentsubAgainstGUID)
-	acc.PUT("/agent/subscribe/:guid", ass.UpdateAgentsubAgainstGUID)
+	acc.DELETE("/agent/subscribe/:guid", ass.DeleteAgentsubAgainstGUID, auth.RequirePermission(model.PermissionSettingsWrite))
+	acc.PUT("/agent/subscribe/:guid", ass.UpdateAgentsubAgainstGUID, auth.RequirePermission(model.PermissionSettingsWrite))
 
 	/* search */
-	acc.POST("/agent/search/:guid/:type", ass.GetAgentSearchByTypeAndGUID)
+	acc.POST("/agent/search/:guid/:type", ass.GetAgentSearchByTypeAndGUID, auth.RequirePermission(model.PermissionSearchRead))
 
 }
 
@@ -6,6 +6,7 @@ import (
 	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 )
 
 func RouteAliasApis(acc *echo.Group, configSession *gorm.DB) {
@@ -13,11 +14,20 @@ func RouteAliasApis(acc *echo.Group, configSession *gorm.DB) {
 	aliasService := service.AliasService{ServiceConfig: service.ServiceConfig{Session: configSession}}
 	// initialize user controller
 	src := controllerv1.AliasController{
-		AliasService: &aliasService,
+		AliasService:     &aliasService,
+		AliasSyncService: service.NewAliasSyncService(configSession),
 	}
 	acc.GET("/alias", src.GetAllAlias)
-	acc.POST("/alias", src.AddAlias, auth.IsAdmin)
-	acc.DELETE("/alias/:guid", src.DeleteAlias, auth.IsAdmin)
-	acc.PUT("/alias/:guid", src.UpdateAlias, auth.IsAdmin)
+	acc.GET("/alias/export", src.ExportAlias)
+	acc.POST("/alias/import", src.ImportAlias, auth.RequirePermission(model.PermissionAliasWrite))
+	acc.POST("/alias/sync", src.SyncAlias, auth.RequirePermission(model.PermissionAliasWrite))
+	acc.POST("/alias", src.AddAlias, auth.RequirePermission(model.PermissionAliasWrite))
+	acc.DELETE("/alias/:guid", src.DeleteAlias, auth.RequirePermission(model.PermissionAliasWrite))
+	acc.PUT("/alias/:guid", src.UpdateAlias, auth.RequirePermission(model.PermissionAliasWrite))
+	acc.GET("/alias/group", src.GetAllAliasGroup)
+	acc.GET("/alias/group/tree", src.GetAliasGroupTree)
+	acc.POST("/alias/group", src.AddAliasGroup, auth.RequirePermission(model.PermissionAliasWrite))
+	acc.PUT("/alias/group/:guid", src.UpdateAliasGroup, auth.RequirePermission(model.PermissionAliasWrite))
+	acc.DELETE("/alias/group/:guid", src.DeleteAliasGroup, auth.RequirePermission(model.PermissionAliasWrite))
 
 }
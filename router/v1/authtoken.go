@@ -6,6 +6,7 @@ import (
 	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 )
 
 func RouteAuthTokenApis(acc *echo.Group, session *gorm.DB) {
@@ -18,12 +19,12 @@ func RouteAuthTokenApis(acc *echo.Group, session *gorm.DB) {
 
 	// create agent subscribe
 	/************************************/
-	acc.GET("/token/auth", ats.GetAuthtoken, auth.IsAdmin)
-	acc.GET("/token/auth/:guid", ats.GetAuthtokenAgainstGUID, auth.IsAdmin)
+	acc.GET("/token/auth", ats.GetAuthtoken, auth.RequirePermission(model.PermissionTokensAdmin))
+	acc.GET("/token/auth/:guid", ats.GetAuthtokenAgainstGUID, auth.RequirePermission(model.PermissionTokensAdmin))
 
-	acc.POST("/token/auth", ats.AddAuthtoken, auth.IsAdmin)
-	acc.PUT("/token/auth/:guid", ats.UpdateAuthtokenAgainstGUID, auth.IsAdmin)
+	acc.POST("/token/auth", ats.AddAuthtoken, auth.RequirePermission(model.PermissionTokensAdmin))
+	acc.PUT("/token/auth/:guid", ats.UpdateAuthtokenAgainstGUID, auth.RequirePermission(model.PermissionTokensAdmin))
 
-	acc.DELETE("/token/auth/:guid", ats.DeleteAuthtokenAgainstGUID, auth.IsAdmin)
+	acc.DELETE("/token/auth/:guid", ats.DeleteAuthtokenAgainstGUID, auth.RequirePermission(model.PermissionTokensAdmin))
 
 }
@@ -0,0 +1,25 @@
+package apirouterv1
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteHepCollectorApis
+func RouteHepCollectorApis(acc *echo.Group, hepCollectorService *service.HepCollectorService) {
+
+	/* collector is disabled */
+	if hepCollectorService == nil {
+		return
+	}
+
+	// initialize collector controller
+	hc := controllerv1.HepCollectorController{
+		HepCollectorService: hepCollectorService,
+	}
+
+	acc.GET("/hep/collector/stats", hc.GetStats, auth.RequirePermission(model.PermissionStatsRead))
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 )
 
 func RouteHepsubApis(acc *echo.Group, session *gorm.DB) {
@@ -20,7 +21,7 @@ func RouteHepsubApis(acc *echo.Group, session *gorm.DB) {
 	acc.GET("/hepsub/protocol/:id/:transaction", hs.GetHepSubFields)
 	acc.GET("/hepsub/protocol", hs.GetHepSub)
 	acc.GET("/hepsub/protocol/:guid", hs.GetHepSubAgainstGUID)
-	acc.POST("/hepsub/protocol", hs.AddHepSub, auth.IsAdmin)
-	acc.PUT("/hepsub/protocol/:guid", hs.UpdateHepSubAgainstGUID, auth.IsAdmin)
-	acc.DELETE("/hepsub/protocol/:guid", hs.DeleteHepSubAgainstGUID, auth.IsAdmin)
+	acc.POST("/hepsub/protocol", hs.AddHepSub, auth.RequirePermission(model.PermissionSettingsWrite))
+	acc.PUT("/hepsub/protocol/:guid", hs.UpdateHepSubAgainstGUID, auth.RequirePermission(model.PermissionSettingsWrite))
+	acc.DELETE("/hepsub/protocol/:guid", hs.DeleteHepSubAgainstGUID, auth.RequirePermission(model.PermissionSettingsWrite))
 }
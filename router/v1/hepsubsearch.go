@@ -3,20 +3,24 @@ package apirouterv1
 import (
 	"github.com/jinzhu/gorm"
 	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 )
 
 func RouteHepSubSearch(acc *echo.Group, session *gorm.DB) {
 	// initialize service of user
 	HepsubsearchService := service.HepsubsearchService{ServiceConfig: service.ServiceConfig{Session: session}}
+	tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: session}}
 	// initialize user controller
 	hss := controllerv1.HepsubsearchController{
 		HepsubsearchService: &HepsubsearchService,
+		TenantService:       &tenantService,
 	}
 
 	// create agent subscribe
 	/************************************/
-	acc.POST("/hepsub/search", hss.DoHepsubsearch)
+	acc.POST("/hepsub/search", hss.DoHepsubsearch, auth.RequirePermission(model.PermissionSearchRead))
 
 }
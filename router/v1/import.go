@@ -0,0 +1,36 @@
+package apirouterv1
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteImportApis
+func RouteImportApis(acc *echo.Group, dataSession map[string]*gorm.DB, configSession *gorm.DB) {
+	// initialize service of import
+	importService := service.ImportService{ServiceData: service.ServiceData{Session: dataSession}}
+	tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: configSession}}
+
+	// initialize import controller
+	ic := controllerv1.ImportController{
+		ImportService: &importService,
+		TenantService: &tenantService,
+	}
+
+	importPcap := auth.RequirePermission(model.PermissionImportPcap)
+
+	/* synchronous import */
+	acc.POST("/import/data/pcap", ic.GetDataAsPCap, importPcap)
+	acc.POST("/import/data/pcap/now", ic.GetDataAsPCapNow, importPcap)
+
+	/* background jobs */
+	acc.POST("/import/job", ic.CreateImportJob, importPcap)
+	acc.GET("/import/job", ic.GetImportJobs, importPcap)
+	acc.GET("/import/job/:id", ic.GetImportJob, importPcap)
+	acc.POST("/import/job/:id/cancel", ic.CancelImportJob, importPcap)
+	acc.DELETE("/import/job/:id", ic.DeleteImportJob, auth.RequirePermission(model.PermissionImportDelete))
+}
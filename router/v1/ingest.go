@@ -0,0 +1,32 @@
+package apirouterv1
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/config"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteIngestApis
+func RouteIngestApis(acc *echo.Group, dataSession map[string]*gorm.DB, configSession *gorm.DB) {
+
+	if !config.Setting.INGEST_SETTINGS.Enable {
+		return
+	}
+
+	// initialize service of ingest
+	ingestService := service.IngestService{ServiceData: service.ServiceData{Session: dataSession}, ConfigSession: configSession}
+	tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: configSession}}
+
+	// initialize ingest controller
+	ic := controllerv1.IngestController{
+		IngestService: &ingestService,
+		TenantService: &tenantService,
+	}
+
+	/* only admins and tokens with the ingest scope */
+	auth.ScopeRoute(acc.POST("/ingest/hep", ic.IngestHep), model.TokenScopeIngest)
+}
@@ -0,0 +1,36 @@
+package apirouterv1
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	"github.com/sipcapture/homer-app/config"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteLiveApis
+func RouteLiveApis(acc *echo.Group, dataSession map[string]*gorm.DB, configSession *gorm.DB) {
+
+	if !config.Setting.LIVE_SETTINGS.Enable {
+		return
+	}
+
+	// initialize services of live search
+	searchService := service.SearchService{ServiceData: service.ServiceData{Session: dataSession}}
+	aliasService := service.AliasService{ServiceConfig: service.ServiceConfig{Session: configSession}}
+	settingService := service.UserSettingsService{ServiceConfig: service.ServiceConfig{Session: configSession}}
+	tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: configSession}}
+
+	// initialize live controller
+	lc := controllerv1.LiveController{
+		SearchService:  &searchService,
+		SettingService: &settingService,
+		AliasService:   &aliasService,
+		TenantService:  &tenantService,
+	}
+
+	/* browsers can't set headers on WebSockets */
+	auth.QueryTokenRoute(acc.GET("/live/ws", lc.LiveTail, auth.RequirePermission(model.PermissionSearchRead)))
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 )
 
 func RouteMappingdApis(acc *echo.Group, session *gorm.DB) {
@@ -17,13 +18,13 @@ func RouteMappingdApis(acc *echo.Group, session *gorm.DB) {
 	}
 	// get all dashboards
 	acc.GET("/mapping/protocol", mpc.GetMapping)
-	acc.GET("/mapping/protocol/reset", mpc.ResetMapping)
-	acc.GET("/mapping/protocol/reset/:uuid", mpc.ResetMappingAgainstUUID)
+	acc.GET("/mapping/protocol/reset", mpc.ResetMapping, auth.RequirePermission(model.PermissionMappingWrite))
+	acc.GET("/mapping/protocol/reset/:uuid", mpc.ResetMappingAgainstUUID, auth.RequirePermission(model.PermissionMappingWrite))
 	acc.GET("/mapping/protocol/:id/:transaction", mpc.GetMappingFields)
 	acc.GET("/mapping/protocol/:guid", mpc.GetMappingAgainstGUID)
-	acc.POST("/mapping/protocol", mpc.AddMapping, auth.IsAdmin)
-	acc.PUT("/mapping/protocol/:guid", mpc.UpdateMappingAgainstGUID, auth.IsAdmin)
-	acc.DELETE("/mapping/protocol/:guid", mpc.DeleteMappingAgainstGUID, auth.IsAdmin)
+	acc.POST("/mapping/protocol", mpc.AddMapping, auth.RequirePermission(model.PermissionMappingWrite))
+	acc.PUT("/mapping/protocol/:guid", mpc.UpdateMappingAgainstGUID, auth.RequirePermission(model.PermissionMappingWrite))
+	acc.DELETE("/mapping/protocol/:guid", mpc.DeleteMappingAgainstGUID, auth.RequirePermission(model.PermissionMappingWrite))
 
 	/* search smart */
 	acc.GET("/smart/search/tag/:hepid/:profile", mpc.GetSmartHepProfile)
@@ -0,0 +1,38 @@
+package apirouterv1
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteMFAApis
+func RouteMFAApis(acc *echo.Group, session *gorm.DB) {
+	// initialize service of 2FA
+	mfaService := service.MFAService{ServiceConfig: service.ServiceConfig{Session: session}}
+	// initialize service of sessions
+	sessionService := service.SessionService{ServiceConfig: service.ServiceConfig{Session: session}}
+	// initialize 2FA controller
+	mc := controllerv1.MFAController{
+		MFAService:     &mfaService,
+		SessionService: &sessionService,
+	}
+
+	usersAdmin := auth.RequirePermission(model.PermissionUsersAdmin)
+
+	// 2FA of the current user
+	acc.GET("/users/mfa", mc.GetStatus)
+	acc.POST("/users/mfa/enrol", mc.Enrol)
+	acc.POST("/users/mfa/confirm", mc.Confirm)
+	acc.POST("/users/mfa/disable", mc.Disable)
+
+	// reset the 2FA of a user who lost the app
+	acc.DELETE("/users/:userGuid/mfa", mc.ResetUser, usersAdmin)
+
+	// user groups which must use 2FA
+	acc.GET("/mfa/policy", mc.GetPolicy, usersAdmin)
+	acc.PUT("/mfa/policy", mc.SetPolicy, usersAdmin, auth.RequireSuperAdmin())
+}
@@ -0,0 +1,29 @@
+package apirouterv1
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/config"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/utils/oidc"
+)
+
+// RouteOIDCApis
+func RouteOIDCApis(acc *echo.Group, session *gorm.DB, providers map[string]*oidc.Provider) {
+	// initialize service of OpenID Connect
+	oidcService := service.OIDCService{ServiceConfig: service.ServiceConfig{Session: session}}
+	// initialize OpenID Connect controller
+	oc := controllerv1.OIDCController{
+		OIDCService: &oidcService,
+		Providers:   providers,
+		Logins:      oidc.NewLogins(time.Duration(config.Setting.OIDC_SETTINGS.ExpireLogin) * time.Minute),
+	}
+
+	// send the browser to the provider
+	acc.GET("/oidc/login/:provider", oc.LoginRedirect)
+	// the provider sends the browser back
+	acc.GET("/oidc/callback/:provider", oc.Callback)
+}
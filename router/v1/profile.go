@@ -6,15 +6,17 @@ import (
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
 	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/decoder"
 )
 
 // comments
-func RouteProfileApis(acc *echo.Group, session *gorm.DB, databaseNodeMap []model.DatabasesMap) {
+func RouteProfileApis(acc *echo.Group, session *gorm.DB, databaseNodeMap []model.DatabasesMap, decoders *decoder.Registry) {
 	// initialize service of user
 	ProfileService := service.ProfileService{ServiceConfig: service.ServiceConfig{Session: session}, DatabaseNodeMap: &databaseNodeMap}
 	// initialize user controller
 	hs := controllerv1.ProfileController{
 		ProfileService: &ProfileService,
+		Decoders:       decoders,
 	}
 	// get all dashboards
 	acc.GET("/admin/profiles", hs.GetDashboardList)
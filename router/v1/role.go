@@ -0,0 +1,30 @@
+package apirouterv1
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteRoleApis
+func RouteRoleApis(acc *echo.Group, session *gorm.DB) {
+	// initialize service of roles
+	roleService := service.RoleService{ServiceConfig: service.ServiceConfig{Session: session}}
+	// initialize role controller
+	rc := controllerv1.RoleController{
+		RoleService: &roleService,
+	}
+
+	usersAdmin := auth.RequirePermission(model.PermissionUsersAdmin)
+	/* the roles are shared by all partitions */
+	superAdmin := auth.RequireSuperAdmin()
+
+	acc.GET("/roles", rc.GetAllRoles, usersAdmin)
+	acc.GET("/roles/permissions", rc.GetPermissions)
+	acc.POST("/roles", rc.AddRole, usersAdmin, superAdmin)
+	acc.PUT("/roles/:guid", rc.UpdateRole, usersAdmin, superAdmin)
+	acc.DELETE("/roles/:guid", rc.DeleteRole, usersAdmin, superAdmin)
+}
@@ -3,38 +3,43 @@ package apirouterv1
 import (
 	"github.com/jinzhu/gorm"
 	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/decoder"
 )
 
 // routesearch Apis
-func RouteSearchApis(acc *echo.Group, dataSession map[string]*gorm.DB, configSession *gorm.DB, externalDecoder service.ExternalDecoder) {
+func RouteSearchApis(acc *echo.Group, dataSession map[string]*gorm.DB, configSession *gorm.DB, decoders *decoder.Registry) {
 	// initialize service of user
-	searchService := service.SearchService{ServiceData: service.ServiceData{Session: dataSession, Decoder: externalDecoder}}
+	searchService := service.SearchService{ServiceData: service.ServiceData{Session: dataSession, Decoders: decoders}}
 	aliasService := service.AliasService{ServiceConfig: service.ServiceConfig{Session: configSession}}
 	settingService := service.UserSettingsService{ServiceConfig: service.ServiceConfig{Session: configSession}}
+	tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: configSession}}
 
 	// initialize user controller
 	src := controllerv1.SearchController{
 		SearchService:  &searchService,
 		SettingService: &settingService,
 		AliasService:   &aliasService,
+		TenantService:  &tenantService,
 	}
 
-	// create new user
-	acc.POST("/search/call/data", src.SearchData)
-	acc.POST("/search/call/message", src.GetMessageById)
+	searchRead := auth.RequirePermission(model.PermissionSearchRead)
+	exportPcap := auth.RequirePermission(model.PermissionExportPcap)
 
-	acc.POST("/search/call/decode/message", src.GetDecodeMessageById)
-	acc.POST("/call/transaction", src.GetTransaction)
-	acc.POST("/call/report/qos", src.GetTransactionQos)
-	acc.POST("/call/report/log", src.GetTransactionLog)
-	acc.POST("/export/call/messages/pcap", src.GetMessagesAsPCap)
-	acc.POST("/export/call/messages/text", src.GetMessagesAsText)
+	// create new user
+	acc.POST("/search/call/data", src.SearchData, searchRead)
+	acc.POST("/search/geo/aggregate", src.GeoAggregate, searchRead)
+	acc.POST("/search/call/message", src.GetMessageById, searchRead)
 
-	/* import data */
-	acc.POST("/import/data/pcap", src.GetDataAsPCap)
-	acc.POST("/import/data/pcap/now", src.GetDataAsPCapNow)
+	acc.POST("/search/call/decode/message", src.GetDecodeMessageById, searchRead)
+	acc.POST("/call/transaction", src.GetTransaction, searchRead)
+	acc.POST("/call/report/qos", src.GetTransactionQos, searchRead)
+	acc.POST("/call/report/log", src.GetTransactionLog, searchRead)
+	acc.POST("/export/call/messages/pcap", src.GetMessagesAsPCap, exportPcap)
+	acc.POST("/export/call/messages/text", src.GetMessagesAsText, exportPcap)
 
 	//acc.POST("/api/call/report/log", src.HepSub)
 }
@@ -0,0 +1,29 @@
+package apirouterv1
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteSessionApis
+func RouteSessionApis(acc *echo.Group, session *gorm.DB) {
+	// initialize service of sessions
+	sessionService := service.SessionService{ServiceConfig: service.ServiceConfig{Session: session}}
+	// initialize session controller
+	sc := controllerv1.SessionController{
+		SessionService: &sessionService,
+	}
+
+	usersAdmin := auth.RequirePermission(model.PermissionUsersAdmin)
+
+	// end the session of the token
+	acc.POST("/auth/logout", sc.Logout)
+
+	acc.GET("/sessions", sc.GetSessions, usersAdmin)
+	acc.DELETE("/sessions/user/:username", sc.DeleteUserSessions, usersAdmin)
+	acc.DELETE("/sessions/:guid", sc.DeleteSession, usersAdmin)
+}
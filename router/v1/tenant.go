@@ -0,0 +1,29 @@
+package apirouterv1
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
+	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
+)
+
+// RouteTenantApis
+func RouteTenantApis(acc *echo.Group, session *gorm.DB) {
+	// initialize service of tenants
+	tenantService := service.TenantService{ServiceConfig: service.ServiceConfig{Session: session}}
+	// initialize tenant controller
+	tc := controllerv1.TenantController{
+		TenantService: &tenantService,
+	}
+
+	usersAdmin := auth.RequirePermission(model.PermissionUsersAdmin)
+	/* the tenants are shared by all partitions */
+	superAdmin := auth.RequireSuperAdmin()
+
+	acc.GET("/tenants", tc.GetAllTenants, usersAdmin)
+	acc.POST("/tenants", tc.AddTenant, usersAdmin, superAdmin)
+	acc.PUT("/tenants/:guid", tc.UpdateTenant, usersAdmin, superAdmin)
+	acc.DELETE("/tenants/:guid", tc.DeleteTenant, usersAdmin, superAdmin)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
 	"github.com/sipcapture/homer-app/data/service"
+	"github.com/sipcapture/homer-app/model"
 	"github.com/sipcapture/homer-app/utils/httpauth"
 	"github.com/sipcapture/homer-app/utils/ldap"
 )
@@ -22,12 +23,12 @@ func RouteUserDetailsApis(acc *echo.Group, session *gorm.DB) {
 	//
 	acc.GET("/users/groups", urc.GetGroups)
 	// create new user
-	acc.POST("/users", urc.CreateUser, auth.IsAdmin)
+	acc.POST("/users", urc.CreateUser, auth.RequirePermission(model.PermissionUsersAdmin))
 	// update user
 	acc.PUT("/users/:userGuid", urc.UpdateUser)
 
 	// delete user
-	acc.DELETE("/users/:userGuid", urc.DeleteUser, auth.IsAdmin)
+	acc.DELETE("/users/:userGuid", urc.DeleteUser, auth.RequirePermission(model.PermissionUsersAdmin))
 
 	//get user
 	acc.GET("/users/:userGuid", urc.GetUserByGUID)
@@ -40,13 +41,30 @@ func RouteUserDetailsApis(acc *echo.Group, session *gorm.DB) {
 func RouteUserApis(acc *echo.Group, session *gorm.DB, ldapClient *ldap.LDAPClient, httpAuth *httpauth.Client) {
 	// initialize service of user
 	userService := service.UserService{ServiceConfig: service.ServiceConfig{Session: session}, LdapClient: ldapClient, HttpAuth: httpAuth}
+	// initialize service of sessions
+	sessionService := service.SessionService{ServiceConfig: service.ServiceConfig{Session: session}}
 	// initialize user controller
 	urc := controllerv1.UserController{
-		UserService: &userService,
+		UserService:    &userService,
+		SessionService: &sessionService,
+	}
+	sc := controllerv1.SessionController{
+		SessionService: &sessionService,
+	}
+	mc := controllerv1.MFAController{
+		MFAService:     &service.MFAService{ServiceConfig: service.ServiceConfig{Session: session}},
+		SessionService: &sessionService,
 	}
 	// user login
 	acc.POST("/auth", urc.LoginUser)
 
+	// second step of the login with 2FA
+	acc.POST("/auth/mfa", mc.CompleteLogin)
+	acc.POST("/auth/mfa/enrol", mc.EnrolLogin)
+
+	// new token for the refresh token
+	acc.POST("/auth/refresh", sc.RefreshSession)
+
 	//list of auths
 	acc.GET("/auth/type/list", urc.GetAuthTypeList)
 
@@ -2,15 +2,23 @@ package apirouterv1
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/sipcapture/homer-app/auth"
 	controllerv1 "github.com/sipcapture/homer-app/controller/v1"
+	"github.com/sipcapture/homer-app/model"
+	"github.com/sipcapture/homer-app/utils/heprelay"
 )
 
-// routesearch Apis
-func RouteWebSocketApis(acc *echo.Group, addr string) {
-	// initialize service of user
-	// create new user
+// RouteWebSocketApis
+func RouteWebSocketApis(acc *echo.Group, relay *heprelay.Relay) {
 
-	wc := controllerv1.WebSocketController{Addr: &addr}
-	acc.GET("/ws", wc.RelayHepData)
+	/* relay is disabled */
+	if relay == nil {
+		return
+	}
 
+	wc := controllerv1.WebSocketController{Relay: relay}
+
+	/* browsers can't set headers on WebSockets */
+	auth.QueryTokenRoute(acc.GET("/ws", wc.RelayHepData, auth.RequirePermission(model.PermissionHepRelay)))
+	acc.GET("/hep/relay/stats", wc.GetRelayStats, auth.RequirePermission(model.PermissionStatsRead))
 }
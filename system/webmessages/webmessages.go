@@ -39,4 +39,34 @@ const (
 	BadPCAPData                 = "bad pcap data"
 	BadDatabaseRetrieve         = "db data retrieve error"
 	GrafanaProcessingError      = "grafana returned"
+	ImportJobNotFound           = "import job not found"
+	ImportJobFailed             = "failed to start the import job"
+	ImportDeleteFailed          = "failed to delete the imported data"
+	IngestFailed                = "failed to ingest the records"
+	LiveTooManySubscriptions    = "too many live subscriptions"
+	LiveSessionEnded            = "the session has ended, please log in again"
+	AliasImportFailed           = "failed to import the aliases"
+	AliasExportFailed           = "failed to export the aliases"
+	AliasSyncNotConfigured      = "alias sync is not configured"
+	AliasGroupNotFound          = "alias group not found"
+	AliasGroupFailed            = "failed to save the alias group"
+	GeoIPNotConfigured          = "geoip is not configured"
+	DashboardShareDenied        = "sharing dashboards needs the permission dashboards:share"
+	RoleNotFound                = "role not found"
+	RoleFailed                  = "failed to save the role"
+	TenantNotFound              = "tenant not found"
+	TenantFailed                = "failed to save the tenant"
+	TenantDenied                = "the users of a tenant can't change the tenants"
+	TenantDataHidden            = "the data is not visible to the tenant"
+	PartitionDenied             = "only a super admin can change other partitions"
+	SessionNotFound             = "session not found"
+	SessionFailed               = "failed to start the session"
+	RefreshTokenInvalid         = "the refresh token is invalid or has expired"
+	OIDCProviderNotFound        = "OpenID Connect provider not found"
+	OIDCLoginFailed             = "OpenID Connect login failed"
+	MFACodeIncorrect            = "the 2FA code is not correct"
+	MFAChallengeInvalid         = "the 2FA challenge is invalid or has expired, please log in again"
+	MFAFailed                   = "failed to change the 2FA of the user"
+	MFAUserNotFound             = "user not found"
+	MFAInternalOnly             = "2FA is only available for the internal users"
 )
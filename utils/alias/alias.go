@@ -0,0 +1,298 @@
+// Package alias resolves an address to its alias name. The aliases are kept in a
+// binary prefix tree per address family, so a network needs a single entry.
+//
+// When more than one alias matches, the most specific one wins:
+//  1. the longest network prefix
+//  2. an alias of the same captureId before an alias of every captureId
+//  3. a single port before a port range, a narrow range before a wide one,
+//     and a range before port 0 (every port)
+//  4. the alias loaded first
+package alias
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Entry is an alias of a network and port range
+type Entry struct {
+	Alias     string
+	Network   *net.IPNet
+	PortFrom  int
+	PortTo    int
+	CaptureID string
+	/* the tags of the alias and of its groups */
+	Tags []string
+	/* the names of the groups, from the top one down */
+	Groups []string
+	order  int
+}
+
+// NewEntry builds an entry. A port of 0 matches every port, a portEnd above port
+// makes a range. A captureId of "" or "0" matches every captureId.
+func NewEntry(alias, ip string, mask, port, portEnd int, captureID string) (*Entry, error) {
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, fmt.Errorf("alias %s: bad ip %q", alias, ip)
+	}
+	bits := 128
+	if v4 := addr.To4(); v4 != nil {
+		addr, bits = v4, 32
+	}
+	if mask < 0 || mask > bits {
+		return nil, fmt.Errorf("alias %s: bad mask %d for %s", alias, mask, ip)
+	}
+	if port < 0 || port > 65535 || portEnd < 0 || portEnd > 65535 {
+		return nil, fmt.Errorf("alias %s: bad port range %d-%d", alias, port, portEnd)
+	}
+
+	e := &Entry{Alias: alias, PortFrom: port, PortTo: port, CaptureID: captureID}
+	if portEnd > port {
+		e.PortTo = portEnd
+	}
+	if captureID == "0" {
+		e.CaptureID = ""
+	}
+	netMask := net.CIDRMask(mask, bits)
+	e.Network = &net.IPNet{IP: addr.Mask(netMask), Mask: netMask}
+	return e, nil
+}
+
+// String of the entry, like 10.0.0.0/24:5060-5080
+func (e *Entry) String() string {
+	str := e.Network.String()
+	if e.PortFrom != 0 {
+		str += ":" + strconv.Itoa(e.PortFrom)
+		if e.PortTo != e.PortFrom {
+			str += "-" + strconv.Itoa(e.PortTo)
+		}
+	}
+	if e.CaptureID != "" {
+		str += "@" + e.CaptureID
+	}
+	return str
+}
+
+// Path is the groups and the alias, like Carrier X → POP Frankfurt → SBC-1
+func (e *Entry) Path() string {
+	return strings.Join(append(append([]string{}, e.Groups...), e.Alias), " → ")
+}
+
+// HasTag is true if the alias or one of its groups has the tag, the case is ignored
+func (e *Entry) HasTag(tag string) bool {
+	for _, val := range e.Tags {
+		if strings.EqualFold(val, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// InGroup is true if the alias is below the group, the case is ignored
+func (e *Entry) InGroup(group string) bool {
+	for _, val := range e.Groups {
+		if strings.EqualFold(val, group) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) portWidth() int {
+	if e.PortFrom == 0 {
+		return 65536
+	}
+	return e.PortTo - e.PortFrom
+}
+
+func (e *Entry) matchPort(port int) bool {
+	return e.PortFrom == 0 || (port >= e.PortFrom && port <= e.PortTo)
+}
+
+// overlaps is true if both entries match some port and captureId, the networks aside
+func (e *Entry) overlaps(o *Entry) bool {
+	if e.CaptureID != "" && o.CaptureID != "" && e.CaptureID != o.CaptureID {
+		return false
+	}
+	return e.PortFrom == 0 || o.PortFrom == 0 || (e.PortFrom <= o.PortTo && o.PortFrom <= e.PortTo)
+}
+
+// moreSpecific compares entries of the same prefix
+func (e *Entry) moreSpecific(o *Entry) bool {
+	if (e.CaptureID != "") != (o.CaptureID != "") {
+		return e.CaptureID != ""
+	}
+	if e.portWidth() != o.portWidth() {
+		return e.portWidth() < o.portWidth()
+	}
+	return e.order < o.order
+}
+
+type node struct {
+	child   [2]*node
+	entries []*Entry
+}
+
+func (n *node) insert(e *Entry) {
+	ones, _ := e.Network.Mask.Size()
+	for i := 0; i < ones; i++ {
+		bit := e.Network.IP[i/8] >> (7 - uint(i%8)) & 1
+		if n.child[bit] == nil {
+			n.child[bit] = &node{}
+		}
+		n = n.child[bit]
+	}
+	n.entries = append(n.entries, e)
+}
+
+// Resolver finds the alias of an address, it can be reloaded while in use
+type Resolver struct {
+	mu      sync.RWMutex
+	v4      *node
+	v6      *node
+	entries []*Entry
+	/* when false the captureId of the aliases is ignored */
+	captureScope bool
+}
+
+// NewResolver returns an empty resolver, captureScope makes aliases with a
+// captureId match only that captureId
+func NewResolver(captureScope bool) *Resolver {
+	return &Resolver{v4: &node{}, v6: &node{}, captureScope: captureScope}
+}
+
+// Load replaces the aliases
+func (r *Resolver) Load(entries []*Entry) {
+
+	v4, v6 := &node{}, &node{}
+	for i, e := range entries {
+		e.order = i
+		if !r.captureScope {
+			e.CaptureID = ""
+		}
+		if len(e.Network.IP) == net.IPv4len {
+			v4.insert(e)
+		} else {
+			v6.insert(e)
+		}
+	}
+
+	r.mu.Lock()
+	r.v4, r.v6, r.entries = v4, v6, entries
+	r.mu.Unlock()
+}
+
+// Len is the number of aliases
+func (r *Resolver) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.entries)
+}
+
+// Select returns the aliases for which match is true
+func (r *Resolver) Select(match func(e *Entry) bool) []*Entry {
+
+	r.mu.RLock()
+	entries := r.entries
+	r.mu.RUnlock()
+
+	var selected []*Entry
+	for _, e := range entries {
+		if match(e) {
+			selected = append(selected, e)
+		}
+	}
+	return selected
+}
+
+// Shadowing returns the aliases which win over e for a part of its addresses, ports or
+// captureIds: the ones of a longer prefix inside its network and the more specific ones
+// of the same network. Lookup returns e where it matches and none of them does.
+func (r *Resolver) Shadowing(e *Entry) []*Entry {
+
+	r.mu.RLock()
+	entries := r.entries
+	r.mu.RUnlock()
+
+	ones, _ := e.Network.Mask.Size()
+
+	var shadowing []*Entry
+	for _, o := range entries {
+		if o == e || len(o.Network.IP) != len(e.Network.IP) || !e.Network.Contains(o.Network.IP) || !o.overlaps(e) {
+			continue
+		}
+		if oOnes, _ := o.Network.Mask.Size(); oOnes > ones || (oOnes == ones && o.moreSpecific(e)) {
+			shadowing = append(shadowing, o)
+		}
+	}
+	return shadowing
+}
+
+// CaptureScope is true if the captureId of the aliases is used
+func (r *Resolver) CaptureScope() bool {
+	return r.captureScope
+}
+
+// Lookup returns the most specific alias of the address, nil if none matches
+func (r *Resolver) Lookup(ip string, port int, captureID string) *Entry {
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	n := r.v6
+	if v4 := addr.To4(); v4 != nil {
+		addr, n = v4, r.v4
+	}
+	r.mu.RUnlock()
+
+	var best *Entry
+	for i := 0; n != nil; i++ {
+		var found *Entry
+		for _, e := range n.entries {
+			if !e.matchPort(port) || (e.CaptureID != "" && e.CaptureID != captureID) {
+				continue
+			}
+			if found == nil || e.moreSpecific(found) {
+				found = e
+			}
+		}
+		if found != nil {
+			best = found
+		}
+		if i == len(addr)*8 {
+			break
+		}
+		n = n.child[addr[i/8]>>(7-uint(i%8))&1]
+	}
+	return best
+}
+
+// Alias returns the alias name of the address
+func (r *Resolver) Alias(ip string, port int, captureID string) (string, bool) {
+	if e := r.Lookup(ip, port, captureID); e != nil {
+		return e.Alias, true
+	}
+	return "", false
+}
+
+// Name returns the alias name of the address or ip:port, [ip]:port for IPv6
+func (r *Resolver) Name(ip string, port int, captureID string) string {
+	if r != nil {
+		if name, ok := r.Alias(ip, port, captureID); ok {
+			return name
+		}
+	}
+	return HostPort(ip, port)
+}
+
+// HostPort joins the address the way the aliases of the replies are keyed
+func HostPort(ip string, port int) string {
+	return net.JoinHostPort(ip, strconv.Itoa(port))
+}
@@ -0,0 +1,188 @@
+package alias
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func testEntry(t *testing.T, alias, ip string, mask, port, portEnd int, captureID string) *Entry {
+	e, err := NewEntry(alias, ip, mask, port, portEnd, captureID)
+	if err != nil {
+		t.Fatalf("[testEntry] %v", err)
+	}
+	return e
+}
+
+func TestLookup(t *testing.T) {
+
+	r := NewResolver(true)
+	r.Load([]*Entry{
+		testEntry(t, "carrier", "10.0.0.0", 8, 0, 0, "0"),
+		testEntry(t, "sbc", "10.1.2.0", 24, 0, 0, ""),
+		testEntry(t, "sbc-sip", "10.1.2.0", 24, 5060, 0, ""),
+		testEntry(t, "sbc-rtp", "10.1.2.0", 24, 10000, 20000, ""),
+		testEntry(t, "sbc-rtp-low", "10.1.2.0", 24, 10000, 10100, ""),
+		testEntry(t, "sbc-node2", "10.1.2.0", 24, 0, 0, "2"),
+		testEntry(t, "proxy", "10.1.2.3", 32, 0, 0, ""),
+		testEntry(t, "v6-net", "2001:db8::", 32, 0, 0, ""),
+		testEntry(t, "v6-host", "2001:db8::5", 128, 5061, 0, ""),
+		testEntry(t, "default", "0.0.0.0", 0, 0, 0, ""),
+	})
+
+	tests := []struct {
+		ip        string
+		port      int
+		captureID string
+		alias     string
+	}{
+		{"10.200.0.1", 5060, "1", "carrier"},
+		{"10.1.2.9", 80, "1", "sbc"},
+		{"10.1.2.9", 5060, "1", "sbc-sip"},
+		{"10.1.2.9", 15000, "1", "sbc-rtp"},
+		{"10.1.2.9", 10050, "1", "sbc-rtp-low"},
+		{"10.1.2.9", 5060, "2", "sbc-node2"},
+		{"10.1.2.3", 5060, "2", "proxy"},
+		{"192.168.0.1", 5060, "1", "default"},
+		{"::ffff:10.1.2.3", 5060, "1", "proxy"},
+		{"2001:db8::7", 5060, "1", "v6-net"},
+		{"2001:db8::5", 5061, "1", "v6-host"},
+		{"2001:db9::5", 5061, "1", ""},
+		{"not an ip", 5060, "1", ""},
+	}
+	for _, test := range tests {
+		name, _ := r.Alias(test.ip, test.port, test.captureID)
+		if name != test.alias {
+			t.Errorf("[TestLookup] %s:%d@%s got %q, want %q", test.ip, test.port, test.captureID, name, test.alias)
+		}
+	}
+
+	if r.Len() != 10 {
+		t.Errorf("[TestLookup] %d aliases", r.Len())
+	}
+	if name := r.Name("2001:db9::5", 5061, ""); name != "[2001:db9::5]:5061" {
+		t.Errorf("[TestLookup] name %q", name)
+	}
+}
+
+func TestShadowing(t *testing.T) {
+
+	entries := []*Entry{
+		testEntry(t, "carrier", "10.0.0.0", 8, 0, 0, "0"),
+		testEntry(t, "sbc", "10.1.2.0", 24, 0, 0, ""),
+		testEntry(t, "sbc-sip", "10.1.2.0", 24, 5060, 0, ""),
+		testEntry(t, "sbc-rtp", "10.1.2.0", 24, 10000, 20000, ""),
+		testEntry(t, "sbc-rtp-low", "10.1.2.0", 24, 10000, 10100, ""),
+		testEntry(t, "sbc-node2", "10.1.2.0", 24, 0, 0, "2"),
+		testEntry(t, "proxy", "10.1.2.3", 32, 0, 0, ""),
+		testEntry(t, "default", "0.0.0.0", 0, 0, 0, ""),
+	}
+	r := NewResolver(true)
+	r.Load(entries)
+
+	/* an entry wins where it matches and none of its shadowing entries does */
+	matches := func(e *Entry, ip string, port int, captureID string) bool {
+		return e.Network.Contains(net.ParseIP(ip)) && e.matchPort(port) && (e.CaptureID == "" || e.CaptureID == captureID)
+	}
+	for _, ip := range []string{"10.1.2.3", "10.1.2.9", "10.200.0.1", "192.168.0.1"} {
+		for _, port := range []int{80, 5060, 10050, 15000} {
+			for _, captureID := range []string{"0", "1", "2"} {
+				found := r.Lookup(ip, port, captureID)
+				for _, e := range entries {
+					wins := matches(e, ip, port, captureID)
+					for _, o := range r.Shadowing(e) {
+						wins = wins && !matches(o, ip, port, captureID)
+					}
+					if wins != (found == e) {
+						t.Errorf("[TestShadowing] %s:%d@%s: %s wins %v, lookup %v", ip, port, captureID, e.Alias, wins, found)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCaptureScope(t *testing.T) {
+
+	entries := func() []*Entry {
+		return []*Entry{
+			testEntry(t, "node1", "10.0.0.1", 32, 0, 0, "1"),
+		}
+	}
+
+	scoped := NewResolver(true)
+	scoped.Load(entries())
+	if _, ok := scoped.Alias("10.0.0.1", 5060, "2"); ok {
+		t.Errorf("[TestCaptureScope] alias of captureId 1 matched 2")
+	}
+
+	global := NewResolver(false)
+	global.Load(entries())
+	if name, _ := global.Alias("10.0.0.1", 5060, "2"); name != "node1" {
+		t.Errorf("[TestCaptureScope] got %q without scope", name)
+	}
+}
+
+func TestNewEntry(t *testing.T) {
+
+	bad := [][]interface{}{
+		{"x", 32, 0, 0},
+		{"10.0.0.1", 33, 0, 0},
+		{"10.0.0.1", 32, 70000, 0},
+		{"2001:db8::1", 129, 0, 0},
+	}
+	for _, b := range bad {
+		if _, err := NewEntry("bad", b[0].(string), b[1].(int), b[2].(int), b[3].(int), ""); err == nil {
+			t.Errorf("[TestNewEntry] %v accepted", b)
+		}
+	}
+
+	e := testEntry(t, "net", "10.1.2.3", 24, 5060, 5080, "7")
+	if e.String() != "10.1.2.0/24:5060-5080@7" {
+		t.Errorf("[TestNewEntry] %s", e)
+	}
+}
+
+func TestSelect(t *testing.T) {
+
+	sbc := testEntry(t, "SBC-1", "10.1.0.10", 32, 5060, 0, "")
+	sbc.Groups = []string{"Carrier X", "POP Frankfurt"}
+	sbc.Tags = []string{"sbc", "carrier-x", "site-fra"}
+	pbx := testEntry(t, "PBX", "10.2.0.0", 24, 0, 0, "")
+	pbx.Tags = []string{"pbx"}
+
+	r := NewResolver(false)
+	r.Load([]*Entry{sbc, pbx})
+
+	if got := r.Select(func(e *Entry) bool { return e.HasTag("Carrier-X") }); len(got) != 1 || got[0] != sbc {
+		t.Errorf("[TestSelect] tag got %v", got)
+	}
+	if got := r.Select(func(e *Entry) bool { return e.InGroup("pop frankfurt") }); len(got) != 1 || got[0] != sbc {
+		t.Errorf("[TestSelect] group got %v", got)
+	}
+	if got := r.Select(func(e *Entry) bool { return e.HasTag("registrar") }); len(got) != 0 {
+		t.Errorf("[TestSelect] unknown tag got %v", got)
+	}
+	if path := sbc.Path(); path != "Carrier X → POP Frankfurt → SBC-1" {
+		t.Errorf("[TestSelect] path %q", path)
+	}
+	if path := pbx.Path(); path != "PBX" {
+		t.Errorf("[TestSelect] path %q", path)
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+
+	r := NewResolver(false)
+	entries := []*Entry{}
+	for i := 0; i < 10000; i++ {
+		e, _ := NewEntry(fmt.Sprintf("a%d", i), fmt.Sprintf("10.%d.%d.0", i/256, i%256), 24, 0, 0, "")
+		entries = append(entries, e)
+	}
+	r.Load(entries)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Lookup("10.20.30.40", 5060, "")
+	}
+}
@@ -0,0 +1,361 @@
+// Package aliasfile reads and writes alias lists in CSV, JSON and YAML, reads the
+// Kamailio dispatcher.list and address table dumps, and compares a list with the
+// stored aliases.
+package aliasfile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// formats
+const (
+	FormatCSV        = "csv"
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatDispatcher = "dispatcher"
+	FormatAddress    = "address"
+)
+
+// Alias is an alias of a list
+type Alias struct {
+	Alias     string   `json:"alias" yaml:"alias"`
+	IP        string   `json:"ip" yaml:"ip"`
+	Mask      int      `json:"mask" yaml:"mask"`
+	Port      int      `json:"port" yaml:"port"`
+	PortEnd   int      `json:"port_end" yaml:"port_end"`
+	CaptureID string   `json:"captureID" yaml:"captureID"`
+	Status    bool     `json:"status" yaml:"status"`
+	Tags      []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Source    string   `json:"source,omitempty" yaml:"source,omitempty"`
+	GUID      string   `json:"guid,omitempty" yaml:"guid,omitempty"`
+}
+
+// Key identifies the address of the alias, two aliases of a key can't be loaded
+func (a *Alias) Key() string {
+	captureID := a.CaptureID
+	if captureID == "0" {
+		captureID = ""
+	}
+	return fmt.Sprintf("%s/%d:%d-%d@%s", a.IP, a.Mask, a.Port, a.PortEnd, captureID)
+}
+
+/* the fields of the files, missing ones get defaults */
+type fileAlias struct {
+	Alias     string      `json:"alias" yaml:"alias"`
+	IP        string      `json:"ip" yaml:"ip"`
+	Mask      *int        `json:"mask" yaml:"mask"`
+	Port      int         `json:"port" yaml:"port"`
+	PortEnd   int         `json:"port_end" yaml:"port_end"`
+	CaptureID interface{} `json:"captureID" yaml:"captureID"`
+	Status    *bool       `json:"status" yaml:"status"`
+	Tags      []string    `json:"tags" yaml:"tags"`
+}
+
+func (f *fileAlias) alias() Alias {
+	a := Alias{Alias: f.Alias, IP: f.IP, Port: f.Port, PortEnd: f.PortEnd, Status: true, Mask: -1, Tags: f.Tags}
+	if f.Mask != nil {
+		a.Mask = *f.Mask
+	}
+	if f.Status != nil {
+		a.Status = *f.Status
+	}
+	if f.CaptureID != nil {
+		a.CaptureID = fmt.Sprint(f.CaptureID)
+	}
+	return a
+}
+
+// DetectFormat guesses the format of a file by its name
+func DetectFormat(name string) string {
+
+	base := strings.ToLower(path.Base(name))
+	switch {
+	case strings.HasPrefix(base, "dispatcher"):
+		return FormatDispatcher
+	case strings.HasPrefix(base, "address"):
+		return FormatAddress
+	case strings.HasSuffix(base, ".csv"):
+		return FormatCSV
+	case strings.HasSuffix(base, ".yaml"), strings.HasSuffix(base, ".yml"):
+		return FormatYAML
+	}
+	return FormatJSON
+}
+
+// Parse reads a list, the aliases are checked and get a host mask when it is missing
+func Parse(format string, data []byte) ([]Alias, error) {
+
+	var aliases []Alias
+	var err error
+
+	switch format {
+	case FormatCSV:
+		aliases, err = parseCSV(data)
+	case FormatJSON:
+		aliases, err = parseJSON(data)
+	case FormatYAML:
+		aliases, err = parseYAML(data)
+	case FormatDispatcher:
+		aliases, err = parseDispatcher(data)
+	case FormatAddress:
+		aliases, err = parseAddress(data)
+	default:
+		return nil, fmt.Errorf("unknown alias format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range aliases {
+		if err := check(&aliases[i]); err != nil {
+			return nil, fmt.Errorf("alias %d: %v", i+1, err)
+		}
+	}
+	return aliases, nil
+}
+
+func check(a *Alias) error {
+
+	ip := net.ParseIP(a.IP)
+	if ip == nil {
+		return fmt.Errorf("bad ip %q", a.IP)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		bits = 32
+	}
+	if a.Mask == -1 {
+		a.Mask = bits
+	}
+	if a.Mask < 0 || a.Mask > bits {
+		return fmt.Errorf("bad mask %d of %s", a.Mask, a.IP)
+	}
+	if a.Port < 0 || a.Port > 65535 || a.PortEnd < 0 || a.PortEnd > 65535 || (a.PortEnd != 0 && a.PortEnd < a.Port) {
+		return fmt.Errorf("bad port range %d-%d of %s", a.Port, a.PortEnd, a.IP)
+	}
+	if a.Alias == "" {
+		return fmt.Errorf("no alias name for %s", a.IP)
+	}
+	return nil
+}
+
+/* the tags of a csv row are separated by ';' */
+var csvColumns = []string{"alias", "ip", "mask", "port", "port_end", "captureID", "status", "tags", "source"}
+
+func parseCSV(data []byte) ([]Alias, error) {
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["ip"]; !ok {
+		return nil, fmt.Errorf("the csv header has no ip column")
+	}
+	value := func(record []string, name string) string {
+		if i, ok := columns[strings.ToLower(name)]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	aliases := []Alias{}
+	for line, record := range records[1:] {
+		f := fileAlias{Alias: value(record, "alias"), IP: value(record, "ip")}
+		ints := []struct {
+			name string
+			dst  *int
+		}{{"port", &f.Port}, {"port_end", &f.PortEnd}}
+		for _, val := range ints {
+			if str := value(record, val.name); str != "" {
+				if *val.dst, err = strconv.Atoi(str); err != nil {
+					return nil, fmt.Errorf("line %d: bad %s %q", line+2, val.name, str)
+				}
+			}
+		}
+		if str := value(record, "mask"); str != "" {
+			mask, err := strconv.Atoi(str)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad mask %q", line+2, str)
+			}
+			f.Mask = &mask
+		}
+		if str := value(record, "captureID"); str != "" {
+			f.CaptureID = str
+		}
+		if str := value(record, "status"); str != "" {
+			status, err := strconv.ParseBool(str)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad status %q", line+2, str)
+			}
+			f.Status = &status
+		}
+		if str := value(record, "tags"); str != "" {
+			for _, tag := range strings.Split(str, ";") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					f.Tags = append(f.Tags, tag)
+				}
+			}
+		}
+		aliases = append(aliases, f.alias())
+	}
+	return aliases, nil
+}
+
+/* a list, or an object with the list in data like the GET /alias reply */
+type fileList struct {
+	Data []fileAlias `json:"data" yaml:"data"`
+}
+
+func convert(list []fileAlias) []Alias {
+	aliases := make([]Alias, len(list))
+	for i := range list {
+		aliases[i] = list[i].alias()
+	}
+	return aliases
+}
+
+func parseJSON(data []byte) ([]Alias, error) {
+
+	var list []fileAlias
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var obj fileList
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, err
+		}
+		list = obj.Data
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return convert(list), nil
+}
+
+func parseYAML(data []byte) ([]Alias, error) {
+
+	var list []fileAlias
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		var obj fileList
+		if err2 := yaml.Unmarshal(data, &obj); err2 != nil {
+			return nil, err
+		}
+		list = obj.Data
+	}
+	return convert(list), nil
+}
+
+// Write writes the aliases as CSV, JSON or YAML
+func Write(format string, aliases []Alias) ([]byte, error) {
+
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(aliases, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(aliases)
+	case FormatCSV:
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write(csvColumns)
+		for _, a := range aliases {
+			writer.Write([]string{a.Alias, a.IP, strconv.Itoa(a.Mask), strconv.Itoa(a.Port), strconv.Itoa(a.PortEnd),
+				a.CaptureID, strconv.FormatBool(a.Status), strings.Join(a.Tags, ";"), a.Source})
+		}
+		writer.Flush()
+		return buf.Bytes(), writer.Error()
+	}
+	return nil, fmt.Errorf("aliases can't be written as %q", format)
+}
+
+// Change is an alias with a new name, status or tags
+type Change struct {
+	From Alias `json:"from"`
+	To   Alias `json:"to"`
+}
+
+// Diff is what applying a list would do
+type Diff struct {
+	Added   []Alias  `json:"added"`
+	Changed []Change `json:"changed"`
+	Removed []Alias  `json:"removed"`
+	/* imported aliases with the address of a manual alias or another source, not applied */
+	Conflicts []Alias `json:"conflicts"`
+	Unchanged int     `json:"unchanged"`
+}
+
+// Empty is true if nothing changes
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Compare compares the imported aliases of a source with the stored ones. The
+// aliases of other sources and the manual ones, without source, are never changed.
+func Compare(stored []Alias, imported []Alias, source string) (*Diff, error) {
+
+	if source == "" {
+		return nil, fmt.Errorf("imported aliases need a source")
+	}
+
+	diff := &Diff{Added: []Alias{}, Changed: []Change{}, Removed: []Alias{}, Conflicts: []Alias{}}
+
+	owned := map[string]Alias{}
+	others := map[string]bool{}
+	for _, a := range stored {
+		if a.Source == source {
+			owned[a.Key()] = a
+		} else {
+			others[a.Key()] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, a := range imported {
+		a.Source = source
+		key := a.Key()
+		if seen[key] {
+			return nil, fmt.Errorf("%s is imported twice", key)
+		}
+		seen[key] = true
+
+		old, ok := owned[key]
+		switch {
+		case others[key]:
+			diff.Conflicts = append(diff.Conflicts, a)
+		case !ok:
+			diff.Added = append(diff.Added, a)
+		case old.Alias != a.Alias || old.Status != a.Status || strings.Join(old.Tags, ";") != strings.Join(a.Tags, ";"):
+			a.GUID = old.GUID
+			diff.Changed = append(diff.Changed, Change{From: old, To: a})
+		default:
+			diff.Unchanged++
+		}
+	}
+
+	for key, a := range owned {
+		if !seen[key] {
+			diff.Removed = append(diff.Removed, a)
+		}
+	}
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Key() < diff.Removed[j].Key() })
+
+	return diff, nil
+}
@@ -0,0 +1,172 @@
+package aliasfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFormats(t *testing.T) {
+
+	want := []Alias{
+		{Alias: "sbc", IP: "10.1.2.0", Mask: 24, Port: 5060, Status: true, Tags: []string{"carrier-x", "sbc"}},
+		{Alias: "media", IP: "2001:db8::1", Mask: 128, Port: 10000, PortEnd: 20000, CaptureID: "2", Status: false},
+	}
+
+	files := map[string]string{
+		FormatCSV: "alias,ip,mask,port,port_end,captureID,status,tags\n" +
+			"sbc,10.1.2.0,24,5060,,,,carrier-x;sbc\n" +
+			"# media servers\n" +
+			"media,2001:db8::1,,10000,20000,2,false\n",
+		FormatJSON: `{"data":[{"alias":"sbc","ip":"10.1.2.0","mask":24,"port":5060,"tags":["carrier-x","sbc"]},` +
+			`{"alias":"media","ip":"2001:db8::1","port":10000,"port_end":20000,"captureID":2,"status":false}]}`,
+		FormatYAML: "- alias: sbc\n  ip: 10.1.2.0\n  mask: 24\n  port: 5060\n  tags: [carrier-x, sbc]\n" +
+			"- alias: media\n  ip: \"2001:db8::1\"\n  port: 10000\n  port_end: 20000\n  captureID: \"2\"\n  status: false\n",
+	}
+
+	for format, data := range files {
+		aliases, err := Parse(format, []byte(data))
+		if err != nil {
+			t.Errorf("[TestParseFormats] %s: %v", format, err)
+			continue
+		}
+		if !reflect.DeepEqual(aliases, want) {
+			t.Errorf("[TestParseFormats] %s: got %+v", format, aliases)
+		}
+
+		if format == FormatJSON {
+			continue
+		}
+		out, err := Write(format, aliases)
+		if err != nil {
+			t.Errorf("[TestParseFormats] write %s: %v", format, err)
+			continue
+		}
+		again, err := Parse(format, out)
+		if err != nil || !reflect.DeepEqual(again, want) {
+			t.Errorf("[TestParseFormats] %s round trip: %v %+v", format, err, again)
+		}
+	}
+
+	bad := map[string]string{
+		FormatCSV:  "alias,ip\nx,300.1.1.1\n",
+		FormatJSON: `[{"alias":"x","ip":"10.0.0.1","mask":40}]`,
+		FormatYAML: "- ip: 10.0.0.1\n",
+		"xml":      "<aliases/>",
+	}
+	for format, data := range bad {
+		if _, err := Parse(format, []byte(data)); err == nil {
+			t.Errorf("[TestParseFormats] bad %s accepted", format)
+		}
+	}
+}
+
+func TestParseDispatcher(t *testing.T) {
+
+	list := `# setid(int) destination(sip uri) flags(int,opt) priority(int,opt) attrs(str,opt)
+1 sip:10.0.0.10:5060 0 0 duid=sbc-a;weight=50
+1 sip:10.0.0.11;transport=tcp
+2 sip:[2001:db8::10]:5080 8 1
+2 sip:proxy.example.com:5060
+`
+	aliases, err := Parse(FormatDispatcher, []byte(list))
+	if err != nil {
+		t.Fatalf("[TestParseDispatcher] %v", err)
+	}
+	want := []Alias{
+		{Alias: "sbc-a", IP: "10.0.0.10", Mask: 32, Port: 5060, Status: true},
+		{Alias: "dispatcher-1", IP: "10.0.0.11", Mask: 32, Port: 0, Status: true},
+		{Alias: "dispatcher-2", IP: "2001:db8::10", Mask: 128, Port: 5080, Status: true},
+	}
+	if !reflect.DeepEqual(aliases, want) {
+		t.Errorf("[TestParseDispatcher] got %+v", aliases)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+
+	dbText := "id(int,auto) grp(int) ip_addr(string) mask(int) port(int) tag(string,null)\n" +
+		"1:1:10.1.0.0:16:0:carrier-a\n" +
+		"2:2:2001\\:db8\\:\\:1:128:5060:\n"
+	aliases, err := Parse(FormatAddress, []byte(dbText))
+	if err != nil {
+		t.Fatalf("[TestParseAddress] %v", err)
+	}
+	want := []Alias{
+		{Alias: "carrier-a", IP: "10.1.0.0", Mask: 16, Port: 0, Status: true},
+		{Alias: "address-2", IP: "2001:db8::1", Mask: 128, Port: 5060, Status: true},
+	}
+	if !reflect.DeepEqual(aliases, want) {
+		t.Errorf("[TestParseAddress] db_text got %+v", aliases)
+	}
+
+	tabs := "id\tgrp\tip_addr\tmask\tport\ttag\n" +
+		"1\t1\t10.1.0.0\t16\t0\tcarrier-a\n" +
+		"2\t2\t2001:db8::1\t128\t5060\tNULL\n"
+	aliases, err = Parse(FormatAddress, []byte(tabs))
+	if err != nil || !reflect.DeepEqual(aliases, want) {
+		t.Errorf("[TestParseAddress] tabs got %v %+v", err, aliases)
+	}
+}
+
+func TestCompare(t *testing.T) {
+
+	stored := []Alias{
+		{Alias: "manual", IP: "10.0.0.1", Mask: 32, Status: true, GUID: "m"},
+		{Alias: "old-name", IP: "10.0.0.2", Mask: 32, Status: true, Source: "inventory", GUID: "a"},
+		{Alias: "same", IP: "10.0.0.3", Mask: 32, Status: true, Source: "inventory", GUID: "b"},
+		{Alias: "gone", IP: "10.0.0.4", Mask: 32, Status: true, Source: "inventory", GUID: "c"},
+		{Alias: "other", IP: "10.0.0.5", Mask: 32, Status: true, Source: "dispatcher", GUID: "d"},
+		{Alias: "tagged", IP: "10.0.0.7", Mask: 32, Status: true, Source: "inventory", GUID: "e"},
+	}
+	imported := []Alias{
+		{Alias: "takeover", IP: "10.0.0.1", Mask: 32, Status: true},
+		{Alias: "new-name", IP: "10.0.0.2", Mask: 32, Status: true},
+		{Alias: "same", IP: "10.0.0.3", Mask: 32, Status: true},
+		{Alias: "tagged", IP: "10.0.0.7", Mask: 32, Status: true, Tags: []string{"pbx"}},
+		{Alias: "fresh", IP: "10.0.0.6", Mask: 32, Status: true},
+	}
+
+	diff, err := Compare(stored, imported, "inventory")
+	if err != nil {
+		t.Fatalf("[TestCompare] %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Alias != "fresh" || diff.Added[0].Source != "inventory" {
+		t.Errorf("[TestCompare] added %+v", diff.Added)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].To.GUID != "a" || diff.Changed[0].To.Alias != "new-name" ||
+		diff.Changed[1].To.GUID != "e" {
+		t.Errorf("[TestCompare] changed %+v", diff.Changed)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].GUID != "c" {
+		t.Errorf("[TestCompare] removed %+v", diff.Removed)
+	}
+	if len(diff.Conflicts) != 1 || diff.Conflicts[0].Alias != "takeover" {
+		t.Errorf("[TestCompare] conflicts %+v", diff.Conflicts)
+	}
+	if diff.Unchanged != 1 || diff.Empty() {
+		t.Errorf("[TestCompare] unchanged %d", diff.Unchanged)
+	}
+
+	if _, err := Compare(stored, append(imported, imported[0]), "inventory"); err == nil {
+		t.Errorf("[TestCompare] duplicate accepted")
+	}
+	if _, err := Compare(stored, imported, ""); err == nil {
+		t.Errorf("[TestCompare] empty source accepted")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+
+	names := map[string]string{
+		"/etc/kamailio/dispatcher.list": FormatDispatcher,
+		"address.dump":                  FormatAddress,
+		"aliases.CSV":                   FormatCSV,
+		"inventory.yml":                 FormatYAML,
+		"inventory.json":                FormatJSON,
+	}
+	for name, format := range names {
+		if got := DetectFormat(name); got != format {
+			t.Errorf("[TestDetectFormat] %s: %s", name, got)
+		}
+	}
+}
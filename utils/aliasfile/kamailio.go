@@ -0,0 +1,183 @@
+package aliasfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// parseDispatcher reads a Kamailio dispatcher.list:
+//
+//	setid destination [flags [priority [attrs]]]
+//
+// The alias is the duid attribute, or dispatcher-<setid>. Destinations which
+// are host names can't be aliased and are skipped.
+func parseDispatcher(data []byte) ([]Alias, error) {
+
+	aliases := []Alias{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: no destination", line)
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("line %d: bad set id %q", line, fields[0])
+		}
+
+		host, port, err := sipHostPort(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if net.ParseIP(host) == nil {
+			continue
+		}
+
+		name := "dispatcher-" + fields[0]
+		if len(fields) > 4 {
+			for _, attr := range strings.Split(fields[4], ";") {
+				if strings.HasPrefix(attr, "duid=") && len(attr) > 5 {
+					name = attr[5:]
+				}
+			}
+		}
+		aliases = append(aliases, Alias{Alias: name, IP: host, Mask: -1, Port: port, Status: true})
+	}
+	return aliases, scanner.Err()
+}
+
+// sipHostPort splits a SIP URI like sip:10.0.0.1:5060;transport=tcp
+func sipHostPort(uri string) (string, int, error) {
+
+	rest := uri
+	if pos := strings.IndexByte(rest, ':'); pos > -1 && strings.HasPrefix(strings.ToLower(rest), "sip") {
+		rest = rest[pos+1:]
+	}
+	if pos := strings.IndexByte(rest, '@'); pos > -1 {
+		rest = rest[pos+1:]
+	}
+	if pos := strings.IndexAny(rest, ";?>"); pos > -1 {
+		rest = rest[:pos]
+	}
+
+	host, portStr := rest, ""
+	if strings.HasPrefix(rest, "[") {
+		end := strings.IndexByte(rest, ']')
+		if end == -1 {
+			return "", 0, fmt.Errorf("bad destination %q", uri)
+		}
+		host = rest[1:end]
+		portStr = strings.TrimPrefix(rest[end+1:], ":")
+	} else if pos := strings.LastIndexByte(rest, ':'); pos > -1 {
+		host, portStr = rest[:pos], rest[pos+1:]
+	}
+
+	port := 0
+	if portStr != "" {
+		var err error
+		if port, err = strconv.Atoi(portStr); err != nil {
+			return "", 0, fmt.Errorf("bad port in %q", uri)
+		}
+	}
+	return host, port, nil
+}
+
+// parseAddress reads a dump of the Kamailio address table, either the db_text
+// file, with a header like "id(int,auto) grp(int) ip_addr(string) ..." and rows
+// separated by colons, or tab or space separated rows with a header of the
+// column names, as printed by mysql -B or psql. The alias is the tag, or
+// address-<grp>.
+func parseAddress(data []byte) ([]Alias, error) {
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	var columns map[string]int
+	dbText := false
+	aliases := []Alias{}
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if columns == nil {
+			columns = map[string]int{}
+			dbText = strings.Contains(text, "(")
+			for i, name := range strings.Fields(text) {
+				if pos := strings.IndexByte(name, '('); pos > -1 {
+					name = name[:pos]
+				}
+				columns[strings.ToLower(name)] = i
+			}
+			if _, ok := columns["ip_addr"]; !ok {
+				return nil, fmt.Errorf("line %d: the header has no ip_addr column", line)
+			}
+			continue
+		}
+
+		var fields []string
+		if dbText {
+			fields = splitDBText(text)
+		} else if strings.Contains(text, "\t") {
+			fields = strings.Split(text, "\t")
+		} else {
+			fields = strings.Fields(text)
+		}
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				val := strings.TrimSpace(fields[i])
+				if val == "NULL" || val == `\N` {
+					return ""
+				}
+				return val
+			}
+			return ""
+		}
+
+		a := Alias{IP: value("ip_addr"), Mask: -1, Status: true, Alias: value("tag")}
+		for _, val := range []struct {
+			name string
+			dst  *int
+		}{{"mask", &a.Mask}, {"port", &a.Port}} {
+			if str := value(val.name); str != "" {
+				n, err := strconv.Atoi(str)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: bad %s %q", line, val.name, str)
+				}
+				*val.dst = n
+			}
+		}
+		if a.Alias == "" {
+			a.Alias = "address-" + value("grp")
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, scanner.Err()
+}
+
+// splitDBText splits a db_text row, a colon in a value is escaped as \:
+func splitDBText(row string) []string {
+
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row):
+			i++
+			field.WriteByte(row[i])
+		case row[i] == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(row[i])
+		}
+	}
+	return append(fields, field.String())
+}
@@ -0,0 +1,264 @@
+// Package decoder keeps the message decoders by HEP payload type and profile.
+// Native Go decoders are tried first, external processes are the fallback.
+package decoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// ErrSkip is returned by a decoder which doesn't handle the record, the next one is tried
+var ErrSkip = errors.New("decoder: record not handled")
+
+// ErrNoDecoder is returned when no decoder could decode the record
+var ErrNoDecoder = errors.New("decoder: no decoder for the record")
+
+// Record is a stored message to decode
+type Record struct {
+	ID          float64
+	Node        string
+	Table       string
+	PayloadType int
+	Profile     string
+	Raw         string
+	Date        time.Time
+	/* the whole row, needed to write a pcap */
+	Row *gabs.Container
+}
+
+// NewRecord builds a record of a row of the hep_proto_<type>_<profile> table
+func NewRecord(node, table string, row *gabs.Container) *Record {
+
+	rec := &Record{Node: node, Table: table, Row: row}
+	rec.ID, _ = row.S("id").Data().(float64)
+	rec.Raw, _ = row.S("raw").Data().(string)
+	if date, ok := row.S("create_date").Data().(string); ok {
+		rec.Date, _ = time.Parse(time.RFC3339, date)
+	}
+
+	key := strings.TrimPrefix(table, "hep_proto_")
+	if pos := strings.IndexByte(key, '_'); pos > -1 {
+		rec.PayloadType, _ = strconv.Atoi(key[:pos])
+		rec.Profile = key[pos+1:]
+	} else {
+		rec.PayloadType, _ = strconv.Atoi(key)
+	}
+	return rec
+}
+
+// Key is the payload type and profile, like 1_call
+func (rec *Record) Key() string {
+	if rec.Profile == "" {
+		return strconv.Itoa(rec.PayloadType)
+	}
+	return fmt.Sprintf("%d_%s", rec.PayloadType, rec.Profile)
+}
+
+// CacheKey identifies the stored message
+func (rec *Record) CacheKey() string {
+	return fmt.Sprintf("%s/%s/%.0f", rec.Node, rec.Table, rec.ID)
+}
+
+// Decoder turns a record into named sections, like sip and sdp
+type Decoder interface {
+	Name() string
+	Native() bool
+	Decode(ctx context.Context, rec *Record) (map[string]interface{}, error)
+}
+
+// Checker is a decoder which can tell if it is usable
+type Checker interface {
+	Check() error
+}
+
+// Func is a native decoder
+type Func struct {
+	name string
+	fn   func(rec *Record) (map[string]interface{}, error)
+}
+
+// NewFunc returns a native decoder calling fn
+func NewFunc(name string, fn func(rec *Record) (map[string]interface{}, error)) *Func {
+	return &Func{name: name, fn: fn}
+}
+
+// Name of the decoder
+func (f *Func) Name() string { return f.name }
+
+// Native is true
+func (f *Func) Native() bool { return true }
+
+// Decode calls the function
+func (f *Func) Decode(ctx context.Context, rec *Record) (map[string]interface{}, error) {
+	return f.fn(rec)
+}
+
+// Result is the output of the decoder which handled the record
+type Result struct {
+	Decoder  string
+	Sections map[string]interface{}
+}
+
+// Status is the health of a decoder
+type Status struct {
+	Name         string   `json:"name"`
+	Native       bool     `json:"native"`
+	Keys         []string `json:"keys"`
+	Healthy      bool     `json:"healthy"`
+	Calls        int64    `json:"calls"`
+	Errors       int64    `json:"errors"`
+	Timeouts     int64    `json:"timeouts"`
+	CacheHits    int64    `json:"cache_hits"`
+	LastError    string   `json:"last_error,omitempty"`
+	LastDuration string   `json:"last_duration,omitempty"`
+}
+
+type entry struct {
+	decoder Decoder
+	keys    []string
+	status  Status
+}
+
+// Registry holds the decoders by key. A key is a payload type and profile like
+// 1_call, a payload type like 54, or * for every record.
+type Registry struct {
+	mu      sync.RWMutex
+	keys    map[string][]*entry
+	entries []*entry
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{keys: map[string][]*entry{}}
+}
+
+// Register adds the decoder for the keys, a decoder can be registered more than once
+func (r *Registry) Register(d Decoder, keys ...string) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var e *entry
+	for _, val := range r.entries {
+		if val.decoder == d {
+			e = val
+		}
+	}
+	if e == nil {
+		e = &entry{decoder: d, status: Status{Name: d.Name(), Native: d.Native()}}
+		r.entries = append(r.entries, e)
+	}
+	for _, key := range keys {
+		e.keys = append(e.keys, key)
+		r.keys[key] = append(r.keys[key], e)
+	}
+}
+
+// Decoders returns the decoders of a record in the order they are tried: natives
+// before externals, then the profile key before the payload type key before *
+func (r *Registry) Decoders(rec *Record) []Decoder {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []*entry
+	seen := map[*entry]bool{}
+	for _, key := range []string{rec.Key(), strconv.Itoa(rec.PayloadType), "*"} {
+		for _, e := range r.keys[key] {
+			if !seen[e] {
+				seen[e] = true
+				list = append(list, e)
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].decoder.Native() && !list[j].decoder.Native()
+	})
+
+	decoders := make([]Decoder, len(list))
+	for i, e := range list {
+		decoders[i] = e.decoder
+	}
+	return decoders
+}
+
+// Has is true if a decoder is registered for the record
+func (r *Registry) Has(rec *Record) bool {
+	return len(r.Decoders(rec)) > 0
+}
+
+// Decode returns the result of the first decoder which handles the record
+func (r *Registry) Decode(ctx context.Context, rec *Record) (*Result, error) {
+
+	var lastErr error = ErrNoDecoder
+	for _, d := range r.Decoders(rec) {
+		start := time.Now()
+		sections, err := d.Decode(ctx, rec)
+		if err == ErrSkip {
+			continue
+		}
+		r.record(d, time.Since(start), err)
+		if err == nil {
+			return &Result{Decoder: d.Name(), Sections: sections}, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", d.Name(), err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+func (r *Registry) record(d Decoder, took time.Duration, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.entries {
+		if e.decoder != d {
+			continue
+		}
+		e.status.Calls++
+		e.status.LastDuration = took.String()
+		if err != nil {
+			e.status.Errors++
+			e.status.LastError = err.Error()
+			if errors.Is(err, context.DeadlineExceeded) {
+				e.status.Timeouts++
+			}
+		}
+	}
+}
+
+// Status returns the health of every decoder
+func (r *Registry) Status() []Status {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Status, 0, len(r.entries))
+	for _, e := range r.entries {
+		s := e.status
+		s.Keys = append([]string{}, e.keys...)
+		s.Healthy = true
+		if c, ok := e.decoder.(interface{ CacheHits() int64 }); ok {
+			s.CacheHits = c.CacheHits()
+		}
+		if c, ok := e.decoder.(Checker); ok {
+			if err := c.Check(); err != nil {
+				s.Healthy = false
+				s.LastError = err.Error()
+			}
+		}
+		list = append(list, s)
+	}
+	return list
+}
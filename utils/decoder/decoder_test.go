@@ -0,0 +1,186 @@
+package decoder
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+const testInvite = "INVITE sip:bob@example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK776asdhds\r\n" +
+	"From: <sip:alice@example.com>;tag=1928301774\r\n" +
+	"To: <sip:bob@example.com>\r\n" +
+	"Call-ID: a84b4c76e66710\r\n" +
+	"CSeq: 314159 INVITE\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"Content-Length: 26\r\n" +
+	"\r\n" +
+	"v=0\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 1.2.3.4\r\n"
+
+func testRecord(t *testing.T, table, raw string) *Record {
+	row := gabs.New()
+	row.Set(float64(7), "id")
+	row.Set(raw, "raw")
+	row.Set("2020-01-02T03:04:05Z", "create_date")
+	return NewRecord("node1", table, row)
+}
+
+func shell(t *testing.T, script string, workers int, timeout time.Duration, cacheSize int) *External {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	e := NewExternal("sh", "sh", []string{"-c", script}, workers, timeout, cacheSize)
+	e.Input = func(rec *Record) ([]byte, error) {
+		return []byte(rec.Raw), nil
+	}
+	return e
+}
+
+func TestNewRecord(t *testing.T) {
+
+	rec := testRecord(t, "hep_proto_1_call", "x")
+	if rec.PayloadType != 1 || rec.Profile != "call" || rec.Key() != "1_call" {
+		t.Errorf("[TestNewRecord] got type %d profile %q", rec.PayloadType, rec.Profile)
+	}
+	if rec.CacheKey() != "node1/hep_proto_1_call/7" {
+		t.Errorf("[TestNewRecord] cache key %q", rec.CacheKey())
+	}
+	if rec.Date.Year() != 2020 {
+		t.Errorf("[TestNewRecord] date %v", rec.Date)
+	}
+}
+
+func TestRegistryOrder(t *testing.T) {
+
+	var called []string
+	fn := func(name string, err error) *Func {
+		return NewFunc(name, func(rec *Record) (map[string]interface{}, error) {
+			called = append(called, name)
+			return map[string]interface{}{name: true}, err
+		})
+	}
+
+	r := NewRegistry()
+	ext := shell(t, "echo '{}'", 1, time.Second, 0)
+	r.Register(ext, "1_call")
+	r.Register(fn("any", nil), "*")
+	r.Register(fn("skip", ErrSkip), "1_call")
+	r.Register(fn("broken", errors.New("broken")), "1")
+
+	result, err := r.Decode(context.Background(), testRecord(t, "hep_proto_1_call", "x"))
+	if err != nil {
+		t.Fatalf("[TestRegistryOrder] %v", err)
+	}
+	if result.Decoder != "any" || strings.Join(called, ",") != "skip,broken,any" {
+		t.Errorf("[TestRegistryOrder] decoded by %s after %v", result.Decoder, called)
+	}
+
+	status := r.Status()
+	if len(status) != 4 {
+		t.Fatalf("[TestRegistryOrder] %d statuses", len(status))
+	}
+	for _, s := range status {
+		switch s.Name {
+		case "skip":
+			if s.Calls != 0 {
+				t.Errorf("[TestRegistryOrder] a skip counts as a call")
+			}
+		case "broken":
+			if s.Errors != 1 || s.LastError == "" {
+				t.Errorf("[TestRegistryOrder] broken status %+v", s)
+			}
+		}
+	}
+
+	if _, err := NewRegistry().Decode(context.Background(), testRecord(t, "hep_proto_100_default", "x")); err != ErrNoDecoder {
+		t.Errorf("[TestRegistryOrder] no decoder: %v", err)
+	}
+}
+
+func TestNative(t *testing.T) {
+
+	r := NewRegistry()
+	RegisterNative(r)
+
+	result, err := r.Decode(context.Background(), testRecord(t, "hep_proto_1_call", testInvite))
+	if err != nil || result.Decoder != "sip" || result.Sections["sip"] == nil || result.Sections["sdp"] == nil {
+		t.Errorf("[TestNative] sip: %v %+v", err, result)
+	}
+
+	result, err = r.Decode(context.Background(), testRecord(t, "hep_proto_5_default", `{"type":200,"ssrc":1}`))
+	if err != nil || result.Sections["rtcp"] == nil {
+		t.Errorf("[TestNative] rtcp: %v %+v", err, result)
+	}
+
+	result, err = r.Decode(context.Background(), testRecord(t, "hep_proto_54_default", "not a hex dump"))
+	if err != ErrNoDecoder {
+		t.Errorf("[TestNative] text decoded as isup: %v %+v", err, result)
+	}
+}
+
+func TestExternal(t *testing.T) {
+
+	ext := shell(t, `cat >/dev/null; echo "running as root" >&2; echo '[{"layers":{}}]'`, 2, 5*time.Second, 10)
+	r := NewRegistry()
+	r.Register(ext, "100")
+
+	rec := testRecord(t, "hep_proto_100_default", "payload")
+	for i := 0; i < 2; i++ {
+		result, err := r.Decode(context.Background(), rec)
+		if err != nil {
+			t.Fatalf("[TestExternal] %v", err)
+		}
+		if _, ok := result.Sections["decoded"].([]interface{}); !ok {
+			t.Errorf("[TestExternal] decoded %+v", result.Sections)
+		}
+	}
+	if ext.CacheHits() != 1 {
+		t.Errorf("[TestExternal] %d cache hits", ext.CacheHits())
+	}
+
+	bad := shell(t, `echo "no such field" >&2; exit 2`, 1, 5*time.Second, 10)
+	if _, err := bad.Decode(context.Background(), rec); err == nil || !strings.Contains(err.Error(), "no such field") {
+		t.Errorf("[TestExternal] stderr not in the error: %v", err)
+	}
+}
+
+func TestExternalTimeout(t *testing.T) {
+
+	slow := shell(t, "exec sleep 5", 1, 100*time.Millisecond, 0)
+	r := NewRegistry()
+	r.Register(slow, "100")
+
+	start := time.Now()
+	_, err := r.Decode(context.Background(), testRecord(t, "hep_proto_100_default", "x"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("[TestExternalTimeout] err %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("[TestExternalTimeout] took %v", time.Since(start))
+	}
+	if s := r.Status(); s[0].Timeouts != 1 {
+		t.Errorf("[TestExternalTimeout] status %+v", s[0])
+	}
+}
+
+func TestCache(t *testing.T) {
+
+	c := newCache(2)
+	c.add("a", 1)
+	c.add("b", 2)
+	c.get("a")
+	c.add("c", 3)
+	if _, ok := c.get("b"); ok {
+		t.Errorf("[TestCache] b should be dropped")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Errorf("[TestCache] a should be kept")
+	}
+}
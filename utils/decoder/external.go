@@ -0,0 +1,206 @@
+package decoder
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/exportwriter"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// External runs a process per record, like tshark, and parses its JSON output.
+// A pool limits the processes running at once, each one is killed after Timeout.
+type External struct {
+	name    string
+	Binary  string
+	Args    []string
+	UID     uint32
+	GID     uint32
+	Timeout time.Duration
+	/* Input writes the record for the stdin of the process, a pcap by default */
+	Input func(rec *Record) ([]byte, error)
+
+	workers   chan struct{}
+	cache     *cache
+	cacheHits int64
+}
+
+// NewExternal returns an external decoder with a pool of workers and a result cache
+// of cacheSize records, 0 disables the cache
+func NewExternal(name, binary string, args []string, workers int, timeout time.Duration, cacheSize int) *External {
+
+	if workers < 1 {
+		workers = 1
+	}
+	return &External{
+		name:    name,
+		Binary:  binary,
+		Args:    args,
+		Timeout: timeout,
+		Input:   PcapInput,
+		workers: make(chan struct{}, workers),
+		cache:   newCache(cacheSize),
+	}
+}
+
+// Name of the decoder
+func (e *External) Name() string { return e.name }
+
+// Native is false
+func (e *External) Native() bool { return false }
+
+// Check tells if the binary can be run
+func (e *External) Check() error {
+	_, err := exec.LookPath(e.Binary)
+	return err
+}
+
+// CacheHits is the number of records served from the cache
+func (e *External) CacheHits() int64 {
+	return atomic.LoadInt64(&e.cacheHits)
+}
+
+// Decode runs the process, the stdout is the JSON result and the stderr is
+// only used in the error
+func (e *External) Decode(ctx context.Context, rec *Record) (map[string]interface{}, error) {
+
+	key := rec.CacheKey()
+	if data, ok := e.cache.get(key); ok {
+		atomic.AddInt64(&e.cacheHits, 1)
+		return map[string]interface{}{"decoded": data}, nil
+	}
+
+	input, err := e.Input(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+
+	/* wait for a free worker */
+	select {
+	case e.workers <- struct{}{}:
+		defer func() { <-e.workers }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.Binary, e.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	/* change to the configured user when running under root, the warnings
+	   of the decoder about root go to stderr and don't break the output */
+	if os.Getuid() == 0 || os.Getgid() == 0 {
+		if e.UID != 0 && e.GID != 0 {
+			cmd.SysProcAttr = &syscall.SysProcAttr{
+				Credential: &syscall.Credential{Uid: e.UID, Gid: e.GID, NoSetGroups: true},
+			}
+		} else {
+			logger.Debug(fmt.Sprintf("running %s under root, please set uid and gid in the config", e.Binary))
+		}
+	}
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%v: %s", err, firstLine(stderr.String()))
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(stdout.Bytes(), &data); err != nil {
+		return nil, fmt.Errorf("bad json: %v: %s", err, firstLine(stderr.String()))
+	}
+
+	e.cache.add(key, data)
+	return map[string]interface{}{"decoded": data}, nil
+}
+
+func firstLine(str string) string {
+	str = strings.TrimSpace(str)
+	if pos := strings.IndexByte(str, '\n'); pos > -1 {
+		return str[:pos]
+	}
+	return str
+}
+
+// PcapInput writes the row as a pcap file
+func PcapInput(rec *Record) ([]byte, error) {
+
+	var buffer bytes.Buffer
+	export := exportwriter.NewWriter(buffer)
+	if err := export.WritePcapHeader(65536, 1); err != nil {
+		return nil, err
+	}
+	if err := export.WriteDataPcapBuffer(rec.Row); err != nil {
+		return nil, err
+	}
+	return export.Buffer.Bytes(), nil
+}
+
+// cache keeps the last results, the oldest one is dropped first
+type cache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type cacheItem struct {
+	key  string
+	data interface{}
+}
+
+func newCache(size int) *cache {
+	return &cache{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *cache) get(key string) (interface{}, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*cacheItem).data, true
+	}
+	return nil, false
+}
+
+func (c *cache) add(key string, data interface{}) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		el.Value.(*cacheItem).data = data
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheItem{key: key, data: data})
+	for c.order.Len() > c.size {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.items, el.Value.(*cacheItem).key)
+	}
+}
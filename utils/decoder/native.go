@@ -0,0 +1,73 @@
+package decoder
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/sipcapture/homer-app/utils/isup"
+	"github.com/sipcapture/homer-app/utils/sipparser"
+)
+
+// SIP decodes a SIP message, its SDP and ISUP bodies get their own sections
+var SIP = NewFunc("sip", func(rec *Record) (map[string]interface{}, error) {
+
+	sipMsg := sipparser.ParseMsg(rec.Raw, nil, nil)
+	if sipMsg.StartLine == nil {
+		return nil, ErrSkip
+	}
+	sections := map[string]interface{}{"sip": sipMsg}
+	if sipMsg.Sdp != nil {
+		sections["sdp"] = sipMsg.Sdp
+	}
+	if sipMsg.Isup != nil {
+		sections["isup"] = sipMsg.Isup
+	}
+	return sections, nil
+})
+
+// SDP decodes a bare session description
+var SDP = NewFunc("sdp", func(rec *Record) (map[string]interface{}, error) {
+
+	if !strings.HasPrefix(strings.TrimSpace(rec.Raw), "v=") {
+		return nil, ErrSkip
+	}
+	return map[string]interface{}{"sdp": sipparser.ParseSdp(rec.Raw)}, nil
+})
+
+// ISUP decodes ISUP stored as a hex dump
+var ISUP = NewFunc("isup", func(rec *Record) (map[string]interface{}, error) {
+
+	data, err := hex.DecodeString(strings.Replace(rec.Raw, " ", "", -1))
+	if err != nil {
+		return nil, ErrSkip
+	}
+	msg, err := isup.DecodeWithCIC(data)
+	if msg == nil {
+		return nil, err
+	}
+	return map[string]interface{}{"isup": msg}, nil
+})
+
+// RTCP decodes the RTCP reports, stored as JSON by the capture server
+var RTCP = NewFunc("rtcp", func(rec *Record) (map[string]interface{}, error) {
+
+	var report map[string]interface{}
+	if err := json.Unmarshal([]byte(rec.Raw), &report); err != nil {
+		return nil, ErrSkip
+	}
+	if report["type"] == nil && report["sender_information"] == nil && report["report_blocks"] == nil {
+		return nil, errors.New("not an RTCP report")
+	}
+	return map[string]interface{}{"rtcp": report}, nil
+})
+
+// RegisterNative adds the native decoders for their payload types, SIP is 1,
+// RTCP 5 and ISUP 54
+func RegisterNative(r *Registry) {
+	r.Register(SIP, "1")
+	r.Register(SDP, "1")
+	r.Register(RTCP, "5")
+	r.Register(ISUP, "54")
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/Jeffail/gabs/v2"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
+	"github.com/sipcapture/homer-app/utils/alias"
 	"github.com/sipcapture/homer-app/utils/heputils"
 	"github.com/sipcapture/homer-app/utils/logger"
 )
@@ -41,6 +42,8 @@ type ExportElement struct {
 type Writer struct {
 	Buffer   bytes.Buffer
 	tsScaler int
+	// Aliases names the addresses of the text export, can be nil
+	Aliases *alias.Resolver
 	// Moving this into the struct seems to save an allocation for each call to writePacketHeader
 	buf [16]byte
 }
@@ -129,14 +132,26 @@ func (w *Writer) WriteDataToBuffer(h *gabs.Container) error {
 	packet, _ := w.createExportElementfromGab(h)
 
 	w.Buffer.WriteString("proto:" + packet.ProtocolText + " " + packet.CreateDate + "  " +
-		packet.SrcIP + ":" + strconv.FormatFloat(packet.SrcPort, 'f', 0, 64) +
-		" ---> " + packet.DstIP + ":" + strconv.FormatFloat(packet.DstPort, 'f', 0, 64) + "\r\n\r\n")
+		w.hostName(packet.SrcIP, packet.SrcPort, packet.CaptureID) +
+		" ---> " + w.hostName(packet.DstIP, packet.DstPort, packet.CaptureID) + "\r\n\r\n")
 	w.Buffer.WriteString(packet.Message)
 	_, err := w.Buffer.WriteString("\r\n")
 
 	return err
 }
 
+// hostName is ip:port, with the alias in front when there is one
+func (w *Writer) hostName(ip string, port float64, captureID float64) string {
+
+	hostPort := ip + ":" + strconv.FormatFloat(port, 'f', 0, 64)
+	if w.Aliases != nil {
+		if name, ok := w.Aliases.Alias(ip, int(port), strconv.FormatFloat(captureID, 'f', 0, 64)); ok {
+			return name + " (" + hostPort + ")"
+		}
+	}
+	return hostPort
+}
+
 // WriteDataToBuffer writes a file header out to the writer.
 // This must be called exactly once per output.
 func (w *Writer) WriteDataPcapBuffer(h *gabs.Container) error {
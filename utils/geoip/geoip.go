@@ -0,0 +1,185 @@
+// Package geoip looks up the country, city and autonomous system of an address in
+// local MaxMind DB (.mmdb) files, like GeoLite2-City and GeoLite2-ASN. It makes no
+// network calls, the files are read again when they change.
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// CheckInterval is how often the files are checked for changes
+var CheckInterval = 10 * time.Second
+
+// Geo is what the databases know of an address
+type Geo struct {
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	ASN     uint   `json:"asn,omitempty"`
+	Org     string `json:"org,omitempty"`
+}
+
+// FileStatus is the state of a database file
+type FileStatus struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type,omitempty"`
+	Loaded  time.Time `json:"loaded,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Healthy bool      `json:"healthy"`
+}
+
+type file struct {
+	path    string
+	mu      sync.Mutex
+	reader  *Reader
+	modTime time.Time
+	checked time.Time
+	loaded  time.Time
+	err     error
+}
+
+// get returns the reader, loading the file again when it has changed
+func (f *file) get() *Reader {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if now := time.Now(); now.Sub(f.checked) >= CheckInterval {
+		f.checked = now
+		f.load()
+	}
+	return f.reader
+}
+
+/* a broken file keeps the reader of the last good one */
+func (f *file) load() {
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.err = err
+		return
+	}
+	if f.reader != nil && info.ModTime().Equal(f.modTime) {
+		return
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		f.err = err
+		return
+	}
+	reader, err := NewReader(data)
+	if err != nil {
+		f.err = err
+		return
+	}
+	f.reader, f.modTime, f.loaded, f.err = reader, info.ModTime(), time.Now(), nil
+}
+
+func (f *file) status() FileStatus {
+
+	reader := f.get()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := FileStatus{Path: f.path, Loaded: f.loaded, Healthy: reader != nil && f.err == nil}
+	if reader != nil {
+		s.Type = reader.DatabaseType
+	}
+	if f.err != nil {
+		s.Error = f.err.Error()
+	}
+	return s
+}
+
+// DB looks up addresses in a location database, of cities or countries, and in
+// an ASN database. Either path can be empty.
+type DB struct {
+	location *file
+	asn      *file
+}
+
+// NewDB returns the databases of the paths, they are read on the first lookup
+func NewDB(locationPath, asnPath string) *DB {
+
+	db := &DB{}
+	if locationPath != "" {
+		db.location = &file{path: locationPath}
+	}
+	if asnPath != "" {
+		db.asn = &file{path: asnPath}
+	}
+	return db
+}
+
+// Lookup returns what the databases know of the address, nil if nothing
+func (db *DB) Lookup(ip string) *Geo {
+
+	addr := net.ParseIP(ip)
+	if db == nil || addr == nil {
+		return nil
+	}
+
+	geo := Geo{}
+	if m := db.record(db.location, addr); m != nil {
+		geo.Country, _ = value(m, "country", "iso_code").(string)
+		if geo.Country == "" {
+			geo.Country, _ = value(m, "registered_country", "iso_code").(string)
+		}
+		geo.City, _ = value(m, "city", "names", "en").(string)
+	}
+	if m := db.record(db.asn, addr); m != nil {
+		asn, _ := value(m, "autonomous_system_number").(uint64)
+		geo.ASN = uint(asn)
+		geo.Org, _ = value(m, "autonomous_system_organization").(string)
+	}
+
+	if geo == (Geo{}) {
+		return nil
+	}
+	return &geo
+}
+
+func (db *DB) record(f *file, addr net.IP) map[string]interface{} {
+
+	if f == nil {
+		return nil
+	}
+	reader := f.get()
+	if reader == nil {
+		return nil
+	}
+	rec, err := reader.Lookup(addr)
+	if err != nil {
+		return nil
+	}
+	m, _ := rec.(map[string]interface{})
+	return m
+}
+
+// Status returns the state of the configured files
+func (db *DB) Status() []FileStatus {
+
+	status := []FileStatus{}
+	for _, f := range []*file{db.location, db.asn} {
+		if f != nil {
+			status = append(status, f.status())
+		}
+	}
+	return status
+}
+
+func value(m map[string]interface{}, keys ...string) interface{} {
+
+	var cur interface{} = m
+	for _, key := range keys {
+		next, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = next[key]
+	}
+	return cur
+}
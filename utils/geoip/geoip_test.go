@@ -0,0 +1,277 @@
+package geoip
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+/* a pointer to an offset of the data section */
+type pointer uint
+
+func encodeUint(typ byte, val uint64) []byte {
+	var b []byte
+	for ; val > 0; val >>= 8 {
+		b = append([]byte{byte(val)}, b...)
+	}
+	return append(control(typ, len(b)), b...)
+}
+
+func control(typ byte, size int) []byte {
+	var ext []byte
+	if size >= 29 {
+		ext, size = []byte{byte(size - 29)}, 29
+	}
+	if typ > 7 {
+		return append([]byte{byte(size), typ - 7}, ext...)
+	}
+	return append([]byte{typ<<5 | byte(size)}, ext...)
+}
+
+func encode(v interface{}) []byte {
+
+	switch v := v.(type) {
+	case pointer:
+		return []byte{typePointer<<5 | byte(v>>8&0x7), byte(v)}
+	case string:
+		return append(control(typeString, len(v)), v...)
+	case uint16:
+		return encodeUint(typeUint16, uint64(v))
+	case uint32:
+		return encodeUint(typeUint32, uint64(v))
+	case bool:
+		if v {
+			return control(typeBool, 1)
+		}
+		return control(typeBool, 0)
+	case []interface{}:
+		b := control(typeArray, len(v))
+		for _, val := range v {
+			b = append(b, encode(val)...)
+		}
+		return b
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b := control(typeMap, len(v))
+		for _, key := range keys {
+			b = append(append(b, encode(key)...), encode(v[key])...)
+		}
+		return b
+	}
+	panic("can't encode")
+}
+
+/* a record is empty (-1), a node or a data offset */
+type testRecord struct {
+	node int
+	data int
+}
+
+// buildDB writes a database of networks which don't overlap
+func buildDB(t *testing.T, ipVersion uint16, recordSize int, nets map[string]interface{}, shared string) []byte {
+
+	data := encode(shared)
+	nodes := [][2]testRecord{{{node: -1, data: -1}, {node: -1, data: -1}}}
+
+	cidrs := []string{}
+	for cidr := range nets {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := network.Mask.Size()
+		ip := network.IP
+		/* IPv4 is below ::/96 of an IPv6 tree */
+		if ip4 := ip.To4(); ip4 != nil && ipVersion == 6 {
+			ones += 96
+			ip = append(make(net.IP, 12), ip4...)
+		}
+		offset := len(data)
+		data = append(data, encode(nets[cidr])...)
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - uint(i%8)) & 1
+			if i == ones-1 {
+				nodes[node][bit] = testRecord{node: -1, data: offset}
+				break
+			}
+			if nodes[node][bit].node == -1 {
+				nodes = append(nodes, [2]testRecord{{node: -1, data: -1}, {node: -1, data: -1}})
+				nodes[node][bit].node = len(nodes) - 1
+			}
+			node = nodes[node][bit].node
+		}
+	}
+
+	count := len(nodes)
+	value := func(r testRecord) uint32 {
+		switch {
+		case r.node >= 0:
+			return uint32(r.node)
+		case r.data >= 0:
+			return uint32(count + dataSeparator + r.data)
+		}
+		return uint32(count)
+	}
+	var tree []byte
+	for _, n := range nodes {
+		left, right := value(n[0]), value(n[1])
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(left>>20&0xF0|right>>24&0x0F),
+				byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			tree = append(tree, byte(left>>24), byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, dataSeparator))
+	buf.Write(data)
+	buf.Write(metadataStart)
+	buf.Write(encode(map[string]interface{}{
+		"node_count":    uint32(count),
+		"record_size":   uint16(recordSize),
+		"ip_version":    ipVersion,
+		"database_type": "Test-DB",
+		"languages":     []interface{}{"en"},
+	}))
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+
+	nets := map[string]interface{}{
+		"81.2.69.0/24": map[string]interface{}{
+			"country": map[string]interface{}{"iso_code": "GB"},
+			"city":    map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+			"org":     pointer(0),
+		},
+		"2001:db8::/32": map[string]interface{}{"country": map[string]interface{}{"iso_code": "DE"}, "eu": true},
+	}
+
+	for _, size := range []int{24, 28, 32} {
+		r, err := NewReader(buildDB(t, 6, size, nets, "Shared Org"))
+		if err != nil {
+			t.Fatalf("[TestReader] %d: %v", size, err)
+		}
+		if r.DatabaseType != "Test-DB" {
+			t.Errorf("[TestReader] %d: type %q", size, r.DatabaseType)
+		}
+
+		rec, err := r.Lookup(net.ParseIP("81.2.69.160"))
+		m, _ := rec.(map[string]interface{})
+		if err != nil || value(m, "city", "names", "en") != "London" || m["org"] != "Shared Org" {
+			t.Errorf("[TestReader] %d: v4 %v %+v", size, err, rec)
+		}
+		rec, err = r.Lookup(net.ParseIP("2001:db8:1::5"))
+		m, _ = rec.(map[string]interface{})
+		if err != nil || value(m, "country", "iso_code") != "DE" || m["eu"] != true {
+			t.Errorf("[TestReader] %d: v6 %v %+v", size, err, rec)
+		}
+		for _, ip := range []string{"81.2.70.1", "10.0.0.1", "2001:db9::1"} {
+			if rec, err := r.Lookup(net.ParseIP(ip)); rec != nil || err != nil {
+				t.Errorf("[TestReader] %d: %s found %v %+v", size, ip, err, rec)
+			}
+		}
+	}
+
+	r, err := NewReader(buildDB(t, 4, 24, map[string]interface{}{"10.0.0.0/8": "private"}, ""))
+	if err != nil {
+		t.Fatalf("[TestReader] v4 tree: %v", err)
+	}
+	if rec, _ := r.Lookup(net.ParseIP("10.1.2.3")); rec != "private" {
+		t.Errorf("[TestReader] v4 tree got %v", rec)
+	}
+	if rec, _ := r.Lookup(net.ParseIP("::1")); rec != nil {
+		t.Errorf("[TestReader] v6 in v4 tree got %v", rec)
+	}
+
+	for _, bad := range [][]byte{[]byte("no metadata"), append(append([]byte{}, metadataStart...), 0xff)} {
+		if _, err := NewReader(bad); err == nil {
+			t.Errorf("[TestReader] bad file accepted")
+		}
+	}
+}
+
+func TestDB(t *testing.T) {
+
+	CheckInterval = 0
+	defer func() { CheckInterval = 10 * time.Second }()
+
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cityPath := filepath.Join(dir, "city.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	city := func(country string) []byte {
+		return buildDB(t, 6, 24, map[string]interface{}{
+			"81.2.69.0/24": map[string]interface{}{
+				"country": map[string]interface{}{"iso_code": country},
+				"city":    map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+			},
+		}, "")
+	}
+	ioutil.WriteFile(cityPath, city("GB"), 0644)
+	ioutil.WriteFile(asnPath, buildDB(t, 6, 28, map[string]interface{}{
+		"81.2.0.0/16": map[string]interface{}{
+			"autonomous_system_number":       uint32(20712),
+			"autonomous_system_organization": "Andrews & Arnold Ltd",
+		},
+	}, ""), 0644)
+
+	db := NewDB(cityPath, asnPath)
+	want := Geo{Country: "GB", City: "London", ASN: 20712, Org: "Andrews & Arnold Ltd"}
+	if geo := db.Lookup("81.2.69.160"); geo == nil || *geo != want {
+		t.Errorf("[TestDB] got %+v", geo)
+	}
+	if geo := db.Lookup("81.2.1.1"); geo == nil || geo.Country != "" || geo.ASN != 20712 {
+		t.Errorf("[TestDB] asn only got %+v", geo)
+	}
+	if geo := db.Lookup("192.168.1.1"); geo != nil {
+		t.Errorf("[TestDB] private got %+v", geo)
+	}
+
+	/* a changed file is read again, a broken one keeps the last good data */
+	ioutil.WriteFile(cityPath, city("IE"), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(cityPath, later, later)
+	if geo := db.Lookup("81.2.69.160"); geo == nil || geo.Country != "IE" {
+		t.Errorf("[TestDB] reload got %+v", geo)
+	}
+	ioutil.WriteFile(cityPath, []byte("broken"), 0644)
+	later = later.Add(time.Minute)
+	os.Chtimes(cityPath, later, later)
+	if geo := db.Lookup("81.2.69.160"); geo == nil || geo.Country != "IE" {
+		t.Errorf("[TestDB] broken file got %+v", geo)
+	}
+	status := db.Status()
+	if len(status) != 2 || status[0].Healthy || status[0].Error == "" || !status[1].Healthy || status[1].Type != "Test-DB" {
+		t.Errorf("[TestDB] status %+v", status)
+	}
+
+	if geo := (*DB)(nil).Lookup("81.2.69.160"); geo != nil {
+		t.Errorf("[TestDB] nil db got %+v", geo)
+	}
+}
@@ -0,0 +1,287 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// metadataStart marks the metadata at the end of the file
+var metadataStart = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// data types
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+/* the data section starts after 16 zero bytes behind the search tree */
+const dataSeparator = 16
+
+// Reader reads a database in the MaxMind DB format
+type Reader struct {
+	DatabaseType string
+	tree         []byte
+	data         decoder
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	ipv4Start    uint
+}
+
+// NewReader reads the database of the bytes of a .mmdb file
+func NewReader(buf []byte) (*Reader, error) {
+
+	pos := bytes.LastIndex(buf, metadataStart)
+	if pos == -1 {
+		return nil, errors.New("no MaxMind DB metadata")
+	}
+	meta := decoder{buf: buf[pos+len(metadataStart):]}
+	value, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("bad metadata: %v", err)
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("bad metadata: not a map")
+	}
+
+	r := &Reader{
+		nodeCount:  toUint(m["node_count"]),
+		recordSize: toUint(m["record_size"]),
+		ipVersion:  toUint(m["ip_version"]),
+	}
+	r.DatabaseType, _ = m["database_type"].(string)
+
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported ip version %d", r.ipVersion)
+	}
+	treeSize := r.recordSize * r.nodeCount / 4
+	if treeSize+dataSeparator > uint(pos) {
+		return nil, errors.New("the search tree is larger than the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = decoder{buf: buf[treeSize+dataSeparator : pos]}
+
+	/* IPv4 addresses are below ::/96 of an IPv6 tree */
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// record returns the left (0) or right (1) record of a node
+func (r *Reader) record(node uint, bit uint) uint {
+
+	b := r.tree
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return uint(b[off+3]&0xF0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+		}
+		return uint(b[off+3]&0x0F)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6])
+	}
+	off := node*8 + bit*4
+	return uint(binary.BigEndian.Uint32(b[off : off+4]))
+}
+
+// Lookup returns the record of the address, nil if the database has none
+func (r *Reader) Lookup(ip net.IP) (interface{}, error) {
+
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		node = r.ipv4Start
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < len(ip)*8 && node < r.nodeCount; i++ {
+		node = r.record(node, uint(ip[i/8]>>(7-uint(i%8))&1))
+	}
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, errors.New("the search tree is deeper than the address")
+	}
+
+	value, _, err := r.data.decode(node-r.nodeCount-dataSeparator, 0)
+	return value, err
+}
+
+type decoder struct {
+	buf []byte
+}
+
+/* a pointer may point to a map with pointers, but the depth is limited */
+const maxDepth = 32
+
+// decode returns the value at the offset and the offset after it
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+
+	if depth > maxDepth {
+		return nil, 0, errors.New("the data is nested too deep")
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("offset %d is outside of the data", offset)
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(ptr, depth+1)
+		return value, next, err
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errors.New("the extended type is missing")
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errors.New("the size is outside of the data")
+		}
+		val := uintOf(d.buf[offset : offset+n])
+		offset += n
+		size = []uint{29, 285, 65821}[n-1] + uint(val)
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("a map key is not a string")
+			}
+			if m[name], offset, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, size)
+		for i := range a {
+			var err error
+			if a[i], offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("a value of %d bytes is outside of the data", size)
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte{}, b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("a double of %d bytes", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("a float of %d bytes", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("an integer of %d bytes", size)
+		}
+		return uintOf(b), offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("an integer of %d bytes", size)
+		}
+		return int64(int32(uint32(uintOf(b)))), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// pointer returns the offset a pointer points to and the offset after it
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+
+	n := uint(ctrl>>3&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("the pointer is outside of the data")
+	}
+	val := uint(uintOf(d.buf[offset : offset+n]))
+	prefix := uint(ctrl & 0x7)
+	switch n {
+	case 1:
+		val |= prefix << 8
+	case 2:
+		val = (val | prefix<<16) + 2048
+	case 3:
+		val = (val | prefix<<24) + 526336
+	}
+	return val, offset + n, nil
+}
+
+func uintOf(b []byte) uint64 {
+	var val uint64
+	for _, c := range b {
+		val = val<<8 | uint64(c)
+	}
+	return val
+}
+
+func toUint(v interface{}) uint {
+	if val, ok := v.(uint64); ok {
+		return uint(val)
+	}
+	return 0
+}
@@ -0,0 +1,322 @@
+package hep
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// HEPv3 chunk types of the generic vendor (0)
+const (
+	ChunkIPFamily      = 1
+	ChunkIPProtocol    = 2
+	ChunkIPv4Src       = 3
+	ChunkIPv4Dst       = 4
+	ChunkIPv6Src       = 5
+	ChunkIPv6Dst       = 6
+	ChunkSrcPort       = 7
+	ChunkDstPort       = 8
+	ChunkTimeSeconds   = 9
+	ChunkTimeUseconds  = 10
+	ChunkProtocolType  = 11
+	ChunkCaptureID     = 12
+	ChunkKeepAlive     = 13
+	ChunkAuthKey       = 14
+	ChunkPayload       = 15
+	ChunkCompressed    = 16
+	ChunkCorrelationID = 17
+	ChunkVlan          = 18
+	ChunkNodeName      = 19
+
+	// HeaderLength is the size of the HEP3 magic and the total length
+	HeaderLength = 6
+	// MaxLength is the biggest frame the 16 bit length can describe
+	MaxLength = 65535
+
+	chunkHeaderLength = 6
+)
+
+// HEP payload types
+const (
+	PayloadSIP   = 1
+	PayloadRTCP  = 5
+	PayloadISUP  = 54
+	PayloadLog   = 100
+	PayloadEvent = 200
+)
+
+var (
+	magic = []byte("HEP3")
+
+	ErrNotHEP3   = errors.New("not a HEPv3 frame")
+	ErrTruncated = errors.New("truncated HEPv3 frame")
+	ErrNoPayload = errors.New("HEPv3 frame without payload")
+)
+
+// Packet is a decoded HEPv3 frame
+type Packet struct {
+	Version       uint8
+	Protocol      uint8
+	SrcIP         net.IP
+	DstIP         net.IP
+	SrcPort       uint16
+	DstPort       uint16
+	Tsec          uint32
+	Tmsec         uint32
+	ProtoType     uint8
+	NodeID        uint32
+	KeepAlive     uint16
+	NodePW        string
+	Payload       []byte
+	CID           string
+	Vlan          uint16
+	NodeName      string
+	Compressed    bool
+	UnknownChunks int
+}
+
+// Timestamp returns the capture time of the packet
+func (p *Packet) Timestamp() time.Time {
+	return time.Unix(int64(p.Tsec), int64(p.Tmsec)*1000)
+}
+
+// IsHEP3 checks the magic of the frame
+func IsHEP3(data []byte) bool {
+	return len(data) >= HeaderLength && bytes.Equal(data[:4], magic)
+}
+
+// FrameLength returns the length announced in the header, or -1 if the header is incomplete
+func FrameLength(data []byte) int {
+
+	if !IsHEP3(data) {
+		return -1
+	}
+	return int(binary.BigEndian.Uint16(data[4:6]))
+}
+
+// Decode parses a single HEPv3 frame. Bytes after the announced length are ignored.
+func Decode(data []byte) (*Packet, error) {
+
+	if !IsHEP3(data) {
+		return nil, ErrNotHEP3
+	}
+
+	length := FrameLength(data)
+	if length < HeaderLength || length > len(data) {
+		return nil, ErrTruncated
+	}
+
+	packet := &Packet{}
+	seenPayload := false
+
+	for offset := HeaderLength; offset < length; {
+		if length-offset < chunkHeaderLength {
+			return nil, ErrTruncated
+		}
+
+		vendor := binary.BigEndian.Uint16(data[offset:])
+		chunkType := binary.BigEndian.Uint16(data[offset+2:])
+		chunkLength := int(binary.BigEndian.Uint16(data[offset+4:]))
+
+		if chunkLength < chunkHeaderLength || offset+chunkLength > length {
+			return nil, fmt.Errorf("bad HEPv3 chunk %d length %d", chunkType, chunkLength)
+		}
+
+		value := data[offset+chunkHeaderLength : offset+chunkLength]
+		offset += chunkLength
+
+		if vendor != 0 {
+			packet.UnknownChunks++
+			continue
+		}
+
+		if err := packet.setChunk(chunkType, value); err != nil {
+			return nil, err
+		}
+
+		if chunkType == ChunkPayload || chunkType == ChunkCompressed {
+			seenPayload = true
+		}
+	}
+
+	if !seenPayload && packet.KeepAlive == 0 {
+		return nil, ErrNoPayload
+	}
+
+	return packet, nil
+}
+
+func (p *Packet) setChunk(chunkType uint16, value []byte) error {
+
+	expect := func(size int) error {
+		if len(value) != size {
+			return fmt.Errorf("bad HEPv3 chunk %d size %d", chunkType, len(value))
+		}
+		return nil
+	}
+
+	switch chunkType {
+	case ChunkIPFamily:
+		if err := expect(1); err != nil {
+			return err
+		}
+		switch value[0] {
+		case 2:
+			p.Version = 4
+		case 10:
+			p.Version = 6
+		default:
+			return fmt.Errorf("bad HEPv3 ip family %d", value[0])
+		}
+	case ChunkIPProtocol:
+		if err := expect(1); err != nil {
+			return err
+		}
+		p.Protocol = value[0]
+	case ChunkIPv4Src, ChunkIPv4Dst:
+		if err := expect(4); err != nil {
+			return err
+		}
+		if chunkType == ChunkIPv4Src {
+			p.SrcIP = net.IP(append([]byte{}, value...))
+		} else {
+			p.DstIP = net.IP(append([]byte{}, value...))
+		}
+	case ChunkIPv6Src, ChunkIPv6Dst:
+		if err := expect(16); err != nil {
+			return err
+		}
+		if chunkType == ChunkIPv6Src {
+			p.SrcIP = net.IP(append([]byte{}, value...))
+		} else {
+			p.DstIP = net.IP(append([]byte{}, value...))
+		}
+	case ChunkSrcPort, ChunkDstPort, ChunkKeepAlive, ChunkVlan:
+		if err := expect(2); err != nil {
+			return err
+		}
+		val := binary.BigEndian.Uint16(value)
+		switch chunkType {
+		case ChunkSrcPort:
+			p.SrcPort = val
+		case ChunkDstPort:
+			p.DstPort = val
+		case ChunkKeepAlive:
+			p.KeepAlive = val
+		default:
+			p.Vlan = val
+		}
+	case ChunkTimeSeconds, ChunkTimeUseconds, ChunkCaptureID:
+		if err := expect(4); err != nil {
+			return err
+		}
+		val := binary.BigEndian.Uint32(value)
+		switch chunkType {
+		case ChunkTimeSeconds:
+			p.Tsec = val
+		case ChunkTimeUseconds:
+			p.Tmsec = val
+		default:
+			p.NodeID = val
+		}
+	case ChunkProtocolType:
+		if err := expect(1); err != nil {
+			return err
+		}
+		p.ProtoType = value[0]
+	case ChunkAuthKey:
+		p.NodePW = string(value)
+	case ChunkPayload:
+		p.Payload = append([]byte{}, value...)
+	case ChunkCompressed:
+		p.Payload = append([]byte{}, value...)
+		p.Compressed = true
+	case ChunkCorrelationID:
+		p.CID = string(value)
+	case ChunkNodeName:
+		p.NodeName = string(value)
+	default:
+		p.UnknownChunks++
+	}
+
+	return nil
+}
+
+// Encode builds a HEPv3 frame from the packet
+func Encode(p *Packet) ([]byte, error) {
+
+	buffer := bytes.NewBuffer(make([]byte, 0, HeaderLength+128+len(p.Payload)))
+	buffer.Write(magic)
+	buffer.Write([]byte{0, 0})
+
+	family := byte(2)
+	srcChunk, dstChunk := uint16(ChunkIPv4Src), uint16(ChunkIPv4Dst)
+	srcIP, dstIP := p.SrcIP.To4(), p.DstIP.To4()
+	if p.Version == 6 || srcIP == nil || dstIP == nil {
+		family = 10
+		srcChunk, dstChunk = ChunkIPv6Src, ChunkIPv6Dst
+		srcIP, dstIP = p.SrcIP.To16(), p.DstIP.To16()
+	}
+	if srcIP == nil || dstIP == nil {
+		return nil, fmt.Errorf("bad source or destination address")
+	}
+
+	writeChunk(buffer, ChunkIPFamily, []byte{family})
+	writeChunk(buffer, ChunkIPProtocol, []byte{p.Protocol})
+	writeChunk(buffer, srcChunk, srcIP)
+	writeChunk(buffer, dstChunk, dstIP)
+	writeChunk(buffer, ChunkSrcPort, uint16Bytes(p.SrcPort))
+	writeChunk(buffer, ChunkDstPort, uint16Bytes(p.DstPort))
+	writeChunk(buffer, ChunkTimeSeconds, uint32Bytes(p.Tsec))
+	writeChunk(buffer, ChunkTimeUseconds, uint32Bytes(p.Tmsec))
+	writeChunk(buffer, ChunkProtocolType, []byte{p.ProtoType})
+	writeChunk(buffer, ChunkCaptureID, uint32Bytes(p.NodeID))
+
+	if p.NodePW != "" {
+		writeChunk(buffer, ChunkAuthKey, []byte(p.NodePW))
+	}
+	if p.CID != "" {
+		writeChunk(buffer, ChunkCorrelationID, []byte(p.CID))
+	}
+	if p.Vlan != 0 {
+		writeChunk(buffer, ChunkVlan, uint16Bytes(p.Vlan))
+	}
+	if p.NodeName != "" {
+		writeChunk(buffer, ChunkNodeName, []byte(p.NodeName))
+	}
+	if p.Compressed {
+		writeChunk(buffer, ChunkCompressed, p.Payload)
+	} else {
+		writeChunk(buffer, ChunkPayload, p.Payload)
+	}
+
+	data := buffer.Bytes()
+	if len(data) > MaxLength {
+		return nil, fmt.Errorf("HEPv3 frame too big: %d", len(data))
+	}
+	binary.BigEndian.PutUint16(data[4:6], uint16(len(data)))
+
+	return data, nil
+}
+
+func writeChunk(buffer *bytes.Buffer, chunkType uint16, value []byte) {
+	buffer.Write([]byte{0, 0})
+	buffer.Write(uint16Bytes(chunkType))
+	buffer.Write(uint16Bytes(uint16(len(value) + chunkHeaderLength)))
+	buffer.Write(value)
+}
+
+func uint16Bytes(val uint16) []byte {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, val)
+	return data
+}
+
+func uint32Bytes(val uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, val)
+	return data
+}
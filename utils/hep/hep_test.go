@@ -0,0 +1,103 @@
+package hep
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+
+	packet := &Packet{
+		Version:   4,
+		Protocol:  17,
+		SrcIP:     net.ParseIP("10.0.0.1"),
+		DstIP:     net.ParseIP("10.0.0.2"),
+		SrcPort:   5060,
+		DstPort:   5080,
+		Tsec:      1600000000,
+		Tmsec:     123456,
+		ProtoType: PayloadSIP,
+		NodeID:    2001,
+		NodePW:    "secret",
+		CID:       "call-1",
+		NodeName:  "edge-1",
+		Payload:   []byte("OPTIONS sip:10.0.0.2 SIP/2.0\r\n\r\n"),
+	}
+
+	data, err := Encode(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsHEP3(data) || FrameLength(data) != len(data) {
+		t.Fatalf("[TestEncodeDecode] bad header: %v", data[:6])
+	}
+
+	decoded, err := Decode(append(data, 0xff, 0xff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Version != 4 || decoded.Protocol != 17 || decoded.SrcPort != 5060 || decoded.DstPort != 5080 {
+		t.Errorf("[TestEncodeDecode] wrong transport: %+v", decoded)
+	}
+	if !decoded.SrcIP.Equal(packet.SrcIP) || !decoded.DstIP.Equal(packet.DstIP) {
+		t.Errorf("[TestEncodeDecode] wrong addresses: %s %s", decoded.SrcIP, decoded.DstIP)
+	}
+	if decoded.NodeID != 2001 || decoded.NodePW != "secret" || decoded.CID != "call-1" || decoded.NodeName != "edge-1" {
+		t.Errorf("[TestEncodeDecode] wrong meta data: %+v", decoded)
+	}
+	if decoded.Timestamp().Unix() != 1600000000 || decoded.Timestamp().Nanosecond() != 123456000 {
+		t.Errorf("[TestEncodeDecode] wrong timestamp: %s", decoded.Timestamp())
+	}
+	if !bytes.Equal(decoded.Payload, packet.Payload) {
+		t.Errorf("[TestEncodeDecode] wrong payload: %q", decoded.Payload)
+	}
+}
+
+func TestDecodeIPv6(t *testing.T) {
+
+	packet := &Packet{
+		Version:   6,
+		Protocol:  6,
+		SrcIP:     net.ParseIP("2001:db8::1"),
+		DstIP:     net.ParseIP("2001:db8::2"),
+		ProtoType: PayloadLog,
+		Payload:   []byte("log line"),
+	}
+
+	data, err := Encode(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != 6 || !decoded.SrcIP.Equal(packet.SrcIP) || decoded.ProtoType != PayloadLog {
+		t.Errorf("[TestDecodeIPv6] wrong packet: %+v", decoded)
+	}
+}
+
+func TestDecodeBroken(t *testing.T) {
+
+	data, err := Encode(&Packet{SrcIP: net.ParseIP("10.0.0.1"), DstIP: net.ParseIP("10.0.0.2"), Payload: []byte("x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Decode([]byte("HEP2abcdef")); err != ErrNotHEP3 {
+		t.Errorf("[TestDecodeBroken] expected ErrNotHEP3, got %v", err)
+	}
+	if _, err := Decode(data[:len(data)-1]); err != ErrTruncated {
+		t.Errorf("[TestDecodeBroken] expected ErrTruncated, got %v", err)
+	}
+
+	/* chunk length pointing behind the frame */
+	broken := append([]byte{}, data...)
+	broken[HeaderLength+5] = 0xff
+	if _, err := Decode(broken); err == nil {
+		t.Errorf("[TestDecodeBroken] expected an error for a bad chunk length")
+	}
+}
@@ -0,0 +1,382 @@
+package hepcollector
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/hep"
+	"github.com/sipcapture/homer-app/utils/importreader"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+// Options of the listeners, an empty address disables the transport
+type Options struct {
+	UDPAddress string
+	TCPAddress string
+	TLSAddress string
+	TLSConfig  *tls.Config
+	// frames waiting for the writer, UDP frames are dropped when it is full
+	QueueSize int
+	// TCP/TLS readers wait that long for room in the queue before dropping
+	BlockTimeout time.Duration
+	// close idle TCP/TLS connections, 0 keeps them forever
+	IdleTimeout time.Duration
+	// frames with another auth key are dropped, empty accepts all
+	AuthKey string
+}
+
+// Stats are the counters of the collector
+// swagger:model HepCollectorStats
+type Stats struct {
+	// frames read from the network
+	Received uint64 `json:"received"`
+	// frames which were not HEPv3 or had no payload
+	DecodeErrors uint64 `json:"decode_errors"`
+	// frames with a wrong auth key
+	AuthErrors uint64 `json:"auth_errors"`
+	// frames lost because the queue was full
+	Dropped uint64 `json:"dropped"`
+	// TCP/TLS readers which had to wait for the writer
+	Blocked uint64 `json:"blocked"`
+	// rows written by the writer
+	Inserted uint64 `json:"inserted"`
+	// rows the writer couldn't build or insert
+	InsertErrors uint64 `json:"insert_errors"`
+	// batches written by the writer
+	Batches uint64 `json:"batches"`
+	// open TCP/TLS connections
+	Connections int64 `json:"connections"`
+	QueueLength int   `json:"queue_length"`
+	QueueSize   int   `json:"queue_size"`
+}
+
+// Collector receives HEPv3 on UDP, TCP and TLS and queues the packets for a writer
+type Collector struct {
+	options Options
+	queue   chan *importreader.Packet
+
+	received     uint64
+	decodeErrors uint64
+	authErrors   uint64
+	dropped      uint64
+	blocked      uint64
+	inserted     uint64
+	insertErrors uint64
+	batches      uint64
+	connections  int64
+
+	mutex     sync.Mutex
+	listeners []io.Closer
+	conns     map[net.Conn]struct{}
+	quit      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// New returns a collector, Start opens the listeners
+func New(options Options) *Collector {
+
+	if options.QueueSize <= 0 {
+		options.QueueSize = 10000
+	}
+
+	return &Collector{
+		options: options,
+		queue:   make(chan *importreader.Packet, options.QueueSize),
+		conns:   map[net.Conn]struct{}{},
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start opens the configured listeners. If one of them fails, the others are closed.
+func (c *Collector) Start() error {
+
+	if c.options.UDPAddress == "" && c.options.TCPAddress == "" && c.options.TLSAddress == "" {
+		return fmt.Errorf("no HEP listener has been configured")
+	}
+
+	if c.options.UDPAddress != "" {
+		conn, err := net.ListenPacket("udp", c.options.UDPAddress)
+		if err != nil {
+			c.Stop()
+			return err
+		}
+		c.addListener(conn)
+		c.wg.Add(1)
+		go c.serveUDP(conn)
+		logger.Info("HEP collector listening on udp ", c.options.UDPAddress)
+	}
+
+	if c.options.TCPAddress != "" {
+		listener, err := net.Listen("tcp", c.options.TCPAddress)
+		if err != nil {
+			c.Stop()
+			return err
+		}
+		c.addListener(listener)
+		c.wg.Add(1)
+		go c.serveStream(listener)
+		logger.Info("HEP collector listening on tcp ", c.options.TCPAddress)
+	}
+
+	if c.options.TLSAddress != "" {
+		if c.options.TLSConfig == nil {
+			c.Stop()
+			return fmt.Errorf("HEP over TLS needs a certificate")
+		}
+		listener, err := tls.Listen("tcp", c.options.TLSAddress, c.options.TLSConfig)
+		if err != nil {
+			c.Stop()
+			return err
+		}
+		c.addListener(listener)
+		c.wg.Add(1)
+		go c.serveStream(listener)
+		logger.Info("HEP collector listening on tls ", c.options.TLSAddress)
+	}
+
+	return nil
+}
+
+// Packets returns the queue the writer reads from. It is never closed, use Done to stop.
+func (c *Collector) Packets() <-chan *importreader.Packet {
+	return c.queue
+}
+
+// Done is closed when the collector stops
+func (c *Collector) Done() <-chan struct{} {
+	return c.quit
+}
+
+// Addr returns the address of the first listener of the network (udp, tcp), for tests
+// and random ports
+func (c *Collector) Addr(network string) net.Addr {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, val := range c.listeners {
+		switch listener := val.(type) {
+		case net.PacketConn:
+			if network == "udp" {
+				return listener.LocalAddr()
+			}
+		case net.Listener:
+			if network == "tcp" {
+				return listener.Addr()
+			}
+		}
+	}
+	return nil
+}
+
+// Stop closes the listeners and connections and waits for the readers
+func (c *Collector) Stop() {
+
+	c.mutex.Lock()
+	select {
+	case <-c.quit:
+		c.mutex.Unlock()
+		return
+	default:
+		close(c.quit)
+	}
+	for _, listener := range c.listeners {
+		listener.Close()
+	}
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.mutex.Unlock()
+
+	c.wg.Wait()
+}
+
+// Inserted is called by the writer after a batch
+func (c *Collector) Inserted(rows, failed int) {
+	atomic.AddUint64(&c.batches, 1)
+	atomic.AddUint64(&c.inserted, uint64(rows))
+	atomic.AddUint64(&c.insertErrors, uint64(failed))
+}
+
+// Stats returns a snapshot of the counters
+func (c *Collector) Stats() Stats {
+	return Stats{
+		Received:     atomic.LoadUint64(&c.received),
+		DecodeErrors: atomic.LoadUint64(&c.decodeErrors),
+		AuthErrors:   atomic.LoadUint64(&c.authErrors),
+		Dropped:      atomic.LoadUint64(&c.dropped),
+		Blocked:      atomic.LoadUint64(&c.blocked),
+		Inserted:     atomic.LoadUint64(&c.inserted),
+		InsertErrors: atomic.LoadUint64(&c.insertErrors),
+		Batches:      atomic.LoadUint64(&c.batches),
+		Connections:  atomic.LoadInt64(&c.connections),
+		QueueLength:  len(c.queue),
+		QueueSize:    cap(c.queue),
+	}
+}
+
+func (c *Collector) addListener(listener io.Closer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *Collector) stopped() bool {
+	select {
+	case <-c.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Collector) serveUDP(conn net.PacketConn) {
+
+	defer c.wg.Done()
+
+	buffer := make([]byte, hep.MaxLength)
+	for {
+		size, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if c.stopped() {
+				return
+			}
+			logger.Error("HEP collector udp read: ", err.Error())
+			continue
+		}
+
+		/* frames share the buffer, the packet has to own its payload */
+		frame := make([]byte, size)
+		copy(frame, buffer[:size])
+		c.handle(frame, false)
+	}
+}
+
+func (c *Collector) serveStream(listener net.Listener) {
+
+	defer c.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if c.stopped() {
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			logger.Error("HEP collector accept: ", err.Error())
+			return
+		}
+
+		c.mutex.Lock()
+		if c.stopped() {
+			c.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		c.conns[conn] = struct{}{}
+		c.wg.Add(1)
+		c.mutex.Unlock()
+
+		go c.serveConn(conn)
+	}
+}
+
+// serveConn reads length prefixed frames, a broken frame closes the connection
+func (c *Collector) serveConn(conn net.Conn) {
+
+	atomic.AddInt64(&c.connections, 1)
+	defer func() {
+		conn.Close()
+		c.mutex.Lock()
+		delete(c.conns, conn)
+		c.mutex.Unlock()
+		atomic.AddInt64(&c.connections, -1)
+		c.wg.Done()
+	}()
+
+	reader := bufio.NewReaderSize(conn, hep.MaxLength)
+	for {
+		if c.options.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(c.options.IdleTimeout))
+		}
+
+		header, err := reader.Peek(hep.HeaderLength)
+		if err != nil {
+			if err != io.EOF && !c.stopped() && !strings.Contains(err.Error(), "closed") {
+				logger.Debug("HEP collector connection ", conn.RemoteAddr().String(), ": ", err.Error())
+			}
+			return
+		}
+
+		size := hep.FrameLength(header)
+		if size < hep.HeaderLength {
+			atomic.AddUint64(&c.decodeErrors, 1)
+			logger.Error("HEP collector: no HEPv3 frame from ", conn.RemoteAddr().String(), ", closing the connection")
+			return
+		}
+
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return
+		}
+
+		c.handle(frame, true)
+	}
+}
+
+// handle decodes the frame and queues it. Streams wait for the writer up to BlockTimeout,
+// which slows the sender down, datagrams are dropped at once.
+func (c *Collector) handle(frame []byte, stream bool) {
+
+	atomic.AddUint64(&c.received, 1)
+
+	decoded, err := hep.Decode(frame)
+	if err != nil {
+		atomic.AddUint64(&c.decodeErrors, 1)
+		return
+	}
+
+	if c.options.AuthKey != "" && decoded.NodePW != c.options.AuthKey {
+		atomic.AddUint64(&c.authErrors, 1)
+		return
+	}
+
+	packet, err := importreader.PacketFromHEP(decoded)
+	if err != nil {
+		/* keepalives and compressed frames */
+		atomic.AddUint64(&c.decodeErrors, 1)
+		return
+	}
+
+	select {
+	case c.queue <- packet:
+		return
+	default:
+	}
+
+	if !stream || c.options.BlockTimeout <= 0 {
+		atomic.AddUint64(&c.dropped, 1)
+		return
+	}
+
+	atomic.AddUint64(&c.blocked, 1)
+	timer := time.NewTimer(c.options.BlockTimeout)
+	defer timer.Stop()
+
+	select {
+	case c.queue <- packet:
+	case <-timer.C:
+		atomic.AddUint64(&c.dropped, 1)
+	case <-c.quit:
+	}
+}
@@ -0,0 +1,122 @@
+package hepcollector
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/hep"
+)
+
+const testPayload = "OPTIONS sip:bob@example.com SIP/2.0\r\n" +
+	"Call-ID: 843817637684230@998sdasdh09\r\n" +
+	"CSeq: 1 OPTIONS\r\n" +
+	"Content-Length: 0\r\n\r\n"
+
+func testFrame(t *testing.T, authKey string) []byte {
+	frame, err := hep.Encode(&hep.Packet{
+		Version:   2,
+		Protocol:  17,
+		SrcIP:     net.IPv4(10, 0, 0, 1),
+		DstIP:     net.IPv4(10, 0, 0, 2),
+		SrcPort:   5060,
+		DstPort:   5060,
+		Tsec:      1600000000,
+		ProtoType: hep.PayloadSIP,
+		NodeID:    2001,
+		NodePW:    authKey,
+		Payload:   []byte(testPayload),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func waitFor(t *testing.T, name string, check func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("[%s] timed out", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCollectorUDP(t *testing.T) {
+
+	c := New(Options{UDPAddress: "127.0.0.1:0", QueueSize: 1, AuthKey: "secret"})
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	conn, err := net.Dial("udp", c.Addr("udp").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	/* the second frame doesn't fit into the queue, the third has a wrong key, the last isn't HEP */
+	conn.Write(testFrame(t, "secret"))
+	conn.Write(testFrame(t, "secret"))
+	conn.Write(testFrame(t, "wrong"))
+	conn.Write([]byte("not hep"))
+
+	waitFor(t, "TestCollectorUDP", func() bool { return c.Stats().Received == 4 })
+
+	stats := c.Stats()
+	if stats.Dropped != 1 || stats.AuthErrors != 1 || stats.DecodeErrors != 1 || stats.QueueLength != 1 {
+		t.Errorf("[TestCollectorUDP] unexpected counters: %+v", stats)
+	}
+
+	packet := <-c.Packets()
+	if packet.CaptureID != "2001" || string(packet.Payload) != testPayload || packet.SrcPort != 5060 {
+		t.Errorf("[TestCollectorUDP] unexpected packet: %+v", packet)
+	}
+}
+
+func TestCollectorTCP(t *testing.T) {
+
+	c := New(Options{TCPAddress: "127.0.0.1:0", QueueSize: 1, BlockTimeout: 2 * time.Second})
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	conn, err := net.Dial("tcp", c.Addr("tcp").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	/* three frames in one write, split in the middle of the second */
+	frame := testFrame(t, "")
+	stream := append(append(append([]byte{}, frame...), frame...), frame...)
+	conn.Write(stream[:len(frame)+10])
+	time.Sleep(20 * time.Millisecond)
+	conn.Write(stream[len(frame)+10:])
+
+	/* the queue holds one, the reader waits for the writer instead of dropping */
+	waitFor(t, "TestCollectorTCP", func() bool { return c.Stats().Blocked == 1 })
+
+	for i := 0; i < 3; i++ {
+		select {
+		case packet := <-c.Packets():
+			if string(packet.Payload) != testPayload {
+				t.Errorf("[TestCollectorTCP] unexpected payload: %q", packet.Payload)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("[TestCollectorTCP] frame %d wasn't received", i)
+		}
+	}
+
+	stats := c.Stats()
+	if stats.Received != 3 || stats.Dropped != 0 || stats.Blocked == 0 || stats.Connections != 1 {
+		t.Errorf("[TestCollectorTCP] unexpected counters: %+v", stats)
+	}
+
+	/* garbage closes the connection */
+	conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+	waitFor(t, "TestCollectorTCP", func() bool { return c.Stats().Connections == 0 })
+}
@@ -0,0 +1,52 @@
+package heprelay
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket: rate tokens per second, at most burst of them saved
+type Limiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewLimiter returns a full bucket, a burst below one is set to the rate
+func NewLimiter(rate, burst int) *Limiter {
+
+	if burst < 1 {
+		burst = rate
+	}
+
+	return &Limiter{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Allow takes a token if there is one
+func (l *Limiter) Allow() bool {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
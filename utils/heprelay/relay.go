@@ -0,0 +1,302 @@
+package heprelay
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/hep"
+	"github.com/sipcapture/homer-app/utils/logger"
+)
+
+var (
+	ErrBadFrame    = errors.New("not a well-formed HEPv3 frame")
+	ErrRateLimited = errors.New("rate limit exceeded")
+	ErrNoUpstream  = errors.New("no upstream accepted the frame")
+)
+
+// DefaultQueueSize is the number of frames waiting for one upstream
+const DefaultQueueSize = 1000
+
+// Stats are the counters of the relay
+// swagger:model HepRelayStats
+type Stats struct {
+	// frames sent to at least one upstream
+	Relayed uint64 `json:"relayed"`
+	// frames which were not HEPv3
+	Rejected uint64 `json:"rejected"`
+	// frames over the rate limit of their connection
+	RateLimited uint64 `json:"rate_limited"`
+	// open WebSocket connections
+	Connections int64           `json:"connections"`
+	Upstreams   []UpstreamStats `json:"upstreams"`
+}
+
+// UpstreamStats are the counters of one collector
+type UpstreamStats struct {
+	// example: udp://127.0.0.1:9060
+	URL    string `json:"url"`
+	Sent   uint64 `json:"sent"`
+	Errors uint64 `json:"errors"`
+	// frames dropped because the queue of the upstream was full
+	Dropped   uint64 `json:"dropped"`
+	Queued    int    `json:"queued"`
+	Connected bool   `json:"connected"`
+}
+
+// Options of the relay
+type Options struct {
+	// udp://host:port, tcp://host:port or tls://host:port
+	Upstreams []string
+	// used by tls upstreams
+	TLSConfig *tls.Config
+	// frames per second and burst of one connection, 0 disables the limit
+	MaxRate int
+	Burst   int
+	// wait between reconnects of TCP/TLS upstreams
+	ReconnectInterval time.Duration
+	// frames waiting for each upstream, DefaultQueueSize if 0
+	QueueSize int
+}
+
+// Relay sends validated HEPv3 frames to all configured collectors. Every upstream has
+// its own queue and writer, a slow or unreachable one doesn't hold the others up.
+type Relay struct {
+	options   Options
+	upstreams []*upstream
+
+	relayed     uint64
+	rejected    uint64
+	rateLimited uint64
+	connections int64
+}
+
+type upstream struct {
+	sync.Mutex
+	url       string
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	conn      net.Conn
+	lastDial  time.Time
+	interval  time.Duration
+	sent      uint64
+	errors    uint64
+	dropped   uint64
+	queue     chan []byte
+	done      chan struct{}
+}
+
+// New checks the upstream URLs and starts their writers, connections are opened on the
+// first frame
+func New(options Options) (*Relay, error) {
+
+	if options.ReconnectInterval <= 0 {
+		options.ReconnectInterval = time.Second
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultQueueSize
+	}
+
+	relay := &Relay{options: options}
+	for _, val := range options.Upstreams {
+		u, err := url.Parse(val)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("bad upstream url: %s", val)
+		}
+
+		target := &upstream{url: val, address: u.Host, interval: options.ReconnectInterval}
+		switch u.Scheme {
+		case "udp", "tcp":
+			target.network = u.Scheme
+		case "tls":
+			target.network = "tcp"
+			target.tlsConfig = options.TLSConfig
+			if target.tlsConfig == nil {
+				target.tlsConfig = &tls.Config{}
+			}
+			if target.tlsConfig.ServerName == "" {
+				target.tlsConfig = target.tlsConfig.Clone()
+				target.tlsConfig.ServerName = u.Hostname()
+			}
+		default:
+			return nil, fmt.Errorf("bad upstream scheme: %s", val)
+		}
+		relay.upstreams = append(relay.upstreams, target)
+	}
+
+	if len(relay.upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream has been configured")
+	}
+
+	for _, target := range relay.upstreams {
+		target.queue = make(chan []byte, options.QueueSize)
+		target.done = make(chan struct{})
+		go target.run()
+	}
+
+	return relay, nil
+}
+
+// Validate accepts exactly one complete HEPv3 frame
+func Validate(frame []byte) error {
+
+	if hep.FrameLength(frame) != len(frame) {
+		return ErrBadFrame
+	}
+	if _, err := hep.Decode(frame); err != nil {
+		return ErrBadFrame
+	}
+	return nil
+}
+
+// NewLimiter returns the rate limit of one connection, nil if there is none
+func (r *Relay) NewLimiter() *Limiter {
+	if r.options.MaxRate <= 0 {
+		return nil
+	}
+	return NewLimiter(r.options.MaxRate, r.options.Burst)
+}
+
+// Connected and Disconnected count the open WebSocket connections
+func (r *Relay) Connected() {
+	atomic.AddInt64(&r.connections, 1)
+}
+
+func (r *Relay) Disconnected() {
+	atomic.AddInt64(&r.connections, -1)
+}
+
+// Relay validates the frame and queues it for every upstream. It fails only if the frame
+// was refused or the queues of all upstreams were full.
+func (r *Relay) Relay(frame []byte, limiter *Limiter) error {
+
+	if err := Validate(frame); err != nil {
+		atomic.AddUint64(&r.rejected, 1)
+		return err
+	}
+
+	if limiter != nil && !limiter.Allow() {
+		atomic.AddUint64(&r.rateLimited, 1)
+		return ErrRateLimited
+	}
+
+	queued := false
+	for _, target := range r.upstreams {
+		select {
+		case target.queue <- frame:
+			queued = true
+		default:
+			atomic.AddUint64(&target.dropped, 1)
+		}
+	}
+
+	if !queued {
+		return ErrNoUpstream
+	}
+
+	atomic.AddUint64(&r.relayed, 1)
+	return nil
+}
+
+// Stats returns a snapshot of the counters
+func (r *Relay) Stats() Stats {
+
+	stats := Stats{
+		Relayed:     atomic.LoadUint64(&r.relayed),
+		Rejected:    atomic.LoadUint64(&r.rejected),
+		RateLimited: atomic.LoadUint64(&r.rateLimited),
+		Connections: atomic.LoadInt64(&r.connections),
+		Upstreams:   []UpstreamStats{},
+	}
+
+	for _, target := range r.upstreams {
+		target.Lock()
+		stats.Upstreams = append(stats.Upstreams, UpstreamStats{
+			URL:       target.url,
+			Sent:      target.sent,
+			Errors:    target.errors,
+			Dropped:   atomic.LoadUint64(&target.dropped),
+			Queued:    len(target.queue),
+			Connected: target.conn != nil,
+		})
+		target.Unlock()
+	}
+
+	return stats
+}
+
+// Close stops the writers and closes the upstream connections, queued frames are dropped
+func (r *Relay) Close() {
+	for _, target := range r.upstreams {
+		close(target.done)
+		target.Lock()
+		if target.conn != nil {
+			target.conn.Close()
+			target.conn = nil
+		}
+		target.Unlock()
+	}
+}
+
+// run writes the queued frames until the relay is closed
+func (u *upstream) run() {
+	for {
+		select {
+		case frame := <-u.queue:
+			if err := u.send(frame); err != nil {
+				logger.Debug("HEP relay ", u.url, ": ", err.Error())
+			}
+		case <-u.done:
+			return
+		}
+	}
+}
+
+// send writes the frame, a broken stream is dropped and dialed again after the interval
+func (u *upstream) send(frame []byte) error {
+
+	u.Lock()
+	defer u.Unlock()
+
+	if u.conn == nil {
+		if time.Since(u.lastDial) < u.interval {
+			u.errors++
+			return fmt.Errorf("waiting to reconnect")
+		}
+		u.lastDial = time.Now()
+
+		var conn net.Conn
+		var err error
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		if u.tlsConfig != nil {
+			conn, err = tls.DialWithDialer(dialer, u.network, u.address, u.tlsConfig)
+		} else {
+			conn, err = dialer.Dial(u.network, u.address)
+		}
+		if err != nil {
+			u.errors++
+			return err
+		}
+		u.conn = conn
+	}
+
+	u.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := u.conn.Write(frame); err != nil {
+		u.errors++
+		/* UDP errors are mostly ICMP replies, the socket stays usable */
+		if u.network != "udp" {
+			u.conn.Close()
+			u.conn = nil
+		}
+		return err
+	}
+
+	u.sent++
+	return nil
+}
@@ -0,0 +1,135 @@
+package heprelay
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/hep"
+)
+
+func testFrame(t *testing.T) []byte {
+	frame, err := hep.Encode(&hep.Packet{
+		Version:   2,
+		Protocol:  17,
+		SrcIP:     net.IPv4(10, 0, 0, 1),
+		DstIP:     net.IPv4(10, 0, 0, 2),
+		SrcPort:   5060,
+		DstPort:   5060,
+		Tsec:      1600000000,
+		ProtoType: hep.PayloadSIP,
+		NodeID:    2001,
+		Payload:   []byte("OPTIONS sip:bob@example.com SIP/2.0\r\n\r\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func TestValidate(t *testing.T) {
+
+	frame := testFrame(t)
+	if err := Validate(frame); err != nil {
+		t.Errorf("[TestValidate] good frame refused: %v", err)
+	}
+
+	bad := map[string][]byte{
+		"garbage":   []byte("hello world"),
+		"truncated": frame[:len(frame)-1],
+		"trailing":  append(append([]byte{}, frame...), 0),
+		"two":       append(append([]byte{}, frame...), frame...),
+	}
+	for name, val := range bad {
+		if err := Validate(val); err != ErrBadFrame {
+			t.Errorf("[TestValidate] %s frame accepted", name)
+		}
+	}
+}
+
+func TestRelayFanOut(t *testing.T) {
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+
+	relay, err := New(Options{Upstreams: []string{"udp://" + udp.LocalAddr().String(), "tcp://" + tcp.Addr().String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	frame := testFrame(t)
+	if err := relay.Relay(frame, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := relay.Relay([]byte("not hep"), nil); err != ErrBadFrame {
+		t.Errorf("[TestRelayFanOut] bad frame relayed: %v", err)
+	}
+
+	buffer := make([]byte, hep.MaxLength)
+	udp.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if size, _, err := udp.ReadFrom(buffer); err != nil || string(buffer[:size]) != string(frame) {
+		t.Errorf("[TestRelayFanOut] udp upstream got %d bytes: %v", size, err)
+	}
+
+	conn, err := tcp.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, buffer[:len(frame)]); err != nil || string(buffer[:len(frame)]) != string(frame) {
+		t.Errorf("[TestRelayFanOut] tcp upstream: %v", err)
+	}
+
+	/* the writers count the frame after it has been written */
+	stats := relay.Stats()
+	for deadline := time.Now().Add(2 * time.Second); stats.Upstreams[1].Sent != 1 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		stats = relay.Stats()
+	}
+	if stats.Relayed != 1 || stats.Rejected != 1 || len(stats.Upstreams) != 2 || stats.Upstreams[1].Sent != 1 {
+		t.Errorf("[TestRelayFanOut] unexpected counters: %+v", stats)
+	}
+}
+
+func TestRelayBadUpstream(t *testing.T) {
+
+	for _, val := range []string{"http://127.0.0.1:9060", "udp://", "127.0.0.1:9060"} {
+		if _, err := New(Options{Upstreams: []string{val}}); err == nil {
+			t.Errorf("[TestRelayBadUpstream] %s accepted", val)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+
+	now := time.Unix(1600000000, 0)
+	limiter := NewLimiter(10, 2)
+	limiter.now = func() time.Time { return now }
+	limiter.last = now
+
+	if !limiter.Allow() || !limiter.Allow() || limiter.Allow() {
+		t.Errorf("[TestLimiter] burst of 2 not applied")
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	if !limiter.Allow() || limiter.Allow() {
+		t.Errorf("[TestLimiter] one token per 100ms expected")
+	}
+
+	now = now.Add(time.Hour)
+	if !limiter.Allow() || !limiter.Allow() || limiter.Allow() {
+		t.Errorf("[TestLimiter] tokens above the burst saved")
+	}
+}
@@ -256,29 +256,6 @@ func ConvertProtoTypeToString(val float64) string {
 	return protoText
 }
 
-/* isup to HEX */
-func IsupToHex(s string) string {
-	p1 := strings.Index(strings.ToLower(s), "content-type: application/isup")
-	if p1 == -1 {
-		return s
-	}
-
-	if p2 := strings.Index(s[p1:], "\r\n\r\n"); p2 > -1 {
-		p2 = p1 + p2 + 4
-		if p3 := strings.Index(s[p2:], "\r\n"); p3 > -1 {
-			p3 = p2 + p3
-			return injectHex(s, p2, p3)
-		} else {
-			return injectHex(s, p2, len(s)-1)
-		}
-	}
-	return s
-}
-
-func injectHex(s string, start, end int) string {
-	return s[:start] + fmt.Sprintf("% X", s[start:end]) + s[end+1:]
-}
-
 /* check if the element exists */
 func ItemExists(arr []string, elem string) bool {
 
@@ -0,0 +1,113 @@
+package importreader
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// the biggest datagram we agree to reassemble
+const maxIPv6Datagram = 65535
+
+type ipv6Piece struct {
+	offset int
+	data   []byte
+}
+
+type ipv6Datagram struct {
+	pieces     []ipv6Piece
+	size       int
+	total      int
+	nextHeader layers.IPProtocol
+	seen       time.Time
+}
+
+// ipv6Defragmenter reassembles IPv6 fragments, gopacket provides only an IPv4 one
+type ipv6Defragmenter struct {
+	datagrams map[string]*ipv6Datagram
+}
+
+func newIPv6Defragmenter() *ipv6Defragmenter {
+	return &ipv6Defragmenter{datagrams: make(map[string]*ipv6Datagram)}
+}
+
+// defrag returns the transport protocol and payload once all fragments have been seen
+func (d *ipv6Defragmenter) defrag(ip *layers.IPv6, fragment *layers.IPv6Fragment, ts time.Time) (layers.IPProtocol, []byte) {
+
+	offset := int(fragment.FragmentOffset) * 8
+
+	/* atomic fragment */
+	if offset == 0 && !fragment.MoreFragments {
+		return fragment.NextHeader, fragment.Payload
+	}
+
+	key := string(ip.SrcIP) + string(ip.DstIP) + string([]byte{
+		byte(fragment.Identification >> 24), byte(fragment.Identification >> 16),
+		byte(fragment.Identification >> 8), byte(fragment.Identification),
+	})
+
+	datagram, ok := d.datagrams[key]
+	if !ok {
+		datagram = &ipv6Datagram{total: -1}
+		d.datagrams[key] = datagram
+	}
+
+	data := make([]byte, len(fragment.Payload))
+	copy(data, fragment.Payload)
+
+	datagram.pieces = append(datagram.pieces, ipv6Piece{offset: offset, data: data})
+	datagram.size += len(data)
+	datagram.seen = ts
+
+	if offset == 0 {
+		datagram.nextHeader = fragment.NextHeader
+	}
+
+	if !fragment.MoreFragments {
+		datagram.total = offset + len(data)
+	}
+
+	if datagram.size > maxIPv6Datagram || datagram.total > maxIPv6Datagram {
+		delete(d.datagrams, key)
+		return 0, nil
+	}
+
+	if datagram.total < 0 || datagram.size < datagram.total {
+		return 0, nil
+	}
+
+	sort.Slice(datagram.pieces, func(i, j int) bool {
+		return datagram.pieces[i].offset < datagram.pieces[j].offset
+	})
+
+	payload := make([]byte, 0, datagram.total)
+	for _, piece := range datagram.pieces {
+		switch {
+		case piece.offset > len(payload):
+			/* hole - wait for the missing piece */
+			return 0, nil
+		case piece.offset+len(piece.data) <= len(payload):
+			/* duplicate */
+			continue
+		}
+		payload = append(payload, piece.data[len(payload)-piece.offset:]...)
+	}
+
+	if len(payload) != datagram.total {
+		return 0, nil
+	}
+
+	delete(d.datagrams, key)
+
+	return datagram.nextHeader, payload
+}
+
+func (d *ipv6Defragmenter) discardOlderThan(t time.Time) {
+
+	for key, datagram := range d.datagrams {
+		if datagram.seen.Before(t) {
+			delete(d.datagrams, key)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package importreader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/ip4defrag"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/google/gopacket/tcpassembly"
+	"github.com/sipcapture/homer-app/utils/hep"
+)
+
+const (
+	magicPcapng = 0x0A0D0D0A
+	// how long fragments and half assembled TCP streams are kept, measured in capture time
+	flushTimeout = 2 * time.Minute
+	// a SIP message without end of headers bigger than this is not SIP
+	maxStreamBuffer = 65535
+)
+
+// Packet is a complete application payload taken from the capture, after
+// IP defragmentation and TCP stream reassembly.
+type Packet struct {
+	Timestamp time.Time
+	Version   uint8
+	Protocol  uint8
+	SrcIP     net.IP
+	DstIP     net.IP
+	SrcPort   uint16
+	DstPort   uint16
+	Payload   []byte
+
+	// set for packets unwrapped from HEP
+	PayloadType   uint8
+	CaptureID     string
+	CorrelationID string
+	NodeName      string
+}
+
+// Reader reads a pcap or pcapng stream and returns the reassembled packets.
+// It is not safe for concurrent use.
+type Reader struct {
+	// Frames is the number of frames read from the capture
+	Frames int
+	// Skipped is the number of frames that carried no IP payload
+	Skipped int
+	// HEP is the number of packets unwrapped from HEP
+	HEP int
+
+	source    gopacket.PacketDataSource
+	ngReader  *pcapgo.NgReader
+	linkType  layers.LinkType
+	ipv4      *ip4defrag.IPv4Defragmenter
+	ipv6      *ipv6Defragmenter
+	assembler *tcpassembly.Assembler
+	queue     []*Packet
+	lastFlush time.Time
+	eof       bool
+}
+
+// NewReader detects the capture format (pcap or pcapng) and prepares the reader.
+func NewReader(r io.Reader) (*Reader, error) {
+
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{
+		ipv4: ip4defrag.NewIPv4Defragmenter(),
+		ipv6: newIPv6Defragmenter(),
+	}
+
+	if binary.LittleEndian.Uint32(magic) == magicPcapng {
+		options := pcapgo.DefaultNgReaderOptions
+		options.WantMixedLinkType = true
+		ngReader, err := pcapgo.NewNgReader(br, options)
+		if err != nil {
+			return nil, err
+		}
+		reader.source = ngReader
+		reader.ngReader = ngReader
+		reader.linkType = ngReader.LinkType()
+	} else {
+		pcapReader, err := pcapgo.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		reader.source = pcapReader
+		reader.linkType = pcapReader.LinkType()
+	}
+
+	reader.assembler = tcpassembly.NewAssembler(tcpassembly.NewStreamPool(&sipStreamFactory{reader: reader}))
+
+	return reader, nil
+}
+
+// Next returns the next reassembled packet or io.EOF when the capture is exhausted.
+func (r *Reader) Next() (*Packet, error) {
+
+	for len(r.queue) == 0 {
+		if r.eof {
+			return nil, io.EOF
+		}
+
+		data, ci, err := r.source.ReadPacketData()
+		if err != nil {
+			/* truncated captures are common - keep what we have got */
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				r.eof = true
+				r.assembler.FlushAll()
+				continue
+			}
+			return nil, err
+		}
+
+		r.Frames++
+		r.decode(data, ci)
+	}
+
+	packet := r.queue[0]
+	r.queue[0] = nil
+	r.queue = r.queue[1:]
+
+	return packet, nil
+}
+
+func (r *Reader) frameLinkType(ci gopacket.CaptureInfo) layers.LinkType {
+
+	if r.ngReader != nil {
+		if iface, err := r.ngReader.Interface(ci.InterfaceIndex); err == nil {
+			return iface.LinkType
+		}
+	}
+
+	return r.linkType
+}
+
+func (r *Reader) decode(data []byte, ci gopacket.CaptureInfo) {
+
+	packet := gopacket.NewPacket(data, r.frameLinkType(ci), gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	ts := ci.Timestamp
+
+	r.expire(ts)
+
+	if layer := packet.Layer(layers.LayerTypeIPv4); layer != nil {
+		ip, err := r.ipv4.DefragIPv4WithTimestamp(layer.(*layers.IPv4), ts)
+		if err != nil || ip == nil {
+			/* broken or incomplete fragment */
+			return
+		}
+
+		template := Packet{
+			Timestamp: ts,
+			Version:   4,
+			Protocol:  uint8(ip.Protocol),
+			SrcIP:     ip.SrcIP,
+			DstIP:     ip.DstIP,
+		}
+
+		r.transport(template, ip.NetworkFlow(), ip.Protocol, ip.Payload)
+		return
+	}
+
+	if layer := packet.Layer(layers.LayerTypeIPv6); layer != nil {
+		ip := layer.(*layers.IPv6)
+		protocol, payload := ipv6Transport(packet, ip)
+
+		if fragment, ok := packet.Layer(layers.LayerTypeIPv6Fragment).(*layers.IPv6Fragment); ok {
+			protocol, payload = r.ipv6.defrag(ip, fragment, ts)
+			if payload == nil {
+				return
+			}
+		}
+
+		template := Packet{
+			Timestamp: ts,
+			Version:   6,
+			Protocol:  uint8(protocol),
+			SrcIP:     ip.SrcIP,
+			DstIP:     ip.DstIP,
+		}
+
+		r.transport(template, ip.NetworkFlow(), protocol, payload)
+		return
+	}
+
+	r.Skipped++
+}
+
+// ipv6Transport skips the extension headers which have been decoded by gopacket
+func ipv6Transport(packet gopacket.Packet, ip *layers.IPv6) (layers.IPProtocol, []byte) {
+
+	protocol, payload := ip.NextHeader, ip.Payload
+
+	for _, layer := range packet.Layers() {
+		switch extension := layer.(type) {
+		case *layers.IPv6HopByHop:
+			protocol, payload = extension.NextHeader, extension.Payload
+		case *layers.IPv6Routing:
+			protocol, payload = extension.NextHeader, extension.Payload
+		case *layers.IPv6Destination:
+			protocol, payload = extension.NextHeader, extension.Payload
+		}
+	}
+
+	return protocol, payload
+}
+
+func (r *Reader) transport(template Packet, netFlow gopacket.Flow, protocol layers.IPProtocol, payload []byte) {
+
+	switch protocol {
+	case layers.IPProtocolUDP:
+		udp := &layers.UDP{}
+		if err := udp.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil {
+			r.Skipped++
+			return
+		}
+		template.SrcPort = uint16(udp.SrcPort)
+		template.DstPort = uint16(udp.DstPort)
+		r.push(template, udp.Payload)
+
+	case layers.IPProtocolTCP:
+		tcp := &layers.TCP{}
+		if err := tcp.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil {
+			r.Skipped++
+			return
+		}
+		r.assembler.AssembleWithTimestamp(netFlow, tcp, template.Timestamp)
+
+	case layers.IPProtocolSCTP:
+		sctp := gopacket.NewPacket(payload, layers.LayerTypeSCTP, gopacket.NoCopy)
+		header, ok := sctp.Layer(layers.LayerTypeSCTP).(*layers.SCTP)
+		if !ok {
+			r.Skipped++
+			return
+		}
+		template.SrcPort = uint16(header.SrcPort)
+		template.DstPort = uint16(header.DstPort)
+		for _, layer := range sctp.Layers() {
+			if chunk, ok := layer.(*layers.SCTPData); ok && len(chunk.Payload) > 0 {
+				r.push(template, chunk.Payload)
+			}
+		}
+
+	default:
+		r.push(template, payload)
+	}
+}
+
+// Counters returns the number of frames read and skipped
+func (r *Reader) Counters() (int, int) {
+	return r.Frames, r.Skipped
+}
+
+func (r *Reader) push(template Packet, payload []byte) {
+
+	if len(payload) == 0 {
+		return
+	}
+
+	/* HEP captured on its way to the collector - keep the original packet */
+	if hep.IsHEP3(payload) {
+		if packet, err := UnwrapHEP(payload); err == nil {
+			r.HEP++
+			r.queue = append(r.queue, packet)
+			return
+		}
+	}
+
+	packet := template
+	packet.Payload = make([]byte, len(payload))
+	copy(packet.Payload, payload)
+	r.queue = append(r.queue, &packet)
+}
+
+// expire drops fragments and flushes TCP streams nobody has touched for a while
+func (r *Reader) expire(ts time.Time) {
+
+	if r.lastFlush.IsZero() {
+		r.lastFlush = ts
+		return
+	}
+
+	if ts.Sub(r.lastFlush) < flushTimeout/4 {
+		return
+	}
+
+	r.lastFlush = ts
+	r.ipv4.DiscardOlderThan(ts.Add(-flushTimeout))
+	r.ipv6.discardOlderThan(ts.Add(-flushTimeout))
+	r.assembler.FlushOlderThan(ts.Add(-flushTimeout))
+}
+
+// sipStreamFactory creates a stream for every TCP connection found in the capture
+type sipStreamFactory struct {
+	reader *Reader
+}
+
+func (f *sipStreamFactory) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+
+	src, dst := netFlow.Endpoints()
+	srcPort, dstPort := tcpFlow.Endpoints()
+
+	template := Packet{
+		Version:  4,
+		Protocol: uint8(layers.IPProtocolTCP),
+		SrcIP:    net.IP(src.Raw()),
+		DstIP:    net.IP(dst.Raw()),
+		SrcPort:  binary.BigEndian.Uint16(srcPort.Raw()),
+		DstPort:  binary.BigEndian.Uint16(dstPort.Raw()),
+	}
+
+	if src.EndpointType() == layers.EndpointIPv6 {
+		template.Version = 6
+	}
+
+	return &sipStream{reader: f.reader, template: template}
+}
+
+// sipStream cuts a reassembled TCP byte stream into SIP messages using Content-Length
+// and into HEP frames using the HEP length
+type sipStream struct {
+	reader   *Reader
+	template Packet
+	buffer   []byte
+	seen     time.Time
+}
+
+func (s *sipStream) Reassembled(reassemblies []tcpassembly.Reassembly) {
+
+	for _, reassembly := range reassemblies {
+		/* we have lost data - whatever is pending can't be completed anymore */
+		if reassembly.Skip > 0 {
+			s.flush()
+		}
+
+		if len(reassembly.Bytes) == 0 {
+			continue
+		}
+
+		if len(s.buffer) == 0 {
+			s.seen = reassembly.Seen
+		}
+
+		s.buffer = append(s.buffer, reassembly.Bytes...)
+		s.split(reassembly.Seen)
+	}
+}
+
+func (s *sipStream) ReassemblyComplete() {
+	s.flush()
+}
+
+func (s *sipStream) split(seen time.Time) {
+
+	for len(s.buffer) > 0 {
+
+		/* CRLF keep-alives */
+		skip := 0
+		for skip < len(s.buffer) && (s.buffer[skip] == '\r' || s.buffer[skip] == '\n') {
+			skip++
+		}
+		if skip > 0 {
+			s.buffer = s.buffer[skip:]
+			s.seen = seen
+			continue
+		}
+
+		size := -1
+		switch {
+		case hep.IsHEP3(s.buffer):
+			if size = hep.FrameLength(s.buffer); size < hep.HeaderLength {
+				s.flush()
+				return
+			}
+		case IsSIP(s.buffer):
+			size = sipMessageSize(s.buffer)
+		case len(s.buffer) < hep.HeaderLength && (bytes.HasPrefix(s.buffer, []byte("HEP3")) || bytes.HasPrefix([]byte("HEP3"), s.buffer)):
+			/* wait for the rest of the HEP header */
+		default:
+			s.flush()
+			return
+		}
+
+		if size < 0 {
+			if len(s.buffer) > maxStreamBuffer {
+				s.flush()
+			}
+			return
+		}
+
+		if size > len(s.buffer) {
+			return
+		}
+
+		s.emit(s.buffer[:size])
+		s.buffer = s.buffer[size:]
+		s.seen = seen
+	}
+}
+
+func (s *sipStream) flush() {
+
+	if len(s.buffer) > 0 {
+		s.emit(s.buffer)
+	}
+	s.buffer = nil
+}
+
+func (s *sipStream) emit(payload []byte) {
+
+	template := s.template
+	template.Timestamp = s.seen
+	s.reader.push(template, payload)
+}
+
+// UnwrapHEP turns a HEPv3 frame back into the packet the agent has captured
+func UnwrapHEP(payload []byte) (*Packet, error) {
+
+	frame, err := hep.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return PacketFromHEP(frame)
+}
+
+// PacketFromHEP converts a decoded HEPv3 frame, frames without a usable payload are refused
+func PacketFromHEP(frame *hep.Packet) (*Packet, error) {
+
+	if frame.Compressed || len(frame.Payload) == 0 {
+		return nil, hep.ErrNoPayload
+	}
+
+	return &Packet{
+		Timestamp:     frame.Timestamp(),
+		Version:       frame.Version,
+		Protocol:      frame.Protocol,
+		SrcIP:         frame.SrcIP,
+		DstIP:         frame.DstIP,
+		SrcPort:       frame.SrcPort,
+		DstPort:       frame.DstPort,
+		Payload:       frame.Payload,
+		PayloadType:   frame.ProtoType,
+		CaptureID:     strconv.FormatUint(uint64(frame.NodeID), 10),
+		CorrelationID: frame.CID,
+		NodeName:      frame.NodeName,
+	}, nil
+}
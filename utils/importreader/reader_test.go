@@ -0,0 +1,350 @@
+package importreader
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/sipcapture/homer-app/utils/hep"
+)
+
+const testInvite = "INVITE sip:bob@example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK776asdhds\r\n" +
+	"Max-Forwards: 70\r\n" +
+	"To: Bob <sip:bob@example.com>\r\n" +
+	"From: Alice <sip:alice@example.org>;tag=1928301774\r\n" +
+	"Call-ID: a84b4c76e66710@pc33.example.org\r\n" +
+	"CSeq: 314159 INVITE\r\n" +
+	"Contact: <sip:alice@10.0.0.1>\r\n" +
+	"User-Agent: TestPhone\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"Content-Length: 5\r\n\r\n" +
+	"v=0\r\n"
+
+const testRegister = "REGISTER sip:example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/TCP 10.0.0.1:5060;branch=z9hG4bKnashds7\r\n" +
+	"To: Bob <sip:bob@example.com>\r\n" +
+	"From: Bob <sip:bob@example.com>;tag=456248\r\n" +
+	"Call-ID: 843817637684230@998sdasdh09\r\n" +
+	"CSeq: 1826 REGISTER\r\n" +
+	"Content-Length: 0\r\n\r\n"
+
+type testCapture struct {
+	buffer bytes.Buffer
+	writer *pcapgo.Writer
+	ts     time.Time
+}
+
+func newTestCapture(t *testing.T) *testCapture {
+	c := &testCapture{ts: time.Unix(1600000000, 0)}
+	c.writer = pcapgo.NewWriter(&c.buffer)
+	if err := c.writer.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (c *testCapture) write(t *testing.T, l ...gopacket.SerializableLayer) {
+	buffer := gopacket.NewSerializeBuffer()
+	options := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buffer, options, l...); err != nil {
+		t.Fatal(err)
+	}
+	c.ts = c.ts.Add(time.Millisecond)
+	data := buffer.Bytes()
+	ci := gopacket.CaptureInfo{Timestamp: c.ts, CaptureLength: len(data), Length: len(data)}
+	if err := c.writer.WritePacket(ci, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ethernet(ethType layers.EthernetType) *layers.Ethernet {
+	return &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		EthernetType: ethType,
+	}
+}
+
+func ipv4(protocol layers.IPProtocol) *layers.IPv4 {
+	return &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: protocol,
+		SrcIP:    net.IPv4(10, 0, 0, 1),
+		DstIP:    net.IPv4(10, 0, 0, 2),
+	}
+}
+
+func readAll(t *testing.T, c *testCapture) []*Packet {
+	reader, err := NewReader(&c.buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []*Packet
+	for {
+		packet, err := reader.Next()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+	}
+}
+
+func TestReaderUDP(t *testing.T) {
+	c := newTestCapture(t)
+	udp := &layers.UDP{SrcPort: 5060, DstPort: 5060}
+	ip := ipv4(layers.IPProtocolUDP)
+	udp.SetNetworkLayerForChecksum(ip)
+	c.write(t, ethernet(layers.EthernetTypeIPv4), ip, udp, gopacket.Payload(testInvite))
+
+	packets := readAll(t, c)
+	if len(packets) != 1 {
+		t.Fatalf("[TestReaderUDP] expected 1 packet, got %d", len(packets))
+	}
+	if string(packets[0].Payload) != testInvite {
+		t.Errorf("[TestReaderUDP] payload mismatch: %q", packets[0].Payload)
+	}
+
+	record, err := BuildRecord(packets[0], Options{CaptureID: "2001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_1_call" {
+		t.Errorf("[TestReaderUDP] expected table hep_proto_1_call, got %s", record.TableName())
+	}
+	if record.Sid != "a84b4c76e66710@pc33.example.org" {
+		t.Errorf("[TestReaderUDP] wrong sid: %s", record.Sid)
+	}
+	for _, field := range []string{`"method":"INVITE"`, `"from_user":"alice"`, `"to_user":"bob"`, `"ruri_user":"bob"`, `"user_agent":"TestPhone"`} {
+		if !strings.Contains(string(record.DataHeader), field) {
+			t.Errorf("[TestReaderUDP] data_header %s doesn't contain %s", record.DataHeader, field)
+		}
+	}
+	for _, field := range []string{`"srcIp":"10.0.0.1"`, `"dstPort":5060`, `"protocol":17`, `"captureId":"2001"`, `"payloadType":1`} {
+		if !strings.Contains(string(record.ProtocolHeader), field) {
+			t.Errorf("[TestReaderUDP] protocol_header %s doesn't contain %s", record.ProtocolHeader, field)
+		}
+	}
+
+	record, err = BuildRecord(packets[0], Options{Profile: "1_default", ImportID: "job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_1_default" {
+		t.Errorf("[TestReaderUDP] profile option was ignored, got %s", record.TableName())
+	}
+	if !strings.Contains(string(record.ProtocolHeader), `"importId":"job-1"`) {
+		t.Errorf("[TestReaderUDP] protocol_header %s has no importId", record.ProtocolHeader)
+	}
+}
+
+func TestReaderIPv4Fragments(t *testing.T) {
+	c := newTestCapture(t)
+
+	/* build the complete datagram and cut it after the UDP header + 64 bytes */
+	buffer := gopacket.NewSerializeBuffer()
+	udp := &layers.UDP{SrcPort: 5060, DstPort: 5060}
+	udp.SetNetworkLayerForChecksum(ipv4(layers.IPProtocolUDP))
+	if err := gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{FixLengths: true}, udp, gopacket.Payload(testInvite)); err != nil {
+		t.Fatal(err)
+	}
+	datagram := buffer.Bytes()
+
+	first := ipv4(layers.IPProtocolUDP)
+	first.Id = 4242
+	first.Flags = layers.IPv4MoreFragments
+	second := ipv4(layers.IPProtocolUDP)
+	second.Id = 4242
+	second.FragOffset = 9
+
+	c.write(t, ethernet(layers.EthernetTypeIPv4), second, gopacket.Payload(datagram[72:]))
+	c.write(t, ethernet(layers.EthernetTypeIPv4), first, gopacket.Payload(datagram[:72]))
+
+	packets := readAll(t, c)
+	if len(packets) != 1 {
+		t.Fatalf("[TestReaderIPv4Fragments] expected 1 packet, got %d", len(packets))
+	}
+	if string(packets[0].Payload) != testInvite {
+		t.Errorf("[TestReaderIPv4Fragments] payload mismatch: %q", packets[0].Payload)
+	}
+}
+
+func TestReaderTCPStream(t *testing.T) {
+	c := newTestCapture(t)
+
+	stream := testInvite + testRegister
+	cut := 100
+	seq := uint32(1000)
+
+	for _, part := range []string{stream[:cut], stream[cut:]} {
+		ip := ipv4(layers.IPProtocolTCP)
+		tcp := &layers.TCP{SrcPort: 5060, DstPort: 5061, Seq: seq, ACK: true, PSH: true, Window: 1024}
+		tcp.SetNetworkLayerForChecksum(ip)
+		c.write(t, ethernet(layers.EthernetTypeIPv4), ip, tcp, gopacket.Payload(part))
+		seq += uint32(len(part))
+	}
+
+	packets := readAll(t, c)
+	if len(packets) != 2 {
+		t.Fatalf("[TestReaderTCPStream] expected 2 packets, got %d", len(packets))
+	}
+	if string(packets[0].Payload) != testInvite || string(packets[1].Payload) != testRegister {
+		t.Errorf("[TestReaderTCPStream] stream was not split on Content-Length")
+	}
+	if packets[1].SrcPort != 5060 || packets[1].DstPort != 5061 || packets[1].Protocol != 6 {
+		t.Errorf("[TestReaderTCPStream] wrong ports or protocol: %+v", packets[1])
+	}
+
+	record, err := BuildRecord(packets[1], Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_1_registration" {
+		t.Errorf("[TestReaderTCPStream] expected table hep_proto_1_registration, got %s", record.TableName())
+	}
+}
+
+func TestReaderFallback(t *testing.T) {
+	c := newTestCapture(t)
+
+	ip := &layers.IPv6{
+		Version:    6,
+		HopLimit:   64,
+		NextHeader: layers.IPProtocolUDP,
+		SrcIP:      net.ParseIP("2001:db8::1"),
+		DstIP:      net.ParseIP("2001:db8::2"),
+	}
+	udp := &layers.UDP{SrcPort: 53, DstPort: 53}
+	udp.SetNetworkLayerForChecksum(ip)
+	c.write(t, ethernet(layers.EthernetTypeIPv6), ip, udp, gopacket.Payload([]byte{0xde, 0xad, 0xbe, 0xef}))
+
+	packets := readAll(t, c)
+	if len(packets) != 1 {
+		t.Fatalf("[TestReaderFallback] expected 1 packet, got %d", len(packets))
+	}
+
+	record, err := BuildRecord(packets[0], Options{FallbackProfile: "100_default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_100_default" {
+		t.Errorf("[TestReaderFallback] expected fallback table, got %s", record.TableName())
+	}
+	if !strings.Contains(record.Raw, `"payload":"deadbeef"`) {
+		t.Errorf("[TestReaderFallback] raw payload should be hex encoded: %s", record.Raw)
+	}
+	if !strings.Contains(string(record.ProtocolHeader), `"protocolFamily":10`) {
+		t.Errorf("[TestReaderFallback] wrong protocol family: %s", record.ProtocolHeader)
+	}
+}
+
+func TestReaderHEP(t *testing.T) {
+	c := newTestCapture(t)
+
+	frames := []*hep.Packet{
+		{
+			Version: 4, Protocol: 17, SrcIP: net.IPv4(172, 16, 0, 1), DstIP: net.IPv4(172, 16, 0, 2),
+			SrcPort: 5060, DstPort: 5060, Tsec: 1500000000, Tmsec: 42,
+			ProtoType: hep.PayloadSIP, NodeID: 2001, Payload: []byte(testInvite),
+		},
+		{
+			Version: 4, Protocol: 17, SrcIP: net.IPv4(172, 16, 0, 1), DstIP: net.IPv4(172, 16, 0, 2),
+			SrcPort: 10001, DstPort: 20001, Tsec: 1500000001,
+			ProtoType: hep.PayloadRTCP, NodeID: 2001, CID: "a84b4c76e66710@pc33.example.org", Payload: []byte(`{"type":200}`),
+		},
+	}
+
+	for _, frame := range frames {
+		data, err := hep.Encode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipv4(layers.IPProtocolUDP)
+		udp := &layers.UDP{SrcPort: 40000, DstPort: 9060}
+		udp.SetNetworkLayerForChecksum(ip)
+		c.write(t, ethernet(layers.EthernetTypeIPv4), ip, udp, gopacket.Payload(data))
+	}
+
+	packets := readAll(t, c)
+	if len(packets) != 2 {
+		t.Fatalf("[TestReaderHEP] expected 2 packets, got %d", len(packets))
+	}
+
+	sip := packets[0]
+	if sip.SrcIP.String() != "172.16.0.1" || sip.DstPort != 5060 || sip.CaptureID != "2001" {
+		t.Errorf("[TestReaderHEP] HEP was not unwrapped: %+v", sip)
+	}
+	if sip.Timestamp.Unix() != 1500000000 || sip.Timestamp.Nanosecond() != 42000 {
+		t.Errorf("[TestReaderHEP] original timestamp was lost: %s", sip.Timestamp)
+	}
+
+	record, err := BuildRecord(sip, Options{CaptureID: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_1_call" || !strings.Contains(string(record.ProtocolHeader), `"captureId":"2001"`) {
+		t.Errorf("[TestReaderHEP] wrong SIP record: %s %s", record.TableName(), record.ProtocolHeader)
+	}
+
+	record, err = BuildRecord(packets[1], Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_5_default" || record.Sid != "a84b4c76e66710@pc33.example.org" || record.Raw != `{"type":200}` {
+		t.Errorf("[TestReaderHEP] wrong RTCP record: %s %s %s", record.TableName(), record.Sid, record.Raw)
+	}
+	if !strings.Contains(string(record.ProtocolHeader), `"payloadType":5`) {
+		t.Errorf("[TestReaderHEP] wrong payload type: %s", record.ProtocolHeader)
+	}
+}
+
+func TestBuildRecordSIPBinary(t *testing.T) {
+	payload := strings.Replace(testInvite, "TestPhone", "Test\x00Phone\xff", 1)
+	packet := &Packet{
+		Timestamp: time.Unix(1500000000, 0), Version: 4, Protocol: 17,
+		SrcIP: net.IPv4(10, 0, 0, 1), DstIP: net.IPv4(10, 0, 0, 2), SrcPort: 5060, DstPort: 5060,
+		PayloadType: PayloadTypeSIP, Payload: []byte(payload),
+	}
+
+	record, err := BuildRecord(packet, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.ValidString(record.Raw) || strings.IndexByte(record.Raw, 0) >= 0 {
+		t.Errorf("[TestBuildRecordSIPBinary] raw isn't storable: %q", record.Raw)
+	}
+	if !strings.Contains(record.Raw, "User-Agent: Test\uFFFDPhone\uFFFD\r\n") || record.Sid != "a84b4c76e66710@pc33.example.org" {
+		t.Errorf("[TestBuildRecordSIPBinary] wrong record: %s %q", record.Sid, record.Raw)
+	}
+}
+
+func TestBuildRecordISUP(t *testing.T) {
+	packet := &Packet{
+		Timestamp: time.Unix(1500000000, 0), Version: 4, Protocol: 132,
+		SrcIP: net.IPv4(172, 16, 0, 1), DstIP: net.IPv4(172, 16, 0, 2),
+		PayloadType: PayloadTypeISUP, CorrelationID: "cic-298",
+		Payload: []byte{0x2a, 0x01, 0x0c, 0x02, 0x00, 0x02, 0x80, 0x90},
+	}
+
+	record, err := BuildRecord(packet, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_54_default" || record.Raw != "2A 01 0C 02 00 02 80 90" {
+		t.Errorf("[TestBuildRecordISUP] wrong ISUP record: %s %q", record.TableName(), record.Raw)
+	}
+	if !strings.Contains(string(record.DataHeader), `"isup_type":"REL","isup_cause":16,"isup_cic":298`) {
+		t.Errorf("[TestBuildRecordISUP] wrong data header: %s", record.DataHeader)
+	}
+}
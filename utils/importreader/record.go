@@ -0,0 +1,397 @@
+package importreader
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipcapture/homer-app/utils/heputils"
+	"github.com/sipcapture/homer-app/utils/isup"
+	"github.com/sipcapture/homer-app/utils/sipparser"
+)
+
+const (
+	// PayloadTypeSIP is the HEP payload type for SIP
+	PayloadTypeSIP = 1
+	// PayloadTypeISUP is the HEP payload type for ISUP with its CIC
+	PayloadTypeISUP = 54
+	// DefaultFallbackProfile is used for everything which is not SIP
+	DefaultFallbackProfile = "200_default"
+)
+
+var sipMethods = [][]byte{
+	[]byte("INVITE "), []byte("ACK "), []byte("BYE "), []byte("CANCEL "), []byte("REGISTER "),
+	[]byte("OPTIONS "), []byte("PRACK "), []byte("SUBSCRIBE "), []byte("NOTIFY "), []byte("PUBLISH "),
+	[]byte("INFO "), []byte("REFER "), []byte("MESSAGE "), []byte("UPDATE "), []byte("SIP/2.0 "),
+}
+
+// Options controls how packets are turned into database records
+type Options struct {
+	// CaptureID is written to protocol_header.captureId
+	CaptureID string
+	// FallbackProfile receives all traffic which is not SIP, i.e. 200_default
+	FallbackProfile string
+	// Profile forces all records into one profile, the SIP/fallback selection is used if empty
+	Profile string
+	// ImportID is written to protocol_header.importId so an import can be removed again
+	ImportID string
+}
+
+// Record is a single row for one of the hep_proto_* tables
+type Record struct {
+	Profile        string
+	Sid            string
+	CreateDate     time.Time
+	ProtocolHeader json.RawMessage
+	DataHeader     json.RawMessage
+	Raw            string
+}
+
+// TableName returns the name of the data table the record belongs to
+func (r *Record) TableName() string {
+	return "hep_proto_" + r.Profile
+}
+
+type protocolHeader struct {
+	ProtocolFamily int    `json:"protocolFamily"`
+	Protocol       int    `json:"protocol"`
+	SrcIP          string `json:"srcIp"`
+	DstIP          string `json:"dstIp"`
+	SrcPort        int    `json:"srcPort"`
+	DstPort        int    `json:"dstPort"`
+	TimeSeconds    int64  `json:"timeSeconds"`
+	TimeUseconds   int64  `json:"timeUseconds"`
+	PayloadType    int    `json:"payloadType"`
+	CaptureID      string `json:"captureId"`
+	CorrelationID  string `json:"correlation_id,omitempty"`
+	ImportID       string `json:"importId,omitempty"`
+}
+
+// the same fields heplify-server writes for SIP
+type sipDataHeader struct {
+	RuriUser    string `json:"ruri_user"`
+	RuriDomain  string `json:"ruri_domain"`
+	FromUser    string `json:"from_user"`
+	FromDomain  string `json:"from_domain"`
+	FromTag     string `json:"from_tag"`
+	ToUser      string `json:"to_user"`
+	ToDomain    string `json:"to_domain"`
+	ToTag       string `json:"to_tag"`
+	PidUser     string `json:"pid_user"`
+	ContactUser string `json:"contact_user"`
+	AuthUser    string `json:"auth_user"`
+	CallID      string `json:"callid"`
+	CallIDAleg  string `json:"callid_aleg"`
+	Method      string `json:"method"`
+	Cseq        string `json:"cseq"`
+	Reason      string `json:"reason"`
+	Diversion   string `json:"diversion"`
+	ViaBranch   string `json:"via_branch"`
+	UserAgent   string `json:"user_agent"`
+	isupDataHeader
+}
+
+// ISUP of SIP-I bodies and HEP type 54, searchable as data_header.isup_*
+type isupDataHeader struct {
+	IsupType    string `json:"isup_type,omitempty"`
+	IsupCalled  string `json:"isup_called,omitempty"`
+	IsupCalling string `json:"isup_calling,omitempty"`
+	IsupCause   int    `json:"isup_cause,omitempty"`
+	IsupCIC     int    `json:"isup_cic,omitempty"`
+}
+
+type eventDataHeader struct {
+	CallID string `json:"callid"`
+	Method string `json:"method"`
+}
+
+type hepDataHeader struct {
+	CallID string `json:"callid"`
+	Node   string `json:"node,omitempty"`
+	isupDataHeader
+}
+
+type eventRaw struct {
+	Encoding string `json:"encoding"`
+	Length   int    `json:"length"`
+	Payload  string `json:"payload"`
+}
+
+// IsSIP checks whether the payload starts with a SIP request or status line
+func IsSIP(payload []byte) bool {
+
+	for _, method := range sipMethods {
+		if bytes.HasPrefix(payload, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// sipMessageSize returns the full size of the first SIP message in the buffer
+// or -1 if its headers are not complete yet
+func sipMessageSize(buffer []byte) int {
+
+	end := bytes.Index(buffer, []byte("\r\n\r\n"))
+	if end == -1 {
+		return -1
+	}
+
+	headers := buffer[:end]
+	length := 0
+
+	for _, line := range bytes.Split(headers, []byte("\r\n")) {
+		colon := bytes.IndexByte(line, ':')
+		if colon == -1 {
+			continue
+		}
+		name := string(bytes.TrimSpace(line[:colon]))
+		if name == "l" || name == "L" || bytes.EqualFold([]byte(name), []byte("Content-Length")) {
+			if value, err := strconv.Atoi(string(bytes.TrimSpace(line[colon+1:]))); err == nil && value > 0 {
+				length = value
+			}
+			break
+		}
+	}
+
+	return end + 4 + length
+}
+
+// BuildRecord turns a reassembled packet into a row. SIP goes to the 1_call, 1_registration
+// or 1_default profile, other HEP payloads to <payload type>_default and everything else to
+// the fallback profile, unless Options.Profile is set.
+func BuildRecord(packet *Packet, options Options) (*Record, error) {
+
+	var record *Record
+	var err error
+
+	switch {
+	case packet.PayloadType > PayloadTypeSIP:
+		record, err = buildHEPRecord(packet, options)
+	case IsSIP(packet.Payload):
+		record, err = buildSIPRecord(packet, options)
+	}
+
+	if record == nil || err != nil {
+		if record, err = buildEventRecord(packet, options); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Profile != "" {
+		record.Profile = options.Profile
+	}
+
+	return record, nil
+}
+
+func buildProtocolHeader(packet *Packet, payloadType int, options Options) protocolHeader {
+
+	family := 2
+	if packet.Version == 6 {
+		family = 10
+	}
+
+	captureID := options.CaptureID
+	if packet.CaptureID != "" {
+		captureID = packet.CaptureID
+	}
+
+	return protocolHeader{
+		ProtocolFamily: family,
+		Protocol:       int(packet.Protocol),
+		SrcIP:          packet.SrcIP.String(),
+		DstIP:          packet.DstIP.String(),
+		SrcPort:        int(packet.SrcPort),
+		DstPort:        int(packet.DstPort),
+		TimeSeconds:    packet.Timestamp.Unix(),
+		TimeUseconds:   int64(packet.Timestamp.Nanosecond() / 1000),
+		PayloadType:    payloadType,
+		CaptureID:      captureID,
+		CorrelationID:  packet.CorrelationID,
+		ImportID:       options.ImportID,
+	}
+}
+
+func buildSIPRecord(packet *Packet, options Options) (*Record, error) {
+
+	raw := sipText(packet.Payload)
+	sip := sipparser.ParseMsg(raw, nil, nil)
+	if sip.Error != nil {
+		return nil, sip.Error
+	}
+	if sip.CallID == "" {
+		return nil, fmt.Errorf("no Call-ID in SIP message")
+	}
+
+	dataHeader := sipDataHeader{
+		RuriUser:    sip.URIUser,
+		RuriDomain:  sip.URIHost,
+		FromUser:    sip.FromUser,
+		FromDomain:  sip.FromHost,
+		FromTag:     sip.FromTag,
+		ToUser:      sip.ToUser,
+		ToDomain:    sip.ToHost,
+		ToTag:       sip.ToTag,
+		PidUser:     sip.PaiUser,
+		ContactUser: sip.ContactUser,
+		AuthUser:    sip.AuthUser,
+		CallID:      sip.CallID,
+		CallIDAleg:  sip.XCallID,
+		Method:      sip.FirstMethod,
+		Cseq:        sip.CseqVal,
+		Reason:      sip.ReasonVal,
+		Diversion:   sip.DiversionVal,
+		ViaBranch:   sip.ViaOneBranch,
+		UserAgent:   sip.UserAgent,
+	}
+
+	if dataHeader.Method == "" {
+		dataHeader.Method = sip.FirstResp
+	}
+	if dataHeader.UserAgent == "" {
+		dataHeader.UserAgent = sip.Server
+	}
+	if sip.Isup != nil {
+		dataHeader.isupDataHeader = newIsupDataHeader(sip.Isup)
+	}
+
+	protocolData, err := json.Marshal(buildProtocolHeader(packet, PayloadTypeSIP, options))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(dataHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Profile:        SIPProfile(sip.CseqMethod),
+		Sid:            sip.CallID,
+		CreateDate:     packet.Timestamp,
+		ProtocolHeader: protocolData,
+		DataHeader:     data,
+		Raw:            raw,
+	}, nil
+}
+
+// sipText makes the SIP payload storable in the text column raw: postgres refuses NUL
+// bytes and invalid UTF-8, both are replaced by U+FFFD
+func sipText(payload []byte) string {
+
+	raw := string(payload)
+	if utf8.ValidString(raw) && strings.IndexByte(raw, 0) < 0 {
+		return raw
+	}
+	return strings.Replace(strings.ToValidUTF8(raw, "\uFFFD"), "\x00", "\uFFFD", -1)
+}
+
+// SIPProfile returns the profile heplify-server would store a SIP message with the CSeq method in
+func SIPProfile(cseqMethod string) string {
+
+	switch cseqMethod {
+	case "REGISTER":
+		return "1_registration"
+	case "INVITE", "ACK", "BYE", "CANCEL", "UPDATE", "PRACK", "REFER", "INFO":
+		return "1_call"
+	default:
+		return "1_default"
+	}
+}
+
+// buildHEPRecord stores non SIP HEP payloads (RTCP, logs, ...) the way heplify-server does
+func buildHEPRecord(packet *Packet, options Options) (*Record, error) {
+
+	if packet.CorrelationID == "" {
+		return nil, fmt.Errorf("no correlation id for payload type %d", packet.PayloadType)
+	}
+
+	protocolData, err := json.Marshal(buildProtocolHeader(packet, int(packet.PayloadType), options))
+	if err != nil {
+		return nil, err
+	}
+
+	dataHeader := hepDataHeader{CallID: packet.CorrelationID, Node: packet.NodeName}
+	raw := string(packet.Payload)
+
+	/* binary ISUP is kept as a hex dump, the text column can't hold it */
+	if packet.PayloadType == PayloadTypeISUP && !json.Valid(packet.Payload) {
+		if m, _ := isup.DecodeWithCIC(packet.Payload); m != nil {
+			dataHeader.isupDataHeader = newIsupDataHeader(m)
+		}
+		raw = isup.HexDump(packet.Payload)
+	}
+
+	data, err := json.Marshal(dataHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Profile:        fmt.Sprintf("%d_default", packet.PayloadType),
+		Sid:            packet.CorrelationID,
+		CreateDate:     packet.Timestamp,
+		ProtocolHeader: protocolData,
+		DataHeader:     data,
+		Raw:            raw,
+	}, nil
+}
+
+func newIsupDataHeader(m *isup.Message) isupDataHeader {
+	return isupDataHeader{
+		IsupType:    m.Name,
+		IsupCalled:  m.CalledDigits(),
+		IsupCalling: m.CallingDigits(),
+		IsupCause:   m.CauseValue(),
+		IsupCIC:     m.CIC,
+	}
+}
+
+func buildEventRecord(packet *Packet, options Options) (*Record, error) {
+
+	hashIPPort := fmt.Sprintf("%s:%d->%s:%d", packet.SrcIP.String(), packet.SrcPort, packet.DstIP.String(), packet.DstPort)
+	sid := strconv.FormatUint(uint64(heputils.Hash32(hashIPPort)), 10)
+
+	profile := options.FallbackProfile
+	if profile == "" {
+		profile = DefaultFallbackProfile
+	}
+
+	raw := eventRaw{Encoding: "text", Length: len(packet.Payload)}
+	if utf8.Valid(packet.Payload) {
+		raw.Payload = string(packet.Payload)
+	} else {
+		raw.Encoding = "hex"
+		raw.Payload = hex.EncodeToString(packet.Payload)
+	}
+
+	protocolData, err := json.Marshal(buildProtocolHeader(packet, int(packet.PayloadType), options))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(eventDataHeader{CallID: sid, Method: "event"})
+	if err != nil {
+		return nil, err
+	}
+
+	rawData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Record{
+		Profile:        profile,
+		Sid:            sid,
+		CreateDate:     packet.Timestamp,
+		ProtocolHeader: protocolData,
+		DataHeader:     data,
+		Raw:            string(rawData),
+	}, nil
+}
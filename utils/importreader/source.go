@@ -0,0 +1,70 @@
+package importreader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Source is implemented by the capture and the text log reader
+type Source interface {
+	Next() (*Packet, error)
+	Counters() (int, int)
+}
+
+// IsCapture checks for the pcap and pcapng magic numbers
+func IsCapture(magic []byte) bool {
+
+	if len(magic) < 4 {
+		return false
+	}
+
+	switch binary.BigEndian.Uint32(magic) {
+	case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1, magicPcapng:
+		return true
+	}
+	return false
+}
+
+// Open returns a reader for the format: pcap, one of TextLogFormats, custom for the text log
+// options or auto to detect it.
+func Open(r io.Reader, format string, options TextLogOptions) (Source, error) {
+
+	br := bufio.NewReaderSize(r, detectSize)
+	sample, err := br.Peek(detectSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	if format == "" || format == "auto" {
+		switch {
+		case IsCapture(sample):
+			format = "pcap"
+		case options.AddressRegex != "":
+			format = "custom"
+		default:
+			detected, ok := DetectTextLog(sample)
+			if !ok {
+				return nil, fmt.Errorf("unknown file format")
+			}
+			format = detected
+		}
+	}
+
+	if format == "pcap" {
+		return NewReader(br)
+	}
+
+	if format != "custom" {
+		preset, ok := TextLogFormats[format]
+		if !ok {
+			return nil, fmt.Errorf("unknown text log format: %s", format)
+		}
+		preset.LocalIP, preset.LocalPort = options.LocalIP, options.LocalPort
+		preset.Date, preset.Location = options.Date, options.Location
+		options = preset
+	}
+
+	return NewTextLogReader(br, options)
+}
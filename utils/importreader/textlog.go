@@ -0,0 +1,415 @@
+package importreader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TimestampUnix is the layout for seconds.fraction timestamps
+	TimestampUnix = "unix"
+	// the biggest log line we accept
+	maxLogLine = 1024 * 1024
+	// how much of a file is looked at to detect its format
+	detectSize = 64 * 1024
+)
+
+// TextLogOptions describes how SIP messages, their time and addresses are found in a text log.
+//
+// TimestampRegex sets the time of the following messages with the named group ts. AddressRegex
+// sets the addresses with the named groups src_ip, src_port, dst_ip and dst_port, or peer_ip,
+// peer_port and dir (recv/rcv/in/read/received for incoming messages). The groups proto and ts are
+// optional. Both regexes are matched on every line outside of a SIP message.
+type TextLogOptions struct {
+	TimestampRegex  string
+	TimestampLayout string
+	AddressRegex    string
+	// LocalIP and LocalPort are used for the side of the message the log doesn't mention
+	LocalIP   string
+	LocalPort uint16
+	// Date completes timestamps without a date, today if zero
+	Date time.Time
+	// Location of timestamps without a zone, local time if nil
+	Location *time.Location
+}
+
+// TextLogFormats are the presets for the logs of common SIP servers
+var TextLogFormats = map[string]TextLogOptions{
+	/* sip set debug on */
+	"asterisk": {
+		TimestampRegex:  `^\[(?P<ts>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?)\]`,
+		TimestampLayout: "Jan _2 15:04:05",
+		AddressRegex: `<--- (?:SIP read from |Received SIP (?:request|response) \(\d+ bytes\) from )(?P<proto>[A-Za-z]+):(?P<src_ip>\[[0-9a-fA-F:.]+\]|[0-9.]+):(?P<src_port>\d+) --->` +
+			`|<--- (?:Reliably )?Transmitting (?:\([^)]*\) to |SIP (?:request|response) \(\d+ bytes\) to (?P<proto>[A-Za-z]+):)(?P<dst_ip>\[[0-9a-fA-F:.]+\]|[0-9.]+):(?P<dst_port>\d+) --->`,
+	},
+	/* sofia global siptrace on */
+	"freeswitch": {
+		TimestampLayout: "15:04:05.000000",
+		AddressRegex:    `^(?P<dir>recv|send) \d+ bytes (?:from|to) (?P<proto>[a-z]+)/\[(?P<peer_ip>[^\]]+)\]:(?P<peer_port>\d+) at (?P<ts>\d{2}:\d{2}:\d{2}\.\d+):`,
+	},
+	/* sipdump module */
+	"kamailio": {
+		TimestampRegex:  `^time: (?P<ts>\d+\.\d+)$`,
+		TimestampLayout: TimestampUnix,
+		AddressRegex:    `^(?:proto: (?P<proto>[a-z]+)|srcip: (?P<src_ip>\S+)|srcport: (?P<src_port>\d+)|dstip: (?P<dst_ip>\S+)|dstport: (?P<dst_port>\d+))`,
+	},
+}
+
+// TextLogReader extracts SIP messages from text logs
+type TextLogReader struct {
+	// Lines is the number of lines read
+	Lines int
+	// Skipped is the number of messages which couldn't be completed
+	Skipped int
+
+	scanner   *bufio.Scanner
+	options   TextLogOptions
+	tsRegex   *regexp.Regexp
+	addrRegex *regexp.Regexp
+	localIP   net.IP
+
+	layoutHasDate bool
+
+	ts   time.Time
+	meta map[string]string
+
+	collecting bool
+	inBody     bool
+	indent     string
+	headers    []string
+	body       bytes.Buffer
+	expected   int
+
+	queue []*Packet
+	eof   bool
+}
+
+// NewTextLogReader compiles the regexes of the options
+func NewTextLogReader(r io.Reader, options TextLogOptions) (*TextLogReader, error) {
+
+	if options.AddressRegex == "" {
+		return nil, fmt.Errorf("no address regex for the text log")
+	}
+
+	reader := &TextLogReader{
+		options: options,
+		meta:    map[string]string{},
+	}
+
+	var err error
+	if reader.addrRegex, err = regexp.Compile(options.AddressRegex); err != nil {
+		return nil, fmt.Errorf("bad address regex: %s", err.Error())
+	}
+
+	if options.TimestampRegex != "" {
+		if reader.tsRegex, err = regexp.Compile(options.TimestampRegex); err != nil {
+			return nil, fmt.Errorf("bad timestamp regex: %s", err.Error())
+		}
+	}
+
+	if reader.localIP = net.ParseIP(options.LocalIP); reader.localIP == nil {
+		reader.localIP = net.IPv4(127, 0, 0, 1)
+	}
+
+	if reader.options.Location == nil {
+		reader.options.Location = time.Local
+	}
+
+	/* a layout has a date if the day survives a format and parse round trip */
+	probe := time.Date(0, time.March, 15, 0, 0, 0, 0, time.UTC)
+	if parsed, err := time.Parse(options.TimestampLayout, probe.Format(options.TimestampLayout)); err == nil {
+		reader.layoutHasDate = parsed.Day() == probe.Day()
+	}
+
+	if reader.options.Date.IsZero() {
+		reader.options.Date = time.Now().In(reader.options.Location)
+	}
+
+	reader.scanner = bufio.NewScanner(r)
+	reader.scanner.Buffer(make([]byte, 64*1024), maxLogLine)
+
+	return reader, nil
+}
+
+// Counters returns the number of lines read and messages skipped
+func (r *TextLogReader) Counters() (int, int) {
+	return r.Lines, r.Skipped
+}
+
+// Next returns the next SIP message or io.EOF at the end of the log
+func (r *TextLogReader) Next() (*Packet, error) {
+
+	for len(r.queue) == 0 {
+		if r.eof {
+			return nil, io.EOF
+		}
+
+		if !r.scanner.Scan() {
+			if err := r.scanner.Err(); err != nil {
+				return nil, err
+			}
+			r.eof = true
+			if r.collecting {
+				r.emit()
+			}
+			continue
+		}
+
+		r.Lines++
+		r.line(strings.TrimRight(r.scanner.Text(), "\r"))
+	}
+
+	packet := r.queue[0]
+	r.queue[0] = nil
+	r.queue = r.queue[1:]
+
+	return packet, nil
+}
+
+func (r *TextLogReader) line(text string) {
+
+	if r.collecting && r.collect(text) {
+		return
+	}
+
+	r.match(text)
+
+	trimmed := strings.TrimLeft(text, " \t")
+	if IsSIP([]byte(trimmed)) {
+		r.collecting = true
+		r.inBody = false
+		r.indent = text[:len(text)-len(trimmed)]
+		r.headers = []string{trimmed}
+		r.body.Reset()
+		r.expected = 0
+	}
+}
+
+// collect adds a line to the current message, false means the line doesn't belong to it
+func (r *TextLogReader) collect(text string) bool {
+
+	if r.addrRegex.MatchString(text) {
+		r.emit()
+		return false
+	}
+
+	text = strings.TrimPrefix(text, r.indent)
+
+	if r.inBody {
+		r.body.WriteString(text)
+		r.body.WriteString("\r\n")
+		if r.body.Len() >= r.expected {
+			r.emit()
+		}
+		return true
+	}
+
+	if text == "" {
+		r.inBody = true
+		head := strings.Join(r.headers, "\r\n") + "\r\n\r\n"
+		r.expected = sipMessageSize([]byte(head)) - len(head)
+		if r.expected <= 0 {
+			r.emit()
+		}
+		return true
+	}
+
+	/* header or folded header */
+	if text[0] == ' ' || text[0] == '\t' || isHeaderLine(text) {
+		r.headers = append(r.headers, text)
+		return true
+	}
+
+	/* the log ends the message without an empty line */
+	r.emit()
+	return false
+}
+
+func isHeaderLine(text string) bool {
+
+	colon := strings.IndexByte(text, ':')
+	if colon <= 0 {
+		return false
+	}
+	return !strings.ContainsAny(text[:colon], " \t")
+}
+
+// match updates the time and addresses with the lines between the messages
+func (r *TextLogReader) match(text string) {
+
+	if r.tsRegex != nil {
+		if ts, ok := namedGroups(r.tsRegex, text)["ts"]; ok {
+			if parsed, err := r.parseTime(ts); err == nil {
+				r.ts = parsed
+			}
+		}
+	}
+
+	for name, value := range namedGroups(r.addrRegex, text) {
+		r.meta[name] = value
+	}
+}
+
+func namedGroups(re *regexp.Regexp, text string) map[string]string {
+
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		return nil
+	}
+
+	groups := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		if name != "" && match[i] != "" {
+			groups[name] = match[i]
+		}
+	}
+	return groups
+}
+
+func (r *TextLogReader) parseTime(value string) (time.Time, error) {
+
+	if r.options.TimestampLayout == TimestampUnix {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(seconds*1e9)), nil
+	}
+
+	ts, err := time.ParseInLocation(r.options.TimestampLayout, value, r.options.Location)
+	if err != nil {
+		return ts, err
+	}
+
+	/* layouts without the date or the year */
+	if ts.Year() == 0 {
+		date := r.options.Date
+		month, day := date.Month(), date.Day()
+		if r.layoutHasDate {
+			month, day = ts.Month(), ts.Day()
+		}
+		ts = time.Date(date.Year(), month, day, ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), r.options.Location)
+	}
+
+	return ts, nil
+}
+
+func (r *TextLogReader) emit() {
+
+	defer func() {
+		r.collecting = false
+		r.headers = nil
+		r.body.Reset()
+		r.meta = map[string]string{}
+	}()
+
+	payload := strings.Join(r.headers, "\r\n") + "\r\n\r\n"
+	body := r.body.Bytes()
+	if len(body) > r.expected {
+		body = body[:r.expected]
+	}
+	payload += string(body)
+
+	if len(r.headers) < 2 {
+		r.Skipped++
+		return
+	}
+
+	packet := &Packet{
+		Timestamp: r.ts,
+		Protocol:  protocolNumber(r.meta["proto"]),
+		SrcIP:     r.localIP,
+		DstIP:     r.localIP,
+		SrcPort:   r.options.LocalPort,
+		DstPort:   r.options.LocalPort,
+		Payload:   []byte(payload),
+	}
+
+	if ts, ok := r.meta["ts"]; ok {
+		if parsed, err := r.parseTime(ts); err == nil {
+			packet.Timestamp = parsed
+		}
+	}
+	if packet.Timestamp.IsZero() {
+		packet.Timestamp = time.Now()
+	}
+
+	if peer, ok := r.meta["peer_ip"]; ok {
+		switch strings.ToLower(r.meta["dir"]) {
+		case "recv", "rcv", "in", "read", "received":
+			r.meta["src_ip"], r.meta["src_port"] = peer, r.meta["peer_port"]
+		default:
+			r.meta["dst_ip"], r.meta["dst_port"] = peer, r.meta["peer_port"]
+		}
+	}
+
+	if ip := parseLogIP(r.meta["src_ip"]); ip != nil {
+		packet.SrcIP = ip
+	}
+	if ip := parseLogIP(r.meta["dst_ip"]); ip != nil {
+		packet.DstIP = ip
+	}
+	if port, err := strconv.ParseUint(r.meta["src_port"], 10, 16); err == nil {
+		packet.SrcPort = uint16(port)
+	}
+	if port, err := strconv.ParseUint(r.meta["dst_port"], 10, 16); err == nil {
+		packet.DstPort = uint16(port)
+	}
+
+	packet.Version = 4
+	if packet.SrcIP.To4() == nil || packet.DstIP.To4() == nil {
+		packet.Version = 6
+	}
+
+	r.queue = append(r.queue, packet)
+}
+
+func parseLogIP(value string) net.IP {
+	return net.ParseIP(strings.Trim(value, "[]"))
+}
+
+func protocolNumber(proto string) uint8 {
+
+	switch strings.ToLower(proto) {
+	case "tcp", "tls", "ws", "wss":
+		return 6
+	case "sctp":
+		return 132
+	default:
+		return 17
+	}
+}
+
+// DetectTextLog returns the preset whose address regex matches the sample
+func DetectTextLog(sample []byte) (string, bool) {
+
+	names := make([]string, 0, len(TextLogFormats))
+	regexes := map[string]*regexp.Regexp{}
+	for name, format := range TextLogFormats {
+		re, err := regexp.Compile(format.AddressRegex)
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+		regexes[name] = re
+	}
+	sort.Strings(names)
+
+	for _, line := range strings.Split(string(sample), "\n") {
+		line = strings.TrimRight(line, "\r")
+		for _, name := range names {
+			if regexes[name].MatchString(line) {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
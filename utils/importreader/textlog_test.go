@@ -0,0 +1,169 @@
+package importreader
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readLog(t *testing.T, log, format string) []*Packet {
+
+	options := TextLogOptions{
+		LocalIP:   "192.168.0.10",
+		LocalPort: 5060,
+		Date:      time.Date(2020, time.September, 13, 0, 0, 0, 0, time.UTC),
+		Location:  time.UTC,
+	}
+
+	source, err := Open(strings.NewReader(log), format, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets []*Packet
+	for {
+		packet, err := source.Next()
+		if err == io.EOF {
+			return packets
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+	}
+}
+
+func TestTextLogAsterisk(t *testing.T) {
+
+	log := "[Sep 13 12:26:40] VERBOSE[1234] chan_sip.c: \n" +
+		"<--- SIP read from UDP:10.0.0.1:5062 --->\n" +
+		strings.Replace(testInvite, "\r\n", "\n", -1) + "\n" +
+		"<------------->\n" +
+		"[Sep 13 12:26:41] VERBOSE[1234] chan_sip.c: \n" +
+		"<--- Transmitting (no NAT) to 10.0.0.1:5062 --->\n" +
+		"SIP/2.0 100 Trying\n" +
+		"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK776asdhds\n" +
+		"From: Alice <sip:alice@example.org>;tag=1928301774\n" +
+		"To: Bob <sip:bob@example.com>\n" +
+		"Call-ID: a84b4c76e66710@pc33.example.org\n" +
+		"CSeq: 314159 INVITE\n" +
+		"Content-Length: 0\n" +
+		"\n" +
+		"<------------>\n"
+
+	packets := readLog(t, log, "auto")
+	if len(packets) != 2 {
+		t.Fatalf("[TestTextLogAsterisk] expected 2 messages, got %d", len(packets))
+	}
+
+	if string(packets[0].Payload) != testInvite {
+		t.Errorf("[TestTextLogAsterisk] payload mismatch: %q", packets[0].Payload)
+	}
+	if packets[0].SrcIP.String() != "10.0.0.1" || packets[0].SrcPort != 5062 || packets[0].DstIP.String() != "192.168.0.10" {
+		t.Errorf("[TestTextLogAsterisk] wrong addresses of the request: %+v", packets[0])
+	}
+	if !packets[0].Timestamp.Equal(time.Date(2020, time.September, 13, 12, 26, 40, 0, time.UTC)) {
+		t.Errorf("[TestTextLogAsterisk] wrong timestamp: %s", packets[0].Timestamp)
+	}
+	if packets[1].DstIP.String() != "10.0.0.1" || packets[1].SrcIP.String() != "192.168.0.10" || packets[1].SrcPort != 5060 {
+		t.Errorf("[TestTextLogAsterisk] wrong addresses of the reply: %+v", packets[1])
+	}
+
+	record, err := BuildRecord(packets[1], Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.TableName() != "hep_proto_1_call" || record.Sid != "a84b4c76e66710@pc33.example.org" {
+		t.Errorf("[TestTextLogAsterisk] wrong record: %s %s", record.TableName(), record.Sid)
+	}
+}
+
+func TestTextLogFreeSWITCH(t *testing.T) {
+
+	indented := "   " + strings.Replace(strings.TrimSuffix(testRegister, "\r\n"), "\r\n", "\n   ", -1) + "\n"
+
+	log := "recv 300 bytes from tcp/[10.0.0.1]:41234 at 10:00:01.250000:\n" +
+		"   ------------------------------------------------------------------------\n" +
+		indented +
+		"   ------------------------------------------------------------------------\n"
+
+	packets := readLog(t, log, "auto")
+	if len(packets) != 1 {
+		t.Fatalf("[TestTextLogFreeSWITCH] expected 1 message, got %d", len(packets))
+	}
+
+	packet := packets[0]
+	if string(packet.Payload) != testRegister {
+		t.Errorf("[TestTextLogFreeSWITCH] payload mismatch: %q", packet.Payload)
+	}
+	if packet.SrcIP.String() != "10.0.0.1" || packet.SrcPort != 41234 || packet.Protocol != 6 {
+		t.Errorf("[TestTextLogFreeSWITCH] wrong addresses: %+v", packet)
+	}
+	if !packet.Timestamp.Equal(time.Date(2020, time.September, 13, 10, 0, 1, 250000000, time.UTC)) {
+		t.Errorf("[TestTextLogFreeSWITCH] wrong timestamp: %s", packet.Timestamp)
+	}
+}
+
+func TestTextLogKamailio(t *testing.T) {
+
+	log := "====================\n" +
+		"tag: rcv\n" +
+		"pid: 1234\n" +
+		"time: 1600000000.500000\n" +
+		"proto: udp ipv4\n" +
+		"srcip: 10.0.0.1\n" +
+		"srcport: 5060\n" +
+		"dstip: 10.0.0.2\n" +
+		"dstport: 5080\n" +
+		"~~~~~~~~~~~~~~~~~~~~\n" +
+		testInvite +
+		"||||||||||||||||||||\n"
+
+	packets := readLog(t, log, "kamailio")
+	if len(packets) != 1 {
+		t.Fatalf("[TestTextLogKamailio] expected 1 message, got %d", len(packets))
+	}
+
+	packet := packets[0]
+	if string(packet.Payload) != testInvite {
+		t.Errorf("[TestTextLogKamailio] payload mismatch: %q", packet.Payload)
+	}
+	if packet.SrcIP.String() != "10.0.0.1" || packet.DstIP.String() != "10.0.0.2" || packet.DstPort != 5080 {
+		t.Errorf("[TestTextLogKamailio] wrong addresses: %+v", packet)
+	}
+	if packet.Timestamp.Unix() != 1600000000 {
+		t.Errorf("[TestTextLogKamailio] wrong timestamp: %s", packet.Timestamp)
+	}
+}
+
+func TestTextLogCustom(t *testing.T) {
+
+	options := TextLogOptions{
+		TimestampRegex:  `^(?P<ts>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})`,
+		TimestampLayout: "2006-01-02 15:04:05",
+		AddressRegex:    `from (?P<src_ip>[0-9.]+):(?P<src_port>\d+) to (?P<dst_ip>[0-9.]+):(?P<dst_port>\d+)`,
+		Location:        time.UTC,
+	}
+
+	log := "2021-01-02 03:04:05 message from 10.1.1.1:5060 to 10.2.2.2:5070\n" + testRegister
+
+	source, err := Open(strings.NewReader(log), "auto", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := source.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet.DstIP.String() != "10.2.2.2" || packet.DstPort != 5070 {
+		t.Errorf("[TestTextLogCustom] wrong addresses: %+v", packet)
+	}
+	if !packet.Timestamp.Equal(time.Date(2021, time.January, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("[TestTextLogCustom] wrong timestamp: %s", packet.Timestamp)
+	}
+	if _, err := source.Next(); err != io.EOF {
+		t.Errorf("[TestTextLogCustom] expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+// Package isup decodes ITU-T Q.763 ISUP messages as found in SIP-I/SIP-T
+// bodies (RFC 3204) and in HEP payload type 54.
+package isup
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// message types
+const (
+	MessageIAM = 0x01
+	MessageACM = 0x06
+	MessageANM = 0x09
+	MessageREL = 0x0c
+	MessageRLC = 0x10
+	MessageCPG = 0x2c
+	MessageCON = 0x07
+)
+
+// parameter codes
+const (
+	ParamEndOfOptional        = 0x00
+	ParamCalledPartyNumber    = 0x04
+	ParamNatureOfConnection   = 0x06
+	ParamForwardCall          = 0x07
+	ParamCallingPartyCategory = 0x09
+	ParamCallingPartyNumber   = 0x0a
+	ParamRedirectingNumber    = 0x0b
+	ParamRedirectionNumber    = 0x0c
+	ParamBackwardCall         = 0x11
+	ParamCauseIndicators      = 0x12
+	ParamRedirectionInfo      = 0x13
+	ParamEventInformation     = 0x24
+	ParamTransmissionMedium   = 0x02
+	ParamOriginalCalledNumber = 0x28
+	ParamGenericNumber        = 0xc0
+)
+
+var (
+	ErrTooShort = errors.New("isup: message too short")
+	ErrPointer  = errors.New("isup: pointer outside of the message")
+)
+
+// Message is a decoded ISUP message. Parameters holds every parameter with its
+// raw value, the known ones are decoded into the fields as well.
+type Message struct {
+	CIC                    int          `json:"cic,omitempty"`
+	Type                   int          `json:"message_type"`
+	Name                   string       `json:"message_name"`
+	CalledPartyNumber      *Number      `json:"called_party_number,omitempty"`
+	CallingPartyNumber     *Number      `json:"calling_party_number,omitempty"`
+	CallingPartyCategory   *int         `json:"calling_party_category,omitempty"`
+	RedirectingNumber      *Number      `json:"redirecting_number,omitempty"`
+	OriginalCalledNumber   *Number      `json:"original_called_number,omitempty"`
+	RedirectionNumber      *Number      `json:"redirection_number,omitempty"`
+	RedirectionInformation *Redirection `json:"redirection_information,omitempty"`
+	Cause                  *Cause       `json:"cause,omitempty"`
+	Parameters             []*Parameter `json:"parameters"`
+}
+
+// Parameter is one parameter as received
+type Parameter struct {
+	Code  int    `json:"code"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// layout of the mandatory part of a message type
+type layout struct {
+	name     string
+	fixed    []fixedParam
+	variable []int
+	optional bool
+}
+
+type fixedParam struct {
+	code   int
+	length int
+}
+
+var layouts = map[int]layout{
+	MessageIAM: {
+		name: "IAM",
+		fixed: []fixedParam{
+			{ParamNatureOfConnection, 1},
+			{ParamForwardCall, 2},
+			{ParamCallingPartyCategory, 1},
+			{ParamTransmissionMedium, 1},
+		},
+		variable: []int{ParamCalledPartyNumber},
+		optional: true,
+	},
+	MessageACM: {name: "ACM", fixed: []fixedParam{{ParamBackwardCall, 2}}, optional: true},
+	MessageCON: {name: "CON", fixed: []fixedParam{{ParamBackwardCall, 2}}, optional: true},
+	MessageANM: {name: "ANM", optional: true},
+	MessageCPG: {name: "CPG", fixed: []fixedParam{{ParamEventInformation, 1}}, optional: true},
+	MessageREL: {name: "REL", variable: []int{ParamCauseIndicators}, optional: true},
+	MessageRLC: {name: "RLC", optional: true},
+}
+
+// Decode decodes a message starting with the message type, as in an
+// application/isup body
+func Decode(data []byte) (*Message, error) {
+
+	if len(data) < 1 {
+		return nil, ErrTooShort
+	}
+
+	m := &Message{Type: int(data[0]), Parameters: []*Parameter{}}
+	l, ok := layouts[m.Type]
+	if !ok {
+		m.Name = fmt.Sprintf("0x%02x", m.Type)
+		return m, nil
+	}
+	m.Name = l.name
+
+	pos := 1
+	for _, p := range l.fixed {
+		if pos+p.length > len(data) {
+			return m, ErrTooShort
+		}
+		m.add(p.code, data[pos:pos+p.length])
+		pos += p.length
+	}
+
+	/* pointers are relative to their own position and point at a length */
+	for _, code := range l.variable {
+		value, err := pointed(data, pos)
+		if err != nil {
+			return m, err
+		}
+		m.add(code, value[1:])
+		pos++
+	}
+
+	if !l.optional {
+		return m, nil
+	}
+	if pos >= len(data) {
+		return m, ErrTooShort
+	}
+	if data[pos] == 0 {
+		return m, nil
+	}
+
+	start := pos + int(data[pos])
+	for start < len(data) {
+		code := int(data[start])
+		if code == ParamEndOfOptional {
+			return m, nil
+		}
+		if start+1 >= len(data) || start+2+int(data[start+1]) > len(data) {
+			return m, ErrPointer
+		}
+		length := int(data[start+1])
+		m.add(code, data[start+2:start+2+length])
+		start += 2 + length
+	}
+
+	return m, nil
+}
+
+// DecodeWithCIC decodes a message preceded by its circuit identification code,
+// as sent in HEP payload type 54
+func DecodeWithCIC(data []byte) (*Message, error) {
+
+	if len(data) < 3 {
+		return nil, ErrTooShort
+	}
+
+	m, err := Decode(data[2:])
+	if m != nil {
+		m.CIC = int(data[0]) | int(data[1]&0x0f)<<8
+	}
+	return m, err
+}
+
+func pointed(data []byte, pos int) ([]byte, error) {
+
+	if pos >= len(data) || data[pos] == 0 {
+		return nil, ErrPointer
+	}
+	start := pos + int(data[pos])
+	if start >= len(data) || start+1+int(data[start]) > len(data) {
+		return nil, ErrPointer
+	}
+	return data[start : start+1+int(data[start])], nil
+}
+
+func (m *Message) add(code int, value []byte) {
+
+	m.Parameters = append(m.Parameters, &Parameter{Code: code, Name: ParameterName(code), Value: hex.EncodeToString(value)})
+
+	switch code {
+	case ParamCalledPartyNumber:
+		m.CalledPartyNumber = decodeNumber(value, true)
+	case ParamCallingPartyNumber:
+		m.CallingPartyNumber = decodeNumber(value, false)
+	case ParamRedirectingNumber:
+		m.RedirectingNumber = decodeNumber(value, false)
+	case ParamOriginalCalledNumber:
+		m.OriginalCalledNumber = decodeNumber(value, false)
+	case ParamRedirectionNumber:
+		m.RedirectionNumber = decodeNumber(value, true)
+	case ParamRedirectionInfo:
+		m.RedirectionInformation = decodeRedirection(value)
+	case ParamCauseIndicators:
+		m.Cause = decodeCause(value)
+	case ParamCallingPartyCategory:
+		if len(value) == 1 {
+			category := int(value[0])
+			m.CallingPartyCategory = &category
+		}
+	}
+}
+
+// CalledDigits, CallingDigits and CauseValue return the searchable values
+// of the message, empty if it hasn't got them
+func (m *Message) CalledDigits() string {
+	if m.CalledPartyNumber == nil {
+		return ""
+	}
+	return m.CalledPartyNumber.Digits
+}
+
+func (m *Message) CallingDigits() string {
+	if m.CallingPartyNumber == nil {
+		return ""
+	}
+	return m.CallingPartyNumber.Digits
+}
+
+func (m *Message) CauseValue() int {
+	if m.Cause == nil {
+		return 0
+	}
+	return m.Cause.Value
+}
+
+// HexDump returns the bytes as upper case hex pairs separated by spaces
+func HexDump(data []byte) string {
+	return strings.ToUpper(strings.TrimSpace(fmt.Sprintf("% x", data)))
+}
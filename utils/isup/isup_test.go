@@ -0,0 +1,115 @@
+package isup
+
+import (
+	"testing"
+)
+
+var testIAM = []byte{
+	0x01,                   // IAM
+	0x00, 0x60, 0x01, 0x0a, // nature of connection, forward call, calling party's category
+	0x00,       // transmission medium requirement
+	0x02, 0x07, // pointers to called party number and optional part
+	0x05, 0x83, 0x10, 0x21, 0x43, 0x05, // called: odd, national, 12345
+	0x0a, 0x05, 0x04, 0x13, 0x94, 0x71, 0x10, // calling: even, international, 491701
+	0x13, 0x02, 0x03, 0x41, // redirection information
+	0x28, 0x04, 0x03, 0x13, 0x21, 0x43, // original called number 1234
+	0x00,
+}
+
+func TestDecodeIAM(t *testing.T) {
+	m, err := Decode(testIAM)
+	if err != nil {
+		t.Fatalf("[TestDecodeIAM] Unexpected err: %v", err)
+	}
+	if m.Name != "IAM" || len(m.Parameters) != 8 {
+		t.Errorf("[TestDecodeIAM] Bad message %s with %d parameters", m.Name, len(m.Parameters))
+	}
+	if m.CallingPartyCategory == nil || *m.CallingPartyCategory != 0x0a {
+		t.Errorf("[TestDecodeIAM] Bad calling party's category: %v", m.CallingPartyCategory)
+	}
+
+	called := m.CalledPartyNumber
+	if called == nil || called.Digits != "12345" || called.NatureOfAddress != 3 || called.NumberingPlan != 1 {
+		t.Errorf("[TestDecodeIAM] Bad called party number: %+v", called)
+	}
+	calling := m.CallingPartyNumber
+	if calling == nil || calling.Digits != "491701" || calling.NatureOfAddress != 4 || calling.Screening != 3 {
+		t.Errorf("[TestDecodeIAM] Bad calling party number: %+v", calling)
+	}
+	if m.CalledDigits() != "12345" || m.CallingDigits() != "491701" {
+		t.Errorf("[TestDecodeIAM] Bad searchable digits: %s %s", m.CalledDigits(), m.CallingDigits())
+	}
+
+	r := m.RedirectionInformation
+	if r == nil || r.Indicator != 3 || r.Counter != 1 || r.Reason != 4 {
+		t.Errorf("[TestDecodeIAM] Bad redirection information: %+v", r)
+	}
+	if m.OriginalCalledNumber == nil || m.OriginalCalledNumber.Digits != "1234" {
+		t.Errorf("[TestDecodeIAM] Bad original called number: %+v", m.OriginalCalledNumber)
+	}
+}
+
+func TestDecodeMessages(t *testing.T) {
+	tests := []struct {
+		data  []byte
+		name  string
+		cause int
+	}{
+		{[]byte{0x06, 0x12, 0x14, 0x00}, "ACM", 0},
+		{[]byte{0x09, 0x00}, "ANM", 0},
+		{[]byte{0x0c, 0x02, 0x00, 0x02, 0x80, 0x90}, "REL", 16},
+		{[]byte{0x0c, 0x02, 0x00, 0x04, 0x02, 0x04, 0x91, 0x55}, "REL", 17},
+		{[]byte{0x10, 0x00}, "RLC", 0},
+		{[]byte{0x33}, "0x33", 0},
+	}
+	for _, val := range tests {
+		m, err := Decode(val.data)
+		if err != nil {
+			t.Errorf("[TestDecodeMessages] Unexpected err for %s: %v", val.name, err)
+			continue
+		}
+		if m.Name != val.name || m.CauseValue() != val.cause {
+			t.Errorf("[TestDecodeMessages] Expected %s with cause %d, got %s with cause %d", val.name, val.cause, m.Name, m.CauseValue())
+		}
+	}
+
+	m, _ := Decode([]byte{0x0c, 0x02, 0x00, 0x02, 0x80, 0x90})
+	if m.Cause.Name != "Normal call clearing" {
+		t.Errorf("[TestDecodeMessages] Bad cause name: %s", m.Cause.Name)
+	}
+	m, _ = Decode([]byte{0x0c, 0x02, 0x00, 0x04, 0x02, 0x04, 0x91, 0x55})
+	if m.Cause.Location != 2 || m.Cause.Diagnostics != "55" {
+		t.Errorf("[TestDecodeMessages] Bad cause with recommendation: %+v", m.Cause)
+	}
+}
+
+func TestDecodeWithCIC(t *testing.T) {
+	m, err := DecodeWithCIC([]byte{0x2a, 0x01, 0x09, 0x00})
+	if err != nil {
+		t.Fatalf("[TestDecodeWithCIC] Unexpected err: %v", err)
+	}
+	if m.CIC != 298 || m.Name != "ANM" {
+		t.Errorf("[TestDecodeWithCIC] Expected ANM on CIC 298, got %s on %d", m.Name, m.CIC)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := [][]byte{
+		{},
+		{0x01, 0x00, 0x60},
+		{0x01, 0x00, 0x60, 0x01, 0x0a, 0x00, 0x30, 0x00},
+		{0x0c, 0x02},
+		{0x09},
+		{0x09, 0x01, 0x0a, 0x09, 0x01},
+	}
+	for _, val := range tests {
+		if _, err := Decode(val); err == nil {
+			t.Errorf("[TestDecodeErrors] Expected an error for % x", val)
+		}
+	}
+
+	/* every prefix of a message is rejected without a panic */
+	for i := range testIAM {
+		Decode(testIAM[:i])
+	}
+}
@@ -0,0 +1,195 @@
+package isup
+
+// Number is a called, calling, redirecting or original called party number
+type Number struct {
+	Digits          string `json:"digits"`
+	NatureOfAddress int    `json:"nature_of_address"`
+	NumberingPlan   int    `json:"numbering_plan"`
+	// calling, redirecting and original called numbers only
+	Presentation int `json:"presentation,omitempty"`
+	Screening    int `json:"screening,omitempty"`
+	// called party number ends with ST
+	EndOfPulsing bool `json:"end_of_pulsing,omitempty"`
+}
+
+// Cause are the cause indicators (Q.850)
+type Cause struct {
+	Value       int    `json:"value"`
+	Name        string `json:"name"`
+	Location    int    `json:"location"`
+	Standard    int    `json:"coding_standard"`
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+// Redirection is the redirection information
+type Redirection struct {
+	Indicator      int `json:"redirecting_indicator"`
+	OriginalReason int `json:"original_reason"`
+	Counter        int `json:"counter"`
+	Reason         int `json:"reason"`
+}
+
+const bcdDigits = "0123456789ABCDEF"
+
+// decodeNumber decodes the Q.763 number format, called is true for the called
+// party and redirection numbers which carry an INN instead of presentation
+func decodeNumber(value []byte, called bool) *Number {
+
+	if len(value) < 2 {
+		return nil
+	}
+
+	n := &Number{
+		NatureOfAddress: int(value[0] & 0x7f),
+		NumberingPlan:   int(value[1]>>4) & 0x07,
+	}
+	if !called {
+		n.Presentation = int(value[1]>>2) & 0x03
+		n.Screening = int(value[1] & 0x03)
+	}
+
+	odd := value[0]&0x80 != 0
+	digits := make([]byte, 0, 2*(len(value)-2))
+	for i, b := range value[2:] {
+		digits = append(digits, bcdDigits[b&0x0f])
+		/* the filler of an odd count is left out */
+		if i == len(value)-3 && odd {
+			break
+		}
+		digits = append(digits, bcdDigits[b>>4])
+	}
+
+	if called && len(digits) > 0 && digits[len(digits)-1] == 'F' {
+		digits = digits[:len(digits)-1]
+		n.EndOfPulsing = true
+	}
+	n.Digits = string(digits)
+
+	return n
+}
+
+func decodeCause(value []byte) *Cause {
+
+	if len(value) < 2 {
+		return nil
+	}
+
+	c := &Cause{
+		Location: int(value[0] & 0x0f),
+		Standard: int(value[0]>>5) & 0x03,
+	}
+
+	/* extension bit clear: a recommendation octet follows the location */
+	rest := value[1:]
+	if value[0]&0x80 == 0 {
+		rest = value[2:]
+	}
+	if len(rest) < 1 {
+		return nil
+	}
+
+	c.Value = int(rest[0] & 0x7f)
+	c.Name = CauseName(c.Value)
+	if len(rest) > 1 {
+		c.Diagnostics = HexDump(rest[1:])
+	}
+
+	return c
+}
+
+func decodeRedirection(value []byte) *Redirection {
+
+	if len(value) < 1 {
+		return nil
+	}
+
+	r := &Redirection{
+		Indicator:      int(value[0] & 0x07),
+		OriginalReason: int(value[0] >> 4),
+	}
+	if len(value) > 1 {
+		r.Counter = int(value[1] & 0x07)
+		r.Reason = int(value[1] >> 4)
+	}
+	return r
+}
+
+var parameterNames = map[int]string{
+	ParamCalledPartyNumber:    "Called party number",
+	ParamNatureOfConnection:   "Nature of connection indicators",
+	ParamForwardCall:          "Forward call indicators",
+	ParamCallingPartyCategory: "Calling party's category",
+	ParamCallingPartyNumber:   "Calling party number",
+	ParamRedirectingNumber:    "Redirecting number",
+	ParamRedirectionNumber:    "Redirection number",
+	ParamBackwardCall:         "Backward call indicators",
+	ParamCauseIndicators:      "Cause indicators",
+	ParamRedirectionInfo:      "Redirection information",
+	ParamEventInformation:     "Event information",
+	ParamTransmissionMedium:   "Transmission medium requirement",
+	ParamOriginalCalledNumber: "Original called number",
+	ParamGenericNumber:        "Generic number",
+	0x1d:                      "User service information",
+	0x29:                      "Optional backward call indicators",
+	0x31:                      "Optional forward call indicators",
+	0x39:                      "Parameter compatibility information",
+	0x3d:                      "Call diversion information",
+	0x21:                      "Call reference",
+	0x03:                      "Access transport",
+	0x2d:                      "Access delivery information",
+	0x4e:                      "Automatic congestion level",
+}
+
+// ParameterName returns the Q.763 name of a parameter code
+func ParameterName(code int) string {
+	if name, ok := parameterNames[code]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+var causeNames = map[int]string{
+	1:   "Unallocated (unassigned) number",
+	2:   "No route to specified transit network",
+	3:   "No route to destination",
+	16:  "Normal call clearing",
+	17:  "User busy",
+	18:  "No user responding",
+	19:  "No answer from user (user alerted)",
+	20:  "Subscriber absent",
+	21:  "Call rejected",
+	22:  "Number changed",
+	27:  "Destination out of order",
+	28:  "Invalid number format (address incomplete)",
+	29:  "Facility rejected",
+	31:  "Normal, unspecified",
+	34:  "No circuit/channel available",
+	38:  "Network out of order",
+	41:  "Temporary failure",
+	42:  "Switching equipment congestion",
+	44:  "Requested circuit/channel not available",
+	47:  "Resource unavailable, unspecified",
+	50:  "Requested facility not subscribed",
+	55:  "Incoming calls barred within CUG",
+	57:  "Bearer capability not authorized",
+	58:  "Bearer capability not presently available",
+	63:  "Service or option not available, unspecified",
+	65:  "Bearer capability not implemented",
+	69:  "Requested facility not implemented",
+	79:  "Service or option not implemented, unspecified",
+	88:  "Incompatible destination",
+	95:  "Invalid message, unspecified",
+	97:  "Message type non-existent or not implemented",
+	99:  "Information element/parameter non-existent or not implemented",
+	102: "Recovery on timer expiry",
+	111: "Protocol error, unspecified",
+	127: "Interworking, unspecified",
+}
+
+// CauseName returns the Q.850 name of a cause value
+func CauseName(value int) string {
+	if name, ok := causeNames[value]; ok {
+		return name
+	}
+	return "Unknown"
+}
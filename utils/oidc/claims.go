@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Claims are the claims of the ID token of a user
+type Claims map[string]interface{}
+
+// Value returns the claim, a dotted path like realm_access.roles goes into objects. A
+// claim with dots in its name, like the URLs some providers use, is found as is.
+func (c Claims) Value(path string) interface{} {
+
+	if val, ok := c[path]; ok {
+		return val
+	}
+
+	var val interface{} = map[string]interface{}(c)
+	for _, name := range strings.Split(path, ".") {
+		object, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if val, ok = object[name]; !ok {
+			return nil
+		}
+	}
+	return val
+}
+
+// String returns the claim if it is a string or a number
+func (c Claims) String(path string) string {
+
+	switch val := c.Value(path).(type) {
+	case string:
+		return val
+	case float64:
+		return fmt.Sprint(val)
+	}
+	return ""
+}
+
+// Strings returns the values of a list claim, a string claim is one value
+func (c Claims) Strings(path string) []string {
+
+	values := []string{}
+	switch val := c.Value(path).(type) {
+	case string:
+		values = append(values, val)
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// Username returns the user name of the claims
+func (p *Provider) Username(claims Claims) string {
+
+	if p.UsernameClaim != "" {
+		return claims.String(p.UsernameClaim)
+	}
+	for _, name := range []string{"preferred_username", "email", "sub"} {
+		if val := claims.String(name); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// Groups maps the values of the groups claim to user groups, the default group is
+// returned when there are none
+func (p *Provider) Groups(claims Claims) []string {
+
+	claim := p.GroupsClaim
+	if claim == "" {
+		claim = "groups"
+	}
+
+	found := map[string]bool{}
+	groups := []string{}
+	for _, value := range claims.Strings(claim) {
+		group := value
+		if len(p.mapping) > 0 {
+			var ok bool
+			if group, ok = p.mapping[strings.ToLower(value)]; !ok {
+				continue
+			}
+		}
+		if group != "" && !found[group] {
+			found[group] = true
+			groups = append(groups, group)
+		}
+	}
+
+	if len(groups) == 0 && p.DefaultGroup != "" {
+		groups = append(groups, p.DefaultGroup)
+	}
+	return groups
+}
@@ -0,0 +1,172 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+/* the providers sign with keys of their own, never with the client secret */
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+/* the clocks of the provider and of homer may differ a bit */
+const clockSkew = time.Minute
+
+/* an unknown kid loads the keys again, a rotation of the keys, but not more often */
+const keysReload = time.Minute
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func decodeInt(val string) (*big.Int, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+// publicKey returns the RSA or EC key, nil for the other ones
+func (key jsonWebKey) publicKey() (interface{}, error) {
+
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unknown curve %s", key.Crv)
+		}
+		x, err := decodeInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+// key returns the signing key of the kid, the keys are loaded again for an unknown one
+func (p *Provider) key(ctx context.Context, metadata *Metadata, kid string) (interface{}, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysLoaded) < keysReload {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	set := jsonWebKeySet{}
+	if err := p.getJSON(ctx, metadata.JwksURI, &set); err != nil {
+		return nil, fmt.Errorf("keys of %s can't be loaded: %v", p.Name, err)
+	}
+	keys := map[string]interface{}{}
+	for _, val := range set.Keys {
+		if val.Use != "" && val.Use != "sig" {
+			continue
+		}
+		key, err := val.publicKey()
+		if err != nil || key == nil {
+			continue
+		}
+		keys[val.Kid] = key
+	}
+	p.keys, p.keysLoaded = keys, time.Now()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+/* a token without kid is fine when the provider has one key */
+func (p *Provider) findKey(kid string) interface{} {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// Verify checks the signature of the ID token with the keys of the provider, then its
+// issuer, audience, expiry and nonce
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	parser := jwt.Parser{ValidMethods: signingMethods, SkipClaimsValidation: true}
+	if _, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, metadata, kid)
+	}); err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	now := time.Now()
+	switch {
+	case !claims.VerifyIssuer(metadata.Issuer, true):
+		return nil, errors.New("the ID token has another issuer")
+	case !claims.VerifyAudience(p.ClientID, true):
+		return nil, errors.New("the ID token is for another client")
+	case !claims.VerifyExpiresAt(now.Add(-clockSkew).Unix(), true):
+		return nil, errors.New("the ID token has expired")
+	case !claims.VerifyIssuedAt(now.Add(clockSkew).Unix(), false):
+		return nil, errors.New("the ID token has been issued in the future")
+	case nonce == "" || Claims(claims).String("nonce") != nonce:
+		return nil, errors.New("the nonce of the ID token doesn't match")
+	}
+
+	/* with several audiences the token must have been issued to us */
+	if auds, ok := claims["aud"].([]interface{}); ok && len(auds) > 1 && Claims(claims).String("azp") != p.ClientID {
+		return nil, errors.New("the ID token has been issued to another client")
+	}
+
+	return Claims(claims), nil
+}
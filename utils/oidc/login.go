@@ -0,0 +1,78 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// Login is a login sent to a provider, kept until the browser comes back with the state
+type Login struct {
+	Provider string
+	State    string
+	Nonce    string
+	// the PKCE code verifier, the provider gets its challenge
+	Verifier string
+	Expire   time.Time
+}
+
+// Logins are the logins on their way, every state can be used once
+type Logins struct {
+	sync.Mutex
+	ttl    time.Duration
+	logins map[string]Login
+}
+
+// NewLogins keeps the logins for ttl, the time the user has to log in at the provider
+func NewLogins(ttl time.Duration) *Logins {
+	return &Logins{ttl: ttl, logins: map[string]Login{}}
+}
+
+func random() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Start makes the state, nonce and code verifier of a login
+func (l *Logins) Start(provider string) (Login, error) {
+
+	login := Login{Provider: provider, Expire: time.Now().Add(l.ttl)}
+	for _, val := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		s, err := random()
+		if err != nil {
+			return login, err
+		}
+		*val = s
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	/* the logins which never came back */
+	now := time.Now()
+	for state, val := range l.logins {
+		if now.After(val.Expire) {
+			delete(l.logins, state)
+		}
+	}
+	l.logins[login.State] = login
+	return login, nil
+}
+
+// Finish returns the login of the state and forgets it, false if it is unknown or expired
+func (l *Logins) Finish(state string) (Login, bool) {
+
+	l.Lock()
+	defer l.Unlock()
+
+	login, ok := l.logins[state]
+	if !ok {
+		return login, false
+	}
+	delete(l.logins, state)
+	return login, time.Now().Before(login.Expire)
+}
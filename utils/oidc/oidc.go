@@ -0,0 +1,229 @@
+// Package oidc logs users in with OpenID Connect providers: discovery, the
+// authorization code flow with PKCE, state and nonce, the ID token checked against the
+// JWKS of the provider and the mapping of its claims to user groups.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/heputils"
+	"golang.org/x/oauth2"
+)
+
+// Config is a provider in the config, oidc.providers
+type Config struct {
+	// the name of the provider in the URLs, /oidc/login/<name>
+	Name         string `json:"name" mapstructure:"name"`
+	DisplayName  string `json:"display_name" mapstructure:"display_name"`
+	Image        string `json:"image" mapstructure:"image"`
+	Issuer       string `json:"issuer" mapstructure:"issuer"`
+	ClientID     string `json:"client_id" mapstructure:"client_id"`
+	ClientSecret string `json:"client_secret" mapstructure:"client_secret"`
+	// the callback URL registered with the provider, .../api/v3/oidc/callback/<name>
+	RedirectURI string   `json:"redirect_uri" mapstructure:"redirect_uri"`
+	Scopes      []string `json:"scopes" mapstructure:"scopes"`
+	// the claim of the user name, preferred_username, email and sub are tried if empty
+	UsernameClaim string `json:"username_claim" mapstructure:"username_claim"`
+	// the claim of the groups or roles, a dotted path like realm_access.roles is possible
+	GroupsClaim string `json:"groups_claim" mapstructure:"groups_claim"`
+	// the user group of the values of the groups claim, the values which aren't in it are
+	// ignored. Without it the values are the user groups.
+	GroupMapping map[string]string `json:"group_mapping" mapstructure:"group_mapping"`
+	// the user group when no group has been found
+	DefaultGroup string `json:"default_group" mapstructure:"default_group"`
+	// the claims of the userinfo endpoint are added to the ones of the ID token
+	UserInfo bool `json:"userinfo" mapstructure:"userinfo"`
+	// the users are created in the users table at their first login
+	Provision    bool `json:"provision" mapstructure:"provision"`
+	PartID       int  `json:"partid" mapstructure:"partid"`
+	AutoRedirect bool `json:"auto_redirect" mapstructure:"auto_redirect"`
+}
+
+// Metadata is what the discovery document of a provider says
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Provider is a configured provider, its discovery document and keys are loaded at the
+// first login and kept
+type Provider struct {
+	Config
+	client  *http.Client
+	mapping map[string]string
+
+	sync.Mutex
+	metadata   *Metadata
+	keys       map[string]interface{}
+	keysLoaded time.Time
+}
+
+// NewProvider checks the config of a provider
+func NewProvider(cfg Config, client *http.Client) (*Provider, error) {
+
+	if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("a provider needs a name, an issuer and a client_id")
+	}
+	if cfg.RedirectURI == "" {
+		return nil, fmt.Errorf("the provider %s has no redirect_uri", cfg.Name)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	scopes := []string{"openid"}
+	for _, scope := range cfg.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	cfg.Scopes = scopes
+
+	/* the config keys may have been lowercased, the claims are compared the same way */
+	mapping := map[string]string{}
+	for value, group := range cfg.GroupMapping {
+		mapping[strings.ToLower(value)] = group
+	}
+
+	return &Provider{Config: cfg, client: client, mapping: mapping}, nil
+}
+
+func (p *Provider) context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, p.client)
+}
+
+// Discover loads the discovery document of the provider, once it has worked
+func (p *Provider) Discover(ctx context.Context) (*Metadata, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &Metadata{}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", metadata); err != nil {
+		return nil, fmt.Errorf("discovery of %s failed: %v", p.Name, err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/") {
+		return nil, fmt.Errorf("the provider %s says its issuer is %q", p.Name, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksURI == "" {
+		return nil, fmt.Errorf("the discovery document of %s misses endpoints", p.Name)
+	}
+
+	p.metadata = metadata
+	return metadata, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, val interface{}) error {
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned [%d]", url, resp.StatusCode)
+	}
+	return json.Unmarshal(body, val)
+}
+
+func (p *Provider) oauth2Config(metadata *Metadata) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURI,
+		Scopes:       p.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}
+}
+
+// AuthCodeURL returns where the browser is sent to log in
+func (p *Provider) AuthCodeURL(ctx context.Context, login Login) (string, error) {
+
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return p.oauth2Config(metadata).AuthCodeURL(login.State,
+		oauth2.SetAuthURLParam("nonce", login.Nonce),
+		oauth2.SetAuthURLParam("code_challenge", heputils.GenCodeChallengeS256(login.Verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256")), nil
+}
+
+// Exchange swaps the code the browser came back with for the tokens and returns the
+// claims of the checked ID token, and of the userinfo endpoint if configured
+func (p *Provider) Exchange(ctx context.Context, code string, login Login) (Claims, error) {
+
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := p.oauth2Config(metadata)
+	token, err := config.Exchange(p.context(ctx), code, oauth2.SetAuthURLParam("code_verifier", login.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange with %s failed: %v", p.Name, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%s returned no ID token", p.Name)
+	}
+	claims, err := p.Verify(ctx, rawIDToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.UserInfo && metadata.UserinfoEndpoint != "" {
+		info := Claims{}
+		resp, err := config.Client(p.context(ctx), token).Get(metadata.UserinfoEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("userinfo of %s failed: %v", p.Name, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("userinfo of %s returned [%d]", p.Name, resp.StatusCode)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			return nil, fmt.Errorf("userinfo of %s is invalid: %v", p.Name, err)
+		}
+		/* the userinfo must be about the user of the ID token */
+		if info.String("sub") != claims.String("sub") {
+			return nil, fmt.Errorf("userinfo of %s is about another user", p.Name)
+		}
+		for key, val := range info {
+			if _, ok := claims[key]; !ok {
+				claims[key] = val
+			}
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,491 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/sipcapture/homer-app/utils/heputils"
+)
+
+const (
+	testClientID     = "homer"
+	testClientSecret = "homer-secret"
+	testRedirectURI  = "http://homer.example.com/api/v3/oidc/callback/keycloak"
+)
+
+/* a stand-in OpenID Connect provider */
+type testIssuer struct {
+	*httptest.Server
+	sync.Mutex
+	key *rsa.PrivateKey
+	kid string
+	/* code -> the authorization request */
+	codes  map[string]url.Values
+	claims jwt.MapClaims
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+
+	ti := &testIssuer{codes: map[string]url.Values{}, kid: "key1", key: testKey(t)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ti.URL,
+			"authorization_endpoint": ti.URL + "/auth",
+			"token_endpoint":         ti.URL + "/token",
+			"userinfo_endpoint":      ti.URL + "/userinfo",
+			"jwks_uri":               ti.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		ti.Lock()
+		defer ti.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": ti.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(ti.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(ti.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		ti.Lock()
+		request, found := ti.codes[r.PostForm.Get("code")]
+		delete(ti.codes, r.PostForm.Get("code"))
+		ti.Unlock()
+		switch {
+		case id != testClientID || secret != testClientSecret:
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		case !found || r.PostForm.Get("redirect_uri") != request.Get("redirect_uri"):
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		case heputils.GenCodeChallengeS256(r.PostForm.Get("code_verifier")) != request.Get("code_challenge"):
+			http.Error(w, `{"error":"invalid_grant","error_description":"PKCE"}`, http.StatusBadRequest)
+			return
+		}
+		claims := ti.idClaims()
+		claims["nonce"] = request.Get("nonce")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-" + claims["sub"].(string),
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     ti.sign(t, claims),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-"+ti.claims["sub"].(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":   ti.claims["sub"],
+			"email": "jdoe@example.com",
+			/* the ID token says otherwise, it wins */
+			"preferred_username": "someone",
+		})
+	})
+	ti.Server = httptest.NewServer(mux)
+	ti.claims = jwt.MapClaims{
+		"sub":                "f3b5c6d2",
+		"preferred_username": "jdoe",
+		"given_name":         "John",
+		"realm_access":       map[string]interface{}{"roles": []interface{}{"offline_access", "Homer-Admins"}},
+	}
+	return ti
+}
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("[testKey] %v", err)
+	}
+	return key
+}
+
+func (ti *testIssuer) idClaims() jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss": ti.URL,
+		"aud": testClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for key, val := range ti.claims {
+		claims[key] = val
+	}
+	return claims
+}
+
+func (ti *testIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	ti.Lock()
+	defer ti.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = ti.kid
+	raw, err := token.SignedString(ti.key)
+	if err != nil {
+		t.Fatalf("[sign] %v", err)
+	}
+	return raw
+}
+
+/* the user logs in at the provider, which sends the browser back with a code */
+func (ti *testIssuer) authorize(t *testing.T, authURL string) (string, string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("[authorize] %v", err)
+	}
+	query := u.Query()
+	code := fmt.Sprintf("code%d", time.Now().UnixNano())
+	ti.Lock()
+	ti.codes[code] = query
+	ti.Unlock()
+	return code, query.Get("state")
+}
+
+func testProvider(t *testing.T, ti *testIssuer, cfg Config) *Provider {
+	cfg.Name = "keycloak"
+	cfg.Issuer = ti.URL
+	cfg.ClientID = testClientID
+	cfg.ClientSecret = testClientSecret
+	cfg.RedirectURI = testRedirectURI
+	p, err := NewProvider(cfg, ti.Client())
+	if err != nil {
+		t.Fatalf("[testProvider] %v", err)
+	}
+	return p
+}
+
+func TestLogin(t *testing.T) {
+
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	p := testProvider(t, ti, Config{
+		Scopes:       []string{"profile", "email"},
+		GroupsClaim:  "realm_access.roles",
+		GroupMapping: map[string]string{"homer-admins": "admin"},
+		UserInfo:     true,
+	})
+	logins := NewLogins(time.Minute)
+	ctx := context.Background()
+
+	login, err := logins.Start(p.Name)
+	if err != nil {
+		t.Fatalf("[TestLogin] %v", err)
+	}
+	authURL, err := p.AuthCodeURL(ctx, login)
+	if err != nil {
+		t.Fatalf("[TestLogin] %v", err)
+	}
+	if !strings.HasPrefix(authURL, ti.URL+"/auth?") {
+		t.Errorf("[TestLogin] the browser is sent to %s", authURL)
+	}
+	query, _ := url.Parse(authURL)
+	for key, expected := range map[string]string{
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURI,
+		"response_type":         "code",
+		"scope":                 "openid profile email",
+		"code_challenge_method": "S256",
+		"nonce":                 login.Nonce,
+		"state":                 login.State,
+	} {
+		if got := query.Query().Get(key); got != expected {
+			t.Errorf("[TestLogin] %s is %q, expected %q", key, got, expected)
+		}
+	}
+	if query.Query().Get("code_challenge") == login.Verifier {
+		t.Errorf("[TestLogin] the code verifier has been sent instead of its challenge")
+	}
+
+	code, state := ti.authorize(t, authURL)
+	back, ok := logins.Finish(state)
+	if !ok || back.Nonce != login.Nonce {
+		t.Fatalf("[TestLogin] the login of the state isn't found")
+	}
+	claims, err := p.Exchange(ctx, code, back)
+	if err != nil {
+		t.Fatalf("[TestLogin] %v", err)
+	}
+	if got := p.Username(claims); got != "jdoe" {
+		t.Errorf("[TestLogin] user name %q, expected jdoe", got)
+	}
+	if got := claims.String("email"); got != "jdoe@example.com" {
+		t.Errorf("[TestLogin] the email of the userinfo is missing: %q", got)
+	}
+	if got := p.Groups(claims); len(got) != 1 || got[0] != "admin" {
+		t.Errorf("[TestLogin] groups %v, expected [admin]", got)
+	}
+
+	/* the code can't be used twice */
+	if _, err := p.Exchange(ctx, code, back); err == nil {
+		t.Errorf("[TestLogin] a code has been used twice")
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	p := testProvider(t, ti, Config{})
+	ctx := context.Background()
+
+	login, _ := NewLogins(time.Minute).Start(p.Name)
+	authURL, err := p.AuthCodeURL(ctx, login)
+	if err != nil {
+		t.Fatalf("[TestExchangeWrongVerifier] %v", err)
+	}
+	code, _ := ti.authorize(t, authURL)
+
+	/* a stolen code is useless without the verifier */
+	other, _ := NewLogins(time.Minute).Start(p.Name)
+	other.Nonce = login.Nonce
+	if _, err := p.Exchange(ctx, code, other); err == nil {
+		t.Errorf("[TestExchangeWrongVerifier] the code has been exchanged with another verifier")
+	}
+}
+
+func TestVerify(t *testing.T) {
+
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	p := testProvider(t, ti, Config{})
+	ctx := context.Background()
+
+	valid := func() jwt.MapClaims {
+		claims := ti.idClaims()
+		claims["nonce"] = "n1"
+		return claims
+	}
+	if _, err := p.Verify(ctx, ti.sign(t, valid()), "n1"); err != nil {
+		t.Fatalf("[TestVerify] valid token refused: %v", err)
+	}
+
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+	hmacToken.Header["kid"] = ti.kid
+	hmacRaw, _ := hmacToken.SignedString([]byte(testClientSecret))
+
+	otherKey := jwt.NewWithClaims(jwt.SigningMethodRS256, valid())
+	otherKey.Header["kid"] = ti.kid
+	otherRaw, _ := otherKey.SignedString(testKey(t))
+
+	tests := []struct {
+		name  string
+		raw   string
+		nonce string
+	}{
+		{"wrong nonce", ti.sign(t, valid()), "n2"},
+		{"no nonce", ti.sign(t, valid()), ""},
+		{"client secret", hmacRaw, "n1"},
+		{"other key", otherRaw, "n1"},
+		{"not a JWT", "abc.def.ghi", "n1"},
+	}
+	for _, change := range []struct {
+		name  string
+		claim string
+		value interface{}
+	}{
+		{"other audience", "aud", "grafana"},
+		{"other issuer", "iss", "https://evil.example.com"},
+		{"expired", "exp", time.Now().Add(-10 * time.Minute).Unix()},
+		{"issued in the future", "iat", time.Now().Add(10 * time.Minute).Unix()},
+		{"no expiry", "exp", nil},
+		{"several audiences", "aud", []interface{}{testClientID, "grafana"}},
+	} {
+		claims := valid()
+		if change.value == nil {
+			delete(claims, change.claim)
+		} else {
+			claims[change.claim] = change.value
+		}
+		tests = append(tests, struct {
+			name  string
+			raw   string
+			nonce string
+		}{change.name, ti.sign(t, claims), "n1"})
+	}
+
+	for _, test := range tests {
+		if _, err := p.Verify(ctx, test.raw, test.nonce); err == nil {
+			t.Errorf("[TestVerify] %s: the token has been accepted", test.name)
+		}
+	}
+
+	/* several audiences are fine when we are the authorized party */
+	claims := valid()
+	claims["aud"] = []interface{}{testClientID, "grafana"}
+	claims["azp"] = testClientID
+	if _, err := p.Verify(ctx, ti.sign(t, claims), "n1"); err != nil {
+		t.Errorf("[TestVerify] token of several audiences refused: %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	p := testProvider(t, ti, Config{})
+	ctx := context.Background()
+
+	claims := ti.idClaims()
+	claims["nonce"] = "n1"
+	if _, err := p.Verify(ctx, ti.sign(t, claims), "n1"); err != nil {
+		t.Fatalf("[TestKeyRotation] %v", err)
+	}
+
+	ti.Lock()
+	ti.key, ti.kid = testKey(t), "key2"
+	ti.Unlock()
+
+	/* the keys have just been loaded, they aren't loaded again at once */
+	if _, err := p.Verify(ctx, ti.sign(t, claims), "n1"); err == nil {
+		t.Errorf("[TestKeyRotation] the keys have been loaded again at once")
+	}
+
+	p.Lock()
+	p.keysLoaded = time.Now().Add(-2 * keysReload)
+	p.Unlock()
+	if _, err := p.Verify(ctx, ti.sign(t, claims), "n1"); err != nil {
+		t.Errorf("[TestKeyRotation] the new key isn't used: %v", err)
+	}
+}
+
+func TestDiscoverOtherIssuer(t *testing.T) {
+
+	ti := newTestIssuer(t)
+	defer ti.Close()
+
+	/* the provider must say it is the configured issuer */
+	p, _ := NewProvider(Config{Name: "other", Issuer: ti.URL + "/", ClientID: testClientID, RedirectURI: testRedirectURI}, ti.Client())
+	if _, err := p.Discover(context.Background()); err != nil {
+		t.Errorf("[TestDiscoverOtherIssuer] a trailing slash should be ignored: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ti.URL,
+			"authorization_endpoint": ti.URL + "/auth",
+			"token_endpoint":         ti.URL + "/token",
+			"jwks_uri":               ti.URL + "/jwks",
+		})
+	})
+	fake := httptest.NewServer(mux)
+	defer fake.Close()
+	p, _ = NewProvider(Config{Name: "fake", Issuer: fake.URL, ClientID: testClientID, RedirectURI: testRedirectURI}, fake.Client())
+	if _, err := p.Discover(context.Background()); err == nil {
+		t.Errorf("[TestDiscoverOtherIssuer] the document of another issuer has been accepted")
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+
+	if _, err := NewProvider(Config{Name: "a", Issuer: "https://a", RedirectURI: testRedirectURI}, nil); err == nil {
+		t.Errorf("[TestNewProvider] a provider without client_id has been accepted")
+	}
+	if _, err := NewProvider(Config{Name: "a", Issuer: "https://a", ClientID: "c"}, nil); err == nil {
+		t.Errorf("[TestNewProvider] a provider without redirect_uri has been accepted")
+	}
+	p, err := NewProvider(Config{Name: "a", Issuer: "https://a", ClientID: "c", RedirectURI: testRedirectURI,
+		Scopes: []string{"email", "openid"}}, nil)
+	if err != nil {
+		t.Fatalf("[TestNewProvider] %v", err)
+	}
+	if strings.Join(p.Scopes, " ") != "openid email" {
+		t.Errorf("[TestNewProvider] scopes %v, expected [openid email]", p.Scopes)
+	}
+}
+
+func TestClaims(t *testing.T) {
+
+	claims := Claims{}
+	json.Unmarshal([]byte(`{
+		"sub": "1234",
+		"number": 42,
+		"groups": ["noc", "support", 5],
+		"role": "admin",
+		"https://example.com/roles": ["dev"],
+		"resource_access": {"homer": {"roles": ["viewer"]}}
+	}`), &claims)
+
+	if got := claims.String("number"); got != "42" {
+		t.Errorf("[TestClaims] number claim %q", got)
+	}
+	if got := claims.Strings("groups"); strings.Join(got, ",") != "noc,support" {
+		t.Errorf("[TestClaims] groups %v", got)
+	}
+	if got := claims.Strings("role"); strings.Join(got, ",") != "admin" {
+		t.Errorf("[TestClaims] a string claim should be one value, got %v", got)
+	}
+	if got := claims.Strings("https://example.com/roles"); strings.Join(got, ",") != "dev" {
+		t.Errorf("[TestClaims] claim with dots %v", got)
+	}
+	if got := claims.Strings("resource_access.homer.roles"); strings.Join(got, ",") != "viewer" {
+		t.Errorf("[TestClaims] nested claim %v", got)
+	}
+	if got := claims.Strings("resource_access.grafana.roles"); len(got) != 0 {
+		t.Errorf("[TestClaims] missing claim %v", got)
+	}
+
+	p, _ := NewProvider(Config{Name: "a", Issuer: "https://a", ClientID: "c", RedirectURI: testRedirectURI}, nil)
+	if got := p.Groups(claims); strings.Join(got, ",") != "noc,support" {
+		t.Errorf("[TestClaims] without mapping the values are the groups, got %v", got)
+	}
+	if got := p.Username(claims); got != "1234" {
+		t.Errorf("[TestClaims] user name %q, expected the sub", got)
+	}
+
+	p, _ = NewProvider(Config{Name: "a", Issuer: "https://a", ClientID: "c", RedirectURI: testRedirectURI,
+		GroupMapping: map[string]string{"NOC": "support", "support": "support", "ops": "admin"},
+		DefaultGroup: "user"}, nil)
+	if got := p.Groups(claims); strings.Join(got, ",") != "support" {
+		t.Errorf("[TestClaims] mapped groups %v, expected [support]", got)
+	}
+	if got := p.Groups(Claims{"groups": []interface{}{"guests"}}); strings.Join(got, ",") != "user" {
+		t.Errorf("[TestClaims] groups %v, expected the default group", got)
+	}
+}
+
+func TestLogins(t *testing.T) {
+
+	logins := NewLogins(time.Minute)
+	login, err := logins.Start("keycloak")
+	if err != nil {
+		t.Fatalf("[TestLogins] %v", err)
+	}
+	if login.State == login.Nonce || login.State == login.Verifier || len(login.Verifier) < 43 {
+		t.Errorf("[TestLogins] weak login %+v", login)
+	}
+	if _, ok := logins.Finish("unknown"); ok {
+		t.Errorf("[TestLogins] an unknown state has been accepted")
+	}
+	if got, ok := logins.Finish(login.State); !ok || got.Provider != "keycloak" {
+		t.Errorf("[TestLogins] the login hasn't been found")
+	}
+	if _, ok := logins.Finish(login.State); ok {
+		t.Errorf("[TestLogins] a state has been used twice")
+	}
+
+	expired := NewLogins(-time.Second)
+	login, _ = expired.Start("keycloak")
+	if _, ok := expired.Finish(login.State); ok {
+		t.Errorf("[TestLogins] an expired login has been accepted")
+	}
+}
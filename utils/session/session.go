@@ -0,0 +1,30 @@
+// Package session makes the refresh tokens of the login sessions. Only the hash of a
+// refresh token is stored, the token itself is given once to the client.
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+/* 256 random bits */
+const tokenSize = 32
+
+// NewRefreshToken returns a random refresh token, URL safe
+func NewRefreshToken() (string, error) {
+	buf := make([]byte, tokenSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Hash returns the hex SHA-256 of the refresh token, the way it's stored. The tokens
+// are random, a salt wouldn't add anything.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,43 @@
+package session
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		token, err := NewRefreshToken()
+		if err != nil {
+			t.Fatalf("[TestNewRefreshToken] unexpected error: %v", err)
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			t.Errorf("[TestNewRefreshToken] token %q isn't URL safe base64: %v", token, err)
+		}
+		if len(raw) != tokenSize {
+			t.Errorf("[TestNewRefreshToken] got %d random bytes, expected %d", len(raw), tokenSize)
+		}
+		if seen[token] {
+			t.Errorf("[TestNewRefreshToken] token %q made twice", token)
+		}
+		seen[token] = true
+	}
+}
+
+func TestHash(t *testing.T) {
+
+	/* sha256 of "abc" */
+	expected := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := Hash("abc"); got != expected {
+		t.Errorf("[TestHash] got %s, expected %s", got, expected)
+	}
+	if Hash(" abc\n") != expected {
+		t.Errorf("[TestHash] the spaces around the token should be ignored")
+	}
+	if Hash("abc") == Hash("abd") {
+		t.Errorf("[TestHash] different tokens have the same hash")
+	}
+}
@@ -0,0 +1,252 @@
+// Package siplint checks SIP messages and the dialogs of a call against the
+// rules of RFC 3261, RFC 3262 (100rel) and RFC 3311 (UPDATE).
+package siplint
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sipcapture/homer-app/utils/sipparser"
+)
+
+// severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Finding is one broken rule
+type Finding struct {
+	Rule      string  `json:"rule"`
+	Severity  string  `json:"severity"`
+	MessageID float64 `json:"message_id"`
+	Text      string  `json:"text"`
+}
+
+// Message is a captured message with the id of its row
+type Message struct {
+	ID  float64
+	Sip *sipparser.SipMsg
+}
+
+// Rule describes a check
+type Rule struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+	RFC      string `json:"rfc"`
+	Text     string `json:"text"`
+}
+
+// Rules are all the checks, by id
+var Rules = map[string]Rule{
+	"parse":                 {"parse", SeverityError, "RFC 3261 25", "message can't be parsed"},
+	"mandatory-header":      {"mandatory-header", SeverityError, "RFC 3261 8.1.1", "a mandatory header is missing"},
+	"via-branch-cookie":     {"via-branch-cookie", SeverityError, "RFC 3261 8.1.1.7", "the top Via branch doesn't start with z9hG4bK"},
+	"via-received":          {"via-received", SeverityError, "RFC 3261 18.2.1", "the Via received parameter is not an IP address"},
+	"via-rport":             {"via-rport", SeverityError, "RFC 3581 4", "the Via rport parameter is not a port"},
+	"max-forwards":          {"max-forwards", SeverityError, "RFC 3261 20.22", "Max-Forwards is not a number from 0 to 255"},
+	"cseq-method":           {"cseq-method", SeverityError, "RFC 3261 8.1.1.5", "the CSeq method differs from the request method"},
+	"content-length":        {"content-length", SeverityError, "RFC 3261 20.14", "Content-Length differs from the body size"},
+	"content-type":          {"content-type", SeverityError, "RFC 3261 20.15", "a body without Content-Type"},
+	"contact-missing":       {"contact-missing", SeverityError, "RFC 3261 8.1.1.8", "the request must have a Contact"},
+	"response-to-tag":       {"response-to-tag", SeverityWarning, "RFC 3261 8.2.6.2", "the response has no To tag"},
+	"initial-invite-to-tag": {"initial-invite-to-tag", SeverityError, "RFC 3261 8.1.1.2", "the initial INVITE has a To tag"},
+	"cseq-order":            {"cseq-order", SeverityError, "RFC 3261 12.2.1.1", "the CSeq number is lower than the one of an earlier request"},
+	"ack-cseq":              {"ack-cseq", SeverityError, "RFC 3261 17.1.1.3", "the ACK or CANCEL CSeq number matches no INVITE"},
+	"rel1xx-require":        {"rel1xx-require", SeverityError, "RFC 3262 7.1", "a reliable provisional response without Require: 100rel"},
+	"rseq-order":            {"rseq-order", SeverityWarning, "RFC 3262 3", "RSeq doesn't increase by one"},
+	"prack-rack":            {"prack-rack", SeverityError, "RFC 3262 7.2", "PRACK without a valid RAck"},
+	"prack-unmatched":       {"prack-unmatched", SeverityWarning, "RFC 3262 7.2", "the RAck matches no reliable provisional response"},
+	"update-contact":        {"update-contact", SeverityError, "RFC 3311 5.1", "UPDATE without Contact"},
+}
+
+func finding(rule string, id float64, format string, args ...interface{}) Finding {
+	return Finding{Rule: rule, Severity: Rules[rule].Severity, MessageID: id, Text: fmt.Sprintf(format, args...)}
+}
+
+// LintMessage checks one message on its own
+func LintMessage(m Message) []Finding {
+
+	s := m.Sip
+	findings := []Finding{}
+	if s.Error != nil {
+		return append(findings, finding("parse", m.ID, "%v", s.Error))
+	}
+	if s.StartLine == nil {
+		return findings
+	}
+	request := s.StartLine.Type == sipparser.SIP_REQUEST
+	method := s.StartLine.Method
+
+	mandatory := []struct {
+		name string
+		ok   bool
+	}{
+		{"Via", len(s.Via) > 0},
+		{"From", s.From != nil},
+		{"To", s.To != nil},
+		{"Call-ID", s.CallID != ""},
+		{"CSeq", s.Cseq != nil},
+		{"Max-Forwards", !request || s.MaxForwards != ""},
+	}
+	for _, h := range mandatory {
+		if !h.ok {
+			findings = append(findings, finding("mandatory-header", m.ID, "no %s header", h.name))
+		}
+	}
+
+	if len(s.Via) > 0 {
+		top := s.Via[0]
+		if request && !strings.HasPrefix(top.Branch, "z9hG4bK") {
+			findings = append(findings, finding("via-branch-cookie", m.ID, "branch %q", top.Branch))
+		}
+		for _, v := range s.Via {
+			if v.Received != "" && net.ParseIP(v.Received) == nil {
+				findings = append(findings, finding("via-received", m.ID, "received=%s", v.Received))
+			}
+			if port, err := strconv.Atoi(v.RPort); v.RPort != "" && (err != nil || port < 1 || port > 65535) {
+				findings = append(findings, finding("via-rport", m.ID, "rport=%s", v.RPort))
+			}
+		}
+	}
+
+	if request && s.MaxForwards != "" && (s.MaxForwardsInt < 0 || s.MaxForwardsInt > 255 || strconv.Itoa(s.MaxForwardsInt) != s.MaxForwards) {
+		findings = append(findings, finding("max-forwards", m.ID, "Max-Forwards: %s", s.MaxForwards))
+	}
+	if request && s.Cseq != nil && s.CseqMethod != method {
+		findings = append(findings, finding("cseq-method", m.ID, "CSeq method %s in a %s", s.CseqMethod, method))
+	}
+	if s.ContentLength != "" && s.ContentLengthInt != len(s.Body) {
+		findings = append(findings, finding("content-length", m.ID, "Content-Length %s, body of %d bytes", s.ContentLength, len(s.Body)))
+	}
+	if s.Body != "" && s.ContentType == "" {
+		findings = append(findings, finding("content-type", m.ID, "body of %d bytes", len(s.Body)))
+	}
+
+	if request {
+		switch method {
+		case sipparser.SIP_METHOD_INVITE, sipparser.SIP_METHOD_SUBSCRIBE, sipparser.SIP_METHOD_REFER:
+			if s.Contact == nil {
+				findings = append(findings, finding("contact-missing", m.ID, "%s without Contact", method))
+			}
+		case sipparser.SIP_METHOD_UPDATE:
+			if s.Contact == nil {
+				findings = append(findings, finding("update-contact", m.ID, "UPDATE without Contact"))
+			}
+		case sipparser.SIP_METHOD_PRACK:
+			if s.Rack == nil || s.Rack.CseqMethod == "" {
+				findings = append(findings, finding("prack-rack", m.ID, "RAck %q", s.HeaderValues("RAck")))
+			}
+		}
+		return findings
+	}
+
+	code, _ := strconv.Atoi(s.StartLine.Resp)
+	if code > 100 && s.To != nil && s.ToTag == "" {
+		findings = append(findings, finding("response-to-tag", m.ID, "%d without To tag", code))
+	}
+	if code > 100 && code < 200 && s.Rseq != "" && !hasOption(s.Require, "100rel") {
+		findings = append(findings, finding("rel1xx-require", m.ID, "RSeq %s", s.Rseq))
+	}
+
+	return findings
+}
+
+func hasOption(options []string, option string) bool {
+	for _, val := range options {
+		if strings.EqualFold(val, option) {
+			return true
+		}
+	}
+	return false
+}
+
+// LintDialog checks the messages of a call in capture order, message and dialog rules
+func LintDialog(messages []Message) []Finding {
+
+	findings := []Finding{}
+
+	type sender struct {
+		maxCseq int
+		invites map[int]bool
+	}
+	senders := map[string]*sender{}
+	seenInvite := map[string]bool{}
+	rseqs := map[string]int{}
+	reliable := map[string]bool{}
+
+	for _, m := range messages {
+		findings = append(findings, LintMessage(m)...)
+
+		s := m.Sip
+		if s.Error != nil || s.StartLine == nil || s.Cseq == nil {
+			continue
+		}
+		cseq, err := strconv.Atoi(s.Cseq.Digit)
+		if err != nil {
+			continue
+		}
+
+		if s.StartLine.Type != sipparser.SIP_REQUEST {
+			/* reliable provisional responses, by call and INVITE CSeq */
+			if s.Rseq != "" && s.CseqMethod == sipparser.SIP_METHOD_INVITE {
+				key := s.CallID + " " + s.ToTag + " " + s.Cseq.Digit
+				if last, ok := rseqs[key]; ok && s.RseqInt != last && s.RseqInt != last+1 {
+					findings = append(findings, finding("rseq-order", m.ID, "RSeq %d after %d", s.RseqInt, last))
+				}
+				rseqs[key] = s.RseqInt
+				reliable[s.CallID+" "+s.Rseq+" "+s.Cseq.Digit] = true
+			}
+			continue
+		}
+
+		method := s.StartLine.Method
+		snd, ok := senders[s.CallID+" "+s.FromTag]
+		if !ok {
+			snd = &sender{maxCseq: -1, invites: map[int]bool{}}
+			senders[s.CallID+" "+s.FromTag] = snd
+		}
+
+		switch method {
+		case sipparser.SIP_METHOD_ACK, sipparser.SIP_METHOD_CANCEL:
+			/* an ACK for a 2xx belongs to the INVITE too, retransmissions are fine */
+			if len(snd.invites) > 0 && !snd.invites[cseq] {
+				findings = append(findings, finding("ack-cseq", m.ID, "%s with CSeq %d", method, cseq))
+			}
+			continue
+		case sipparser.SIP_METHOD_INVITE:
+			if !seenInvite[s.CallID] && s.ToTag != "" {
+				findings = append(findings, finding("initial-invite-to-tag", m.ID, "To tag %s", s.ToTag))
+			}
+			seenInvite[s.CallID] = true
+			snd.invites[cseq] = true
+		case sipparser.SIP_METHOD_PRACK:
+			if s.Rack != nil && s.Rack.CseqMethod != "" && len(rseqs) > 0 && !reliable[s.CallID+" "+s.Rack.RseqVal+" "+s.Rack.CseqVal] {
+				findings = append(findings, finding("prack-unmatched", m.ID, "RAck %s", s.Rack.Val))
+			}
+		}
+
+		if cseq < snd.maxCseq {
+			findings = append(findings, finding("cseq-order", m.ID, "%s with CSeq %d after %d", method, cseq, snd.maxCseq))
+		}
+		if cseq > snd.maxCseq {
+			snd.maxCseq = cseq
+		}
+	}
+
+	return findings
+}
+
+// Count returns the number of findings of a severity
+func Count(findings []Finding, severity string) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,110 @@
+package siplint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipcapture/homer-app/utils/sipparser"
+)
+
+func testMsg(lines ...string) *sipparser.SipMsg {
+	return sipparser.ParseMsg(strings.Join(lines, "\r\n")+"\r\n\r\n", nil, nil)
+}
+
+func testInvite(extra ...string) *sipparser.SipMsg {
+	return testMsg(append([]string{
+		"INVITE sip:bob@example.com SIP/2.0",
+		"Via: SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK74bf9;rport",
+		"Max-Forwards: 70",
+		"From: <sip:alice@example.org>;tag=9fxced76sl",
+		"To: <sip:bob@example.com>",
+		"Call-ID: lint-1",
+		"CSeq: 1 INVITE",
+		"Contact: <sip:alice@192.0.2.1>",
+		"Content-Length: 0",
+	}, extra...)...)
+}
+
+func rules(findings []Finding) string {
+	var ids []string
+	for _, f := range findings {
+		ids = append(ids, f.Rule)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestLintMessage(t *testing.T) {
+	if f := LintMessage(Message{ID: 1, Sip: testInvite()}); len(f) != 0 {
+		t.Errorf("[TestLintMessage] Expected no findings, got: %v", f)
+	}
+
+	tests := []struct {
+		msg   *sipparser.SipMsg
+		rules string
+	}{
+		{testMsg("INVITE sip:bob@example.com SIP/2.0", "Via: SIP/2.0/UDP 192.0.2.1;branch=74bf9;received=host.example.org;rport=abc",
+			"From: <sip:alice@example.org>;tag=1", "To: <sip:bob@example.com>", "Call-ID: lint-2", "CSeq: 1 ACK", "Max-Forwards: 300", "Content-Length: 10"),
+			"via-branch-cookie,via-received,via-rport,max-forwards,cseq-method,content-length,contact-missing"},
+		{testMsg("BYE sip:bob@example.com SIP/2.0", "Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK1", "Call-ID: lint-3", "CSeq: 2 BYE"),
+			"mandatory-header,mandatory-header,mandatory-header"},
+		{testMsg("SIP/2.0 183 Session Progress", "Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK1", "From: <sip:alice@example.org>;tag=1",
+			"To: <sip:bob@example.com>", "Call-ID: lint-4", "CSeq: 1 INVITE", "RSeq: 1"),
+			"response-to-tag,rel1xx-require"},
+		{testMsg("UPDATE sip:bob@example.com SIP/2.0", "Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK1", "Max-Forwards: 70", "From: <sip:alice@example.org>;tag=1",
+			"To: <sip:bob@example.com>;tag=2", "Call-ID: lint-5", "CSeq: 3 UPDATE"),
+			"update-contact"},
+		{testMsg("INVITE sip:bob@example.com SIP/2.0", "CSeq: x"), "parse"},
+	}
+	for i, val := range tests {
+		if got := rules(LintMessage(Message{ID: float64(i), Sip: val.msg})); got != val.rules {
+			t.Errorf("[TestLintMessage] %d: expected %s, got %s", i, val.rules, got)
+		}
+	}
+
+	f := LintMessage(Message{ID: 7, Sip: tests[0].msg})
+	if f[0].MessageID != 7 || f[0].Severity != SeverityError || f[0].Text != `branch "74bf9"` {
+		t.Errorf("[TestLintMessage] Bad finding: %+v", f[0])
+	}
+}
+
+func TestLintDialog(t *testing.T) {
+	response := func(code, cseq string, extra ...string) *sipparser.SipMsg {
+		return testMsg(append([]string{"SIP/2.0 " + code, "Via: SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK74bf9;received=192.0.2.1",
+			"From: <sip:alice@example.org>;tag=9fxced76sl", "To: <sip:bob@example.com>;tag=8321234356", "Call-ID: lint-1", "CSeq: " + cseq,
+			"Contact: <sip:bob@192.0.2.4>", "Content-Length: 0"}, extra...)...)
+	}
+	request := func(method, cseq string, extra ...string) *sipparser.SipMsg {
+		return testMsg(append([]string{method + " sip:bob@192.0.2.4 SIP/2.0", "Via: SIP/2.0/UDP 192.0.2.1:5060;branch=z9hG4bK" + method,
+			"Max-Forwards: 70", "From: <sip:alice@example.org>;tag=9fxced76sl", "To: <sip:bob@example.com>;tag=8321234356",
+			"Call-ID: lint-1", "CSeq: " + cseq, "Contact: <sip:alice@192.0.2.1>", "Content-Length: 0"}, extra...)...)
+	}
+
+	good := []Message{
+		{1, testInvite()},
+		{2, response("183 Session Progress", "1 INVITE", "Require: 100rel", "RSeq: 1")},
+		{3, request("PRACK", "2 PRACK", "RAck: 1 1 INVITE")},
+		{4, response("200 OK", "2 PRACK")},
+		{5, response("200 OK", "1 INVITE")},
+		{6, request("ACK", "1 ACK")},
+		{7, request("BYE", "3 BYE")},
+	}
+	if f := LintDialog(good); len(f) != 0 {
+		t.Errorf("[TestLintDialog] Expected no findings, got: %v", f)
+	}
+
+	bad := []Message{
+		{1, request("INVITE", "5 INVITE")},
+		{2, response("183 Session Progress", "5 INVITE", "Require: 100rel", "RSeq: 1")},
+		{3, response("183 Session Progress", "5 INVITE", "Require: 100rel", "RSeq: 3")},
+		{4, request("PRACK", "6 PRACK", "RAck: 2 5 INVITE")},
+		{5, request("ACK", "4 ACK")},
+		{6, request("BYE", "4 BYE")},
+	}
+	f := LintDialog(bad)
+	if got := rules(f); got != "initial-invite-to-tag,rseq-order,prack-unmatched,ack-cseq,cseq-order" {
+		t.Errorf("[TestLintDialog] Bad findings: %s", got)
+	}
+	if Count(f, SeverityError) != 3 || Count(f, SeverityWarning) != 2 || f[4].MessageID != 6 {
+		t.Errorf("[TestLintDialog] Bad severities or ids: %+v", f)
+	}
+}
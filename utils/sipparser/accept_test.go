@@ -6,8 +6,11 @@
 package sipparser
 
 // Imports from the go standard library
+import (
+	"testing"
+)
 
-/* // TestAccept tests the accept header and parsing functions
+// TestAccept tests the accept header and parsing functions
 func TestAccept(t *testing.T) {
 	sm := &SipMsg{}
 	s := "application/sdp"
@@ -25,4 +28,3 @@ func TestAccept(t *testing.T) {
 		t.Errorf("[TestAccept] Error parsing accept hdr: application/sdp.  sm.Accept.Params[0].Val should be \"sdp\" but received: %q", sm.Accept.Params[0].Val)
 	}
 }
-*/
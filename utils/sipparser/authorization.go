@@ -17,10 +17,9 @@ type Authorization struct {
 	Val         string
 	Credentials string
 	Username    string
-	//Params      []*Param
+	Params      []*Param
 }
 
-/*
 func (a *Authorization) GetParam(param string) *Param {
 	if a.Params == nil {
 		return nil
@@ -32,7 +31,7 @@ func (a *Authorization) GetParam(param string) *Param {
 	}
 	return nil
 }
-*/
+
 func (a *Authorization) parse() error {
 	pos := strings.IndexRune(a.Val, ' ')
 	if pos == -1 {
@@ -43,15 +42,14 @@ func (a *Authorization) parse() error {
 		return errors.New("Authorization.parse err: no digest-resp found")
 	}
 	a.Username = internal.ExtractSIPParam("username=\"", a.Val)
-	/*
-		a.Params = make([]*Param, 0)
-		parts := strings.Split(a.Val[pos+1:], ",")
-		for i := range parts {
-			a.Params = append(a.Params, getParam(strings.Replace(parts[i], "\"", "", -1)))
-		}
-		if a.GetParam("username") != nil {
-			a.Username = a.GetParam("username").Val
+	a.Params = make([]*Param, 0)
+	for _, part := range splitQuoted(a.Val[pos+1:], ',') {
+		if part = cleanWs(part); part != "" {
+			a.Params = append(a.Params, getParam(strings.Replace(part, "\"", "", -1)))
 		}
-	*/
+	}
+	if a.Username == "" && a.GetParam("username") != nil {
+		a.Username = a.GetParam("username").Val
+	}
 	return nil
 }
@@ -0,0 +1,58 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"testing"
+)
+
+// Run with: go test -run NONE -bench . -benchmem ./utils/sipparser/
+// The full parser is the reference, the lazy one reads what getTransactionSummary needs.
+
+var benchMessages = []struct {
+	name string
+	msg  string
+}{
+	{"INVITE", testMsgInvite},
+	{"200", testMsgOk},
+	{"REGISTER", testMsgRegister},
+}
+
+func BenchmarkParseMsg(b *testing.B) {
+	for _, bm := range benchMessages {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(bm.msg)))
+			for i := 0; i < b.N; i++ {
+				ParseMsg(bm.msg, nil, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkParseMsgLazy(b *testing.B) {
+	for _, bm := range benchMessages {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(bm.msg)))
+			for i := 0; i < b.N; i++ {
+				ParseMsgLazy(bm.msg, "From", "To")
+			}
+		})
+	}
+}
+
+func BenchmarkParseMsgLazyStartLine(b *testing.B) {
+	for _, bm := range benchMessages {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ParseMsgLazy(bm.msg)
+			}
+		})
+	}
+}
@@ -6,8 +6,11 @@
 package sipparser
 
 // Imports from the go standard library
+import (
+	"testing"
+)
 
-/* func TestContentDisposition(t *testing.T) {
+func TestContentDisposition(t *testing.T) {
 	sm := &SipMsg{}
 	s := "session; handling=required"
 	sm.parseContentDisposition(s)
@@ -27,4 +30,3 @@ package sipparser
 		t.Errorf("[TestContentDisposition] Error parsing content-disposition hdr: session; handling=required.  sm.ContentDisposition.Params[0].Val should be \"required\" but received: \"%s\"", sm.ContentDisposition.Params[0].Val)
 	}
 }
-*/
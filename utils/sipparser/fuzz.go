@@ -3,6 +3,13 @@
 package sipparser
 
 func Fuzz(data []byte) int {
-	ParseMsg(string(data))
+	ParseMsg(string(data), nil, nil)
 	return 0
 }
+
+func FuzzSdp(data []byte) int {
+	if s := ParseSdp(string(data)); s.Error != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,156 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Identity is an Identity header (RFC 8224) carrying a PASSporT (RFC 8225),
+// usually with the shaken extension (RFC 8588)
+type Identity struct {
+	Val          string
+	Token        string
+	Info         string
+	Alg          string
+	Ppt          string
+	Header       *PassportHeader
+	Payload      *PassportPayload
+	SigningInput string
+	Signature    []byte
+	Error        error
+}
+
+// PassportHeader is the JOSE header of a PASSporT
+type PassportHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Ppt string `json:"ppt,omitempty"`
+	X5u string `json:"x5u"`
+}
+
+// PassportPayload are the claims of a PASSporT
+type PassportPayload struct {
+	Attest string        `json:"attest,omitempty"`
+	Dest   PassportDest  `json:"dest"`
+	Iat    int64         `json:"iat"`
+	Orig   PassportOrig  `json:"orig"`
+	OrigID string        `json:"origid,omitempty"`
+	Div    *PassportOrig `json:"div,omitempty"`
+}
+
+// PassportOrig is the originating identity, either a telephone number or an URI
+type PassportOrig struct {
+	TN  string `json:"tn,omitempty"`
+	URI string `json:"uri,omitempty"`
+}
+
+// PassportDest are the destination identities
+type PassportDest struct {
+	TN  []string `json:"tn,omitempty"`
+	URI []string `json:"uri,omitempty"`
+}
+
+// DestTN returns the first destination number
+func (p *PassportPayload) DestTN() string {
+	if len(p.Dest.TN) == 0 {
+		return ""
+	}
+	return p.Dest.TN[0]
+}
+
+func (s *SipMsg) parseIdentity(str string) error {
+	id := &Identity{Val: str}
+	s.Identity = append(s.Identity, id)
+	id.Error = id.parse()
+	return id.Error
+}
+
+func (id *Identity) parse() error {
+
+	parts := strings.Split(id.Val, ";")
+	id.Token = cleanWs(parts[0])
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		val := strings.Trim(cleanWs(kv[1]), "\"<>")
+		switch strings.ToLower(cleanWs(kv[0])) {
+		case "info":
+			id.Info = val
+		case "alg":
+			id.Alg = val
+		case "ppt":
+			id.Ppt = val
+		}
+	}
+
+	segments := strings.Split(id.Token, ".")
+	if len(segments) != 3 {
+		return fmt.Errorf("parseIdentity err: PASSporT has %d segments, want 3", len(segments))
+	}
+	if segments[1] == "" {
+		return errors.New("parseIdentity err: compact form PASSporT is not supported")
+	}
+
+	header := &PassportHeader{}
+	if err := decodeSegment(segments[0], header); err != nil {
+		return fmt.Errorf("parseIdentity err: header: %v", err)
+	}
+	id.Header = header
+
+	payload := &PassportPayload{}
+	if err := decodeSegment(segments[1], payload); err != nil {
+		return fmt.Errorf("parseIdentity err: payload: %v", err)
+	}
+	id.Payload = payload
+
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segments[2], "="))
+	if err != nil {
+		return fmt.Errorf("parseIdentity err: signature: %v", err)
+	}
+	id.Signature = signature
+	id.SigningInput = segments[0] + "." + segments[1]
+
+	/* the header parameters default to the token */
+	if id.Alg == "" {
+		id.Alg = header.Alg
+	}
+	if id.Ppt == "" {
+		id.Ppt = header.Ppt
+	}
+	if id.Info == "" {
+		id.Info = header.X5u
+	}
+	return nil
+}
+
+func decodeSegment(str string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(str, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Shaken returns the first Identity with the shaken extension, or the first one
+func (s *SipMsg) Shaken() *Identity {
+	for _, id := range s.Identity {
+		if id.Ppt == "shaken" {
+			return id
+		}
+	}
+	if len(s.Identity) > 0 {
+		return s.Identity[0]
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"testing"
+)
+
+// the example of RFC 8588 with its header
+var testIdentity = "eyJhbGciOiJFUzI1NiIsInBwdCI6InNoYWtlbiIsInR5cCI6InBhc3Nwb3J0IiwieDV1IjoiaHR0cHM6Ly9jZXJ0LmV4YW1wbGUub3JnL3Bhc3Nwb3J0LmNlciJ9" +
+	".eyJhdHRlc3QiOiJBIiwiZGVzdCI6eyJ0biI6WyIxMjE1NTU1MTIxMyJdfSwiaWF0IjoxNDQzMjA4MzQ1LCJvcmlnIjp7InRuIjoiMTIxNTU1NTEyMTIifSwib3JpZ2lkIjoiMTIzZTQ1NjctZTg5Yi0xMmQzLWE0NTYtNDI2NjU1NDQwMDAwIn0" +
+	".c2ln;info=<https://cert.example.org/passport.cer>;alg=ES256;ppt=\"shaken\""
+
+func TestParseIdentity(t *testing.T) {
+	s := ParseMsg("INVITE sip:+12155551213@example.org SIP/2.0\r\n"+
+		"Call-ID: identity-1\r\n"+
+		"y: "+testIdentity+"\r\n"+
+		"Identity: abc;info=<https://cert.example.org/other.cer>\r\n"+
+		"\r\n", nil, nil)
+	if s.Error != nil {
+		t.Fatalf("[TestParseIdentity] Unexpected err: %v", s.Error)
+	}
+	if len(s.Identity) != 2 || s.Headers[1].Error != nil || s.Headers[2].Error == nil {
+		t.Fatalf("[TestParseIdentity] Expected a good and a broken identity, got: %d", len(s.Identity))
+	}
+
+	id := s.Shaken()
+	if id != s.Identity[0] || id.Info != "https://cert.example.org/passport.cer" || id.Alg != "ES256" || id.Ppt != "shaken" {
+		t.Errorf("[TestParseIdentity] Bad identity params: %+v", id)
+	}
+	if id.Header.X5u != "https://cert.example.org/passport.cer" || id.Header.Typ != "passport" {
+		t.Errorf("[TestParseIdentity] Bad PASSporT header: %+v", id.Header)
+	}
+	p := id.Payload
+	if p.Attest != "A" || p.Orig.TN != "12155551212" || p.DestTN() != "12155551213" || p.Iat != 1443208345 || p.OrigID != "123e4567-e89b-12d3-a456-426655440000" {
+		t.Errorf("[TestParseIdentity] Bad PASSporT payload: %+v", p)
+	}
+	if string(id.Signature) != "sig" {
+		t.Errorf("[TestParseIdentity] Bad signature: %q", id.Signature)
+	}
+}
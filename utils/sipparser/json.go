@@ -0,0 +1,333 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/sipcapture/homer-app/utils/isup"
+)
+
+// jsonMessage is the JSON form of a parsed message, the flat helper
+// fields of SipMsg are left out
+type jsonMessage struct {
+	Type               string          `json:"type,omitempty"`
+	Method             string          `json:"method,omitempty"`
+	RequestURI         *jsonURI        `json:"request_uri,omitempty"`
+	Code               int             `json:"code,omitempty"`
+	ReasonPhrase       string          `json:"reason_phrase,omitempty"`
+	Version            string          `json:"version,omitempty"`
+	Headers            []jsonHeader    `json:"headers"`
+	Via                []jsonVia       `json:"via,omitempty"`
+	From               *jsonAddress    `json:"from,omitempty"`
+	To                 *jsonAddress    `json:"to,omitempty"`
+	Contact            *jsonAddress    `json:"contact,omitempty"`
+	PAssertedIdentity  *jsonAddress    `json:"p_asserted_identity,omitempty"`
+	CallID             string          `json:"call_id,omitempty"`
+	CSeq               *jsonCSeq       `json:"cseq,omitempty"`
+	MaxForwards        *int            `json:"max_forwards,omitempty"`
+	ContentType        string          `json:"content_type,omitempty"`
+	ContentLength      *int            `json:"content_length,omitempty"`
+	ContentDisposition *jsonParams     `json:"content_disposition,omitempty"`
+	Route              []*jsonURI      `json:"route,omitempty"`
+	RecordRoute        []*jsonURI      `json:"record_route,omitempty"`
+	Require            []string        `json:"require,omitempty"`
+	ProxyRequire       []string        `json:"proxy_require,omitempty"`
+	Supported          []string        `json:"supported,omitempty"`
+	Unsupported        []string        `json:"unsupported,omitempty"`
+	Allow              []string        `json:"allow,omitempty"`
+	AllowEvents        []string        `json:"allow_events,omitempty"`
+	Accept             []string        `json:"accept,omitempty"`
+	Authorization      *jsonParams     `json:"authorization,omitempty"`
+	ProxyAuthenticate  *jsonParams     `json:"proxy_authenticate,omitempty"`
+	WWWAuthenticate    *jsonParams     `json:"www_authenticate,omitempty"`
+	RAck               *jsonRAck       `json:"rack,omitempty"`
+	RSeq               *int            `json:"rseq,omitempty"`
+	Reason             *jsonReason     `json:"reason,omitempty"`
+	Warning            *jsonWarning    `json:"warning,omitempty"`
+	Identity           []*jsonIdentity `json:"identity,omitempty"`
+	Expires            string          `json:"expires,omitempty"`
+	Subject            string          `json:"subject,omitempty"`
+	Organization       string          `json:"organization,omitempty"`
+	Privacy            string          `json:"privacy,omitempty"`
+	AlertInfo          string          `json:"alert_info,omitempty"`
+	UserAgent          string          `json:"user_agent,omitempty"`
+	Server             string          `json:"server,omitempty"`
+	Body               string          `json:"body,omitempty"`
+	Parts              []*jsonPart     `json:"parts,omitempty"`
+	Sdp                *Sdp            `json:"sdp,omitempty"`
+	Isup               *isup.Message   `json:"isup,omitempty"`
+	BodyError          string          `json:"body_error,omitempty"`
+	Error              string          `json:"error,omitempty"`
+}
+
+type jsonHeader struct {
+	Name     string `json:"name"`
+	LongName string `json:"long_name,omitempty"`
+	Value    string `json:"value"`
+	Error    string `json:"error,omitempty"`
+}
+
+type jsonIdentity struct {
+	Info   string   `json:"info,omitempty"`
+	Alg    string   `json:"alg,omitempty"`
+	Ppt    string   `json:"ppt,omitempty"`
+	X5u    string   `json:"x5u,omitempty"`
+	Attest string   `json:"attest,omitempty"`
+	OrigTN string   `json:"orig_tn,omitempty"`
+	DestTN []string `json:"dest_tn,omitempty"`
+	OrigID string   `json:"origid,omitempty"`
+	Iat    int64    `json:"iat,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type jsonPart struct {
+	ContentType string        `json:"content_type"`
+	Headers     []*jsonHeader `json:"headers,omitempty"`
+	Body        string        `json:"body"`
+}
+
+type jsonURI struct {
+	URI    string `json:"uri"`
+	Scheme string `json:"scheme,omitempty"`
+	User   string `json:"user,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Port   int    `json:"port,omitempty"`
+}
+
+type jsonAddress struct {
+	Name string   `json:"name,omitempty"`
+	URI  *jsonURI `json:"uri,omitempty"`
+	Tag  string   `json:"tag,omitempty"`
+}
+
+type jsonVia struct {
+	Protocol  string            `json:"protocol"`
+	Transport string            `json:"transport"`
+	SentBy    string            `json:"sent_by"`
+	Branch    string            `json:"branch,omitempty"`
+	Received  string            `json:"received,omitempty"`
+	RPort     string            `json:"rport,omitempty"`
+	Params    map[string]string `json:"params,omitempty"`
+}
+
+type jsonCSeq struct {
+	Seq    string `json:"seq"`
+	Method string `json:"method"`
+}
+
+type jsonParams struct {
+	Value  string            `json:"value"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+type jsonRAck struct {
+	RSeq   string `json:"rseq"`
+	CSeq   string `json:"cseq"`
+	Method string `json:"method"`
+}
+
+type jsonReason struct {
+	Protocol string `json:"protocol"`
+	Cause    string `json:"cause,omitempty"`
+	Text     string `json:"text,omitempty"`
+}
+
+type jsonWarning struct {
+	Code  int    `json:"code"`
+	Agent string `json:"agent"`
+	Text  string `json:"text"`
+}
+
+// MarshalJSON returns the complete parsed message, used by the decode API
+func (s *SipMsg) MarshalJSON() ([]byte, error) {
+
+	m := jsonMessage{
+		Headers:      []jsonHeader{},
+		CallID:       s.CallID,
+		ContentType:  s.ContentType,
+		Require:      s.Require,
+		ProxyRequire: s.ProxyRequire,
+		Supported:    s.Supported,
+		Unsupported:  s.Unsupported,
+		Allow:        s.Allow,
+		AllowEvents:  s.AllowEvents,
+		Expires:      s.Expires,
+		Subject:      s.Subject,
+		Organization: s.Organization,
+		Privacy:      s.Privacy,
+		AlertInfo:    s.AlertInfo,
+		UserAgent:    s.UserAgent,
+		Server:       s.Server,
+		Sdp:          s.Sdp,
+		Isup:         s.Isup,
+	}
+
+	/* binary bodies are shown as hex dumps, JSON can't carry them */
+	if s.Body != "" {
+		m.Body = s.PrintableMsg()[len(s.Msg)-len(s.Body):]
+	}
+	for _, p := range s.Parts {
+		part := &jsonPart{ContentType: p.ContentType, Body: p.Body}
+		if isBinaryContentType(p.ContentType) {
+			part.Body = isup.HexDump([]byte(p.Body))
+		}
+		for _, h := range p.Headers {
+			part.Headers = append(part.Headers, &jsonHeader{Name: h.Header, Value: h.Val})
+		}
+		m.Parts = append(m.Parts, part)
+	}
+	if s.BodyError != nil {
+		m.BodyError = s.BodyError.Error()
+	}
+
+	if s.Error != nil {
+		m.Error = s.Error.Error()
+	}
+
+	if sl := s.StartLine; sl != nil {
+		m.Type = sl.Type
+		m.Method = sl.Method
+		m.RequestURI = newJSONURI(sl.URI)
+		m.Code, _ = strconv.Atoi(sl.Resp)
+		m.ReasonPhrase = sl.RespText
+		if sl.Proto != "" {
+			m.Version = sl.Proto + "/" + sl.Version
+		}
+	}
+
+	for _, h := range s.Headers {
+		header := jsonHeader{Name: h.Header, Value: h.Val}
+		if h.Compact {
+			header.LongName = h.Name
+		}
+		if h.Error != nil {
+			header.Error = h.Error.Error()
+		}
+		m.Headers = append(m.Headers, header)
+	}
+
+	for _, v := range s.Via {
+		m.Via = append(m.Via, jsonVia{
+			Protocol:  v.Proto + "/" + v.Version,
+			Transport: v.Transport,
+			SentBy:    v.SentBy,
+			Branch:    v.Branch,
+			Received:  v.Received,
+			RPort:     v.RPort,
+			Params:    paramsMap(v.Params),
+		})
+	}
+
+	m.From = newJSONAddress(s.From)
+	m.To = newJSONAddress(s.To)
+	m.Contact = newJSONAddress(s.Contact)
+	if p := s.PAssertedId; p != nil && p.Error == nil {
+		m.PAssertedIdentity = &jsonAddress{Name: p.Name, URI: newJSONURI(p.URI)}
+	}
+
+	if s.Cseq != nil {
+		m.CSeq = &jsonCSeq{Seq: s.Cseq.Digit, Method: s.Cseq.Method}
+	}
+	if s.MaxForwards != "" {
+		m.MaxForwards = &s.MaxForwardsInt
+	}
+	if s.ContentLength != "" {
+		m.ContentLength = &s.ContentLengthInt
+	}
+	if s.Rseq != "" {
+		m.RSeq = &s.RseqInt
+	}
+	if cd := s.ContentDisposition; cd != nil {
+		m.ContentDisposition = &jsonParams{Value: cd.DispType, Params: paramsMap(cd.Params)}
+	}
+
+	for _, u := range s.Route {
+		m.Route = append(m.Route, newJSONURI(u))
+	}
+	for _, u := range s.RecordRoute {
+		m.RecordRoute = append(m.RecordRoute, newJSONURI(u))
+	}
+
+	if s.Accept != nil {
+		for _, p := range s.Accept.Params {
+			m.Accept = append(m.Accept, p.Type+"/"+p.Val)
+		}
+	}
+
+	m.Authorization = newJSONAuth(s.Authorization)
+	m.ProxyAuthenticate = newJSONAuth(s.ProxyAuthenticate)
+	m.WWWAuthenticate = newJSONAuth(s.WWWAuthenticate)
+
+	if r := s.Rack; r != nil && r.CseqMethod != "" {
+		m.RAck = &jsonRAck{RSeq: r.RseqVal, CSeq: r.CseqVal, Method: r.CseqMethod}
+	}
+	if r := s.Reason; r != nil {
+		m.Reason = &jsonReason{Protocol: r.Proto, Cause: r.Cause, Text: r.Text}
+	}
+	if w := s.Warning; w != nil && w.Code != "" {
+		m.Warning = &jsonWarning{Code: w.CodeInt, Agent: w.Agent, Text: w.Text}
+	}
+	for _, id := range s.Identity {
+		identity := &jsonIdentity{Info: id.Info, Alg: id.Alg, Ppt: id.Ppt}
+		if id.Header != nil {
+			identity.X5u = id.Header.X5u
+		}
+		if p := id.Payload; p != nil {
+			identity.Attest = p.Attest
+			identity.OrigTN = p.Orig.TN
+			identity.DestTN = p.Dest.TN
+			identity.OrigID = p.OrigID
+			identity.Iat = p.Iat
+		}
+		if id.Error != nil {
+			identity.Error = id.Error.Error()
+		}
+		m.Identity = append(m.Identity, identity)
+	}
+
+	return json.Marshal(m)
+}
+
+func newJSONURI(u *URI) *jsonURI {
+	if u == nil {
+		return nil
+	}
+	uri := u.Raw
+	if u.Scheme != "" {
+		uri = u.Scheme + ":" + u.Raw
+	}
+	return &jsonURI{URI: uri, Scheme: u.Scheme, User: u.User, Host: u.Host, Port: u.PortInt}
+}
+
+func newJSONAddress(f *From) *jsonAddress {
+	if f == nil || f.Error != nil {
+		return nil
+	}
+	return &jsonAddress{Name: f.Name, URI: newJSONURI(f.URI), Tag: f.Tag}
+}
+
+func newJSONAuth(a *Authorization) *jsonParams {
+	if a == nil || a.Credentials == "" {
+		return nil
+	}
+	return &jsonParams{Value: a.Credentials, Params: paramsMap(a.Params)}
+}
+
+func paramsMap(params []*Param) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(params))
+	for _, p := range params {
+		if p.Param != "" {
+			m[p.Param] = p.Val
+		}
+	}
+	return m
+}
@@ -0,0 +1,85 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"errors"
+	"strings"
+)
+
+// ParseMsgLazy parses the start line and only the listed headers, given in their
+// long form. Headers are matched in long and compact form, the others are skipped
+// without being split or copied, values are slices of str. The body is only parsed
+// when Content-Type is listed.
+func ParseMsgLazy(str string, headers ...string) *SipMsg {
+
+	s := &SipMsg{Msg: str, eof: strings.Index(str, "\r\n\r\n")}
+	if s.eof == -1 {
+		s.eof = strings.LastIndex(str, "\r\n")
+	}
+	if s.eof == -1 {
+		s.Error = errors.New("ParseMsgLazy: err parsing msg no SIP eof found")
+		return s
+	}
+	if len(str)-1 > s.eof+4 {
+		s.Body = str[s.eof+4:]
+	}
+
+	wantBody := false
+	for _, name := range headers {
+		if strings.EqualFold(name, "content-type") {
+			wantBody = true
+		}
+	}
+
+	end := s.eof + 2
+	for pos := 0; pos < end; {
+		line := str[pos:end]
+		if nl := strings.IndexByte(line, '\n'); nl > -1 {
+			line = line[:nl]
+			pos += nl + 1
+		} else {
+			pos = end
+		}
+		line = cleanWs(strings.TrimSuffix(line, "\r"))
+
+		if s.StartLine == nil {
+			s.parseStartLine(line)
+		} else if lazyWanted(line, headers) {
+			s.addHdr(line)
+		}
+		if s.Error != nil {
+			return s
+		}
+	}
+
+	if wantBody && s.Body != "" {
+		s.parseBody()
+	}
+	return s
+}
+
+func lazyWanted(line string, headers []string) bool {
+
+	colon := strings.IndexByte(line, ':')
+	if colon < 1 {
+		return false
+	}
+	name := cleanWs(line[:colon])
+	if len(name) == 1 {
+		/* single byte strings don't allocate */
+		if long, ok := compactHeaders[string(name[0]|0x20)]; ok {
+			name = long
+		}
+	}
+	for _, val := range headers {
+		if strings.EqualFold(name, val) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"testing"
+)
+
+var testMsgOk = "SIP/2.0 200 OK\r\n" +
+	"Via: SIP/2.0/UDP server10.biloxi.example.com;branch=z9hG4bKnashds8;received=192.0.2.3\r\n" +
+	"Via: SIP/2.0/UDP bigbox3.site3.atlanta.example.com;branch=z9hG4bK77ef4c2312983.1;received=192.0.2.2\r\n" +
+	"Via: SIP/2.0/UDP pc33.atlanta.example.com;branch=z9hG4bK776asdhds;received=192.0.2.1\r\n" +
+	"To: Bob <sip:bob@biloxi.example.com>;tag=a6c85cf\r\n" +
+	"From: Alice <sip:alice@atlanta.example.com>;tag=1928301774\r\n" +
+	"Call-ID: a84b4c76e66710@pc33.atlanta.example.com\r\n" +
+	"CSeq: 314159 INVITE\r\n" +
+	"Contact: <sip:bob@192.0.2.4>\r\n" +
+	"Record-Route: <sip:proxy.example.com;lr>\r\n" +
+	"Allow: INVITE, ACK, CANCEL, BYE, PRACK\r\n" +
+	"Supported: timer\r\n" +
+	"Server: Bob's phone\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"Content-Length: 131\r\n" +
+	"\r\n" +
+	"v=0\r\n" +
+	"o=bob 2890844527 2890844527 IN IP4 192.0.2.4\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 192.0.2.4\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 3456 RTP/AVP 0 8\r\n" +
+	"a=rtpmap:0 PCMU/8000\r\n"
+
+var testMsgRegister = "REGISTER sip:registrar.biloxi.example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP bobspc.biloxi.example.com:5060;branch=z9hG4bKnashds7\r\n" +
+	"Max-Forwards: 70\r\n" +
+	"To: Bob <sip:bob@biloxi.example.com>\r\n" +
+	"From: Bob <sip:bob@biloxi.example.com>;tag=456248\r\n" +
+	"Call-ID: 843817637684230@998sdasdh09\r\n" +
+	"CSeq: 1826 REGISTER\r\n" +
+	"Contact: <sip:bob@192.0.2.4>\r\n" +
+	"Expires: 7200\r\n" +
+	"Authorization: Digest username=\"bob\", realm=\"biloxi.example.com\", nonce=\"dcd98b7102dd2f0e8b11d0f600bfb0c093\", uri=\"sip:registrar.biloxi.example.com\", response=\"245f23415f11432b3434341c022\"\r\n" +
+	"User-Agent: Softphone Beta1.5\r\n" +
+	"Content-Length: 0\r\n" +
+	"\r\n"
+
+func TestParseMsgLazy(t *testing.T) {
+	for _, msg := range []string{testMsgInvite, testMsgOk, testMsgRegister} {
+		full := ParseMsg(msg, nil, nil)
+		lazy := ParseMsgLazy(msg, "From", "To", "Call-ID")
+		if lazy.Error != nil {
+			t.Fatalf("[TestParseMsgLazy] Unexpected err: %v", lazy.Error)
+		}
+		if lazy.FromHost != full.FromHost || lazy.ToHost != full.ToHost || lazy.FromTag != full.FromTag || lazy.CallID != full.CallID {
+			t.Errorf("[TestParseMsgLazy] Lazy headers differ: %s %s %s", lazy.FromHost, lazy.ToHost, lazy.CallID)
+		}
+		if lazy.FirstMethod != full.FirstMethod || lazy.FirstResp != full.FirstResp || lazy.URIUser != full.URIUser {
+			t.Errorf("[TestParseMsgLazy] Lazy start line differs: %+v", lazy.StartLine)
+		}
+		if len(lazy.Headers) != 3 || lazy.CseqMethod != "" || lazy.Via != nil || lazy.Sdp != nil {
+			t.Errorf("[TestParseMsgLazy] Parsed more than the listed headers: %d", len(lazy.Headers))
+		}
+	}
+
+	/* the body comes with Content-Type */
+	s := ParseMsgLazy(testMsgInvite, "content-type", "via")
+	if s.Sdp == nil || len(s.Via) != 2 || !s.Headers[0].Compact {
+		t.Errorf("[TestParseMsgLazy] Expected the compact via and the sdp, got: %d %v", len(s.Via), s.Sdp)
+	}
+
+	if s := ParseMsgLazy("INVITE sip:bob@example.com SIP/2.0", "From"); s.Error == nil {
+		t.Errorf("[TestParseMsgLazy] Expected an error without eof")
+	}
+}
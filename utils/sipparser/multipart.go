@@ -0,0 +1,204 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"errors"
+	"mime"
+	"strings"
+
+	"github.com/sipcapture/homer-app/utils/isup"
+)
+
+// BodyPart is one part of a multipart body. Nested multipart bodies are
+// flattened into the list.
+type BodyPart struct {
+	ContentType string
+	Headers     []*Header
+	Body        string
+	start       int
+	end         int
+}
+
+// binary bodies are shown as hex dumps
+var binaryContentTypes = []string{"application/isup", "application/qsig", "application/octet-stream"}
+
+func isBinaryContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, val := range binaryContentTypes {
+		if strings.HasPrefix(contentType, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBody splits a multipart body and decodes the SDP and ISUP parts. Body
+// problems don't make the message broken, they are kept in BodyError.
+func (s *SipMsg) parseBody() {
+
+	if !strings.HasPrefix(strings.ToLower(s.ContentType), "multipart/") {
+		s.parseBodyPart(s.ContentType, s.Body)
+		return
+	}
+
+	parts, err := parseMultipart(s.ContentType, s.Body, 0, 0)
+	s.Parts = parts
+	if err != nil {
+		s.BodyError = err
+	}
+	for _, p := range parts {
+		s.parseBodyPart(p.ContentType, p.Body)
+	}
+}
+
+func (s *SipMsg) parseBodyPart(contentType, body string) {
+
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/sdp":
+		if s.Sdp == nil {
+			s.Sdp = ParseSdp(body)
+		}
+	case "application/isup":
+		if s.Isup != nil {
+			return
+		}
+		if strings.HasPrefix(strings.ToLower(params["version"]), "ansi") {
+			s.BodyError = errors.New("parseBody err: ANSI ISUP is not supported")
+			return
+		}
+		m, err := isup.Decode([]byte(body))
+		s.Isup = m
+		if err != nil {
+			s.BodyError = err
+		}
+	}
+}
+
+// parseMultipart returns the parts of a body with the boundary of the content type,
+// the parts found before an error are returned with it
+func parseMultipart(contentType, body string, offset, depth int) ([]*BodyPart, error) {
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("parseMultipart err: no boundary")
+	}
+
+	delim := "--" + boundary
+	pos := strings.Index(body, delim)
+	if pos == -1 {
+		return nil, errors.New("parseMultipart err: boundary not found")
+	}
+
+	var parts []*BodyPart
+	pos += len(delim)
+	for {
+		/* the close delimiter */
+		if strings.HasPrefix(body[pos:], "--") {
+			return parts, nil
+		}
+		nl := strings.IndexByte(body[pos:], '\n')
+		if nl == -1 {
+			return parts, errors.New("parseMultipart err: no line break after boundary")
+		}
+		pos += nl + 1
+
+		end := strings.Index(body[pos:], "\n"+delim)
+		if end == -1 {
+			return parts, errors.New("parseMultipart err: no closing boundary")
+		}
+		partEnd := pos + end
+		if partEnd > pos && body[partEnd-1] == '\r' {
+			partEnd--
+		}
+
+		part := parsePart(body[pos:partEnd], offset+pos)
+		if strings.HasPrefix(strings.ToLower(part.ContentType), "multipart/") && depth < 4 {
+			nested, err := parseMultipart(part.ContentType, part.Body, part.start, depth+1)
+			parts = append(parts, nested...)
+			if err != nil {
+				return parts, err
+			}
+		} else {
+			parts = append(parts, part)
+		}
+
+		pos += end + 1 + len(delim)
+	}
+}
+
+// parsePart splits the headers of a part from its body
+func parsePart(str string, offset int) *BodyPart {
+
+	p := &BodyPart{ContentType: "text/plain"}
+
+	headers, bodyStart := "", 0
+	switch {
+	case strings.HasPrefix(str, "\r\n"):
+		bodyStart = 2
+	case strings.HasPrefix(str, "\n"):
+		bodyStart = 1
+	default:
+		if i := strings.Index(str, "\r\n\r\n"); i > -1 {
+			headers, bodyStart = str[:i], i+4
+		} else if i := strings.Index(str, "\n\n"); i > -1 {
+			headers, bodyStart = str[:i], i+2
+		} else {
+			headers, bodyStart = str, len(str)
+		}
+	}
+
+	for _, line := range strings.Split(headers, "\n") {
+		line = strings.TrimRight(line, "\r")
+		colon := strings.IndexByte(line, ':')
+		if colon < 1 {
+			continue
+		}
+		h := &Header{Header: cleanWs(line[:colon]), Val: cleanWs(line[colon+1:])}
+		h.Name = h.Header
+		if strings.EqualFold(h.Header, "content-type") || h.Header == "c" {
+			p.ContentType = h.Val
+		}
+		p.Headers = append(p.Headers, h)
+	}
+
+	p.Body = str[bodyStart:]
+	p.start = offset + bodyStart
+	p.end = offset + len(str)
+	return p
+}
+
+// PrintableMsg returns the message with binary bodies, i.e. ISUP, as hex dumps
+func (s *SipMsg) PrintableMsg() string {
+
+	if s.Body == "" || s.eof < 0 {
+		return s.Msg
+	}
+	bodyStart := len(s.Msg) - len(s.Body)
+
+	if len(s.Parts) == 0 {
+		if !isBinaryContentType(s.ContentType) {
+			return s.Msg
+		}
+		return s.Msg[:bodyStart] + isup.HexDump([]byte(s.Body))
+	}
+
+	msg := s.Msg
+	for i := len(s.Parts) - 1; i >= 0; i-- {
+		p := s.Parts[i]
+		if !isBinaryContentType(p.ContentType) {
+			continue
+		}
+		msg = msg[:bodyStart+p.start] + isup.HexDump([]byte(p.Body)) + msg[bodyStart+p.end:]
+	}
+	return msg
+}
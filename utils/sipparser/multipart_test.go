@@ -0,0 +1,87 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"strings"
+	"testing"
+)
+
+var testMsgSipI = "INVITE sip:+4930123@gw.example.com SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 192.0.2.10:5060;branch=z9hG4bK74bf9\r\n" +
+	"From: <sip:+4989777@192.0.2.10>;tag=9fxced76sl\r\n" +
+	"To: <sip:+4930123@gw.example.com>\r\n" +
+	"Call-ID: 3848276298220188511@192.0.2.10\r\n" +
+	"CSeq: 1 INVITE\r\n" +
+	"Content-Type: multipart/mixed;boundary=\"unique-boundary-1\"\r\n" +
+	"\r\n" +
+	"--unique-boundary-1\r\n" +
+	"Content-Type: application/sdp\r\n" +
+	"\r\n" +
+	"v=0\r\n" +
+	"o=- 1 1 IN IP4 192.0.2.10\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 192.0.2.10\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 49170 RTP/AVP 8\r\n" +
+	"\r\n" +
+	"--unique-boundary-1\r\n" +
+	"Content-Type: application/isup;version=itu-t92+\r\n" +
+	"Content-Disposition: signal;handling=optional\r\n" +
+	"\r\n" +
+	"\x01\x00\x60\x01\x0a\x00\x02\x07\x05\x83\x10\x21\x43\x05\x0a\x05\x04\x13\x94\x71\x10\x00\r\n" +
+	"--unique-boundary-1--\r\n"
+
+func TestParseMultipart(t *testing.T) {
+	s := ParseMsg(testMsgSipI, nil, nil)
+	if s.Error != nil || s.BodyError != nil {
+		t.Fatalf("[TestParseMultipart] Unexpected err: %v %v", s.Error, s.BodyError)
+	}
+	if len(s.Parts) != 2 {
+		t.Fatalf("[TestParseMultipart] Expected 2 parts, got: %d", len(s.Parts))
+	}
+	if s.Parts[0].ContentType != "application/sdp" || !strings.HasPrefix(s.Parts[0].Body, "v=0\r\n") || !strings.HasSuffix(s.Parts[0].Body, "RTP/AVP 8\r\n") {
+		t.Errorf("[TestParseMultipart] Bad sdp part: %+v", s.Parts[0])
+	}
+	if len(s.Parts[1].Headers) != 2 || s.Parts[1].Headers[1].Val != "signal;handling=optional" {
+		t.Errorf("[TestParseMultipart] Bad isup part headers: %+v", s.Parts[1].Headers)
+	}
+	if s.Sdp == nil || len(s.Sdp.Media) != 1 || s.Sdp.Media[0].Port != 49170 {
+		t.Errorf("[TestParseMultipart] Bad sdp: %+v", s.Sdp)
+	}
+	if s.Isup == nil || s.Isup.Name != "IAM" || s.Isup.CalledDigits() != "12345" || s.Isup.CallingDigits() != "491701" {
+		t.Errorf("[TestParseMultipart] Bad isup: %+v", s.Isup)
+	}
+
+	printable := s.PrintableMsg()
+	if !strings.Contains(printable, "\r\n\r\n01 00 60 01 0A 00 02 07 05 83 10 21 43 05 0A 05 04 13 94 71 10 00\r\n--unique-boundary-1--") {
+		t.Errorf("[TestParseMultipart] Bad printable message: %q", printable)
+	}
+	if !strings.HasPrefix(printable, testMsgSipI[:strings.Index(testMsgSipI, "\x01")]) {
+		t.Errorf("[TestParseMultipart] The printable message changed the text parts")
+	}
+}
+
+func TestParseMultipartBroken(t *testing.T) {
+	/* a truncated capture keeps the parts found so far */
+	s := ParseMsg(testMsgSipI[:strings.Index(testMsgSipI, "\x01")+4], nil, nil)
+	if s.Error != nil || s.BodyError == nil {
+		t.Fatalf("[TestParseMultipartBroken] Expected a body error only, got: %v %v", s.Error, s.BodyError)
+	}
+	if len(s.Parts) != 1 || s.Sdp == nil {
+		t.Errorf("[TestParseMultipartBroken] Expected the sdp part, got: %d", len(s.Parts))
+	}
+
+	msg := strings.Replace(testMsgSipI, "multipart/mixed;boundary=\"unique-boundary-1\"", "application/isup", 1)
+	s = ParseMsg(msg, nil, nil)
+	if s.Error != nil || s.Isup == nil || s.Isup.Type != 0x2d {
+		t.Errorf("[TestParseMultipartBroken] Expected an unknown isup message from the boundary")
+	}
+	if strings.Contains(s.PrintableMsg(), "--unique-boundary-1\r\n") {
+		t.Errorf("[TestParseMultipartBroken] A binary body should be a hex dump")
+	}
+}
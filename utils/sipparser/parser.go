@@ -8,7 +8,10 @@ package sipparser
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/sipcapture/homer-app/utils/isup"
 )
 
 const (
@@ -28,9 +31,39 @@ type CallingPartyInfo struct {
 	Anonymous bool
 }
 
+// Header is one header line in the order of the message. Header is the
+// name as received, Name the long form of a compact one, Error is set if
+// the value couldn't be parsed.
 type Header struct {
-	Header string
-	Val    string
+	Header  string
+	Name    string
+	Val     string
+	Compact bool
+	Error   error
+}
+
+// compactHeaders maps the compact forms to the long header names
+var compactHeaders = map[string]string{
+	SIP_HDR_ACCEPT_CONTACT_CMP:   "Accept-Contact",
+	SIP_HDR_REFERRED_BY_CMP:      "Referred-By",
+	SIP_HDR_CONTENT_TYPE_CMP:     "Content-Type",
+	"d":                          "Request-Disposition",
+	SIP_HDR_CONTENT_ENCODING_CMP: "Content-Encoding",
+	SIP_HDR_FROM_CMP:             "From",
+	SIP_HDR_CALL_ID_CMP:          "Call-ID",
+	SIP_HDR_REJECT_CONTACT_CMP:   "Reject-Contact",
+	SIP_HDR_SUPPORTED_CMP:        "Supported",
+	SIP_HDR_CONTENT_LENGTH_CMP:   "Content-Length",
+	SIP_HDR_CONTACT_CMP:          "Contact",
+	SIP_HDR_IDENTITY_INFO_CMP:    "Identity-Info",
+	"o":                          "Event",
+	"r":                          "Refer-To",
+	SIP_HDR_SUBJECT_CMP:          "Subject",
+	SIP_HDR_TO_CMP:               "To",
+	SIP_HDR_ALLOW_EVENTS_CMP:     "Allow-Events",
+	SIP_HDR_VIA_CMP:              "Via",
+	SIP_HDR_SESSION_EXPIRES_CMP:  "Session-Expires",
+	SIP_HDR_IDENTITY_CMP:         "Identity",
 }
 
 func (h *Header) String() string {
@@ -40,89 +73,93 @@ func (h *Header) String() string {
 type sipParserStateFn func(s *SipMsg) sipParserStateFn
 
 type SipMsg struct {
-	State            string
-	Error            error
-	Msg              string
-	CallingParty     *CallingPartyInfo
-	Body             string
-	Authorization    *Authorization
-	AuthVal          string
-	AuthUser         string
-	ContentLength    string
-	ContentType      string
-	From             *From
-	FromUser         string
-	FromHost         string
-	FromTag          string
-	MaxForwards      string
-	Organization     string
-	To               *From
-	ToUser           string
-	ToHost           string
-	ToTag            string
-	Expires          string
-	Contact          *From
-	ContactVal       string
-	ContactUser      string
-	ContactHost      string
-	ContactPort      int
-	CallID           string
-	XCallID          string
-	XHeader          []string
-	CHeader          []string
-	CustomHeader     map[string]string
-	Cseq             *Cseq
-	CseqMethod       string
-	CseqVal          string
-	ReasonVal        string
-	RTPStatVal       string
-	ViaOne           string
-	ViaOneBranch     string
-	Privacy          string
-	RemotePartyIdVal string
-	DiversionVal     string
-	RemotePartyId    *RemotePartyId
-	PAssertedIdVal   string
-	PaiUser          string
-	PaiHost          string
-	PAssertedId      *PAssertedId
-	UserAgent        string
-	Server           string
-	URIHost          string
-	URIRaw           string
-	URIUser          string
-	FirstMethod      string
-	FirstResp        string
-	FirstRespText    string
-	Profile          string
-	eof              int
-	hdr              string
-	hdrv             string
-	//Reason           *Reason
-	//Via                []*Via
-	//StartLine          *StartLine
-	//Headers            []*Header
-	//Accept             *Accept
-	//AlertInfo          string
-	//Allow              []string
-	//AllowEvents        []string
-	//ContentDisposition *ContentDisposition
-	//ContentLengthInt   int
-	//MaxForwardsInt     int
-	//ProxyAuthenticate  *Authorization
-	//ProxyRequire       []string
-	//Rack               *Rack
-	//Rseq               string
-	//RseqInt            int
-	//RecordRoute        []*URI
-	//RTPStat            *RTPStat
-	//Route              []*URI
-	//Require            []string
-	//Unsupported        []string
-	//Subject            string
-	//Supported          []string
-	//Warning            *Warning
-	//WWWAuthenticate    *Authorization
+	State              string
+	Error              error
+	Msg                string
+	CallingParty       *CallingPartyInfo
+	Body               string
+	Parts              []*BodyPart
+	Sdp                *Sdp
+	Isup               *isup.Message
+	Identity           []*Identity
+	BodyError          error
+	Authorization      *Authorization
+	AuthVal            string
+	AuthUser           string
+	ContentLength      string
+	ContentType        string
+	From               *From
+	FromUser           string
+	FromHost           string
+	FromTag            string
+	MaxForwards        string
+	Organization       string
+	To                 *From
+	ToUser             string
+	ToHost             string
+	ToTag              string
+	Expires            string
+	Contact            *From
+	ContactVal         string
+	ContactUser        string
+	ContactHost        string
+	ContactPort        int
+	CallID             string
+	XCallID            string
+	XHeader            []string
+	CHeader            []string
+	CustomHeader       map[string]string
+	Cseq               *Cseq
+	CseqMethod         string
+	CseqVal            string
+	ReasonVal          string
+	RTPStatVal         string
+	ViaOne             string
+	ViaOneBranch       string
+	Privacy            string
+	RemotePartyIdVal   string
+	DiversionVal       string
+	RemotePartyId      *RemotePartyId
+	PAssertedIdVal     string
+	PaiUser            string
+	PaiHost            string
+	PAssertedId        *PAssertedId
+	UserAgent          string
+	Server             string
+	URIHost            string
+	URIRaw             string
+	URIUser            string
+	FirstMethod        string
+	FirstResp          string
+	FirstRespText      string
+	Profile            string
+	Via                []*Via
+	StartLine          *StartLine
+	Headers            []*Header
+	Reason             *Reason
+	Accept             *Accept
+	AlertInfo          string
+	Allow              []string
+	AllowEvents        []string
+	ContentDisposition *ContentDisposition
+	ContentLengthInt   int
+	MaxForwardsInt     int
+	ProxyAuthenticate  *Authorization
+	ProxyRequire       []string
+	Rack               *Rack
+	Rseq               string
+	RseqInt            int
+	RecordRoute        []*URI
+	Route              []*URI
+	Require            []string
+	Unsupported        []string
+	Subject            string
+	Supported          []string
+	Warning            *Warning
+	WWWAuthenticate    *Authorization
+	eof                int
+	hdr                string
+	hdrv               string
 }
 
 func (s *SipMsg) run() {
@@ -159,98 +196,99 @@ func (s *SipMsg) addHdr(str string) {
 		s.hdrv = ""
 	}
 
+	h := &Header{Header: s.hdr, Name: s.hdr, Val: s.hdrv}
 	if len(s.hdr) == 1 {
-		switch {
-		case s.hdr == "I" || s.hdr == "i":
-			s.CallID = s.hdrv
-		case s.hdr == "F" || s.hdr == "f":
-			s.parseFrom(s.hdrv)
-		case s.hdr == "T" || s.hdr == "t":
-			s.parseTo(s.hdrv)
-		case s.hdr == "M" || s.hdr == "m":
-			s.ContactVal = s.hdrv
-			s.parseContact(str)
-		case s.hdr == "V" || s.hdr == "v":
-			s.parseVia(s.hdrv)
-		case s.hdr == "C" || s.hdr == "c":
-			s.ContentType = s.hdrv
-		case s.hdr == "L" || s.hdr == "l":
-			s.ContentLength = s.hdrv
-		case s.hdr == "u":
+		if name, ok := compactHeaders[strings.ToLower(s.hdr)]; ok {
+			h.Name = name
+			h.Compact = true
 		}
-	} else if len(s.hdr) == 2 {
-		// To header
+	}
+	s.Headers = append(s.Headers, h)
+
+	switch strings.ToLower(h.Name) {
+	case SIP_HDR_VIA:
+		h.Error = s.parseVia(s.hdrv)
+	case SIP_HDR_FROM:
+		s.parseFrom(s.hdrv)
+	case SIP_HDR_TO:
 		s.parseTo(s.hdrv)
-	} else {
-		switch {
-		case s.hdr == "Via" || s.hdr == "VIA" || s.hdr == "via":
-			s.parseVia(s.hdrv)
-		case s.hdr == "From" || s.hdr == "FROM" || s.hdr == "from":
-			s.parseFrom(s.hdrv)
-		case s.hdr == "Call-ID" || s.hdr == "CALL-ID" || s.hdr == "Call-Id" || s.hdr == "Call-id" || s.hdr == "call-id":
-			s.CallID = s.hdrv
-		case s.hdr == "CSeq" || s.hdr == "CSEQ" || s.hdr == "Cseq" || s.hdr == "cseq":
-			s.CseqVal = s.hdrv
-			s.parseCseq(s.hdrv)
-		case s.hdr == "Contact" || s.hdr == "CONTACT" || s.hdr == "contact":
-			s.ContactVal = s.hdrv
-			s.parseContact(str)
-		case s.hdr == "User-Agent" || s.hdr == "USER-AGENT" || s.hdr == "user-agent":
-			s.UserAgent = s.hdrv
-		case s.hdr == "Server" || s.hdr == "server":
-			s.Server = s.hdrv
-		case s.hdr == "Content-Type" || s.hdr == "CONTENT-TYPE" || s.hdr == "content-type":
-			s.ContentType = s.hdrv
-		case s.hdr == "Content-Length" || s.hdr == "CONTENT-LENGTH" || s.hdr == "content-length":
-			s.ContentLength = s.hdrv
-		case s.hdr == "Accept" || s.hdr == "Accept-Encoding" || s.hdr == "Accept-Language":
-			//s.parseAccept(s.hdrv)
-		case s.hdr == "Allow":
-			//s.parseAllow(s.hdrv)
-		case s.hdr == "Allow‑Events":
-			//s.parseAllowEvents(s.hdrv)
-		case s.hdr == "Authorization" || s.hdr == "authorization" || s.hdr == "Proxy-Authorization" || s.hdr == "proxy-authorization":
-			s.parseAuthorization(s.hdrv)
-		case s.hdr == "Content-Disposition":
-			//s.parseContentDisposition(s.hdrv)
-		case s.hdr == "Route":
-			//s.parseRoute(s.hdrv)
-		case s.hdr == "Record-Route":
-			//s.parseRecordRoute(s.hdrv)
-		case s.hdr == "Max-Forwards" || s.hdr == "MAX-FORWARDS" || s.hdr == "max-forwards":
-			s.MaxForwards = s.hdrv
-		case s.hdr == "Organization" || s.hdr == "organization":
-			s.Organization = s.hdrv
-		case s.hdr == "P-Asserted-Identity" || s.hdr == "p-asserted-identity":
-			s.PAssertedIdVal = s.hdrv
-			s.parsePAssertedId(s.hdrv)
-		case s.hdr == "Proxy-Authenticate" || s.hdr == "proxy-authenticate":
-			//s.parseProxyAuthenticate(s.hdrv)
-		case s.hdr == "RAck":
-			//s.parseRack(s.hdrv)
-		case s.hdr == "Reason" || s.hdr == "reason":
-			s.ReasonVal = s.hdrv
-			//s.parseReason(s.hdrv)
-		case s.hdr == "Remote-Party-Id" || s.hdr == "remote-party-id":
-			s.RemotePartyIdVal = s.hdrv
-		case s.hdr == "Diversion" || s.hdr == "diversion":
-			s.DiversionVal = s.hdrv
-		case s.hdr == "Supported":
-			//s.parseSupported(s.hdrv)
-		case s.hdr == "Unsupported":
-			//s.parseUnsupported(s.hdrv)
-		case s.hdr == "Warning":
-			//s.parseWarning(s.hdrv)
-		case s.hdr == "WWW-Authenticate":
-			//s.parseWWWAuthenticate(s.hdrv)
-		case s.hdr == "Privacy" || s.hdr == "privacy":
-			s.Privacy = s.hdrv
-		case s.hdr == "X-RTP-Stat":
-			s.parseRTPStat(s.hdrv)
-		case s.hdr == "Expires":
-			s.Expires = s.hdrv
-		default:
-		}
+	case SIP_HDR_CALL_ID:
+		s.CallID = s.hdrv
+	case SIP_HDR_CSEQ:
+		s.CseqVal = s.hdrv
+		s.parseCseq(s.hdrv)
+	case SIP_HDR_CONTACT:
+		s.ContactVal = s.hdrv
+		s.parseContact(s.hdrv)
+	case SIP_HDR_USER_AGENT:
+		s.UserAgent = s.hdrv
+	case SIP_HDR_SERVER:
+		s.Server = s.hdrv
+	case SIP_HDR_CONTENT_TYPE:
+		s.ContentType = s.hdrv
+	case SIP_HDR_CONTENT_LENGTH:
+		s.ContentLength = s.hdrv
+		s.ContentLengthInt, h.Error = strconv.Atoi(s.hdrv)
+	case SIP_HDR_ACCEPT:
+		s.parseAccept(s.hdrv)
+	case SIP_HDR_ALERT_INFO:
+		s.AlertInfo = s.hdrv
+	case SIP_HDR_ALLOW:
+		s.parseAllow(s.hdrv)
+	case SIP_HDR_ALLOW_EVENTS:
+		s.parseAllowEvents(s.hdrv)
+	case SIP_HDR_AUTHORIZATION, SIP_HDR_PROXY_AUTHORIZATION:
+		s.parseAuthorization(s.hdrv)
+	case SIP_HDR_CONTENT_DISPOSITION:
+		s.parseContentDisposition(s.hdrv)
+	case SIP_HDR_ROUTE:
+		h.Error = s.parseRoute(s.hdrv)
+	case SIP_HDR_RECORD_ROUTE:
+		h.Error = s.parseRecordRoute(s.hdrv)
+	case SIP_HDR_MAX_FORWARDS:
+		s.MaxForwards = s.hdrv
+		s.MaxForwardsInt, h.Error = strconv.Atoi(s.hdrv)
+	case SIP_HDR_ORGANIZATION:
+		s.Organization = s.hdrv
+	case SIP_HDR_P_ASSERTED_IDENTITY:
+		s.PAssertedIdVal = s.hdrv
+		s.parsePAssertedId(s.hdrv)
+	case SIP_HDR_PROXY_AUTHENTICATE:
+		h.Error = s.parseProxyAuthenticate(s.hdrv)
+	case SIP_HDR_PROXY_REQUIRE:
+		s.parseProxyRequire(s.hdrv)
+	case SIP_HDR_RACK:
+		h.Error = s.parseRack(s.hdrv)
+	case SIP_HDR_RSEQ:
+		s.Rseq = s.hdrv
+		s.RseqInt, h.Error = strconv.Atoi(s.hdrv)
+	case SIP_HDR_REASON:
+		s.ReasonVal = s.hdrv
+		s.parseReason(s.hdrv)
+	case SIP_HDR_REMOTE_PARTY_ID:
+		s.RemotePartyIdVal = s.hdrv
+	case SIP_HDR_DIVERSION:
+		s.DiversionVal = s.hdrv
+	case SIP_HDR_REQUIRE:
+		s.parseRequire(s.hdrv)
+	case SIP_HDR_SUBJECT:
+		s.Subject = s.hdrv
+	case SIP_HDR_SUPPORTED:
+		s.parseSupported(s.hdrv)
+	case SIP_HDR_UNSUPPORTED:
+		s.parseUnsupported(s.hdrv)
+	case SIP_HDR_WARNING:
+		h.Error = s.parseWarning(s.hdrv)
+	case SIP_HDR_WWW_AUTHENTICATE:
+		h.Error = s.parseWWWAuthenticate(s.hdrv)
+	case SIP_HDR_IDENTITY:
+		h.Error = s.parseIdentity(s.hdrv)
+	case SIP_HDR_PRIVACY:
+		s.Privacy = s.hdrv
+	case SIP_HDR_X_RTP_STAT:
+		s.parseRTPStat(s.hdrv)
+	case SIP_HDR_EXPIRES:
+		s.Expires = s.hdrv
 	}
 }
 
@@ -335,24 +373,18 @@ func (s *SipMsg) getCallingPartyRpid() error {
 	return nil
 }
 
-/* func (s *SipMsg) parseAccept(str string) {
+func (s *SipMsg) parseAccept(str string) {
 	s.Accept = &Accept{Val: str}
 	s.Accept.parse()
-} */
+}
 
-/* func (s *SipMsg) parseAllow(str string) {
-	s.Allow = getCommaSeperated(str)
-	if s.Allow == nil {
-		s.Allow = []string{str}
-	}
-} */
+func (s *SipMsg) parseAllow(str string) {
+	s.Allow = append(s.Allow, getCommaList(str)...)
+}
 
-/* func (s *SipMsg) parseAllowEvents(str string) {
-	s.AllowEvents = getCommaSeperated(str)
-	if s.AllowEvents == nil {
-		s.Allow = []string{str}
-	}
-} */
+func (s *SipMsg) parseAllowEvents(str string) {
+	s.AllowEvents = append(s.AllowEvents, getCommaList(str)...)
+}
 
 func (s *SipMsg) parseAuthorization(str string) {
 	s.Authorization = &Authorization{Val: str}
@@ -377,10 +409,10 @@ func (s *SipMsg) ParseContact(str string) {
 	s.parseContact(str)
 }
 
-/* func (s *SipMsg) parseContentDisposition(str string) {
+func (s *SipMsg) parseContentDisposition(str string) {
 	s.ContentDisposition = &ContentDisposition{Val: str}
 	s.ContentDisposition.parse()
-} */
+}
 
 func (s *SipMsg) parseCseq(str string) {
 	s.Cseq = &Cseq{Val: str}
@@ -419,52 +451,37 @@ func (s *SipMsg) ParsePAssertedId(str string) {
 	s.parsePAssertedId(str)
 }
 
-/* func (s *SipMsg) parseProxyAuthenticate(str string) {
+func (s *SipMsg) parseProxyAuthenticate(str string) error {
 	s.ProxyAuthenticate = &Authorization{Val: str}
-	s.Error = s.ProxyAuthenticate.parse()
-} */
+	return s.ProxyAuthenticate.parse()
+}
 
-/* func (s *SipMsg) parseRack(str string) {
+func (s *SipMsg) parseProxyRequire(str string) {
+	s.ProxyRequire = append(s.ProxyRequire, getCommaList(str)...)
+}
+
+func (s *SipMsg) parseRack(str string) error {
 	s.Rack = &Rack{Val: str}
-	s.Error = s.Rack.parse()
-} */
+	return s.Rack.parse()
+}
 
-/* func (s *SipMsg) parseReason(str string) {
+func (s *SipMsg) parseReason(str string) {
 	s.Reason = &Reason{Val: str}
 	s.Reason.parse()
-} */
+}
 
 func (s *SipMsg) parseRTPStat(str string) {
 	s.RTPStatVal = str
 }
 
-/* func (s *SipMsg) parseRecordRoute(str string) {
-	cs := []string{str}
-	for rt := range cs {
-		left := 0
-		right := 0
-		for i := range cs[rt] {
-			if cs[rt][i] == '<' && left == 0 {
-				left = i
-			}
-			if cs[rt][i] == '>' && right == 0 {
-				right = i
-			}
-		}
-		if left < right {
-			u := ParseURI(cs[rt][left+1 : right])
-			if u.Error != nil {
-				s.Error = fmt.Errorf("parseRecordRoute err: received err parsing uri: %v", u.Error)
-				return
-			}
-			if s.RecordRoute == nil {
-				s.RecordRoute = []*URI{u}
-			}
-			s.RecordRoute = append(s.RecordRoute, u)
-		}
+func (s *SipMsg) parseRecordRoute(str string) error {
+	uris, err := getRouteSet(str)
+	s.RecordRoute = append(s.RecordRoute, uris...)
+	if err != nil {
+		return fmt.Errorf("parseRecordRoute err: received err parsing uri: %v", err)
 	}
-	return
-} */
+	return nil
+}
 
 func (s *SipMsg) parseRemotePartyId(str string) {
 	s.RemotePartyId = &RemotePartyId{Val: str}
@@ -478,43 +495,23 @@ func (s *SipMsg) ParseRemotePartyId(str string) {
 	s.parseRemotePartyId(str)
 }
 
-/* func (s *SipMsg) parseRequire(str string) {
-	s.Require = getCommaSeperated(str)
-	if s.Require == nil {
-		s.Require = []string{str}
-	}
-} */
+func (s *SipMsg) parseRequire(str string) {
+	s.Require = append(s.Require, getCommaList(str)...)
+}
 
-/* func (s *SipMsg) parseRoute(str string) {
-	cs := getCommaSeperated(str)
-	for rt := range cs {
-		left := 0
-		right := 0
-		for i := range cs[rt] {
-			if cs[rt][i] == '<' && left == 0 {
-				left = i
-			}
-			if cs[rt][i] == '>' && right == 0 {
-				right = i
-			}
-		}
-		if left < right {
-			u := ParseURI(cs[rt][left+1 : right])
-			if u.Error != nil {
-				s.Error = fmt.Errorf("parseRoute err: received err parsing uri: %v", u.Error)
-				return
-			}
-			if s.Route == nil {
-				s.Route = []*URI{u}
-			}
-			s.Route = append(s.Route, u)
-		}
+func (s *SipMsg) parseRoute(str string) error {
+	uris, err := getRouteSet(str)
+	s.Route = append(s.Route, uris...)
+	if err != nil {
+		return fmt.Errorf("parseRoute err: received err parsing uri: %v", err)
 	}
-} */
+	return nil
+}
 
 func (s *SipMsg) parseStartLine(str string) {
 	s.State = sipParseStateStartLine
 	sLine := ParseStartLine(str)
+	s.StartLine = sLine
 	s.FirstMethod = sLine.Method
 	s.FirstResp = sLine.Resp
 	s.FirstRespText = sLine.RespText
@@ -528,12 +525,9 @@ func (s *SipMsg) parseStartLine(str string) {
 	}
 }
 
-/* func (s *SipMsg) parseSupported(str string) {
-	s.Supported = getCommaSeperated(str)
-	if s.Supported == nil {
-		s.Supported = []string{str}
-	}
-} */
+func (s *SipMsg) parseSupported(str string) {
+	s.Supported = append(s.Supported, getCommaList(str)...)
+}
 
 func (s *SipMsg) parseTo(str string) {
 	s.To = getFrom(str)
@@ -546,24 +540,15 @@ func (s *SipMsg) parseTo(str string) {
 	}
 }
 
-/* func (s *SipMsg) parseUnsupported(str string) {
-	s.Unsupported = getCommaSeperated(str)
-	if s.Unsupported == nil {
-		s.Unsupported = []string{str}
-	}
-} */
+func (s *SipMsg) parseUnsupported(str string) {
+	s.Unsupported = append(s.Unsupported, getCommaList(str)...)
+}
+
+func (s *SipMsg) parseVia(str string) error {
+	vs := &vias{via: str}
+	vs.parse()
+	s.Via = append(s.Via, vs.vias...)
 
-func (s *SipMsg) parseVia(str string) {
-	/* 	vs := &vias{via: str}
-	   	vs.parse()
-	   	if vs.err != nil {
-	   		s.Error = vs.err
-	   		return
-	   	}
-	   	for _, v := range vs.vias {
-	   		s.Via = append(s.Via, v)
-	   	}
-	*/
 	s.ViaOne = str
 	if a := strings.Index(str, "branch="); a > -1 && a < len(str) {
 		b := str[a:]
@@ -574,17 +559,18 @@ func (s *SipMsg) parseVia(str string) {
 			s.ViaOneBranch = b[7:]
 		}
 	}
+	return vs.err
 }
 
-/* func (s *SipMsg) parseWarning(str string) {
+func (s *SipMsg) parseWarning(str string) error {
 	s.Warning = &Warning{Val: str}
-	s.Error = s.Warning.parse()
-} */
+	return s.Warning.parse()
+}
 
-/* func (s *SipMsg) parseWWWAuthenticate(str string) {
+func (s *SipMsg) parseWWWAuthenticate(str string) error {
 	s.WWWAuthenticate = &Authorization{Val: str}
-	s.Error = s.WWWAuthenticate.parse()
-} */
+	return s.WWWAuthenticate.parse()
+}
 
 func getHeaders(s *SipMsg) sipParserStateFn {
 	s.State = sipParseStateHeaders
@@ -636,6 +622,10 @@ func ParseMsg(str string, xcid, cheader []string) (s *SipMsg) {
 		return s
 	}
 	s.run()
+	/* a broken body doesn't make the SIP message broken, see BodyError and Sdp.Error */
+	if s.Error == nil && s.Body != "" {
+		s.parseBody()
+	}
 	return s
 }
 
@@ -0,0 +1,164 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testMsgInvite = "INVITE sip:bob@biloxi.example.com SIP/2.0\r\n" +
+	"v: SIP/2.0/TCP proxy.example.com;branch=z9hG4bK2d4790.1\r\n" +
+	"Via: SIP/2.0/UDP pc33.atlanta.example.com:5060;branch=z9hG4bK776asdhds;received=192.0.2.1\r\n" +
+	"Max-Forwards: 69\r\n" +
+	"Route: <sip:p1.example.com;lr>, <sip:p2.example.com;lr>\r\n" +
+	"Record-Route: <sip:proxy.example.com;lr>\r\n" +
+	"t: Bob <sip:bob@biloxi.example.com>\r\n" +
+	"f: \"Alice\" <sip:alice@atlanta.example.com>;tag=1928301774\r\n" +
+	"i: a84b4c76e66710@pc33.atlanta.example.com\r\n" +
+	"CSeq: 314159 INVITE\r\n" +
+	"m: <sip:alice@pc33.atlanta.example.com>\r\n" +
+	"Require: 100rel\r\n" +
+	"k: timer, replaces\r\n" +
+	"Allow: INVITE, ACK, CANCEL, BYE, PRACK\r\n" +
+	"u: talk, hold\r\n" +
+	"Accept: application/sdp, application/dtmf-relay\r\n" +
+	"Content-Disposition: session; handling=required\r\n" +
+	"s: Project X\r\n" +
+	"Warning: 399 proxy.example.com \"Noisy line\"\r\n" +
+	"Proxy-Authorization: Digest username=\"alice\", realm=\"atlanta.example.com\", qop=\"auth,auth-int\", nonce=\"84a4cc6f3082121f32b42a2187831a9e\"\r\n" +
+	"X-Custom: yes\r\n" +
+	"c: application/sdp\r\n" +
+	"l: 4\r\n" +
+	"\r\n" +
+	"v=0\r\n"
+
+func TestParseMsgHeaders(t *testing.T) {
+	s := ParseMsg(testMsgInvite, nil, nil)
+	if s.Error != nil {
+		t.Fatalf("[TestParseMsgHeaders] Unexpected err: %v", s.Error)
+	}
+
+	if s.StartLine == nil || s.StartLine.Method != "INVITE" || s.StartLine.URI.Host != "biloxi.example.com" {
+		t.Errorf("[TestParseMsgHeaders] Bad start line: %+v", s.StartLine)
+	}
+
+	if len(s.Headers) != 22 {
+		t.Fatalf("[TestParseMsgHeaders] Expected 22 headers, got: %d", len(s.Headers))
+	}
+	if h := s.Headers[0]; h.Header != "v" || h.Name != "Via" || !h.Compact {
+		t.Errorf("[TestParseMsgHeaders] Bad compact via header: %+v", h)
+	}
+	if h := s.Headers[1]; h.Header != "Via" || h.Compact {
+		t.Errorf("[TestParseMsgHeaders] Bad via header: %+v", h)
+	}
+	if h := s.Headers[19]; h.String() != "X-Custom: yes" {
+		t.Errorf("[TestParseMsgHeaders] Headers are out of order, got: %s", h.String())
+	}
+
+	if len(s.Via) != 2 || s.Via[0].SentBy != "proxy.example.com" || s.Via[1].Received != "192.0.2.1" {
+		t.Errorf("[TestParseMsgHeaders] Bad via list: %d", len(s.Via))
+	}
+	if s.MaxForwardsInt != 69 || s.ContentLengthInt != 4 {
+		t.Errorf("[TestParseMsgHeaders] Bad max-forwards %d or content-length %d", s.MaxForwardsInt, s.ContentLengthInt)
+	}
+	if len(s.Route) != 2 || s.Route[1].Host != "p2.example.com" {
+		t.Errorf("[TestParseMsgHeaders] Bad route: %d", len(s.Route))
+	}
+	if len(s.RecordRoute) != 1 || s.RecordRoute[0].Host != "proxy.example.com" {
+		t.Errorf("[TestParseMsgHeaders] Bad record-route: %d", len(s.RecordRoute))
+	}
+	if s.FromTag != "1928301774" || s.ToUser != "bob" || s.CallID != "a84b4c76e66710@pc33.atlanta.example.com" {
+		t.Errorf("[TestParseMsgHeaders] Bad compact from/to/call-id: %s %s %s", s.FromTag, s.ToUser, s.CallID)
+	}
+	if s.ContactHost != "pc33.atlanta.example.com" || s.Contact.Name != "" {
+		t.Errorf("[TestParseMsgHeaders] Bad contact: %+v", s.Contact)
+	}
+	if strings.Join(s.Require, ",") != "100rel" || strings.Join(s.Supported, ",") != "timer,replaces" {
+		t.Errorf("[TestParseMsgHeaders] Bad require %v or supported %v", s.Require, s.Supported)
+	}
+	if len(s.Allow) != 5 || strings.Join(s.AllowEvents, ",") != "talk,hold" {
+		t.Errorf("[TestParseMsgHeaders] Bad allow %v or allow-events %v", s.Allow, s.AllowEvents)
+	}
+	if s.Accept == nil || len(s.Accept.Params) != 2 {
+		t.Errorf("[TestParseMsgHeaders] Bad accept: %+v", s.Accept)
+	}
+	if s.ContentDisposition == nil || s.ContentDisposition.DispType != "session" {
+		t.Errorf("[TestParseMsgHeaders] Bad content-disposition: %+v", s.ContentDisposition)
+	}
+	if s.Subject != "Project X" || s.ContentType != "application/sdp" {
+		t.Errorf("[TestParseMsgHeaders] Bad subject %q or content-type %q", s.Subject, s.ContentType)
+	}
+	if s.Warning == nil || s.Warning.CodeInt != 399 || s.Warning.Text != "Noisy line" {
+		t.Errorf("[TestParseMsgHeaders] Bad warning: %+v", s.Warning)
+	}
+	if s.AuthUser != "alice" || s.Authorization.GetParam("qop") == nil || s.Authorization.GetParam("qop").Val != "auth,auth-int" {
+		t.Errorf("[TestParseMsgHeaders] Bad authorization: %+v", s.Authorization)
+	}
+}
+
+func TestParseMsgBadHeaders(t *testing.T) {
+	msg := "SIP/2.0 200 OK\r\n" +
+		"Via: SIP/2.0/UDP pc33.atlanta.example.com;branch=z9hG4bK776asdhds\r\n" +
+		"From: <sip:alice@atlanta.example.com>;tag=1928301774\r\n" +
+		"To: <sip:bob@biloxi.example.com>;tag=a6c85cf\r\n" +
+		"Call-ID: a84b4c76e66710\r\n" +
+		"CSeq: 1 PRACK\r\n" +
+		"RAck: 776656 INVITE\r\n" +
+		"RSeq: 1\r\n" +
+		"Warning: bad\r\n" +
+		"WWW-Authenticate: Digest realm=\"biloxi.example.com\", nonce=\"abc\"\r\n" +
+		"\r\n"
+
+	s := ParseMsg(msg, nil, nil)
+	if s.Error != nil {
+		t.Fatalf("[TestParseMsgBadHeaders] A broken RAck or Warning shouldn't fail the message: %v", s.Error)
+	}
+	if s.Headers[5].Error == nil || s.Headers[7].Error == nil {
+		t.Errorf("[TestParseMsgBadHeaders] Expected errors on RAck and Warning")
+	}
+	if s.RseqInt != 1 {
+		t.Errorf("[TestParseMsgBadHeaders] Bad rseq: %d", s.RseqInt)
+	}
+	if s.WWWAuthenticate == nil || s.WWWAuthenticate.GetParam("realm").Val != "biloxi.example.com" {
+		t.Errorf("[TestParseMsgBadHeaders] Bad www-authenticate: %+v", s.WWWAuthenticate)
+	}
+}
+
+func TestSipMsgJSON(t *testing.T) {
+	data, err := json.Marshal(ParseMsg(testMsgInvite, nil, nil))
+	if err != nil {
+		t.Fatalf("[TestSipMsgJSON] Marshal err: %v", err)
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("[TestSipMsgJSON] Unmarshal err: %v", err)
+	}
+	if m["method"] != "INVITE" || m["version"] != "SIP/2.0" || m["call_id"] != "a84b4c76e66710@pc33.atlanta.example.com" {
+		t.Errorf("[TestSipMsgJSON] Bad start line or call-id: %s", data)
+	}
+
+	headers := m["headers"].([]interface{})
+	first := headers[0].(map[string]interface{})
+	if len(headers) != 22 || first["name"] != "v" || first["long_name"] != "Via" {
+		t.Errorf("[TestSipMsgJSON] Bad headers: %v", headers[0])
+	}
+	if via := m["via"].([]interface{}); len(via) != 2 || via[1].(map[string]interface{})["branch"] != "z9hG4bK776asdhds" {
+		t.Errorf("[TestSipMsgJSON] Bad via: %v", m["via"])
+	}
+	if route := m["route"].([]interface{}); len(route) != 2 || route[0].(map[string]interface{})["uri"] != "sip:p1.example.com;lr" {
+		t.Errorf("[TestSipMsgJSON] Bad route: %v", m["route"])
+	}
+	if from := m["from"].(map[string]interface{}); from["name"] != "Alice" || from["tag"] != "1928301774" {
+		t.Errorf("[TestSipMsgJSON] Bad from: %v", m["from"])
+	}
+	if m["max_forwards"] != float64(69) || m["sdp"] == nil {
+		t.Errorf("[TestSipMsgJSON] Bad max-forwards or sdp: %s", data)
+	}
+}
@@ -6,8 +6,11 @@
 package sipparser
 
 // Imports from the go standard library
+import (
+	"testing"
+)
 
-/* func TestRack(t *testing.T) {
+func TestRack(t *testing.T) {
 	sm := &SipMsg{}
 	s := "776656 1 INVITE"
 	sm.parseRack(s)
@@ -24,4 +27,3 @@ package sipparser
 		t.Errorf("[TestRack] Error parsing rack hdr: 776656 1 INVITE.  CseqMethod should be \"INVITE\" but received: \"%s\"", sm.Rack.CseqMethod)
 	}
 }
-*/
@@ -5,7 +5,12 @@
 
 package sipparser
 
-/* func TestReason(t *testing.T) {
+// Imports from the go standard library
+import (
+	"testing"
+)
+
+func TestReason(t *testing.T) {
 	sm := &SipMsg{}
 	s := "Q.850;cause=16;text=\"NORMAL_CLEARING\""
 	sm.parseReason(s)
@@ -27,4 +32,3 @@ package sipparser
 		t.Errorf("[TestReason] Error parsing reason hdr: Q.850;cause=102.  Cause should be \"102\" but received: " + sm.Reason.Cause)
 	}
 }
-*/
@@ -0,0 +1,610 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sdp is a parsed session description (RFC 4566/8866).
+// Session level c=, b= and a= lines are kept here, the ones after
+// an m= line in the media.
+type Sdp struct {
+	Version     string          `json:"version"`
+	Origin      *SdpOrigin      `json:"origin,omitempty"`
+	SessionName string          `json:"session_name"`
+	Info        string          `json:"info,omitempty"`
+	URI         string          `json:"uri,omitempty"`
+	Email       []string        `json:"email,omitempty"`
+	Phone       []string        `json:"phone,omitempty"`
+	Connection  *SdpConnection  `json:"connection,omitempty"`
+	Bandwidth   []string        `json:"bandwidth,omitempty"`
+	Timing      []string        `json:"timing,omitempty"`
+	Direction   string          `json:"direction,omitempty"`
+	Groups      []*SdpGroup     `json:"groups,omitempty"`
+	IceUfrag    string          `json:"ice_ufrag,omitempty"`
+	IcePwd      string          `json:"ice_pwd,omitempty"`
+	IceLite     bool            `json:"ice_lite,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Setup       string          `json:"setup,omitempty"`
+	Attributes  []*SdpAttribute `json:"attributes,omitempty"`
+	Media       []*SdpMedia     `json:"media"`
+	Error       error           `json:"-"`
+	Errors      []string        `json:"errors,omitempty"`
+	media       *SdpMedia
+}
+
+// SdpOrigin is the o= line
+type SdpOrigin struct {
+	Username       string `json:"username"`
+	SessionID      string `json:"session_id"`
+	SessionVersion string `json:"session_version"`
+	NetType        string `json:"net_type"`
+	AddrType       string `json:"addr_type"`
+	Address        string `json:"address"`
+}
+
+// SdpConnection is a c= line, TTL and Count are set for multicast
+type SdpConnection struct {
+	NetType  string `json:"net_type"`
+	AddrType string `json:"addr_type"`
+	Address  string `json:"address"`
+	TTL      int    `json:"ttl,omitempty"`
+	Count    int    `json:"count,omitempty"`
+}
+
+// SdpGroup is an a=group line, i.e. BUNDLE
+type SdpGroup struct {
+	Semantics string   `json:"semantics"`
+	Mids      []string `json:"mids"`
+}
+
+// SdpAttribute is an a= line which hasn't got a field of its own
+type SdpAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// SdpMedia is an m= line with the lines following it
+type SdpMedia struct {
+	Type        string          `json:"type"`
+	Port        int             `json:"port"`
+	PortCount   int             `json:"port_count,omitempty"`
+	Proto       string          `json:"proto"`
+	Formats     []string        `json:"formats"`
+	Info        string          `json:"info,omitempty"`
+	Connection  *SdpConnection  `json:"connection,omitempty"`
+	Bandwidth   []string        `json:"bandwidth,omitempty"`
+	Direction   string          `json:"direction,omitempty"`
+	Mid         string          `json:"mid,omitempty"`
+	Rtcp        string          `json:"rtcp,omitempty"`
+	RtcpMux     bool            `json:"rtcp_mux,omitempty"`
+	Ptime       string          `json:"ptime,omitempty"`
+	Codecs      []*SdpCodec     `json:"codecs,omitempty"`
+	Candidates  []*SdpCandidate `json:"candidates,omitempty"`
+	Crypto      []*SdpCrypto    `json:"crypto,omitempty"`
+	IceUfrag    string          `json:"ice_ufrag,omitempty"`
+	IcePwd      string          `json:"ice_pwd,omitempty"`
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Setup       string          `json:"setup,omitempty"`
+	Attributes  []*SdpAttribute `json:"attributes,omitempty"`
+}
+
+// SdpCodec joins the rtpmap and fmtp of a payload type. Static payload
+// types without rtpmap get their name from RFC 3551.
+type SdpCodec struct {
+	PayloadType int    `json:"payload_type"`
+	Name        string `json:"name"`
+	ClockRate   int    `json:"clock_rate,omitempty"`
+	Channels    int    `json:"channels,omitempty"`
+	Fmtp        string `json:"fmtp,omitempty"`
+}
+
+// SdpCandidate is an ICE candidate (RFC 8839)
+type SdpCandidate struct {
+	Foundation string `json:"foundation"`
+	Component  int    `json:"component"`
+	Transport  string `json:"transport"`
+	Priority   uint64 `json:"priority"`
+	Address    string `json:"address"`
+	Port       int    `json:"port"`
+	Type       string `json:"type"`
+	RelAddr    string `json:"rel_addr,omitempty"`
+	RelPort    int    `json:"rel_port,omitempty"`
+}
+
+// SdpCrypto is an SDES a=crypto line (RFC 4568)
+type SdpCrypto struct {
+	Tag           int    `json:"tag"`
+	Suite         string `json:"suite"`
+	KeyParams     string `json:"key_params"`
+	SessionParams string `json:"session_params,omitempty"`
+}
+
+// static payload types of RFC 3551
+var sdpStaticCodecs = map[int]SdpCodec{
+	0:  {Name: "PCMU", ClockRate: 8000, Channels: 1},
+	3:  {Name: "GSM", ClockRate: 8000, Channels: 1},
+	4:  {Name: "G723", ClockRate: 8000, Channels: 1},
+	5:  {Name: "DVI4", ClockRate: 8000, Channels: 1},
+	6:  {Name: "DVI4", ClockRate: 16000, Channels: 1},
+	7:  {Name: "LPC", ClockRate: 8000, Channels: 1},
+	8:  {Name: "PCMA", ClockRate: 8000, Channels: 1},
+	9:  {Name: "G722", ClockRate: 8000, Channels: 1},
+	10: {Name: "L16", ClockRate: 44100, Channels: 2},
+	11: {Name: "L16", ClockRate: 44100, Channels: 1},
+	12: {Name: "QCELP", ClockRate: 8000, Channels: 1},
+	13: {Name: "CN", ClockRate: 8000, Channels: 1},
+	14: {Name: "MPA", ClockRate: 90000},
+	15: {Name: "G728", ClockRate: 8000, Channels: 1},
+	16: {Name: "DVI4", ClockRate: 11025, Channels: 1},
+	17: {Name: "DVI4", ClockRate: 22050, Channels: 1},
+	18: {Name: "G729", ClockRate: 8000, Channels: 1},
+	25: {Name: "CelB", ClockRate: 90000},
+	26: {Name: "JPEG", ClockRate: 90000},
+	28: {Name: "nv", ClockRate: 90000},
+	31: {Name: "H261", ClockRate: 90000},
+	32: {Name: "MPV", ClockRate: 90000},
+	33: {Name: "MP2T", ClockRate: 90000},
+	34: {Name: "H263", ClockRate: 90000},
+}
+
+// ParseSdp parses a session description. Broken lines are skipped and
+// reported in Errors, Error holds the first of them.
+func ParseSdp(str string) *Sdp {
+	s := &Sdp{Media: []*SdpMedia{}}
+	s.parse(str)
+	return s
+}
+
+func (s *Sdp) addError(line int, format string, args ...interface{}) {
+	err := fmt.Errorf("ParseSdp err: line %d: %s", line, fmt.Sprintf(format, args...))
+	if s.Error == nil {
+		s.Error = err
+	}
+	s.Errors = append(s.Errors, err.Error())
+}
+
+func (s *Sdp) parse(str string) {
+
+	if strings.TrimSpace(str) == "" {
+		s.addError(0, "empty session description")
+		return
+	}
+
+	codecs := map[*SdpMedia]map[int]*SdpCodec{}
+
+	for i, line := range strings.Split(str, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		if len(line) < 2 || line[1] != '=' {
+			s.addError(i+1, "no type=value line: %q", line)
+			continue
+		}
+
+		val := line[2:]
+		switch line[0] {
+		case 'v':
+			s.Version = val
+		case 'o':
+			s.parseOrigin(i+1, val)
+		case 's':
+			s.SessionName = val
+		case 'i':
+			if s.media != nil {
+				s.media.Info = val
+			} else {
+				s.Info = val
+			}
+		case 'u':
+			s.URI = val
+		case 'e':
+			s.Email = append(s.Email, val)
+		case 'p':
+			s.Phone = append(s.Phone, val)
+		case 'c':
+			conn := s.parseConnection(i+1, val)
+			if s.media != nil {
+				s.media.Connection = conn
+			} else {
+				s.Connection = conn
+			}
+		case 'b':
+			if s.media != nil {
+				s.media.Bandwidth = append(s.media.Bandwidth, val)
+			} else {
+				s.Bandwidth = append(s.Bandwidth, val)
+			}
+		case 't':
+			s.Timing = append(s.Timing, val)
+		case 'r', 'z', 'k':
+			/* repeat times, time zones and the obsolete key line */
+		case 'm':
+			s.parseMedia(i+1, val)
+			if s.media != nil {
+				codecs[s.media] = map[int]*SdpCodec{}
+			}
+		case 'a':
+			s.parseAttribute(i+1, val, codecs[s.media])
+		default:
+			s.addError(i+1, "unknown type %q", line[0])
+		}
+	}
+
+	/* codecs in the order of the m= line */
+	for _, m := range s.Media {
+		for _, format := range m.Formats {
+			pt, err := strconv.Atoi(format)
+			if err != nil {
+				continue
+			}
+			if codec, ok := codecs[m][pt]; ok {
+				if codec.Name == "" {
+					if static, ok := sdpStaticCodecs[pt]; ok {
+						codec.Name, codec.ClockRate, codec.Channels = static.Name, static.ClockRate, static.Channels
+					}
+				}
+				m.Codecs = append(m.Codecs, codec)
+			} else if static, ok := sdpStaticCodecs[pt]; ok {
+				static.PayloadType = pt
+				m.Codecs = append(m.Codecs, &static)
+			}
+		}
+	}
+
+	if s.Version == "" {
+		s.addError(0, "no v= line")
+	}
+}
+
+func (s *Sdp) parseOrigin(line int, val string) {
+	f := strings.Fields(val)
+	if len(f) != 6 {
+		s.addError(line, "o= needs 6 fields: %q", val)
+		return
+	}
+	s.Origin = &SdpOrigin{
+		Username:       f[0],
+		SessionID:      f[1],
+		SessionVersion: f[2],
+		NetType:        f[3],
+		AddrType:       f[4],
+		Address:        f[5],
+	}
+}
+
+func (s *Sdp) parseConnection(line int, val string) *SdpConnection {
+	f := strings.Fields(val)
+	if len(f) != 3 {
+		s.addError(line, "c= needs 3 fields: %q", val)
+		return nil
+	}
+	conn := &SdpConnection{NetType: f[0], AddrType: f[1], Address: f[2]}
+
+	/* multicast: 224.2.1.1/127/3 or ff15::101/3 */
+	if parts := strings.Split(f[2], "/"); len(parts) > 1 {
+		conn.Address = parts[0]
+		if f[1] == "IP4" {
+			conn.TTL, _ = strconv.Atoi(parts[1])
+			if len(parts) > 2 {
+				conn.Count, _ = strconv.Atoi(parts[2])
+			}
+		} else {
+			conn.Count, _ = strconv.Atoi(parts[1])
+		}
+	}
+	return conn
+}
+
+func (s *Sdp) parseMedia(line int, val string) {
+	f := strings.Fields(val)
+	if len(f) < 3 {
+		s.media = nil
+		s.addError(line, "m= needs at least 3 fields: %q", val)
+		return
+	}
+
+	m := &SdpMedia{Type: f[0], Proto: f[2], Formats: f[3:]}
+	port := f[1]
+	if pos := strings.IndexByte(port, '/'); pos > -1 {
+		m.PortCount, _ = strconv.Atoi(port[pos+1:])
+		port = port[:pos]
+	}
+
+	var err error
+	if m.Port, err = strconv.Atoi(port); err != nil || m.Port < 0 || m.Port > 65535 {
+		s.addError(line, "bad media port: %q", f[1])
+	}
+
+	s.media = m
+	s.Media = append(s.Media, m)
+}
+
+func (s *Sdp) parseAttribute(line int, val string, codecs map[int]*SdpCodec) {
+
+	name, value := val, ""
+	if pos := strings.IndexByte(val, ':'); pos > -1 {
+		name, value = val[:pos], val[pos+1:]
+	}
+
+	m := s.media
+	switch name {
+	case "sendrecv", "sendonly", "recvonly", "inactive":
+		if m != nil {
+			m.Direction = name
+		} else {
+			s.Direction = name
+		}
+	case "rtpmap", "fmtp":
+		if m == nil || codecs == nil {
+			s.addError(line, "%s outside of a media", name)
+			return
+		}
+		pos := strings.IndexByte(value, ' ')
+		if pos < 1 {
+			s.addError(line, "bad %s: %q", name, value)
+			return
+		}
+		pt, err := strconv.Atoi(value[:pos])
+		if err != nil || pt < 0 || pt > 127 {
+			s.addError(line, "bad payload type in %s: %q", name, value)
+			return
+		}
+		codec, ok := codecs[pt]
+		if !ok {
+			codec = &SdpCodec{PayloadType: pt}
+			codecs[pt] = codec
+		}
+		rest := strings.TrimSpace(value[pos+1:])
+		if name == "fmtp" {
+			codec.Fmtp = rest
+			return
+		}
+		/* <encoding name>/<clock rate>[/<encoding parameters>] */
+		parts := strings.Split(rest, "/")
+		codec.Name = parts[0]
+		if len(parts) > 1 {
+			codec.ClockRate, _ = strconv.Atoi(parts[1])
+		}
+		if len(parts) > 2 {
+			codec.Channels, _ = strconv.Atoi(parts[2])
+		}
+	case "candidate":
+		if m == nil {
+			s.addError(line, "candidate outside of a media")
+			return
+		}
+		if c := parseSdpCandidate(value); c != nil {
+			m.Candidates = append(m.Candidates, c)
+		} else {
+			s.addError(line, "bad candidate: %q", value)
+		}
+	case "crypto":
+		if m == nil {
+			s.addError(line, "crypto outside of a media")
+			return
+		}
+		f := strings.Fields(value)
+		tag, err := strconv.Atoi(firstOf(f))
+		if len(f) < 3 || err != nil {
+			s.addError(line, "bad crypto: %q", value)
+			return
+		}
+		m.Crypto = append(m.Crypto, &SdpCrypto{
+			Tag:           tag,
+			Suite:         f[1],
+			KeyParams:     f[2],
+			SessionParams: strings.Join(f[3:], " "),
+		})
+	case "group":
+		f := strings.Fields(value)
+		if len(f) == 0 {
+			s.addError(line, "empty group")
+			return
+		}
+		s.Groups = append(s.Groups, &SdpGroup{Semantics: f[0], Mids: f[1:]})
+	case "mid":
+		if m != nil {
+			m.Mid = value
+		}
+	case "rtcp":
+		if m != nil {
+			m.Rtcp = value
+		}
+	case "rtcp-mux":
+		if m != nil {
+			m.RtcpMux = true
+		}
+	case "ptime":
+		if m != nil {
+			m.Ptime = value
+		}
+	case "ice-ufrag":
+		if m != nil {
+			m.IceUfrag = value
+		} else {
+			s.IceUfrag = value
+		}
+	case "ice-pwd":
+		if m != nil {
+			m.IcePwd = value
+		} else {
+			s.IcePwd = value
+		}
+	case "ice-lite":
+		s.IceLite = true
+	case "fingerprint":
+		if m != nil {
+			m.Fingerprint = value
+		} else {
+			s.Fingerprint = value
+		}
+	case "setup":
+		if m != nil {
+			m.Setup = value
+		} else {
+			s.Setup = value
+		}
+	default:
+		attr := &SdpAttribute{Name: name, Value: value}
+		if m != nil {
+			m.Attributes = append(m.Attributes, attr)
+		} else {
+			s.Attributes = append(s.Attributes, attr)
+		}
+	}
+}
+
+func firstOf(f []string) string {
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0]
+}
+
+// parseSdpCandidate parses <foundation> <component> <transport> <priority> <address> <port> typ <type> ...
+func parseSdpCandidate(str string) *SdpCandidate {
+	f := strings.Fields(str)
+	if len(f) < 8 || f[6] != "typ" {
+		return nil
+	}
+
+	c := &SdpCandidate{Foundation: f[0], Transport: f[2], Address: f[4], Type: f[7]}
+	var err error
+	if c.Component, err = strconv.Atoi(f[1]); err != nil {
+		return nil
+	}
+	if c.Priority, err = strconv.ParseUint(f[3], 10, 64); err != nil {
+		return nil
+	}
+	if c.Port, err = strconv.Atoi(f[5]); err != nil {
+		return nil
+	}
+
+	for i := 8; i+1 < len(f); i += 2 {
+		switch f[i] {
+		case "raddr":
+			c.RelAddr = f[i+1]
+		case "rport":
+			c.RelPort, _ = strconv.Atoi(f[i+1])
+		}
+	}
+	return c
+}
+
+// MediaConnection returns the c= line which applies to the media
+func (s *Sdp) MediaConnection(m *SdpMedia) *SdpConnection {
+	if m.Connection != nil {
+		return m.Connection
+	}
+	return s.Connection
+}
+
+// MediaIPs returns the addresses RTP is sent to, without duplicates
+func (s *Sdp) MediaIPs() []string {
+	var ips []string
+	for _, m := range s.Media {
+		if conn := s.MediaConnection(m); conn != nil && !stringInSlice(conn.Address, ips) {
+			ips = append(ips, conn.Address)
+		}
+	}
+	return ips
+}
+
+// CodecNames returns the names of all offered codecs, without duplicates
+func (s *Sdp) CodecNames() []string {
+	var names []string
+	for _, m := range s.Media {
+		for _, c := range m.Codecs {
+			if c.Name != "" && !stringInSlice(c.Name, names) {
+				names = append(names, c.Name)
+			}
+		}
+	}
+	return names
+}
+
+// Bundle returns the mids of the BUNDLE group
+func (s *Sdp) Bundle() []string {
+	for _, g := range s.Groups {
+		if g.Semantics == "BUNDLE" {
+			return g.Mids
+		}
+	}
+	return nil
+}
+
+func stringInSlice(val string, list []string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// SdpFields are the virtual search fields matched against the raw message
+var SdpFields = []string{"sdp.codec", "sdp.media_ip", "sdp.media_port", "sdp.media", "sdp.direction"}
+
+// SdpFieldPattern returns a case insensitive regular expression, valid for Go and
+// PostgreSQL, matching a raw SIP message whose SDP has one of the values in the field.
+// Values which can't appear in the field return false.
+func SdpFieldPattern(field string, values []string) (string, bool) {
+
+	var patterns []string
+	for _, val := range values {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+		quoted := regexp.QuoteMeta(val)
+
+		switch field {
+		case "sdp.codec":
+			patterns = append(patterns, `a=rtpmap:[0-9]+ `+quoted+`/`)
+			/* static payload types don't need an rtpmap */
+			var types []int
+			for pt, codec := range sdpStaticCodecs {
+				if strings.EqualFold(codec.Name, val) {
+					types = append(types, pt)
+				}
+			}
+			if len(types) > 0 {
+				sort.Ints(types)
+				list := []string{}
+				for _, pt := range types {
+					list = append(list, strconv.Itoa(pt))
+				}
+				patterns = append(patterns, `m=[a-z]+ [0-9/]+ [a-z/]+( [0-9]+)* (`+strings.Join(list, "|")+`)(\s|$)`)
+			}
+		case "sdp.media_ip":
+			patterns = append(patterns, `c=IN IP[46] `+quoted+`(/|\s|$)`)
+		case "sdp.media_port":
+			if port, err := strconv.Atoi(val); err != nil || port < 0 || port > 65535 {
+				continue
+			}
+			patterns = append(patterns, `m=[a-z]+ `+val+`(/|\s)`)
+		case "sdp.media":
+			patterns = append(patterns, `m=`+quoted+` `)
+		case "sdp.direction":
+			patterns = append(patterns, `a=`+quoted+`(\s|$)`)
+		default:
+			return "", false
+		}
+	}
+
+	if len(patterns) == 0 {
+		return "", false
+	}
+	return "(" + strings.Join(patterns, "|") + ")", true
+}
@@ -0,0 +1,256 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var testSdpAudio = "v=0\r\n" +
+	"o=alice 2890844526 2890844527 IN IP4 host.atlanta.example.com\r\n" +
+	"s=-\r\n" +
+	"c=IN IP4 192.0.2.101\r\n" +
+	"t=0 0\r\n" +
+	"m=audio 49172 RTP/AVP 0 8 97 101\r\n" +
+	"a=rtpmap:97 opus/48000/2\r\n" +
+	"a=fmtp:97 useinbandfec=1\r\n" +
+	"a=rtpmap:101 telephone-event/8000\r\n" +
+	"a=fmtp:101 0-16\r\n" +
+	"a=ptime:20\r\n" +
+	"a=sendonly\r\n" +
+	"a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:32 KDR=1\r\n" +
+	"m=video 51372/2 RTP/AVP 31\r\n" +
+	"c=IN IP4 224.2.1.1/127/3\r\n"
+
+var testSdpWebRTC = "v=0\r\n" +
+	"o=- 4611731400430051336 2 IN IP4 127.0.0.1\r\n" +
+	"s=-\r\n" +
+	"t=0 0\r\n" +
+	"a=group:BUNDLE 0 1\r\n" +
+	"a=ice-lite\r\n" +
+	"a=msid-semantic: WMS\r\n" +
+	"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+	"c=IN IP6 2001:db8::1\r\n" +
+	"a=mid:0\r\n" +
+	"a=ice-ufrag:F7gI\r\n" +
+	"a=ice-pwd:x9cml/YzichV2+XlhiMu8g\r\n" +
+	"a=fingerprint:sha-256 D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39\r\n" +
+	"a=setup:actpass\r\n" +
+	"a=rtcp-mux\r\n" +
+	"a=rtpmap:111 opus/48000/2\r\n" +
+	"a=candidate:1 1 udp 2122260223 192.0.2.10 54400 typ host generation 0\r\n" +
+	"a=candidate:2 1 udp 1686052607 198.51.100.7 54400 typ srflx raddr 192.0.2.10 rport 54400\r\n" +
+	"a=candidate:broken\r\n" +
+	"m=application 9 UDP/DTLS/SCTP webrtc-datachannel\r\n" +
+	"c=IN IP4 0.0.0.0\r\n" +
+	"a=mid:1\r\n" +
+	"a=sctp-port:5000\r\n"
+
+func TestParseSdp(t *testing.T) {
+	s := ParseSdp(testSdpAudio)
+	if s.Error != nil {
+		t.Fatalf("[TestParseSdp] Unexpected err: %v", s.Error)
+	}
+	if s.Version != "0" || s.SessionName != "-" || len(s.Timing) != 1 {
+		t.Errorf("[TestParseSdp] Bad session lines: %+v", s)
+	}
+	if s.Origin == nil || s.Origin.Username != "alice" || s.Origin.SessionVersion != "2890844527" || s.Origin.Address != "host.atlanta.example.com" {
+		t.Errorf("[TestParseSdp] Bad origin: %+v", s.Origin)
+	}
+	if s.Connection == nil || s.Connection.Address != "192.0.2.101" {
+		t.Errorf("[TestParseSdp] Bad session connection: %+v", s.Connection)
+	}
+	if len(s.Media) != 2 {
+		t.Fatalf("[TestParseSdp] Expected 2 media, got: %d", len(s.Media))
+	}
+
+	audio := s.Media[0]
+	if audio.Type != "audio" || audio.Port != 49172 || audio.Proto != "RTP/AVP" || audio.Direction != "sendonly" || audio.Ptime != "20" {
+		t.Errorf("[TestParseSdp] Bad audio media: %+v", audio)
+	}
+	if s.MediaConnection(audio) != s.Connection {
+		t.Errorf("[TestParseSdp] Audio should use the session connection")
+	}
+	names := []string{}
+	for _, c := range audio.Codecs {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "PCMU,PCMA,opus,telephone-event" {
+		t.Errorf("[TestParseSdp] Bad codecs: %v", names)
+	}
+	if opus := audio.Codecs[2]; opus.PayloadType != 97 || opus.ClockRate != 48000 || opus.Channels != 2 || opus.Fmtp != "useinbandfec=1" {
+		t.Errorf("[TestParseSdp] Bad opus codec: %+v", opus)
+	}
+	if len(audio.Crypto) != 1 || audio.Crypto[0].Tag != 1 || audio.Crypto[0].Suite != "AES_CM_128_HMAC_SHA1_80" || audio.Crypto[0].SessionParams != "KDR=1" {
+		t.Errorf("[TestParseSdp] Bad crypto: %+v", audio.Crypto)
+	}
+
+	video := s.Media[1]
+	if video.Port != 51372 || video.PortCount != 2 {
+		t.Errorf("[TestParseSdp] Bad video port: %+v", video)
+	}
+	if video.Connection == nil || video.Connection.Address != "224.2.1.1" || video.Connection.TTL != 127 || video.Connection.Count != 3 {
+		t.Errorf("[TestParseSdp] Bad multicast connection: %+v", video.Connection)
+	}
+	if len(video.Codecs) != 1 || video.Codecs[0].Name != "H261" || video.Codecs[0].ClockRate != 90000 {
+		t.Errorf("[TestParseSdp] Bad static video codec: %+v", video.Codecs)
+	}
+
+	if ips := strings.Join(s.MediaIPs(), ","); ips != "192.0.2.101,224.2.1.1" {
+		t.Errorf("[TestParseSdp] Bad media ips: %s", ips)
+	}
+	if codecs := strings.Join(s.CodecNames(), ","); codecs != "PCMU,PCMA,opus,telephone-event,H261" {
+		t.Errorf("[TestParseSdp] Bad codec names: %s", codecs)
+	}
+}
+
+func TestParseSdpWebRTC(t *testing.T) {
+	s := ParseSdp(testSdpWebRTC)
+	if len(s.Errors) != 1 || !strings.Contains(s.Errors[0], "candidate") {
+		t.Errorf("[TestParseSdpWebRTC] Expected one candidate error, got: %v", s.Errors)
+	}
+	if strings.Join(s.Bundle(), ",") != "0,1" || !s.IceLite {
+		t.Errorf("[TestParseSdpWebRTC] Bad bundle or ice-lite: %+v", s.Groups)
+	}
+	if len(s.Attributes) != 1 || s.Attributes[0].Name != "msid-semantic" {
+		t.Errorf("[TestParseSdpWebRTC] Bad session attributes: %+v", s.Attributes)
+	}
+
+	audio := s.Media[0]
+	if audio.Mid != "0" || !audio.RtcpMux || audio.IceUfrag != "F7gI" || audio.IcePwd != "x9cml/YzichV2+XlhiMu8g" || audio.Setup != "actpass" {
+		t.Errorf("[TestParseSdpWebRTC] Bad audio attributes: %+v", audio)
+	}
+	if !strings.HasPrefix(audio.Fingerprint, "sha-256 D1:2C") {
+		t.Errorf("[TestParseSdpWebRTC] Bad fingerprint: %s", audio.Fingerprint)
+	}
+	if audio.Connection == nil || audio.Connection.AddrType != "IP6" || audio.Connection.Address != "2001:db8::1" {
+		t.Errorf("[TestParseSdpWebRTC] Bad IPv6 connection: %+v", audio.Connection)
+	}
+	if len(audio.Candidates) != 2 {
+		t.Fatalf("[TestParseSdpWebRTC] Expected 2 candidates, got: %d", len(audio.Candidates))
+	}
+	if c := audio.Candidates[0]; c.Foundation != "1" || c.Component != 1 || c.Transport != "udp" || c.Priority != 2122260223 || c.Address != "192.0.2.10" || c.Port != 54400 || c.Type != "host" {
+		t.Errorf("[TestParseSdpWebRTC] Bad host candidate: %+v", c)
+	}
+	if c := audio.Candidates[1]; c.Type != "srflx" || c.RelAddr != "192.0.2.10" || c.RelPort != 54400 {
+		t.Errorf("[TestParseSdpWebRTC] Bad srflx candidate: %+v", c)
+	}
+
+	data := s.Media[1]
+	if data.Type != "application" || len(data.Codecs) != 0 || strings.Join(data.Formats, ",") != "webrtc-datachannel" {
+		t.Errorf("[TestParseSdpWebRTC] Bad application media: %+v", data)
+	}
+}
+
+func TestParseSdpErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"v=0\r\no=only three fields\r\n",
+		"v=0\r\nc=IN IP4\r\n",
+		"v=0\r\nm=audio\r\n",
+		"v=0\r\nm=audio 70000 RTP/AVP 0\r\n",
+		"v=0\r\nm=audio 1 RTP/AVP 0\r\na=rtpmap:x PCMU/8000\r\n",
+		"v=0\r\na=rtpmap:0 PCMU/8000\r\n",
+		"v=0\r\nnot a line\r\n",
+		"s=no version\r\n",
+	}
+	for _, val := range tests {
+		if s := ParseSdp(val); s.Error == nil {
+			t.Errorf("[TestParseSdpErrors] Expected an error for: %q", val)
+		}
+	}
+
+	/* LF only line endings are accepted */
+	if s := ParseSdp(strings.Replace(testSdpAudio, "\r\n", "\n", -1)); s.Error != nil || len(s.Media) != 2 {
+		t.Errorf("[TestParseSdpErrors] LF line endings: %v", s.Error)
+	}
+}
+
+func TestParseMsgSdp(t *testing.T) {
+	msg := "INVITE sip:bob@biloxi.example.com SIP/2.0\r\n" +
+		"Via: SIP/2.0/UDP pc33.atlanta.example.com;branch=z9hG4bK776asdhds\r\n" +
+		"From: Alice <sip:alice@atlanta.example.com>;tag=1928301774\r\n" +
+		"To: Bob <sip:bob@biloxi.example.com>\r\n" +
+		"Call-ID: a84b4c76e66710@pc33.atlanta.example.com\r\n" +
+		"CSeq: 314159 INVITE\r\n" +
+		"Content-Type: Application/SDP\r\n" +
+		"Content-Length: " + "0" + "\r\n\r\n" + testSdpAudio
+
+	s := ParseMsg(msg, nil, nil)
+	if s.Error != nil {
+		t.Fatalf("[TestParseMsgSdp] Unexpected err: %v", s.Error)
+	}
+	if s.Sdp == nil || len(s.Sdp.Media) != 2 {
+		t.Errorf("[TestParseMsgSdp] SDP body has not been parsed: %+v", s.Sdp)
+	}
+
+	s = ParseMsg(strings.Replace(msg, "Application/SDP", "text/plain", 1), nil, nil)
+	if s.Sdp != nil {
+		t.Errorf("[TestParseMsgSdp] Only SDP bodies should be parsed")
+	}
+}
+
+func TestSdpFieldPattern(t *testing.T) {
+	raw := "INVITE sip:bob@biloxi.example.com SIP/2.0\r\nContent-Type: application/sdp\r\n\r\n" + testSdpAudio
+
+	tests := []struct {
+		field  string
+		values []string
+		match  bool
+	}{
+		{"sdp.codec", []string{"opus"}, true},
+		{"sdp.codec", []string{"OPUS"}, true},
+		{"sdp.codec", []string{"PCMA"}, true},
+		{"sdp.codec", []string{"G729"}, false},
+		{"sdp.codec", []string{"G729", "H261"}, true},
+		{"sdp.codec", []string{"opu"}, false},
+		{"sdp.media_ip", []string{"192.0.2.101"}, true},
+		{"sdp.media_ip", []string{"224.2.1.1"}, true},
+		{"sdp.media_ip", []string{"192.0.2.10"}, false},
+		{"sdp.media_ip", []string{"192.0.2.1.1"}, false},
+		{"sdp.media_port", []string{"49172"}, true},
+		{"sdp.media_port", []string{"51372"}, true},
+		{"sdp.media_port", []string{"4917"}, false},
+		{"sdp.media", []string{"video"}, true},
+		{"sdp.media", []string{"application"}, false},
+		{"sdp.direction", []string{"sendonly"}, true},
+		{"sdp.direction", []string{"sendrecv"}, false},
+	}
+	for _, val := range tests {
+		pattern, ok := SdpFieldPattern(val.field, val.values)
+		if !ok {
+			t.Errorf("[TestSdpFieldPattern] No pattern for %s %v", val.field, val.values)
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			t.Errorf("[TestSdpFieldPattern] Bad pattern %s: %v", pattern, err)
+			continue
+		}
+		if re.MatchString(raw) != val.match {
+			t.Errorf("[TestSdpFieldPattern] %s %v: pattern %s should match: %v", val.field, val.values, pattern, val.match)
+		}
+	}
+
+	if _, ok := SdpFieldPattern("sdp.media_port", []string{"abc"}); ok {
+		t.Errorf("[TestSdpFieldPattern] A bad port shouldn't give a pattern")
+	}
+	if _, ok := SdpFieldPattern("sdp.unknown", []string{"x"}); ok {
+		t.Errorf("[TestSdpFieldPattern] An unknown field shouldn't give a pattern")
+	}
+}
+
+/* the fuzz corpus starts with these, every prefix must parse without a panic */
+func TestParseSdpPrefixes(t *testing.T) {
+	for _, val := range []string{testSdpAudio, testSdpWebRTC} {
+		for i := range val {
+			ParseSdp(val[:i])
+		}
+	}
+}
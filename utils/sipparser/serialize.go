@@ -0,0 +1,302 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// The message is kept as text, the API only edits that text: the mutation
+// methods change the header lines and parse the result again, so the parsed
+// fields always match String(). The parsed fields are read only, setting them
+// doesn't change the message. Header lines which are not touched are kept as
+// they were, Content-Length is set to the size of the body.
+
+var errNoMessage = errors.New("sipparser: message has no headers to edit")
+
+// URI bearing headers, their URIs are rewritten by RewriteURIs
+var uriHeaders = []string{
+	"From", "To", "Contact", "Route", "Record-Route", "P-Asserted-Identity", "P-Preferred-Identity",
+	"Remote-Party-ID", "Diversion", "History-Info", "Refer-To", "Referred-By", "P-Called-Party-ID",
+}
+
+// String returns the message as text, as it was parsed or last edited
+func (s *SipMsg) String() string {
+	return s.Msg
+}
+
+// Bytes returns the message as bytes
+func (s *SipMsg) Bytes() []byte {
+	return []byte(s.Msg)
+}
+
+// msgText is the message split into its start line, header lines and body.
+// A folded header keeps its continuation lines.
+type msgText struct {
+	start   string
+	headers []string
+	body    string
+}
+
+func (s *SipMsg) text() (*msgText, error) {
+
+	if s.eof < 0 || s.eof > len(s.Msg) {
+		return nil, errNoMessage
+	}
+
+	t := &msgText{}
+	if len(s.Msg) >= s.eof+4 {
+		t.body = s.Msg[s.eof+4:]
+	}
+	for i, line := range strings.Split(s.Msg[:s.eof], "\r\n") {
+		switch {
+		case i == 0:
+			t.start = line
+		case len(t.headers) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")):
+			t.headers[len(t.headers)-1] += "\r\n" + line
+		default:
+			t.headers = append(t.headers, line)
+		}
+	}
+	return t, nil
+}
+
+// apply serialises the text with a correct Content-Length and parses it again
+func (s *SipMsg) apply(t *msgText) error {
+
+	length := strconv.Itoa(len(t.body))
+	found := false
+	for i := 0; i < len(t.headers); i++ {
+		name, val := splitHeaderLine(t.headers[i])
+		if !sameHeader(name, "Content-Length") {
+			continue
+		}
+		if found {
+			t.headers = append(t.headers[:i], t.headers[i+1:]...)
+			i--
+			continue
+		}
+		found = true
+		if val != length {
+			t.headers[i] = name + ": " + length
+		}
+	}
+	if !found {
+		t.headers = append(t.headers, "Content-Length: "+length)
+	}
+
+	msg := t.start + "\r\n"
+	for _, h := range t.headers {
+		msg += h + "\r\n"
+	}
+	msg += "\r\n" + t.body
+
+	*s = *ParseMsg(msg, s.XHeader, s.CHeader)
+	return s.Error
+}
+
+func splitHeaderLine(line string) (string, string) {
+	colon := strings.IndexByte(line, ':')
+	if colon == -1 {
+		return cleanWs(line), ""
+	}
+	return cleanWs(line[:colon]), cleanWs(line[colon+1:])
+}
+
+// sameHeader compares header names, compact forms included
+func sameHeader(a, b string) bool {
+	if len(a) == 1 {
+		if long, ok := compactHeaders[strings.ToLower(a)]; ok {
+			a = long
+		}
+	}
+	if len(b) == 1 {
+		if long, ok := compactHeaders[strings.ToLower(b)]; ok {
+			b = long
+		}
+	}
+	return strings.EqualFold(a, b)
+}
+
+// HeaderValues returns the values of a header in order, it works on lazily
+// parsed messages too
+func (s *SipMsg) HeaderValues(name string) []string {
+
+	t, err := s.text()
+	if err != nil {
+		return nil
+	}
+	var values []string
+	for _, line := range t.headers {
+		if n, val := splitHeaderLine(line); sameHeader(n, name) {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+// SetHeader replaces the first header of the name and removes the others,
+// the header is added at the end if there is none
+func (s *SipMsg) SetHeader(name, value string) error {
+
+	t, err := s.text()
+	if err != nil {
+		return err
+	}
+	found := false
+	headers := t.headers[:0]
+	for _, line := range t.headers {
+		n, _ := splitHeaderLine(line)
+		if !sameHeader(n, name) {
+			headers = append(headers, line)
+		} else if !found {
+			headers = append(headers, n+": "+value)
+			found = true
+		}
+	}
+	if !found {
+		headers = append(headers, name+": "+value)
+	}
+	t.headers = headers
+	return s.apply(t)
+}
+
+// AddHeader adds a header after the last one of the same name, or at the end
+func (s *SipMsg) AddHeader(name, value string) error {
+
+	t, err := s.text()
+	if err != nil {
+		return err
+	}
+	pos := len(t.headers)
+	for i, line := range t.headers {
+		if n, _ := splitHeaderLine(line); sameHeader(n, name) {
+			pos = i + 1
+		}
+	}
+	t.headers = append(t.headers, "")
+	copy(t.headers[pos+1:], t.headers[pos:])
+	t.headers[pos] = name + ": " + value
+	return s.apply(t)
+}
+
+// RemoveHeader removes every header of the name and returns how many there were
+func (s *SipMsg) RemoveHeader(name string) (int, error) {
+
+	t, err := s.text()
+	if err != nil {
+		return 0, err
+	}
+	headers := t.headers[:0]
+	for _, line := range t.headers {
+		if n, _ := splitHeaderLine(line); !sameHeader(n, name) {
+			headers = append(headers, line)
+		}
+	}
+	removed := len(t.headers) - len(headers)
+	if removed == 0 {
+		return 0, nil
+	}
+	t.headers = headers
+	return removed, s.apply(t)
+}
+
+// SetBody replaces the body and its Content-Type, an empty content type removes it
+func (s *SipMsg) SetBody(contentType, body string) error {
+
+	t, err := s.text()
+	if err != nil {
+		return err
+	}
+	headers := t.headers[:0]
+	for _, line := range t.headers {
+		if n, _ := splitHeaderLine(line); !sameHeader(n, "Content-Type") {
+			headers = append(headers, line)
+		}
+	}
+	if contentType != "" {
+		headers = append(headers, "Content-Type: "+contentType)
+	}
+	t.headers = headers
+	t.body = body
+	return s.apply(t)
+}
+
+// SetRequestURI replaces the URI of a request line
+func (s *SipMsg) SetRequestURI(uri string) error {
+
+	t, err := s.text()
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(t.start, " ")
+	if len(parts) != 3 || s.StartLine == nil || s.StartLine.Type != SIP_REQUEST {
+		return errors.New("SetRequestURI err: not a request")
+	}
+	t.start = parts[0] + " " + uri + " " + parts[2]
+	return s.apply(t)
+}
+
+// RewriteURIs passes the request URI and the URIs of the address headers, like
+// From, To, Contact or P-Asserted-Identity, through fn. The header parameters stay.
+func (s *SipMsg) RewriteURIs(fn func(uri string) string) error {
+
+	t, err := s.text()
+	if err != nil {
+		return err
+	}
+
+	if parts := strings.Split(t.start, " "); len(parts) == 3 && s.StartLine != nil && s.StartLine.Type == SIP_REQUEST {
+		t.start = parts[0] + " " + fn(parts[1]) + " " + parts[2]
+	}
+
+	for i, line := range t.headers {
+		n, val := splitHeaderLine(line)
+		for _, name := range uriHeaders {
+			if sameHeader(n, name) {
+				if rewritten := rewriteValueURIs(val, fn); rewritten != val {
+					t.headers[i] = n + ": " + rewritten
+				}
+				break
+			}
+		}
+	}
+	return s.apply(t)
+}
+
+// rewriteValueURIs rewrites the <uri> parts of a value, or the addr-spec up to its
+// parameters when there are no angle brackets
+func rewriteValueURIs(val string, fn func(string) string) string {
+
+	if !strings.Contains(val, "<") {
+		end := strings.IndexAny(val, ";,")
+		if end == -1 {
+			end = len(val)
+		}
+		return fn(val[:end]) + val[end:]
+	}
+
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(val, '<')
+		if open == -1 {
+			break
+		}
+		close := strings.IndexByte(val[open:], '>')
+		if close == -1 {
+			break
+		}
+		b.WriteString(val[:open+1])
+		b.WriteString(fn(val[open+1 : open+close]))
+		b.WriteByte('>')
+		val = val[open+close+1:]
+	}
+	b.WriteString(val)
+	return b.String()
+}
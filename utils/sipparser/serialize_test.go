@@ -0,0 +1,200 @@
+// Copyright 2011, Shelby Ramsey. All rights reserved.
+// Copyright 2018, Eugen Biegler. All rights reserved.
+// Use of this code is governed by a BSD license that can be
+// found in the LICENSE.txt file.
+
+package sipparser
+
+// Imports from the go standard library
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// testMsg is a random but well formed SIP message for the property tests
+type testMsg string
+
+var testHeaderNames = []string{"Via", "v", "From", "f", "To", "t", "Call-ID", "i", "CSeq", "Contact", "m",
+	"Max-Forwards", "User-Agent", "Supported", "k", "Allow", "Subject", "X-Custom", "P-Asserted-Identity"}
+
+const testTokenChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-.!%*_+`'~"
+
+func testToken(r *rand.Rand) string {
+	b := make([]byte, 1+r.Intn(12))
+	for i := range b {
+		b[i] = testTokenChars[r.Intn(len(testTokenChars))]
+	}
+	return string(b)
+}
+
+func testHeaderValue(r *rand.Rand, name string) string {
+	switch {
+	case sameHeader(name, "Via"):
+		return "SIP/2.0/UDP " + testToken(r) + ".example.com;branch=z9hG4bK" + testToken(r)
+	case sameHeader(name, "From"), sameHeader(name, "To"), sameHeader(name, "Contact"), sameHeader(name, "P-Asserted-Identity"):
+		return "\"" + testToken(r) + "\" <sip:" + testToken(r) + "@" + testToken(r) + ".example.com>;tag=" + testToken(r)
+	case sameHeader(name, "CSeq"):
+		return strconv.Itoa(r.Intn(100000)) + " INVITE"
+	case sameHeader(name, "Max-Forwards"):
+		return strconv.Itoa(r.Intn(70))
+	}
+	return testToken(r) + " " + testToken(r)
+}
+
+// Generate implements quick.Generator
+func (testMsg) Generate(r *rand.Rand, size int) reflect.Value {
+	msg := "INVITE sip:" + testToken(r) + "@example.com SIP/2.0\r\n"
+	if r.Intn(2) == 0 {
+		msg = "SIP/2.0 " + strconv.Itoa(100+r.Intn(500)) + " " + testToken(r) + "\r\n"
+	}
+	msg += "Call-ID: " + testToken(r) + "\r\n"
+	for i := r.Intn(size + 1); i > 0; i-- {
+		name := testHeaderNames[r.Intn(len(testHeaderNames))]
+		sep := []string{": ", ":", " : ", ":  "}[r.Intn(4)]
+		msg += name + sep + testHeaderValue(r, name) + "\r\n"
+	}
+	body := ""
+	if r.Intn(2) == 0 {
+		body = "v=0\r\no=- " + strconv.Itoa(r.Int()) + " 1 IN IP4 192.0.2.1\r\n"
+		msg += "Content-Type: application/sdp\r\n"
+	}
+	msg += "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	return reflect.ValueOf(testMsg(msg))
+}
+
+func TestUntouchedLines(t *testing.T) {
+	f := func(m testMsg, value uint32) bool {
+		s := ParseMsg(string(m), nil, nil)
+		if err := s.SetHeader("X-Custom", strconv.Itoa(int(value))); err != nil {
+			return false
+		}
+		/* the other lines keep their bytes and their order */
+		var before, after []string
+		for _, line := range strings.Split(string(m), "\r\n") {
+			if n, _ := splitHeaderLine(line); !sameHeader(n, "X-Custom") && !sameHeader(n, "Content-Length") {
+				before = append(before, line)
+			}
+		}
+		for _, line := range strings.Split(s.String(), "\r\n") {
+			if n, _ := splitHeaderLine(line); !sameHeader(n, "X-Custom") && !sameHeader(n, "Content-Length") {
+				after = append(after, line)
+			}
+		}
+		return reflect.DeepEqual(before, after)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Errorf("[TestUntouchedLines] %v", err)
+	}
+}
+
+// headersBut returns all header values in order, without the named ones
+func headersBut(s *SipMsg, names ...string) []string {
+	var values []string
+	for _, h := range testHeaderNames {
+		skip := sameHeader(h, "Content-Length")
+		for _, name := range names {
+			skip = skip || sameHeader(h, name)
+		}
+		if !skip && len(h) > 1 {
+			values = append(values, strings.Join(s.HeaderValues(h), "|"))
+		}
+	}
+	return values
+}
+
+func TestSetRemoveHeader(t *testing.T) {
+	f := func(m testMsg, pick uint8, value uint32) bool {
+		name := testHeaderNames[int(pick)%len(testHeaderNames)]
+		val := testHeaderValue(rand.New(rand.NewSource(int64(value))), name)
+
+		s := ParseMsg(string(m), nil, nil)
+		others := headersBut(s, name)
+		if err := s.SetHeader(name, val); err != nil {
+			return false
+		}
+		values := s.HeaderValues(name)
+		if len(values) != 1 || values[0] != val || !reflect.DeepEqual(headersBut(s, name), others) {
+			return false
+		}
+		if ParseMsg(s.String(), nil, nil).String() != s.String() {
+			return false
+		}
+
+		if n, err := s.RemoveHeader(name); n != 1 || err != nil {
+			return false
+		}
+		return len(s.HeaderValues(name)) == 0 && reflect.DeepEqual(headersBut(s, name), others)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Errorf("[TestSetRemoveHeader] %v", err)
+	}
+}
+
+func TestSetBody(t *testing.T) {
+	f := func(m testMsg, body string) bool {
+		s := ParseMsg(string(m), nil, nil)
+		others := headersBut(s)
+		if err := s.SetBody("text/plain", body); err != nil {
+			return false
+		}
+		return s.Body == body && s.ContentLengthInt == len(body) && s.ContentType == "text/plain" &&
+			reflect.DeepEqual(headersBut(s), others)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Errorf("[TestSetBody] %v", err)
+	}
+}
+
+func TestRewriteURIs(t *testing.T) {
+	/* the identity rewrite keeps every header */
+	f := func(m testMsg) bool {
+		s := ParseMsg(string(m), nil, nil)
+		others := headersBut(s)
+		if err := s.RewriteURIs(func(uri string) string { return uri }); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(headersBut(s), others)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Errorf("[TestRewriteURIs] %v", err)
+	}
+
+	s := ParseMsg(testMsgInvite, nil, nil)
+	err := s.RewriteURIs(func(uri string) string {
+		return strings.Replace(strings.Replace(uri, "alice", "anonymous", 1), "bob", "anonymous", 1)
+	})
+	if err != nil {
+		t.Fatalf("[TestRewriteURIs] Unexpected err: %v", err)
+	}
+	if s.URIUser != "anonymous" || s.FromUser != "anonymous" || s.ToUser != "anonymous" || s.ContactUser != "anonymous" {
+		t.Errorf("[TestRewriteURIs] URIs not rewritten: %s %s %s %s", s.URIUser, s.FromUser, s.ToUser, s.ContactUser)
+	}
+	if s.FromTag != "1928301774" || s.From.Name != "Alice" || s.Sdp == nil {
+		t.Errorf("[TestRewriteURIs] Lost the header params or the body: %+v", s.From)
+	}
+
+	if err := s.SetRequestURI("sip:carol@example.com"); err != nil || s.URIUser != "carol" {
+		t.Errorf("[TestRewriteURIs] Bad request uri: %v %s", err, s.URIUser)
+	}
+	if err := ParseMsg(testMsgOk, nil, nil).SetRequestURI("sip:carol@example.com"); err == nil {
+		t.Errorf("[TestRewriteURIs] Expected an error for a response")
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	msg := "MESSAGE sip:bob@example.com SIP/2.0\r\nCall-ID: cl-1\r\nl: 99\r\nContent-Length: 1\r\n\r\nhello"
+	s := ParseMsg(msg, nil, nil)
+	if err := s.AddHeader("Subject", "fixed"); err != nil {
+		t.Fatalf("[TestContentLength] Unexpected err: %v", err)
+	}
+	if got := s.HeaderValues("Content-Length"); len(got) != 1 || got[0] != "5" || s.ContentLengthInt != 5 {
+		t.Errorf("[TestContentLength] Expected one Content-Length of 5, got: %v", got)
+	}
+	if !strings.Contains(s.String(), "\r\nl: 5\r\nSubject: fixed\r\n\r\nhello") {
+		t.Errorf("[TestContentLength] Bad message: %q", s.String())
+	}
+}
@@ -126,3 +126,52 @@ func getCommaSeperated(str string) []string {
 	}
 	return s
 }
+
+// getCommaList splits a comma separated header value, a single value
+// gives a list of one
+func getCommaList(str string) []string {
+	var list []string
+	for _, val := range strings.Split(str, ",") {
+		if val = cleanWs(val); val != "" {
+			list = append(list, val)
+		}
+	}
+	return list
+}
+
+// getRouteSet parses the <uri> entries of a Route or Record-Route value,
+// the uris parsed before an error are returned with it
+func getRouteSet(str string) ([]*URI, error) {
+	var uris []*URI
+	for _, val := range getCommaList(str) {
+		left, right, ok := getBracks(val)
+		if !ok {
+			continue
+		}
+		u := ParseURI(val[left+1 : right])
+		if u.Error != nil {
+			return uris, u.Error
+		}
+		uris = append(uris, u)
+	}
+	return uris, nil
+}
+
+// splitQuoted splits on sep outside of double quotes, i.e. qop="auth,auth-int"
+func splitQuoted(str string, sep byte) []string {
+	var parts []string
+	quoted := false
+	last := 0
+	for i := 0; i < len(str); i++ {
+		switch str[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, str[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, str[last:])
+}
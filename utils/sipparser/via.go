@@ -22,7 +22,7 @@ type vias struct {
 func (vs *vias) parse() {
 	parts := strings.Split(vs.via, ",")
 	for _, p := range parts {
-		v := &Via{Via: p}
+		v := &Via{Via: cleanWs(p)}
 		v.parse()
 		if v.Error != nil {
 			vs.err = v.Error
@@ -125,8 +125,10 @@ func parseViaGetHostPort(v *Via) viaStateFn {
 		v.Error = errors.New("parseViaGetHostPort err: protoEnd is 0")
 		return nil
 	}
-	if v.protoEnd < v.paramStart {
-		v.SentBy = v.Via[v.protoEnd+1 : v.paramStart]
+	if v.paramStart == 0 {
+		v.SentBy = cleanWs(v.Via[v.protoEnd+1:])
+	} else if v.protoEnd < v.paramStart {
+		v.SentBy = cleanWs(v.Via[v.protoEnd+1 : v.paramStart])
 	}
 	return nil
 }
@@ -0,0 +1,224 @@
+// Package stirshaken verifies the PASSporTs of SIP Identity headers (RFC 8224,
+// RFC 8588) against certificates from a local directory, so verification
+// works without fetching the x5u URL.
+package stirshaken
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/sipparser"
+)
+
+// verification status
+const (
+	StatusNone          = "none"
+	StatusValid         = "valid"
+	StatusInvalid       = "invalid"
+	StatusNoCertificate = "no_certificate"
+	StatusMalformed     = "malformed"
+	StatusUnverified    = "unverified"
+)
+
+// MaxAge is how far iat may be from the time the message was captured (RFC 8224 5.1)
+var MaxAge = 60 * time.Second
+
+// Result is the outcome of checking the Identity of a message
+type Result struct {
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Attest  string `json:"attest,omitempty"`
+	OrigTN  string `json:"orig_tn,omitempty"`
+	DestTN  string `json:"dest_tn,omitempty"`
+	OrigID  string `json:"origid,omitempty"`
+	Iat     int64  `json:"iat,omitempty"`
+	X5u     string `json:"x5u,omitempty"`
+	Subject string `json:"certificate,omitempty"`
+}
+
+// Verifier holds the certificates of a directory, a certificate is found by the
+// file name of the x5u URL or else by the key that verifies the signature
+type Verifier struct {
+	dir     string
+	mu      sync.Mutex
+	modTime time.Time
+	certs   map[string]*x509.Certificate
+}
+
+// NewVerifier returns a verifier for the certificates in dir, an empty dir
+// only parses the PASSporTs
+func NewVerifier(dir string) *Verifier {
+	return &Verifier{dir: dir, certs: map[string]*x509.Certificate{}}
+}
+
+// Check verifies the shaken Identity of a message captured at the given time
+func (v *Verifier) Check(msg *sipparser.SipMsg, at time.Time) *Result {
+
+	id := msg.Shaken()
+	if id == nil {
+		return &Result{Status: StatusNone}
+	}
+	return v.Verify(id, at)
+}
+
+// Verify verifies one Identity
+func (v *Verifier) Verify(id *sipparser.Identity, at time.Time) *Result {
+
+	r := &Result{Status: StatusMalformed}
+	if id.Header != nil {
+		r.X5u = id.Header.X5u
+	}
+	if p := id.Payload; p != nil {
+		r.Attest = p.Attest
+		r.OrigTN = p.Orig.TN
+		r.DestTN = p.DestTN()
+		r.OrigID = p.OrigID
+		r.Iat = p.Iat
+	}
+	if id.Error != nil {
+		r.Reason = id.Error.Error()
+		return r
+	}
+	if id.Header.Alg != "ES256" {
+		r.Reason = fmt.Sprintf("unsupported alg %q", id.Header.Alg)
+		return r
+	}
+
+	if v == nil || v.dir == "" {
+		r.Status = StatusUnverified
+		return r
+	}
+
+	cert, byName := v.certificate(r.X5u, id)
+	if cert == nil {
+		r.Status = StatusNoCertificate
+		r.Reason = fmt.Sprintf("no certificate for %s", r.X5u)
+		return r
+	}
+	r.Subject = cert.Subject.String()
+
+	r.Status = StatusInvalid
+	if byName && !verifySignature(cert, id) {
+		r.Reason = "bad signature"
+		return r
+	}
+
+	iat := time.Unix(r.Iat, 0)
+	if iat.Before(cert.NotBefore) || iat.After(cert.NotAfter) {
+		r.Reason = fmt.Sprintf("certificate is not valid at iat %s", iat.UTC().Format(time.RFC3339))
+		return r
+	}
+	if !at.IsZero() {
+		if age := at.Sub(iat); age > MaxAge || age < -MaxAge {
+			r.Reason = fmt.Sprintf("iat is %s from the message time", age.Round(time.Second))
+			return r
+		}
+	}
+
+	r.Status = StatusValid
+	return r
+}
+
+// certificate returns the certificate named like the x5u file, or the one which verifies
+// the signature. byName tells that the signature still has to be checked.
+func (v *Verifier) certificate(x5u string, id *sipparser.Identity) (*x509.Certificate, bool) {
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.load()
+
+	if u, err := url.Parse(x5u); err == nil && u.Path != "" {
+		if cert, ok := v.certs[path.Base(u.Path)]; ok {
+			return cert, true
+		}
+	}
+	for _, cert := range v.certs {
+		if verifySignature(cert, id) {
+			return cert, false
+		}
+	}
+	return nil, false
+}
+
+// load reads the directory again when it has changed
+func (v *Verifier) load() {
+
+	info, err := os.Stat(v.dir)
+	if err != nil || !info.ModTime().After(v.modTime) {
+		return
+	}
+	v.modTime = info.ModTime()
+
+	files, err := ioutil.ReadDir(v.dir)
+	if err != nil {
+		return
+	}
+	certs := map[string]*x509.Certificate{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(v.dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		/* the first certificate of a chain is the signing one */
+		if block, _ := pem.Decode(data); block != nil && block.Type == "CERTIFICATE" {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+				certs[f.Name()] = cert
+			}
+		}
+	}
+	v.certs = certs
+}
+
+func verifySignature(cert *x509.Certificate, id *sipparser.Identity) bool {
+
+	key, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || len(id.Signature) != 64 {
+		return false
+	}
+	hash := sha256.Sum256([]byte(id.SigningInput))
+	r := new(big.Int).SetBytes(id.Signature[:32])
+	s := new(big.Int).SetBytes(id.Signature[32:])
+	return ecdsa.Verify(key, hash[:], r, s)
+}
+
+// Fields are the virtual search fields
+var Fields = []string{"stir.status", "stir.attest", "stir.orig_tn", "stir.dest_tn"}
+
+// Match tells whether a result matches one of the values of a stir.* search field
+func (r *Result) Match(field string, values []string) bool {
+
+	var have string
+	switch field {
+	case "stir.status":
+		have = r.Status
+	case "stir.attest":
+		have = r.Attest
+	case "stir.orig_tn":
+		have = r.OrigTN
+	case "stir.dest_tn":
+		have = r.DestTN
+	default:
+		return false
+	}
+	for _, val := range values {
+		if strings.EqualFold(strings.TrimPrefix(strings.TrimSpace(val), "+"), strings.TrimPrefix(have, "+")) {
+			return true
+		}
+	}
+	return false
+}
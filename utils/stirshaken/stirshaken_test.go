@@ -0,0 +1,147 @@
+package stirshaken
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipcapture/homer-app/utils/sipparser"
+)
+
+var testIat = time.Unix(1700000000, 0)
+
+func newTestCert(t *testing.T, dir, name string) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "SHAKEN 1234"},
+		NotBefore:    testIat.Add(-time.Hour),
+		NotAfter:     testIat.Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestIdentity(t *testing.T, key *ecdsa.PrivateKey, payload string) string {
+	enc := base64.RawURLEncoding
+	input := enc.EncodeToString([]byte(`{"alg":"ES256","typ":"passport","ppt":"shaken","x5u":"https://cert.example.org/sp.pem"}`)) +
+		"." + enc.EncodeToString([]byte(payload))
+	hash := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(signature[32-len(rb):32], rb)
+	copy(signature[64-len(sb):], sb)
+	return input + "." + enc.EncodeToString(signature) + ";info=<https://cert.example.org/sp.pem>;alg=ES256;ppt=shaken"
+}
+
+func newTestMsg(identity string) *sipparser.SipMsg {
+	return sipparser.ParseMsg("INVITE sip:+12155550131@example.org SIP/2.0\r\n"+
+		"Via: SIP/2.0/UDP 192.0.2.1;branch=z9hG4bK1\r\n"+
+		"From: <sip:+12155550121@example.com>;tag=1\r\n"+
+		"To: <sip:+12155550131@example.org>\r\n"+
+		"Call-ID: stir-1\r\n"+
+		"CSeq: 1 INVITE\r\n"+
+		"Identity: "+identity+"\r\n"+
+		"\r\n", nil, nil)
+}
+
+const testPayload = `{"attest":"A","dest":{"tn":["12155550131"]},"iat":1700000000,"orig":{"tn":"12155550121"},"origid":"123e4567-e89b-12d3-a456-426655440000"}`
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := newTestCert(t, dir, "sp.pem")
+	msg := newTestMsg(newTestIdentity(t, key, testPayload))
+	if msg.Error != nil {
+		t.Fatalf("[TestVerify] Unexpected err: %v", msg.Error)
+	}
+
+	r := NewVerifier(dir).Check(msg, testIat.Add(time.Second))
+	if r.Status != StatusValid || r.Attest != "A" || r.OrigTN != "12155550121" || r.DestTN != "12155550131" || r.Subject != "CN=SHAKEN 1234" {
+		t.Errorf("[TestVerify] Expected a valid A attestation, got: %+v", r)
+	}
+	if !r.Match("stir.attest", []string{"a"}) || !r.Match("stir.orig_tn", []string{"+12155550121"}) || r.Match("stir.status", []string{"invalid"}) {
+		t.Errorf("[TestVerify] Bad match of %+v", r)
+	}
+
+	if r := NewVerifier(dir).Check(msg, testIat.Add(5*time.Minute)); r.Status != StatusInvalid {
+		t.Errorf("[TestVerify] Expected a stale iat to be invalid, got: %+v", r)
+	}
+	if r := NewVerifier("").Check(msg, testIat); r.Status != StatusUnverified || r.Attest != "A" {
+		t.Errorf("[TestVerify] Expected unverified without certificates, got: %+v", r)
+	}
+
+	/* signed by another key, the named certificate doesn't verify it */
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if r := NewVerifier(dir).Check(newTestMsg(newTestIdentity(t, other, testPayload)), testIat); r.Status != StatusInvalid || r.Reason != "bad signature" {
+		t.Errorf("[TestVerify] Expected a bad signature, got: %+v", r)
+	}
+}
+
+func TestVerifyByKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := newTestCert(t, dir, "carrier.crt")
+	msg := newTestMsg(newTestIdentity(t, key, testPayload))
+
+	v := NewVerifier(dir)
+	if r := v.Check(msg, time.Time{}); r.Status != StatusValid {
+		t.Errorf("[TestVerifyByKey] Expected the certificate to be found by its key, got: %+v", r)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if r := v.Check(newTestMsg(newTestIdentity(t, other, testPayload)), time.Time{}); r.Status != StatusNoCertificate {
+		t.Errorf("[TestVerifyByKey] Expected no certificate, got: %+v", r)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	tests := []string{
+		"abc",
+		"e30..c2ln",
+		"bm90IGpzb24.e30.c2ln",
+	}
+	for _, val := range tests {
+		r := NewVerifier("").Check(newTestMsg(val), time.Time{})
+		if r.Status != StatusMalformed || r.Reason == "" {
+			t.Errorf("[TestVerifyMalformed] Expected %q to be malformed, got: %+v", val, r)
+		}
+	}
+
+	if r := NewVerifier("").Check(sipparser.ParseMsg("OPTIONS sip:a@b SIP/2.0\r\nCall-ID: x\r\n\r\n", nil, nil), time.Time{}); r.Status != StatusNone {
+		t.Errorf("[TestVerifyMalformed] Expected no identity, got: %+v", r)
+	}
+}
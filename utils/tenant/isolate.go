@@ -0,0 +1,140 @@
+package tenant
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	isolateCaptureID   = regexp.MustCompile(`^protocol_header\s*->>\s*'captureId'\s*=\s*'?(\w+)'?$`)
+	isolateCaptureIDIn = regexp.MustCompile(`^protocol_header\s*->>\s*'captureId'\s+(?:in|IN)\s*\((.*)\)$`)
+	isolateAddress     = regexp.MustCompile(`^protocol_header\s*->>\s*'(src|dst)Ip'\s*=\s*'([^']+)'$`)
+	isolateValue       = regexp.MustCompile(`^\w+$`)
+)
+
+// ParseIsolateQuery converts the isolate_query of the group_settings into the
+// captureIds and networks of a tenant. Only the queries a tenant can express are
+// converted: captureIds compared with = or IN, and addresses compared as source or
+// destination, like (srcIp = 'a' OR dstIp = 'a'), the restrictions joined by AND.
+func ParseIsolateQuery(query string) (captureIDs, networks []string, err error) {
+
+	for _, term := range splitTop(query, "AND") {
+		if m := isolateCaptureID.FindStringSubmatch(term); m != nil {
+			if captureIDs != nil {
+				return nil, nil, fmt.Errorf("more than one captureId restriction in %q", query)
+			}
+			captureIDs = []string{m[1]}
+			continue
+		}
+		if m := isolateCaptureIDIn.FindStringSubmatch(term); m != nil {
+			if captureIDs != nil {
+				return nil, nil, fmt.Errorf("more than one captureId restriction in %q", query)
+			}
+			for _, val := range strings.Split(m[1], ",") {
+				val = strings.Trim(strings.TrimSpace(val), "'")
+				if !isolateValue.MatchString(val) {
+					return nil, nil, fmt.Errorf("bad captureId %q in %q", val, query)
+				}
+				captureIDs = append(captureIDs, val)
+			}
+			continue
+		}
+		if networks != nil {
+			return nil, nil, fmt.Errorf("can't convert %q", term)
+		}
+		if networks, err = isolateNetworks(term); err != nil {
+			return nil, nil, err
+		}
+	}
+	if captureIDs == nil && networks == nil {
+		return nil, nil, fmt.Errorf("no captureId or address in %q", query)
+	}
+	return captureIDs, networks, nil
+}
+
+// isolateNetworks converts addresses compared as source or destination, a tenant
+// can't restrict one side only
+func isolateNetworks(term string) ([]string, error) {
+
+	sides := map[string]map[string]bool{}
+	networks := []string{}
+	for _, part := range splitTop(term, "OR") {
+		m := isolateAddress.FindStringSubmatch(part)
+		if m == nil || net.ParseIP(m[2]) == nil {
+			return nil, fmt.Errorf("can't convert %q", part)
+		}
+		if sides[m[2]] == nil {
+			sides[m[2]] = map[string]bool{}
+			networks = append(networks, m[2])
+		}
+		sides[m[2]][m[1]] = true
+	}
+	for _, address := range networks {
+		if !sides[address]["src"] || !sides[address]["dst"] {
+			return nil, fmt.Errorf("the address %s is not compared as source and destination", address)
+		}
+	}
+	return networks, nil
+}
+
+// splitTop splits the query at the keyword outside of parentheses and quotes, the
+// parts lose their outer parentheses
+func splitTop(query, keyword string) []string {
+
+	query = stripParens(strings.Join(strings.Fields(query), " "))
+	sep := " " + keyword + " "
+
+	parts := []string{}
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '\'':
+			quoted = !quoted
+		case '(':
+			if !quoted {
+				depth++
+			}
+		case ')':
+			if !quoted {
+				depth--
+			}
+		case ' ':
+			if !quoted && depth == 0 && strings.EqualFold(prefix(query[i:], len(sep)), sep) {
+				parts = append(parts, stripParens(query[start:i]))
+				start = i + len(sep)
+				i += len(sep) - 1
+			}
+		}
+	}
+	return append(parts, stripParens(query[start:]))
+}
+
+func prefix(s string, n int) string {
+	if len(s) < n {
+		return s
+	}
+	return s[:n]
+}
+
+// stripParens removes the parentheses around the whole of s
+func stripParens(s string) string {
+
+	for strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		depth := 0
+		for i := 0; i < len(s); i++ {
+			if s[i] == '(' {
+				depth++
+			} else if s[i] == ')' {
+				depth--
+			}
+			/* the first parenthesis closes before the end */
+			if depth == 0 && i < len(s)-1 {
+				return s
+			}
+		}
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
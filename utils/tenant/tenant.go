@@ -0,0 +1,182 @@
+package tenant
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Filter restricts the rows of the hep_proto tables to the ones of a tenant. A nil
+// filter doesn't restrict anything. The restrictions of a filter are all applied: a
+// row must have one of the captureIds, be stored on one of the nodes, in one of the
+// profiles and have a source or destination address in one of the networks. An empty
+// restriction allows everything.
+type Filter struct {
+	Name       string
+	CaptureIDs []string
+	Nodes      []string
+	Profiles   []string
+	Networks   []*net.IPNet
+	deny       bool
+}
+
+// New returns the filter of a tenant, the networks are CIDRs or single addresses
+func New(name string, captureIDs, nodes, profiles, networks []string) (*Filter, error) {
+
+	filter := &Filter{Name: name, CaptureIDs: captureIDs, Nodes: nodes, Profiles: profiles}
+	for _, val := range networks {
+		network, err := ParseNetwork(val)
+		if err != nil {
+			return nil, err
+		}
+		filter.Networks = append(filter.Networks, network)
+	}
+	/* a tenant which sees everything is a mistake */
+	if len(captureIDs) == 0 && len(nodes) == 0 && len(profiles) == 0 && len(networks) == 0 {
+		return nil, fmt.Errorf("the tenant %q has no captureIds, nodes, profiles or networks", name)
+	}
+	return filter, nil
+}
+
+// Deny returns a filter which allows no row, used when the tenant can't be found
+func Deny(name string) *Filter {
+	return &Filter{Name: name, deny: true}
+}
+
+// ParseNetwork parses a CIDR or a single address
+func ParseNetwork(val string) (*net.IPNet, error) {
+
+	val = strings.TrimSpace(val)
+	if !strings.Contains(val, "/") {
+		ip := net.ParseIP(val)
+		if ip == nil {
+			return nil, fmt.Errorf("bad network %q", val)
+		}
+		if ip.To4() != nil {
+			return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(val)
+	if err != nil {
+		return nil, fmt.Errorf("bad network %q", val)
+	}
+	return network, nil
+}
+
+// Condition returns the where clause of the filter and its values, the clause is
+// empty when nothing is restricted
+func (f *Filter) Condition() (string, []interface{}) {
+
+	if f == nil {
+		return "", nil
+	}
+	if f.deny {
+		return "FALSE", nil
+	}
+
+	parts := []string{}
+	values := []interface{}{}
+	if len(f.CaptureIDs) > 0 {
+		parts = append(parts, "protocol_header->>'captureId' IN (?)")
+		values = append(values, f.CaptureIDs)
+	}
+	if len(f.Networks) > 0 {
+		networks := []string{}
+		for _, network := range f.Networks {
+			networks = append(networks, "NULLIF(protocol_header->>'srcIp','')::inet <<= ?::inet",
+				"NULLIF(protocol_header->>'dstIp','')::inet <<= ?::inet")
+			values = append(values, network.String(), network.String())
+		}
+		parts = append(parts, "("+strings.Join(networks, " OR ")+")")
+	}
+	return strings.Join(parts, " AND "), values
+}
+
+// AllowsNode checks if the rows of a database node can be seen
+func (f *Filter) AllowsNode(node string) bool {
+	if f == nil {
+		return true
+	}
+	return !f.deny && allows(f.Nodes, node)
+}
+
+// AllowsCaptureID checks if the rows of a captureId can be seen
+func (f *Filter) AllowsCaptureID(captureID string) bool {
+	if f == nil {
+		return true
+	}
+	return !f.deny && allows(f.CaptureIDs, captureID)
+}
+
+// AllowsTable checks if the rows of a hep_proto_<type>_<profile> table can be seen
+func (f *Filter) AllowsTable(table string) bool {
+	if f == nil {
+		return true
+	}
+	return !f.deny && allows(f.Profiles, strings.TrimPrefix(table, "hep_proto_"))
+}
+
+// Allows checks a row read of a table of a node, the rows the Condition has already
+// selected are checked again
+func (f *Filter) Allows(node, table string, protocolHeader json.RawMessage) bool {
+
+	if f == nil {
+		return true
+	}
+	if !f.AllowsNode(node) || !f.AllowsTable(table) {
+		return false
+	}
+	if len(f.CaptureIDs) == 0 && len(f.Networks) == 0 {
+		return true
+	}
+
+	header := struct {
+		CaptureID json.Number `json:"captureId"`
+		SrcIP     string      `json:"srcIp"`
+		DstIP     string      `json:"dstIp"`
+	}{}
+	decoder := json.NewDecoder(bytes.NewReader(protocolHeader))
+	decoder.UseNumber()
+	if err := decoder.Decode(&header); err != nil {
+		return false
+	}
+
+	if len(f.CaptureIDs) > 0 && !allows(f.CaptureIDs, header.CaptureID.String()) {
+		return false
+	}
+	if len(f.Networks) > 0 && !f.contains(header.SrcIP) && !f.contains(header.DstIP) {
+		return false
+	}
+	return true
+}
+
+func (f *Filter) contains(address string) bool {
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range f.Networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func allows(list []string, val string) bool {
+
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testRow struct {
+	tenant string
+	node   string
+	table  string
+	header string
+}
+
+/* the rows of two tenants and of nobody, stored side by side */
+var testRows = []testRow{
+	{"a", "node1", "hep_proto_1_call", `{"captureId":2001,"srcIp":"10.1.0.5","dstIp":"192.0.2.1"}`},
+	{"a", "node1", "hep_proto_1_call", `{"captureId":"2001","srcIp":"192.0.2.1","dstIp":"10.1.200.9"}`},
+	{"a", "node1", "hep_proto_1_registration", `{"captureId":2001,"srcIp":"2001:db8:a::1","dstIp":"192.0.2.1"}`},
+	{"b", "node1", "hep_proto_1_call", `{"captureId":2002,"srcIp":"10.2.0.5","dstIp":"192.0.2.1"}`},
+	{"b", "node2", "hep_proto_1_call", `{"captureId":2002,"srcIp":"192.0.2.1","dstIp":"10.2.3.4"}`},
+	{"b", "node2", "hep_proto_100_default", `{"captureId":2002,"srcIp":"10.2.0.5","dstIp":"10.2.0.6"}`},
+	/* the captureId of a, the addresses of b */
+	{"", "node1", "hep_proto_1_call", `{"captureId":2001,"srcIp":"10.2.0.5","dstIp":"192.0.2.1"}`},
+	/* the addresses of a, the captureId of b */
+	{"", "node1", "hep_proto_1_call", `{"captureId":2002,"srcIp":"10.1.0.5","dstIp":"10.1.0.6"}`},
+	{"", "node1", "hep_proto_1_call", `{"srcIp":"10.1.0.5","dstIp":"10.1.0.6"}`},
+	{"", "node1", "hep_proto_1_call", `{"captureId":2001,"srcIp":"","dstIp":""}`},
+	{"", "node1", "hep_proto_1_call", `not json`},
+	{"", "node3", "hep_proto_1_call", `{"captureId":2003,"srcIp":"172.16.0.1","dstIp":"172.16.0.2"}`},
+}
+
+func testFilters(t *testing.T) map[string]*Filter {
+
+	a, err := New("a", []string{"2001"}, []string{"node1"}, []string{"1_call", "1_registration"},
+		[]string{"10.1.0.0/16", "2001:db8:a::/48"})
+	if err != nil {
+		t.Fatalf("[testFilters] %v", err)
+	}
+	b, err := New("b", []string{"2002"}, nil, nil, []string{"10.2.0.0/16"})
+	if err != nil {
+		t.Fatalf("[testFilters] %v", err)
+	}
+	return map[string]*Filter{"a": a, "b": b}
+}
+
+func TestIsolation(t *testing.T) {
+
+	for name, filter := range testFilters(t) {
+		seen := 0
+		for i, row := range testRows {
+			allowed := filter.Allows(row.node, row.table, json.RawMessage(row.header))
+			if allowed && row.tenant != name {
+				t.Errorf("[TestIsolation] tenant %s sees row %d of %q", name, i, row.tenant)
+			}
+			if !allowed && row.tenant == name {
+				t.Errorf("[TestIsolation] tenant %s doesn't see its row %d", name, i)
+			}
+			if allowed {
+				seen++
+			}
+		}
+		if seen == 0 {
+			t.Errorf("[TestIsolation] tenant %s sees nothing", name)
+		}
+	}
+}
+
+func TestDeny(t *testing.T) {
+
+	filter := Deny("gone")
+	for i, row := range testRows {
+		if filter.Allows(row.node, row.table, json.RawMessage(row.header)) {
+			t.Errorf("[TestDeny] row %d allowed", i)
+		}
+	}
+	if filter.AllowsNode("node1") || filter.AllowsTable("hep_proto_1_call") {
+		t.Errorf("[TestDeny] node or table allowed")
+	}
+	if sql, _ := filter.Condition(); sql != "FALSE" {
+		t.Errorf("[TestDeny] condition %q", sql)
+	}
+}
+
+func TestNoTenant(t *testing.T) {
+
+	var filter *Filter
+	for i, row := range testRows {
+		if !filter.Allows(row.node, row.table, json.RawMessage(row.header)) {
+			t.Errorf("[TestNoTenant] row %d not allowed", i)
+		}
+	}
+	if sql, values := filter.Condition(); sql != "" || values != nil {
+		t.Errorf("[TestNoTenant] condition %q %v", sql, values)
+	}
+}
+
+func TestCondition(t *testing.T) {
+
+	filter := testFilters(t)["a"]
+	sql, values := filter.Condition()
+
+	expected := "protocol_header->>'captureId' IN (?) AND (" +
+		"NULLIF(protocol_header->>'srcIp','')::inet <<= ?::inet OR NULLIF(protocol_header->>'dstIp','')::inet <<= ?::inet OR " +
+		"NULLIF(protocol_header->>'srcIp','')::inet <<= ?::inet OR NULLIF(protocol_header->>'dstIp','')::inet <<= ?::inet)"
+	if sql != expected {
+		t.Errorf("[TestCondition] got %q", sql)
+	}
+	expectedValues := []interface{}{[]string{"2001"}, "10.1.0.0/16", "10.1.0.0/16", "2001:db8:a::/48", "2001:db8:a::/48"}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("[TestCondition] got values %v", values)
+	}
+
+	/* every ? has a value, the values are never part of the SQL */
+	marks := 0
+	for _, char := range sql {
+		if char == '?' {
+			marks++
+		}
+	}
+	if marks != len(values) {
+		t.Errorf("[TestCondition] %d placeholders for %d values", marks, len(values))
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	tests := []struct {
+		networks []string
+		captures []string
+		fails    bool
+	}{
+		{[]string{"10.0.0.0/8"}, nil, false},
+		{[]string{"192.168.1.10"}, nil, false},
+		{[]string{"2001:db8::1"}, nil, false},
+		{[]string{"10.0.0.0/33"}, nil, true},
+		{[]string{"carrier"}, nil, true},
+		{nil, []string{"2001"}, false},
+		{nil, nil, true},
+	}
+
+	for _, test := range tests {
+		_, err := New("t", test.captures, nil, nil, test.networks)
+		if (err != nil) != test.fails {
+			t.Errorf("[TestNew] %v %v: got error %v", test.networks, test.captures, err)
+		}
+	}
+
+	network, _ := ParseNetwork("192.168.1.10")
+	if fmt.Sprint(network) != "192.168.1.10/32" {
+		t.Errorf("[TestNew] single address got %v", network)
+	}
+}
+
+func TestParseIsolateQuery(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		captures []string
+		networks []string
+		fails    bool
+	}{
+		{"protocol_header->>'captureId' = '2001'", []string{"2001"}, nil, false},
+		{"(protocol_header->>'captureId' IN ('2001', '2002'))", []string{"2001", "2002"}, nil, false},
+		{"(protocol_header->>'srcIp' = '10.1.0.5' OR protocol_header->>'dstIp' = '10.1.0.5')",
+			nil, []string{"10.1.0.5"}, false},
+		{"protocol_header->>'captureId' = 2001 and (protocol_header->>'srcIp' = '10.1.0.5' or protocol_header->>'dstIp' = '10.1.0.5')",
+			[]string{"2001"}, []string{"10.1.0.5"}, false},
+		/* one side only would become both sides */
+		{"protocol_header->>'srcIp' = '10.1.0.5'", nil, nil, true},
+		{"protocol_header->>'captureId' = '2001' OR 1=1", nil, nil, true},
+		{"data_header->>'callid' LIKE 'acme%'", nil, nil, true},
+		{"protocol_header->>'captureId' IN ('2001', '1; DROP')", nil, nil, true},
+		{"", nil, nil, true},
+	}
+
+	for _, test := range tests {
+		captures, networks, err := ParseIsolateQuery(test.query)
+		if (err != nil) != test.fails {
+			t.Errorf("[TestParseIsolateQuery] %q: got error %v", test.query, err)
+			continue
+		}
+		if !test.fails && (!reflect.DeepEqual(captures, test.captures) || !reflect.DeepEqual(networks, test.networks)) {
+			t.Errorf("[TestParseIsolateQuery] %q: got %v %v", test.query, captures, networks)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* the SHA1 secret of RFC 6238 appendix B */
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+
+	/* RFC 6238 appendix B, the last 6 of the 8 digits */
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		got, err := Code(rfcSecret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("[TestCode] %v", err)
+		}
+		if got != expected {
+			t.Errorf("[TestCode] at %d got %s, expected %s", unix, got, expected)
+		}
+	}
+	if _, err := Code("not base32!", time.Now()); err == nil {
+		t.Errorf("[TestCode] invalid secret accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	now := time.Unix(1111111111, 0)
+
+	counter, ok := Validate(rfcSecret, "050471", now)
+	if !ok || counter != Counter(now) {
+		t.Errorf("[TestValidate] the code of now: %d %v", counter, ok)
+	}
+	/* the code of the step before, a phone a bit late */
+	if counter, ok := Validate(rfcSecret, "081804", now); !ok || counter != Counter(now)-1 {
+		t.Errorf("[TestValidate] the code of the step before: %d %v", counter, ok)
+	}
+	if _, ok := Validate(rfcSecret, " 050 471 ", now); !ok {
+		t.Errorf("[TestValidate] spaces should be ignored")
+	}
+	if _, ok := Validate(strings.ToLower(rfcSecret), "050471", now); !ok {
+		t.Errorf("[TestValidate] a lowercase secret should work")
+	}
+	for _, code := range []string{"050472", "", "50471", "0504710", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("[TestValidate] code %q accepted", code)
+		}
+	}
+	/* too old */
+	if _, ok := Validate(rfcSecret, "050471", now.Add(5*Period*time.Second)); ok {
+		t.Errorf("[TestValidate] an old code has been accepted")
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("[TestNewSecret] %v", err)
+	}
+	other, _ := NewSecret()
+	if secret == other || len(secret) != 32 || strings.Contains(secret, "=") {
+		t.Errorf("[TestNewSecret] bad secrets %s %s", secret, other)
+	}
+	code, _ := Code(secret, time.Now())
+	if _, ok := Validate(secret, code, time.Now()); !ok {
+		t.Errorf("[TestNewSecret] the code of a new secret isn't valid")
+	}
+}
+
+func TestURI(t *testing.T) {
+
+	uri := URI("Homer", "jdoe@example.com", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("[TestURI] %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Homer:jdoe@example.com" {
+		t.Errorf("[TestURI] bad URI %s", uri)
+	}
+	if u.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || u.Query().Get("issuer") != "Homer" || u.Query().Get("digits") != "6" {
+		t.Errorf("[TestURI] bad parameters %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+
+	codes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("[TestRecoveryCodes] %v", err)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Errorf("[TestRecoveryCodes] bad code %s", code)
+		}
+		seen[code] = true
+		if NormalizeRecoveryCode(" "+strings.ToUpper(strings.Replace(code, "-", "", 1))+" ") != code {
+			t.Errorf("[TestRecoveryCodes] %s isn't found again", code)
+		}
+	}
+	if len(codes) != 10 {
+		t.Errorf("[TestRecoveryCodes] %d codes, expected 10", len(codes))
+	}
+}